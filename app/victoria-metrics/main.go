@@ -50,6 +50,9 @@ func main() {
 	logger.Init()
 	pushmetrics.Init()
 
+	if promscrape.IsConfigCheck() {
+		os.Exit(promscrape.RunConfigCheck(os.Stdout))
+	}
 	if promscrape.IsDryRun() {
 		*dryRun = true
 	}
@@ -76,7 +79,7 @@ func main() {
 	vminsert.Init()
 	startSelfScraper()
 
-	go httpserver.Serve(*httpListenAddr, *useProxyProtocol, requestHandler)
+	go httpserver.Serve(*httpListenAddr, *useProxyProtocol, httpserver.WithDetailedMetrics(requestHandler, detailedMetricsPathPrefixes))
 	logger.Infof("started VictoriaMetrics in %.3f seconds", time.Since(startTime).Seconds())
 
 	sig := procutil.WaitForSigterm()
@@ -100,6 +103,37 @@ func main() {
 	logger.Infof("the VictoriaMetrics has been stopped in %.3f seconds", time.Since(startTime).Seconds())
 }
 
+// detailedMetricsPathPrefixes contains path prefixes for vm_http_* histograms exposed when -http.detailedMetrics is set.
+//
+// Add new endpoints served by requestHandler here.
+var detailedMetricsPathPrefixes = []string{
+	// vminsert endpoints
+	"/api/v1/write", "/prometheus/api/v1/write",
+	"/api/v1/import", "/prometheus/api/v1/import",
+	"/api/v1/import/csv", "/prometheus/api/v1/import/csv",
+	"/api/v1/import/native", "/prometheus/api/v1/import/native",
+	"/api/v1/import/prometheus", "/prometheus/api/v1/import/prometheus",
+	"/influx/write", "/influx/api/v2/write", "/write", "/api/v2/write",
+	"/datadog/",
+	"/debug/ingest/sample",
+
+	// vmselect endpoints
+	"/api/v1/query", "/prometheus/api/v1/query",
+	"/api/v1/query_range", "/prometheus/api/v1/query_range",
+	"/api/v1/series", "/prometheus/api/v1/series",
+	"/api/v1/labels", "/prometheus/api/v1/labels",
+	"/api/v1/label/", "/prometheus/api/v1/label/",
+	"/api/v1/export", "/prometheus/api/v1/export",
+	"/api/v1/status/", "/prometheus/api/v1/status/",
+	"/api/v1/admin/tsdb/delete_series", "/prometheus/api/v1/admin/tsdb/delete_series",
+	"/federate", "/prometheus/federate",
+	"/graphite/", "/render", "/metrics/find", "/tags",
+	"/vmui", "/graph",
+
+	// vmstorage endpoints
+	"/snapshot/", "/internal/",
+}
+
 func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 	if r.URL.Path == "/" {
 		if r.Method != http.MethodGet {
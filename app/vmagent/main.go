@@ -95,6 +95,9 @@ func main() {
 	logger.Init()
 	pushmetrics.Init()
 
+	if promscrape.IsConfigCheck() {
+		os.Exit(promscrape.RunConfigCheck(os.Stdout))
+	}
 	if promscrape.IsDryRun() {
 		if err := promscrape.CheckConfig(); err != nil {
 			logger.Fatalf("error when checking -promscrape.config: %s", err)
@@ -138,9 +141,15 @@ func main() {
 	}
 
 	promscrape.Init(remotewrite.Push)
+	httpserver.RegisterReadinessCheck("promscrape", func() error {
+		if n := atomic.LoadInt32(&promscrape.PendingScrapeConfigs); n > 0 {
+			return fmt.Errorf("waiting for scrape config to init targets, configs left: %d", n)
+		}
+		return nil
+	})
 
 	if len(*httpListenAddr) > 0 {
-		go httpserver.Serve(*httpListenAddr, *useProxyProtocol, requestHandler)
+		go httpserver.Serve(*httpListenAddr, *useProxyProtocol, httpserver.WithDetailedMetrics(requestHandler, detailedMetricsPathPrefixes))
 	}
 	logger.Infof("started vmagent in %.3f seconds", time.Since(startTime).Seconds())
 
@@ -210,6 +219,23 @@ func getAuthTokenFromPath(path string) (*auth.Token, error) {
 	return auth.NewToken(p.AuthToken)
 }
 
+// detailedMetricsPathPrefixes contains path prefixes for vm_http_* histograms exposed when -http.detailedMetrics is set.
+//
+// Add new endpoints served by requestHandler here.
+var detailedMetricsPathPrefixes = []string{
+	"/api/v1/write", "/prometheus/api/v1/write",
+	"/api/v1/import", "/prometheus/api/v1/import",
+	"/api/v1/import/csv", "/prometheus/api/v1/import/csv",
+	"/api/v1/import/native", "/prometheus/api/v1/import/native",
+	"/api/v1/import/prometheus", "/prometheus/api/v1/import/prometheus",
+	"/influx/write", "/influx/api/v2/write", "/write", "/api/v2/write",
+	"/datadog/",
+	"/api/v1/targets", "/prometheus/api/v1/targets",
+	"/targets", "/prometheus/targets",
+	"/service-discovery", "/prometheus/service-discovery",
+	"/-/reload", "/prometheus/-/reload",
+}
+
 func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 	if r.URL.Path == "/" {
 		if r.Method != http.MethodGet {
@@ -397,16 +423,6 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 		procutil.SelfSIGHUP()
 		w.WriteHeader(http.StatusOK)
 		return true
-	case "/ready":
-		if rdy := atomic.LoadInt32(&promscrape.PendingScrapeConfigs); rdy > 0 {
-			errMsg := fmt.Sprintf("waiting for scrapes to init, left: %d", rdy)
-			http.Error(w, errMsg, http.StatusTooEarly)
-		} else {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("OK"))
-		}
-		return true
 	default:
 		if strings.HasPrefix(r.URL.Path, "/static") {
 			staticServer.ServeHTTP(w, r)
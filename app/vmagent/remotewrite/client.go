@@ -2,15 +2,20 @@ package remotewrite
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/awsapi"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/persistentqueue"
@@ -29,8 +34,14 @@ var (
 	rateLimit = flagutil.NewArrayInt("remoteWrite.rateLimit", "Optional rate limit in bytes per second for data sent to the corresponding -remoteWrite.url. "+
 		"By default the rate limit is disabled. It can be useful for limiting load on remote storage when big amounts of buffered data "+
 		"is sent after temporary unavailability of the remote storage")
-	sendTimeout = flagutil.NewArrayDuration("remoteWrite.sendTimeout", "Timeout for sending a single block of data to the corresponding -remoteWrite.url")
-	proxyURL    = flagutil.NewArrayString("remoteWrite.proxyURL", "Optional proxy URL for writing data to the corresponding -remoteWrite.url. "+
+	autoscaleConcurrency = flagutil.NewArrayBool("remoteWrite.autoscaleConcurrency", "Whether to automatically reduce the number of concurrent requests "+
+		"to the corresponding -remoteWrite.url on sustained 429 and 5xx responses and to increase it back up to -remoteWrite.queues on successful responses. "+
+		"By default -remoteWrite.queues concurrent requests are sent. See https://docs.victoriametrics.com/vmagent.html#adaptive-concurrency")
+	sendTimeout          = flagutil.NewArrayDuration("remoteWrite.sendTimeout", "Timeout for sending a single block of data to the corresponding -remoteWrite.url")
+	shutdownFlushTimeout = flag.Duration("remoteWrite.shutdownFlushTimeout", 5*time.Second, "The maximum duration to wait for sending the in-flight data "+
+		"to -remoteWrite.url during graceful shutdown. The data, which isn't sent during this time, is persisted to -remoteWrite.tmpDataPath, "+
+		"so it is sent after the restart. See https://docs.victoriametrics.com/vmagent.html#graceful-shutdown")
+	proxyURL = flagutil.NewArrayString("remoteWrite.proxyURL", "Optional proxy URL for writing data to the corresponding -remoteWrite.url. "+
 		"Supported proxies: http, https, socks5. Example: -remoteWrite.proxyURL=socks5://proxy:1234")
 
 	tlsInsecureSkipVerify = flagutil.NewArrayBool("remoteWrite.tlsInsecureSkipVerify", "Whether to skip tls verification when connecting to the corresponding -remoteWrite.url")
@@ -73,12 +84,20 @@ var (
 )
 
 type client struct {
+	// pauseUntil is unix timestamp in nanoseconds until sending must be paused because of Retry-After response header.
+	//
+	// It is put at the top of the struct in order to guarantee 64-bit alignment for atomic access on 32-bit arch.
+	pauseUntil int64
+
 	sanitizedURL   string
 	remoteWriteURL string
 
 	// Whether to use VictoriaMetrics remote write protocol for sending the data to remoteWriteURL
 	useVMProto bool
 
+	// otlpCfg is set if the data must be sent to remoteWriteURL in OpenTelemetry format
+	otlpCfg *otlpConfig
+
 	fq *persistentqueue.FastQueue
 	hc *http.Client
 
@@ -86,8 +105,14 @@ type client struct {
 	authCfg   *promauth.Config
 	awsCfg    *awsapi.Config
 
+	// hmacSigner is used for signing request bodies if -remoteWrite.hmac.secret is set
+	hmacSigner *hmacSigner
+
 	rl rateLimiter
 
+	// cl limits the number of concurrent requests if -remoteWrite.autoscaleConcurrency is set. It is nil otherwise.
+	cl *concurrencyLimiter
+
 	bytesSent       *metrics.Counter
 	blocksSent      *metrics.Counter
 	requestDuration *metrics.Histogram
@@ -97,6 +122,7 @@ type client struct {
 	rateLimit       *metrics.Gauge
 	retriesCount    *metrics.Counter
 	sendDuration    *metrics.FloatCounter
+	pausesCount     *metrics.Counter
 
 	wg     sync.WaitGroup
 	stopCh chan struct{}
@@ -112,6 +138,14 @@ func newHTTPClient(argIdx int, remoteWriteURL, sanitizedURL string, fq *persiste
 	if err != nil {
 		logger.Fatalf("FATAL: cannot initialize AWS Config for remoteWrite.url=%q: %s", remoteWriteURL, err)
 	}
+	hs, err := getHMACSigner(argIdx)
+	if err != nil {
+		logger.Fatalf("FATAL: cannot initialize HMAC signing for remoteWrite.url=%q: %s", remoteWriteURL, err)
+	}
+	otlpCfg, err := getOTLPConfig(argIdx)
+	if err != nil {
+		logger.Fatalf("FATAL: cannot initialize data format for remoteWrite.url=%q: %s", remoteWriteURL, err)
+	}
 	tr := &http.Transport{
 		DialContext:         statDial,
 		TLSClientConfig:     tlsCfg,
@@ -141,6 +175,8 @@ func newHTTPClient(argIdx int, remoteWriteURL, sanitizedURL string, fq *persiste
 		remoteWriteURL: remoteWriteURL,
 		authCfg:        authCfg,
 		awsCfg:         awsCfg,
+		hmacSigner:     hs,
+		otlpCfg:        otlpCfg,
 		fq:             fq,
 		hc:             hc,
 		stopCh:         make(chan struct{}),
@@ -152,7 +188,11 @@ func newHTTPClient(argIdx int, remoteWriteURL, sanitizedURL string, fq *persiste
 	if useVMProto && usePromProto {
 		logger.Fatalf("-remoteWrite.useVMProto and -remoteWrite.usePromProto cannot be set simultaneously for -remoteWrite.url=%s", sanitizedURL)
 	}
-	if !useVMProto && !usePromProto {
+	if otlpCfg != nil {
+		if useVMProto {
+			logger.Fatalf("-remoteWrite.forceVMProto cannot be set for -remoteWrite.url=%s with -remoteWrite.format=otlp", sanitizedURL)
+		}
+	} else if !useVMProto && !usePromProto {
 		// Auto-detect whether the remote storage supports VictoriaMetrics remote write protocol.
 		doRequest := func(url string) (*http.Response, error) {
 			return c.doRequest(url, nil)
@@ -186,9 +226,20 @@ func (c *client) init(argIdx, concurrency int, sanitizedURL string) {
 	c.packetsDropped = metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_packets_dropped_total{url=%q}`, c.sanitizedURL))
 	c.retriesCount = metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_retries_count_total{url=%q}`, c.sanitizedURL))
 	c.sendDuration = metrics.GetOrCreateFloatCounter(fmt.Sprintf(`vmagent_remotewrite_send_duration_seconds_total{url=%q}`, c.sanitizedURL))
+	c.pausesCount = metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_retry_after_pauses_total{url=%q}`, c.sanitizedURL))
 	metrics.GetOrCreateGauge(fmt.Sprintf(`vmagent_remotewrite_queues{url=%q}`, c.sanitizedURL), func() float64 {
 		return float64(*queues)
 	})
+	if autoscaleConcurrency.GetOptionalArg(argIdx) {
+		logger.Infof("enabling adaptive concurrency with up to %d concurrent requests for -remoteWrite.url=%q", concurrency, sanitizedURL)
+		c.cl = newConcurrencyLimiter(concurrency)
+	}
+	metrics.GetOrCreateGauge(fmt.Sprintf(`vmagent_remotewrite_effective_concurrency{url=%q}`, c.sanitizedURL), func() float64 {
+		if c.cl == nil {
+			return float64(concurrency)
+		}
+		return float64(c.cl.getLimit())
+	})
 	for i := 0; i < concurrency; i++ {
 		c.wg.Add(1)
 		go func() {
@@ -304,15 +355,14 @@ func (c *client) runWorker() {
 			c.fq.MustWriteBlock(block)
 			return
 		case <-c.stopCh:
-			// c must be stopped. Wait for a while in the hope the block will be sent.
-			graceDuration := 5 * time.Second
+			// c must be stopped. Wait for up to -remoteWrite.shutdownFlushTimeout in the hope the block will be sent.
 			select {
 			case ok := <-ch:
 				if !ok {
 					// Return unsent block to the queue.
 					c.fq.MustWriteBlock(block)
 				}
-			case <-time.After(graceDuration):
+			case <-time.After(*shutdownFlushTimeout):
 				// Return unsent block to the queue.
 				c.fq.MustWriteBlock(block)
 			}
@@ -331,13 +381,20 @@ func (c *client) doRequest(url string, body []byte) (*http.Response, error) {
 	h := req.Header
 	h.Set("User-Agent", "vmagent")
 	h.Set("Content-Type", "application/x-protobuf")
-	if c.useVMProto {
+	if c.otlpCfg != nil {
+		h.Set("Content-Encoding", "gzip")
+	} else if c.useVMProto {
 		h.Set("Content-Encoding", "zstd")
 		h.Set("X-VictoriaMetrics-Remote-Write-Version", "1")
 	} else {
 		h.Set("Content-Encoding", "snappy")
 		h.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
 	}
+	if c.hmacSigner != nil {
+		// The signature is calculated on every call, so retried requests are signed too.
+		sig := c.hmacSigner.appendSignature(nil, body)
+		h.Set(c.hmacSigner.header, bytesutil.ToUnsafeString(sig))
+	}
 	if c.awsCfg != nil {
 		sigv4Hash := awsapi.HashHex(body)
 		if err := c.awsCfg.SignRequest(req, sigv4Hash); err != nil {
@@ -358,10 +415,17 @@ func (c *client) sendBlockHTTP(block []byte) bool {
 	retriesCount := 0
 
 again:
+	if !c.waitForRetryAfter() {
+		return false
+	}
+	if !c.cl.acquire(c.stopCh) {
+		return false
+	}
 	startTime := time.Now()
 	resp, err := c.doRequest(c.remoteWriteURL, block)
 	c.requestDuration.UpdateDuration(startTime)
 	if err != nil {
+		c.cl.release(false)
 		c.errorsCount.Inc()
 		retryDuration *= 2
 		if retryDuration > time.Minute {
@@ -381,6 +445,7 @@ again:
 		goto again
 	}
 	statusCode := resp.StatusCode
+	c.cl.release(statusCode != 429 && statusCode/100 != 5)
 	if statusCode/100 == 2 {
 		_ = resp.Body.Close()
 		c.requestsOKCount.Inc()
@@ -414,15 +479,23 @@ again:
 	if retryDuration > time.Minute {
 		retryDuration = time.Minute
 	}
+	sleepDuration := retryDuration
+	if statusCode == 429 || statusCode == 503 {
+		if retryAfter := parseRetryAfterHeader(resp.Header.Get("Retry-After"), time.Now()); retryAfter > 0 {
+			// Pause sending to c.remoteWriteURL from all the workers for the duration requested by the remote storage.
+			c.pauseFor(retryAfter)
+			sleepDuration = retryAfter
+		}
+	}
 	body, err := io.ReadAll(resp.Body)
 	_ = resp.Body.Close()
 	if err != nil {
 		logger.Errorf("cannot read response body from %q during retry #%d: %s", c.sanitizedURL, retriesCount, err)
 	} else {
 		logger.Errorf("unexpected status code received after sending a block with size %d bytes to %q during retry #%d: %d; response body=%q; "+
-			"re-sending the block in %.3f seconds", len(block), c.sanitizedURL, retriesCount, statusCode, body, retryDuration.Seconds())
+			"re-sending the block in %.3f seconds", len(block), c.sanitizedURL, retriesCount, statusCode, body, sleepDuration.Seconds())
 	}
-	t := timerpool.Get(retryDuration)
+	t := timerpool.Get(sleepDuration)
 	select {
 	case <-c.stopCh:
 		timerpool.Put(t)
@@ -436,21 +509,105 @@ again:
 
 var remoteWriteRejectedLogger = logger.WithThrottler("remoteWriteRejected", 5*time.Second)
 
+// maxRetryAfterDuration limits the pause duration requested via Retry-After response header,
+// so misconfigured remote storage couldn't stop sending data for too long.
+const maxRetryAfterDuration = time.Hour
+
+// parseRetryAfterHeader returns the duration to wait at currentTime according to the given Retry-After header value.
+//
+// The value may contain either the number of seconds or HTTP-date. See https://www.rfc-editor.org/rfc/rfc9110#field.retry-after
+// Zero is returned if the value is empty or invalid.
+func parseRetryAfterHeader(s string, currentTime time.Time) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		if secs > int64(maxRetryAfterDuration/time.Second) {
+			return maxRetryAfterDuration
+		}
+		d = time.Duration(secs) * time.Second
+	} else {
+		t, err := http.ParseTime(s)
+		if err != nil {
+			return 0
+		}
+		d = t.Sub(currentTime)
+	}
+	if d <= 0 {
+		return 0
+	}
+	if d > maxRetryAfterDuration {
+		d = maxRetryAfterDuration
+	}
+	return d
+}
+
+// pauseFor pauses sending data to c.remoteWriteURL for the given duration d.
+func (c *client) pauseFor(d time.Duration) {
+	pauseUntil := time.Now().Add(d).UnixNano()
+	for {
+		n := atomic.LoadInt64(&c.pauseUntil)
+		if n >= pauseUntil {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&c.pauseUntil, n, pauseUntil) {
+			c.pausesCount.Inc()
+			return
+		}
+	}
+}
+
+// waitForRetryAfter waits until the pause set via pauseFor ends.
+//
+// It returns false if c.stopCh is closed before that.
+func (c *client) waitForRetryAfter() bool {
+	d := time.Until(time.Unix(0, atomic.LoadInt64(&c.pauseUntil)))
+	if d <= 0 {
+		return true
+	}
+	t := timerpool.Get(d)
+	select {
+	case <-c.stopCh:
+		timerpool.Put(t)
+		return false
+	case <-t.C:
+		timerpool.Put(t)
+		return true
+	}
+}
+
 type rateLimiter struct {
 	perSecondLimit int64
 
-	// mu protects budget and deadline from concurrent access.
+	// mu protects budget and lastUpdateMsecs from concurrent access.
 	mu sync.Mutex
 
-	// The current budget. It is increased by perSecondLimit every second.
+	// The current budget. It is increased by perSecondLimit/1000 every millisecond up to maxRateLimiterBudget(perSecondLimit),
+	// so short bursts of data are spread over time instead of being sent at once in the beginning of every second.
 	budget int64
 
-	// The next deadline for increasing the budget by perSecondLimit
-	deadline time.Time
+	// The last time in unix milliseconds when the budget was increased.
+	lastUpdateMsecs uint64
 
 	limitReached *metrics.Counter
 }
 
+// maxRateLimiterBudget returns the maximum budget for the given perSecondLimit.
+//
+// The budget is limited by 100ms of data, so idle periods do not allow sending big bursts of data afterwards.
+func maxRateLimiterBudget(perSecondLimit int64) int64 {
+	n := perSecondLimit / 10
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
 func (rl *rateLimiter) register(dataLen int, stopCh <-chan struct{}) {
 	limit := rl.perSecondLimit
 	if limit <= 0 {
@@ -460,20 +617,44 @@ func (rl *rateLimiter) register(dataLen int, stopCh <-chan struct{}) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
+	rl.updateBudget(limit, fasttime.UnixMilli())
 	for rl.budget <= 0 {
-		if d := time.Until(rl.deadline); d > 0 {
-			rl.limitReached.Inc()
-			t := timerpool.Get(d)
-			select {
-			case <-stopCh:
-				timerpool.Put(t)
-				return
-			case <-t.C:
-				timerpool.Put(t)
-			}
+		// Wait until the budget becomes positive.
+		rl.limitReached.Inc()
+		d := time.Duration((1-rl.budget)*1000/limit+1) * time.Millisecond
+		t := timerpool.Get(d)
+		select {
+		case <-stopCh:
+			timerpool.Put(t)
+			return
+		case <-t.C:
+			timerpool.Put(t)
 		}
-		rl.budget += limit
-		rl.deadline = time.Now().Add(time.Second)
+		rl.updateBudget(limit, fasttime.UnixMilli())
 	}
 	rl.budget -= int64(dataLen)
 }
+
+// updateBudget increases rl.budget according to the time passed since the previous update till currentMsecs.
+func (rl *rateLimiter) updateBudget(limit int64, currentMsecs uint64) {
+	maxBudget := maxRateLimiterBudget(limit)
+	if rl.lastUpdateMsecs == 0 {
+		rl.budget = maxBudget
+		rl.lastUpdateMsecs = currentMsecs
+		return
+	}
+	if currentMsecs <= rl.lastUpdateMsecs {
+		return
+	}
+	n := limit * int64(currentMsecs-rl.lastUpdateMsecs) / 1000
+	if n <= 0 {
+		// Too small time passed since the last update for the given limit.
+		// Do not update lastUpdateMsecs, so the budget is increased on the next call.
+		return
+	}
+	rl.budget += n
+	if rl.budget > maxBudget {
+		rl.budget = maxBudget
+	}
+	rl.lastUpdateMsecs = currentMsecs
+}
@@ -0,0 +1,102 @@
+package remotewrite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func TestParseRetryAfterHeader(t *testing.T) {
+	currentTime := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	f := func(s string, resultExpected time.Duration) {
+		t.Helper()
+		result := parseRetryAfterHeader(s, currentTime)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for Retry-After=%q; got %s; want %s", s, result, resultExpected)
+		}
+	}
+	// Empty or invalid value
+	f("", 0)
+	f("foobar", 0)
+	f("-10", 0)
+	f("0", 0)
+
+	// Seconds
+	f("120", 2*time.Minute)
+	f(" 5 ", 5*time.Second)
+	f("100000", maxRetryAfterDuration)
+
+	// HTTP-date
+	f("Wed, 05 Apr 2023 06:08:08 GMT", time.Minute)
+	f("Wed, 05 Apr 2023 06:00:00 GMT", 0)
+	f("Thu, 06 Apr 2023 06:07:08 GMT", maxRetryAfterDuration)
+}
+
+func TestClientPauseFor(t *testing.T) {
+	c := &client{
+		stopCh: make(chan struct{}),
+	}
+	c.pausesCount = metrics.NewSet().NewCounter("pauses_total")
+	if !c.waitForRetryAfter() {
+		t.Fatalf("waitForRetryAfter must return true when there is no pause")
+	}
+
+	c.pauseFor(50 * time.Millisecond)
+	// Shorter pause mustn't override the longer one
+	c.pauseFor(time.Millisecond)
+	startTime := time.Now()
+	if !c.waitForRetryAfter() {
+		t.Fatalf("waitForRetryAfter must return true when c isn't stopped")
+	}
+	if d := time.Since(startTime); d < 40*time.Millisecond {
+		t.Fatalf("too small pause duration: %s", d)
+	}
+	if n := c.pausesCount.Get(); n != 1 {
+		t.Fatalf("unexpected number of pauses; got %d; want 1", n)
+	}
+
+	c.pauseFor(time.Hour)
+	close(c.stopCh)
+	if c.waitForRetryAfter() {
+		t.Fatalf("waitForRetryAfter must return false when c is stopped")
+	}
+}
+
+func TestRateLimiterUpdateBudget(t *testing.T) {
+	const limit = 1000
+	var rl rateLimiter
+	f := func(currentMsecs uint64, budgetExpected int64) {
+		t.Helper()
+		rl.updateBudget(limit, currentMsecs)
+		if rl.budget != budgetExpected {
+			t.Fatalf("unexpected budget at %dms; got %d; want %d", currentMsecs, rl.budget, budgetExpected)
+		}
+	}
+
+	// The initial budget is limited by 100ms of data
+	f(1000, 100)
+
+	// The budget cannot exceed 100ms of data after idle period
+	f(2000, 100)
+
+	// The budget is increased proportionally to the passed time
+	rl.budget = -500
+	f(2020, -480)
+	f(2020, -480)
+	f(2500, 0)
+	f(2700, 100)
+
+	// Small limits increase the budget only after enough time passes
+	rl = rateLimiter{}
+	rl.updateBudget(5, 1000)
+	rl.budget = 0
+	rl.updateBudget(5, 1100)
+	if rl.budget != 0 {
+		t.Fatalf("unexpected budget; got %d; want 0", rl.budget)
+	}
+	rl.updateBudget(5, 1200)
+	if rl.budget != 1 {
+		t.Fatalf("unexpected budget; got %d; want 1", rl.budget)
+	}
+}
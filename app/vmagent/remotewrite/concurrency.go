@@ -0,0 +1,114 @@
+package remotewrite
+
+import (
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timerpool"
+)
+
+// concurrencyLimiter limits the number of concurrent requests to a single -remoteWrite.url
+// if -remoteWrite.autoscaleConcurrency is set.
+//
+// The limit is adjusted in AIMD fashion: it is halved on sustained errors and it is increased by one
+// after the limit number of successfully sent requests, until it reaches maxConcurrency.
+type concurrencyLimiter struct {
+	maxConcurrency int
+
+	// wakeCh is used for notifying goroutines waiting in acquire about released slots.
+	wakeCh chan struct{}
+
+	// mu protects the fields below.
+	mu               sync.Mutex
+	limit            int
+	inflight         int
+	successes        int
+	lastDecreaseTime time.Time
+}
+
+// concurrencyDecreaseCooldown is the minimum interval between limit decreases.
+//
+// This prevents from collapsing the limit to 1 when many concurrent requests fail at once.
+const concurrencyDecreaseCooldown = time.Second
+
+func newConcurrencyLimiter(maxConcurrency int) *concurrencyLimiter {
+	if maxConcurrency <= 0 {
+		maxConcurrency = 1
+	}
+	return &concurrencyLimiter{
+		maxConcurrency: maxConcurrency,
+		wakeCh:         make(chan struct{}, maxConcurrency),
+		limit:          maxConcurrency,
+	}
+}
+
+// acquire waits until a request can be sent.
+//
+// It returns false if stopCh is closed before that. It is safe calling acquire on nil cl.
+func (cl *concurrencyLimiter) acquire(stopCh <-chan struct{}) bool {
+	if cl == nil {
+		return true
+	}
+	for {
+		cl.mu.Lock()
+		if cl.inflight < cl.limit {
+			cl.inflight++
+			cl.mu.Unlock()
+			return true
+		}
+		cl.mu.Unlock()
+
+		// Periodically re-check the limit in order to avoid missed wakeups.
+		t := timerpool.Get(time.Second)
+		select {
+		case <-stopCh:
+			timerpool.Put(t)
+			return false
+		case <-cl.wakeCh:
+		case <-t.C:
+		}
+		timerpool.Put(t)
+	}
+}
+
+// release releases the slot obtained via acquire.
+//
+// ok must be set to false if the request failed because of remote storage overload such as 429 or 5xx status code.
+// It is safe calling release on nil cl.
+func (cl *concurrencyLimiter) release(ok bool) {
+	if cl == nil {
+		return
+	}
+	cl.mu.Lock()
+	cl.inflight--
+	if ok {
+		cl.successes++
+		if cl.successes >= cl.limit && cl.limit < cl.maxConcurrency {
+			cl.limit++
+			cl.successes = 0
+		}
+	} else {
+		cl.successes = 0
+		if time.Since(cl.lastDecreaseTime) >= concurrencyDecreaseCooldown {
+			cl.limit /= 2
+			if cl.limit < 1 {
+				cl.limit = 1
+			}
+			cl.lastDecreaseTime = time.Now()
+		}
+	}
+	cl.mu.Unlock()
+
+	select {
+	case cl.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// getLimit returns the current concurrency limit for cl.
+func (cl *concurrencyLimiter) getLimit() int {
+	cl.mu.Lock()
+	n := cl.limit
+	cl.mu.Unlock()
+	return n
+}
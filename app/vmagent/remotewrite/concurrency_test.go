@@ -0,0 +1,94 @@
+package remotewrite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	cl := newConcurrencyLimiter(8)
+	stopCh := make(chan struct{})
+	checkLimit := func(limitExpected int) {
+		t.Helper()
+		if limit := cl.getLimit(); limit != limitExpected {
+			t.Fatalf("unexpected limit; got %d; want %d", limit, limitExpected)
+		}
+	}
+	checkLimit(8)
+
+	// The limit must be halved on error
+	cl.acquire(stopCh)
+	cl.release(false)
+	checkLimit(4)
+
+	// Subsequent errors during the cooldown period mustn't decrease the limit
+	cl.acquire(stopCh)
+	cl.release(false)
+	checkLimit(4)
+
+	// Sustained errors must decrease the limit down to 1
+	for i := 0; i < 5; i++ {
+		cl.lastDecreaseTime = time.Time{}
+		cl.acquire(stopCh)
+		cl.release(false)
+	}
+	checkLimit(1)
+
+	// The limit must be increased by one after the limit number of successful requests
+	cl.acquire(stopCh)
+	cl.release(true)
+	checkLimit(2)
+	for i := 0; i < 2; i++ {
+		cl.acquire(stopCh)
+		cl.release(true)
+	}
+	checkLimit(3)
+
+	// The limit mustn't exceed maxConcurrency
+	for i := 0; i < 100; i++ {
+		cl.acquire(stopCh)
+		cl.release(true)
+	}
+	checkLimit(8)
+}
+
+func TestConcurrencyLimiterAcquire(t *testing.T) {
+	cl := newConcurrencyLimiter(2)
+	cl.limit = 1
+	stopCh := make(chan struct{})
+	if !cl.acquire(stopCh) {
+		t.Fatalf("acquire must succeed when the limit isn't reached")
+	}
+
+	// The next acquire must wait until release
+	acquired := make(chan bool)
+	go func() {
+		acquired <- cl.acquire(stopCh)
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("acquire mustn't succeed when the limit is reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cl.release(true)
+	if ok := <-acquired; !ok {
+		t.Fatalf("acquire must succeed after release")
+	}
+
+	// The acquire must be interrupted on stopCh close
+	cl.limit = 1
+	go func() {
+		acquired <- cl.acquire(stopCh)
+	}()
+	close(stopCh)
+	if ok := <-acquired; ok {
+		t.Fatalf("acquire must return false after stopCh is closed")
+	}
+
+	// Calls on nil limiter must succeed
+	var clNil *concurrencyLimiter
+	if !clNil.acquire(stopCh) {
+		t.Fatalf("acquire on nil limiter must succeed")
+	}
+	clNil.release(false)
+}
@@ -0,0 +1,94 @@
+package remotewrite
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+)
+
+var (
+	hmacSecret = flagutil.NewArrayString("remoteWrite.hmac.secret", "Optional secret for signing requests to the corresponding -remoteWrite.url with HMAC-SHA256. "+
+		"The hex-encoded signature of the request body is sent in the -remoteWrite.hmac.header HTTP header. See also -remoteWrite.hmac.secretFile")
+	hmacSecretFile = flagutil.NewArrayString("remoteWrite.hmac.secretFile", "Optional path to file with the secret for signing requests "+
+		"to the corresponding -remoteWrite.url with HMAC-SHA256. See also -remoteWrite.hmac.secret")
+	hmacHeader = flagutil.NewArrayString("remoteWrite.hmac.header", "HTTP header for sending HMAC-SHA256 signature of the request body "+
+		"to the corresponding -remoteWrite.url if -remoteWrite.hmac.secret or -remoteWrite.hmac.secretFile is set. Default value: "+defaultHMACHeader)
+)
+
+const defaultHMACHeader = "X-Signature-SHA256"
+
+// hmacSigner signs request bodies with HMAC-SHA256.
+type hmacSigner struct {
+	header string
+	key    []byte
+
+	hashPool sync.Pool
+}
+
+func getHMACSigner(argIdx int) (*hmacSigner, error) {
+	secret := hmacSecret.GetOptionalArg(argIdx)
+	secretFile := hmacSecretFile.GetOptionalArg(argIdx)
+	if secret != "" && secretFile != "" {
+		return nil, fmt.Errorf("-remoteWrite.hmac.secret and -remoteWrite.hmac.secretFile cannot be set simultaneously")
+	}
+	if secretFile != "" {
+		data, err := os.ReadFile(secretFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read -remoteWrite.hmac.secretFile: %w", err)
+		}
+		secret = strings.TrimRight(string(data), "\r\n")
+		if secret == "" {
+			return nil, fmt.Errorf("-remoteWrite.hmac.secretFile=%q cannot be empty", secretFile)
+		}
+	}
+	if secret == "" {
+		return nil, nil
+	}
+	header := hmacHeader.GetOptionalArg(argIdx)
+	if header == "" {
+		header = defaultHMACHeader
+	}
+	return newHMACSigner(header, []byte(secret)), nil
+}
+
+func newHMACSigner(header string, key []byte) *hmacSigner {
+	return &hmacSigner{
+		header: header,
+		key:    key,
+	}
+}
+
+// appendSignature appends hex-encoded HMAC-SHA256 signature for body to dst and returns the result.
+func (hs *hmacSigner) appendSignature(dst, body []byte) []byte {
+	h := hs.getHash()
+	h.Write(body)
+	var buf [sha256.Size]byte
+	sum := h.Sum(buf[:0])
+	hs.putHash(h)
+
+	dstLen := len(dst)
+	dst = bytesutil.ResizeWithCopyMayOverallocate(dst, dstLen+hex.EncodedLen(len(sum)))
+	hex.Encode(dst[dstLen:], sum)
+	return dst
+}
+
+func (hs *hmacSigner) getHash() hash.Hash {
+	v := hs.hashPool.Get()
+	if v == nil {
+		return hmac.New(sha256.New, hs.key)
+	}
+	return v.(hash.Hash)
+}
+
+func (hs *hmacSigner) putHash(h hash.Hash) {
+	h.Reset()
+	hs.hashPool.Put(h)
+}
@@ -0,0 +1,35 @@
+package remotewrite
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHMACSignerAppendSignature(t *testing.T) {
+	f := func(key, body string) {
+		t.Helper()
+		hs := newHMACSigner(defaultHMACHeader, []byte(key))
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(body))
+		sigExpected := hex.EncodeToString(mac.Sum(nil))
+
+		// Verify the signature is stable across reused hashes.
+		for i := 0; i < 3; i++ {
+			sig := hs.appendSignature(nil, []byte(body))
+			if string(sig) != sigExpected {
+				t.Fatalf("unexpected signature for key=%q, body=%q; got %q; want %q", key, body, sig, sigExpected)
+			}
+		}
+
+		// Verify the signature is appended to dst.
+		sig := hs.appendSignature([]byte("prefix:"), []byte(body))
+		if string(sig) != "prefix:"+sigExpected {
+			t.Fatalf("unexpected signature with prefix for key=%q, body=%q; got %q; want %q", key, body, sig, "prefix:"+sigExpected)
+		}
+	}
+	f("secret", "")
+	f("secret", "foobar")
+	f("another secret", "\x00\x01\x02 compressed body")
+}
@@ -0,0 +1,28 @@
+package remotewrite
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/golang/snappy"
+)
+
+func BenchmarkHMACSignerAppendSignature(b *testing.B) {
+	for _, rowsCount := range []int{1, 10, 100, 1e3, 1e4} {
+		b.Run(fmt.Sprintf("rows_%d", rowsCount), func(b *testing.B) {
+			wr := newTestWriteRequest(rowsCount, 10)
+			data := prompbmarshal.MarshalWriteRequest(nil, wr)
+			zb := snappy.Encode(nil, data)
+			hs := newHMACSigner(defaultHMACHeader, []byte("secret"))
+			b.ReportAllocs()
+			b.SetBytes(int64(rowsCount))
+			b.RunParallel(func(pb *testing.PB) {
+				var sig []byte
+				for pb.Next() {
+					sig = hs.appendSignature(sig[:0], zb)
+				}
+			})
+		})
+	}
+}
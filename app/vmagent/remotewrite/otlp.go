@@ -0,0 +1,671 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/persistentqueue"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+var (
+	remoteWriteFormat = flagutil.NewArrayString("remoteWrite.format", "Data format for sending data to the corresponding -remoteWrite.url. "+
+		"Supported values: prometheus, otlp. The otlp format sends data as OpenTelemetry ExportMetricsServiceRequest messages. "+
+		"Default value: prometheus. See https://docs.victoriametrics.com/vmagent.html#sending-data-in-opentelemetry-format")
+	otlpConvertHistograms = flagutil.NewArrayBool("remoteWrite.otlp.convertHistograms", "Whether to convert Prometheus histograms "+
+		"consisting of _bucket, _sum and _count series into OpenTelemetry histograms before sending them to the corresponding -remoteWrite.url "+
+		"with -remoteWrite.format=otlp. By default every series is sent as a separate gauge or sum")
+	otlpResourceLabels = flagutil.NewArrayString("remoteWrite.otlp.resourceLabels", "Optional list of label names, which must be sent "+
+		"as OpenTelemetry resource attributes instead of data point attributes to the corresponding -remoteWrite.url with -remoteWrite.format=otlp. "+
+		"Label names must be delimited by ';'. For example, -remoteWrite.otlp.resourceLabels='job;instance'")
+)
+
+// otlpConfig contains settings for converting data to OpenTelemetry format.
+type otlpConfig struct {
+	convertHistograms bool
+	resourceLabels    []string
+}
+
+// getOTLPConfig returns otlpConfig for -remoteWrite.url at argIdx.
+//
+// nil is returned if the data must be sent in Prometheus remote write format.
+func getOTLPConfig(argIdx int) (*otlpConfig, error) {
+	switch format := remoteWriteFormat.GetOptionalArg(argIdx); format {
+	case "", "prometheus":
+		return nil, nil
+	case "otlp":
+	default:
+		return nil, fmt.Errorf("unsupported -remoteWrite.format=%q; supported values: prometheus, otlp", format)
+	}
+	cfg := &otlpConfig{
+		convertHistograms: otlpConvertHistograms.GetOptionalArg(argIdx),
+	}
+	if s := otlpResourceLabels.GetOptionalArg(argIdx); s != "" {
+		for _, name := range strings.Split(s, ";") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.resourceLabels = append(cfg.resourceLabels, name)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// pushOTLPWriteRequest converts wr to gzip-compressed OpenTelemetry ExportMetricsServiceRequest and passes it to pushBlock.
+//
+// wr is split into smaller parts if the resulting block exceeds size limits, like pushWriteRequest does.
+// The split is performed after the conversion on metric boundaries, so buckets for a single histogram aren't sent in distinct blocks.
+func pushOTLPWriteRequest(wr *prompbmarshal.WriteRequest, pushBlock func(block []byte), cfg *otlpConfig) {
+	if len(wr.Timeseries) == 0 {
+		// Nothing to push
+		return
+	}
+	rms := convertToOTLP(wr.Timeseries, cfg)
+	pushOTLPResourceMetrics(rms, pushBlock)
+}
+
+func pushOTLPResourceMetrics(rms []*otlpResourceMetrics, pushBlock func(block []byte)) {
+	bb := writeRequestBufPool.Get()
+	bb.B = marshalOTLPRequest(bb.B[:0], rms)
+	if len(bb.B) <= maxUnpackedBlockSize.IntN() {
+		zb := snappyBufPool.Get()
+		zb.B = gzipCompress(zb.B[:0], bb.B)
+		writeRequestBufPool.Put(bb)
+		if len(zb.B) <= persistentqueue.MaxBlockSize {
+			pushBlock(zb.B)
+			blockSizeRows.Update(float64(getOTLPMetricsCount(rms)))
+			blockSizeBytes.Update(float64(len(zb.B)))
+			snappyBufPool.Put(zb)
+			return
+		}
+		snappyBufPool.Put(zb)
+	} else {
+		writeRequestBufPool.Put(bb)
+	}
+
+	// Too big block. Recursively split it into smaller parts on metric boundaries if possible.
+	if getOTLPMetricsCount(rms) == 1 {
+		// Split data points for a single metric. Every data point contains the full histogram, so histograms aren't broken.
+		for _, rm := range rms {
+			if len(rm.metrics) == 0 {
+				continue
+			}
+			m := rm.metrics[0]
+			if m.getPointsCount() == 1 {
+				logger.Warnf("dropping a sample for metric with too long labels exceeding -remoteWrite.maxBlockSize=%d bytes", maxUnpackedBlockSize.N)
+				return
+			}
+			mHead, mTail := m.splitPoints()
+			pushOTLPResourceMetrics([]*otlpResourceMetrics{{resource: rm.resource, metrics: []*otlpMetric{mHead}}}, pushBlock)
+			pushOTLPResourceMetrics([]*otlpResourceMetrics{{resource: rm.resource, metrics: []*otlpMetric{mTail}}}, pushBlock)
+			return
+		}
+	}
+	rmsHead, rmsTail := splitOTLPResourceMetrics(rms)
+	pushOTLPResourceMetrics(rmsHead, pushBlock)
+	pushOTLPResourceMetrics(rmsTail, pushBlock)
+}
+
+func getOTLPMetricsCount(rms []*otlpResourceMetrics) int {
+	n := 0
+	for _, rm := range rms {
+		n += len(rm.metrics)
+	}
+	return n
+}
+
+// splitOTLPResourceMetrics splits rms into two parts with approximately equal number of metrics.
+func splitOTLPResourceMetrics(rms []*otlpResourceMetrics) ([]*otlpResourceMetrics, []*otlpResourceMetrics) {
+	n := getOTLPMetricsCount(rms) / 2
+	var rmsHead, rmsTail []*otlpResourceMetrics
+	for _, rm := range rms {
+		if n <= 0 {
+			rmsTail = append(rmsTail, rm)
+			continue
+		}
+		if len(rm.metrics) <= n {
+			rmsHead = append(rmsHead, rm)
+			n -= len(rm.metrics)
+			continue
+		}
+		rmsHead = append(rmsHead, &otlpResourceMetrics{
+			resource: rm.resource,
+			metrics:  rm.metrics[:n],
+		})
+		rmsTail = append(rmsTail, &otlpResourceMetrics{
+			resource: rm.resource,
+			metrics:  rm.metrics[n:],
+		})
+		n = 0
+	}
+	return rmsHead, rmsTail
+}
+
+func gzipCompress(dst, src []byte) []byte {
+	bb := bytesutil.ByteBuffer{
+		B: dst,
+	}
+	zw := gzipWriterPool.Get().(*gzip.Writer)
+	zw.Reset(&bb)
+	if _, err := zw.Write(src); err != nil {
+		logger.Panicf("BUG: unexpected error when compressing data in memory: %s", err)
+	}
+	if err := zw.Close(); err != nil {
+		logger.Panicf("BUG: unexpected error when closing gzip writer: %s", err)
+	}
+	gzipWriterPool.Put(zw)
+	return bb.B
+}
+
+var gzipWriterPool = &sync.Pool{
+	New: func() interface{} {
+		zw, err := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		if err != nil {
+			logger.Panicf("BUG: cannot create gzip writer: %s", err)
+		}
+		return zw
+	},
+}
+
+// otlpResourceMetrics holds metrics sharing the same resource attributes.
+type otlpResourceMetrics struct {
+	resource []prompbmarshal.Label
+	metrics  []*otlpMetric
+}
+
+type otlpMetricType int
+
+const (
+	otlpGauge otlpMetricType = iota
+	otlpSum
+	otlpHistogram
+)
+
+type otlpMetric struct {
+	name string
+	typ  otlpMetricType
+
+	// numberPoints is used for otlpGauge and otlpSum
+	numberPoints []otlpNumberDataPoint
+
+	// histogramPoints is used for otlpHistogram
+	histogramPoints []otlpHistogramDataPoint
+}
+
+type otlpNumberDataPoint struct {
+	attributes []prompbmarshal.Label
+	timestamp  int64
+	value      float64
+}
+
+type otlpHistogramDataPoint struct {
+	attributes     []prompbmarshal.Label
+	timestamp      int64
+	count          uint64
+	sum            float64
+	hasSum         bool
+	bucketCounts   []uint64
+	explicitBounds []float64
+}
+
+// convertToOTLP converts tss to OpenTelemetry metrics grouped by resource attributes according to cfg.
+//
+// Series with names ending with _total are converted to cumulative monotonic sums, while the rest of series are converted to gauges.
+// Histogram series are converted to OpenTelemetry histograms if cfg.convertHistograms is set.
+func convertToOTLP(tss []prompbmarshal.TimeSeries, cfg *otlpConfig) []*otlpResourceMetrics {
+	var rms []*otlpResourceMetrics
+	rmsIdx := make(map[string]*otlpResourceMetrics)
+	var hcs []*otlpHistogramConverter
+	hcsIdx := make(map[string]*otlpHistogramConverter)
+	var keyBuf []byte
+	for i := range tss {
+		ts := &tss[i]
+		name, resource, attrs := splitOTLPLabels(ts.Labels, cfg.resourceLabels)
+		keyBuf = marshalOTLPLabels(keyBuf[:0], resource)
+		rm := rmsIdx[string(keyBuf)]
+		if rm == nil {
+			rm = &otlpResourceMetrics{
+				resource: resource,
+			}
+			rmsIdx[string(keyBuf)] = rm
+			rms = append(rms, rm)
+		}
+		if cfg.convertHistograms {
+			if baseName, suffix := splitHistogramName(name); baseName != "" {
+				le := 0.0
+				leOK := true
+				histAttrs := attrs
+				if suffix == "_bucket" {
+					var leStr string
+					leStr, histAttrs = extractLabel(attrs, "le")
+					v, err := strconv.ParseFloat(leStr, 64)
+					le, leOK = v, err == nil
+				}
+				if leOK {
+					keyBuf = append(keyBuf, baseName...)
+					keyBuf = marshalOTLPLabels(keyBuf, histAttrs)
+					hc := hcsIdx[string(keyBuf)]
+					if hc == nil {
+						hc = &otlpHistogramConverter{
+							rm:         rm,
+							name:       baseName,
+							attributes: histAttrs,
+						}
+						hcsIdx[string(keyBuf)] = hc
+						hcs = append(hcs, hc)
+					}
+					hc.add(suffix, le, ts)
+					continue
+				}
+			}
+		}
+		rm.metrics = append(rm.metrics, newOTLPNumberMetric(name, attrs, ts.Samples))
+	}
+	for _, hc := range hcs {
+		hc.flush()
+	}
+	return rms
+}
+
+func newOTLPNumberMetric(name string, attrs []prompbmarshal.Label, samples []prompbmarshal.Sample) *otlpMetric {
+	m := &otlpMetric{
+		name: name,
+		typ:  otlpGauge,
+	}
+	if strings.HasSuffix(name, "_total") {
+		m.typ = otlpSum
+	}
+	for _, s := range samples {
+		m.numberPoints = append(m.numberPoints, otlpNumberDataPoint{
+			attributes: attrs,
+			timestamp:  s.Timestamp,
+			value:      s.Value,
+		})
+	}
+	return m
+}
+
+// otlpHistogramConverter collects _bucket, _sum and _count series for a single Prometheus histogram.
+type otlpHistogramConverter struct {
+	rm         *otlpResourceMetrics
+	name       string
+	attributes []prompbmarshal.Label
+
+	buckets []*prompbmarshal.TimeSeries
+	les     []float64
+	sum     *prompbmarshal.TimeSeries
+	count   *prompbmarshal.TimeSeries
+}
+
+func (hc *otlpHistogramConverter) add(suffix string, le float64, ts *prompbmarshal.TimeSeries) {
+	switch suffix {
+	case "_bucket":
+		hc.buckets = append(hc.buckets, ts)
+		hc.les = append(hc.les, le)
+	case "_sum":
+		hc.sum = ts
+	case "_count":
+		hc.count = ts
+	}
+}
+
+func (hc *otlpHistogramConverter) flush() {
+	if len(hc.buckets) == 0 {
+		// There are no buckets, so this isn't a histogram. It may be a summary. Send the collected series as is.
+		if hc.sum != nil {
+			hc.rm.metrics = append(hc.rm.metrics, newOTLPNumberMetric(hc.name+"_sum", hc.attributes, hc.sum.Samples))
+		}
+		if hc.count != nil {
+			hc.rm.metrics = append(hc.rm.metrics, newOTLPNumberMetric(hc.name+"_count", hc.attributes, hc.count.Samples))
+		}
+		return
+	}
+	sort.Sort(hc)
+
+	var timestamps []int64
+	seen := make(map[int64]bool)
+	for _, ts := range hc.buckets {
+		for _, s := range ts.Samples {
+			if !seen[s.Timestamp] {
+				seen[s.Timestamp] = true
+				timestamps = append(timestamps, s.Timestamp)
+			}
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i] < timestamps[j]
+	})
+
+	m := &otlpMetric{
+		name: hc.name,
+		typ:  otlpHistogram,
+	}
+	for _, timestamp := range timestamps {
+		dp := otlpHistogramDataPoint{
+			attributes: hc.attributes,
+			timestamp:  timestamp,
+		}
+		prevCumulative := 0.0
+		hasInf := false
+		for i, ts := range hc.buckets {
+			v, ok := getSampleValue(ts.Samples, timestamp)
+			if !ok {
+				// Missing bucket value. Treat it as equal to the previous bucket.
+				v = prevCumulative
+			}
+			le := hc.les[i]
+			if math.IsInf(le, 1) {
+				hasInf = true
+			} else {
+				dp.explicitBounds = append(dp.explicitBounds, le)
+			}
+			dp.bucketCounts = append(dp.bucketCounts, toUint64(v-prevCumulative))
+			if v > prevCumulative {
+				prevCumulative = v
+			}
+		}
+		count := prevCumulative
+		if hc.count != nil {
+			if v, ok := getSampleValue(hc.count.Samples, timestamp); ok {
+				count = v
+			}
+		}
+		if !hasInf {
+			// OpenTelemetry histograms must contain an implicit +Inf bucket.
+			dp.bucketCounts = append(dp.bucketCounts, toUint64(count-prevCumulative))
+		}
+		dp.count = toUint64(count)
+		if hc.sum != nil {
+			dp.sum, dp.hasSum = getSampleValue(hc.sum.Samples, timestamp)
+		}
+		m.histogramPoints = append(m.histogramPoints, dp)
+	}
+	hc.rm.metrics = append(hc.rm.metrics, m)
+}
+
+func (hc *otlpHistogramConverter) Len() int           { return len(hc.les) }
+func (hc *otlpHistogramConverter) Less(i, j int) bool { return hc.les[i] < hc.les[j] }
+func (hc *otlpHistogramConverter) Swap(i, j int) {
+	hc.les[i], hc.les[j] = hc.les[j], hc.les[i]
+	hc.buckets[i], hc.buckets[j] = hc.buckets[j], hc.buckets[i]
+}
+
+func getSampleValue(samples []prompbmarshal.Sample, timestamp int64) (float64, bool) {
+	for _, s := range samples {
+		if s.Timestamp == timestamp {
+			return s.Value, true
+		}
+	}
+	return 0, false
+}
+
+func toUint64(v float64) uint64 {
+	if v <= 0 || math.IsNaN(v) {
+		return 0
+	}
+	return uint64(math.Round(v))
+}
+
+// splitHistogramName returns the base name and the suffix for Prometheus histogram series name.
+//
+// Empty base name is returned if name doesn't belong to histogram.
+func splitHistogramName(name string) (string, string) {
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			return name[:len(name)-len(suffix)], suffix
+		}
+	}
+	return "", ""
+}
+
+// splitOTLPLabels returns metric name, resource attributes and data point attributes from labels.
+func splitOTLPLabels(labels []prompbmarshal.Label, resourceLabels []string) (string, []prompbmarshal.Label, []prompbmarshal.Label) {
+	name := ""
+	var resource, attrs []prompbmarshal.Label
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			name = label.Value
+			continue
+		}
+		if isResourceLabel(label.Name, resourceLabels) {
+			resource = append(resource, label)
+		} else {
+			attrs = append(attrs, label)
+		}
+	}
+	return name, resource, attrs
+}
+
+func isResourceLabel(name string, resourceLabels []string) bool {
+	for _, s := range resourceLabels {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// extractLabel returns the value for the label with the given name and the remaining labels.
+func extractLabel(labels []prompbmarshal.Label, name string) (string, []prompbmarshal.Label) {
+	for i, label := range labels {
+		if label.Name == name {
+			rest := make([]prompbmarshal.Label, 0, len(labels)-1)
+			rest = append(rest, labels[:i]...)
+			rest = append(rest, labels[i+1:]...)
+			return label.Value, rest
+		}
+	}
+	return "", labels
+}
+
+func marshalOTLPLabels(dst []byte, labels []prompbmarshal.Label) []byte {
+	for _, label := range labels {
+		dst = strconv.AppendQuote(dst, label.Name)
+		dst = append(dst, '=')
+		dst = strconv.AppendQuote(dst, label.Value)
+		dst = append(dst, ',')
+	}
+	return append(dst, ';')
+}
+
+// The following code marshals OpenTelemetry ExportMetricsServiceRequest.
+// See https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/collector/metrics/v1/metrics_service.proto
+// and https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/metrics/v1/metrics.proto
+
+const (
+	wireTypeVarint  = 0
+	wireTypeFixed64 = 1
+	wireTypeBytes   = 2
+
+	// aggregationTemporalityCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE
+	aggregationTemporalityCumulative = 2
+)
+
+func marshalOTLPRequest(dst []byte, rms []*otlpResourceMetrics) []byte {
+	for _, rm := range rms {
+		// ExportMetricsServiceRequest.resource_metrics
+		dst = appendMessage(dst, 1, func(dst []byte) []byte {
+			// ResourceMetrics.resource
+			dst = appendMessage(dst, 1, func(dst []byte) []byte {
+				// Resource.attributes
+				return appendKeyValues(dst, 1, rm.resource)
+			})
+			// ResourceMetrics.scope_metrics
+			return appendMessage(dst, 2, func(dst []byte) []byte {
+				// ScopeMetrics.scope
+				dst = appendMessage(dst, 1, func(dst []byte) []byte {
+					// InstrumentationScope.name
+					return appendString(dst, 1, "vmagent")
+				})
+				for _, m := range rm.metrics {
+					// ScopeMetrics.metrics
+					dst = appendMessage(dst, 2, m.marshal)
+				}
+				return dst
+			})
+		})
+	}
+	return dst
+}
+
+func (m *otlpMetric) getPointsCount() int {
+	if m.typ == otlpHistogram {
+		return len(m.histogramPoints)
+	}
+	return len(m.numberPoints)
+}
+
+// splitPoints splits data points for m into two metrics with approximately equal number of data points.
+func (m *otlpMetric) splitPoints() (*otlpMetric, *otlpMetric) {
+	mHead := *m
+	mTail := *m
+	if m.typ == otlpHistogram {
+		n := len(m.histogramPoints) / 2
+		mHead.histogramPoints = m.histogramPoints[:n]
+		mTail.histogramPoints = m.histogramPoints[n:]
+	} else {
+		n := len(m.numberPoints) / 2
+		mHead.numberPoints = m.numberPoints[:n]
+		mTail.numberPoints = m.numberPoints[n:]
+	}
+	return &mHead, &mTail
+}
+
+func (m *otlpMetric) marshal(dst []byte) []byte {
+	// Metric.name
+	dst = appendString(dst, 1, m.name)
+	switch m.typ {
+	case otlpGauge:
+		// Metric.gauge
+		dst = appendMessage(dst, 5, func(dst []byte) []byte {
+			return appendNumberDataPoints(dst, m.numberPoints)
+		})
+	case otlpSum:
+		// Metric.sum
+		dst = appendMessage(dst, 7, func(dst []byte) []byte {
+			dst = appendNumberDataPoints(dst, m.numberPoints)
+			// Sum.aggregation_temporality
+			dst = appendVarint(dst, 2, aggregationTemporalityCumulative)
+			// Sum.is_monotonic
+			return appendVarint(dst, 3, 1)
+		})
+	case otlpHistogram:
+		// Metric.histogram
+		dst = appendMessage(dst, 9, func(dst []byte) []byte {
+			for i := range m.histogramPoints {
+				// Histogram.data_points
+				dst = appendMessage(dst, 1, m.histogramPoints[i].marshal)
+			}
+			// Histogram.aggregation_temporality
+			return appendVarint(dst, 2, aggregationTemporalityCumulative)
+		})
+	default:
+		logger.Panicf("BUG: unexpected metric type %d", m.typ)
+	}
+	return dst
+}
+
+func appendNumberDataPoints(dst []byte, dps []otlpNumberDataPoint) []byte {
+	for i := range dps {
+		dp := &dps[i]
+		// Gauge.data_points or Sum.data_points
+		dst = appendMessage(dst, 1, func(dst []byte) []byte {
+			// NumberDataPoint.time_unix_nano
+			dst = appendFixed64(dst, 3, uint64(dp.timestamp)*1e6)
+			// NumberDataPoint.as_double
+			dst = appendFixed64(dst, 4, math.Float64bits(dp.value))
+			// NumberDataPoint.attributes
+			return appendKeyValues(dst, 7, dp.attributes)
+		})
+	}
+	return dst
+}
+
+func (dp *otlpHistogramDataPoint) marshal(dst []byte) []byte {
+	// HistogramDataPoint.time_unix_nano
+	dst = appendFixed64(dst, 3, uint64(dp.timestamp)*1e6)
+	// HistogramDataPoint.count
+	dst = appendFixed64(dst, 4, dp.count)
+	if dp.hasSum {
+		// HistogramDataPoint.sum
+		dst = appendFixed64(dst, 5, math.Float64bits(dp.sum))
+	}
+	// HistogramDataPoint.bucket_counts
+	dst = appendMessage(dst, 6, func(dst []byte) []byte {
+		for _, n := range dp.bucketCounts {
+			dst = binary.LittleEndian.AppendUint64(dst, n)
+		}
+		return dst
+	})
+	if len(dp.explicitBounds) > 0 {
+		// HistogramDataPoint.explicit_bounds
+		dst = appendMessage(dst, 7, func(dst []byte) []byte {
+			for _, v := range dp.explicitBounds {
+				dst = binary.LittleEndian.AppendUint64(dst, math.Float64bits(v))
+			}
+			return dst
+		})
+	}
+	// HistogramDataPoint.attributes
+	return appendKeyValues(dst, 9, dp.attributes)
+}
+
+func appendKeyValues(dst []byte, fieldNum uint64, labels []prompbmarshal.Label) []byte {
+	for _, label := range labels {
+		dst = appendMessage(dst, fieldNum, func(dst []byte) []byte {
+			// KeyValue.key
+			dst = appendString(dst, 1, label.Name)
+			// KeyValue.value
+			return appendMessage(dst, 2, func(dst []byte) []byte {
+				// AnyValue.string_value
+				return appendString(dst, 1, label.Value)
+			})
+		})
+	}
+	return dst
+}
+
+// appendMessage appends the message marshaled by f to dst as fieldNum field.
+func appendMessage(dst []byte, fieldNum uint64, f func(dst []byte) []byte) []byte {
+	dst = appendTag(dst, fieldNum, wireTypeBytes)
+	bodyStart := len(dst)
+	dst = f(dst)
+	bodyLen := len(dst) - bodyStart
+
+	// Move the message body in order to make room for its length.
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(bodyLen))
+	dst = append(dst, lenBuf[:n]...)
+	copy(dst[bodyStart+n:], dst[bodyStart:bodyStart+bodyLen])
+	copy(dst[bodyStart:], lenBuf[:n])
+	return dst
+}
+
+func appendString(dst []byte, fieldNum uint64, s string) []byte {
+	dst = appendTag(dst, fieldNum, wireTypeBytes)
+	dst = binary.AppendUvarint(dst, uint64(len(s)))
+	return append(dst, s...)
+}
+
+func appendVarint(dst []byte, fieldNum, v uint64) []byte {
+	dst = appendTag(dst, fieldNum, wireTypeVarint)
+	return binary.AppendUvarint(dst, v)
+}
+
+func appendFixed64(dst []byte, fieldNum, v uint64) []byte {
+	dst = appendTag(dst, fieldNum, wireTypeFixed64)
+	return binary.LittleEndian.AppendUint64(dst, v)
+}
+
+func appendTag(dst []byte, fieldNum uint64, wireType uint64) []byte {
+	return binary.AppendUvarint(dst, fieldNum<<3|wireType)
+}
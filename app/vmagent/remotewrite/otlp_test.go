@@ -0,0 +1,340 @@
+package remotewrite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+func TestConvertToOTLP(t *testing.T) {
+	f := func(s string, cfg *otlpConfig, resultExpected string) {
+		t.Helper()
+		tss := mustParseTestSeries(t, s)
+		rms := convertToOTLP(tss, cfg)
+		result := otlpResourceMetricsString(rms)
+		if result != resultExpected {
+			t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	// Gauges and sums
+	f(`
+foo{job="a",instance="x"} 1 1000
+bar_total{job="a",instance="y",mode="idle"} 2 1000
+baz{job="b"} 3 2000
+`, &otlpConfig{
+		resourceLabels: []string{"job"},
+	}, `resource {job="a"}
+  gauge foo {instance="x"} 1000:1
+  sum bar_total {instance="y",mode="idle"} 1000:2
+resource {job="b"}
+  gauge baz {} 2000:3
+`)
+
+	// Histograms without conversion
+	f(`
+req_bucket{le="1"} 1 1000
+req_bucket{le="+Inf"} 3 1000
+req_sum 5 1000
+req_count 3 1000
+`, &otlpConfig{}, `resource {}
+  gauge req_bucket {le="1"} 1000:1
+  gauge req_bucket {le="+Inf"} 1000:3
+  gauge req_sum {} 1000:5
+  gauge req_count {} 1000:3
+`)
+
+	// Histograms with conversion
+	f(`
+req_bucket{job="a",path="/",le="+Inf"} 5 1000
+req_bucket{job="a",path="/",le="0.5"} 1 1000
+req_bucket{job="a",path="/",le="1"} 4 1000
+req_sum{job="a",path="/"} 3.5 1000
+req_count{job="a",path="/"} 5 1000
+req_bucket{job="a",path="/foo",le="1"} 2 1000
+req_count{job="a",path="/foo"} 7 1000
+rpc_sum{job="a"} 10 1000
+rpc_count{job="a"} 2 1000
+bad_bucket{job="a",le="foo"} 1 1000
+`, &otlpConfig{
+		convertHistograms: true,
+		resourceLabels:    []string{"job"},
+	}, `resource {job="a"}
+  gauge bad_bucket {le="foo"} 1000:1
+  histogram req {path="/"} 1000:count=5,sum=3.5,buckets=[1 3 1],bounds=[0.5 1]
+  histogram req {path="/foo"} 1000:count=7,buckets=[2 5],bounds=[1]
+  gauge rpc_sum {} 1000:10
+  gauge rpc_count {} 1000:2
+`)
+}
+
+func TestMarshalOTLPRequest(t *testing.T) {
+	rms := []*otlpResourceMetrics{{
+		metrics: []*otlpMetric{{
+			name: "m",
+			typ:  otlpGauge,
+			numberPoints: []otlpNumberDataPoint{{
+				timestamp: 1,
+			}},
+		}},
+	}}
+	result := marshalOTLPRequest(nil, rms)
+	resultExpected := []byte{
+		// ExportMetricsServiceRequest.resource_metrics
+		0x0a, 0x2a,
+		// ResourceMetrics.resource
+		0x0a, 0x00,
+		// ResourceMetrics.scope_metrics
+		0x12, 0x26,
+		// ScopeMetrics.scope
+		0x0a, 0x09, 0x0a, 0x07, 'v', 'm', 'a', 'g', 'e', 'n', 't',
+		// ScopeMetrics.metrics
+		0x12, 0x19,
+		// Metric.name
+		0x0a, 0x01, 'm',
+		// Metric.gauge
+		0x2a, 0x14,
+		// Gauge.data_points
+		0x0a, 0x12,
+		// NumberDataPoint.time_unix_nano
+		0x19, 0x40, 0x42, 0x0f, 0x00, 0x00, 0x00, 0x00, 0x00,
+		// NumberDataPoint.as_double
+		0x21, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	if !bytes.Equal(result, resultExpected) {
+		t.Fatalf("unexpected result;\ngot\n%x\nwant\n%x", result, resultExpected)
+	}
+}
+
+func TestPushOTLPWriteRequest(t *testing.T) {
+	cfg := &otlpConfig{}
+	wr := newTestWriteRequest(100, 10)
+	var blocks [][]byte
+	pushOTLPWriteRequest(wr, func(block []byte) {
+		blocks = append(blocks, append([]byte{}, block...))
+	}, cfg)
+	if len(blocks) != 1 {
+		t.Fatalf("unexpected number of blocks; got %d; want 1", len(blocks))
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(blocks[0]))
+	if err != nil {
+		t.Fatalf("cannot open gzip reader: %s", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("cannot decompress block: %s", err)
+	}
+	dataExpected := marshalOTLPRequest(nil, convertToOTLP(wr.Timeseries, cfg))
+	if !bytes.Equal(data, dataExpected) {
+		t.Fatalf("unexpected block contents")
+	}
+}
+
+func TestPushOTLPWriteRequestSplit(t *testing.T) {
+	maxBlockSizeOrig := maxUnpackedBlockSize.N
+	defer func() {
+		maxUnpackedBlockSize.N = maxBlockSizeOrig
+	}()
+
+	cfg := &otlpConfig{
+		convertHistograms: true,
+	}
+	var sb strings.Builder
+	for i := 0; i < 10; i++ {
+		for _, le := range []string{"0.5", "1", "+Inf"} {
+			fmt.Fprintf(&sb, "req_bucket{path=\"/%d\",le=%q} %d 1000\n", i, le, i)
+		}
+		fmt.Fprintf(&sb, "req_count{path=\"/%d\"} %d 1000\n", i, i)
+	}
+	tss := mustParseTestSeries(t, sb.String())
+	var dataExpected []byte
+	for _, rm := range convertToOTLP(tss, cfg) {
+		for _, m := range rm.metrics {
+			dataExpected = marshalOTLPRequest(dataExpected, []*otlpResourceMetrics{{
+				resource: rm.resource,
+				metrics:  []*otlpMetric{m},
+			}})
+		}
+	}
+	// Limit the block size, so it fits only a single histogram.
+	maxUnpackedBlockSize.N = int64(len(dataExpected) / 10 * 3 / 2)
+
+	wr := &prompbmarshal.WriteRequest{
+		Timeseries: tss,
+	}
+	var blocks [][]byte
+	pushOTLPWriteRequest(wr, func(block []byte) {
+		blocks = append(blocks, append([]byte{}, block...))
+	}, cfg)
+	// Every histogram must be sent in a single block.
+	if len(blocks) != 10 {
+		t.Fatalf("unexpected number of blocks; got %d; want 10", len(blocks))
+	}
+	var data []byte
+	for _, block := range blocks {
+		zr, err := gzip.NewReader(bytes.NewReader(block))
+		if err != nil {
+			t.Fatalf("cannot open gzip reader: %s", err)
+		}
+		b, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("cannot decompress block: %s", err)
+		}
+		if len(b) > maxUnpackedBlockSize.IntN() {
+			t.Fatalf("too big block; got %d bytes; want up to %d bytes", len(b), maxUnpackedBlockSize.IntN())
+		}
+		data = append(data, b...)
+	}
+	if !bytes.Equal(data, dataExpected) {
+		t.Fatalf("unexpected blocks contents")
+	}
+}
+
+func TestSplitOTLPResourceMetrics(t *testing.T) {
+	tss := mustParseTestSeries(t, `
+foo{job="a"} 1 1000
+bar{job="a"} 2 1000
+baz{job="b"} 3 1000
+`)
+	rms := convertToOTLP(tss, &otlpConfig{
+		resourceLabels: []string{"job"},
+	})
+	rmsHead, rmsTail := splitOTLPResourceMetrics(rms)
+	resultExpected := `resource {job="a"}
+  gauge foo {} 1000:1
+`
+	if result := otlpResourceMetricsString(rmsHead); result != resultExpected {
+		t.Fatalf("unexpected head;\ngot\n%s\nwant\n%s", result, resultExpected)
+	}
+	resultExpected = `resource {job="a"}
+  gauge bar {} 1000:2
+resource {job="b"}
+  gauge baz {} 1000:3
+`
+	if result := otlpResourceMetricsString(rmsTail); result != resultExpected {
+		t.Fatalf("unexpected tail;\ngot\n%s\nwant\n%s", result, resultExpected)
+	}
+
+	m := &otlpMetric{
+		name: "req",
+		typ:  otlpHistogram,
+		histogramPoints: []otlpHistogramDataPoint{
+			{timestamp: 1000},
+			{timestamp: 2000},
+			{timestamp: 3000},
+		},
+	}
+	mHead, mTail := m.splitPoints()
+	if len(mHead.histogramPoints) != 1 || mHead.histogramPoints[0].timestamp != 1000 {
+		t.Fatalf("unexpected head points: %+v", mHead.histogramPoints)
+	}
+	if len(mTail.histogramPoints) != 2 || mTail.histogramPoints[0].timestamp != 2000 {
+		t.Fatalf("unexpected tail points: %+v", mTail.histogramPoints)
+	}
+}
+
+func TestGetOTLPConfig(t *testing.T) {
+	f := func(format string, cfgExpected *otlpConfig, isErrorExpected bool) {
+		t.Helper()
+		if err := remoteWriteFormat.Set(format); err != nil {
+			t.Fatalf("cannot set -remoteWrite.format: %s", err)
+		}
+		defer func() {
+			*remoteWriteFormat = nil
+		}()
+		cfg, err := getOTLPConfig(0)
+		if isErrorExpected != (err != nil) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fmt.Sprintf("%v", cfg) != fmt.Sprintf("%v", cfgExpected) {
+			t.Fatalf("unexpected config; got %+v; want %+v", cfg, cfgExpected)
+		}
+	}
+	f("", nil, false)
+	f("prometheus", nil, false)
+	f("otlp", &otlpConfig{}, false)
+	f("foobar", nil, true)
+}
+
+func mustParseTestSeries(t *testing.T, s string) []prompbmarshal.TimeSeries {
+	t.Helper()
+	var tss []prompbmarshal.TimeSeries
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		var name, labelsStr string
+		var value float64
+		var timestamp int64
+		n := strings.IndexAny(line, "{ ")
+		name, line = line[:n], line[n:]
+		if strings.HasPrefix(line, "{") {
+			n = strings.Index(line, "}")
+			labelsStr, line = line[1:n], line[n+1:]
+		}
+		if _, err := fmt.Sscanf(strings.TrimSpace(line), "%g %d", &value, &timestamp); err != nil {
+			t.Fatalf("cannot parse %q: %s", line, err)
+		}
+		labels := []prompbmarshal.Label{{
+			Name:  "__name__",
+			Value: name,
+		}}
+		if labelsStr != "" {
+			for _, kv := range strings.Split(labelsStr, ",") {
+				n := strings.Index(kv, "=")
+				labels = append(labels, prompbmarshal.Label{
+					Name:  kv[:n],
+					Value: strings.Trim(kv[n+1:], `"`),
+				})
+			}
+		}
+		tss = append(tss, prompbmarshal.TimeSeries{
+			Labels: labels,
+			Samples: []prompbmarshal.Sample{{
+				Value:     value,
+				Timestamp: timestamp,
+			}},
+		})
+	}
+	return tss
+}
+
+func otlpResourceMetricsString(rms []*otlpResourceMetrics) string {
+	var sb strings.Builder
+	for _, rm := range rms {
+		fmt.Fprintf(&sb, "resource %s\n", otlpLabelsString(rm.resource))
+		for _, m := range rm.metrics {
+			switch m.typ {
+			case otlpGauge, otlpSum:
+				typ := "gauge"
+				if m.typ == otlpSum {
+					typ = "sum"
+				}
+				for _, dp := range m.numberPoints {
+					fmt.Fprintf(&sb, "  %s %s %s %d:%g\n", typ, m.name, otlpLabelsString(dp.attributes), dp.timestamp, dp.value)
+				}
+			case otlpHistogram:
+				for _, dp := range m.histogramPoints {
+					sum := ""
+					if dp.hasSum {
+						sum = fmt.Sprintf("sum=%g,", dp.sum)
+					}
+					fmt.Fprintf(&sb, "  histogram %s %s %d:count=%d,%sbuckets=%v,bounds=%v\n",
+						m.name, otlpLabelsString(dp.attributes), dp.timestamp, dp.count, sum, dp.bucketCounts, dp.explicitBounds)
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+func otlpLabelsString(labels []prompbmarshal.Label) string {
+	a := make([]string, len(labels))
+	for i, label := range labels {
+		a[i] = fmt.Sprintf("%s=%q", label.Name, label.Value)
+	}
+	return "{" + strings.Join(a, ",") + "}"
+}
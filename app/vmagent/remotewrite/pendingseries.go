@@ -35,12 +35,16 @@ type pendingSeries struct {
 
 	stopCh            chan struct{}
 	periodicFlusherWG sync.WaitGroup
+
+	// samplesFlushedOnStop is the number of samples flushed to pushBlock on MustStop call.
+	samplesFlushedOnStop int
 }
 
-func newPendingSeries(pushBlock func(block []byte), isVMRemoteWrite bool, significantFigures, roundDigits int) *pendingSeries {
+func newPendingSeries(pushBlock func(block []byte), isVMRemoteWrite bool, otlpCfg *otlpConfig, significantFigures, roundDigits int) *pendingSeries {
 	var ps pendingSeries
 	ps.wr.pushBlock = pushBlock
 	ps.wr.isVMRemoteWrite = isVMRemoteWrite
+	ps.wr.otlpCfg = otlpCfg
 	ps.wr.significantFigures = significantFigures
 	ps.wr.roundDigits = roundDigits
 	ps.stopCh = make(chan struct{})
@@ -52,9 +56,13 @@ func newPendingSeries(pushBlock func(block []byte), isVMRemoteWrite bool, signif
 	return &ps
 }
 
-func (ps *pendingSeries) MustStop() {
+// MustStop flushes the pending samples to pushBlock and stops ps.
+//
+// It returns the number of flushed samples.
+func (ps *pendingSeries) MustStop() int {
 	close(ps.stopCh)
 	ps.periodicFlusherWG.Wait()
+	return ps.samplesFlushedOnStop
 }
 
 func (ps *pendingSeries) Push(tss []prompbmarshal.TimeSeries) {
@@ -81,6 +89,9 @@ func (ps *pendingSeries) periodicFlusher() {
 			}
 		}
 		ps.mu.Lock()
+		if mustStop {
+			ps.samplesFlushedOnStop = len(ps.wr.samples)
+		}
 		ps.wr.flush()
 		ps.mu.Unlock()
 	}
@@ -96,6 +107,9 @@ type writeRequest struct {
 	// Whether to encode the write request with VictoriaMetrics remote write protocol.
 	isVMRemoteWrite bool
 
+	// otlpCfg is set if the writeRequest must be sent in OpenTelemetry format.
+	otlpCfg *otlpConfig
+
 	// How many significant figures must be left before sending the writeRequest to pushBlock.
 	significantFigures int
 
@@ -112,7 +126,7 @@ type writeRequest struct {
 }
 
 func (wr *writeRequest) reset() {
-	// Do not reset lastFlushTime, pushBlock, isVMRemoteWrite, significantFigures and roundDigits, since they are re-used.
+	// Do not reset lastFlushTime, pushBlock, isVMRemoteWrite, otlpCfg, significantFigures and roundDigits, since they are re-used.
 
 	wr.wr.Timeseries = nil
 
@@ -134,7 +148,11 @@ func (wr *writeRequest) flush() {
 	wr.wr.Timeseries = wr.tss
 	wr.adjustSampleValues()
 	atomic.StoreUint64(&wr.lastFlushTime, fasttime.UnixTimestamp())
-	pushWriteRequest(&wr.wr, wr.pushBlock, wr.isVMRemoteWrite)
+	if wr.otlpCfg != nil {
+		pushOTLPWriteRequest(&wr.wr, wr.pushBlock, wr.otlpCfg)
+	} else {
+		pushWriteRequest(&wr.wr, wr.pushBlock, wr.isVMRemoteWrite)
+	}
 	wr.reset()
 }
 
@@ -3,9 +3,11 @@ package remotewrite
 import (
 	"flag"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
@@ -87,8 +89,11 @@ func initLabelsGlobal() {
 	}
 }
 
-func (rctx *relabelCtx) applyRelabeling(tss []prompbmarshal.TimeSeries, extraLabels []prompbmarshal.Label, pcs *promrelabel.ParsedConfigs) []prompbmarshal.TimeSeries {
-	if len(extraLabels) == 0 && pcs.Len() == 0 && !*usePromCompatibleNaming {
+// applyRelabeling applies pcs to tss after adding extraLabels and tenantLabels to them.
+//
+// extraLabels override labels with the same names in tss, while tenantLabels are added only to time series without labels with the same names.
+func (rctx *relabelCtx) applyRelabeling(tss []prompbmarshal.TimeSeries, extraLabels, tenantLabels []prompbmarshal.Label, pcs *promrelabel.ParsedConfigs) []prompbmarshal.TimeSeries {
+	if len(extraLabels) == 0 && len(tenantLabels) == 0 && pcs.Len() == 0 && !*usePromCompatibleNaming {
 		// Nothing to change.
 		return tss
 	}
@@ -108,6 +113,12 @@ func (rctx *relabelCtx) applyRelabeling(tss []prompbmarshal.TimeSeries, extraLab
 				labels = append(labels, *extraLabel)
 			}
 		}
+		for j := range tenantLabels {
+			tenantLabel := &tenantLabels[j]
+			if promrelabel.GetLabelByName(labels[labelsLen:], tenantLabel.Name) == nil {
+				labels = append(labels, *tenantLabel)
+			}
+		}
 		if *usePromCompatibleNaming {
 			// Replace unsupported Prometheus chars in label names and metric names with underscores.
 			tmpLabels := labels[labelsLen:]
@@ -135,6 +146,58 @@ func (rctx *relabelCtx) applyRelabeling(tss []prompbmarshal.TimeSeries, extraLab
 	return tssDst
 }
 
+// Labels with tenant ids, which are added to time series before applying -remoteWrite.urlRelabelConfig
+// and -remoteWrite.streamAggr.config when -remoteWrite.multitenantURL is set.
+//
+// These labels are removed before sending the data to remote storage.
+// Labels with the same names, which already exist in the ingested time series, aren't overridden.
+const (
+	tenantAccountIDLabel = "vm_account_id"
+	tenantProjectIDLabel = "vm_project_id"
+)
+
+func newTenantLabels(at *auth.Token) []prompbmarshal.Label {
+	if at == nil {
+		return nil
+	}
+	return []prompbmarshal.Label{
+		{
+			Name:  tenantAccountIDLabel,
+			Value: strconv.FormatUint(uint64(at.AccountID), 10),
+		},
+		{
+			Name:  tenantProjectIDLabel,
+			Value: strconv.FormatUint(uint64(at.ProjectID), 10),
+		},
+	}
+}
+
+// removeTenantLabels removes tenantLabels added by applyRelabeling from tss in place.
+//
+// Labels with the same names, but with other values, are left as is, since they were ingested with the time series.
+func removeTenantLabels(tss []prompbmarshal.TimeSeries, tenantLabels []prompbmarshal.Label) {
+	for i := range tss {
+		ts := &tss[i]
+		labels := ts.Labels[:0]
+		for _, label := range ts.Labels {
+			if isTenantLabel(label, tenantLabels) {
+				continue
+			}
+			labels = append(labels, label)
+		}
+		ts.Labels = labels
+	}
+}
+
+func isTenantLabel(label prompbmarshal.Label, tenantLabels []prompbmarshal.Label) bool {
+	for _, tenantLabel := range tenantLabels {
+		if label.Name == tenantLabel.Name && label.Value == tenantLabel.Value {
+			return true
+		}
+	}
+	return false
+}
+
 type relabelCtx struct {
 	// pool for labels, which are used during the relabeling.
 	labels []prompbmarshal.Label
@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
@@ -13,7 +14,7 @@ func TestApplyRelabeling(t *testing.T) {
 	f := func(extraLabels []prompbmarshal.Label, pcs *promrelabel.ParsedConfigs, sTss, sExpTss string) {
 		rctx := &relabelCtx{}
 		tss, expTss := parseSeries(sTss), parseSeries(sExpTss)
-		gotTss := rctx.applyRelabeling(tss, extraLabels, pcs)
+		gotTss := rctx.applyRelabeling(tss, extraLabels, nil, pcs)
 		if !reflect.DeepEqual(gotTss, expTss) {
 			t.Fatalf("expected to have: \n%v;\ngot: \n%v", expTss, gotTss)
 		}
@@ -40,6 +41,50 @@ func TestApplyRelabeling(t *testing.T) {
 	*usePromCompatibleNaming = oldVal
 }
 
+func TestApplyRelabelingTenantLabels(t *testing.T) {
+	pcs, err := promrelabel.ParseRelabelConfigsData([]byte(`
+- action: drop
+  if: '{vm_account_id="1"}'
+- if: '{vm_account_id="2", vm_project_id="3"}'
+  target_label: env
+  replacement: prod
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := func(at *auth.Token, sTss, sExpTss string) {
+		t.Helper()
+		rctx := &relabelCtx{}
+		tss := parseSeries(sTss)
+		// Tenant labels must be visible to relabeling and must be removed after it.
+		tenantLabels := newTenantLabels(at)
+		tss = rctx.applyRelabeling(tss, nil, tenantLabels, pcs)
+		removeTenantLabels(tss, tenantLabels)
+		for i := range tss {
+			promrelabel.SortLabels(tss[i].Labels)
+		}
+		var expTss []prompbmarshal.TimeSeries
+		if sExpTss != "" {
+			expTss = parseSeries(sExpTss)
+		}
+		if len(tss) == 0 && len(expTss) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(tss, expTss) {
+			t.Fatalf("expected to have: \n%v;\ngot: \n%v", expTss, tss)
+		}
+	}
+	f(&auth.Token{AccountID: 1}, `up{job="a"}`, "")
+	f(&auth.Token{AccountID: 2}, `up{job="a"}`, `up{job="a"}`)
+	f(&auth.Token{AccountID: 2, ProjectID: 3}, `up{job="a"}`, `up{env="prod",job="a"}`)
+	f(nil, `up{job="a"}`, `up{job="a"}`)
+
+	// Ingested labels with tenant label names must be preserved.
+	f(&auth.Token{AccountID: 1}, `up{job="a",vm_account_id="5"}`, `up{job="a",vm_account_id="5"}`)
+	f(&auth.Token{AccountID: 2, ProjectID: 3}, `up{job="a",vm_project_id="7"}`, `up{job="a",vm_project_id="7"}`)
+	f(&auth.Token{AccountID: 5}, `up{job="a",vm_account_id="2",vm_project_id="3"}`, `up{env="prod",job="a",vm_account_id="2",vm_project_id="3"}`)
+}
+
 func parseSeries(data string) []prompbmarshal.TimeSeries {
 	var tss []prompbmarshal.TimeSeries
 	tss = append(tss, prompbmarshal.TimeSeries{
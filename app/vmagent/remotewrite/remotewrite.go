@@ -383,7 +383,7 @@ func Push(at *auth.Token, wr *prompbmarshal.WriteRequest) {
 		}
 		if rctx != nil {
 			rowsCountBeforeRelabel := getRowsCount(tssBlock)
-			tssBlock = rctx.applyRelabeling(tssBlock, labelsGlobal, pcsGlobal)
+			tssBlock = rctx.applyRelabeling(tssBlock, labelsGlobal, nil, pcsGlobal)
 			rowsCountAfterRelabel := getRowsCount(tssBlock)
 			rowsDroppedByGlobalRelabel.Add(rowsCountBeforeRelabel - rowsCountAfterRelabel)
 		}
@@ -517,6 +517,10 @@ type remoteWriteCtx struct {
 
 	rowsPushedAfterRelabel *metrics.Counter
 	rowsDroppedByRelabel   *metrics.Counter
+
+	// tenantLabels contains vm_account_id and vm_project_id labels for the tenant
+	// if rwctx is created for -remoteWrite.multitenantURL.
+	tenantLabels []prompbmarshal.Label
 }
 
 func newRemoteWriteCtx(argIdx int, at *auth.Token, remoteWriteURL *url.URL, maxInmemoryBlocks int, sanitizedURL string) *remoteWriteCtx {
@@ -555,7 +559,7 @@ func newRemoteWriteCtx(argIdx int, at *auth.Token, remoteWriteURL *url.URL, maxI
 	}
 	pss := make([]*pendingSeries, pssLen)
 	for i := range pss {
-		pss[i] = newPendingSeries(fq.MustWriteBlock, c.useVMProto, sf, rd)
+		pss[i] = newPendingSeries(fq.MustWriteBlock, c.useVMProto, c.otlpCfg, sf, rd)
 	}
 
 	rwctx := &remoteWriteCtx{
@@ -566,13 +570,15 @@ func newRemoteWriteCtx(argIdx int, at *auth.Token, remoteWriteURL *url.URL, maxI
 
 		rowsPushedAfterRelabel: metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_rows_pushed_after_relabel_total{path=%q, url=%q}`, queuePath, sanitizedURL)),
 		rowsDroppedByRelabel:   metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_relabel_metrics_dropped_total{path=%q, url=%q}`, queuePath, sanitizedURL)),
+
+		tenantLabels: newTenantLabels(at),
 	}
 
 	// Initialize sas
 	sasFile := streamAggrConfig.GetOptionalArg(argIdx)
 	if sasFile != "" {
 		dedupInterval := streamAggrDedupInterval.GetOptionalArgOrDefault(argIdx, 0)
-		sas, err := streamaggr.LoadFromFile(sasFile, rwctx.pushInternal, dedupInterval)
+		sas, err := streamaggr.LoadFromFile(sasFile, rwctx.pushStreamAggrOutput, dedupInterval)
 		if err != nil {
 			logger.Fatalf("cannot initialize stream aggregators from -remoteWrite.streamAggr.config=%q: %s", sasFile, err)
 		}
@@ -586,18 +592,26 @@ func newRemoteWriteCtx(argIdx int, at *auth.Token, remoteWriteURL *url.URL, maxI
 }
 
 func (rwctx *remoteWriteCtx) MustStop() {
+	// Stop stream aggregators before pss, since they flush the aggregated data to pss on stop.
+	sas := rwctx.sas.Swap(nil)
+	sas.MustStop()
+
+	// Flush the pending samples from pss to fq, so they could be sent to remote storage
+	// or persisted to the on-disk queue.
+	samplesFlushed := 0
 	for _, ps := range rwctx.pss {
-		ps.MustStop()
+		samplesFlushed += ps.MustStop()
 	}
 	rwctx.idx = 0
 	rwctx.pss = nil
-	rwctx.fq.UnblockAllReaders()
+	sanitizedURL := rwctx.c.sanitizedURL
+	logger.Infof("flushed %d pending samples to the queue for -remoteWrite.url=%q", samplesFlushed, sanitizedURL)
+
+	rwctx.fq.UnblockAllReaders(*shutdownFlushTimeout)
 	rwctx.c.MustStop()
 	rwctx.c = nil
 
-	sas := rwctx.sas.Swap(nil)
-	sas.MustStop()
-
+	// MustClose persists the remaining in-memory blocks to the on-disk queue.
 	rwctx.fq.MustClose()
 	rwctx.fq = nil
 
@@ -611,7 +625,14 @@ func (rwctx *remoteWriteCtx) Push(tss []prompbmarshal.TimeSeries) {
 	var v *[]prompbmarshal.TimeSeries
 	rcs := allRelabelConfigs.Load().(*relabelConfigs)
 	pcs := rcs.perURL[rwctx.idx]
-	if pcs.Len() > 0 {
+	sas := rwctx.sas.Load()
+	var tenantLabels []prompbmarshal.Label
+	if pcs.Len() > 0 || sas != nil {
+		// Make tenant labels visible to per-URL relabeling and stream aggregation.
+		// They are removed before sending the data to the remote storage.
+		tenantLabels = rwctx.tenantLabels
+	}
+	if pcs.Len() > 0 || len(tenantLabels) > 0 {
 		rctx = getRelabelCtx()
 		// Make a copy of tss before applying relabeling in order to prevent
 		// from affecting time series for other remoteWrite.url configs.
@@ -620,7 +641,7 @@ func (rwctx *remoteWriteCtx) Push(tss []prompbmarshal.TimeSeries) {
 		v = tssRelabelPool.Get().(*[]prompbmarshal.TimeSeries)
 		tss = append(*v, tss...)
 		rowsCountBeforeRelabel := getRowsCount(tss)
-		tss = rctx.applyRelabeling(tss, nil, pcs)
+		tss = rctx.applyRelabeling(tss, nil, tenantLabels, pcs)
 		rowsCountAfterRelabel := getRowsCount(tss)
 		rwctx.rowsDroppedByRelabel.Add(rowsCountBeforeRelabel - rowsCountAfterRelabel)
 	}
@@ -628,9 +649,11 @@ func (rwctx *remoteWriteCtx) Push(tss []prompbmarshal.TimeSeries) {
 	rwctx.rowsPushedAfterRelabel.Add(rowsCount)
 
 	// Apply stream aggregation if any
-	sas := rwctx.sas.Load()
 	sas.Push(tss)
 	if sas == nil || rwctx.streamAggrKeepInput {
+		if len(tenantLabels) > 0 {
+			removeTenantLabels(tss, tenantLabels)
+		}
 		// Push samples to the remote storage
 		rwctx.pushInternal(tss)
 	}
@@ -643,6 +666,14 @@ func (rwctx *remoteWriteCtx) Push(tss []prompbmarshal.TimeSeries) {
 	}
 }
 
+// pushStreamAggrOutput pushes the output of stream aggregation to the remote storage.
+func (rwctx *remoteWriteCtx) pushStreamAggrOutput(tss []prompbmarshal.TimeSeries) {
+	if len(rwctx.tenantLabels) > 0 {
+		removeTenantLabels(tss, rwctx.tenantLabels)
+	}
+	rwctx.pushInternal(tss)
+}
+
 func (rwctx *remoteWriteCtx) pushInternal(tss []prompbmarshal.TimeSeries) {
 	pss := rwctx.pss
 	idx := atomic.AddUint64(&rwctx.pssNextIdx, 1) % uint64(len(pss))
@@ -660,7 +691,7 @@ func (rwctx *remoteWriteCtx) reinitStreamAggr() {
 	logger.Infof("reloading stream aggregation configs pointed by -remoteWrite.streamAggr.config=%q", sasFile)
 	metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_streamaggr_config_reloads_total{path=%q}`, sasFile)).Inc()
 	dedupInterval := streamAggrDedupInterval.GetOptionalArgOrDefault(rwctx.idx, 0)
-	sasNew, err := streamaggr.LoadFromFile(sasFile, rwctx.pushInternal, dedupInterval)
+	sasNew, err := streamaggr.LoadFromFile(sasFile, rwctx.pushStreamAggrOutput, dedupInterval)
 	if err != nil {
 		metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_streamaggr_config_reloads_errors_total{path=%q}`, sasFile)).Inc()
 		metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_streamaggr_config_reload_successful{path=%q}`, sasFile)).Set(0)
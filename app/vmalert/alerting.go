@@ -32,6 +32,12 @@ type AlertingRule struct {
 	GroupName    string
 	EvalInterval time.Duration
 	Debug        bool
+	// Limit overrides the group limit if set.
+	Limit *int
+
+	// KeepFiringFor defines for how long the firing alert is kept firing
+	// after its expression stops returning results.
+	KeepFiringFor time.Duration
 
 	q datasource.Querier
 
@@ -66,6 +72,7 @@ func newAlertingRule(qb datasource.QuerierBuilder, group *Group, cfg config.Rule
 		GroupName:    group.Name,
 		EvalInterval: group.Interval,
 		Debug:        cfg.Debug,
+		Limit:        cfg.Limit,
 		q: qb.BuildWithParams(datasource.QuerierParams{
 			DataSourceType:     group.Type.String(),
 			EvaluationInterval: group.Interval,
@@ -73,8 +80,9 @@ func newAlertingRule(qb datasource.QuerierBuilder, group *Group, cfg config.Rule
 			Headers:            group.Headers,
 			Debug:              cfg.Debug,
 		}),
-		alerts:  make(map[uint64]*notifier.Alert),
-		metrics: &alertingRuleMetrics{},
+		KeepFiringFor: cfg.KeepFiringFor.Duration(),
+		alerts:        make(map[uint64]*notifier.Alert),
+		metrics:       &alertingRuleMetrics{},
 	}
 
 	if cfg.UpdateEntriesLimit != nil {
@@ -250,6 +258,10 @@ func (ar *AlertingRule) ExecRange(ctx context.Context, start, end time.Time) ([]
 		if ar.For == 0 { // if alert is instant
 			a.State = notifier.StateFiring
 			for i := range s.Values {
+				if i > 0 {
+					tss, _ := ar.keepFiringToTimeSeries(a, time.Unix(s.Timestamps[i-1], 0), time.Unix(s.Timestamps[i], 0))
+					result = append(result, tss...)
+				}
 				result = append(result, ar.alertToTimeSeries(a, s.Timestamps[i])...)
 			}
 			continue
@@ -260,9 +272,17 @@ func (ar *AlertingRule) ExecRange(ctx context.Context, start, end time.Time) ([]
 		for i := range s.Values {
 			at := time.Unix(s.Timestamps[i], 0)
 			if at.Sub(prevT) > ar.EvalInterval {
-				// reset to Pending if there are gaps > EvalInterval between DPs
-				a.State = notifier.StatePending
-				a.ActiveAt = at
+				keepFiring := false
+				if a.State == notifier.StateFiring {
+					var tss []prompbmarshal.TimeSeries
+					tss, keepFiring = ar.keepFiringToTimeSeries(a, prevT, at)
+					result = append(result, tss...)
+				}
+				if !keepFiring {
+					// reset to Pending if there are gaps > EvalInterval between DPs
+					a.State = notifier.StatePending
+					a.ActiveAt = at
+				}
 			} else if at.Sub(a.ActiveAt) >= ar.For {
 				a.State = notifier.StateFiring
 				a.Start = at
@@ -274,6 +294,30 @@ func (ar *AlertingRule) ExecRange(ctx context.Context, start, end time.Time) ([]
 	return result, nil
 }
 
+// keepFiringToTimeSeries returns time series for the firing alert a at evaluations
+// in the (prevT, nextT) time range, where the alert expression returns no results.
+// The alert is kept firing during these evaluations if KeepFiringFor is set.
+//
+// It returns true if the alert is still firing at nextT.
+func (ar *AlertingRule) keepFiringToTimeSeries(a *notifier.Alert, prevT, nextT time.Time) ([]prompbmarshal.TimeSeries, bool) {
+	if ar.EvalInterval <= 0 || nextT.Sub(prevT) <= ar.EvalInterval {
+		// There are no evaluations without results.
+		return nil, true
+	}
+	if ar.KeepFiringFor <= 0 {
+		return nil, false
+	}
+	var tss []prompbmarshal.TimeSeries
+	keepFiringSince := prevT.Add(ar.EvalInterval)
+	for t := keepFiringSince; t.Before(nextT); t = t.Add(ar.EvalInterval) {
+		if t.Sub(keepFiringSince) >= ar.KeepFiringFor {
+			return tss, false
+		}
+		tss = append(tss, ar.alertToTimeSeries(a, t.Unix())...)
+	}
+	return tss, true
+}
+
 // resolvedRetention is the duration for which a resolved alert instance
 // is kept in memory state and consequently repeatedly sent to the AlertManager.
 const resolvedRetention = 15 * time.Minute
@@ -314,7 +358,9 @@ func (ar *AlertingRule) Exec(ctx context.Context, ts time.Time, limit int) ([]pr
 	}
 
 	qFn := func(query string) ([]datasource.Metric, error) {
-		res, _, err := ar.q.Query(ctx, query, ts)
+		qCtx, cancel := templates.WithQueryTimeout(ctx)
+		defer cancel()
+		res, _, err := ar.q.Query(qCtx, query, ts)
 		return res, err
 	}
 	updated := make(map[uint64]struct{})
@@ -342,6 +388,8 @@ func (ar *AlertingRule) Exec(ctx context.Context, ts time.Time, limit int) ([]pr
 				a.ActiveAt = ts
 				ar.logDebugf(ts, a, "INACTIVE => PENDING")
 			}
+			// reset KeepFiringSince since the alert is active again
+			a.KeepFiringSince = time.Time{}
 			a.Value = m.Values[0]
 			// re-exec template since Value or query can be used in annotations
 			a.Annotations, err = a.ExecTemplate(qFn, ls.origin, ar.Annotations)
@@ -374,8 +422,20 @@ func (ar *AlertingRule) Exec(ctx context.Context, ts time.Time, limit int) ([]pr
 				continue
 			}
 			if a.State == notifier.StateFiring {
+				if ar.KeepFiringFor > 0 {
+					if a.KeepFiringSince.IsZero() {
+						a.KeepFiringSince = ts
+					}
+					if ts.Sub(a.KeepFiringSince) < ar.KeepFiringFor {
+						numActivePending++
+						ar.logDebugf(ts, a, "KEEP FIRING: is absent in current evaluation round, but keep_firing_for=%s hasn't passed since %v",
+							ar.KeepFiringFor, a.KeepFiringSince)
+						continue
+					}
+				}
 				a.State = notifier.StateInactive
 				a.ResolvedAt = ts
+				a.KeepFiringSince = time.Time{}
 				ar.logDebugf(ts, a, "FIRING => INACTIVE: is absent in current evaluation round")
 			}
 			continue
@@ -388,7 +448,11 @@ func (ar *AlertingRule) Exec(ctx context.Context, ts time.Time, limit int) ([]pr
 			ar.logDebugf(ts, a, "PENDING => FIRING: %s since becoming active at %v", ts.Sub(a.ActiveAt), a.ActiveAt)
 		}
 	}
+	if ar.Limit != nil {
+		limit = *ar.Limit
+	}
 	if limit > 0 && numActivePending > limit {
+		ruleLimitExceeded.Inc()
 		ar.alerts = map[uint64]*notifier.Alert{}
 		curState.err = fmt.Errorf("exec exceeded limit of %d with %d alerts", limit, numActivePending)
 		return nil, curState.err
@@ -418,10 +482,12 @@ func (ar *AlertingRule) UpdateWith(r Rule) error {
 	}
 	ar.Expr = nr.Expr
 	ar.For = nr.For
+	ar.KeepFiringFor = nr.KeepFiringFor
 	ar.Labels = nr.Labels
 	ar.Annotations = nr.Annotations
 	ar.EvalInterval = nr.EvalInterval
 	ar.Debug = nr.Debug
+	ar.Limit = nr.Limit
 	ar.q = nr.q
 	ar.state = nr.state
 	return nil
@@ -490,6 +556,7 @@ func (ar *AlertingRule) ToAPI() APIRule {
 		Name:           ar.Name,
 		Query:          ar.Expr,
 		Duration:       ar.For.Seconds(),
+		KeepFiringFor:  ar.KeepFiringFor.Seconds(),
 		Labels:         ar.Labels,
 		Annotations:    ar.Annotations,
 		LastEvaluation: lastState.time,
@@ -554,6 +621,9 @@ func (ar *AlertingRule) newAlertAPI(a notifier.Alert) *APIAlert {
 		Restored:    a.Restored,
 		Value:       strconv.FormatFloat(a.Value, 'f', -1, 32),
 	}
+	if !a.KeepFiringSince.IsZero() {
+		aa.KeepFiringSince = &a.KeepFiringSince
+	}
 	if alertURLGeneratorFn != nil {
 		aa.SourceLink = alertURLGeneratorFn(a)
 	}
@@ -340,6 +340,71 @@ func TestAlertingRule_Exec(t *testing.T) {
 	}
 }
 
+func TestAlertingRule_ExecKeepFiringFor(t *testing.T) {
+	ar := newTestAlertingRule("keep-firing-for", 0)
+	ar.KeepFiringFor = 10 * time.Second
+	fq := &fakeQuerier{}
+	ar.q = fq
+
+	f := func(ts time.Time, metrics []datasource.Metric, stateExpected notifier.AlertState, keepFiringSinceExpected time.Time) {
+		t.Helper()
+		fq.reset()
+		fq.add(metrics...)
+		if _, err := ar.Exec(context.TODO(), ts, 0); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if len(ar.alerts) != 1 {
+			t.Fatalf("expected 1 alert; got %d", len(ar.alerts))
+		}
+		for _, a := range ar.alerts {
+			if a.State != stateExpected {
+				t.Fatalf("expected state %d; got %d", stateExpected, a.State)
+			}
+			if !a.KeepFiringSince.Equal(keepFiringSinceExpected) {
+				t.Fatalf("expected KeepFiringSince %v; got %v", keepFiringSinceExpected, a.KeepFiringSince)
+			}
+		}
+	}
+	m := metricWithLabels(t, "name", "foo")
+	f(time.Unix(0, 0), []datasource.Metric{m}, notifier.StateFiring, time.Time{})
+	// the alert must be kept firing during keep_firing_for
+	f(time.Unix(5, 0), nil, notifier.StateFiring, time.Unix(5, 0))
+	f(time.Unix(10, 0), nil, notifier.StateFiring, time.Unix(5, 0))
+	// the alert returns - KeepFiringSince must be reset
+	f(time.Unix(15, 0), []datasource.Metric{m}, notifier.StateFiring, time.Time{})
+	f(time.Unix(20, 0), nil, notifier.StateFiring, time.Unix(20, 0))
+	// keep_firing_for has passed
+	f(time.Unix(30, 0), nil, notifier.StateInactive, time.Time{})
+}
+
+func TestAlertingRule_ExecRangeKeepFiringFor(t *testing.T) {
+	ar := newTestAlertingRule("keep-firing-for", 0)
+	ar.EvalInterval = time.Second
+	ar.KeepFiringFor = 3 * time.Second
+	fq := &fakeQuerier{}
+	ar.q = fq
+	fq.add(datasource.Metric{Values: []float64{1, 1, 1}, Timestamps: []int64{1, 4, 10}})
+	tss, err := ar.ExecRange(context.TODO(), time.Now(), time.Now())
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	var timestamps []int64
+	for _, ts := range tss {
+		if ts.Labels[0].Value != alertMetricName {
+			continue
+		}
+		for _, s := range ts.Samples {
+			timestamps = append(timestamps, s.Timestamp/1e3)
+		}
+	}
+	// the gap between 1 and 4 is covered by keep_firing_for,
+	// while the gap between 4 and 10 is covered only partially.
+	timestampsExpected := []int64{1, 2, 3, 4, 5, 6, 7, 10}
+	if !reflect.DeepEqual(timestamps, timestampsExpected) {
+		t.Fatalf("unexpected ALERTS timestamps; got %v; want %v", timestamps, timestampsExpected)
+	}
+}
+
 func TestAlertingRule_ExecRange(t *testing.T) {
 	testCases := []struct {
 		rule      *AlertingRule
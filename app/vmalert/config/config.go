@@ -23,7 +23,7 @@ type Group struct {
 	File        string
 	Name        string              `yaml:"name"`
 	Interval    *promutils.Duration `yaml:"interval,omitempty"`
-	Limit       int                 `yaml:"limit,omitempty"`
+	Limit       *int                `yaml:"limit,omitempty"`
 	Rules       []Rule              `yaml:"rules"`
 	Concurrency int                 `yaml:"concurrency"`
 	// Labels is a set of label value pairs, that will be added to every rule.
@@ -67,6 +67,9 @@ func (g *Group) Validate(validateTplFn ValidateTplFn, validateExpressions bool)
 	if g.Name == "" {
 		return fmt.Errorf("group name must be set")
 	}
+	if g.Limit != nil && *g.Limit < 0 {
+		return fmt.Errorf("invalid limit %d for group %q; it must be non-negative", *g.Limit, g.Name)
+	}
 
 	uniqueRules := map[uint64]struct{}{}
 	for _, r := range g.Rules {
@@ -115,6 +118,13 @@ type Rule struct {
 	// UpdateEntriesLimit defines max number of rule's state updates stored in memory.
 	// Overrides `-rule.updateEntriesLimit`.
 	UpdateEntriesLimit *int `yaml:"update_entries_limit,omitempty"`
+	// Limit defines the max number of series or alerts the rule may produce during evaluation.
+	// Overrides the group `limit`. Zero means no limit, so it may be used for exempting the rule from the group limit.
+	Limit *int `yaml:"limit,omitempty"`
+
+	// KeepFiringFor defines for how long the alert is kept firing after its expression stops returning results.
+	// See https://prometheus.io/docs/prometheus/latest/configuration/alerting_rules/
+	KeepFiringFor *promutils.Duration `yaml:"keep_firing_for,omitempty"`
 
 	// Catches all undefined fields and must be empty after parsing.
 	XXX map[string]interface{} `yaml:",inline"`
@@ -193,6 +203,12 @@ func (r *Rule) Validate() error {
 	if r.Expr == "" {
 		return fmt.Errorf("expression can't be empty")
 	}
+	if r.Record != "" && r.KeepFiringFor != nil {
+		return fmt.Errorf("`keep_firing_for` can't be set for recording rule")
+	}
+	if r.Limit != nil && *r.Limit < 0 {
+		return fmt.Errorf("invalid limit %d; it must be non-negative", *r.Limit)
+	}
 	return checkOverflow(r.XXX, "rule")
 }
 
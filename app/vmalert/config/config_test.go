@@ -87,6 +87,12 @@ func TestRule_Validate(t *testing.T) {
 	if err := (&Rule{Alert: "alert", Expr: "test>0"}).Validate(); err != nil {
 		t.Errorf("expected valid rule; got %s", err)
 	}
+	if err := (&Rule{Alert: "alert", Expr: "test>0", KeepFiringFor: promutils.NewDuration(time.Minute)}).Validate(); err != nil {
+		t.Errorf("expected valid rule; got %s", err)
+	}
+	if err := (&Rule{Record: "record", Expr: "test", KeepFiringFor: promutils.NewDuration(time.Minute)}).Validate(); err == nil {
+		t.Errorf("expected keep_firing_for error for recording rule")
+	}
 }
 
 func TestGroup_Validate(t *testing.T) {
@@ -101,6 +107,22 @@ func TestGroup_Validate(t *testing.T) {
 			group:  &Group{},
 			expErr: "group name must be set",
 		},
+		{
+			group:  &Group{Name: "test", Limit: func() *int { n := -1; return &n }()},
+			expErr: "invalid limit -1 for group",
+		},
+		{
+			group: &Group{Name: "test",
+				Rules: []Rule{
+					{
+						Record: "record",
+						Expr:   "up",
+						Limit:  func() *int { n := -1; return &n }(),
+					},
+				},
+			},
+			expErr: "invalid limit -1",
+		},
 		{
 			group: &Group{Name: "test",
 				Rules: []Rule{
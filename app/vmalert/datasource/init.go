@@ -13,8 +13,14 @@ import (
 )
 
 var (
-	addr = flag.String("datasource.url", "", "Datasource compatible with Prometheus HTTP API. It can be single node VictoriaMetrics or vmselect URL. Required parameter. "+
-		"E.g. http://127.0.0.1:8428 . See also -remoteRead.disablePathAppend and -datasource.showURL")
+	addrs = flagutil.NewArrayString("datasource.url", "Datasource compatible with Prometheus HTTP API. It can be single node VictoriaMetrics or vmselect URL. Required parameter. "+
+		"E.g. http://127.0.0.1:8428 . Multiple datasources may be specified for high availability. See also -datasource.lbPolicy, -remoteRead.disablePathAppend and -datasource.showURL")
+	lbPolicy = flag.String("datasource.lbPolicy", lbPolicyFailover, "Policy for spreading requests among multiple -datasource.url values. "+
+		"Supported values: failover, round-robin. The failover policy sends requests to the first available datasource in the order of -datasource.url values, "+
+		"while the round-robin policy spreads requests evenly among all the datasources. "+
+		"In both cases the request is retried at the next datasource on connection errors and 5xx responses")
+	failoverProbeInterval = flag.Duration("datasource.failoverProbeInterval", 30*time.Second, "How often to probe datasources with higher priority "+
+		"than the currently active datasource, so vmalert can return to the primary datasource after it becomes available. Applied only for -datasource.lbPolicy=failover")
 	appendTypePrefix  = flag.Bool("datasource.appendTypePrefix", false, "Whether to add type prefix to -datasource.url based on the query type. Set to true if sending different query types to the vmselect URL.")
 	showDatasourceURL = flag.Bool("datasource.showURL", false, "Whether to show -datasource.url in the exported metrics. "+
 		"It is hidden by default, since it can contain sensitive info such as auth key")
@@ -71,11 +77,23 @@ type Param struct {
 // Provided extraParams will be added as GET params for
 // each request.
 func Init(extraParams url.Values) (QuerierBuilder, error) {
-	if *addr == "" {
+	if len(*addrs) == 0 || (*addrs)[0] == "" {
 		return nil, fmt.Errorf("datasource.url is empty")
 	}
+	p, err := newPool(*addrs, *lbPolicy, *failoverProbeInterval, *showDatasourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure datasources: %w", err)
+	}
 
-	tr, err := utils.Transport(*addr, *tlsCertFile, *tlsKeyFile, *tlsCAFile, *tlsServerName, *tlsInsecureSkipVerify)
+	// Use the first https datasource for building the transport, so TLS settings are applied to all the https datasources.
+	trURL := (*addrs)[0]
+	for _, u := range *addrs {
+		if strings.HasPrefix(u, "https") {
+			trURL = u
+			break
+		}
+	}
+	tr, err := utils.Transport(trURL, *tlsCertFile, *tlsKeyFile, *tlsCAFile, *tlsServerName, *tlsInsecureSkipVerify)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transport: %w", err)
 	}
@@ -104,7 +122,7 @@ func Init(extraParams url.Values) (QuerierBuilder, error) {
 	return &VMStorage{
 		c:                &http.Client{Transport: tr},
 		authCfg:          authCfg,
-		datasourceURL:    strings.TrimSuffix(*addr, "/"),
+		pool:             p,
 		appendTypePrefix: *appendTypePrefix,
 		lookBack:         *lookBack,
 		queryStep:        *queryStep,
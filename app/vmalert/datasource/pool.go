@@ -0,0 +1,166 @@
+package datasource
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+const (
+	// lbPolicyFailover sends all the requests to the first available datasource in the order of -datasource.url flags.
+	lbPolicyFailover = "failover"
+	// lbPolicyRoundRobin spreads requests evenly among all the configured datasources.
+	lbPolicyRoundRobin = "round-robin"
+)
+
+// backend is a single datasource from -datasource.url list.
+type backend struct {
+	url string
+
+	// name is used for referring to the backend in logs and web UI.
+	name string
+
+	failures *metrics.Counter
+}
+
+// pool holds datasources configured via -datasource.url.
+//
+// It is shared among all the VMStorage clones, so the selected datasource is the same for all the groups.
+type pool struct {
+	backends      []*backend
+	policy        string
+	probeInterval time.Duration
+
+	// activeIdx is the index of the currently active backend for lbPolicyFailover.
+	activeIdx uint32
+
+	// lastProbeTime is the unix timestamp in seconds for the last attempt to return to the primary backend.
+	lastProbeTime uint64
+
+	// isProbing is set to 1 while backends with higher priority than the active one are probed.
+	isProbing uint32
+
+	// nextIdx is the index of the next backend for lbPolicyRoundRobin.
+	nextIdx uint32
+}
+
+func newPool(urls []string, policy string, probeInterval time.Duration, showURL bool) (*pool, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("datasource urls cannot be empty")
+	}
+	switch policy {
+	case lbPolicyFailover, lbPolicyRoundRobin:
+	default:
+		return nil, fmt.Errorf("unsupported load balancing policy %q; supported values: %s, %s", policy, lbPolicyFailover, lbPolicyRoundRobin)
+	}
+	p := &pool{
+		policy:        policy,
+		probeInterval: probeInterval,
+	}
+	for i, u := range urls {
+		name := fmt.Sprintf("%d:secret-url", i+1)
+		if showURL {
+			name = fmt.Sprintf("%d:%s", i+1, u)
+		}
+		p.backends = append(p.backends, &backend{
+			url:      strings.TrimSuffix(u, "/"),
+			name:     name,
+			failures: metrics.GetOrCreateCounter(fmt.Sprintf(`vmalert_datasource_failures_total{datasource=%q}`, name)),
+		})
+	}
+	return p, nil
+}
+
+// newSinglePool returns pool with a single datasource at the given url.
+func newSinglePool(url string) *pool {
+	return &pool{
+		backends: []*backend{{
+			url:  strings.TrimSuffix(url, "/"),
+			name: "1:secret-url",
+		}},
+		policy: lbPolicyFailover,
+	}
+}
+
+// getBackends returns backends in the order they must be tried for the next request.
+func (p *pool) getBackends() []int {
+	n := len(p.backends)
+	if n == 1 {
+		return []int{0}
+	}
+	start := 0
+	switch p.policy {
+	case lbPolicyRoundRobin:
+		start = int((atomic.AddUint32(&p.nextIdx, 1) - 1) % uint32(n))
+	default:
+		start = int(atomic.LoadUint32(&p.activeIdx))
+	}
+	idxs := make([]int, n)
+	for i := range idxs {
+		idxs[i] = (start + i) % n
+	}
+	return idxs
+}
+
+// startProbe returns the number of backends with higher priority than the active one, which must be probed with lbPolicyFailover.
+//
+// Zero is returned if there is no need in probing. Otherwise finishProbe must be called after the probing is finished.
+func (p *pool) startProbe() int {
+	if p.policy != lbPolicyFailover {
+		return 0
+	}
+	n := int(atomic.LoadUint32(&p.activeIdx))
+	if n == 0 || !p.shouldProbe() {
+		return 0
+	}
+	if !atomic.CompareAndSwapUint32(&p.isProbing, 0, 1) {
+		// The previous probe is still in progress.
+		return 0
+	}
+	return n
+}
+
+// finishProbe must be called after the probing started by startProbe is finished.
+func (p *pool) finishProbe() {
+	atomic.StoreUint32(&p.isProbing, 0)
+}
+
+func (p *pool) shouldProbe() bool {
+	ct := fasttime.UnixTimestamp()
+	lastProbeTime := atomic.LoadUint64(&p.lastProbeTime)
+	if ct-lastProbeTime < uint64(p.probeInterval.Seconds()) {
+		return false
+	}
+	return atomic.CompareAndSwapUint64(&p.lastProbeTime, lastProbeTime, ct)
+}
+
+// markSuccess must be called after the successful request to the backend with the given idx.
+//
+// activeIdx must contain the active backend index at the time the request was started.
+// The backend with the given idx becomes active only if the active backend wasn't changed concurrently,
+// so slow requests cannot override the result of the more recent failover or probe.
+func (p *pool) markSuccess(activeIdx, idx int) {
+	if p.policy != lbPolicyFailover || activeIdx == idx {
+		return
+	}
+	if atomic.CompareAndSwapUint32(&p.activeIdx, uint32(activeIdx), uint32(idx)) {
+		logger.Infof("switched active datasource from %s to %s", p.backends[activeIdx].name, p.backends[idx].name)
+	}
+}
+
+// markFailure must be called after the failed request to the backend with the given idx.
+func (p *pool) markFailure(idx int) {
+	if b := p.backends[idx]; b.failures != nil {
+		b.failures.Inc()
+	}
+}
+
+// activeBackend returns the name of the backend, which is going to serve the next request for lbPolicyFailover.
+func (p *pool) activeBackend() string {
+	return p.backends[atomic.LoadUint32(&p.activeIdx)].name
+}
@@ -0,0 +1,188 @@
+package datasource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestDatasource(t *testing.T, healthy *uint32, requests *uint64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(requests, 1)
+		if atomic.LoadUint32(healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPoolFailover(t *testing.T) {
+	healthy := []uint32{1, 1}
+	requests := make([]uint64, 2)
+	probes := make([]uint64, 2)
+	var urls []string
+	for i := range healthy {
+		i := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.FormValue("query") == "1" {
+				atomic.AddUint64(&probes[i], 1)
+			} else {
+				atomic.AddUint64(&requests[i], 1)
+			}
+			if atomic.LoadUint32(&healthy[i]) == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		}))
+		t.Cleanup(srv.Close)
+		urls = append(urls, srv.URL)
+	}
+
+	p, err := newPool(urls, lbPolicyFailover, 0, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	s := NewVMStorage(urls[0], nil, 0, 0, false, http.DefaultClient)
+	s.pool = p
+	q := s.BuildWithParams(QuerierParams{}).(*VMStorage)
+
+	query := func() {
+		t.Helper()
+		if _, _, err := q.Query(context.Background(), "up", time.Now()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	checkRequests := func(n1, n2 uint64) {
+		t.Helper()
+		r1, r2 := atomic.LoadUint64(&requests[0]), atomic.LoadUint64(&requests[1])
+		if r1 != n1 || r2 != n2 {
+			t.Fatalf("unexpected number of requests; got %d, %d; want %d, %d", r1, r2, n1, n2)
+		}
+	}
+	waitForProbe := func(nExpected uint64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for atomic.LoadUint64(&probes[0]) < nExpected || atomic.LoadUint32(&p.isProbing) != 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout when waiting for %d probes of the primary datasource", nExpected)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	query()
+	checkRequests(1, 0)
+	if ds := q.ActiveDatasource(); ds != "1:secret-url" {
+		t.Fatalf("unexpected active datasource; got %q; want %q", ds, "1:secret-url")
+	}
+
+	// The primary datasource is unavailable.
+	atomic.StoreUint32(&healthy[0], 0)
+	query()
+	checkRequests(2, 1)
+	if ds := q.ActiveDatasource(); ds != "2:secret-url" {
+		t.Fatalf("unexpected active datasource; got %q; want %q", ds, "2:secret-url")
+	}
+
+	// Rules evaluation requests mustn't be sent to the unavailable primary datasource,
+	// while it is probed with a lightweight query.
+	query()
+	waitForProbe(1)
+	checkRequests(2, 2)
+	if ds := p.activeBackend(); ds != "2:secret-url" {
+		t.Fatalf("unexpected active datasource after the failed probe; got %q; want %q", ds, "2:secret-url")
+	}
+
+	// The primary datasource becomes available again, but the probe interval didn't pass yet.
+	atomic.StoreUint32(&healthy[0], 1)
+	p.probeInterval = time.Hour
+	atomic.StoreUint64(&p.lastProbeTime, uint64(time.Now().Unix()))
+	query()
+	checkRequests(2, 3)
+	if n := atomic.LoadUint64(&probes[0]); n != 1 {
+		t.Fatalf("unexpected number of probes before the probe interval passes; got %d; want 1", n)
+	}
+
+	// The successful probe must return vmalert to the primary datasource.
+	p.probeInterval = 0
+	query()
+	waitForProbe(2)
+	checkRequests(2, 4)
+	if ds := p.activeBackend(); ds != "1:secret-url" {
+		t.Fatalf("unexpected active datasource after the successful probe; got %q; want %q", ds, "1:secret-url")
+	}
+	query()
+	checkRequests(3, 4)
+	if n := atomic.LoadUint64(&probes[1]); n != 0 {
+		t.Fatalf("the active datasource mustn't be probed; got %d probes", n)
+	}
+
+	// All the datasources are unavailable.
+	atomic.StoreUint32(&healthy[0], 0)
+	atomic.StoreUint32(&healthy[1], 0)
+	_, _, err = q.Query(context.Background(), "up", time.Now())
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	for _, name := range []string{"1:secret-url", "2:secret-url"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("error %q must contain datasource %q", err, name)
+		}
+	}
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	healthy := []uint32{1, 1, 1}
+	requests := make([]uint64, 3)
+	var urls []string
+	for i := range healthy {
+		srv := newTestDatasource(t, &healthy[i], &requests[i])
+		urls = append(urls, srv.URL)
+	}
+	p, err := newPool(urls, lbPolicyRoundRobin, 0, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	s := NewVMStorage(urls[0], nil, 0, 0, false, http.DefaultClient)
+	s.pool = p
+	q := s.BuildWithParams(QuerierParams{})
+	for i := 0; i < 6; i++ {
+		if _, _, err := q.Query(context.Background(), "up", time.Now()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	for i, n := range requests {
+		if n != 2 {
+			t.Fatalf("unexpected number of requests to datasource #%d; got %d; want 2", i, n)
+		}
+	}
+
+	// Requests to unavailable datasource must be retried at the next datasource.
+	atomic.StoreUint32(&healthy[1], 0)
+	for i := 0; i < 3; i++ {
+		if _, _, err := q.Query(context.Background(), "up", time.Now()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if requests[0] != 3 || requests[1] != 3 || requests[2] != 4 {
+		t.Fatalf("unexpected number of requests; got %v; want [3 3 4]", requests)
+	}
+}
+
+func TestNewPoolFailure(t *testing.T) {
+	if _, err := newPool(nil, lbPolicyFailover, 0, false); err == nil {
+		t.Fatalf("expecting non-nil error for empty urls")
+	}
+	if _, err := newPool([]string{"http://foo"}, "random", 0, false); err == nil {
+		t.Fatalf("expecting non-nil error for unsupported policy")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -31,7 +32,7 @@ func toDatasourceType(s string) datasourceType {
 type VMStorage struct {
 	c                *http.Client
 	authCfg          *promauth.Config
-	datasourceURL    string
+	pool             *pool
 	appendTypePrefix bool
 	lookBack         time.Duration
 	queryStep        time.Duration
@@ -44,6 +45,10 @@ type VMStorage struct {
 	// whether to print additional log messages
 	// for each sent request
 	debug bool
+
+	// lastBackendIdx is the index+1 of the pool backend, which served the last request.
+	// It is 0 if no requests were served yet.
+	lastBackendIdx uint32
 }
 
 type keyValue struct {
@@ -56,7 +61,7 @@ func (s *VMStorage) Clone() *VMStorage {
 	return &VMStorage{
 		c:                s.c,
 		authCfg:          s.authCfg,
-		datasourceURL:    s.datasourceURL,
+		pool:             s.pool,
 		lookBack:         s.lookBack,
 		queryStep:        s.queryStep,
 		appendTypePrefix: s.appendTypePrefix,
@@ -89,7 +94,7 @@ func NewVMStorage(baseURL string, authCfg *promauth.Config, lookBack time.Durati
 	return &VMStorage{
 		c:                c,
 		authCfg:          authCfg,
-		datasourceURL:    strings.TrimSuffix(baseURL, "/"),
+		pool:             newSinglePool(baseURL),
 		appendTypePrefix: appendTypePrefix,
 		lookBack:         lookBack,
 		queryStep:        queryStep,
@@ -97,23 +102,33 @@ func NewVMStorage(baseURL string, authCfg *promauth.Config, lookBack time.Durati
 	}
 }
 
-// Query executes the given query and returns parsed response
-func (s *VMStorage) Query(ctx context.Context, query string, ts time.Time) ([]Metric, *http.Request, error) {
-	req, err := s.newRequestPOST()
-	if err != nil {
-		return nil, nil, err
+// ActiveDatasource returns the name of the datasource, which served the last request sent via s.
+//
+// If s didn't send requests yet, then the datasource, which is going to serve the next request, is returned.
+func (s *VMStorage) ActiveDatasource() string {
+	if idx := atomic.LoadUint32(&s.lastBackendIdx); idx > 0 {
+		return s.pool.backends[idx-1].name
 	}
+	return s.pool.activeBackend()
+}
 
+// Query executes the given query and returns parsed response
+func (s *VMStorage) Query(ctx context.Context, query string, ts time.Time) ([]Metric, *http.Request, error) {
+	var setReqParams func(r *http.Request)
 	switch s.dataSourceType {
 	case "", datasourcePrometheus:
-		s.setPrometheusInstantReqParams(req, query, ts)
+		setReqParams = func(r *http.Request) {
+			s.setPrometheusInstantReqParams(r, query, ts)
+		}
 	case datasourceGraphite:
-		s.setGraphiteReqParams(req, query, ts)
+		setReqParams = func(r *http.Request) {
+			s.setGraphiteReqParams(r, query, ts)
+		}
 	default:
 		return nil, nil, fmt.Errorf("engine not found: %q", s.dataSourceType)
 	}
 
-	resp, err := s.do(ctx, req)
+	resp, req, err := s.do(ctx, setReqParams)
 	if err != nil {
 		return nil, req, err
 	}
@@ -136,18 +151,15 @@ func (s *VMStorage) QueryRange(ctx context.Context, query string, start, end tim
 	if s.dataSourceType != datasourcePrometheus {
 		return nil, fmt.Errorf("%q is not supported for QueryRange", s.dataSourceType)
 	}
-	req, err := s.newRequestPOST()
-	if err != nil {
-		return nil, err
-	}
 	if start.IsZero() {
 		return nil, fmt.Errorf("start param is missing")
 	}
 	if end.IsZero() {
 		return nil, fmt.Errorf("end param is missing")
 	}
-	s.setPrometheusRangeReqParams(req, query, start, end)
-	resp, err := s.do(ctx, req)
+	resp, req, err := s.do(ctx, func(r *http.Request) {
+		s.setPrometheusRangeReqParams(r, query, start, end)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -157,24 +169,105 @@ func (s *VMStorage) QueryRange(ctx context.Context, query string, start, end tim
 	return parsePrometheusResponse(req, resp)
 }
 
-func (s *VMStorage) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+// do sends the request to the datasources from s.pool according to -datasource.lbPolicy.
+//
+// The next datasource is tried on connection errors and 5xx responses.
+// setReqParams must set query params for the request.
+func (s *VMStorage) do(ctx context.Context, setReqParams func(r *http.Request)) (*http.Response, *http.Request, error) {
+	if n := s.pool.startProbe(); n > 0 {
+		go s.probeBackends(n)
+	}
+	var req *http.Request
+	var errs []string
+	idxs := s.pool.getBackends()
+	for _, idx := range idxs {
+		var err error
+		req, err = s.newRequestPOST(s.pool.backends[idx].url)
+		if err != nil {
+			return nil, nil, err
+		}
+		setReqParams(req)
+		resp, retry, err := s.doRequest(ctx, req)
+		if err == nil {
+			s.pool.markSuccess(idxs[0], idx)
+			atomic.StoreUint32(&s.lastBackendIdx, uint32(idx+1))
+			return resp, req, nil
+		}
+		atomic.StoreUint32(&s.lastBackendIdx, uint32(idx+1))
+		s.pool.markFailure(idx)
+		if len(s.pool.backends) > 1 {
+			err = fmt.Errorf("datasource %s: %w", s.pool.backends[idx].name, err)
+		}
+		if !retry || ctx.Err() != nil || len(s.pool.backends) == 1 {
+			return nil, req, err
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, req, fmt.Errorf("all the %d datasources failed: %s", len(errs), strings.Join(errs, "; "))
+}
+
+// probeTimeout is the timeout for probing datasources with higher priority than the active one.
+const probeTimeout = 10 * time.Second
+
+// probeBackends probes the first n datasources from s.pool with a lightweight query
+// and makes active the first datasource, which successfully served the query.
+//
+// n must be equal to the index of the active datasource.
+//
+// This allows returning to the primary datasource after it becomes available
+// without sending rules evaluation requests to unavailable datasources.
+func (s *VMStorage) probeBackends(n int) {
+	defer s.pool.finishProbe()
+	for idx := 0; idx < n; idx++ {
+		if err := s.probeBackend(idx); err != nil {
+			s.pool.markFailure(idx)
+			if s.debug {
+				logger.Infof("DEBUG datasource probe: datasource %s is unavailable: %s", s.pool.backends[idx].name, err)
+			}
+			continue
+		}
+		s.pool.markSuccess(n, idx)
+		return
+	}
+}
+
+func (s *VMStorage) probeBackend(idx int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	req, err := s.newRequestPOST(s.pool.backends[idx].url)
+	if err != nil {
+		return err
+	}
+	s.setPrometheusInstantReqParams(req, "1", time.Now())
+	resp, _, err := s.doRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// doRequest sends req to the datasource.
+//
+// It returns true if the request can be retried at another datasource.
+func (s *VMStorage) doRequest(ctx context.Context, req *http.Request) (*http.Response, bool, error) {
 	if s.debug {
 		logger.Infof("DEBUG datasource request: executing %s request with params %q", req.Method, req.URL.RawQuery)
 	}
 	resp, err := s.c.Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("error getting response from %s: %w", req.URL.Redacted(), err)
+		return nil, true, fmt.Errorf("error getting response from %s: %w", req.URL.Redacted(), err)
 	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		_ = resp.Body.Close()
-		return nil, fmt.Errorf("unexpected response code %d for %s. Response body %s", resp.StatusCode, req.URL.Redacted(), body)
+		return nil, resp.StatusCode >= 500, fmt.Errorf("unexpected response code %d for %s. Response body %s", resp.StatusCode, req.URL.Redacted(), body)
 	}
-	return resp, nil
+	return resp, false, nil
 }
 
-func (s *VMStorage) newRequestPOST() (*http.Request, error) {
-	req, err := http.NewRequest(http.MethodPost, s.datasourceURL, nil)
+func (s *VMStorage) newRequestPOST(datasourceURL string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, datasourceURL, nil)
 	if err != nil {
 		return nil, err
 	}
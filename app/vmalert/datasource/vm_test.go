@@ -494,7 +494,7 @@ func TestRequestParams(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			req, err := tc.vm.newRequestPOST()
+			req, err := tc.vm.newRequestPOST("")
 			if err != nil {
 				t.Fatalf("unexpected error: %s", err)
 			}
@@ -591,7 +591,7 @@ func TestHeaders(t *testing.T) {
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
 			vm := tt.vmFn()
-			req, err := vm.newRequestPOST()
+			req, err := vm.newRequestPOST("")
 			if err != nil {
 				t.Fatalf("unexpected error: %s", err)
 			}
@@ -97,7 +97,6 @@ func newGroup(cfg config.Group, qb datasource.QuerierBuilder, defaultInterval ti
 		Name:        cfg.Name,
 		File:        cfg.File,
 		Interval:    cfg.Interval.Duration(),
-		Limit:       cfg.Limit,
 		Concurrency: cfg.Concurrency,
 		Checksum:    cfg.Checksum,
 		Params:      cfg.Params,
@@ -111,6 +110,10 @@ func newGroup(cfg config.Group, qb datasource.QuerierBuilder, defaultInterval ti
 	if g.Interval == 0 {
 		g.Interval = defaultInterval
 	}
+	g.Limit = *ruleDefaultLimit
+	if cfg.Limit != nil {
+		g.Limit = *cfg.Limit
+	}
 	if g.Concurrency < 1 {
 		g.Concurrency = 1
 	}
@@ -444,7 +447,8 @@ func (e *executor) execConcurrently(ctx context.Context, rules []Rule, ts time.T
 }
 
 var (
-	alertsFired = metrics.NewCounter(`vmalert_alerts_fired_total`)
+	alertsFired       = metrics.NewCounter(`vmalert_alerts_fired_total`)
+	ruleLimitExceeded = metrics.NewCounter(`vmalert_rule_limit_exceeded_total`)
 
 	execTotal  = metrics.NewCounter(`vmalert_execution_total`)
 	execErrors = metrics.NewCounter(`vmalert_execution_errors_total`)
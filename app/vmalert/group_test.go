@@ -269,6 +269,30 @@ func TestResolveDuration(t *testing.T) {
 	}
 }
 
+func TestGroupLimit(t *testing.T) {
+	defaultLimit := *ruleDefaultLimit
+	defer func() { *ruleDefaultLimit = defaultLimit }()
+	*ruleDefaultLimit = 10
+
+	f := func(limit *int, limitExpected int) {
+		t.Helper()
+		g := newGroup(config.Group{Name: "test", Limit: limit}, &fakeQuerier{}, time.Minute, nil)
+		if g.Limit != limitExpected {
+			t.Fatalf("unexpected group limit; got %d; want %d", g.Limit, limitExpected)
+		}
+	}
+	intPtr := func(n int) *int { return &n }
+
+	// -rule.defaultLimit is used if limit isn't set
+	f(nil, 10)
+
+	// zero limit disables -rule.defaultLimit
+	f(intPtr(0), 0)
+
+	// limit overrides -rule.defaultLimit
+	f(intPtr(5), 5)
+}
+
 func TestGetStaleSeries(t *testing.T) {
 	ts := time.Now()
 	e := &executor{
@@ -68,6 +68,9 @@ absolute path to all .tpl files in root.`)
 	resendDelay            = flag.Duration("rule.resendDelay", 0, "Minimum amount of time to wait before resending an alert to notifier")
 	ruleUpdateEntriesLimit = flag.Int("rule.updateEntriesLimit", 20, "Defines the max number of rule's state updates stored in-memory. "+
 		"Rule's updates are available on rule's Details page and are used for debugging purposes. The number of stored updates can be overriden per rule via update_entries_limit param.")
+	ruleDefaultLimit = flag.Int("rule.defaultLimit", 0, "Default limit for the number of series or alerts a single rule may produce during evaluation. "+
+		"Evaluation results exceeding the limit are discarded and the rule is marked with an error. "+
+		"The limit can be overridden via `limit` param per group or per rule. Zero means no limit. See https://docs.victoriametrics.com/vmalert.html#groups")
 
 	externalURL         = flag.String("external.url", "", "External URL is used as alert's source for sent alerts to the notifier")
 	externalAlertSource = flag.String("external.alert.source", "", `External Alert Source allows to override the Source link for alerts sent to AlertManager `+
@@ -111,6 +114,13 @@ func main() {
 		logger.Fatalf("failed to parse %q: %s", *ruleTemplatesPath, err)
 	}
 
+	if len(*unitTestFiles) > 0 {
+		if !unitTest(*unitTestFiles) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *dryRun {
 		groups, err := config.Parse(*rulePath, notifier.ValidateTemplates, true)
 		if err != nil {
@@ -184,7 +194,7 @@ func main() {
 	go configReload(ctx, manager, groupsCfg, sighupCh)
 
 	rh := &requestHandler{m: manager}
-	go httpserver.Serve(*httpListenAddr, *useProxyProtocol, rh.handler)
+	go httpserver.Serve(*httpListenAddr, *useProxyProtocol, httpserver.WithDetailedMetrics(rh.handler, detailedMetricsPathPrefixes))
 
 	sig := procutil.WaitForSigterm()
 	logger.Infof("service received signal %s", sig)
@@ -182,6 +182,7 @@ func (g *Group) toAPI() APIGroup {
 		Interval:       g.Interval.Seconds(),
 		LastEvaluation: g.LastEvaluation,
 		Concurrency:    g.Concurrency,
+		Limit:          g.Limit,
 		Params:         urlValuesToStrings(g.Params),
 		Headers:        headersToStrings(g.Headers),
 		Labels:         g.Labels,
@@ -189,9 +190,29 @@ func (g *Group) toAPI() APIGroup {
 	for _, r := range g.Rules {
 		ag.Rules = append(ag.Rules, r.ToAPI())
 	}
+	ag.Datasource = g.activeDatasource()
 	return ag
 }
 
+// activeDatasource returns the datasource, which served the most recent request for the first g rule.
+//
+// Empty string is returned if the datasource cannot be determined.
+func (g *Group) activeDatasource() string {
+	for _, r := range g.Rules {
+		var q datasource.Querier
+		switch rr := r.(type) {
+		case *AlertingRule:
+			q = rr.q
+		case *RecordingRule:
+			q = rr.q
+		}
+		if vm, ok := q.(*datasource.VMStorage); ok {
+			return vm.ActiveDatasource()
+		}
+	}
+	return ""
+}
+
 func urlValuesToStrings(values url.Values) []string {
 	if len(values) < 1 {
 		return nil
@@ -47,6 +47,9 @@ type Alert struct {
 	Restored bool
 	// For defines for how long Alert needs to be active to become StateFiring
 	For time.Duration
+	// KeepFiringSince defines the moment when StateFiring was kept because of `keep_firing_for`
+	// instead of being switched to StateInactive
+	KeepFiringSince time.Time
 }
 
 // AlertState type indicates the Alert state
@@ -101,6 +104,9 @@ var tplHeaders = []string{
 // map of annotations.
 // Every alert could have a different datasource, so function
 // requires a queryFunction as an argument.
+//
+// Errors in `query` template function don't fail the execution. Instead, the annotation
+// is set to the error message, so the alert is still delivered to notifiers.
 func (a *Alert) ExecTemplate(q templates.QueryFn, labels, annotations map[string]string) (map[string]string, error) {
 	tplData := AlertTplData{
 		Value:    a.Value,
@@ -111,16 +117,22 @@ func (a *Alert) ExecTemplate(q templates.QueryFn, labels, annotations map[string
 		ActiveAt: a.ActiveAt,
 		For:      a.For,
 	}
-	return ExecTemplate(q, annotations, tplData)
+	return execTemplate(q, annotations, tplData, true)
 }
 
 // ExecTemplate executes the given template for given annotations map.
+//
+// Errors in `query` template function fail the execution, since it is used for templating alert labels.
 func ExecTemplate(q templates.QueryFn, annotations map[string]string, tplData AlertTplData) (map[string]string, error) {
-	tmpl, err := templates.GetWithFuncs(templates.FuncsWithQuery(q))
+	return execTemplate(q, annotations, tplData, false)
+}
+
+func execTemplate(q templates.QueryFn, annotations map[string]string, tplData AlertTplData, inlineQueryErrors bool) (map[string]string, error) {
+	tmpl, err := templates.Get()
 	if err != nil {
 		return nil, fmt.Errorf("error cloning template: %w", err)
 	}
-	return templateAnnotations(annotations, tplData, tmpl, true)
+	return templateAnnotations(annotations, tplData, tmpl, q, true, inlineQueryErrors)
 }
 
 // ValidateTemplates validate annotations for possible template error, uses empty data for template population
@@ -132,11 +144,11 @@ func ValidateTemplates(annotations map[string]string) error {
 	_, err = templateAnnotations(annotations, AlertTplData{
 		Labels: map[string]string{},
 		Value:  0,
-	}, tmpl, false)
+	}, tmpl, nil, false, false)
 	return err
 }
 
-func templateAnnotations(annotations map[string]string, data AlertTplData, tmpl *textTpl.Template, execute bool) (map[string]string, error) {
+func templateAnnotations(annotations map[string]string, data AlertTplData, tmpl *textTpl.Template, q templates.QueryFn, execute, inlineQueryErrors bool) (map[string]string, error) {
 	var builder strings.Builder
 	var buf bytes.Buffer
 	eg := new(utils.ErrGroup)
@@ -149,7 +161,11 @@ func templateAnnotations(annotations map[string]string, data AlertTplData, tmpl
 		builder.Grow(len(header) + len(text))
 		builder.WriteString(header)
 		builder.WriteString(text)
-		if err := templateAnnotation(&buf, builder.String(), tData, tmpl, execute); err != nil {
+		if err := templateAnnotation(&buf, builder.String(), tData, tmpl, q, execute); err != nil {
+			if inlineQueryErrors && templates.IsQueryError(err) {
+				r[key] = fmt.Sprintf("<error expanding template: %s>", err)
+				continue
+			}
 			r[key] = text
 			eg.Add(fmt.Errorf("key %q, template %q: %w", key, text, err))
 			continue
@@ -165,11 +181,15 @@ type tplData struct {
 	ExternalURL    string
 }
 
-func templateAnnotation(dst io.Writer, text string, data tplData, tmpl *textTpl.Template, execute bool) error {
+func templateAnnotation(dst io.Writer, text string, data tplData, tmpl *textTpl.Template, q templates.QueryFn, execute bool) error {
 	tpl, err := tmpl.Clone()
 	if err != nil {
 		return fmt.Errorf("error cloning template before parse annotation: %w", err)
 	}
+	if q != nil {
+		// query budget is applied per template, so query function must be set for every annotation
+		tpl = tpl.Funcs(templates.FuncsWithQuery(q))
+	}
 	// Clone() doesn't copy tpl Options, so we set them manually
 	tpl = tpl.Option("missingkey=zero")
 	tpl, err = tpl.Parse(text)
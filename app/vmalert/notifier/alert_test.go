@@ -3,6 +3,7 @@ package notifier
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -220,6 +221,49 @@ func TestAlert_ExecTemplate(t *testing.T) {
 	}
 }
 
+func TestAlert_ExecTemplateQueryErrors(t *testing.T) {
+	var queriesNum int
+	qFn := func(q string) ([]datasource.Metric, error) {
+		queriesNum++
+		if q == "fail" {
+			return nil, fmt.Errorf("datasource is unavailable")
+		}
+		return []datasource.Metric{{
+			Labels:     []datasource.Label{{Name: "foo", Value: "bar"}},
+			Values:     []float64{1},
+			Timestamps: []int64{1},
+		}}, nil
+	}
+	annotations := map[string]string{
+		"ok":      `{{ query "ok" | first | value }}`,
+		"failed":  `{{ query "fail" | first | value }}`,
+		"limited": `{{ query "q1" | first | value }}{{ query "q2" | first | value }}{{ query "q3" | first | value }}{{ query "q4" | first | value }}{{ query "q5" | first | value }}{{ query "q6" | first | value }}`,
+	}
+	a := &Alert{}
+	tpl, err := a.ExecTemplate(qFn, map[string]string{}, annotations)
+	if err != nil {
+		t.Fatalf("errors in query template function mustn't fail annotations templating; got %s", err)
+	}
+	if tpl["ok"] != "1" {
+		t.Fatalf("unexpected annotation; got %q; want %q", tpl["ok"], "1")
+	}
+	if !strings.HasPrefix(tpl["failed"], "<error expanding template: ") || !strings.Contains(tpl["failed"], "datasource is unavailable") {
+		t.Fatalf("unexpected annotation for failed query: %q", tpl["failed"])
+	}
+	if !strings.Contains(tpl["limited"], "exceeded the limit of 5 queries per template") {
+		t.Fatalf("unexpected annotation for template exceeding the query limit: %q", tpl["limited"])
+	}
+	// ok, failed and 5 queries from limited template
+	if queriesNum != 7 {
+		t.Fatalf("unexpected number of queries; got %d; want 7", queriesNum)
+	}
+
+	// query errors must fail labels templating
+	if _, err := ExecTemplate(qFn, map[string]string{"failed": annotations["failed"]}, AlertTplData{}); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
 func TestAlert_toPromLabels(t *testing.T) {
 	fn := func(labels map[string]string, exp []prompbmarshal.Label, relabel *promrelabel.ParsedConfigs) {
 		t.Helper()
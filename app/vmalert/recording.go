@@ -23,6 +23,8 @@ type RecordingRule struct {
 	Expr    string
 	Labels  map[string]string
 	GroupID uint64
+	// Limit overrides the group limit if set.
+	Limit *int
 
 	q datasource.Querier
 
@@ -57,6 +59,7 @@ func newRecordingRule(qb datasource.QuerierBuilder, group *Group, cfg config.Rul
 		Expr:    cfg.Expr,
 		Labels:  cfg.Labels,
 		GroupID: group.ID(),
+		Limit:   cfg.Limit,
 		metrics: &recordingRuleMetrics{},
 		q: qb.BuildWithParams(datasource.QuerierParams{
 			DataSourceType:     group.Type.String(),
@@ -139,7 +142,11 @@ func (rr *RecordingRule) Exec(ctx context.Context, ts time.Time, limit int) ([]p
 	}
 
 	numSeries := len(qMetrics)
+	if rr.Limit != nil {
+		limit = *rr.Limit
+	}
 	if limit > 0 && numSeries > limit {
+		ruleLimitExceeded.Inc()
 		curState.err = fmt.Errorf("exec exceeded limit of %d with %d series", limit, numSeries)
 		return nil, curState.err
 	}
@@ -199,6 +206,7 @@ func (rr *RecordingRule) UpdateWith(r Rule) error {
 	}
 	rr.Expr = nr.Expr
 	rr.Labels = nr.Labels
+	rr.Limit = nr.Limit
 	rr.q = nr.q
 	return nil
 }
@@ -211,6 +211,20 @@ func TestRecordingRuleLimit(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
+
+	// The rule limit must override the group limit
+	fq := &fakeQuerier{}
+	fq.add(testMetrics...)
+	rule.q = fq
+	ruleLimit := 0
+	rule.Limit = &ruleLimit
+	if _, err := rule.Exec(context.TODO(), timestamp, 1); err != nil {
+		t.Fatalf("unexpected error for the rule exempted from the group limit: %s", err)
+	}
+	ruleLimit = 2
+	if _, err := rule.Exec(context.TODO(), timestamp, 10); err == nil || err.Error() != "exec exceeded limit of 2 with 3 series" {
+		t.Fatalf("unexpected error for the rule limit; got %v", err)
+	}
 }
 
 func TestRecordingRule_ExecNegative(t *testing.T) {
@@ -14,7 +14,9 @@
 package templates
 
 import (
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	htmlTpl "html/template"
 	"io"
@@ -33,6 +35,13 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/formatutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	queryLimit = flag.Int("rule.templateQueryLimit", 5, "The maximum number of `query` function calls in a single annotation or label template. "+
+		"Exceeding calls fail with an error. Zero means no limit")
+	queryTimeout = flag.Duration("rule.templateQueryTimeout", 5*time.Second, "The maximum duration for a single `query` function call in annotation or label template")
 )
 
 // go template execution fails when it's tree is empty
@@ -150,8 +159,40 @@ func datasourceMetricsToTemplateMetrics(ms []datasource.Metric) []metric {
 
 // QueryFn is used to wrap a call to datasource into simple-to-use function
 // for templating functions.
+//
+// QueryFn must limit the query duration with WithQueryTimeout.
 type QueryFn func(query string) ([]datasource.Metric, error)
 
+// WithQueryTimeout returns a context derived from ctx, which is limited by -rule.templateQueryTimeout.
+//
+// It must be used for executing queries from `query` template function, so they are canceled
+// together with the rule evaluation.
+func WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, *queryTimeout)
+}
+
+// QueryError is returned from `query` template function when the query cannot be executed.
+type QueryError struct {
+	Query string
+	Err   error
+}
+
+// Error implements error interface.
+func (qe *QueryError) Error() string {
+	return fmt.Sprintf("cannot execute query %q: %s", qe.Query, qe.Err)
+}
+
+// Unwrap returns the underlying error.
+func (qe *QueryError) Unwrap() error {
+	return qe.Err
+}
+
+// IsQueryError returns true if err has been caused by failed `query` template function.
+func IsQueryError(err error) bool {
+	var qe *QueryError
+	return errors.As(err, &qe)
+}
+
 // UpdateWithFuncs updates existing or sets a new function map for a template
 func UpdateWithFuncs(funcs textTpl.FuncMap) {
 	tplMu.Lock()
@@ -179,18 +220,43 @@ func Get() (*textTpl.Template, error) {
 }
 
 // FuncsWithQuery returns a function map that depends on metric data
+//
+// The returned `query` function is limited by -rule.templateQueryLimit calls,
+// so the returned map must be used for executing a single template.
 func FuncsWithQuery(query QueryFn) textTpl.FuncMap {
+	queriesNum := 0
 	return textTpl.FuncMap{
 		"query": func(q string) ([]metric, error) {
+			queriesNum++
+			if *queryLimit > 0 && queriesNum > *queryLimit {
+				templateQueryErrors.Inc()
+				return nil, &QueryError{
+					Query: q,
+					Err:   fmt.Errorf("exceeded the limit of %d queries per template; see -rule.templateQueryLimit", *queryLimit),
+				}
+			}
+			templateQueries.Inc()
 			result, err := query(q)
 			if err != nil {
-				return nil, err
+				if errors.Is(err, context.DeadlineExceeded) {
+					err = fmt.Errorf("the query didn't finish in %s; see -rule.templateQueryTimeout: %w", *queryTimeout, err)
+				}
+				templateQueryErrors.Inc()
+				return nil, &QueryError{
+					Query: q,
+					Err:   err,
+				}
 			}
 			return datasourceMetricsToTemplateMetrics(result), nil
 		},
 	}
 }
 
+var (
+	templateQueries     = metrics.NewCounter(`vmalert_template_queries_total`)
+	templateQueryErrors = metrics.NewCounter(`vmalert_template_query_errors_total`)
+)
+
 // FuncsWithExternalURL returns a function map that depends on externalURL value
 func FuncsWithExternalURL(externalURL *url.URL) textTpl.FuncMap {
 	return textTpl.FuncMap{
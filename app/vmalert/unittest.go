@@ -0,0 +1,661 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metricsql"
+	"gopkg.in/yaml.v2"
+)
+
+var unitTestFiles = flagutil.NewArrayString("unitTest", "Path to the files with unit tests for alerting and recording rules in Prometheus-compatible format. "+
+	"vmalert runs the tests, prints the report for the failed tests and exits. The exit code is non-zero if at least a single test fails. "+
+	"See https://docs.victoriametrics.com/vmalert.html#unit-testing-for-rules")
+
+// unitTestFile is the contents of a file with unit tests for rules.
+//
+// The format is compatible with `promtool test rules`.
+// See https://prometheus.io/docs/prometheus/latest/configuration/unit_testing_rules/
+type unitTestFile struct {
+	RuleFiles          []string            `yaml:"rule_files"`
+	EvaluationInterval *promutils.Duration `yaml:"evaluation_interval,omitempty"`
+	GroupEvalOrder     []string            `yaml:"group_eval_order,omitempty"`
+	Tests              []testGroup         `yaml:"tests"`
+}
+
+// testGroup is a set of test cases sharing the same input series.
+type testGroup struct {
+	Name              string              `yaml:"name,omitempty"`
+	Interval          *promutils.Duration `yaml:"interval,omitempty"`
+	InputSeries       []inputSeries       `yaml:"input_series"`
+	AlertRuleTests    []alertTestCase     `yaml:"alert_rule_test,omitempty"`
+	MetricsqlExprTest []metricsqlTestCase `yaml:"promql_expr_test,omitempty"`
+	ExternalLabels    map[string]string   `yaml:"external_labels,omitempty"`
+}
+
+type inputSeries struct {
+	Series string `yaml:"series"`
+	Values string `yaml:"values"`
+}
+
+type alertTestCase struct {
+	EvalTime  *promutils.Duration `yaml:"eval_time"`
+	Alertname string              `yaml:"alertname"`
+	ExpAlerts []expAlert          `yaml:"exp_alerts"`
+}
+
+type expAlert struct {
+	ExpLabels      map[string]string `yaml:"exp_labels"`
+	ExpAnnotations map[string]string `yaml:"exp_annotations"`
+}
+
+type metricsqlTestCase struct {
+	Expr       string              `yaml:"expr"`
+	EvalTime   *promutils.Duration `yaml:"eval_time"`
+	ExpSamples []expSample         `yaml:"exp_samples"`
+}
+
+type expSample struct {
+	Labels string  `yaml:"labels"`
+	Value  float64 `yaml:"value"`
+}
+
+// unitTestStartTime is the timestamp for the first sample of input series and the zero eval_time.
+//
+// It is shifted by a day from unix epoch, since the storage reserves the zero date for global index.
+var unitTestStartTime = time.Date(1970, 1, 2, 0, 0, 0, 0, time.UTC)
+
+// unitTest runs unit tests from the given files and prints the report for the failed tests to stdout.
+//
+// It returns true if all the tests passed.
+func unitTest(files []string) bool {
+	tmpDir, err := os.MkdirTemp("", "vmalert-unittest-")
+	if err != nil {
+		logger.Fatalf("cannot create temporary dir for unit tests: %s", err)
+	}
+	defer fs.MustRemoveAll(tmpDir)
+	setUpStorage(tmpDir)
+	defer tearDownStorage()
+
+	passed := true
+	for _, f := range files {
+		fmt.Printf("Unit Testing: %s\n", f)
+		errs := runUnitTestFile(f)
+		if len(errs) == 0 {
+			fmt.Printf("  SUCCESS\n\n")
+			continue
+		}
+		passed = false
+		fmt.Printf("  FAILED:\n")
+		for _, err := range errs {
+			fmt.Printf("%s\n", indent(err.Error(), "    "))
+		}
+		fmt.Printf("\n")
+	}
+	return passed
+}
+
+func setUpStorage(dataPath string) {
+	// The input series start at unitTestStartTime, so the retention must cover it.
+	mustSetFlag("storageDataPath", dataPath)
+	mustSetFlag("retentionPeriod", "100y")
+	mustSetFlag("search.disableCache", "true")
+	vmstorage.Init(promql.ResetRollupResultCacheIfNeeded)
+	netstorage.InitTmpBlocksDir(filepath.Join(dataPath, "tmp"))
+	promql.InitRollupResultCache("")
+}
+
+func tearDownStorage() {
+	promql.StopRollupResultCache()
+	vmstorage.Stop()
+}
+
+func mustSetFlag(name, value string) {
+	if err := flag.Set(name, value); err != nil {
+		logger.Panicf("BUG: cannot set -%s=%q: %s", name, value, err)
+	}
+}
+
+// deleteAllSeries deletes all the series from the storage, so the next test group starts with an empty storage.
+func deleteAllSeries() error {
+	tfs := storage.NewTagFilters()
+	if err := tfs.Add(nil, []byte(".+"), false, true); err != nil {
+		return fmt.Errorf("cannot create tag filter: %w", err)
+	}
+	if _, err := vmstorage.DeleteSeries(nil, []*storage.TagFilters{tfs}); err != nil {
+		return fmt.Errorf("cannot delete series: %w", err)
+	}
+	return nil
+}
+
+func runUnitTestFile(path string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("cannot read file: %w", err)}
+	}
+	var utf unitTestFile
+	if err := yaml.UnmarshalStrict(data, &utf); err != nil {
+		return []error{fmt.Errorf("cannot parse file: %w", err)}
+	}
+	// Rule files are relative to the test file in the same way as in promtool.
+	ruleFiles := make([]string, len(utf.RuleFiles))
+	for i, rf := range utf.RuleFiles {
+		if !filepath.IsAbs(rf) {
+			rf = filepath.Join(filepath.Dir(path), rf)
+		}
+		ruleFiles[i] = rf
+	}
+	groupsCfg, err := config.Parse(ruleFiles, notifier.ValidateTemplates, true)
+	if err != nil {
+		return []error{fmt.Errorf("cannot parse rule files %q: %w", ruleFiles, err)}
+	}
+	groupsCfg, err = orderGroups(groupsCfg, utf.GroupEvalOrder)
+	if err != nil {
+		return []error{err}
+	}
+	evalInterval := utf.EvaluationInterval.Duration()
+	if evalInterval <= 0 {
+		evalInterval = time.Minute
+	}
+
+	var errs []error
+	for _, tg := range utf.Tests {
+		for _, err := range tg.run(groupsCfg, evalInterval) {
+			if tg.Name != "" {
+				err = fmt.Errorf("name: %s,\n%w", tg.Name, err)
+			}
+			errs = append(errs, err)
+		}
+		if err := deleteAllSeries(); err != nil {
+			return append(errs, err)
+		}
+	}
+	return errs
+}
+
+// orderGroups returns groupsCfg ordered according to groupEvalOrder.
+func orderGroups(groupsCfg []config.Group, groupEvalOrder []string) ([]config.Group, error) {
+	if len(groupEvalOrder) == 0 {
+		return groupsCfg, nil
+	}
+	m := make(map[string]config.Group, len(groupsCfg))
+	for _, g := range groupsCfg {
+		m[g.Name] = g
+	}
+	result := make([]config.Group, 0, len(groupsCfg))
+	for _, name := range groupEvalOrder {
+		g, ok := m[name]
+		if !ok {
+			return nil, fmt.Errorf("group %q from group_eval_order is missing in rule files", name)
+		}
+		result = append(result, g)
+		delete(m, name)
+	}
+	if len(m) > 0 {
+		return nil, fmt.Errorf("group_eval_order must contain all the groups from rule files; missing %d groups", len(m))
+	}
+	return result, nil
+}
+
+func (tg *testGroup) run(groupsCfg []config.Group, evalInterval time.Duration) []error {
+	interval := tg.Interval.Duration()
+	if interval <= 0 {
+		interval = evalInterval
+	}
+	var tss []prompbmarshal.TimeSeries
+	for _, is := range tg.InputSeries {
+		ts, err := is.toTimeSeries(interval)
+		if err != nil {
+			return []error{fmt.Errorf("cannot parse input series %q: %w", is.Series, err)}
+		}
+		tss = append(tss, ts)
+	}
+	if err := writeTimeSeries(tss); err != nil {
+		return []error{err}
+	}
+
+	q := &unitTestQuerier{}
+	var groups []*Group
+	for _, cfg := range groupsCfg {
+		groups = append(groups, newGroup(cfg, q, evalInterval, tg.ExternalLabels))
+	}
+	defer func() {
+		for _, g := range groups {
+			for _, r := range g.Rules {
+				r.Close()
+			}
+		}
+	}()
+
+	var errs []error
+	alertTests := append([]alertTestCase{}, tg.AlertRuleTests...)
+	sort.SliceStable(alertTests, func(i, j int) bool {
+		return alertTests[i].EvalTime.Duration() < alertTests[j].EvalTime.Duration()
+	})
+	var maxEvalTime time.Duration
+	for _, at := range alertTests {
+		if d := at.EvalTime.Duration(); d > maxEvalTime {
+			maxEvalTime = d
+		}
+	}
+	for _, mt := range tg.MetricsqlExprTest {
+		if d := mt.EvalTime.Duration(); d > maxEvalTime {
+			maxEvalTime = d
+		}
+	}
+	ctx := context.Background()
+	for offset := time.Duration(0); offset <= maxEvalTime; offset += evalInterval {
+		ts := unitTestStartTime.Add(offset)
+		for _, g := range groups {
+			var resultTSS []prompbmarshal.TimeSeries
+			for _, r := range g.Rules {
+				rtss, err := r.Exec(ctx, ts, g.Limit)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("cannot evaluate rule %q from group %q at %s: %w", r, g.Name, offset, err))
+					continue
+				}
+				resultTSS = append(resultTSS, rtss...)
+			}
+			// Write results of every group before evaluating the next group, so the next group could use them.
+			if err := writeTimeSeries(resultTSS); err != nil {
+				return append(errs, err)
+			}
+		}
+		for len(alertTests) > 0 && alertTests[0].EvalTime.Duration() < offset+evalInterval {
+			if err := alertTests[0].check(groups); err != nil {
+				errs = append(errs, err)
+			}
+			alertTests = alertTests[1:]
+		}
+	}
+	for _, mt := range tg.MetricsqlExprTest {
+		if err := mt.check(ctx, q); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (is *inputSeries) toTimeSeries(interval time.Duration) (prompbmarshal.TimeSeries, error) {
+	labels, err := parseSeriesSelector(is.Series)
+	if err != nil {
+		return prompbmarshal.TimeSeries{}, err
+	}
+	values, err := parseInputValues(is.Values)
+	if err != nil {
+		return prompbmarshal.TimeSeries{}, err
+	}
+	ts := prompbmarshal.TimeSeries{
+		Labels: labels,
+	}
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		ts.Samples = append(ts.Samples, prompbmarshal.Sample{
+			Value:     *v,
+			Timestamp: unitTestStartTime.Add(time.Duration(i) * interval).UnixMilli(),
+		})
+	}
+	return ts, nil
+}
+
+// parseSeriesSelector parses series in the form `metric_name{label="value",...}` to sorted labels.
+func parseSeriesSelector(s string) ([]prompbmarshal.Label, error) {
+	expr, err := metricsql.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	me, ok := expr.(*metricsql.MetricExpr)
+	if !ok {
+		return nil, fmt.Errorf("expecting series selector; got %q", expr.AppendString(nil))
+	}
+	var labels []prompbmarshal.Label
+	for _, lf := range me.LabelFilters {
+		if lf.IsRegexp || lf.IsNegative {
+			return nil, fmt.Errorf("series selector mustn't contain regexp or negative label filters; got %q", lf.AppendString(nil))
+		}
+		labels = append(labels, prompbmarshal.Label{
+			Name:  lf.Label,
+			Value: lf.Value,
+		})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels, nil
+}
+
+// parseInputValues parses values in promtool expanding notation.
+//
+// The following notations are supported:
+//
+//   - `a+bxn` becomes `a a+b a+(2*b) ... a+(n*b)`
+//   - `a-bxn` becomes `a a-b a-(2*b) ... a-(n*b)`
+//   - `axn` becomes `a a ... a` with n+1 items
+//   - `_` is a missing sample, while `_xn` becomes n missing samples
+//   - `stale` is a staleness marker
+//
+// nil items in the returned result correspond to missing samples.
+func parseInputValues(s string) ([]*float64, error) {
+	var result []*float64
+	for _, item := range strings.Fields(s) {
+		switch {
+		case item == "_":
+			result = append(result, nil)
+			continue
+		case item == "stale":
+			v := decimal.StaleNaN
+			result = append(result, &v)
+			continue
+		}
+		n := strings.LastIndexByte(item, 'x')
+		if n < 0 {
+			v, err := strconv.ParseFloat(item, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse value %q: %w", item, err)
+			}
+			result = append(result, &v)
+			continue
+		}
+		count, err := strconv.Atoi(item[n+1:])
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("cannot parse repetitions count in %q; it must be non-negative integer", item)
+		}
+		start := item[:n]
+		if start == "_" {
+			for i := 0; i < count; i++ {
+				result = append(result, nil)
+			}
+			continue
+		}
+		initial, delta, err := parseExpandingItem(start)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q: %w", item, err)
+		}
+		for i := 0; i <= count; i++ {
+			v := initial + float64(i)*delta
+			result = append(result, &v)
+		}
+	}
+	return result, nil
+}
+
+// parseExpandingItem parses `a`, `a+b` or `a-b` and returns a and the signed b.
+func parseExpandingItem(s string) (float64, float64, error) {
+	// Skip the leading sign and signs after exponent such as 1e-3.
+	n := -1
+	for i := 1; i < len(s); i++ {
+		if (s[i] == '+' || s[i] == '-') && s[i-1] != 'e' && s[i-1] != 'E' {
+			n = i
+			break
+		}
+	}
+	if n < 0 {
+		v, err := strconv.ParseFloat(s, 64)
+		return v, 0, err
+	}
+	initial, err := strconv.ParseFloat(s[:n], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	delta, err := strconv.ParseFloat(s[n+1:], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if s[n] == '-' {
+		delta = -delta
+	}
+	return initial, delta, nil
+}
+
+func writeTimeSeries(tss []prompbmarshal.TimeSeries) error {
+	var mrs []storage.MetricRow
+	var labels []prompb.Label
+	for _, ts := range tss {
+		labels = labels[:0]
+		for _, l := range ts.Labels {
+			labels = append(labels, prompb.Label{
+				Name:  []byte(l.Name),
+				Value: []byte(l.Value),
+			})
+		}
+		metricNameRaw := storage.MarshalMetricNameRaw(nil, labels)
+		for _, s := range ts.Samples {
+			mrs = append(mrs, storage.MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     s.Timestamp,
+				Value:         s.Value,
+			})
+		}
+	}
+	if len(mrs) == 0 {
+		return nil
+	}
+	if err := vmstorage.AddRows(mrs); err != nil {
+		return fmt.Errorf("cannot write series to the storage: %w", err)
+	}
+	vmstorage.Storage.DebugFlush()
+	return nil
+}
+
+func (at *alertTestCase) check(groups []*Group) error {
+	// The alertgroup label is specific to vmalert, so it is ignored unless it is explicitly set in exp_labels.
+	ignoreAlertGroup := true
+	for _, ea := range at.ExpAlerts {
+		if _, ok := ea.ExpLabels[alertGroupNameLabel]; ok {
+			ignoreAlertGroup = false
+		}
+	}
+	var got []string
+	for _, g := range groups {
+		for _, r := range g.Rules {
+			ar, ok := r.(*AlertingRule)
+			if !ok || ar.Name != at.Alertname {
+				continue
+			}
+			ar.alertsMu.RLock()
+			for _, a := range ar.alerts {
+				if a.State != notifier.StateFiring {
+					continue
+				}
+				labels := make(map[string]string, len(a.Labels))
+				for k, v := range a.Labels {
+					if k == alertGroupNameLabel && ignoreAlertGroup {
+						continue
+					}
+					labels[k] = v
+				}
+				got = append(got, alertToString(labels, a.Annotations))
+			}
+			ar.alertsMu.RUnlock()
+		}
+	}
+	var exp []string
+	for _, ea := range at.ExpAlerts {
+		labels := make(map[string]string, len(ea.ExpLabels)+1)
+		for k, v := range ea.ExpLabels {
+			labels[k] = v
+		}
+		labels[alertNameLabel] = at.Alertname
+		exp = append(exp, alertToString(labels, ea.ExpAnnotations))
+	}
+	if diff := diffStrings(exp, got); diff != "" {
+		return fmt.Errorf("alertname: %s, time: %s,\n%s", at.Alertname, at.EvalTime.Duration(), diff)
+	}
+	return nil
+}
+
+func alertToString(labels, annotations map[string]string) string {
+	return fmt.Sprintf("labels: %s, annotations: %s", mapToString(labels), mapToString(annotations))
+}
+
+func mapToString(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	a := make([]string, len(keys))
+	for i, k := range keys {
+		a[i] = fmt.Sprintf("%s=%q", k, m[k])
+	}
+	return "{" + strings.Join(a, ", ") + "}"
+}
+
+func (mt *metricsqlTestCase) check(ctx context.Context, q datasource.Querier) error {
+	ts := unitTestStartTime.Add(mt.EvalTime.Duration())
+	ms, _, err := q.Query(ctx, mt.Expr, ts)
+	if err != nil {
+		return fmt.Errorf("expr: %q, time: %s, err: %w", mt.Expr, mt.EvalTime.Duration(), err)
+	}
+	var got []string
+	for _, m := range ms {
+		labels := make(map[string]string, len(m.Labels))
+		for _, l := range m.Labels {
+			labels[l.Name] = l.Value
+		}
+		got = append(got, sampleToString(labels, m.Values[0]))
+	}
+	var exp []string
+	for _, es := range mt.ExpSamples {
+		var labels map[string]string
+		if es.Labels != "" {
+			ls, err := parseSeriesSelector(es.Labels)
+			if err != nil {
+				return fmt.Errorf("expr: %q, time: %s, cannot parse labels %q: %w", mt.Expr, mt.EvalTime.Duration(), es.Labels, err)
+			}
+			labels = make(map[string]string, len(ls))
+			for _, l := range ls {
+				labels[l.Name] = l.Value
+			}
+		}
+		exp = append(exp, sampleToString(labels, es.Value))
+	}
+	if diff := diffStrings(exp, got); diff != "" {
+		return fmt.Errorf("expr: %q, time: %s,\n%s", mt.Expr, mt.EvalTime.Duration(), diff)
+	}
+	return nil
+}
+
+func sampleToString(labels map[string]string, value float64) string {
+	// Round the value in order to avoid false mismatches because of floating-point calculation errors.
+	if !math.IsNaN(value) && !math.IsInf(value, 0) {
+		value, _ = strconv.ParseFloat(strconv.FormatFloat(value, 'g', 12, 64), 64)
+	}
+	return fmt.Sprintf("%s %g", mapToString(labels), value)
+}
+
+// diffStrings returns diff between sorted exp and got in the form of `- missing` and `+ unexpected` lines.
+//
+// An empty string is returned if exp and got contain the same items.
+func diffStrings(exp, got []string) string {
+	sort.Strings(exp)
+	sort.Strings(got)
+	var lines []string
+	i, j := 0, 0
+	for i < len(exp) || j < len(got) {
+		switch {
+		case j >= len(got) || (i < len(exp) && exp[i] < got[j]):
+			lines = append(lines, "- "+exp[i])
+			i++
+		case i >= len(exp) || got[j] < exp[i]:
+			lines = append(lines, "+ "+got[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "  (- expected, + got)\n  " + strings.Join(lines, "\n  ")
+}
+
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+// unitTestQuerier executes queries via the embedded MetricsQL engine over the local storage with input series.
+type unitTestQuerier struct{}
+
+// BuildWithParams implements datasource.QuerierBuilder interface.
+func (q *unitTestQuerier) BuildWithParams(_ datasource.QuerierParams) datasource.Querier {
+	return q
+}
+
+// Query implements datasource.Querier interface.
+func (q *unitTestQuerier) Query(_ context.Context, query string, ts time.Time) ([]datasource.Metric, *http.Request, error) {
+	start := ts.UnixMilli()
+	ms, err := q.exec(query, start, start, true)
+	return ms, nil, err
+}
+
+// QueryRange implements datasource.Querier interface.
+func (q *unitTestQuerier) QueryRange(_ context.Context, query string, from, to time.Time) ([]datasource.Metric, error) {
+	return q.exec(query, from.UnixMilli(), to.UnixMilli(), false)
+}
+
+func (q *unitTestQuerier) exec(query string, start, end int64, isInstant bool) ([]datasource.Metric, error) {
+	const step = 5 * 60 * 1000
+	ec := &promql.EvalConfig{
+		Start:              start,
+		End:                end,
+		Step:               step,
+		MaxPointsPerSeries: 30e3,
+		MaxSeries:          1e6,
+		Deadline:           searchutils.NewDeadline(time.Now(), time.Minute, ""),
+		RoundDigits:        100,
+	}
+	result, err := promql.Exec(nil, ec, query, isInstant)
+	if err != nil {
+		return nil, err
+	}
+	ms := make([]datasource.Metric, 0, len(result))
+	for _, r := range result {
+		var m datasource.Metric
+		if len(r.MetricName.MetricGroup) > 0 {
+			m.AddLabel("__name__", string(r.MetricName.MetricGroup))
+		}
+		for _, tag := range r.MetricName.Tags {
+			m.AddLabel(string(tag.Key), string(tag.Value))
+		}
+		for i, v := range r.Values {
+			if decimal.IsStaleNaN(v) || math.IsNaN(v) {
+				continue
+			}
+			m.Values = append(m.Values, v)
+			m.Timestamps = append(m.Timestamps, r.Timestamps[i]/1e3)
+		}
+		if len(m.Values) == 0 {
+			continue
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
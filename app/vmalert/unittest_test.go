@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+func TestParseInputValues(t *testing.T) {
+	f := func(s string, resultExpected []float64) {
+		t.Helper()
+		result, err := parseInputValues(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var values []float64
+		for _, v := range result {
+			if v == nil {
+				// Use -inf for missing samples in order to simplify the comparison.
+				values = append(values, math.Inf(-1))
+				continue
+			}
+			values = append(values, *v)
+		}
+		if len(values) != len(resultExpected) {
+			t.Fatalf("unexpected number of values; got %v; want %v", values, resultExpected)
+		}
+		for i, v := range values {
+			vExpected := resultExpected[i]
+			if decimal.IsStaleNaN(vExpected) {
+				if !decimal.IsStaleNaN(v) {
+					t.Fatalf("unexpected value #%d; got %v; want staleness marker", i, v)
+				}
+				continue
+			}
+			if v != vExpected {
+				t.Fatalf("unexpected value #%d; got %v; want %v", i, v, vExpected)
+			}
+		}
+	}
+	missing := math.Inf(-1)
+	f("", nil)
+	f("1 2 3", []float64{1, 2, 3})
+	f("1+1x3", []float64{1, 2, 3, 4})
+	f("-2+4x3", []float64{-2, 2, 6, 10})
+	f("1-1x3", []float64{1, 0, -1, -2})
+	f("1x4", []float64{1, 1, 1, 1, 1})
+	f("1e3+1e-3x1", []float64{1000, 1000.001})
+	f("_ 1 _x3 stale", []float64{missing, 1, missing, missing, missing, decimal.StaleNaN})
+}
+
+func TestParseInputValuesFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		if _, err := parseInputValues(s); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+	f("foo")
+	f("1+1xfoo")
+	f("1+1x-1")
+	f("1+ax3")
+	f("_xa")
+}
+
+func TestParseSeriesSelector(t *testing.T) {
+	labels, err := parseSeriesSelector(`up{job="prometheus",instance="localhost:9090"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	labelsExpected := []prompbmarshal.Label{
+		{Name: "__name__", Value: "up"},
+		{Name: "instance", Value: "localhost:9090"},
+		{Name: "job", Value: "prometheus"},
+	}
+	if !reflect.DeepEqual(labels, labelsExpected) {
+		t.Fatalf("unexpected labels; got %v; want %v", labels, labelsExpected)
+	}
+
+	for _, s := range []string{`up{job=~"prom.+"}`, `sum(up)`, `up{`} {
+		if _, err := parseSeriesSelector(s); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+}
+
+func TestDiffStrings(t *testing.T) {
+	f := func(exp, got []string, resultExpected string) {
+		t.Helper()
+		result := diffStrings(exp, got)
+		if result != resultExpected {
+			t.Fatalf("unexpected diff;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+	f(nil, nil, "")
+	f([]string{"b", "a"}, []string{"a", "b"}, "")
+	f([]string{"a", "c"}, []string{"b", "c"}, "  (- expected, + got)\n  - a\n  + b")
+}
+
+func TestUnitTest(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "vmalert-unittest-")
+	if err != nil {
+		t.Fatalf("cannot create temporary dir: %s", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	setUpStorage(tmpDir)
+	defer tearDownStorage()
+
+	if errs := runUnitTestFile("testdata/unittest/test-good.yaml"); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	errs := runUnitTestFile("testdata/unittest/test-bad.yaml")
+	if len(errs) != 2 {
+		t.Fatalf("unexpected number of errors; got %d; want 2; errors: %v", len(errs), errs)
+	}
+	if s := errs[0].Error(); !strings.Contains(s, `- labels: {alertname="InstanceDown", instance="localhost:9090", job="prometheus", severity="critical"}`) ||
+		!strings.Contains(s, `+ labels: {alertname="InstanceDown", instance="localhost:9090", job="prometheus", severity="page"}`) {
+		t.Fatalf("unexpected error for alert test: %s", s)
+	}
+	if s := errs[1].Error(); !strings.Contains(s, `- {__name__="up", instance="localhost:9090", job="prometheus"} 1`) ||
+		!strings.Contains(s, `+ {__name__="up", instance="localhost:9090", job="prometheus"} 0`) {
+		t.Fatalf("unexpected error for expr test: %s", s)
+	}
+
+	if errs := runUnitTestFile("testdata/unittest/missing.yaml"); len(errs) != 1 {
+		t.Fatalf("expecting a single error for missing file; got %v", errs)
+	}
+}
@@ -23,6 +23,7 @@ var (
 		{"api/v1/rules", "list all loaded groups and rules"},
 		{"api/v1/alerts", "list all active alerts"},
 		{fmt.Sprintf("api/v1/alert?%s=<int>&%s=<int>", paramGroupID, paramAlertID), "get alert status by group and alert ID"},
+		{"api/v1/status", "show the currently active datasource per group"},
 	}
 	systemLinks = [][2]string{
 		{"/flags", "command-line flags"},
@@ -38,6 +39,19 @@ var (
 	}
 )
 
+// detailedMetricsPathPrefixes contains path prefixes for vm_http_* histograms exposed when -http.detailedMetrics is set.
+//
+// Add new endpoints served by requestHandler here.
+var detailedMetricsPathPrefixes = []string{
+	"/vmalert/api/v1/rules", "/api/v1/rules",
+	"/vmalert/api/v1/alerts", "/api/v1/alerts",
+	"/vmalert/api/v1/alert", "/api/v1/alert",
+	"/vmalert/api/v1/status",
+	"/vmalert/", "/rules",
+	"/api/v1/",
+	"/-/reload",
+}
+
 type requestHandler struct {
 	m *manager
 }
@@ -131,6 +145,15 @@ func (rh *requestHandler) handler(w http.ResponseWriter, r *http.Request) bool {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write(data)
 		return true
+	case "/vmalert/api/v1/status":
+		data, err := rh.status()
+		if err != nil {
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+		return true
 	case "/-/reload":
 		logger.Infof("api config reload was called, sending sighup")
 		procutil.SelfSIGHUP()
@@ -237,6 +260,33 @@ func (rh *requestHandler) listGroups() ([]byte, error) {
 	return b, nil
 }
 
+type statusResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Groups []APIGroupStatus `json:"groups"`
+	} `json:"data"`
+}
+
+func (rh *requestHandler) status() ([]byte, error) {
+	sr := statusResponse{Status: "success"}
+	for _, g := range rh.groups() {
+		sr.Data.Groups = append(sr.Data.Groups, APIGroupStatus{
+			Name:       g.Name,
+			ID:         g.ID,
+			File:       g.File,
+			Datasource: g.Datasource,
+		})
+	}
+	b, err := json.Marshal(sr)
+	if err != nil {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf(`error encoding status: %w`, err),
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	return b, nil
+}
+
 type listAlertsResponse struct {
 	Status string `json:"status"`
 	Data   struct {
@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
 )
 
@@ -28,6 +29,7 @@ func TestHandler(t *testing.T) {
 	rr := &RecordingRule{
 		Name:  "record",
 		state: newRuleState(10),
+		q:     datasource.NewVMStorage("http://localhost:8428", nil, 0, 0, false, http.DefaultClient),
 	}
 	g := &Group{
 		Name:  "group",
@@ -146,6 +148,17 @@ func TestHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("/vmalert/api/v1/status", func(t *testing.T) {
+		sr := statusResponse{}
+		getResp(ts.URL+"/vmalert/api/v1/status", &sr, 200)
+		if length := len(sr.Data.Groups); length != 1 {
+			t.Fatalf("expected 1 group got %d", length)
+		}
+		if gs := sr.Data.Groups[0]; gs.Name != "group" || gs.Datasource != "1:secret-url" {
+			t.Errorf("unexpected group status %+v", gs)
+		}
+	})
+
 	// check deprecated links support
 	// TODO: remove as soon as deprecated links removed
 	t.Run("/api/v1/0/0/status", func(t *testing.T) {
@@ -16,6 +16,10 @@ type APIAlert struct {
 	Annotations map[string]string `json:"annotations"`
 	ActiveAt    time.Time         `json:"activeAt"`
 
+	// KeepFiringSince is the time when the alert expression stopped returning results,
+	// while the alert is kept firing because of rule's `keep_firing_for` field.
+	KeepFiringSince *time.Time `json:"keepFiringSince,omitempty"`
+
 	// Additional fields
 
 	// ID is an unique Alert's ID within a group
@@ -68,12 +72,28 @@ type APIGroup struct {
 	File string `json:"file"`
 	// Concurrency shows how many rules may be evaluated simultaneously
 	Concurrency int `json:"concurrency"`
+	// Limit is the max number of series or alerts a single rule of the Group may produce during evaluation
+	Limit int `json:"limit,omitempty"`
 	// Params contains HTTP URL parameters added to each Rule's request
 	Params []string `json:"params,omitempty"`
 	// Headers contains HTTP headers added to each Rule's request
 	Headers []string `json:"headers,omitempty"`
 	// Labels is a set of label value pairs, that will be added to every rule.
 	Labels map[string]string `json:"labels,omitempty"`
+	// Datasource shows the datasource, which served the most recent request for the Group
+	Datasource string `json:"datasource,omitempty"`
+}
+
+// APIGroupStatus represents the status of a Group for WEB view
+type APIGroupStatus struct {
+	// Name is the group name as present in the config
+	Name string `json:"name"`
+	// ID is a unique Group ID
+	ID string `json:"id"`
+	// File contains a path to the file with Group's config
+	File string `json:"file"`
+	// Datasource shows the currently active datasource for the Group
+	Datasource string `json:"datasource"`
 }
 
 // GroupAlerts represents a group of alerts for WEB view
@@ -110,6 +130,8 @@ type APIRule struct {
 	Health string `json:"health"`
 	// Type of the rule: recording or alerting
 	Type string `json:"type"`
+	// KeepFiringFor represents Rule's `keep_firing_for` field
+	KeepFiringFor float64 `json:"keepFiringFor"`
 
 	// Additional fields
 
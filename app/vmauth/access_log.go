@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	accessLogEnabled = flag.Bool("accessLog.enabled", false, "Whether to write access log with a JSON line per each request. "+
+		"See https://docs.victoriametrics.com/vmauth.html#access-log")
+	accessLogPath = flag.String("accessLog.path", "", "Path to file for writing access log if -accessLog.enabled is set. "+
+		"Access log is written to stdout if empty. See also -accessLog.maxFileSize")
+	accessLogMaxFileSize = flagutil.NewBytes("accessLog.maxFileSize", 100*1024*1024, "The maximum size of -accessLog.path file. "+
+		"The file is rotated to <-accessLog.path>.1 when it reaches this size")
+	accessLogFields = flagutil.NewArrayString("accessLog.fields", "Fields to write to access log if -accessLog.enabled is set. "+
+		"All the fields are written by default. Supported fields: "+strings.Join(accessLogAllFields, ", "))
+	accessLogSampleRate = flag.Int("accessLog.sampleRate", 1, "Write only 1 of N successful requests to access log if -accessLog.enabled is set. "+
+		"Requests with 4xx and 5xx response status codes are always written")
+	accessLogRedactQueryArgs = flagutil.NewArrayString("accessLog.redactQueryArgs", "Query arg names, which values must be redacted in access log. "+
+		"Shell-style patterns such as '*token*' are supported. The match is case-insensitive. "+
+		"By default the following query args are redacted: "+strings.Join(accessLogDefaultRedactQueryArgs, ", "))
+)
+
+var accessLogAllFields = []string{
+	"timestamp",
+	"user",
+	"remote_addr",
+	"method",
+	"path",
+	"url_map",
+	"backend",
+	"status",
+	"duration",
+	"request_bytes",
+	"response_bytes",
+}
+
+var accessLogDefaultRedactQueryArgs = []string{"*token*", "*password*", "*secret*", "authkey"}
+
+// redactedValue is written to access log instead of secrets.
+const redactedValue = "<redacted>"
+
+var (
+	accessLogFieldsParsed      []string
+	accessLogRedactPatterns    []string
+	accessLogSuccessfulCounter uint64
+)
+
+// initAccessLog must be called after flag.Parse and before serving requests.
+func initAccessLog() {
+	if !*accessLogEnabled {
+		return
+	}
+	fields, err := parseAccessLogFields(*accessLogFields)
+	if err != nil {
+		logger.Fatalf("cannot parse -accessLog.fields: %s", err)
+	}
+	accessLogFieldsParsed = fields
+	accessLogRedactPatterns = getAccessLogRedactPatterns(*accessLogRedactQueryArgs)
+	if *accessLogPath != "" {
+		alw, err := openAccessLogFile(*accessLogPath, accessLogMaxFileSize.N)
+		if err != nil {
+			logger.Fatalf("cannot open -accessLog.path: %s", err)
+		}
+		accessLogOutput = alw
+	}
+}
+
+// stopAccessLog must be called before exiting.
+func stopAccessLog() {
+	if alw, ok := accessLogOutput.(*accessLogFile); ok {
+		alw.mustClose()
+	}
+}
+
+func parseAccessLogFields(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return accessLogAllFields, nil
+	}
+	for _, field := range fields {
+		if !isSupportedAccessLogField(field) {
+			return nil, fmt.Errorf("unsupported field %q; supported fields: %s", field, strings.Join(accessLogAllFields, ", "))
+		}
+	}
+	return fields, nil
+}
+
+func isSupportedAccessLogField(field string) bool {
+	for _, f := range accessLogAllFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func getAccessLogRedactPatterns(args []string) []string {
+	if len(args) == 0 {
+		args = accessLogDefaultRedactQueryArgs
+	}
+	patterns := make([]string, 0, len(args))
+	for _, arg := range args {
+		patterns = append(patterns, strings.ToLower(arg))
+	}
+	return patterns
+}
+
+// accessLogEntry collects information about the request for writing it to access log.
+//
+// All the methods are safe to call on nil accessLogEntry, e.g. when access log is disabled.
+type accessLogEntry struct {
+	w         http.ResponseWriter
+	body      *countingReader
+	startTime time.Time
+
+	user       string
+	urlMap     string
+	backend    string
+	remoteAddr string
+	method     string
+	requestURI string
+
+	statusCode    int
+	responseBytes int64
+}
+
+// newAccessLogEntry returns an entry for the access log for the given w and r.
+//
+// The returned entry must be used instead of w for writing the response.
+// The entry must be written with write() after the request is processed.
+func newAccessLogEntry(w http.ResponseWriter, r *http.Request) *accessLogEntry {
+	body := &countingReader{
+		r: r.Body,
+	}
+	if r.Body != nil {
+		r.Body = body
+	}
+	return &accessLogEntry{
+		w:          w,
+		body:       body,
+		startTime:  time.Now(),
+		remoteAddr: r.RemoteAddr,
+		method:     r.Method,
+		requestURI: redactRequestURI(r.URL, accessLogRedactPatterns),
+	}
+}
+
+func (ale *accessLogEntry) setUser(user string) {
+	if ale != nil {
+		ale.user = user
+	}
+}
+
+func (ale *accessLogEntry) setURLMap(srcPath string) {
+	if ale != nil {
+		ale.urlMap = srcPath
+	}
+}
+
+func (ale *accessLogEntry) setBackend(u *url.URL) {
+	if ale != nil {
+		ale.backend = u.Redacted()
+	}
+}
+
+// Header implements http.ResponseWriter interface.
+func (ale *accessLogEntry) Header() http.Header {
+	return ale.w.Header()
+}
+
+// WriteHeader implements http.ResponseWriter interface.
+func (ale *accessLogEntry) WriteHeader(statusCode int) {
+	if ale.statusCode == 0 {
+		ale.statusCode = statusCode
+	}
+	ale.w.WriteHeader(statusCode)
+}
+
+// Write implements http.ResponseWriter interface.
+func (ale *accessLogEntry) Write(p []byte) (int, error) {
+	if ale.statusCode == 0 {
+		ale.statusCode = http.StatusOK
+	}
+	n, err := ale.w.Write(p)
+	ale.responseBytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher interface.
+func (ale *accessLogEntry) Flush() {
+	if f, ok := ale.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker interface.
+//
+// It is used for proxying connections upgraded to another protocol such as WebSocket.
+func (ale *accessLogEntry) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := ale.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T doesn't support hijacking", ale.w)
+	}
+	c, brw, err := hj.Hijack()
+	if err == nil && ale.statusCode == 0 {
+		ale.statusCode = http.StatusSwitchingProtocols
+	}
+	return c, brw, err
+}
+
+// write writes ale to access log if needed.
+func (ale *accessLogEntry) write() {
+	if ale == nil {
+		return
+	}
+	if ale.statusCode == 0 {
+		ale.statusCode = http.StatusOK
+	}
+	if !shouldWriteAccessLogEntry(ale.statusCode, *accessLogSampleRate, &accessLogSuccessfulCounter) {
+		accessLogSkippedEntries.Inc()
+		return
+	}
+	line := ale.marshal(nil, accessLogFieldsParsed, time.Now())
+	if _, err := accessLogOutput.Write(line); err != nil {
+		accessLogWriteErrors.Inc()
+		logger.WithThrottler("accessLogWrite", 5*time.Second).Errorf("cannot write access log: %s", err)
+		return
+	}
+	accessLogEntries.Inc()
+}
+
+// shouldWriteAccessLogEntry returns true if the entry with the given statusCode must be written to access log.
+//
+// Requests with 4xx and 5xx status codes are always written, while only 1 of sampleRate successful requests is written.
+func shouldWriteAccessLogEntry(statusCode, sampleRate int, successfulCounter *uint64) bool {
+	if statusCode >= 400 || sampleRate <= 1 {
+		return true
+	}
+	n := atomic.AddUint64(successfulCounter, 1)
+	return n%uint64(sampleRate) == 1
+}
+
+// marshal appends JSON line with the given fields from ale to dst and returns the result.
+func (ale *accessLogEntry) marshal(dst []byte, fields []string, currentTime time.Time) []byte {
+	dst = append(dst, '{')
+	for i, field := range fields {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = strconv.AppendQuote(dst, field)
+		dst = append(dst, ':')
+		switch field {
+		case "timestamp":
+			dst = appendJSONString(dst, ale.startTime.UTC().Format(time.RFC3339Nano))
+		case "user":
+			dst = appendJSONString(dst, ale.user)
+		case "remote_addr":
+			dst = appendJSONString(dst, ale.remoteAddr)
+		case "method":
+			dst = appendJSONString(dst, ale.method)
+		case "path":
+			dst = appendJSONString(dst, ale.requestURI)
+		case "url_map":
+			dst = appendJSONString(dst, ale.urlMap)
+		case "backend":
+			dst = appendJSONString(dst, ale.backend)
+		case "status":
+			dst = strconv.AppendInt(dst, int64(ale.statusCode), 10)
+		case "duration":
+			dst = strconv.AppendFloat(dst, currentTime.Sub(ale.startTime).Seconds(), 'f', 3, 64)
+		case "request_bytes":
+			dst = strconv.AppendInt(dst, ale.body.n, 10)
+		case "response_bytes":
+			dst = strconv.AppendInt(dst, ale.responseBytes, 10)
+		default:
+			logger.Panicf("BUG: unexpected access log field %q", field)
+		}
+	}
+	dst = append(dst, '}', '\n')
+	return dst
+}
+
+func appendJSONString(dst []byte, s string) []byte {
+	data, err := json.Marshal(s)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal string %q to JSON: %s", s, err)
+	}
+	return append(dst, data...)
+}
+
+// redactRequestURI returns request uri for u with redacted values for query args matching the given patterns.
+func redactRequestURI(u *url.URL, patterns []string) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	args := u.Query()
+	for name, values := range args {
+		if !matchesAnyPattern(strings.ToLower(name), patterns) {
+			continue
+		}
+		for i := range values {
+			values[i] = redactedValue
+		}
+	}
+	return u.Path + "?" + args.Encode()
+}
+
+func matchesAnyPattern(s string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type countingReader struct {
+	r io.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *countingReader) Close() error {
+	return cr.r.Close()
+}
+
+// accessLogOutput is the destination for access log.
+var accessLogOutput io.Writer = &syncWriter{w: os.Stdout}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	n, err := sw.w.Write(p)
+	sw.mu.Unlock()
+	return n, err
+}
+
+// accessLogFile writes access log to a file with size-based rotation.
+type accessLogFile struct {
+	path    string
+	maxSize int64
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func openAccessLogFile(path string, maxSize int64) (*accessLogFile, error) {
+	alf := &accessLogFile{
+		path:    path,
+		maxSize: maxSize,
+	}
+	if err := alf.open(); err != nil {
+		return nil, err
+	}
+	return alf, nil
+}
+
+func (alf *accessLogFile) open() error {
+	f, err := os.OpenFile(alf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open access log file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("cannot stat access log file: %w", err)
+	}
+	alf.f = f
+	alf.size = fi.Size()
+	return nil
+}
+
+// Write writes p to alf and rotates the file if its size exceeds alf.maxSize.
+func (alf *accessLogFile) Write(p []byte) (int, error) {
+	alf.mu.Lock()
+	defer alf.mu.Unlock()
+
+	if alf.maxSize > 0 && alf.size > 0 && alf.size+int64(len(p)) > alf.maxSize {
+		if err := alf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := alf.f.Write(p)
+	alf.size += int64(n)
+	return n, err
+}
+
+func (alf *accessLogFile) rotate() error {
+	if err := alf.f.Close(); err != nil {
+		return fmt.Errorf("cannot close access log file %q: %w", alf.path, err)
+	}
+	if err := os.Rename(alf.path, alf.path+".1"); err != nil {
+		logger.Errorf("cannot rotate access log file %q: %s", alf.path, err)
+	}
+	return alf.open()
+}
+
+func (alf *accessLogFile) mustClose() {
+	alf.mu.Lock()
+	defer alf.mu.Unlock()
+	if err := alf.f.Close(); err != nil {
+		logger.Errorf("cannot close access log file %q: %s", alf.path, err)
+	}
+}
+
+var (
+	accessLogEntries        = metrics.NewCounter(`vmauth_access_log_entries_total`)
+	accessLogSkippedEntries = metrics.NewCounter(`vmauth_access_log_skipped_entries_total`)
+	accessLogWriteErrors    = metrics.NewCounter(`vmauth_access_log_write_errors_total`)
+)
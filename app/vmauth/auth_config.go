@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"flag"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"regexp"
@@ -18,6 +19,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
 	"github.com/VictoriaMetrics/metrics"
 	"gopkg.in/yaml.v2"
 )
@@ -45,10 +47,17 @@ type UserInfo struct {
 	Headers               []Header   `yaml:"headers,omitempty"`
 	MaxConcurrentRequests int        `yaml:"max_concurrent_requests,omitempty"`
 
+	// MaxConnectionDuration is the maximum duration for connections upgraded to another protocol such as WebSocket.
+	MaxConnectionDuration *promutils.Duration `yaml:"max_connection_duration,omitempty"`
+
+	// IdleTimeout is the maximum duration without data transfer for connections upgraded to another protocol such as WebSocket.
+	IdleTimeout *promutils.Duration `yaml:"idle_timeout,omitempty"`
+
 	concurrencyLimitCh      chan struct{}
 	concurrencyLimitReached *metrics.Counter
 
-	requests *metrics.Counter
+	requests      *metrics.Counter
+	upgradedConns *upgradedConnMetrics
 }
 
 func (ui *UserInfo) beginConcurrencyLimit() error {
@@ -115,14 +124,46 @@ type SrcPath struct {
 
 // URLPrefix represents passed `url_prefix`
 type URLPrefix struct {
-	n   uint32
+	n uint32
+
+	// bus contains backend urls as they are specified in `url_prefix`.
 	bus []*backendURL
+
+	// needDiscovery is set to true if bus contains at least a single url with `srv+` scheme.
+	needDiscovery bool
+
+	// discoveredBus contains *[]*backendURL obtained from bus during the last successful backends discovery.
+	// It is used instead of bus if needDiscovery is set.
+	discoveredBus atomic.Value
 }
 
 type backendURL struct {
 	brokenDeadline     uint64
 	concurrentRequests int32
 	url                *url.URL
+
+	// origIdx is the index of the `url_prefix` entry the url was discovered from.
+	origIdx int
+
+	// tr is the transport for the discovered backend.
+	//
+	// It allows closing idle connections to the backend when it is removed from DNS.
+	// The shared transport is used for static backends.
+	tr *http.Transport
+}
+
+func (bu *backendURL) getTransport() *http.Transport {
+	if bu.tr != nil {
+		return bu.tr
+	}
+	transportOnce.Do(transportInit)
+	return transport
+}
+
+func (bu *backendURL) closeIdleConnections() {
+	if bu.tr != nil {
+		bu.tr.CloseIdleConnections()
+	}
 }
 
 func (bu *backendURL) isBroken() bool {
@@ -140,14 +181,25 @@ func (bu *backendURL) put() {
 }
 
 func (up *URLPrefix) getBackendsCount() int {
-	return len(up.bus)
+	return len(up.getBackends())
+}
+
+func (up *URLPrefix) getBackends() []*backendURL {
+	if !up.needDiscovery {
+		return up.bus
+	}
+	v := up.discoveredBus.Load()
+	if v == nil {
+		return nil
+	}
+	return *(v.(*[]*backendURL))
 }
 
 // getLeastLoadedBackendURL returns the backendURL with the minimum number of concurrent requests.
 //
 // backendURL.put() must be called on the returned backendURL after the request is complete.
 func (up *URLPrefix) getLeastLoadedBackendURL() *backendURL {
-	bus := up.bus
+	bus := up.getBackends()
 	if len(bus) == 1 {
 		// Fast path - return the only backend url.
 		bu := bus[0]
@@ -219,7 +271,8 @@ func (up *URLPrefix) UnmarshalYAML(f func(interface{}) error) error {
 			return fmt.Errorf("cannot unmarshal %q into url: %w", u, err)
 		}
 		bus[i] = &backendURL{
-			url: pu,
+			url:     pu,
+			origIdx: i,
 		}
 	}
 	up.bus = bus
@@ -293,6 +346,7 @@ func initAuthConfig() {
 	if err != nil {
 		logger.Fatalf("cannot load auth config from `-auth.config=%s`: %s", *authConfigPath, err)
 	}
+	startBackendsDiscovery(m)
 	authConfig.Store(m)
 	stopCh = make(chan struct{})
 	authConfigWG.Add(1)
@@ -305,6 +359,7 @@ func initAuthConfig() {
 func stopAuthConfig() {
 	close(stopCh)
 	authConfigWG.Wait()
+	stopBackendsDiscovery()
 }
 
 func authConfigReloader(sighupCh <-chan os.Signal) {
@@ -329,6 +384,7 @@ func authConfigReloader(sighupCh <-chan os.Signal) {
 				logger.Errorf("failed to load -auth.config=%q; using the last successfully loaded config; error: %s", *authConfigPath, err)
 				continue
 			}
+			startBackendsDiscovery(m)
 			authConfig.Store(m)
 			logger.Infof("Successfully reloaded -auth.config=%q", *authConfigPath)
 		}
@@ -401,6 +457,12 @@ func parseAuthConfig(data []byte) (map[string]*UserInfo, error) {
 		if len(ui.URLMaps) == 0 && ui.URLPrefix == nil {
 			return nil, fmt.Errorf("missing `url_prefix`")
 		}
+		if d := ui.MaxConnectionDuration.Duration(); d < 0 {
+			return nil, fmt.Errorf("`max_connection_duration` cannot be negative; got %s", d)
+		}
+		if d := ui.IdleTimeout.Duration(); d < 0 {
+			return nil, fmt.Errorf("`idle_timeout` cannot be negative; got %s", d)
+		}
 		name := ui.name()
 		if ui.BearerToken != "" {
 			if ui.Password != "" {
@@ -411,6 +473,7 @@ func parseAuthConfig(data []byte) (map[string]*UserInfo, error) {
 		if ui.Username != "" {
 			ui.requests = metrics.GetOrCreateCounter(fmt.Sprintf(`vmauth_user_requests_total{username=%q}`, name))
 		}
+		ui.upgradedConns = newUpgradedConnMetrics(name)
 		mcr := ui.getMaxConcurrentRequests()
 		ui.concurrencyLimitCh = make(chan struct{}, mcr)
 		ui.concurrencyLimitReached = metrics.GetOrCreateCounter(fmt.Sprintf(`vmauth_user_concurrent_requests_limit_reached_total{username=%q}`, name))
@@ -466,6 +529,9 @@ func (up *URLPrefix) sanitize() error {
 			return err
 		}
 		bu.url = puNew
+		if isDiscoveryScheme(puNew.Scheme) {
+			up.needDiscovery = true
+		}
 	}
 	return nil
 }
@@ -476,8 +542,9 @@ func sanitizeURLPrefix(urlPrefix *url.URL) (*url.URL, error) {
 		urlPrefix.Path = urlPrefix.Path[:len(urlPrefix.Path)-1]
 	}
 	// Validate urlPrefix
-	if urlPrefix.Scheme != "http" && urlPrefix.Scheme != "https" {
-		return nil, fmt.Errorf("unsupported scheme for `url_prefix: %q`: %q; must be `http` or `https`", urlPrefix, urlPrefix.Scheme)
+	scheme := strings.TrimPrefix(urlPrefix.Scheme, "srv+")
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme for `url_prefix: %q`: %q; must be `http`, `https`, `srv+http` or `srv+https`", urlPrefix, urlPrefix.Scheme)
 	}
 	if urlPrefix.Host == "" {
 		return nil, fmt.Errorf("missing hostname in `url_prefix %q`", urlPrefix.Host)
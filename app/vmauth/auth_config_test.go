@@ -6,7 +6,9 @@ import (
 	"net/url"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
 	"gopkg.in/yaml.v2"
 )
 
@@ -41,6 +43,20 @@ users:
 - username: foo
   url_prefix: bar
 `)
+
+	// Negative limits for upgraded connections
+	f(`
+users:
+- username: foo
+  url_prefix: http://foo.bar
+  max_connection_duration: -1h
+`)
+	f(`
+users:
+- username: foo
+  url_prefix: http://foo.bar
+  idle_timeout: -1m
+`)
 	f(`
 users:
 - username: foo
@@ -48,6 +64,11 @@ users:
 `)
 	f(`
 users:
+- username: foo
+  url_prefix: srv+ftp://bar
+`)
+	f(`
+users:
 - username: foo
   url_prefix: //bar
 `)
@@ -228,6 +249,22 @@ users:
 		},
 	})
 
+	// Limits for upgraded connections
+	f(`
+users:
+- username: foo
+  url_prefix: http://aaa:343/bbb
+  max_connection_duration: 1h
+  idle_timeout: 5m
+`, map[string]*UserInfo{
+		getAuthToken("", "foo", ""): {
+			Username:              "foo",
+			URLPrefix:             mustParseURL("http://aaa:343/bbb"),
+			MaxConnectionDuration: promutils.NewDuration(time.Hour),
+			IdleTimeout:           promutils.NewDuration(5 * time.Minute),
+		},
+	})
+
 	// Multiple url_prefix entries
 	f(`
 users:
@@ -399,7 +436,8 @@ func mustParseURLs(us []string) *URLPrefix {
 			panic(fmt.Errorf("BUG: cannot parse %q: %w", u, err))
 		}
 		bus[i] = &backendURL{
-			url: pu,
+			url:     pu,
+			origIdx: i,
 		}
 	}
 	return &URLPrefix{
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	backendDiscoveryInterval = flag.Duration("backendDiscoveryInterval", 10*time.Second, "Interval for refreshing the list of backends "+
+		"for `url_prefix` entries with `srv+http` or `srv+https` scheme. See https://docs.victoriametrics.com/vmauth.html#backend-discovery")
+	backendDiscoveryTimeout = flag.Duration("backendDiscoveryTimeout", 5*time.Second, "Timeout for DNS lookups during backend discovery "+
+		"for `url_prefix` entries with `srv+http` or `srv+https` scheme")
+)
+
+var (
+	backendDiscoveries      = metrics.NewCounter(`vmauth_backend_discoveries_total`)
+	backendDiscoveryErrors  = metrics.NewCounter(`vmauth_backend_discovery_errors_total`)
+	backendDiscoveryRemoved = metrics.NewCounter(`vmauth_backend_discovery_removed_backends_total`)
+)
+
+// dnsResolver is used for backends discovery.
+//
+// It may be substituted in tests.
+var dnsResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+} = net.DefaultResolver
+
+func isDiscoveryScheme(scheme string) bool {
+	return strings.HasPrefix(scheme, "srv+")
+}
+
+// startBackendsDiscovery performs the initial backends discovery for `url_prefix` entries with `srv+` scheme in m
+// and then starts refreshing the discovered backends in background every -backendDiscoveryInterval.
+//
+// The discovery is performed outside the request processing path, so requests are proxied only to already discovered backends.
+// If the discovery was already started for the previous config, then it is stopped, while the backends discovered
+// for the previous config are used as the last known backends for the same `url_prefix` entries in m.
+// This preserves backends and their connections across config reloads even if the discovery for m fails.
+//
+// stopBackendsDiscovery must be called when the backends for m are no longer needed.
+func startBackendsDiscovery(m map[string]*UserInfo) {
+	upsPrev := discoveredURLPrefixes
+	if discoveryStopCh != nil {
+		close(discoveryStopCh)
+		discoveryWG.Wait()
+	}
+	busPrev := make(map[string][]*backendURL, len(upsPrev))
+	for _, up := range upsPrev {
+		busPrev[up.discoveryKey()] = up.getBackends()
+	}
+
+	ups := getDiscoveryURLPrefixes(m)
+	for _, up := range ups {
+		if bus, ok := busPrev[up.discoveryKey()]; ok {
+			up.discoveredBus.Store(&bus)
+		}
+		up.discoverBackends()
+	}
+
+	// Close idle connections to the previously discovered backends, which aren't used by m.
+	inUse := make(map[*backendURL]bool)
+	for _, up := range ups {
+		for _, bu := range up.getBackends() {
+			inUse[bu] = true
+		}
+	}
+	for _, up := range upsPrev {
+		for _, bu := range up.getBackends() {
+			if !inUse[bu] {
+				bu.closeIdleConnections()
+			}
+		}
+	}
+
+	discoveredURLPrefixes = ups
+	discoveryStopCh = make(chan struct{})
+	if len(ups) == 0 {
+		return
+	}
+	discoveryWG.Add(1)
+	go func(stopCh <-chan struct{}) {
+		defer discoveryWG.Done()
+		backendsDiscoverer(ups, stopCh)
+	}(discoveryStopCh)
+}
+
+// stopBackendsDiscovery stops the backends discovery started by startBackendsDiscovery
+// and closes idle connections to the discovered backends.
+func stopBackendsDiscovery() {
+	close(discoveryStopCh)
+	discoveryWG.Wait()
+	for _, up := range discoveredURLPrefixes {
+		for _, bu := range up.getBackends() {
+			bu.closeIdleConnections()
+		}
+	}
+	discoveredURLPrefixes = nil
+	discoveryStopCh = nil
+}
+
+var (
+	discoveredURLPrefixes []*URLPrefix
+	discoveryStopCh       chan struct{}
+	discoveryWG           sync.WaitGroup
+)
+
+// discoveryKey returns the key for the `url_prefix` entry, which is used for matching it across config reloads.
+func (up *URLPrefix) discoveryKey() string {
+	urls := make([]string, 0, len(up.bus))
+	for _, bu := range up.bus {
+		urls = append(urls, bu.url.String())
+	}
+	return strings.Join(urls, "\n")
+}
+
+func backendsDiscoverer(ups []*URLPrefix, stopCh <-chan struct{}) {
+	interval := *backendDiscoveryInterval
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			for _, up := range ups {
+				up.discoverBackends()
+			}
+		}
+	}
+}
+
+// getDiscoveryURLPrefixes returns unique `url_prefix` entries with `srv+` scheme from m.
+func getDiscoveryURLPrefixes(m map[string]*UserInfo) []*URLPrefix {
+	var ups []*URLPrefix
+	seen := make(map[*URLPrefix]bool)
+	addURLPrefix := func(up *URLPrefix) {
+		if up == nil || !up.needDiscovery || seen[up] {
+			return
+		}
+		seen[up] = true
+		ups = append(ups, up)
+	}
+	for _, ui := range m {
+		addURLPrefix(ui.URLPrefix)
+		for _, e := range ui.URLMaps {
+			addURLPrefix(e.URLPrefix)
+		}
+	}
+	return ups
+}
+
+// discoverBackends refreshes the list of backends for up.
+//
+// The last known backends are preserved for the `url_prefix` entry if its discovery fails or returns an empty result.
+// Idle connections to the backends removed from DNS are closed, while requests already proxied to them are allowed to finish.
+//
+// discoverBackends mustn't be called concurrently for the same up.
+func (up *URLPrefix) discoverBackends() {
+	ctx, cancel := context.WithTimeout(context.Background(), *backendDiscoveryTimeout)
+	defer cancel()
+
+	busOld := up.getBackends()
+	busOldByURL := make(map[string]*backendURL, len(busOld))
+	for _, bu := range busOld {
+		busOldByURL[bu.url.String()] = bu
+	}
+	var busNew []*backendURL
+	for i, buOrig := range up.bus {
+		if !isDiscoveryScheme(buOrig.url.Scheme) {
+			busNew = append(busNew, buOrig)
+			continue
+		}
+		urls, err := discoverBackendURLs(ctx, buOrig.url)
+		if err != nil {
+			backendDiscoveryErrors.Inc()
+			logger.Errorf("cannot discover backends for `url_prefix: %q`; preserving the last known backends; error: %s", buOrig.url, err)
+			for _, bu := range busOld {
+				if bu.origIdx == i {
+					busNew = append(busNew, bu)
+				}
+			}
+			continue
+		}
+		for _, u := range urls {
+			bu := busOldByURL[u.String()]
+			if bu == nil {
+				bu = &backendURL{
+					url:     u,
+					origIdx: i,
+					tr:      newTransport(),
+				}
+			}
+			busNew = append(busNew, bu)
+		}
+	}
+	up.discoveredBus.Store(&busNew)
+	backendDiscoveries.Inc()
+
+	busNewByURL := make(map[string]bool, len(busNew))
+	for _, bu := range busNew {
+		busNewByURL[bu.url.String()] = true
+	}
+	for _, bu := range busOld {
+		if !busNewByURL[bu.url.String()] {
+			backendDiscoveryRemoved.Inc()
+			bu.closeIdleConnections()
+		}
+	}
+}
+
+// discoverBackendURLs returns backend urls for u.
+//
+// u is returned as is if it doesn't have `srv+` scheme. Otherwise the host from u is resolved into a list of backends:
+//
+//   - via DNS SRV lookup if u has no port, e.g. `srv+http://_http._tcp.vmselect.svc/select/0/prometheus`;
+//   - via DNS A and AAAA lookup if u has a port, e.g. `srv+http://vmselect.svc:8481/select/0/prometheus`.
+//     This is useful for Kubernetes headless services.
+//
+// An error is returned if the resolved list is empty.
+func discoverBackendURLs(ctx context.Context, u *url.URL) ([]*url.URL, error) {
+	if !isDiscoveryScheme(u.Scheme) {
+		return []*url.URL{u}, nil
+	}
+	var hosts []string
+	if port := u.Port(); port == "" {
+		_, srvs, err := dnsResolver.LookupSRV(ctx, "", "", u.Hostname())
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve SRV records for %q: %w", u.Hostname(), err)
+		}
+		for _, srv := range srvs {
+			target := strings.TrimSuffix(srv.Target, ".")
+			hosts = append(hosts, net.JoinHostPort(target, strconv.Itoa(int(srv.Port))))
+		}
+	} else {
+		ips, err := dnsResolver.LookupIPAddr(ctx, u.Hostname())
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve IP addresses for %q: %w", u.Hostname(), err)
+		}
+		for _, ip := range ips {
+			hosts = append(hosts, net.JoinHostPort(ip.IP.String(), port))
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no backends found for %q", u.Hostname())
+	}
+	// Sort hosts in order to keep the stable order of backends between discoveries.
+	sort.Strings(hosts)
+	urls := make([]*url.URL, 0, len(hosts))
+	for _, host := range hosts {
+		uCopy := *u
+		uCopy.Scheme = strings.TrimPrefix(u.Scheme, "srv+")
+		uCopy.Host = host
+		urls = append(urls, &uCopy)
+	}
+	return urls, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+)
+
+type fakeResolver struct {
+	srvs map[string][]*net.SRV
+	ips  map[string][]net.IPAddr
+}
+
+func (r *fakeResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	srvs, ok := r.srvs[name]
+	if !ok {
+		return "", nil, fmt.Errorf("no such host: %q", name)
+	}
+	return name, srvs, nil
+}
+
+func (r *fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r.ips[host]
+	if !ok {
+		return nil, fmt.Errorf("no such host: %q", host)
+	}
+	return ips, nil
+}
+
+func TestDiscoverBackends(t *testing.T) {
+	r := &fakeResolver{
+		srvs: map[string][]*net.SRV{
+			"_http._tcp.vmselect": {
+				{Target: "vmselect-1.", Port: 8481},
+				{Target: "vmselect-0.", Port: 8481},
+			},
+		},
+		ips: map[string][]net.IPAddr{
+			"vmselect": {
+				{IP: net.ParseIP("10.0.0.2")},
+				{IP: net.ParseIP("10.0.0.1")},
+			},
+		},
+	}
+	resolverOrig := dnsResolver
+	dnsResolver = r
+	defer func() {
+		dnsResolver = resolverOrig
+	}()
+
+	f := func(up *URLPrefix, urlsExpected []string) {
+		t.Helper()
+		up.discoverBackends()
+		var urls []string
+		for _, bu := range up.getBackends() {
+			urls = append(urls, bu.url.String())
+		}
+		if !reflect.DeepEqual(urls, urlsExpected) {
+			t.Fatalf("unexpected backends; got %q; want %q", urls, urlsExpected)
+		}
+	}
+
+	up := mustParseSanitizedURLs(t, []string{
+		"srv+http://_http._tcp.vmselect/select/0/prometheus",
+		"srv+https://vmselect:8481/select/0/prometheus",
+		"http://static:8481/select/0/prometheus",
+	})
+	f(up, []string{
+		"http://vmselect-0:8481/select/0/prometheus",
+		"http://vmselect-1:8481/select/0/prometheus",
+		"https://10.0.0.1:8481/select/0/prometheus",
+		"https://10.0.0.2:8481/select/0/prometheus",
+		"http://static:8481/select/0/prometheus",
+	})
+	bu := up.getBackends()[0]
+	if bu.tr == nil {
+		t.Fatalf("the discovered backend must have its own transport")
+	}
+	if up.getBackends()[4].tr != nil {
+		t.Fatalf("the static backend must use the shared transport")
+	}
+
+	// Scale down the SRV backends
+	r.srvs["_http._tcp.vmselect"] = []*net.SRV{
+		{Target: "vmselect-0.", Port: 8481},
+	}
+	f(up, []string{
+		"http://vmselect-0:8481/select/0/prometheus",
+		"https://10.0.0.1:8481/select/0/prometheus",
+		"https://10.0.0.2:8481/select/0/prometheus",
+		"http://static:8481/select/0/prometheus",
+	})
+	if up.getBackends()[0] != bu {
+		t.Fatalf("the backend must be preserved between discoveries")
+	}
+
+	// Empty result must preserve the last known backends
+	r.srvs["_http._tcp.vmselect"] = nil
+	f(up, []string{
+		"http://vmselect-0:8481/select/0/prometheus",
+		"https://10.0.0.1:8481/select/0/prometheus",
+		"https://10.0.0.2:8481/select/0/prometheus",
+		"http://static:8481/select/0/prometheus",
+	})
+
+	// Resolution error must preserve the last known backends
+	delete(r.ips, "vmselect")
+	f(up, []string{
+		"http://vmselect-0:8481/select/0/prometheus",
+		"https://10.0.0.1:8481/select/0/prometheus",
+		"https://10.0.0.2:8481/select/0/prometheus",
+		"http://static:8481/select/0/prometheus",
+	})
+
+	// Unknown host without the last known backends
+	up = mustParseSanitizedURLs(t, []string{"srv+http://missing:8481"})
+	f(up, nil)
+
+	// Static urls must be returned as is
+	up = mustParseSanitizedURLs(t, []string{"http://foo", "http://bar"})
+	f(up, []string{"http://foo", "http://bar"})
+}
+
+func TestStartBackendsDiscovery(t *testing.T) {
+	resolverOrig := dnsResolver
+	dnsResolver = &fakeResolver{
+		ips: map[string][]net.IPAddr{
+			"vmselect": {
+				{IP: net.ParseIP("10.0.0.1")},
+			},
+		},
+	}
+	defer func() {
+		dnsResolver = resolverOrig
+	}()
+
+	m, err := parseAuthConfig([]byte(`
+users:
+- username: foo
+  url_prefix: srv+http://vmselect:8481/select/0/prometheus
+- username: bar
+  url_map:
+  - src_paths: ["/api/v1/query"]
+    url_prefix: [srv+http://vmselect:8481/select/1/prometheus, http://static:8481/select/1/prometheus]
+- username: baz
+  url_prefix: http://static:8481/select/2/prometheus
+`))
+	if err != nil {
+		t.Fatalf("cannot parse auth config: %s", err)
+	}
+	startBackendsDiscovery(m)
+	defer stopBackendsDiscovery()
+
+	if n := len(discoveredURLPrefixes); n != 2 {
+		t.Fatalf("unexpected number of url_prefix entries with discovery; got %d; want 2", n)
+	}
+	// The backends must be discovered before serving requests for m.
+	f := func(up *URLPrefix, urlsExpected []string) {
+		t.Helper()
+		var urls []string
+		for _, bu := range up.getBackends() {
+			urls = append(urls, bu.url.String())
+		}
+		if !reflect.DeepEqual(urls, urlsExpected) {
+			t.Fatalf("unexpected backends; got %q; want %q", urls, urlsExpected)
+		}
+	}
+	at := getAuthToken("", "foo", "")
+	f(m[at].URLPrefix, []string{"http://10.0.0.1:8481/select/0/prometheus"})
+	at = getAuthToken("", "bar", "")
+	f(m[at].URLMaps[0].URLPrefix, []string{"http://10.0.0.1:8481/select/1/prometheus", "http://static:8481/select/1/prometheus"})
+	at = getAuthToken("", "baz", "")
+	f(m[at].URLPrefix, []string{"http://static:8481/select/2/prometheus"})
+}
+
+func TestStartBackendsDiscoveryReload(t *testing.T) {
+	r := &fakeResolver{
+		ips: map[string][]net.IPAddr{
+			"vmselect": {
+				{IP: net.ParseIP("10.0.0.1")},
+			},
+		},
+	}
+	resolverOrig := dnsResolver
+	dnsResolver = r
+	defer func() {
+		dnsResolver = resolverOrig
+	}()
+
+	mustParse := func(data string) map[string]*UserInfo {
+		t.Helper()
+		m, err := parseAuthConfig([]byte(data))
+		if err != nil {
+			t.Fatalf("cannot parse auth config: %s", err)
+		}
+		return m
+	}
+	getBackends := func(m map[string]*UserInfo, username string) []*backendURL {
+		t.Helper()
+		at := getAuthToken("", username, "")
+		return m[at].URLPrefix.getBackends()
+	}
+	cfg := `
+users:
+- username: foo
+  url_prefix: srv+http://vmselect:8481/select/0/prometheus
+- username: bar
+  url_prefix: srv+http://vmselect:8481/select/1/prometheus
+`
+	m := mustParse(cfg)
+	startBackendsDiscovery(m)
+	defer stopBackendsDiscovery()
+	bus := getBackends(m, "foo")
+	if len(bus) != 1 {
+		t.Fatalf("unexpected number of discovered backends; got %d; want 1", len(bus))
+	}
+
+	// The previously discovered backends must be preserved after the config reload if the discovery fails.
+	delete(r.ips, "vmselect")
+	m = mustParse(cfg)
+	startBackendsDiscovery(m)
+	busNew := getBackends(m, "foo")
+	if len(busNew) != 1 || busNew[0] != bus[0] {
+		t.Fatalf("the previously discovered backends must be preserved after the config reload; got %d backends", len(busNew))
+	}
+
+	// The previously discovered backends and their transports must be reused after the config reload.
+	r.ips["vmselect"] = []net.IPAddr{
+		{IP: net.ParseIP("10.0.0.1")},
+		{IP: net.ParseIP("10.0.0.2")},
+	}
+	m = mustParse(cfg)
+	startBackendsDiscovery(m)
+	busNew = getBackends(m, "foo")
+	if len(busNew) != 2 || busNew[0] != bus[0] {
+		t.Fatalf("the previously discovered backend must be reused after the config reload; got %d backends", len(busNew))
+	}
+
+	// Changed url_prefix entries must not reuse the previously discovered backends.
+	delete(r.ips, "vmselect")
+	m = mustParse(`
+users:
+- username: foo
+  url_prefix: srv+http://vmselect:8481/select/2/prometheus
+`)
+	startBackendsDiscovery(m)
+	if n := len(getBackends(m, "foo")); n != 0 {
+		t.Fatalf("unexpected number of backends for the changed url_prefix; got %d; want 0", n)
+	}
+}
+
+func mustParseSanitizedURLs(t *testing.T, us []string) *URLPrefix {
+	t.Helper()
+	up := mustParseURLs(us)
+	if err := up.sanitize(); err != nil {
+		t.Fatalf("cannot sanitize %q: %s", us, err)
+	}
+	return up
+}
@@ -55,7 +55,8 @@ func main() {
 	logger.Infof("starting vmauth at %q...", *httpListenAddr)
 	startTime := time.Now()
 	initAuthConfig()
-	go httpserver.Serve(*httpListenAddr, *useProxyProtocol, requestHandler)
+	initAccessLog()
+	go httpserver.Serve(*httpListenAddr, *useProxyProtocol, httpserver.WithDetailedMetrics(requestHandler, detailedMetricsPathPrefixes))
 	logger.Infof("started vmauth in %.3f seconds", time.Since(startTime).Seconds())
 
 	sig := procutil.WaitForSigterm()
@@ -68,9 +69,17 @@ func main() {
 	}
 	logger.Infof("successfully shut down the webservice in %.3f seconds", time.Since(startTime).Seconds())
 	stopAuthConfig()
+	stopAccessLog()
 	logger.Infof("successfully stopped vmauth in %.3f seconds", time.Since(startTime).Seconds())
 }
 
+// detailedMetricsPathPrefixes contains path prefixes for vm_http_* histograms exposed when -http.detailedMetrics is set.
+//
+// Proxied requests are exposed with path="other" label, since their paths depend on -auth.config.
+var detailedMetricsPathPrefixes = []string{
+	"/-/reload",
+}
+
 func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 	switch r.URL.Path {
 	case "/-/reload":
@@ -82,6 +91,12 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 		w.WriteHeader(http.StatusOK)
 		return true
 	}
+	var ale *accessLogEntry
+	if *accessLogEnabled {
+		ale = newAccessLogEntry(w, r)
+		defer ale.write()
+		w = ale
+	}
 	authToken := r.Header.Get("Authorization")
 	if authToken == "" {
 		w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
@@ -111,6 +126,7 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 		return true
 	}
 	ui.requests.Inc()
+	ale.setUser(ui.name())
 
 	// Limit the concurrency of requests to backends
 	concurrencyLimitOnce.Do(concurrencyLimitInit)
@@ -127,24 +143,28 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 		handleConcurrencyLimitError(w, r, err)
 		return true
 	}
-	processRequest(w, r, ui)
+	processRequest(w, r, ui, ale)
 	ui.endConcurrencyLimit()
 	<-concurrencyLimitCh
 	return true
 }
 
-func processRequest(w http.ResponseWriter, r *http.Request, ui *UserInfo) {
+func processRequest(w http.ResponseWriter, r *http.Request, ui *UserInfo, ale *accessLogEntry) {
 	u := normalizeURL(r.URL)
 	up, headers, err := ui.getURLPrefixAndHeaders(u)
 	if err != nil {
 		httpserver.Errorf(w, r, "cannot determine targetURL: %s", err)
 		return
 	}
+	if ale != nil {
+		ale.setURLMap(ui.getMatchedSrcPath(u))
+	}
 	maxAttempts := up.getBackendsCount()
 	for i := 0; i < maxAttempts; i++ {
 		bu := up.getLeastLoadedBackendURL()
+		ale.setBackend(bu.url)
 		targetURL := mergeURLs(bu.url, u)
-		ok := tryProcessingRequest(w, r, targetURL, headers)
+		ok := tryProcessingRequest(w, r, targetURL, bu.getTransport(), headers, ui)
 		bu.put()
 		if ok {
 			return
@@ -158,15 +178,21 @@ func processRequest(w http.ResponseWriter, r *http.Request, ui *UserInfo) {
 	httpserver.Errorf(w, r, "%s", err)
 }
 
-func tryProcessingRequest(w http.ResponseWriter, r *http.Request, targetURL *url.URL, headers []Header) bool {
+func tryProcessingRequest(w http.ResponseWriter, r *http.Request, targetURL *url.URL, tr *http.Transport, headers []Header, ui *UserInfo) bool {
 	// This code has been copied from net/http/httputil/reverseproxy.go
 	req := sanitizeRequestHeaders(r)
 	req.URL = targetURL
+	upgradeType := getUpgradeType(r.Header)
+	if upgradeType != "" {
+		// Restore hop-by-hop headers removed by sanitizeRequestHeaders, since they are needed for protocol upgrade.
+		// See https://developer.mozilla.org/en-US/docs/Web/HTTP/Protocol_upgrade_mechanism
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", upgradeType)
+	}
 	for _, h := range headers {
 		req.Header.Set(h.Name, h.Value)
 	}
-	transportOnce.Do(transportInit)
-	res, err := transport.RoundTrip(req)
+	res, err := tr.RoundTrip(req)
 	if err != nil {
 		remoteAddr := httpserver.GetQuotedRemoteAddr(r)
 		requestURI := httpserver.GetRequestURI(r)
@@ -183,6 +209,11 @@ func tryProcessingRequest(w http.ResponseWriter, r *http.Request, targetURL *url
 		logger.Warnf("remoteAddr: %s; requestURI: %s; error when proxying the request to %q: %s", remoteAddr, requestURI, targetURL, err)
 		return false
 	}
+	if res.StatusCode == http.StatusSwitchingProtocols {
+		// The upgraded connection is proxied as a single long-lived request to the backend.
+		handleUpgradeResponse(w, r, res, upgradeType, ui)
+		return true
+	}
 	removeHopHeaders(res.Header)
 	copyHeader(w.Header(), res.Header)
 	w.WriteHeader(res.StatusCode)
@@ -276,6 +307,10 @@ var (
 )
 
 func transportInit() {
+	transport = newTransport()
+}
+
+func newTransport() *http.Transport {
 	tr := http.DefaultTransport.(*http.Transport).Clone()
 	tr.ResponseHeaderTimeout = *responseTimeout
 	// Automatic compression must be disabled in order to fix https://github.com/VictoriaMetrics/VictoriaMetrics/issues/535
@@ -286,7 +321,7 @@ func transportInit() {
 	if tr.MaxIdleConns != 0 && tr.MaxIdleConns < tr.MaxIdleConnsPerHost {
 		tr.MaxIdleConns = tr.MaxIdleConnsPerHost
 	}
-	transport = tr
+	return tr
 }
 
 var (
@@ -45,6 +45,20 @@ func (ui *UserInfo) getURLPrefixAndHeaders(u *url.URL) (*URLPrefix, []Header, er
 	return nil, nil, fmt.Errorf("missing route for %q", u.String())
 }
 
+// getMatchedSrcPath returns src_paths entry from url_map, which matches u.
+//
+// An empty string is returned if u doesn't match any src_paths entry.
+func (ui *UserInfo) getMatchedSrcPath(u *url.URL) string {
+	for _, e := range ui.URLMaps {
+		for _, sp := range e.SrcPaths {
+			if sp.match(u.Path) {
+				return sp.sOriginal
+			}
+		}
+	}
+	return ""
+}
+
 func normalizeURL(uOrig *url.URL) *url.URL {
 	u := *uOrig
 	// Prevent from attacks with using `..` in r.URL.Path
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/netutil"
+	"github.com/VictoriaMetrics/metrics"
+	"golang.org/x/net/http/httpguts"
+)
+
+// getUpgradeType returns the protocol name from `Upgrade` header if h contains `Connection: Upgrade` header.
+//
+// Empty string is returned if h doesn't request protocol upgrade.
+func getUpgradeType(h http.Header) string {
+	if !httpguts.HeaderValuesContainsToken(h["Connection"], "Upgrade") {
+		return ""
+	}
+	return h.Get("Upgrade")
+}
+
+// upgradedConnMetrics contains per-user metrics for connections upgraded to another protocol such as WebSocket.
+type upgradedConnMetrics struct {
+	total              *metrics.Counter
+	active             *metrics.Counter
+	bytesFromClient    *metrics.Counter
+	bytesToClient      *metrics.Counter
+	idleTimeouts       *metrics.Counter
+	maxDurationReached *metrics.Counter
+}
+
+func newUpgradedConnMetrics(name string) *upgradedConnMetrics {
+	return &upgradedConnMetrics{
+		total: metrics.GetOrCreateCounter(fmt.Sprintf(`vmauth_user_upgraded_connections_total{username=%q}`, name)),
+		// The number of active connections is tracked with a counter instead of a gauge, since the gauge callback
+		// would refer to the UserInfo from the first loaded config after config reload.
+		active:             metrics.GetOrCreateCounter(fmt.Sprintf(`vmauth_user_upgraded_connections_active{username=%q}`, name)),
+		bytesFromClient:    metrics.GetOrCreateCounter(fmt.Sprintf(`vmauth_user_upgraded_connection_bytes_total{username=%q,direction="from_client"}`, name)),
+		bytesToClient:      metrics.GetOrCreateCounter(fmt.Sprintf(`vmauth_user_upgraded_connection_bytes_total{username=%q,direction="to_client"}`, name)),
+		idleTimeouts:       metrics.GetOrCreateCounter(fmt.Sprintf(`vmauth_user_upgraded_connections_closed_total{username=%q,reason="idle_timeout"}`, name)),
+		maxDurationReached: metrics.GetOrCreateCounter(fmt.Sprintf(`vmauth_user_upgraded_connections_closed_total{username=%q,reason="max_connection_duration"}`, name)),
+	}
+}
+
+// handleUpgradeResponse switches the client connection to the protocol from the backend response res with 101 status code
+// and then proxies data between the client and the backend until one of them closes the connection
+// or until -auth.config limits for the ui are reached.
+//
+// upgradeType must contain the protocol requested by the client.
+func handleUpgradeResponse(w http.ResponseWriter, r *http.Request, res *http.Response, upgradeType string, ui *UserInfo) {
+	resUpgradeType := getUpgradeType(res.Header)
+	if upgradeType == "" || !strings.EqualFold(upgradeType, resUpgradeType) {
+		_ = res.Body.Close()
+		err := &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("backend switched to unexpected protocol %q; requested protocol: %q", resUpgradeType, upgradeType),
+			StatusCode: http.StatusBadGateway,
+		}
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+	backendConn, ok := res.Body.(io.ReadWriteCloser)
+	if !ok {
+		_ = res.Body.Close()
+		logger.Panicf("BUG: unexpected response body type for 101 Switching Protocols response: %T; want io.ReadWriteCloser", res.Body)
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		_ = backendConn.Close()
+		err := &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("cannot switch protocol to %q, since the client connection doesn't support hijacking", upgradeType),
+			StatusCode: http.StatusInternalServerError,
+		}
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+	// Drop `Connection` header set by the http server, since the backend response already contains `Connection: Upgrade`.
+	w.Header().Del("Connection")
+	copyHeader(w.Header(), res.Header)
+	clientConn, brw, err := hj.Hijack()
+	if err != nil {
+		_ = backendConn.Close()
+		err := &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("cannot hijack client connection for switching protocol to %q: %w", upgradeType, err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+	// Reset deadlines set by the http server, since the upgraded connection may live for long time.
+	_ = clientConn.SetDeadline(time.Time{})
+	res.Header = w.Header()
+	res.Body = nil
+	if err := res.Write(brw); err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		_ = clientConn.Close()
+		_ = backendConn.Close()
+		if !netutil.IsTrivialNetworkError(err) {
+			logger.Warnf("remoteAddr: %s; requestURI: %s; cannot send response for switching protocol to %q: %s",
+				httpserver.GetQuotedRemoteAddr(r), httpserver.GetRequestURI(r), upgradeType, err)
+		}
+		return
+	}
+	// Data sent by the client right after the upgrade request may be already buffered in brw.Reader.
+	proxyUpgradedConn(clientConn, brw.Reader, backendConn, ui)
+}
+
+// proxyUpgradedConn copies data between clientConn and backendConn until one of them is closed or until ui limits are reached.
+//
+// Data from the client is read from clientReader. Both connections are closed on return.
+func proxyUpgradedConn(clientConn net.Conn, clientReader io.Reader, backendConn io.ReadWriteCloser, ui *UserInfo) {
+	ucm := ui.upgradedConns
+	ucm.total.Inc()
+	ucm.active.Inc()
+	defer ucm.active.Dec()
+
+	startTime := time.Now()
+	lastActivityTime := startTime.UnixNano()
+	doneCh := make(chan struct{}, 2)
+	go func() {
+		cw := &upgradedConnWriter{
+			w:                backendConn,
+			bytes:            ucm.bytesFromClient,
+			lastActivityTime: &lastActivityTime,
+		}
+		_, _ = io.Copy(cw, clientReader)
+		doneCh <- struct{}{}
+	}()
+	go func() {
+		cw := &upgradedConnWriter{
+			w:                clientConn,
+			bytes:            ucm.bytesToClient,
+			lastActivityTime: &lastActivityTime,
+		}
+		_, _ = io.Copy(cw, backendConn)
+		doneCh <- struct{}{}
+	}()
+
+	maxDuration := ui.MaxConnectionDuration.Duration()
+	idleTimeout := ui.IdleTimeout.Duration()
+	if maxDuration <= 0 && idleTimeout <= 0 {
+		<-doneCh
+		closeUpgradedConns(clientConn, backendConn)
+		<-doneCh
+		return
+	}
+	_, d := getUpgradedConnCloseReason(startTime, startTime, startTime, maxDuration, idleTimeout)
+	t := time.NewTimer(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-doneCh:
+			closeUpgradedConns(clientConn, backendConn)
+			<-doneCh
+			return
+		case <-t.C:
+			lastActivity := time.Unix(0, atomic.LoadInt64(&lastActivityTime))
+			reason, d := getUpgradedConnCloseReason(startTime, lastActivity, time.Now(), maxDuration, idleTimeout)
+			switch reason {
+			case "":
+				t.Reset(d)
+				continue
+			case "idle_timeout":
+				ucm.idleTimeouts.Inc()
+			case "max_connection_duration":
+				ucm.maxDurationReached.Inc()
+			}
+			closeUpgradedConns(clientConn, backendConn)
+			<-doneCh
+			<-doneCh
+			return
+		}
+	}
+}
+
+func closeUpgradedConns(clientConn net.Conn, backendConn io.Closer) {
+	_ = clientConn.Close()
+	_ = backendConn.Close()
+}
+
+// getUpgradedConnCloseReason returns the reason for closing the upgraded connection started at startTime
+// with the last activity at lastActivity.
+//
+// Empty reason is returned if the connection mustn't be closed yet. In this case the duration
+// until the next check is returned. Zero maxDuration and zero idleTimeout mean no limits.
+func getUpgradedConnCloseReason(startTime, lastActivity, now time.Time, maxDuration, idleTimeout time.Duration) (string, time.Duration) {
+	var d time.Duration
+	if maxDuration > 0 {
+		d = startTime.Add(maxDuration).Sub(now)
+		if d <= 0 {
+			return "max_connection_duration", 0
+		}
+	}
+	if idleTimeout > 0 {
+		dIdle := lastActivity.Add(idleTimeout).Sub(now)
+		if dIdle <= 0 {
+			return "idle_timeout", 0
+		}
+		if d <= 0 || dIdle < d {
+			d = dIdle
+		}
+	}
+	return "", d
+}
+
+// upgradedConnWriter writes data to w, counts the written bytes and updates lastActivityTime.
+type upgradedConnWriter struct {
+	w                io.Writer
+	bytes            *metrics.Counter
+	lastActivityTime *int64
+}
+
+func (cw *upgradedConnWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.bytes.Add(n)
+	atomic.StoreInt64(cw.lastActivityTime, time.Now().UnixNano())
+	return n, err
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetUpgradeType(t *testing.T) {
+	f := func(h http.Header, resultExpected string) {
+		t.Helper()
+		result := getUpgradeType(h)
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %q; want %q", result, resultExpected)
+		}
+	}
+	f(http.Header{}, "")
+	f(http.Header{"Upgrade": {"websocket"}}, "")
+	f(http.Header{"Connection": {"keep-alive"}, "Upgrade": {"websocket"}}, "")
+	f(http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}}, "websocket")
+	f(http.Header{"Connection": {"keep-alive, upgrade"}, "Upgrade": {"websocket"}}, "websocket")
+}
+
+func TestGetUpgradedConnCloseReason(t *testing.T) {
+	startTime := time.Unix(1000, 0)
+	f := func(lastActivityOffset, nowOffset, maxDuration, idleTimeout time.Duration, reasonExpected string, dExpected time.Duration) {
+		t.Helper()
+		reason, d := getUpgradedConnCloseReason(startTime, startTime.Add(lastActivityOffset), startTime.Add(nowOffset), maxDuration, idleTimeout)
+		if reason != reasonExpected {
+			t.Fatalf("unexpected reason; got %q; want %q", reason, reasonExpected)
+		}
+		if d != dExpected {
+			t.Fatalf("unexpected duration; got %s; want %s", d, dExpected)
+		}
+	}
+
+	// Only max_connection_duration
+	f(0, time.Minute, time.Hour, 0, "", 59*time.Minute)
+	f(0, time.Hour, time.Hour, 0, "max_connection_duration", 0)
+
+	// Only idle_timeout
+	f(0, 0, 0, time.Minute, "", time.Minute)
+	f(50*time.Second, time.Minute, 0, time.Minute, "", 50*time.Second)
+	f(0, time.Minute, 0, time.Minute, "idle_timeout", 0)
+
+	// Both limits
+	f(0, 0, time.Hour, time.Minute, "", time.Minute)
+	f(59*time.Minute, 59*time.Minute+30*time.Second, time.Hour, time.Minute, "", 30*time.Second)
+	f(59*time.Minute+30*time.Second, time.Hour, time.Hour, time.Minute, "max_connection_duration", 0)
+}
+
+func TestProxyUpgradedConn(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			panic(fmt.Errorf("cannot hijack connection: %w", err))
+		}
+		defer c.Close()
+		_, _ = io.WriteString(c, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		_, _ = io.Copy(c, brw)
+	}))
+	defer backend.Close()
+	m, err := parseAuthConfig([]byte(fmt.Sprintf(`
+users:
+- name: upgrade-test-foo
+  bearer_token: foo
+  url_prefix: %s
+- name: upgrade-test-bar
+  bearer_token: bar
+  url_prefix: %s
+  idle_timeout: 100ms
+`, backend.URL, backend.URL)))
+	if err != nil {
+		t.Fatalf("cannot parse auth config: %s", err)
+	}
+	origAuthConfig := authConfig.Load()
+	authConfig.Store(m)
+	defer func() {
+		if origAuthConfig != nil {
+			authConfig.Store(origAuthConfig)
+		}
+	}()
+	vmauth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestHandler(w, r)
+	}))
+	defer vmauth.Close()
+
+	dial := func(token string) (net.Conn, *bufio.Reader) {
+		t.Helper()
+		c, err := net.Dial("tcp", vmauth.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("cannot connect to vmauth: %s", err)
+		}
+		_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+		req := "GET /ws HTTP/1.1\r\nHost: vmauth\r\nAuthorization: Bearer " + token + "\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n"
+		if _, err := io.WriteString(c, req); err != nil {
+			t.Fatalf("cannot send upgrade request: %s", err)
+		}
+		br := bufio.NewReader(c)
+		res, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatalf("cannot read upgrade response: %s", err)
+		}
+		if res.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("unexpected response status code; got %d; want %d", res.StatusCode, http.StatusSwitchingProtocols)
+		}
+		if upgradeType := getUpgradeType(res.Header); upgradeType != "echo" {
+			t.Fatalf("unexpected upgrade type in the response; got %q; want %q", upgradeType, "echo")
+		}
+		return c, br
+	}
+	echo := func(c net.Conn, br *bufio.Reader, s string) {
+		t.Helper()
+		if _, err := io.WriteString(c, s); err != nil {
+			t.Fatalf("cannot send data: %s", err)
+		}
+		buf := make([]byte, len(s))
+		if _, err := io.ReadFull(br, buf); err != nil {
+			t.Fatalf("cannot read data: %s", err)
+		}
+		if string(buf) != s {
+			t.Fatalf("unexpected data; got %q; want %q", buf, s)
+		}
+	}
+
+	// The connection is proxied until the client closes it.
+	ui := m[getAuthToken("foo", "", "")]
+	bytesFromClient := ui.upgradedConns.bytesFromClient.Get()
+	bytesToClient := ui.upgradedConns.bytesToClient.Get()
+	c, br := dial("foo")
+	echo(c, br, "foo")
+	echo(c, br, "bar")
+	_ = c.Close()
+	waitForZeroActiveConns(t, ui)
+	if n := ui.upgradedConns.bytesFromClient.Get() - bytesFromClient; n != 6 {
+		t.Fatalf("unexpected number of bytes from client; got %d; want 6", n)
+	}
+	if n := ui.upgradedConns.bytesToClient.Get() - bytesToClient; n != 6 {
+		t.Fatalf("unexpected number of bytes to client; got %d; want 6", n)
+	}
+
+	// The connection is closed by vmauth after idle_timeout.
+	ui = m[getAuthToken("bar", "", "")]
+	idleTimeouts := ui.upgradedConns.idleTimeouts.Get()
+	c, br = dial("bar")
+	echo(c, br, "baz")
+	if _, err := br.ReadByte(); err == nil {
+		t.Fatalf("expecting non-nil error when reading from the connection closed on idle timeout")
+	}
+	_ = c.Close()
+	waitForZeroActiveConns(t, ui)
+	if n := ui.upgradedConns.idleTimeouts.Get() - idleTimeouts; n != 1 {
+		t.Fatalf("unexpected number of connections closed on idle timeout; got %d; want 1", n)
+	}
+}
+
+func waitForZeroActiveConns(t *testing.T, ui *UserInfo) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for ui.upgradedConns.active.Get() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for closing upgraded connections")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,11 +32,16 @@ var (
 	snapshotDeleteURL = flag.String("snapshot.deleteURL", "", "VictoriaMetrics delete snapshot url. Optional. Will be generated from -snapshot.createURL if not provided. "+
 		"All created snapshots will be automatically deleted. Example: http://victoriametrics:8428/snapshot/delete")
 	dst = flag.String("dst", "", "Where to put the backup on the remote storage. "+
-		"Example: gs://bucket/path/to/backup, s3://bucket/path/to/backup, azblob://container/path/to/backup or fs:///path/to/local/backup/dir\n"+
+		"Example: gs://bucket/path/to/backup, s3://bucket/path/to/backup, azblob://container/path/to/backup, sftp://user@host:port/path/to/backup, webdavs://host/path/to/backup or fs:///path/to/local/backup/dir\n"+
 		"-dst can point to the previous backup. In this case incremental backup is performed, i.e. only changed data is uploaded")
 	origin            = flag.String("origin", "", "Optional origin directory on the remote storage with old backup for server-side copying when performing full backup. This speeds up full backups")
 	concurrency       = flag.Int("concurrency", 10, "The number of concurrent workers. Higher concurrency may reduce backup duration")
 	maxBytesPerSecond = flagutil.NewBytes("maxBytesPerSecond", 0, "The maximum upload speed. There is no limit if it is set to 0")
+	verify            = flag.Bool("verify", false, "Whether to verify integrity of the backup at -dst instead of creating a new backup. "+
+		"vmbackup exits with non-zero code if the backup is incomplete or contains missing or corrupted parts. See https://docs.victoriametrics.com/vmbackup.html#backup-verification")
+	verifySample = flag.String("verify.sample", "0%", "The percentage of randomly selected parts to download from -dst for verifying their checksums when -verify is set. "+
+		"For example, -verify.sample=10% verifies checksums for 10% of parts, while -verify.sample=100% verifies checksums for all the parts. "+
+		"Checksums are verified only for backups made by vmbackup versions, which record checksums in the backup manifest")
 )
 
 func main() {
@@ -49,6 +55,13 @@ func main() {
 	logger.Init()
 	pushmetrics.Init()
 
+	if *verify {
+		if err := verifyBackup(); err != nil {
+			logger.Fatalf("cannot verify backup: %s", err)
+		}
+		return
+	}
+
 	// Storing snapshot delete function to be able to call it in case
 	// of error since logger.Fatal will exit the program without
 	// calling deferred functions.
@@ -144,6 +157,39 @@ func makeBackup() error {
 	return nil
 }
 
+func verifyBackup() error {
+	sampleRatio, err := parseSampleRatio(*verifySample)
+	if err != nil {
+		return fmt.Errorf("cannot parse -verify.sample=%q: %w", *verifySample, err)
+	}
+	fs, err := actions.NewRemoteFS(*dst)
+	if err != nil {
+		return fmt.Errorf("cannot parse `-dst`=%q: %w", *dst, err)
+	}
+	v := &actions.Verify{
+		Concurrency: *concurrency,
+		Src:         fs,
+		SampleRatio: sampleRatio,
+	}
+	if err := v.Run(); err != nil {
+		return err
+	}
+	fs.MustStop()
+	return nil
+}
+
+// parseSampleRatio parses percentage value such as `10%` into sample ratio in the range [0..1].
+func parseSampleRatio(s string) (float64, error) {
+	n, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > 100 {
+		return 0, fmt.Errorf("the percentage must be in the range [0%%..100%%]")
+	}
+	return n / 100, nil
+}
+
 func usage() {
 	const s = `
 vmbackup performs backups for VictoriaMetrics data from instant snapshots to gcs, s3, azblob
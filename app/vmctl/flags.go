@@ -338,6 +338,8 @@ const (
 	vmNativeDstPassword    = "vm-native-dst-password"
 	vmNativeDstHeaders     = "vm-native-dst-headers"
 	vmNativeDstBearerToken = "vm-native-dst-bearer-token"
+
+	vmNativeRelabel = "vm-native-relabel"
 )
 
 var (
@@ -427,6 +429,13 @@ var (
 			Usage: "Extra labels, that will be added to imported timeseries. In case of collision, label value defined by flag" +
 				"will have priority. Flag can be set multiple times, to add few additional labels.",
 		},
+		&cli.StringFlag{
+			Name: vmNativeRelabel,
+			Usage: "Optional relabeling config to apply at the destination to the imported time series. " +
+				"It may contain either the name of relabeling config configured at the destination via -import.namedRelabelConfig command-line flag " +
+				"or the path to local file with relabeling config. " +
+				"See https://docs.victoriametrics.com/#relabeling-during-native-import",
+		},
 		&cli.Int64Flag{
 			Name: vmRateLimit,
 			Usage: "Optional data transfer rate limit in bytes per second.\n" +
@@ -446,6 +455,67 @@ var (
 	}
 )
 
+const (
+	tsdbOutputDir = "tsdb-output-dir"
+)
+
+var (
+	vmNativeToTSDBFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name: vmNativeFilterMatch,
+			Usage: "Time series selector to match series for export. For example, select {instance!=\"localhost\"} will " +
+				"match all series with \"instance\" label different to \"localhost\".",
+			Value: `{__name__!=""}`,
+		},
+		&cli.StringFlag{
+			Name:     vmNativeFilterTimeStart,
+			Usage:    "The time filter in RFC3339 format to select time series with timestamp equal or higher than provided value. E.g. '2020-01-01T20:07:00Z'",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  vmNativeFilterTimeEnd,
+			Usage: "The time filter in RFC3339 format to select time series with timestamp equal or lower than provided value. E.g. '2020-01-01T20:07:00Z'",
+		},
+		&cli.BoolFlag{
+			Name:  vmNativeDisableHTTPKeepAlive,
+			Usage: "Disable HTTP persistent connections for requests made to VictoriaMetrics components during export",
+			Value: false,
+		},
+		&cli.StringFlag{
+			Name: vmNativeSrcAddr,
+			Usage: "VictoriaMetrics address to perform export from. \n" +
+				" Should be the same as --httpListenAddr value for single-node version or vmselect component.",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    vmNativeSrcUser,
+			Usage:   "VictoriaMetrics username for basic auth",
+			EnvVars: []string{"VM_NATIVE_SRC_USERNAME"},
+		},
+		&cli.StringFlag{
+			Name:    vmNativeSrcPassword,
+			Usage:   "VictoriaMetrics password for basic auth",
+			EnvVars: []string{"VM_NATIVE_SRC_PASSWORD"},
+		},
+		&cli.StringFlag{
+			Name: vmNativeSrcHeaders,
+			Usage: "Optional HTTP headers to send with each request to the corresponding source address. \n" +
+				"For example, --vm-native-src-headers='My-Auth:foobar' would send 'My-Auth: foobar' HTTP header with every request to the corresponding source address. \n" +
+				"Multiple headers must be delimited by '^^': --vm-native-src-headers='header1:value1^^header2:value2'",
+		},
+		&cli.StringFlag{
+			Name:  vmNativeSrcBearerToken,
+			Usage: "Optional bearer auth token to use for the corresponding `--vm-native-src-addr`",
+		},
+		&cli.StringFlag{
+			Name: tsdbOutputDir,
+			Usage: "Path to the directory for writing Prometheus TSDB blocks to. The directory is created if it doesn't exist. \n" +
+				" The written blocks can be copied to Prometheus data directory or uploaded to Thanos object storage.",
+			Required: true,
+		},
+	}
+)
+
 const (
 	remoteRead                   = "remote-read"
 	remoteReadUseStream          = "remote-read-use-stream"
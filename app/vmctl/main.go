@@ -240,6 +240,7 @@ func main() {
 							AuthCfg:              dstAuthConfig,
 							Addr:                 dstAddr,
 							ExtraLabels:          dstExtraLabels,
+							RelabelConfig:        c.String(vmNativeRelabel),
 							DisableHTTPKeepAlive: c.Bool(vmNativeDisableHTTPKeepAlive),
 						},
 						backoff: backoff.New(),
@@ -248,6 +249,38 @@ func main() {
 					return p.run(ctx, isNonInteractive(c))
 				},
 			},
+			{
+				Name:  "vm-native-to-tsdb",
+				Usage: "Export time series from VictoriaMetrics into Prometheus TSDB blocks",
+				Flags: mergeFlags(globalFlags, vmNativeToTSDBFlags),
+				Action: func(c *cli.Context) error {
+					fmt.Println("VictoriaMetrics to Prometheus TSDB export mode")
+
+					srcAddr := strings.Trim(c.String(vmNativeSrcAddr), "/")
+					srcAuthConfig, err := auth.Generate(
+						auth.WithBasicAuth(c.String(vmNativeSrcUser), c.String(vmNativeSrcPassword)),
+						auth.WithBearer(c.String(vmNativeSrcBearerToken)),
+						auth.WithHeaders(c.String(vmNativeSrcHeaders)))
+					if err != nil {
+						return fmt.Errorf("error initilize auth config for source: %s", srcAddr)
+					}
+
+					p := vmNativeTSDBProcessor{
+						filter: native.Filter{
+							Match:     c.String(vmNativeFilterMatch),
+							TimeStart: c.String(vmNativeFilterTimeStart),
+							TimeEnd:   c.String(vmNativeFilterTimeEnd),
+						},
+						src: &native.Client{
+							AuthCfg:              srcAuthConfig,
+							Addr:                 srcAddr,
+							DisableHTTPKeepAlive: c.Bool(vmNativeDisableHTTPKeepAlive),
+						},
+						outputDir: c.String(tsdbOutputDir),
+					}
+					return p.run(ctx, isNonInteractive(c))
+				},
+			},
 			{
 				Name:  "verify-block",
 				Usage: "Verifies exported block with VictoriaMetrics Native format",
@@ -22,6 +22,7 @@ type Client struct {
 	AuthCfg              *auth.Config
 	Addr                 string
 	ExtraLabels          []string
+	RelabelConfig        string
 	DisableHTTPKeepAlive bool
 }
 
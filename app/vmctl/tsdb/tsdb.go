@@ -0,0 +1,240 @@
+package tsdb
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/vm"
+)
+
+// BlockDuration is the duration of Prometheus blocks created by Builder.
+//
+// It equals to the default min block duration in Prometheus.
+const BlockDuration = 2 * time.Hour
+
+// TimeRange represents [Min ... Max) time range in milliseconds.
+type TimeRange struct {
+	Min int64
+	Max int64
+}
+
+// SplitTimeRange splits [start ... end] time range in milliseconds into ranges aligned to BlockDuration.
+//
+// The first and the last ranges are limited by start and end.
+func SplitTimeRange(start, end int64) []TimeRange {
+	if start > end {
+		return nil
+	}
+	d := BlockDuration.Milliseconds()
+	var trs []TimeRange
+	for t := start - start%d; t <= end; t += d {
+		tr := TimeRange{
+			Min: t,
+			Max: t + d,
+		}
+		if tr.Min < start {
+			tr.Min = start
+		}
+		if tr.Max > end+1 {
+			tr.Max = end + 1
+		}
+		trs = append(trs, tr)
+	}
+	return trs
+}
+
+// Stats contains the number of series and samples in a block.
+type Stats struct {
+	Series  uint64
+	Samples uint64
+}
+
+// Builder accumulates series for a single Prometheus block.
+type Builder struct {
+	tr     TimeRange
+	series map[string]*series
+}
+
+type series struct {
+	labels  labels.Labels
+	samples []sample
+}
+
+type sample struct {
+	timestamp int64
+	value     float64
+}
+
+// NewBuilder returns new Builder for the block with the given tr.
+//
+// tr must be within a single BlockDuration-aligned time range.
+func NewBuilder(tr TimeRange) *Builder {
+	return &Builder{
+		tr:     tr,
+		series: make(map[string]*series),
+	}
+}
+
+// Add adds samples from ts to b.
+//
+// Samples outside the time range for b are ignored.
+// Samples for the same series may be added in any order with multiple Add calls.
+func (b *Builder) Add(ts *vm.TimeSeries) {
+	lbls := make([]labels.Label, 0, len(ts.LabelPairs)+1)
+	lbls = append(lbls, labels.Label{
+		Name:  labels.MetricName,
+		Value: ts.Name,
+	})
+	for _, lp := range ts.LabelPairs {
+		if lp.Name == labels.MetricName || lp.Value == "" {
+			continue
+		}
+		lbls = append(lbls, labels.Label{
+			Name:  lp.Name,
+			Value: lp.Value,
+		})
+	}
+	ls := labels.New(lbls...)
+	key := ls.String()
+	s := b.series[key]
+	if s == nil {
+		s = &series{
+			labels: ls,
+		}
+		b.series[key] = s
+	}
+	for i, timestamp := range ts.Timestamps {
+		if timestamp < b.tr.Min || timestamp >= b.tr.Max {
+			continue
+		}
+		s.samples = append(s.samples, sample{
+			timestamp: timestamp,
+			value:     ts.Values[i],
+		})
+	}
+}
+
+// Write writes the block with the collected series to dir and returns its ULID and stats.
+//
+// Samples are sorted by timestamp per each series before writing.
+// Only the last sample is left for samples with identical timestamps.
+// Zero ULID is returned if b contains no samples.
+func (b *Builder) Write(ctx context.Context, dir string) (ulid.ULID, Stats, error) {
+	var st Stats
+	keys := make([]string, 0, len(b.series))
+	for key, s := range b.series {
+		if len(s.samples) == 0 {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return ulid.ULID{}, st, nil
+	}
+	sort.Strings(keys)
+
+	w, err := tsdb.NewBlockWriter(log.NewNopLogger(), dir, BlockDuration.Milliseconds())
+	if err != nil {
+		return ulid.ULID{}, st, fmt.Errorf("cannot create block writer: %w", err)
+	}
+	defer func() {
+		_ = w.Close()
+	}()
+	app := w.Appender(ctx)
+	for i, key := range keys {
+		s := b.series[key]
+		samples := s.samples
+		sort.SliceStable(samples, func(i, j int) bool {
+			return samples[i].timestamp < samples[j].timestamp
+		})
+		st.Series++
+		for j, smpl := range samples {
+			if j+1 < len(samples) && samples[j+1].timestamp == smpl.timestamp {
+				// Leave only the last sample for identical timestamps.
+				continue
+			}
+			if _, err := app.Append(0, s.labels, smpl.timestamp, smpl.value); err != nil {
+				_ = app.Rollback()
+				return ulid.ULID{}, st, fmt.Errorf("cannot add sample for series %s: %w", key, err)
+			}
+			st.Samples++
+		}
+		// Release memory occupied by the written series.
+		delete(b.series, key)
+		if (i+1)%1000 == 0 {
+			if err := app.Commit(); err != nil {
+				return ulid.ULID{}, st, fmt.Errorf("cannot commit samples: %w", err)
+			}
+			app = w.Appender(ctx)
+		}
+	}
+	if err := app.Commit(); err != nil {
+		return ulid.ULID{}, st, fmt.Errorf("cannot commit samples: %w", err)
+	}
+	id, err := w.Flush(ctx)
+	if err != nil {
+		return ulid.ULID{}, st, fmt.Errorf("cannot flush block: %w", err)
+	}
+	return id, st, nil
+}
+
+// Verify opens the block with the given id at dir via Prometheus TSDB reader
+// and verifies it contains the expected number of series and samples.
+func Verify(dir string, id ulid.ULID, stExpected Stats) error {
+	path := filepath.Join(dir, id.String())
+	b, err := tsdb.OpenBlock(nil, path, nil)
+	if err != nil {
+		return fmt.Errorf("cannot open block %q: %w", path, err)
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+	meta := b.Meta()
+	if meta.Stats.NumSeries != stExpected.Series || meta.Stats.NumSamples != stExpected.Samples {
+		return fmt.Errorf("unexpected stats in meta.json for block %q; got %d series and %d samples; want %d series and %d samples",
+			path, meta.Stats.NumSeries, meta.Stats.NumSamples, stExpected.Series, stExpected.Samples)
+	}
+	q, err := tsdb.NewBlockQuerier(b, meta.MinTime, meta.MaxTime)
+	if err != nil {
+		return fmt.Errorf("cannot create querier for block %q: %w", path, err)
+	}
+	defer func() {
+		_ = q.Close()
+	}()
+	var st Stats
+	var it chunkenc.Iterator
+	ss := q.Select(false, nil, labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, ".+"))
+	for ss.Next() {
+		st.Series++
+		prevTimestamp := int64(-1 << 63)
+		it = ss.At().Iterator(it)
+		for it.Next() != chunkenc.ValNone {
+			timestamp, _ := it.At()
+			if timestamp <= prevTimestamp {
+				return fmt.Errorf("unexpected order of samples for series %s in block %q", ss.At().Labels(), path)
+			}
+			prevTimestamp = timestamp
+			st.Samples++
+		}
+		if err := it.Err(); err != nil {
+			return fmt.Errorf("cannot read samples from block %q: %w", path, err)
+		}
+	}
+	if err := ss.Err(); err != nil {
+		return fmt.Errorf("cannot read series from block %q: %w", path, err)
+	}
+	if st != stExpected {
+		return fmt.Errorf("unexpected data in block %q; got %d series and %d samples; want %d series and %d samples",
+			path, st.Series, st.Samples, stExpected.Series, stExpected.Samples)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package tsdb
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/vm"
+)
+
+func TestSplitTimeRange(t *testing.T) {
+	d := BlockDuration.Milliseconds()
+	tests := []struct {
+		name       string
+		start, end int64
+		want       []TimeRange
+	}{
+		{
+			name:  "start after end",
+			start: 10,
+			end:   0,
+			want:  nil,
+		},
+		{
+			name:  "single range",
+			start: 10,
+			end:   20,
+			want:  []TimeRange{{Min: 10, Max: 21}},
+		},
+		{
+			name:  "aligned ranges",
+			start: 0,
+			end:   2*d - 1,
+			want:  []TimeRange{{Min: 0, Max: d}, {Min: d, Max: 2 * d}},
+		},
+		{
+			name:  "unaligned ranges",
+			start: d / 2,
+			end:   2*d + 5,
+			want:  []TimeRange{{Min: d / 2, Max: d}, {Min: d, Max: 2 * d}, {Min: 2 * d, Max: 2*d + 6}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitTimeRange(tt.start, tt.end)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitTimeRange() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuilderWriteVerify(t *testing.T) {
+	dir, err := os.MkdirTemp("", "vmctl-tsdb-")
+	if err != nil {
+		t.Fatalf("cannot create temporary dir: %s", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	tr := TimeRange{Min: start, Max: start + BlockDuration.Milliseconds()}
+	b := NewBuilder(tr)
+	b.Add(&vm.TimeSeries{
+		Name:       "foo",
+		LabelPairs: []vm.LabelPair{{Name: "job", Value: "bar"}, {Name: "empty", Value: ""}},
+		Timestamps: []int64{start + 3000, start + 1000, start - 1000},
+		Values:     []float64{3, 1, -1},
+	})
+	// The same series with out of order and duplicate samples.
+	b.Add(&vm.TimeSeries{
+		Name:       "foo",
+		LabelPairs: []vm.LabelPair{{Name: "job", Value: "bar"}},
+		Timestamps: []int64{start + 2000, start + 3000, tr.Max},
+		Values:     []float64{2, 4, 5},
+	})
+	b.Add(&vm.TimeSeries{
+		Name:       "baz",
+		Timestamps: []int64{start},
+		Values:     []float64{10},
+	})
+	// The series without samples in the time range must be skipped.
+	b.Add(&vm.TimeSeries{
+		Name:       "outside",
+		Timestamps: []int64{tr.Max + 1},
+		Values:     []float64{1},
+	})
+
+	id, st, err := b.Write(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	stExpected := Stats{Series: 2, Samples: 4}
+	if st != stExpected {
+		t.Fatalf("unexpected stats; got %+v; want %+v", st, stExpected)
+	}
+	if err := Verify(dir, id, st); err != nil {
+		t.Fatalf("unexpected verification error: %s", err)
+	}
+	if err := Verify(dir, id, Stats{Series: 2, Samples: 5}); err == nil {
+		t.Fatalf("expecting non-nil verification error for wrong stats")
+	}
+
+	// Empty builder must not create blocks.
+	id, st, err = NewBuilder(tr).Write(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if st.Samples != 0 || id != (ulid.ULID{}) {
+		t.Fatalf("unexpected block %s with stats %+v for empty builder", id, st)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"bufio"
 	"compress/gzip"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +19,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/barpool"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/limiter"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 	"github.com/cheggaaa/pb/v3"
 )
 
@@ -108,6 +112,33 @@ func AddExtraLabelsToImportPath(path string, extraLabels []string) (string, erro
 	return dst, nil
 }
 
+// AddRelabelConfigToImportPath - adds relabel_config query param to given url path.
+//
+// relabelConfig may contain either the name of relabeling config configured at the destination
+// via -import.namedRelabelConfig or the path to local file with relabeling config.
+// The local file is sent to the destination in base64-encoded form.
+func AddRelabelConfigToImportPath(path, relabelConfig string) (string, error) {
+	if relabelConfig == "" {
+		return path, nil
+	}
+	arg := relabelConfig
+	if fi, err := os.Stat(relabelConfig); err == nil && !fi.IsDir() {
+		data, err := os.ReadFile(relabelConfig)
+		if err != nil {
+			return path, fmt.Errorf("cannot read relabel config: %w", err)
+		}
+		if _, err := promrelabel.ParseRelabelConfigsData(data); err != nil {
+			return path, fmt.Errorf("cannot parse relabel config %q: %w", relabelConfig, err)
+		}
+		arg = base64.StdEncoding.EncodeToString(data)
+	}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%srelabel_config=%s", path, separator, url.QueryEscape(arg)), nil
+}
+
 // NewImporter creates new Importer for the given cfg.
 func NewImporter(ctx context.Context, cfg Config) (*Importer, error) {
 	if cfg.Concurrency < 1 {
@@ -1,6 +1,10 @@
 package vm
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestAddExtraLabelsToImportPath(t *testing.T) {
 	type args struct {
@@ -67,3 +71,34 @@ func TestAddExtraLabelsToImportPath(t *testing.T) {
 		})
 	}
 }
+
+func TestAddRelabelConfigToImportPath(t *testing.T) {
+	f := func(path, relabelConfig, want string) {
+		t.Helper()
+		got, err := AddRelabelConfigToImportPath(path, relabelConfig)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got != want {
+			t.Fatalf("unexpected import path; got %q; want %q", got, want)
+		}
+	}
+	f("/api/v1/import/native", "", "/api/v1/import/native")
+	f("/api/v1/import/native", "env-rewrite", "/api/v1/import/native?relabel_config=env-rewrite")
+	f("/api/v1/import/native?extra_label=job=vmagent", "env-rewrite", "/api/v1/import/native?extra_label=job=vmagent&relabel_config=env-rewrite")
+
+	// local file must be sent in base64-encoded form
+	path := filepath.Join(t.TempDir(), "relabel.yml")
+	if err := os.WriteFile(path, []byte("- action: labeldrop\n  regex: pod\n"), 0644); err != nil {
+		t.Fatalf("cannot write relabel config: %s", err)
+	}
+	f("/api/v1/import/native", path, "/api/v1/import/native?relabel_config=LSBhY3Rpb246IGxhYmVsZHJvcAogIHJlZ2V4OiBwb2QK")
+
+	// invalid local file
+	if err := os.WriteFile(path, []byte("- action: foobar\n"), 0644); err != nil {
+		t.Fatalf("cannot write relabel config: %s", err)
+	}
+	if _, err := AddRelabelConfigToImportPath("/api/v1/import/native", path); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
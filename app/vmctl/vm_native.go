@@ -157,6 +157,10 @@ func (p *vmNativeProcessor) runBackfilling(ctx context.Context, tenantID string,
 	if err != nil {
 		return fmt.Errorf("failed to add labels to import path: %s", err)
 	}
+	importAddr, err = vm.AddRelabelConfigToImportPath(importAddr, p.dst.RelabelConfig)
+	if err != nil {
+		return fmt.Errorf("failed to add relabel config to import path: %s", err)
+	}
 	dstURL := fmt.Sprintf("%s/%s", p.dst.Addr, importAddr)
 
 	if p.interCluster {
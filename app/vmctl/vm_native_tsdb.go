@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/tsdb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/vm"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/vmimport"
+	"github.com/oklog/ulid"
+)
+
+// vmNativeTSDBProcessor exports time series from VictoriaMetrics via /api/v1/export
+// and writes them into Prometheus TSDB blocks.
+type vmNativeTSDBProcessor struct {
+	filter native.Filter
+
+	src       *native.Client
+	outputDir string
+}
+
+const jsonExportAddr = "api/v1/export"
+
+type writtenBlock struct {
+	id ulid.ULID
+	st tsdb.Stats
+}
+
+func (p *vmNativeTSDBProcessor) run(ctx context.Context, silent bool) error {
+	start, err := time.Parse(time.RFC3339, p.filter.TimeStart)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s, provided: %s, expected format: %s, error: %w",
+			vmNativeFilterTimeStart, p.filter.TimeStart, time.RFC3339, err)
+	}
+	end := time.Now().In(start.Location())
+	if p.filter.TimeEnd != "" {
+		end, err = time.Parse(time.RFC3339, p.filter.TimeEnd)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s, provided: %s, expected format: %s, error: %w",
+				vmNativeFilterTimeEnd, p.filter.TimeEnd, time.RFC3339, err)
+		}
+	}
+	trs := tsdb.SplitTimeRange(start.UnixMilli(), end.UnixMilli())
+	if len(trs) == 0 {
+		return fmt.Errorf("%s=%s must be smaller than %s=%s", vmNativeFilterTimeStart, p.filter.TimeStart, vmNativeFilterTimeEnd, p.filter.TimeEnd)
+	}
+
+	question := fmt.Sprintf("Export data from %q with %s\n into up to %d Prometheus TSDB blocks at %q. Continue?", p.src.Addr, p.filter, len(trs), p.outputDir)
+	if !silent && !prompt(question) {
+		return nil
+	}
+	if err := os.MkdirAll(p.outputDir, 0755); err != nil {
+		return fmt.Errorf("cannot create %s=%q: %w", tsdbOutputDir, p.outputDir, err)
+	}
+
+	var blocks []writtenBlock
+	var samples uint64
+	for _, tr := range trs {
+		// Process a single block at a time in order to limit memory usage.
+		b, err := p.export(ctx, tr)
+		if err != nil {
+			return err
+		}
+		id, bst, err := b.Write(ctx, p.outputDir)
+		if err != nil {
+			return fmt.Errorf("cannot write block for time range [%s ... %s): %w", formatMillis(tr.Min), formatMillis(tr.Max), err)
+		}
+		if bst.Samples == 0 {
+			log.Printf("no samples found for time range [%s ... %s); skipping it", formatMillis(tr.Min), formatMillis(tr.Max))
+			continue
+		}
+		log.Printf("written block %s for time range [%s ... %s) with %d series and %d samples",
+			id, formatMillis(tr.Min), formatMillis(tr.Max), bst.Series, bst.Samples)
+		blocks = append(blocks, writtenBlock{
+			id: id,
+			st: bst,
+		})
+		samples += bst.Samples
+	}
+
+	log.Printf("verifying %d written blocks", len(blocks))
+	for _, wb := range blocks {
+		if err := tsdb.Verify(p.outputDir, wb.id, wb.st); err != nil {
+			return fmt.Errorf("verification failed: %w", err)
+		}
+	}
+	log.Printf("Export finished! Written %d blocks with %d samples in total to %q", len(blocks), samples, p.outputDir)
+	return nil
+}
+
+// export reads series for the given tr from p.src and returns them in tsdb.Builder.
+func (p *vmNativeTSDBProcessor) export(ctx context.Context, tr tsdb.TimeRange) (*tsdb.Builder, error) {
+	f := p.filter
+	f.TimeStart = formatUnixSeconds(tr.Min)
+	// The end of the time range is inclusive in /api/v1/export.
+	f.TimeEnd = formatUnixSeconds(tr.Max - 1)
+	exportURL := fmt.Sprintf("%s/%s", p.src.Addr, jsonExportAddr)
+	r, err := p.src.ExportPipe(ctx, exportURL, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init export pipe: %w", err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	b := tsdb.NewBuilder(tr)
+	if err := parseJSONExport(r, b.Add); err != nil {
+		return nil, fmt.Errorf("cannot read export response for time range [%s ... %s): %w", formatMillis(tr.Min), formatMillis(tr.Max), err)
+	}
+	return b, nil
+}
+
+// parseJSONExport parses time series in JSON line format from r and calls callback for each parsed time series.
+//
+// See https://docs.victoriametrics.com/#how-to-export-data-in-json-line-format
+func parseJSONExport(r io.Reader, callback func(ts *vm.TimeSeries)) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var rows vmimport.Rows
+	var ts vm.TimeSeries
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			rows.Unmarshal(line)
+			for i := range rows.Rows {
+				row := &rows.Rows[i]
+				ts.Name = ""
+				ts.LabelPairs = ts.LabelPairs[:0]
+				for _, tag := range row.Tags {
+					if string(tag.Key) == "__name__" {
+						ts.Name = string(tag.Value)
+						continue
+					}
+					ts.LabelPairs = append(ts.LabelPairs, vm.LabelPair{
+						Name:  string(tag.Key),
+						Value: string(tag.Value),
+					})
+				}
+				ts.Timestamps = row.Timestamps
+				ts.Values = row.Values
+				callback(&ts)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func formatUnixSeconds(ms int64) string {
+	return strconv.FormatFloat(float64(ms)/1e3, 'f', 3, 64)
+}
+
+func formatMillis(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339)
+}
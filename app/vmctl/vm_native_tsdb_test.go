@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/vm"
+	"github.com/prometheus/prometheus/tsdb"
+)
+
+func Test_parseJSONExport(t *testing.T) {
+	data := `{"metric":{"__name__":"foo","job":"bar"},"values":[1,2],"timestamps":[1000,2000]}
+{"metric":{"__name__":"baz"},"values":[3],"timestamps":[3000]}`
+	var got []string
+	err := parseJSONExport(strings.NewReader(data), func(ts *vm.TimeSeries) {
+		got = append(got, fmt.Sprintf("%s %v %v", ts, ts.Timestamps, ts.Values))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{`foo{job="bar"} [1000 2000] [1 2]`, `baz [3000] [3]`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected series;\ngot\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func Test_vmNativeTSDBProcessor_run(t *testing.T) {
+	// Samples span two 2h blocks: 2023-01-01T01:00:00Z and 2023-01-01T02:00:00Z.
+	const (
+		t1 = 1672534800000
+		t2 = 1672538400000
+	)
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/export" {
+			t.Errorf("unexpected request path %q", r.URL.Path)
+		}
+		start, end := r.FormValue("start"), r.FormValue("end")
+		requests = append(requests, start+"-"+end)
+		// Return all the samples and rely on filtering at the client side.
+		fmt.Fprintf(w, `{"metric":{"__name__":"foo","job":"bar"},"values":[2,1],"timestamps":[%d,%d]}`+"\n", t1+1000, t1)
+		fmt.Fprintf(w, `{"metric":{"__name__":"foo","job":"bar"},"values":[3],"timestamps":[%d]}`+"\n", t2)
+	}))
+	defer srv.Close()
+
+	dir, err := os.MkdirTemp("", "vmctl-vm-native-to-tsdb-")
+	if err != nil {
+		t.Fatalf("cannot create temporary dir: %s", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	p := vmNativeTSDBProcessor{
+		filter: native.Filter{
+			Match:     `{__name__="foo"}`,
+			TimeStart: "2023-01-01T01:00:00Z",
+			TimeEnd:   "2023-01-01T02:30:00Z",
+		},
+		src:       &native.Client{Addr: srv.URL},
+		outputDir: filepath.Join(dir, "blocks"),
+	}
+	if err := p.run(context.Background(), true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	requestsExpected := []string{"1672534800.000-1672538399.999", "1672538400.000-1672540200.000"}
+	if strings.Join(requests, ",") != strings.Join(requestsExpected, ",") {
+		t.Fatalf("unexpected export requests; got %v; want %v", requests, requestsExpected)
+	}
+
+	db, err := tsdb.OpenDBReadOnly(p.outputDir, nil)
+	if err != nil {
+		t.Fatalf("cannot open written blocks: %s", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	blocks, err := db.Blocks()
+	if err != nil {
+		t.Fatalf("cannot read blocks: %s", err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("unexpected number of blocks; got %d; want 2", len(blocks))
+	}
+	var samples uint64
+	for _, b := range blocks {
+		samples += b.Meta().Stats.NumSamples
+	}
+	if samples != 3 {
+		t.Fatalf("unexpected number of samples; got %d; want 3", samples)
+	}
+}
@@ -0,0 +1,223 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+const (
+	defaultIngestSampleDuration = 30 * time.Second
+	maxIngestSampleDuration     = 5 * time.Minute
+	defaultIngestSampleLimit    = 100
+	maxIngestSampleLimit        = 10000
+)
+
+// ingestSamplerActive is set to 1 while ingestion sampling session is active.
+//
+// It is checked per each flushed batch of rows, so the sampling costs nothing when it is inactive.
+var ingestSamplerActive uint32
+
+var ingestSamplerCurrent atomic.Value
+
+// ingestSampleSessionCh limits the number of concurrent ingestion sampling sessions to one.
+var ingestSampleSessionCh = make(chan struct{}, 1)
+
+// ingestSampler captures up to limit rows matching filters.
+type ingestSampler struct {
+	filters []*promrelabel.IfExpression
+	limit   int
+
+	mu     sync.Mutex
+	rows   []sampledRow
+	doneCh chan struct{}
+}
+
+// sampledRow is a row captured by ingestSampler.
+type sampledRow struct {
+	Metric     map[string]string `json:"metric"`
+	Timestamp  int64             `json:"timestamp"`
+	Value      string            `json:"value"`
+	Protocol   string            `json:"protocol"`
+	RemoteAddr string            `json:"remoteAddr,omitempty"`
+}
+
+// captureRows passes rows from ctx to the active ingestion sampling session.
+func (ctx *InsertCtx) captureRows() {
+	is := ingestSamplerCurrent.Load().(*ingestSampler)
+	var mn storage.MetricName
+	var labels []prompbmarshal.Label
+	for i := range ctx.mrs {
+		mr := &ctx.mrs[i]
+		if err := mn.UnmarshalRaw(mr.MetricNameRaw); err != nil {
+			continue
+		}
+		labels = append(labels[:0], prompbmarshal.Label{
+			Name:  "__name__",
+			Value: string(mn.MetricGroup),
+		})
+		for j := range mn.Tags {
+			tag := &mn.Tags[j]
+			labels = append(labels, prompbmarshal.Label{
+				Name:  string(tag.Key),
+				Value: string(tag.Value),
+			})
+		}
+		if !is.add(labels, mr, ctx.protocol, ctx.remoteAddr) {
+			return
+		}
+	}
+}
+
+// add adds the row with the given labels to is if it matches is filters.
+//
+// false is returned if is doesn't accept new rows.
+func (is *ingestSampler) add(labels []prompbmarshal.Label, mr *storage.MetricRow, protocol, remoteAddr string) bool {
+	matched := false
+	for _, ie := range is.filters {
+		if ie.Match(labels) {
+			matched = true
+			break
+		}
+	}
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	if len(is.rows) >= is.limit {
+		return false
+	}
+	if !matched {
+		return true
+	}
+	m := make(map[string]string, len(labels))
+	for _, label := range labels {
+		if label.Value != "" {
+			m[label.Name] = label.Value
+		}
+	}
+	is.rows = append(is.rows, sampledRow{
+		Metric:     m,
+		Timestamp:  mr.Timestamp,
+		Value:      strconv.FormatFloat(mr.Value, 'g', -1, 64),
+		Protocol:   protocol,
+		RemoteAddr: remoteAddr,
+	})
+	if len(is.rows) >= is.limit {
+		close(is.doneCh)
+	}
+	return true
+}
+
+// IngestSampleHandler processes /debug/ingest/sample request.
+//
+// It captures up to `limit` rows matching `match[]` selectors during the given `duration`
+// after relabeling is applied to them and returns the captured rows in JSON.
+func IngestSampleHandler(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("cannot parse request form values: %w", err)
+	}
+	matches := r.Form["match[]"]
+	if len(matches) == 0 {
+		return fmt.Errorf("missing `match[]` query arg")
+	}
+	filters := make([]*promrelabel.IfExpression, 0, len(matches))
+	for _, match := range matches {
+		var ie promrelabel.IfExpression
+		if err := ie.Parse(match); err != nil {
+			return fmt.Errorf("cannot parse `match[]`=%q: %w", match, err)
+		}
+		filters = append(filters, &ie)
+	}
+	duration := defaultIngestSampleDuration
+	if s := r.FormValue("duration"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("cannot parse `duration`=%q: %w", s, err)
+		}
+		if d <= 0 || d > maxIngestSampleDuration {
+			return fmt.Errorf("`duration` must be in the range (0..%s]; got %s", maxIngestSampleDuration, d)
+		}
+		duration = d
+	}
+	limit := defaultIngestSampleLimit
+	if s := r.FormValue("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("cannot parse `limit`=%q: %w", s, err)
+		}
+		if n <= 0 || n > maxIngestSampleLimit {
+			return fmt.Errorf("`limit` must be in the range [1..%d]; got %d", maxIngestSampleLimit, n)
+		}
+		limit = n
+	}
+
+	select {
+	case ingestSampleSessionCh <- struct{}{}:
+	default:
+		return &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("another ingestion sampling session is already running; try again later"),
+			StatusCode: http.StatusTooManyRequests,
+		}
+	}
+	defer func() {
+		<-ingestSampleSessionCh
+	}()
+
+	is := &ingestSampler{
+		filters: filters,
+		limit:   limit,
+		doneCh:  make(chan struct{}),
+	}
+	ingestSamplerCurrent.Store(is)
+	atomic.StoreUint32(&ingestSamplerActive, 1)
+	ingestSampleSessions.Inc()
+	t := time.NewTimer(duration)
+	select {
+	case <-is.doneCh:
+		t.Stop()
+	case <-t.C:
+	case <-r.Context().Done():
+		t.Stop()
+	}
+	atomic.StoreUint32(&ingestSamplerActive, 0)
+
+	is.mu.Lock()
+	rows := is.rows
+	is.limit = 0
+	is.mu.Unlock()
+	if rows == nil {
+		rows = []sampledRow{}
+	}
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return fmt.Errorf("cannot marshal sampled rows: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	return err
+}
+
+var ingestSampleSessions = metrics.NewCounter(`vm_ingest_sample_sessions_total`)
+
+// GetRemoteAddr returns the remote address for r if it is a network connection.
+//
+// Empty string is returned otherwise.
+func GetRemoteAddr(r io.Reader) string {
+	c, ok := r.(net.Conn)
+	if !ok {
+		return ""
+	}
+	return c.RemoteAddr().String()
+}
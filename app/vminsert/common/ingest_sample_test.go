@@ -0,0 +1,186 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+)
+
+type ingestSampleSession struct {
+	w      *httptest.ResponseRecorder
+	doneCh chan error
+}
+
+func startIngestSampleSession(t *testing.T, ctx context.Context, args url.Values) *ingestSampleSession {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/debug/ingest/sample?"+args.Encode(), nil).WithContext(ctx)
+	iss := &ingestSampleSession{
+		w:      httptest.NewRecorder(),
+		doneCh: make(chan error, 1),
+	}
+	go func() {
+		iss.doneCh <- IngestSampleHandler(iss.w, r)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadUint32(&ingestSamplerActive) == 0 {
+		select {
+		case err := <-iss.doneCh:
+			t.Fatalf("the ingestion sampling session finished unexpectedly: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for the ingestion sampling session start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return iss
+}
+
+func (iss *ingestSampleSession) wait(t *testing.T) []sampledRow {
+	t.Helper()
+	select {
+	case err := <-iss.doneCh:
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout when waiting for the ingestion sampling session to finish")
+	}
+	if atomic.LoadUint32(&ingestSamplerActive) != 0 {
+		t.Fatalf("the ingestion sampling must be inactive after the session is finished")
+	}
+	if ct := iss.w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected Content-Type; got %q; want %q", ct, "application/json")
+	}
+	var rows []sampledRow
+	if err := json.Unmarshal(iss.w.Body.Bytes(), &rows); err != nil {
+		t.Fatalf("cannot unmarshal response %q: %s", iss.w.Body.String(), err)
+	}
+	return rows
+}
+
+// writeIngestSampleRows passes rows with the given metric names and job label to the active ingestion sampling session.
+func writeIngestSampleRows(job string, names ...string) {
+	var ctx InsertCtx
+	ctx.Reset(len(names))
+	ctx.SetSource("test", "1.2.3.4:5678")
+	for i, name := range names {
+		ctx.Labels = ctx.Labels[:0]
+		ctx.AddLabel("", name)
+		ctx.AddLabel("job", job)
+		_ = ctx.WriteDataPoint(nil, ctx.Labels, int64(i+1)*1000, float64(i)+0.5)
+	}
+	if atomic.LoadUint32(&ingestSamplerActive) != 0 {
+		ctx.captureRows()
+	}
+}
+
+func TestIngestSampleHandlerCaptureAndLimit(t *testing.T) {
+	args := url.Values{
+		"match[]":  {`{job="foo"}`},
+		"duration": {"1m"},
+		"limit":    {"2"},
+	}
+	iss := startIngestSampleSession(t, context.Background(), args)
+
+	// Only a single session may run at a time.
+	r := httptest.NewRequest(http.MethodGet, "/debug/ingest/sample?"+args.Encode(), nil)
+	err := IngestSampleHandler(httptest.NewRecorder(), r)
+	var esc *httpserver.ErrorWithStatusCode
+	if !errors.As(err, &esc) || esc.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expecting error with status code %d for concurrent session; got %v", http.StatusTooManyRequests, err)
+	}
+
+	// Rows not matching the filters mustn't be captured.
+	writeIngestSampleRows("bar", "metric_a")
+	// The session must be finished after capturing limit rows.
+	writeIngestSampleRows("foo", "metric_b", "metric_c", "metric_d")
+	rows := iss.wait(t)
+	if len(rows) != 2 {
+		t.Fatalf("unexpected number of captured rows; got %d; want 2; rows: %+v", len(rows), rows)
+	}
+	row := rows[0]
+	if row.Metric["__name__"] != "metric_b" || row.Metric["job"] != "foo" || row.Timestamp != 1000 || row.Value != "0.5" {
+		t.Fatalf("unexpected first row: %+v", row)
+	}
+	if row.Protocol != "test" || row.RemoteAddr != "1.2.3.4:5678" {
+		t.Fatalf("unexpected source for the first row: %+v", row)
+	}
+	if rows[1].Metric["__name__"] != "metric_c" {
+		t.Fatalf("unexpected second row: %+v", rows[1])
+	}
+
+	// Rows mustn't be captured after the session is finished.
+	is := ingestSamplerCurrent.Load().(*ingestSampler)
+	writeIngestSampleRows("foo", "metric_e")
+	if n := len(is.rows); n != 2 {
+		t.Fatalf("unexpected number of rows after the session is finished; got %d; want 2", n)
+	}
+}
+
+func TestIngestSampleHandlerStop(t *testing.T) {
+	// The session must be stopped after the given duration.
+	iss := startIngestSampleSession(t, context.Background(), url.Values{
+		"match[]":  {`{job="foo"}`},
+		"duration": {"1s"},
+	})
+	writeIngestSampleRows("foo", "metric_a")
+	rows := iss.wait(t)
+	if len(rows) != 1 || rows[0].Metric["__name__"] != "metric_a" {
+		t.Fatalf("unexpected rows captured until the duration passes: %+v", rows)
+	}
+
+	// The session must be stopped when the client closes the request.
+	ctx, cancel := context.WithCancel(context.Background())
+	iss = startIngestSampleSession(t, ctx, url.Values{
+		"match[]":  {`{job="foo"}`},
+		"duration": {"5m"},
+	})
+	cancel()
+	rows = iss.wait(t)
+	if len(rows) != 0 {
+		t.Fatalf("expecting empty rows; got %+v", rows)
+	}
+	if s := iss.w.Body.String(); s != "[]" {
+		t.Fatalf("unexpected response for empty rows; got %q; want %q", s, "[]")
+	}
+
+	// A new session can be started after the previous session is stopped.
+	iss = startIngestSampleSession(t, context.Background(), url.Values{
+		"match[]": {`{job="foo"}`},
+		"limit":   {"1"},
+	})
+	writeIngestSampleRows("foo", "metric_b")
+	if rows := iss.wait(t); len(rows) != 1 {
+		t.Fatalf("unexpected number of rows for the new session; got %d; want 1", len(rows))
+	}
+}
+
+func TestIngestSampleHandlerFailure(t *testing.T) {
+	f := func(args url.Values) {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/debug/ingest/sample?"+args.Encode(), nil)
+		if err := IngestSampleHandler(httptest.NewRecorder(), r); err == nil {
+			t.Fatalf("expecting non-nil error for args %q", args.Encode())
+		}
+		if atomic.LoadUint32(&ingestSamplerActive) != 0 {
+			t.Fatalf("the ingestion sampling mustn't be started for args %q", args.Encode())
+		}
+	}
+	f(url.Values{})
+	f(url.Values{"match[]": {`{job=~"foo`}})
+	f(url.Values{"match[]": {`{job="foo"}`}, "duration": {"foo"}})
+	f(url.Values{"match[]": {`{job="foo"}`}, "duration": {"0s"}})
+	f(url.Values{"match[]": {`{job="foo"}`}, "duration": {"10m"}})
+	f(url.Values{"match[]": {`{job="foo"}`}, "limit": {"foo"}})
+	f(url.Values{"match[]": {`{job="foo"}`}, "limit": {"0"}})
+	f(url.Values{"match[]": {`{job="foo"}`}, "limit": {"100000"}})
+}
@@ -3,12 +3,14 @@ package common
 import (
 	"fmt"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/relabel"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
 
@@ -23,6 +25,13 @@ type InsertCtx struct {
 	streamAggrCtx streamAggrCtx
 
 	skipStreamAggr bool
+
+	// protocol and remoteAddr identify the source of rows for /debug/ingest/sample handler.
+	protocol   string
+	remoteAddr string
+
+	// rowsStats collects stats for rows of the currently processed request.
+	rowsStats *RowsStats
 }
 
 // Reset resets ctx for future fill with rowsLen rows.
@@ -130,17 +139,41 @@ func (ctx *InsertCtx) AddLabel(name, value string) {
 	})
 }
 
+// SetSource sets the protocol and the remote address for rows added to ctx.
+//
+// The source is returned by /debug/ingest/sample handler for the captured rows.
+func (ctx *InsertCtx) SetSource(protocol, remoteAddr string) {
+	ctx.protocol = protocol
+	ctx.remoteAddr = remoteAddr
+}
+
+// SetRowsStats sets rs for collecting stats for rows added to ctx.
+//
+// rs may be nil if there is no need in collecting stats.
+func (ctx *InsertCtx) SetRowsStats(rs *RowsStats) {
+	ctx.rowsStats = rs
+}
+
 // ApplyRelabeling applies relabeling to ic.Labels.
 func (ctx *InsertCtx) ApplyRelabeling() {
 	ctx.Labels = ctx.relabelCtx.ApplyRelabeling(ctx.Labels)
 }
 
+// ApplyRelabelingWithConfigs applies the given pcs to ic.Labels.
+func (ctx *InsertCtx) ApplyRelabelingWithConfigs(pcs *promrelabel.ParsedConfigs) {
+	ctx.Labels = ctx.relabelCtx.ApplyRelabelingWithConfigs(ctx.Labels, pcs)
+}
+
 // FlushBufs flushes buffered rows to the underlying storage.
 func (ctx *InsertCtx) FlushBufs() error {
+	if atomic.LoadUint32(&ingestSamplerActive) != 0 {
+		ctx.captureRows()
+	}
 	sas := sasGlobal.Load()
 	if sas != nil && !ctx.skipStreamAggr {
 		ctx.streamAggrCtx.push(ctx.mrs)
 		if !*streamAggrKeepInput {
+			ctx.rowsStats.addAggregatedRows(len(ctx.mrs))
 			ctx.Reset(0)
 			return nil
 		}
@@ -148,9 +181,11 @@ func (ctx *InsertCtx) FlushBufs() error {
 	// There is no need in limiting the number of concurrent calls to vmstorage.AddRows() here,
 	// since the number of concurrent FlushBufs() calls should be already limited via writeconcurrencylimiter
 	// used at every stream.Parse() call under lib/protoparser/*
-	err := vmstorage.AddRows(ctx.mrs)
+	var ars storage.AddRowsStats
+	err := vmstorage.AddRowsWithStats(ctx.mrs, &ars)
 	ctx.Reset(0)
 	if err == nil {
+		ctx.rowsStats.addStorageStats(&ars)
 		return nil
 	}
 	return &httpserver.ErrorWithStatusCode{
@@ -0,0 +1,148 @@
+package common
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+var legacyResponses = flag.Bool("insert.legacyResponses", false, "Whether to respond with 2xx status code without X-VM-Rows-* headers to all the successfully parsed "+
+	"data ingestion requests, including requests where all the rows were rejected because of invalid timestamps or values. "+
+	"See https://docs.victoriametrics.com/#ingestion-responses")
+
+// RowsStats holds stats for rows ingested via a single data ingestion request.
+//
+// It is safe calling RowsStats methods from concurrently running goroutines.
+// All the methods are safe to call on nil RowsStats, e.g. for data ingested via TCP and UDP protocols.
+type RowsStats struct {
+	rowsReceived uint64
+
+	rowsWritten    uint64
+	rowsAggregated uint64
+
+	invalidTimestampRows uint64
+	invalidValueRows     uint64
+	seriesLimitRows      uint64
+	otherDroppedRows     uint64
+}
+
+// AddReceivedRows adds n to the number of rows received in the request.
+func (rs *RowsStats) AddReceivedRows(n int) {
+	if rs != nil {
+		atomic.AddUint64(&rs.rowsReceived, uint64(n))
+	}
+}
+
+func (rs *RowsStats) addAggregatedRows(n int) {
+	if rs != nil {
+		atomic.AddUint64(&rs.rowsAggregated, uint64(n))
+	}
+}
+
+func (rs *RowsStats) addStorageStats(ars *storage.AddRowsStats) {
+	if rs == nil {
+		return
+	}
+	atomic.AddUint64(&rs.rowsWritten, uint64(ars.RowsAdded))
+	atomic.AddUint64(&rs.invalidTimestampRows, uint64(ars.InvalidTimestampRows))
+	atomic.AddUint64(&rs.invalidValueRows, uint64(ars.InvalidValueRows))
+	atomic.AddUint64(&rs.seriesLimitRows, uint64(ars.SeriesLimitRows))
+	atomic.AddUint64(&rs.otherDroppedRows, uint64(ars.OtherDroppedRows))
+}
+
+// droppedRowsReason is the number of rows dropped because of the given reason.
+type droppedRowsReason struct {
+	reason string
+	rows   uint64
+}
+
+// getDroppedRows returns the number of dropped rows per each reason with non-zero number of dropped rows.
+func (rs *RowsStats) getDroppedRows() []droppedRowsReason {
+	if rs == nil {
+		return nil
+	}
+	rowsWritten := atomic.LoadUint64(&rs.rowsWritten)
+	drs := []droppedRowsReason{
+		{"stream_aggregation", atomic.LoadUint64(&rs.rowsAggregated)},
+		{"invalid_timestamp", atomic.LoadUint64(&rs.invalidTimestampRows)},
+		{"invalid_value", atomic.LoadUint64(&rs.invalidValueRows)},
+		{"series_limit", atomic.LoadUint64(&rs.seriesLimitRows)},
+		{"other", atomic.LoadUint64(&rs.otherDroppedRows)},
+	}
+	rowsProcessed := rowsWritten
+	for _, dr := range drs {
+		rowsProcessed += dr.rows
+	}
+	// Rows, which didn't reach the storage, are dropped by relabeling or because of missing labels.
+	var relabelingRows uint64
+	if rowsReceived := atomic.LoadUint64(&rs.rowsReceived); rowsReceived > rowsProcessed {
+		relabelingRows = rowsReceived - rowsProcessed
+	}
+	drs = append([]droppedRowsReason{{"relabeling", relabelingRows}}, drs...)
+	result := drs[:0]
+	for _, dr := range drs {
+		if dr.rows > 0 {
+			result = append(result, dr)
+		}
+	}
+	return result
+}
+
+// WriteResponseHeaders writes X-VM-Rows-* response headers with rs stats to w.
+//
+// It returns an error with 400 status code if all the rows in the request were rejected because of invalid timestamps or values,
+// since retrying such a request has no sense.
+//
+// Nothing is written and nil error is returned if -insert.legacyResponses is set.
+func (rs *RowsStats) WriteResponseHeaders(w http.ResponseWriter) error {
+	if *legacyResponses || rs == nil {
+		return nil
+	}
+	drs := rs.getDroppedRows()
+	rowsDropped := uint64(0)
+	reasons := make([]string, 0, len(drs))
+	invalidRows := uint64(0)
+	for _, dr := range drs {
+		rowsDropped += dr.rows
+		reasons = append(reasons, fmt.Sprintf("%s=%d", dr.reason, dr.rows))
+		if dr.reason == "invalid_timestamp" || dr.reason == "invalid_value" {
+			invalidRows += dr.rows
+		}
+	}
+	rowsWritten := atomic.LoadUint64(&rs.rowsWritten)
+	if rowsWritten == 0 && invalidRows > 0 && invalidRows == rowsDropped {
+		return &httpserver.ErrorWithStatusCode{
+			Err: fmt.Errorf("all the %d rows in the request were rejected because of invalid timestamps or values: %s; "+
+				"see https://docs.victoriametrics.com/#protection-against-invalid-samples", rowsDropped, strings.Join(reasons, ", ")),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	h := w.Header()
+	h.Set("X-VM-Rows-Written", strconv.FormatUint(rowsWritten, 10))
+	h.Set("X-VM-Rows-Dropped", strconv.FormatUint(rowsDropped, 10))
+	if len(reasons) > 0 {
+		h.Set("X-VM-Rows-Dropped-Reasons", strings.Join(reasons, ","))
+	}
+	return nil
+}
+
+// WritePromRemoteWriteResponseHeaders writes X-Prometheus-Remote-Write-*-Written response headers with rs stats to w.
+//
+// Nothing is written if -insert.legacyResponses is set.
+// See https://prometheus.io/docs/specs/remote_write_spec_2_0/#required-written-response-headers
+func (rs *RowsStats) WritePromRemoteWriteResponseHeaders(w http.ResponseWriter) {
+	if *legacyResponses || rs == nil {
+		return
+	}
+	h := w.Header()
+	h.Set("X-Prometheus-Remote-Write-Samples-Written", strconv.FormatUint(atomic.LoadUint64(&rs.rowsWritten), 10))
+	// Histograms and exemplars aren't supported yet, so they are dropped.
+	h.Set("X-Prometheus-Remote-Write-Histograms-Written", "0")
+	h.Set("X-Prometheus-Remote-Write-Exemplars-Written", "0")
+}
@@ -18,21 +18,23 @@ var (
 )
 
 // InsertHandler processes /api/v1/import/csv requests.
-func InsertHandler(req *http.Request) error {
+func InsertHandler(req *http.Request, rs *common.RowsStats) error {
 	extraLabels, err := parserCommon.GetExtraLabels(req)
 	if err != nil {
 		return err
 	}
 	return stream.Parse(req, func(rows []parser.Row) error {
-		return insertRows(rows, extraLabels)
+		return insertRows(rows, extraLabels, req.RemoteAddr, rs)
 	})
 }
 
-func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label) error {
+func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label, remoteAddr string, rs *common.RowsStats) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
 	ctx.Reset(len(rows))
+	ctx.SetSource("csvimport", remoteAddr)
+	ctx.SetRowsStats(rs)
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range rows {
 		r := &rows[i]
@@ -59,6 +61,7 @@ func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label) error {
 		}
 	}
 	rowsInserted.Add(len(rows))
+	rs.AddReceivedRows(len(rows))
 	rowsPerInsert.Update(float64(len(rows)))
 	return ctx.FlushBufs()
 }
@@ -20,18 +20,18 @@ var (
 // InsertHandlerForHTTP processes remote write for DataDog POST /api/v1/series request.
 //
 // See https://docs.datadoghq.com/api/latest/metrics/#submit-metrics
-func InsertHandlerForHTTP(req *http.Request) error {
+func InsertHandlerForHTTP(req *http.Request, rs *common.RowsStats) error {
 	extraLabels, err := parserCommon.GetExtraLabels(req)
 	if err != nil {
 		return err
 	}
 	ce := req.Header.Get("Content-Encoding")
 	return stream.Parse(req.Body, ce, func(series []parser.Series) error {
-		return insertRows(series, extraLabels)
+		return insertRows(series, extraLabels, req.RemoteAddr, rs)
 	})
 }
 
-func insertRows(series []parser.Series, extraLabels []prompbmarshal.Label) error {
+func insertRows(series []parser.Series, extraLabels []prompbmarshal.Label, remoteAddr string, rs *common.RowsStats) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
@@ -40,6 +40,8 @@ func insertRows(series []parser.Series, extraLabels []prompbmarshal.Label) error
 		rowsLen += len(series[i].Points)
 	}
 	ctx.Reset(rowsLen)
+	ctx.SetSource("datadog", remoteAddr)
+	ctx.SetRowsStats(rs)
 	rowsTotal := 0
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range series {
@@ -84,6 +86,7 @@ func insertRows(series []parser.Series, extraLabels []prompbmarshal.Label) error
 		}
 	}
 	rowsInserted.Add(rowsTotal)
+	rs.AddReceivedRows(rowsTotal)
 	rowsPerInsert.Update(float64(rowsTotal))
 	return ctx.FlushBufs()
 }
@@ -19,14 +19,18 @@ var (
 //
 // See https://graphite.readthedocs.io/en/latest/feeding-carbon.html#the-plaintext-protocol
 func InsertHandler(r io.Reader) error {
-	return stream.Parse(r, insertRows)
+	remoteAddr := common.GetRemoteAddr(r)
+	return stream.Parse(r, func(rows []parser.Row) error {
+		return insertRows(rows, remoteAddr)
+	})
 }
 
-func insertRows(rows []parser.Row) error {
+func insertRows(rows []parser.Row, remoteAddr string) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
 	ctx.Reset(len(rows))
+	ctx.SetSource("graphite", remoteAddr)
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range rows {
 		r := &rows[i]
@@ -35,15 +35,16 @@ var (
 //
 // See https://github.com/influxdata/telegraf/tree/master/plugins/inputs/socket_listener/
 func InsertHandlerForReader(r io.Reader) error {
+	remoteAddr := common.GetRemoteAddr(r)
 	return stream.Parse(r, false, "", "", func(db string, rows []parser.Row) error {
-		return insertRows(db, rows, nil)
+		return insertRows(db, rows, nil, remoteAddr, nil)
 	})
 }
 
 // InsertHandlerForHTTP processes remote write for influx line protocol.
 //
 // See https://github.com/influxdata/influxdb/blob/4cbdc197b8117fee648d62e2e5be75c6575352f0/tsdb/README.md
-func InsertHandlerForHTTP(req *http.Request) error {
+func InsertHandlerForHTTP(req *http.Request, rs *common.RowsStats) error {
 	extraLabels, err := parserCommon.GetExtraLabels(req)
 	if err != nil {
 		return err
@@ -54,11 +55,11 @@ func InsertHandlerForHTTP(req *http.Request) error {
 	// Read db tag from https://docs.influxdata.com/influxdb/v1.7/tools/api/#write-http-endpoint
 	db := q.Get("db")
 	return stream.Parse(req.Body, isGzipped, precision, db, func(db string, rows []parser.Row) error {
-		return insertRows(db, rows, extraLabels)
+		return insertRows(db, rows, extraLabels, req.RemoteAddr, rs)
 	})
 }
 
-func insertRows(db string, rows []parser.Row, extraLabels []prompbmarshal.Label) error {
+func insertRows(db string, rows []parser.Row, extraLabels []prompbmarshal.Label, remoteAddr string, rs *common.RowsStats) error {
 	ctx := getPushCtx()
 	defer putPushCtx(ctx)
 
@@ -68,6 +69,8 @@ func insertRows(db string, rows []parser.Row, extraLabels []prompbmarshal.Label)
 	}
 	ic := &ctx.Common
 	ic.Reset(rowsLen)
+	ic.SetSource("influx", remoteAddr)
+	ic.SetRowsStats(rs)
 	rowsTotal := 0
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range rows {
@@ -142,6 +145,7 @@ func insertRows(db string, rows []parser.Row, extraLabels []prompbmarshal.Label)
 		}
 	}
 	rowsInserted.Add(rowsTotal)
+	rs.AddReceivedRows(rowsTotal)
 	rowsPerInsert.Update(float64(rowsTotal))
 	return ic.FlushBufs()
 }
@@ -58,7 +58,9 @@ var (
 		"See also -opentsdbHTTPListenAddr.useProxyProtocol")
 	opentsdbHTTPUseProxyProtocol = flag.Bool("opentsdbHTTPListenAddr.useProxyProtocol", false, "Whether to use proxy protocol for connections accepted "+
 		"at -opentsdbHTTPListenAddr . See https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt")
-	configAuthKey          = flag.String("configAuthKey", "", "Authorization key for accessing /config page. It must be passed via authKey query arg")
+	configAuthKey       = flag.String("configAuthKey", "", "Authorization key for accessing /config page. It must be passed via authKey query arg")
+	ingestSampleAuthKey = flag.String("ingestSampleAuthKey", "", "Authorization key for accessing /debug/ingest/sample page. It must be passed via authKey query arg. "+
+		"It overrides httpAuth.* settings")
 	maxLabelsPerTimeseries = flag.Int("maxLabelsPerTimeseries", 30, "The maximum number of labels accepted per time series. Superfluous labels are dropped. In this case the vm_metrics_with_dropped_labels_total metric at /metrics page is incremented")
 	maxLabelValueLen       = flag.Int("maxLabelValueLen", 16*1024, "The maximum length of label values in the accepted time series. Longer label values are truncated. In this case the vm_too_long_label_values_total metric at /metrics page is incremented")
 )
@@ -97,6 +99,12 @@ func Init() {
 	promscrape.Init(func(at *auth.Token, wr *prompbmarshal.WriteRequest) {
 		prompush.Push(wr)
 	})
+	httpserver.RegisterReadinessCheck("promscrape", func() error {
+		if n := atomic.LoadInt32(&promscrape.PendingScrapeConfigs); n > 0 {
+			return fmt.Errorf("waiting for scrape config to init targets, configs left: %d", n)
+		}
+		return nil
+	})
 }
 
 // Stop stops vminsert.
@@ -135,7 +143,12 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 	}
 	if strings.HasPrefix(path, "/prometheus/api/v1/import/prometheus") || strings.HasPrefix(path, "/api/v1/import/prometheus") {
 		prometheusimportRequests.Inc()
-		if err := prometheusimport.InsertHandler(r); err != nil {
+		var rs vminsertCommon.RowsStats
+		err := prometheusimport.InsertHandler(r, &rs)
+		if err == nil {
+			err = rs.WriteResponseHeaders(w)
+		}
+		if err != nil {
 			prometheusimportErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -161,16 +174,27 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		prometheusWriteRequests.Inc()
-		if err := promremotewrite.InsertHandler(r); err != nil {
+		var rs vminsertCommon.RowsStats
+		err := promremotewrite.InsertHandler(r, &rs)
+		if err == nil {
+			err = rs.WriteResponseHeaders(w)
+		}
+		if err != nil {
 			prometheusWriteErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
 		}
+		rs.WritePromRemoteWriteResponseHeaders(w)
 		w.WriteHeader(http.StatusNoContent)
 		return true
 	case "/prometheus/api/v1/import", "/api/v1/import":
 		vmimportRequests.Inc()
-		if err := vmimport.InsertHandler(r); err != nil {
+		var rs vminsertCommon.RowsStats
+		err := vmimport.InsertHandler(r, &rs)
+		if err == nil {
+			err = rs.WriteResponseHeaders(w)
+		}
+		if err != nil {
 			vmimportErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -179,7 +203,12 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		return true
 	case "/prometheus/api/v1/import/csv", "/api/v1/import/csv":
 		csvimportRequests.Inc()
-		if err := csvimport.InsertHandler(r); err != nil {
+		var rs vminsertCommon.RowsStats
+		err := csvimport.InsertHandler(r, &rs)
+		if err == nil {
+			err = rs.WriteResponseHeaders(w)
+		}
+		if err != nil {
 			csvimportErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -188,7 +217,12 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		return true
 	case "/prometheus/api/v1/import/native", "/api/v1/import/native":
 		nativeimportRequests.Inc()
-		if err := native.InsertHandler(r); err != nil {
+		var rs vminsertCommon.RowsStats
+		err := native.InsertHandler(r, &rs)
+		if err == nil {
+			err = rs.WriteResponseHeaders(w)
+		}
+		if err != nil {
 			nativeimportErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -198,7 +232,12 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 	case "/influx/write", "/influx/api/v2/write", "/write", "/api/v2/write":
 		influxWriteRequests.Inc()
 		addInfluxResponseHeaders(w)
-		if err := influx.InsertHandlerForHTTP(r); err != nil {
+		var rs vminsertCommon.RowsStats
+		err := influx.InsertHandlerForHTTP(r, &rs)
+		if err == nil {
+			err = rs.WriteResponseHeaders(w)
+		}
+		if err != nil {
 			influxWriteErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -212,7 +251,12 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		return true
 	case "/datadog/api/v1/series":
 		datadogWriteRequests.Inc()
-		if err := datadog.InsertHandlerForHTTP(r); err != nil {
+		var rs vminsertCommon.RowsStats
+		err := datadog.InsertHandlerForHTTP(r, &rs)
+		if err == nil {
+			err = rs.WriteResponseHeaders(w)
+		}
+		if err != nil {
 			datadogWriteErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -286,21 +330,22 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		promscrape.WriteConfigData(&bb)
 		fmt.Fprintf(w, `{"status":"success","data":{"yaml":%q}}`, bb.B)
 		return true
+	case "/debug/ingest/sample":
+		if !httpserver.CheckAuthFlag(w, r, *ingestSampleAuthKey, "ingestSampleAuthKey") {
+			return true
+		}
+		ingestSampleRequests.Inc()
+		if err := vminsertCommon.IngestSampleHandler(w, r); err != nil {
+			ingestSampleErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
 	case "/prometheus/-/reload", "/-/reload":
 		promscrapeConfigReloadRequests.Inc()
 		procutil.SelfSIGHUP()
 		w.WriteHeader(http.StatusNoContent)
 		return true
-	case "/ready":
-		if rdy := atomic.LoadInt32(&promscrape.PendingScrapeConfigs); rdy > 0 {
-			errMsg := fmt.Sprintf("waiting for scrape config to init targets, configs left: %d", rdy)
-			http.Error(w, errMsg, http.StatusTooEarly)
-		} else {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("OK"))
-		}
-		return true
 	default:
 		// This is not our link
 		return false
@@ -357,6 +402,9 @@ var (
 
 	promscrapeConfigReloadRequests = metrics.NewCounter(`vm_http_requests_total{path="/-/reload"}`)
 
+	ingestSampleRequests = metrics.NewCounter(`vm_http_requests_total{path="/debug/ingest/sample"}`)
+	ingestSampleErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/debug/ingest/sample"}`)
+
 	_ = metrics.NewGauge(`vm_metrics_with_dropped_labels_total`, func() float64 {
 		return float64(atomic.LoadUint64(&storage.MetricsWithDroppedLabels))
 	})
@@ -9,6 +9,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 	parserCommon "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/native/stream"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
@@ -21,18 +22,22 @@ var (
 )
 
 // InsertHandler processes `/api/v1/import/native` request.
-func InsertHandler(req *http.Request) error {
+func InsertHandler(req *http.Request, rs *common.RowsStats) error {
 	extraLabels, err := parserCommon.GetExtraLabels(req)
 	if err != nil {
 		return err
 	}
+	pcs, err := relabel.GetRequestRelabelConfigs(req)
+	if err != nil {
+		return err
+	}
 	isGzip := req.Header.Get("Content-Encoding") == "gzip"
 	return stream.Parse(req.Body, isGzip, func(block *stream.Block) error {
-		return insertRows(block, extraLabels)
+		return insertRows(block, extraLabels, pcs, req.RemoteAddr, rs)
 	})
 }
 
-func insertRows(block *stream.Block, extraLabels []prompbmarshal.Label) error {
+func insertRows(block *stream.Block, extraLabels []prompbmarshal.Label, pcs *promrelabel.ParsedConfigs, remoteAddr string, rs *common.RowsStats) error {
 	ctx := getPushCtx()
 	defer putPushCtx(ctx)
 
@@ -40,10 +45,13 @@ func insertRows(block *stream.Block, extraLabels []prompbmarshal.Label) error {
 	// since relabeling can prevent from inserting the rows.
 	rowsLen := len(block.Values)
 	rowsInserted.Add(rowsLen)
+	rs.AddReceivedRows(rowsLen)
 	rowsPerInsert.Update(float64(rowsLen))
 
 	ic := &ctx.Common
 	ic.Reset(rowsLen)
+	ic.SetSource("native", remoteAddr)
+	ic.SetRowsStats(rs)
 	hasRelabeling := relabel.HasRelabeling()
 	mn := &block.MetricName
 	ic.Labels = ic.Labels[:0]
@@ -56,6 +64,11 @@ func insertRows(block *stream.Block, extraLabels []prompbmarshal.Label) error {
 		label := &extraLabels[j]
 		ic.AddLabel(label.Name, label.Value)
 	}
+	if pcs.Len() > 0 {
+		// Apply per-request relabeling before the global relabeling,
+		// so the global relabeling could override the results.
+		ic.ApplyRelabelingWithConfigs(pcs)
+	}
 	if hasRelabeling {
 		ic.ApplyRelabeling()
 	}
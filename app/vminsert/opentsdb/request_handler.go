@@ -19,14 +19,18 @@ var (
 //
 // See http://opentsdb.net/docs/build/html/api_telnet/put.html
 func InsertHandler(r io.Reader) error {
-	return stream.Parse(r, insertRows)
+	remoteAddr := common.GetRemoteAddr(r)
+	return stream.Parse(r, func(rows []parser.Row) error {
+		return insertRows(rows, remoteAddr)
+	})
 }
 
-func insertRows(rows []parser.Row) error {
+func insertRows(rows []parser.Row, remoteAddr string) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
 	ctx.Reset(len(rows))
+	ctx.SetSource("opentsdb", remoteAddr)
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range rows {
 		r := &rows[i]
@@ -29,18 +29,19 @@ func InsertHandler(req *http.Request) error {
 			return err
 		}
 		return stream.Parse(req, func(rows []parser.Row) error {
-			return insertRows(rows, extraLabels)
+			return insertRows(rows, extraLabels, req.RemoteAddr)
 		})
 	default:
 		return fmt.Errorf("unexpected path requested on HTTP OpenTSDB server: %q", path)
 	}
 }
 
-func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label) error {
+func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label, remoteAddr string) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
 	ctx.Reset(len(rows))
+	ctx.SetSource("opentsdbhttp", remoteAddr)
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range rows {
 		r := &rows[i]
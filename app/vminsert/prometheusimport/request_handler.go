@@ -19,7 +19,7 @@ var (
 )
 
 // InsertHandler processes `/api/v1/import/prometheus` request.
-func InsertHandler(req *http.Request) error {
+func InsertHandler(req *http.Request, rs *common.RowsStats) error {
 	extraLabels, err := parserCommon.GetExtraLabels(req)
 	if err != nil {
 		return err
@@ -29,18 +29,25 @@ func InsertHandler(req *http.Request) error {
 		return err
 	}
 	isGzipped := req.Header.Get("Content-Encoding") == "gzip"
+	if parser.IsProtobufContentType(req.Header.Get("Content-Type")) {
+		return stream.ParseProtobuf(req.Body, defaultTimestamp, isGzipped, func(rows []parser.Row) error {
+			return insertRows(rows, extraLabels, req.RemoteAddr, rs)
+		})
+	}
 	return stream.Parse(req.Body, defaultTimestamp, isGzipped, func(rows []parser.Row) error {
-		return insertRows(rows, extraLabels)
+		return insertRows(rows, extraLabels, req.RemoteAddr, rs)
 	}, func(s string) {
 		httpserver.LogError(req, s)
 	})
 }
 
-func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label) error {
+func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label, remoteAddr string, rs *common.RowsStats) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
 	ctx.Reset(len(rows))
+	ctx.SetSource("prometheusimport", remoteAddr)
+	ctx.SetRowsStats(rs)
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range rows {
 		r := &rows[i]
@@ -67,6 +74,7 @@ func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label) error {
 		}
 	}
 	rowsInserted.Add(len(rows))
+	rs.AddReceivedRows(len(rows))
 	rowsPerInsert.Update(float64(len(rows)))
 	return ctx.FlushBufs()
 }
@@ -18,6 +18,7 @@ const maxRowsPerBlock = 10000
 func Push(wr *prompbmarshal.WriteRequest) {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
+	ctx.SetSource("promscrape", "")
 
 	tss := wr.Timeseries
 	for len(tss) > 0 {
@@ -1,10 +1,15 @@
 package promremotewrite
 
 import (
+	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/relabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	parserCommon "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
@@ -18,18 +23,21 @@ var (
 )
 
 // InsertHandler processes remote write for prometheus.
-func InsertHandler(req *http.Request) error {
+func InsertHandler(req *http.Request, rs *common.RowsStats) error {
+	if err := checkRemoteWriteVersion(req.Header); err != nil {
+		return err
+	}
 	extraLabels, err := parserCommon.GetExtraLabels(req)
 	if err != nil {
 		return err
 	}
 	isVMRemoteWrite := req.Header.Get("Content-Encoding") == "zstd"
 	return stream.Parse(req.Body, isVMRemoteWrite, func(tss []prompb.TimeSeries) error {
-		return insertRows(tss, extraLabels)
+		return insertRows(tss, extraLabels, req.RemoteAddr, rs)
 	})
 }
 
-func insertRows(timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Label) error {
+func insertRows(timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Label, remoteAddr string, rs *common.RowsStats) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
@@ -38,6 +46,8 @@ func insertRows(timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Labe
 		rowsLen += len(timeseries[i].Samples)
 	}
 	ctx.Reset(rowsLen)
+	ctx.SetSource("promremotewrite", remoteAddr)
+	ctx.SetRowsStats(rs)
 	rowsTotal := 0
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range timeseries {
@@ -72,6 +82,44 @@ func insertRows(timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Labe
 		}
 	}
 	rowsInserted.Add(rowsTotal)
+	rs.AddReceivedRows(rowsTotal)
 	rowsPerInsert.Update(float64(rowsTotal))
 	return ctx.FlushBufs()
 }
+
+// checkRemoteWriteVersion returns an error if the request with the given headers h
+// is sent via unsupported version of Prometheus remote write protocol.
+//
+// Only prometheus.WriteRequest messages from Prometheus remote write 1.0 are supported.
+// See https://prometheus.io/docs/specs/remote_write_spec_2_0/#protocol
+func checkRemoteWriteVersion(h http.Header) error {
+	if ct := h.Get("Content-Type"); ct != "" {
+		// Ignore invalid Content-Type for backwards compatibility with clients, which send arbitrary Content-Type.
+		_, params, _ := mime.ParseMediaType(ct)
+		if proto := params["proto"]; proto != "" && proto != "prometheus.WriteRequest" {
+			return &httpserver.ErrorWithStatusCode{
+				Err:        fmt.Errorf("unsupported remote write message %q in Content-Type=%q; only prometheus.WriteRequest messages are supported", proto, ct),
+				StatusCode: http.StatusUnsupportedMediaType,
+			}
+		}
+	}
+	version := h.Get("X-Prometheus-Remote-Write-Version")
+	if version == "" {
+		return nil
+	}
+	n := strings.IndexByte(version, '.')
+	if n < 0 {
+		n = len(version)
+	}
+	major, err := strconv.Atoi(version[:n])
+	if err != nil {
+		return fmt.Errorf("cannot parse X-Prometheus-Remote-Write-Version=%q: %w", version, err)
+	}
+	if major > 2 {
+		return &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("unsupported X-Prometheus-Remote-Write-Version=%q; supported versions: 0.1.0, 1.x, 2.x with prometheus.WriteRequest messages", version),
+			StatusCode: http.StatusUnsupportedMediaType,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package relabel
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var namedRelabelConfigs = flagutil.NewArrayString("import.namedRelabelConfig", "Optional named relabeling configs in the form name=path, "+
+	"which can be applied to data imported via /api/v1/import/native by passing relabel_config=name query arg. "+
+	"The path can point either to local file or to http url. "+
+	"See https://docs.victoriametrics.com/#relabeling-during-native-import for details. The configs are reloaded on SIGHUP signal")
+
+var namedPCSGlobal atomic.Value
+
+var (
+	namedConfigReloads      = metrics.NewCounter(`vm_named_relabel_config_reloads_total`)
+	namedConfigReloadErrors = metrics.NewCounter(`vm_named_relabel_config_reloads_errors_total`)
+)
+
+func loadNamedRelabelConfigs() (map[string]*promrelabel.ParsedConfigs, error) {
+	m := make(map[string]*promrelabel.ParsedConfigs, len(*namedRelabelConfigs))
+	for _, nameToPath := range *namedRelabelConfigs {
+		n := strings.IndexByte(nameToPath, '=')
+		if n <= 0 || n == len(nameToPath)-1 {
+			return nil, fmt.Errorf("cannot parse -import.namedRelabelConfig=%q; it must have the form name=path", nameToPath)
+		}
+		name, path := nameToPath[:n], nameToPath[n+1:]
+		if _, ok := m[name]; ok {
+			return nil, fmt.Errorf("duplicate name %q at -import.namedRelabelConfig", name)
+		}
+		pcs, err := promrelabel.LoadRelabelConfigs(path)
+		if err != nil {
+			return nil, fmt.Errorf("error when reading -import.namedRelabelConfig=%q: %w", nameToPath, err)
+		}
+		m[name] = pcs
+	}
+	return m, nil
+}
+
+func reloadNamedRelabelConfigs() {
+	namedConfigReloads.Inc()
+	logger.Infof("received SIGHUP; reloading -import.namedRelabelConfig=%q...", *namedRelabelConfigs)
+	npcs, err := loadNamedRelabelConfigs()
+	if err != nil {
+		namedConfigReloadErrors.Inc()
+		logger.Errorf("cannot load the updated -import.namedRelabelConfig: %s; preserving the previous configs", err)
+		return
+	}
+	namedPCSGlobal.Store(npcs)
+	logger.Infof("successfully reloaded -import.namedRelabelConfig=%q", *namedRelabelConfigs)
+}
+
+// GetRequestRelabelConfigs returns relabeling configs from `relabel_config` query arg at req.
+//
+// The arg may contain either the name of relabeling config from -import.namedRelabelConfig
+// or base64-encoded relabeling config in YAML format.
+//
+// nil is returned if req doesn't contain `relabel_config` query arg.
+func GetRequestRelabelConfigs(req *http.Request) (*promrelabel.ParsedConfigs, error) {
+	// Do not use req.FormValue, since it may consume the request body.
+	s := req.URL.Query().Get("relabel_config")
+	if s == "" {
+		return nil, nil
+	}
+	return getRelabelConfigsByNameOrData(s)
+}
+
+func getRelabelConfigsByNameOrData(s string) (*promrelabel.ParsedConfigs, error) {
+	npcs, _ := namedPCSGlobal.Load().(map[string]*promrelabel.ParsedConfigs)
+	if pcs, ok := npcs[s]; ok {
+		return pcs, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		data, err = base64.URLEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("`relabel_config` query arg must contain either the name of relabeling config from -import.namedRelabelConfig "+
+			"or base64-encoded relabeling config; got %q", s)
+	}
+	pcs, err := promrelabel.ParseRelabelConfigsData(data)
+	if err != nil {
+		return nil, fmt.Errorf("`relabel_config` query arg must contain either the name of relabeling config from -import.namedRelabelConfig "+
+			"or base64-encoded relabeling config; cannot parse %q as base64-encoded relabeling config: %w", s, err)
+	}
+	return pcs, nil
+}
@@ -40,28 +40,43 @@ func Init() {
 	configSuccess.Set(1)
 	configTimestamp.Set(fasttime.UnixTimestamp())
 
-	if len(*relabelConfig) == 0 {
+	npcs, err := loadNamedRelabelConfigs()
+	if err != nil {
+		logger.Fatalf("cannot load -import.namedRelabelConfig: %s", err)
+	}
+	namedPCSGlobal.Store(npcs)
+
+	if len(*relabelConfig) == 0 && len(*namedRelabelConfigs) == 0 {
 		return
 	}
 	go func() {
 		for range sighupCh {
-			configReloads.Inc()
-			logger.Infof("received SIGHUP; reloading -relabelConfig=%q...", *relabelConfig)
-			pcs, err := loadRelabelConfig()
-			if err != nil {
-				configReloadErrors.Inc()
-				configSuccess.Set(0)
-				logger.Errorf("cannot load the updated relabelConfig: %s; preserving the previous config", err)
-				continue
+			if len(*relabelConfig) > 0 {
+				reloadRelabelConfig()
+			}
+			if len(*namedRelabelConfigs) > 0 {
+				reloadNamedRelabelConfigs()
 			}
-			pcsGlobal.Store(pcs)
-			configSuccess.Set(1)
-			configTimestamp.Set(fasttime.UnixTimestamp())
-			logger.Infof("successfully reloaded -relabelConfig=%q", *relabelConfig)
 		}
 	}()
 }
 
+func reloadRelabelConfig() {
+	configReloads.Inc()
+	logger.Infof("received SIGHUP; reloading -relabelConfig=%q...", *relabelConfig)
+	pcs, err := loadRelabelConfig()
+	if err != nil {
+		configReloadErrors.Inc()
+		configSuccess.Set(0)
+		logger.Errorf("cannot load the updated relabelConfig: %s; preserving the previous config", err)
+		return
+	}
+	pcsGlobal.Store(pcs)
+	configSuccess.Set(1)
+	configTimestamp.Set(fasttime.UnixTimestamp())
+	logger.Infof("successfully reloaded -relabelConfig=%q", *relabelConfig)
+}
+
 var (
 	configReloads      = metrics.NewCounter(`vm_relabel_config_reloads_total`)
 	configReloadErrors = metrics.NewCounter(`vm_relabel_config_reloads_errors_total`)
@@ -71,9 +86,12 @@ var (
 
 var pcsGlobal atomic.Value
 
-// CheckRelabelConfig checks config pointed by -relabelConfig
+// CheckRelabelConfig checks configs pointed by -relabelConfig and -import.namedRelabelConfig
 func CheckRelabelConfig() error {
-	_, err := loadRelabelConfig()
+	if _, err := loadRelabelConfig(); err != nil {
+		return err
+	}
+	_, err := loadNamedRelabelConfigs()
 	return err
 }
 
@@ -108,13 +126,29 @@ func (ctx *Ctx) Reset() {
 
 // ApplyRelabeling applies relabeling to the given labels and returns the result.
 //
-// The returned labels are valid until the next call to ApplyRelabeling.
+// The returned labels are valid until the next call to ApplyRelabeling or ApplyRelabelingWithConfigs.
 func (ctx *Ctx) ApplyRelabeling(labels []prompb.Label) []prompb.Label {
 	pcs := pcsGlobal.Load().(*promrelabel.ParsedConfigs)
 	if pcs.Len() == 0 && !*usePromCompatibleNaming {
 		// There are no relabeling rules.
 		return labels
 	}
+	return ctx.applyRelabeling(labels, pcs, *usePromCompatibleNaming)
+}
+
+// ApplyRelabelingWithConfigs applies the given pcs to the given labels and returns the result.
+//
+// It is used for applying per-request relabeling configs obtained via GetRequestRelabelConfigs.
+//
+// The returned labels are valid until the next call to ApplyRelabeling or ApplyRelabelingWithConfigs.
+func (ctx *Ctx) ApplyRelabelingWithConfigs(labels []prompb.Label, pcs *promrelabel.ParsedConfigs) []prompb.Label {
+	if pcs.Len() == 0 {
+		return labels
+	}
+	return ctx.applyRelabeling(labels, pcs, false)
+}
+
+func (ctx *Ctx) applyRelabeling(labels []prompb.Label, pcs *promrelabel.ParsedConfigs, promCompatibleNaming bool) []prompb.Label {
 	// Convert labels to prompbmarshal.Label format suitable for relabeling.
 	tmpLabels := ctx.tmpLabels[:0]
 	for _, label := range labels {
@@ -129,7 +163,7 @@ func (ctx *Ctx) ApplyRelabeling(labels []prompb.Label) []prompb.Label {
 		})
 	}
 
-	if *usePromCompatibleNaming {
+	if promCompatibleNaming {
 		// Replace unsupported Prometheus chars in label names and metric names with underscores.
 		for i := range tmpLabels {
 			label := &tmpLabels[i]
@@ -24,18 +24,18 @@ var (
 // InsertHandler processes `/api/v1/import` request.
 //
 // See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/6
-func InsertHandler(req *http.Request) error {
+func InsertHandler(req *http.Request, rs *common.RowsStats) error {
 	extraLabels, err := parserCommon.GetExtraLabels(req)
 	if err != nil {
 		return err
 	}
 	isGzipped := req.Header.Get("Content-Encoding") == "gzip"
 	return stream.Parse(req.Body, isGzipped, func(rows []parser.Row) error {
-		return insertRows(rows, extraLabels)
+		return insertRows(rows, extraLabels, req.RemoteAddr, rs)
 	})
 }
 
-func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label) error {
+func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label, remoteAddr string, rs *common.RowsStats) error {
 	ctx := getPushCtx()
 	defer putPushCtx(ctx)
 
@@ -45,6 +45,8 @@ func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label) error {
 	}
 	ic := &ctx.Common
 	ic.Reset(rowsLen)
+	ic.SetSource("vmimport", remoteAddr)
+	ic.SetRowsStats(rs)
 	rowsTotal := 0
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range rows {
@@ -81,6 +83,7 @@ func insertRows(rows []parser.Row, extraLabels []prompbmarshal.Label) error {
 		}
 	}
 	rowsInserted.Add(rowsTotal)
+	rs.AddReceivedRows(rowsTotal)
 	rowsPerInsert.Update(float64(rowsTotal))
 	return ic.FlushBufs()
 }
@@ -20,13 +20,18 @@ import (
 var (
 	httpListenAddr = flag.String("httpListenAddr", ":8421", "TCP address for exporting metrics at /metrics page")
 	src            = flag.String("src", "", "Source path with backup on the remote storage. "+
-		"Example: gs://bucket/path/to/backup, s3://bucket/path/to/backup, azblob://container/path/to/backup or fs:///path/to/local/backup")
+		"Example: gs://bucket/path/to/backup, s3://bucket/path/to/backup, azblob://container/path/to/backup, sftp://user@host:port/path/to/backup, webdavs://host/path/to/backup or fs:///path/to/local/backup")
 	storageDataPath = flag.String("storageDataPath", "victoria-metrics-data", "Destination path where backup must be restored. "+
 		"VictoriaMetrics must be stopped when restoring from backup. -storageDataPath dir can be non-empty. In this case the contents of -storageDataPath dir "+
 		"is synchronized with -src contents, i.e. it works like 'rsync --delete'")
-	concurrency             = flag.Int("concurrency", 10, "The number of concurrent workers. Higher concurrency may reduce restore duration")
-	maxBytesPerSecond       = flagutil.NewBytes("maxBytesPerSecond", 0, "The maximum download speed. There is no limit if it is set to 0")
+	concurrency       = flag.Int("concurrency", 10, "The number of concurrent workers for downloading and verifying parts. Higher concurrency may reduce restore duration")
+	maxBytesPerSecond = flagutil.NewBytes("maxBytesPerSecond", 0, "The maximum speed for writing downloaded data to -storageDataPath and for reading data from -storageDataPath "+
+		"during checksum verification. There is no limit if it is set to 0")
 	skipBackupCompleteCheck = flag.Bool("skipBackupCompleteCheck", false, "Whether to skip checking for 'backup complete' file in -src. This may be useful for restoring from old backups, which were created without 'backup complete' file")
+	restoreTimeRange        = flag.String("restoreFilter.timeRange", "", "Optional time range in the form 'start,end' for restoring only partitions "+
+		"overlapping the given time range. start and end may be specified as RFC3339 timestamps, as YYYY-MM-DD dates or as unix timestamps in seconds. "+
+		"Empty start or end means unbounded time range from the corresponding side. See https://docs.victoriametrics.com/vmrestore.html#partial-restore")
+	dryRun = flag.Bool("dryRun", false, "Whether to log the parts, which would be downloaded from -src, and the total download size without modifying -storageDataPath")
 )
 
 func main() {
@@ -48,11 +53,17 @@ func main() {
 	if err != nil {
 		logger.Fatalf("%s", err)
 	}
+	tr, err := newRestoreTimeRange()
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
 	a := &actions.Restore{
 		Concurrency:             *concurrency,
 		Src:                     srcFS,
 		Dst:                     dstFS,
 		SkipBackupCompleteCheck: *skipBackupCompleteCheck,
+		TimeRange:               tr,
+		DryRun:                  *dryRun,
 	}
 	if err := a.Run(); err != nil {
 		logger.Fatalf("cannot restore from backup: %s", err)
@@ -98,3 +109,14 @@ func newSrcFS() (common.RemoteFS, error) {
 	}
 	return fs, nil
 }
+
+func newRestoreTimeRange() (*actions.RestoreTimeRange, error) {
+	if len(*restoreTimeRange) == 0 {
+		return nil, nil
+	}
+	tr, err := actions.ParseRestoreTimeRange(*restoreTimeRange)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `-restoreFilter.timeRange`=%q: %w", *restoreTimeRange, err)
+	}
+	return tr, nil
+}
@@ -9,6 +9,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/graphite"
@@ -16,14 +17,15 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/prometheus"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/vmuistore"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
-	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timerpool"
 	"github.com/VictoriaMetrics/metrics"
 )
 
@@ -35,6 +37,7 @@ var (
 	maxQueueDuration = flag.Duration("search.maxQueueDuration", 10*time.Second, "The maximum time the request waits for execution when -search.maxConcurrentRequests "+
 		"limit is reached; see also -search.maxQueryDuration")
 	resetCacheAuthKey    = flag.String("search.resetCacheAuthKey", "", "Optional authKey for resetting rollup cache via /internal/resetRollupResultCache call")
+	cacheWarmAuthKey     = flag.String("search.cacheWarmAuthKey", "", "Optional authKey for warming up rollup cache via /internal/cache/warm* calls. See https://docs.victoriametrics.com/#cache-warming")
 	logSlowQueryDuration = flag.Duration("search.logSlowQueryDuration", 5*time.Second, "Log queries with execution time exceeding this value. Zero disables slow query logging. "+
 		"See also -search.logQueryMemoryUsage")
 	vmalertProxyURL = flag.String("vmalert.proxyURL", "", "Optional URL for proxying requests to vmalert. For example, if -vmalert.proxyURL=http://vmalert:8880 , then alerting API requests such as /api/v1/rules from Grafana will be proxied to http://vmalert:8880/api/v1/rules")
@@ -56,33 +59,66 @@ func getDefaultMaxConcurrentRequests() int {
 	return n
 }
 
+func init() {
+	flagutil.RegisterReloadable("search.maxConcurrentRequests", updateConcurrencyLimit)
+	flagutil.RegisterReloadable("search.maxQueueDuration", updateMaxQueueDuration)
+}
+
 // Init initializes vmselect
 func Init() {
 	tmpDirPath := *vmstorage.DataPath + "/tmp"
 	fs.RemoveDirContents(tmpDirPath)
 	netstorage.InitTmpBlocksDir(tmpDirPath)
+	netstorage.InitMetricNameMapping(promql.ResetRollupResultCache)
 	promql.InitRollupResultCache(*vmstorage.DataPath + "/cache/rollupResult")
+	promql.InitLabelMappings()
+	vmuistore.Init(*vmstorage.DataPath + "/vmui/store.json")
 
-	concurrencyLimitCh = make(chan struct{}, *maxConcurrentRequests)
+	updateConcurrencyLimit()
+	updateMaxQueueDuration()
+	initTrustedProxies()
 	initVMAlertProxy()
 }
 
 // Stop stops vmselect
 func Stop() {
+	prometheus.StopCacheWarmJobs()
 	promql.StopRollupResultCache()
+	promql.StopLabelMappings()
+	vmuistore.Stop()
+}
+
+// updateConcurrencyLimit sets the concurrency limit according to -search.maxConcurrentRequests.
+//
+// Requests, which are already executed, aren't interrupted if the limit is reduced,
+// so the concurrency may temporarily exceed the updated -search.maxConcurrentRequests.
+func updateConcurrencyLimit() {
+	concurrencyLimiterV.setMaxConcurrent(*maxConcurrentRequests)
+}
+
+func updateMaxQueueDuration() {
+	atomic.StoreInt64(&maxQueueDurationNsecs, int64(*maxQueueDuration))
+}
+
+func getMaxQueueDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&maxQueueDurationNsecs))
 }
 
-var concurrencyLimitCh chan struct{}
+var (
+	concurrencyLimiterV   = newConcurrencyLimiter()
+	maxQueueDurationNsecs int64
+)
 
 var (
 	concurrencyLimitReached = metrics.NewCounter(`vm_concurrent_select_limit_reached_total`)
 	concurrencyLimitTimeout = metrics.NewCounter(`vm_concurrent_select_limit_timeout_total`)
+	highPriorityDowngrades  = metrics.NewCounter(`vm_concurrent_select_high_priority_downgrades_total`)
 
 	_ = metrics.NewGauge(`vm_concurrent_select_capacity`, func() float64 {
-		return float64(cap(concurrencyLimitCh))
+		return float64(concurrencyLimiterV.getMaxConcurrent())
 	})
 	_ = metrics.NewGauge(`vm_concurrent_select_current`, func() float64 {
-		return float64(len(concurrencyLimitCh))
+		return float64(concurrencyLimiterV.getRunning())
 	})
 )
 
@@ -98,36 +134,51 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 	tracerEnabled := searchutils.GetBool(r, "trace")
 	qt := querytracer.New(tracerEnabled, r.URL.Path)
 
-	// Limit the number of concurrent queries.
-	select {
-	case concurrencyLimitCh <- struct{}{}:
-		defer func() { <-concurrencyLimitCh }()
-	default:
-		// Sleep for a while until giving up. This should resolve short bursts in requests.
+	// Limit the number of concurrent queries according to their priorities.
+	priority, err := getRequestPriority(r)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", &httpserver.ErrorWithStatusCode{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		})
+		return true
+	}
+	if priority == priorityHigh {
+		source := getRequestSource(r)
+		if concurrencyLimiterV.registerHighPriority(source) {
+			defer concurrencyLimiterV.unregisterHighPriority(source)
+		} else {
+			highPriorityDowngrades.Inc()
+			qt.Printf("execute the request with normal priority, since the source %q exceeds -search.maxHighPriorityConcurrentRequestsPerSourcePercent=%g",
+				source, *maxHighPriorityConcurrentRequestsPerSourcePercent)
+			priority = priorityNormal
+		}
+	}
+	d := searchutils.GetMaxQueryDuration(r)
+	maxQueueDuration := getMaxQueueDuration()
+	if d > maxQueueDuration {
+		d = maxQueueDuration
+	}
+	ok, wait := concurrencyLimiterV.acquire(priority, d)
+	if wait {
+		// Wait for a while until giving up. This should resolve short bursts in requests.
 		concurrencyLimitReached.Inc()
-		d := searchutils.GetMaxQueryDuration(r)
-		if d > *maxQueueDuration {
-			d = *maxQueueDuration
-		}
-		t := timerpool.Get(d)
-		select {
-		case concurrencyLimitCh <- struct{}{}:
-			timerpool.Put(t)
-			qt.Printf("wait in queue because -search.maxConcurrentRequests=%d concurrent requests are executed", *maxConcurrentRequests)
-			defer func() { <-concurrencyLimitCh }()
-		case <-t.C:
-			timerpool.Put(t)
-			concurrencyLimitTimeout.Inc()
-			err := &httpserver.ErrorWithStatusCode{
-				Err: fmt.Errorf("couldn't start executing the request in %.3f seconds, since -search.maxConcurrentRequests=%d concurrent requests "+
-					"are executed. Possible solutions: to reduce query load; to add more compute resources to the server; "+
-					"to increase -search.maxQueueDuration=%s; to increase -search.maxQueryDuration; to increase -search.maxConcurrentRequests",
-					d.Seconds(), *maxConcurrentRequests, maxQueueDuration),
-				StatusCode: http.StatusServiceUnavailable,
-			}
-			httpserver.Errorf(w, r, "%s", err)
-			return true
+	}
+	if !ok {
+		concurrencyLimitTimeout.Inc()
+		err := &httpserver.ErrorWithStatusCode{
+			Err: fmt.Errorf("couldn't start executing the request with %s priority in %.3f seconds, since -search.maxConcurrentRequests=%d concurrent requests "+
+				"are executed. Possible solutions: to reduce query load; to add more compute resources to the server; "+
+				"to increase -search.maxQueueDuration=%s; to increase -search.maxQueryDuration; to increase -search.maxConcurrentRequests",
+				priorityNames[priority], d.Seconds(), concurrencyLimiterV.getMaxConcurrent(), maxQueueDuration),
+			StatusCode: http.StatusServiceUnavailable,
 		}
+		httpserver.Errorf(w, r, "%s", err)
+		return true
+	}
+	defer concurrencyLimiterV.release(priority)
+	if wait {
+		qt.Printf("wait in queue with %s priority because -search.maxConcurrentRequests=%d concurrent requests are executed", priorityNames[priority], concurrencyLimiterV.getMaxConcurrent())
 	}
 
 	if *logSlowQueryDuration > 0 {
@@ -152,6 +203,26 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		promql.ResetRollupResultCache()
 		return true
 	}
+	if strings.HasPrefix(path, "/internal/cache/warm") {
+		if !httpserver.CheckAuthFlag(w, r, *cacheWarmAuthKey, "cacheWarmAuthKey") {
+			return true
+		}
+		var err error
+		switch path {
+		case "/internal/cache/warm":
+			err = prometheus.CacheWarmHandler(w, r)
+		case "/internal/cache/warm/status":
+			err = prometheus.CacheWarmStatusHandler(w, r)
+		case "/internal/cache/warm/cancel":
+			err = prometheus.CacheWarmCancelHandler(w, r)
+		default:
+			return false
+		}
+		if err != nil {
+			httpserver.Errorf(w, r, "%s", err)
+		}
+		return true
+	}
 
 	// Strip /prometheus and /graphite prefixes in order to provide path compatibility with cluster version
 	//
@@ -182,6 +253,9 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			}
 			return true
 		}
+		if vmuistore.RequestHandler(w, r, path) {
+			return true
+		}
 		r.URL.Path = path
 		vmuiFileServer.ServeHTTP(w, r)
 		return true
@@ -318,6 +392,36 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/api/v1/status/metric_usage":
+		statusMetricUsageRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.MetricUsageHandler(qt, startTime, w, r); err != nil {
+			statusMetricUsageErrors.Inc()
+			sendPrometheusError(w, r, err)
+			return true
+		}
+		return true
+	case "/api/v1/status/flags":
+		statusFlagsRequests.Inc()
+		if !httpserver.CheckFlagsAuthKey(w, r) {
+			return true
+		}
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.StatusFlagsHandler(w, r); err != nil {
+			statusFlagsErrors.Inc()
+			sendPrometheusError(w, r, err)
+			return true
+		}
+		return true
+	case "/api/v1/status/runtimeinfo":
+		statusRuntimeInfoRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.StatusRuntimeInfoHandler(qt, w, r); err != nil {
+			statusRuntimeInfoErrors.Inc()
+			sendPrometheusError(w, r, err)
+			return true
+		}
+		return true
 	case "/api/v1/status/active_queries":
 		statusActiveQueriesRequests.Inc()
 		promql.WriteActiveQueries(w)
@@ -339,6 +443,14 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/api/v1/read":
+		remoteReadRequests.Inc()
+		if err := prometheus.RemoteReadHandler(startTime, w, r); err != nil {
+			remoteReadErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
 	case "/api/v1/export/csv":
 		exportCSVRequests.Inc()
 		if err := prometheus.ExportCSVHandler(startTime, w, r); err != nil {
@@ -580,6 +692,15 @@ var (
 	statusTSDBRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/tsdb"}`)
 	statusTSDBErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/tsdb"}`)
 
+	statusMetricUsageRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/metric_usage"}`)
+	statusMetricUsageErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/metric_usage"}`)
+
+	statusFlagsRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/flags"}`)
+	statusFlagsErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/flags"}`)
+
+	statusRuntimeInfoRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/runtimeinfo"}`)
+	statusRuntimeInfoErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/runtimeinfo"}`)
+
 	statusActiveQueriesRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/active_queries"}`)
 
 	topQueriesRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/top_queries"}`)
@@ -591,6 +712,9 @@ var (
 	exportRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/export"}`)
 	exportErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/export"}`)
 
+	remoteReadRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/read"}`)
+	remoteReadErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/read"}`)
+
 	exportCSVRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/export/csv"}`)
 	exportCSVErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/export/csv"}`)
 
@@ -0,0 +1,305 @@
+package netstorage
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	metricNameMappingFile = flag.String("search.metricNameMapping", "", "Optional path to a file with old->new metric name pairs. "+
+		"Selectors on the new metric name additionally match series with the old metric name, which are returned under the new name. "+
+		"The path can point either to local file or to http url. The file is re-read on SIGHUP signal. "+
+		"See https://docs.victoriametrics.com/#metric-name-mapping")
+	metricNameMappingLabel = flag.String("search.metricNameMappingLabel", "", "Optional label name to add to series selected via the old metric name "+
+		"from -search.metricNameMapping. The label value is set to the old metric name. This may help tracking the migration progress")
+)
+
+// metricNameMappingEntry is a single entry from -search.metricNameMapping file.
+type metricNameMappingEntry struct {
+	Old string `yaml:"old"`
+	New string `yaml:"new"`
+
+	// Cutoff is an optional time in RFC3339 format. Series with the Old name are selected
+	// only for queries with time range starting before Cutoff.
+	Cutoff string `yaml:"cutoff,omitempty"`
+}
+
+type oldMetricName struct {
+	name string
+
+	// cutoff is the timestamp in milliseconds. Zero means no cutoff.
+	cutoff int64
+}
+
+// metricNameMapping holds parsed -search.metricNameMapping
+type metricNameMapping struct {
+	// newToOld maps new metric names to old metric names
+	newToOld map[string][]oldMetricName
+
+	// oldToNew maps old metric names to new metric names
+	oldToNew map[string]string
+}
+
+var metricNameMappingGlobal atomic.Value
+
+// InitMetricNameMapping loads -search.metricNameMapping and starts watching for SIGHUP for re-reading it.
+//
+// resetCache is called after the mapping is changed, since cached query results may be calculated with the previous mapping.
+func InitMetricNameMapping(resetCache func()) {
+	// Register SIGHUP handler for config re-read just before loadMetricNameMapping call.
+	// This guarantees that the config will be re-read if the signal arrives during loadMetricNameMapping call.
+	sighupCh := procutil.NewSighupChan()
+
+	mnm, err := loadMetricNameMapping(*metricNameMappingFile)
+	if err != nil {
+		logger.Fatalf("cannot load -search.metricNameMapping: %s", err)
+	}
+	metricNameMappingGlobal.Store(mnm)
+	mappingConfigSuccess.Set(1)
+	mappingConfigTimestamp.Set(fasttime.UnixTimestamp())
+
+	if len(*metricNameMappingFile) == 0 {
+		return
+	}
+	go func() {
+		for range sighupCh {
+			mappingConfigReloads.Inc()
+			logger.Infof("received SIGHUP; reloading -search.metricNameMapping=%q...", *metricNameMappingFile)
+			mnm, err := loadMetricNameMapping(*metricNameMappingFile)
+			if err != nil {
+				mappingConfigReloadErrors.Inc()
+				mappingConfigSuccess.Set(0)
+				logger.Errorf("cannot load the updated -search.metricNameMapping: %s; preserving the previous config", err)
+				continue
+			}
+			mappingConfigSuccess.Set(1)
+			mappingConfigTimestamp.Set(fasttime.UnixTimestamp())
+			if reflect.DeepEqual(mnm, getMetricNameMapping()) {
+				logger.Infof("nothing changed in -search.metricNameMapping=%q", *metricNameMappingFile)
+				continue
+			}
+			metricNameMappingGlobal.Store(mnm)
+			resetCache()
+			logger.Infof("successfully reloaded -search.metricNameMapping=%q", *metricNameMappingFile)
+		}
+	}()
+}
+
+var (
+	mappingConfigReloads      = metrics.NewCounter(`vm_metric_name_mapping_config_reloads_total`)
+	mappingConfigReloadErrors = metrics.NewCounter(`vm_metric_name_mapping_config_reloads_errors_total`)
+	mappingConfigSuccess      = metrics.NewCounter(`vm_metric_name_mapping_config_last_reload_successful`)
+	mappingConfigTimestamp    = metrics.NewCounter(`vm_metric_name_mapping_config_last_reload_success_timestamp_seconds`)
+)
+
+func getMetricNameMapping() *metricNameMapping {
+	v := metricNameMappingGlobal.Load()
+	if v == nil {
+		return nil
+	}
+	return v.(*metricNameMapping)
+}
+
+func loadMetricNameMapping(path string) (*metricNameMapping, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := fs.ReadFileOrHTTP(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %w", path, err)
+	}
+	data, err = envtemplate.ReplaceBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot expand environment vars at %q: %w", path, err)
+	}
+	mnm, err := parseMetricNameMapping(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+	return mnm, nil
+}
+
+func parseMetricNameMapping(data []byte) (*metricNameMapping, error) {
+	var entries []metricNameMappingEntry
+	if err := yaml.UnmarshalStrict(data, &entries); err != nil {
+		return nil, err
+	}
+	mnm := &metricNameMapping{
+		newToOld: make(map[string][]oldMetricName),
+		oldToNew: make(map[string]string),
+	}
+	for i, e := range entries {
+		if e.Old == "" || e.New == "" {
+			return nil, fmt.Errorf("entry #%d must contain non-empty `old` and `new` fields", i+1)
+		}
+		if e.Old == e.New {
+			return nil, fmt.Errorf("entry #%d: `old` and `new` fields must differ; got %q", i+1, e.Old)
+		}
+		if prev, ok := mnm.oldToNew[e.Old]; ok {
+			return nil, fmt.Errorf("entry #%d: duplicate mapping for old name %q; it is already mapped to %q", i+1, e.Old, prev)
+		}
+		if _, ok := mnm.newToOld[e.Old]; ok {
+			return nil, fmt.Errorf("entry #%d: old name %q is already used as a new name; chained mappings aren't supported", i+1, e.Old)
+		}
+		if _, ok := mnm.oldToNew[e.New]; ok {
+			return nil, fmt.Errorf("entry #%d: new name %q is already used as an old name; chained mappings aren't supported", i+1, e.New)
+		}
+		var cutoff int64
+		if e.Cutoff != "" {
+			t, err := time.Parse(time.RFC3339, e.Cutoff)
+			if err != nil {
+				return nil, fmt.Errorf("entry #%d: cannot parse `cutoff`: %w", i+1, err)
+			}
+			cutoff = t.UnixMilli()
+		}
+		mnm.oldToNew[e.Old] = e.New
+		mnm.newToOld[e.New] = append(mnm.newToOld[e.New], oldMetricName{
+			name:   e.Old,
+			cutoff: cutoff,
+		})
+	}
+	return mnm, nil
+}
+
+// expandTagFilterss adds tag filters for old metric names to tagFilterss containing exact filters on new metric names.
+//
+// It returns the expanded filters and old->new mapping for the metric names
+// added to the filters, which must be passed to renameMetricName.
+func (mnm *metricNameMapping) expandTagFilterss(tagFilterss [][]storage.TagFilter, tr storage.TimeRange) ([][]storage.TagFilter, map[string]string) {
+	if mnm == nil || len(mnm.newToOld) == 0 {
+		return tagFilterss, nil
+	}
+	var renames map[string]string
+	dst := tagFilterss
+	for _, tfs := range tagFilterss {
+		n := getMetricNameFilterIndex(tfs)
+		if n < 0 {
+			continue
+		}
+		newName := string(tfs[n].Value)
+		for _, old := range mnm.newToOld[newName] {
+			if old.cutoff > 0 && tr.MinTimestamp >= old.cutoff {
+				// There is no data for the old name on the requested time range.
+				continue
+			}
+			if renames == nil {
+				// Do not modify the original tagFilterss.
+				dst = append([][]storage.TagFilter{}, tagFilterss...)
+				renames = make(map[string]string)
+			}
+			tfsCopy := append([]storage.TagFilter{}, tfs...)
+			tfsCopy[n].Value = []byte(old.name)
+			dst = append(dst, tfsCopy)
+			renames[old.name] = newName
+		}
+	}
+	return dst, renames
+}
+
+// getMetricNameFilterIndex returns the index of non-negative exact filter on metric name in tfs.
+//
+// -1 is returned if tfs doesn't contain such filter.
+func getMetricNameFilterIndex(tfs []storage.TagFilter) int {
+	for i := range tfs {
+		tf := &tfs[i]
+		if len(tf.Key) == 0 && !tf.IsNegative && !tf.IsRegexp {
+			return i
+		}
+	}
+	return -1
+}
+
+// renameMetricName renames marshaled metricName according to renames obtained from expandTagFilterss.
+//
+// metricName is returned as is if it mustn't be renamed.
+func renameMetricName(metricName []byte, renames map[string]string, mn *storage.MetricName) ([]byte, error) {
+	if len(renames) == 0 {
+		return metricName, nil
+	}
+	if err := mn.Unmarshal(metricName); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal metricName: %w", err)
+	}
+	if !renameMetricNameInplace(mn, renames) {
+		return metricName, nil
+	}
+	return mn.Marshal(nil), nil
+}
+
+// renameMetricNameInplace renames mn according to renames obtained from expandTagFilterss.
+//
+// false is returned if mn mustn't be renamed.
+func renameMetricNameInplace(mn *storage.MetricName, renames map[string]string) bool {
+	newName, ok := renames[string(mn.MetricGroup)]
+	if !ok {
+		return false
+	}
+	if label := *metricNameMappingLabel; label != "" {
+		// Remove the existing label with the same name in order to avoid duplicate labels.
+		mn.RemoveTag(label)
+		mn.AddTag(label, string(mn.MetricGroup))
+		sort.Slice(mn.Tags, func(i, j int) bool {
+			return string(mn.Tags[i].Key) < string(mn.Tags[j].Key)
+		})
+	}
+	mn.MetricGroup = append(mn.MetricGroup[:0], newName...)
+	return true
+}
+
+// renameMetricNames applies renameMetricName to all the marshaled metricNames and removes duplicates.
+func renameMetricNames(metricNames []string, renames map[string]string) ([]string, error) {
+	if len(renames) == 0 {
+		return metricNames, nil
+	}
+	var mn storage.MetricName
+	m := make(map[string]struct{}, len(metricNames))
+	dst := metricNames[:0]
+	for _, metricName := range metricNames {
+		b, err := renameMetricName([]byte(metricName), renames, &mn)
+		if err != nil {
+			return nil, err
+		}
+		s := string(b)
+		if _, ok := m[s]; ok {
+			continue
+		}
+		m[s] = struct{}{}
+		dst = append(dst, s)
+	}
+	return dst, nil
+}
+
+// renameMetricNameValues replaces old metric names with the new names in values and removes duplicates.
+//
+// This is used for metric name values returned from /api/v1/label/__name__/values,
+// so autocomplete shows only the new names.
+func (mnm *metricNameMapping) renameMetricNameValues(values []string) []string {
+	if mnm == nil || len(mnm.oldToNew) == 0 {
+		return values
+	}
+	m := make(map[string]struct{}, len(values))
+	dst := values[:0]
+	for _, v := range values {
+		if newName, ok := mnm.oldToNew[v]; ok {
+			v = newName
+		}
+		if _, ok := m[v]; ok {
+			continue
+		}
+		m[v] = struct{}{}
+		dst = append(dst, v)
+	}
+	return dst
+}
@@ -0,0 +1,172 @@
+package netstorage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestParseMetricNameMappingFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		if _, err := parseMetricNameMapping([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error for %q", data)
+		}
+	}
+	f("foo")
+	f("- old: foo")
+	f("- new: foo")
+	f("- old: foo\n  new: foo")
+	f("- old: foo\n  new: bar\n  unknown: baz")
+	f("- old: foo\n  new: bar\n  cutoff: 2023-13-01")
+	f("- old: foo\n  new: bar\n- old: foo\n  new: baz")
+	f("- old: foo\n  new: bar\n- old: bar\n  new: baz")
+	f("- old: foo\n  new: bar\n- old: baz\n  new: foo")
+}
+
+func TestMetricNameMappingExpandTagFilterss(t *testing.T) {
+	mnm, err := parseMetricNameMapping([]byte(`
+- old: foo_old
+  new: foo
+- old: foo_older
+  new: foo
+  cutoff: "2023-01-01T00:00:00Z"
+- old: bar_old
+  new: bar
+`))
+	if err != nil {
+		t.Fatalf("cannot parse mapping: %s", err)
+	}
+	const cutoff = 1672531200000
+
+	f := func(tagFilterss [][]storage.TagFilter, minTimestamp int64, resultExpected [][]storage.TagFilter, renamesExpected map[string]string) {
+		t.Helper()
+		tr := storage.TimeRange{
+			MinTimestamp: minTimestamp,
+			MaxTimestamp: minTimestamp + 1000,
+		}
+		result, renames := mnm.expandTagFilterss(tagFilterss, tr)
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected tag filters;\ngot\n%v\nwant\n%v", result, resultExpected)
+		}
+		if !reflect.DeepEqual(renames, renamesExpected) {
+			t.Fatalf("unexpected renames; got %v; want %v", renames, renamesExpected)
+		}
+	}
+	tf := func(key, value string, isNegative, isRegexp bool) storage.TagFilter {
+		var k []byte
+		if key != "" {
+			k = []byte(key)
+		}
+		return storage.TagFilter{
+			Key:        k,
+			Value:      []byte(value),
+			IsNegative: isNegative,
+			IsRegexp:   isRegexp,
+		}
+	}
+
+	// No metric name filters
+	f([][]storage.TagFilter{{tf("job", "x", false, false)}}, 0, [][]storage.TagFilter{{tf("job", "x", false, false)}}, nil)
+
+	// Regexp and negative filters on metric name aren't expanded
+	f([][]storage.TagFilter{{tf("", "foo", false, true)}}, 0, [][]storage.TagFilter{{tf("", "foo", false, true)}}, nil)
+	f([][]storage.TagFilter{{tf("", "foo", true, false)}}, 0, [][]storage.TagFilter{{tf("", "foo", true, false)}}, nil)
+
+	// Unknown metric name
+	f([][]storage.TagFilter{{tf("", "baz", false, false)}}, 0, [][]storage.TagFilter{{tf("", "baz", false, false)}}, nil)
+
+	// Expand metric name before the cutoff
+	f([][]storage.TagFilter{{tf("", "foo", false, false), tf("job", "x", false, false)}}, cutoff-1,
+		[][]storage.TagFilter{
+			{tf("", "foo", false, false), tf("job", "x", false, false)},
+			{tf("", "foo_old", false, false), tf("job", "x", false, false)},
+			{tf("", "foo_older", false, false), tf("job", "x", false, false)},
+		}, map[string]string{
+			"foo_old":   "foo",
+			"foo_older": "foo",
+		})
+
+	// Expand metric name after the cutoff
+	f([][]storage.TagFilter{{tf("", "foo", false, false)}, {tf("", "bar", false, false)}}, cutoff,
+		[][]storage.TagFilter{
+			{tf("", "foo", false, false)},
+			{tf("", "bar", false, false)},
+			{tf("", "foo_old", false, false)},
+			{tf("", "bar_old", false, false)},
+		}, map[string]string{
+			"foo_old": "foo",
+			"bar_old": "bar",
+		})
+
+	// nil mapping
+	var mnmNil *metricNameMapping
+	tfss := [][]storage.TagFilter{{tf("", "foo", false, false)}}
+	result, renames := mnmNil.expandTagFilterss(tfss, storage.TimeRange{})
+	if !reflect.DeepEqual(result, tfss) || renames != nil {
+		t.Fatalf("unexpected result for nil mapping: %v, %v", result, renames)
+	}
+}
+
+func TestRenameMetricNames(t *testing.T) {
+	marshal := func(metricGroup string, tags ...string) string {
+		var mn storage.MetricName
+		mn.MetricGroup = []byte(metricGroup)
+		for i := 0; i < len(tags); i += 2 {
+			mn.AddTag(tags[i], tags[i+1])
+		}
+		return string(mn.Marshal(nil))
+	}
+	renames := map[string]string{
+		"foo_old": "foo",
+	}
+	f := func(metricNames, resultExpected []string) {
+		t.Helper()
+		result, err := renameMetricNames(metricNames, renames)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result;\ngot\n%q\nwant\n%q", result, resultExpected)
+		}
+	}
+	f([]string{marshal("bar", "job", "x")}, []string{marshal("bar", "job", "x")})
+	f([]string{marshal("foo_old", "job", "x"), marshal("foo", "job", "x"), marshal("foo_old", "job", "y")},
+		[]string{marshal("foo", "job", "x"), marshal("foo", "job", "y")})
+
+	// Verify the mapping label is added.
+	origLabel := *metricNameMappingLabel
+	*metricNameMappingLabel = "alias"
+	defer func() {
+		*metricNameMappingLabel = origLabel
+	}()
+	f([]string{marshal("foo_old", "job", "x"), marshal("foo", "job", "x")},
+		[]string{marshal("foo", "alias", "foo_old", "job", "x"), marshal("foo", "job", "x")})
+
+	// Verify the existing label with the mapping label name is overwritten instead of being duplicated.
+	f([]string{marshal("foo_old", "alias", "y", "job", "x")},
+		[]string{marshal("foo", "alias", "foo_old", "job", "x")})
+}
+
+func TestMetricNameMappingRenameMetricNameValues(t *testing.T) {
+	mnm, err := parseMetricNameMapping([]byte(`
+- old: foo_old
+  new: foo
+- old: bar_old
+  new: bar
+`))
+	if err != nil {
+		t.Fatalf("cannot parse mapping: %s", err)
+	}
+	f := func(values, resultExpected []string) {
+		t.Helper()
+		result := mnm.renameMetricNameValues(values)
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result; got %q; want %q", result, resultExpected)
+		}
+	}
+	f([]string{}, []string{})
+	f([]string{"baz"}, []string{"baz"})
+	f([]string{"bar_old", "baz", "foo", "foo_old"}, []string{"bar", "baz", "foo"})
+}
@@ -783,7 +783,8 @@ func LabelNames(qt *querytracer.Tracer, sq *storage.SearchQuery, maxLabelNames i
 		maxLabelNames = *maxTagKeysPerSearch
 	}
 	tr := sq.GetTimeRange()
-	tfss, err := setupTfss(qt, tr, sq.TagFilterss, sq.MaxMetrics, deadline)
+	tagFilterss, _ := getMetricNameMapping().expandTagFilterss(sq.TagFilterss, tr)
+	tfss, err := setupTfss(qt, tr, tagFilterss, sq.MaxMetrics, deadline)
 	if err != nil {
 		return nil, err
 	}
@@ -856,7 +857,9 @@ func LabelValues(qt *querytracer.Tracer, labelName string, sq *storage.SearchQue
 		maxLabelValues = *maxTagValuesPerSearch
 	}
 	tr := sq.GetTimeRange()
-	tfss, err := setupTfss(qt, tr, sq.TagFilterss, sq.MaxMetrics, deadline)
+	mnm := getMetricNameMapping()
+	tagFilterss, _ := mnm.expandTagFilterss(sq.TagFilterss, tr)
+	tfss, err := setupTfss(qt, tr, tagFilterss, sq.MaxMetrics, deadline)
 	if err != nil {
 		return nil, err
 	}
@@ -864,6 +867,9 @@ func LabelValues(qt *querytracer.Tracer, labelName string, sq *storage.SearchQue
 	if err != nil {
 		return nil, fmt.Errorf("error during label values search on time range for labelName=%q: %w", labelName, err)
 	}
+	if labelName == "" || labelName == "__name__" {
+		labelValues = mnm.renameMetricNameValues(labelValues)
+	}
 	// Sort labelValues like Prometheus does
 	sort.Strings(labelValues)
 	qt.Printf("sort %d label values", len(labelValues))
@@ -941,6 +947,25 @@ func TSDBStatus(qt *querytracer.Tracer, sq *storage.SearchQuery, focusLabel stri
 	return status, nil
 }
 
+// GetMetricUsage returns per-metric-name usage stats.
+func GetMetricUsage(qt *querytracer.Tracer) ([]storage.MetricUsage, error) {
+	qt = qt.NewChild("get metric usage stats")
+	defer qt.Done()
+	mus, err := vmstorage.GetMetricUsage()
+	if err != nil {
+		return nil, fmt.Errorf("error during metric usage request: %w", err)
+	}
+	qt.Printf("got stats for %d metric names", len(mus))
+	return mus, nil
+}
+
+// GetStorageInfo returns storage stats for /api/v1/status/runtimeinfo.
+func GetStorageInfo(qt *querytracer.Tracer) *vmstorage.StorageInfo {
+	qt = qt.NewChild("get storage info")
+	defer qt.Done()
+	return vmstorage.GetStorageInfo()
+}
+
 // SeriesCount returns the number of unique series.
 func SeriesCount(qt *querytracer.Tracer, deadline searchutils.Deadline) (uint64, error) {
 	qt = qt.NewChild("get series count")
@@ -987,7 +1012,8 @@ func ExportBlocks(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline sear
 	if err := vmstorage.CheckTimeRange(tr); err != nil {
 		return err
 	}
-	tfss, err := setupTfss(qt, tr, sq.TagFilterss, sq.MaxMetrics, deadline)
+	tagFilterss, renames := getMetricNameMapping().expandTagFilterss(sq.TagFilterss, tr)
+	tfss, err := setupTfss(qt, tr, tagFilterss, sq.MaxMetrics, deadline)
 	if err != nil {
 		return err
 	}
@@ -1043,6 +1069,10 @@ func ExportBlocks(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline sear
 		if err := xw.mn.Unmarshal(sr.MetricBlockRef.MetricName); err != nil {
 			return fmt.Errorf("cannot unmarshal metricName for block #%d: %w", blocksRead, err)
 		}
+		if renames != nil {
+			// Blocks for series with old names from -search.metricNameMapping are exported under new names.
+			renameMetricNameInplace(&xw.mn, renames)
+		}
 		br := sr.MetricBlockRef.BlockRef
 		br.MustReadBlock(&xw.b)
 		samples += br.RowsCount()
@@ -1099,7 +1129,8 @@ func SearchMetricNames(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline
 	if err := vmstorage.CheckTimeRange(tr); err != nil {
 		return nil, err
 	}
-	tfss, err := setupTfss(qt, tr, sq.TagFilterss, sq.MaxMetrics, deadline)
+	tagFilterss, renames := getMetricNameMapping().expandTagFilterss(sq.TagFilterss, tr)
+	tfss, err := setupTfss(qt, tr, tagFilterss, sq.MaxMetrics, deadline)
 	if err != nil {
 		return nil, err
 	}
@@ -1108,6 +1139,10 @@ func SearchMetricNames(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline
 	if err != nil {
 		return nil, fmt.Errorf("cannot find metric names: %w", err)
 	}
+	metricNames, err = renameMetricNames(metricNames, renames)
+	if err != nil {
+		return nil, err
+	}
 	sort.Strings(metricNames)
 	qt.Printf("sort %d metric names", len(metricNames))
 	return metricNames, nil
@@ -1128,7 +1163,8 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 	if err := vmstorage.CheckTimeRange(tr); err != nil {
 		return nil, err
 	}
-	tfss, err := setupTfss(qt, tr, sq.TagFilterss, sq.MaxMetrics, deadline)
+	tagFilterss, renames := getMetricNameMapping().expandTagFilterss(sq.TagFilterss, tr)
+	tfss, err := setupTfss(qt, tr, tagFilterss, sq.MaxMetrics, deadline)
 	if err != nil {
 		return nil, err
 	}
@@ -1150,6 +1186,7 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 	samples := 0
 	tbf := getTmpBlocksFile()
 	var buf []byte
+	var mnTmp storage.MetricName
 	for sr.NextMetricBlock() {
 		blocksRead++
 		if deadline.Exceeded() {
@@ -1174,6 +1211,15 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 		// Do not intern mb.MetricName, since it leads to increased memory usage.
 		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/3692
 		metricName := sr.MetricBlockRef.MetricName
+		if renames != nil {
+			// Series with old names from -search.metricNameMapping are merged with series with new names.
+			metricName, err = renameMetricName(metricName, renames, &mnTmp)
+			if err != nil {
+				putTmpBlocksFile(tbf)
+				putStorageSearch(sr)
+				return nil, err
+			}
+		}
 		brs := m[string(metricName)]
 		if brs == nil {
 			brs = &blockRefs{}
@@ -1220,6 +1266,75 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 	return &rss, nil
 }
 
+// SeriesExists returns true if at least a single series matching sq contains samples on the sq time range.
+//
+// The search is stopped after the first matching sample is found, so it is much faster
+// than ProcessSearchQuery when only the series existence must be checked.
+func SeriesExists(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline searchutils.Deadline) (bool, error) {
+	qt = qt.NewChild("check for matching series existence: %s", sq)
+	defer qt.Done()
+	if deadline.Exceeded() {
+		return false, fmt.Errorf("timeout exceeded before starting the query processing: %s", deadline.String())
+	}
+
+	// Setup search.
+	tr := sq.GetTimeRange()
+	if err := vmstorage.CheckTimeRange(tr); err != nil {
+		return false, err
+	}
+	tagFilterss, _ := getMetricNameMapping().expandTagFilterss(sq.TagFilterss, tr)
+	tfss, err := setupTfss(qt, tr, tagFilterss, sq.MaxMetrics, deadline)
+	if err != nil {
+		return false, err
+	}
+
+	vmstorage.WG.Add(1)
+	defer vmstorage.WG.Done()
+
+	sr := getStorageSearch()
+	defer putStorageSearch(sr)
+	startTime := time.Now()
+	sr.Init(qt, vmstorage.Storage, tfss, tr, sq.MaxMetrics, deadline.Deadline())
+	indexSearchDuration.UpdateDuration(startTime)
+	tmpBlock := getTmpStorageBlock()
+	defer putTmpStorageBlock(tmpBlock)
+	var timestamps []int64
+	var values []float64
+	blocksRead := 0
+	for sr.NextMetricBlock() {
+		blocksRead++
+		if deadline.Exceeded() {
+			return false, fmt.Errorf("timeout exceeded while fetching data block #%d from storage: %s", blocksRead, deadline.String())
+		}
+		// The block time range may only intersect tr, so verify whether the block contains samples on tr.
+		tmpBlock.Reset()
+		sr.MetricBlockRef.BlockRef.MustReadBlock(tmpBlock)
+		if err := tmpBlock.UnmarshalData(); err != nil {
+			return false, fmt.Errorf("cannot unmarshal block #%d: %w", blocksRead, err)
+		}
+		timestamps, values = tmpBlock.AppendRowsWithTimeRangeFilter(timestamps[:0], values[:0], tr)
+		if len(timestamps) == 0 {
+			continue
+		}
+		if qt.Enabled() {
+			var mn storage.MetricName
+			if err := mn.Unmarshal(sr.MetricBlockRef.MetricName); err != nil {
+				return false, fmt.Errorf("cannot unmarshal metricName: %w", err)
+			}
+			qt.Printf("stop the search after the first matching series %s; blocks read=%d", &mn, blocksRead)
+		}
+		return true, nil
+	}
+	if err := sr.Error(); err != nil {
+		if errors.Is(err, storage.ErrDeadlineExceeded) {
+			return false, fmt.Errorf("timeout exceeded during the query: %s", deadline.String())
+		}
+		return false, fmt.Errorf("search error after reading %d data blocks: %w", blocksRead, err)
+	}
+	qt.Printf("no matching series with samples on the time range; blocks read=%d", blocksRead)
+	return false, nil
+}
+
 var indexSearchDuration = metrics.NewHistogram(`vm_index_search_duration_seconds`)
 
 type blockRef struct {
@@ -0,0 +1,343 @@
+package vmselect
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timerpool"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	lowPriorityConcurrentRequestsPercent = flag.Float64("search.lowPriorityConcurrentRequestsPercent", 50, "The maximum percentage of -search.maxConcurrentRequests, "+
+		"which can be used by concurrently executed requests with 'X-VM-Priority: low' header. See https://docs.victoriametrics.com/#request-priorities")
+	maxHighPriorityConcurrentRequestsPerSourcePercent = flag.Float64("search.maxHighPriorityConcurrentRequestsPerSourcePercent", 0, "The maximum percentage of "+
+		"-search.maxConcurrentRequests, which can be occupied by concurrently executed and queued requests with 'X-VM-Priority: high' header from a single source. "+
+		"The remaining high-priority requests from the source are executed with normal priority. Zero disables the limit. "+
+		"See https://docs.victoriametrics.com/#request-priorities")
+	trustedProxies = flagutil.NewArrayString("search.trustedProxies", "Optional list of IP addresses or CIDRs of trusted proxies such as vmauth. "+
+		"X-Forwarded-For request header is used for determining the source of high-priority requests only if the request is received from a trusted proxy. "+
+		"Otherwise the client address is used as the source. See https://docs.victoriametrics.com/#request-priorities")
+)
+
+var trustedProxyNets []*net.IPNet
+
+func initTrustedProxies() {
+	trustedProxyNets = nil
+	for _, s := range *trustedProxies {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				logger.Fatalf("cannot parse -search.trustedProxies=%q as IP address", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+				bits = 8 * net.IPv4len
+			}
+			trustedProxyNets = append(trustedProxyNets, &net.IPNet{
+				IP:   ip,
+				Mask: net.CIDRMask(bits, bits),
+			})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			logger.Fatalf("cannot parse -search.trustedProxies=%q as CIDR: %s", s, err)
+		}
+		trustedProxyNets = append(trustedProxyNets, ipNet)
+	}
+}
+
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trustedProxyNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Request priorities, which can be set via X-VM-Priority request header.
+//
+// Lower values mean higher priority.
+const (
+	priorityHigh = iota
+	priorityNormal
+	priorityLow
+
+	prioritiesCount
+)
+
+var priorityNames = [prioritiesCount]string{"high", "normal", "low"}
+
+// getRequestPriority returns request priority from X-VM-Priority header at r.
+//
+// priorityNormal is returned if the header is missing.
+func getRequestPriority(r *http.Request) (int, error) {
+	s := r.Header.Get("X-VM-Priority")
+	switch strings.ToLower(s) {
+	case "", "normal":
+		return priorityNormal, nil
+	case "high":
+		return priorityHigh, nil
+	case "low":
+		return priorityLow, nil
+	default:
+		return 0, fmt.Errorf("unsupported X-VM-Priority header value: %q; supported values: high, normal, low", s)
+	}
+}
+
+// getRequestSource returns the source for the request r, which is used for limiting high-priority requests.
+//
+// The source is the client address. If the client is a proxy from -search.trustedProxies, then the source is the last address
+// from X-Forwarded-For header, which doesn't belong to -search.trustedProxies, since the header is added by the proxies such as vmauth.
+// X-Forwarded-For header from untrusted clients is ignored, since it can be set to arbitrary value by the client.
+func getRequestSource(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(host) {
+		return host
+	}
+	xff := r.Header.Get("X-Forwarded-For")
+	if xff == "" {
+		return host
+	}
+	addrs := strings.Split(xff, ",")
+	for i := len(addrs) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(addrs[i])
+		if addr != "" && !isTrustedProxy(addr) {
+			return addr
+		}
+	}
+	return host
+}
+
+// concurrencyLimiter limits the number of concurrently executed requests according to their priorities.
+//
+// Queued requests are started in the order of their priorities, so queued high-priority requests
+// are started before queued requests with lower priorities even if they were queued later.
+// Requests with low priority cannot occupy more than -search.lowPriorityConcurrentRequestsPercent of the limit.
+type concurrencyLimiter struct {
+	mu sync.Mutex
+
+	maxConcurrent int
+	running       int
+	runningLow    int
+
+	// queues contain FIFO queues of waiting requests per each priority.
+	queues [prioritiesCount][]*concurrencyWaiter
+
+	// highPrioritySources contains the number of running and queued high-priority requests per source.
+	highPrioritySources map[string]int
+}
+
+type concurrencyWaiter struct {
+	// ch is closed when the waiter is allowed to run.
+	ch chan struct{}
+
+	// granted is set to true under concurrencyLimiter.mu when the waiter is allowed to run.
+	granted bool
+}
+
+func newConcurrencyLimiter() *concurrencyLimiter {
+	return &concurrencyLimiter{
+		highPrioritySources: make(map[string]int),
+	}
+}
+
+// setMaxConcurrent sets the maximum number of concurrently executed requests to n.
+//
+// Requests, which are already executed, aren't interrupted if n is reduced.
+func (cl *concurrencyLimiter) setMaxConcurrent(n int) {
+	cl.mu.Lock()
+	cl.maxConcurrent = n
+	cl.startQueuedLocked()
+	cl.mu.Unlock()
+}
+
+// getMaxConcurrent returns the maximum number of concurrently executed requests.
+func (cl *concurrencyLimiter) getMaxConcurrent() int {
+	cl.mu.Lock()
+	n := cl.maxConcurrent
+	cl.mu.Unlock()
+	return n
+}
+
+// getRunning returns the number of currently executed requests.
+func (cl *concurrencyLimiter) getRunning() int {
+	cl.mu.Lock()
+	n := cl.running
+	cl.mu.Unlock()
+	return n
+}
+
+// getQueued returns the number of queued requests with the given priority.
+func (cl *concurrencyLimiter) getQueued(priority int) int {
+	cl.mu.Lock()
+	n := len(cl.queues[priority])
+	cl.mu.Unlock()
+	return n
+}
+
+// registerHighPriority registers high-priority request from the given source.
+//
+// false is returned if the source exceeds -search.maxHighPriorityConcurrentRequestsPerSourcePercent.
+// unregisterHighPriority must be called for the source when the request is finished if true is returned.
+func (cl *concurrencyLimiter) registerHighPriority(source string) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if *maxHighPriorityConcurrentRequestsPerSourcePercent > 0 {
+		limit := getPercentLimit(cl.maxConcurrent, *maxHighPriorityConcurrentRequestsPerSourcePercent)
+		if cl.highPrioritySources[source] >= limit {
+			return false
+		}
+	}
+	cl.highPrioritySources[source]++
+	return true
+}
+
+func (cl *concurrencyLimiter) unregisterHighPriority(source string) {
+	cl.mu.Lock()
+	n := cl.highPrioritySources[source] - 1
+	if n <= 0 {
+		delete(cl.highPrioritySources, source)
+	} else {
+		cl.highPrioritySources[source] = n
+	}
+	cl.mu.Unlock()
+}
+
+// acquire waits for up to maxWait until the request with the given priority is allowed to run.
+//
+// wait is set to true if the request had to wait in the queue.
+// release must be called with the same priority when the request is finished if ok is true.
+func (cl *concurrencyLimiter) acquire(priority int, maxWait time.Duration) (ok, wait bool) {
+	cl.mu.Lock()
+	if !cl.hasQueuedLocked(priority) && cl.canRunLocked(priority) {
+		cl.startLocked(priority)
+		cl.mu.Unlock()
+		return true, false
+	}
+	w := &concurrencyWaiter{
+		ch: make(chan struct{}),
+	}
+	cl.queues[priority] = append(cl.queues[priority], w)
+	cl.mu.Unlock()
+
+	startTime := time.Now()
+	t := timerpool.Get(maxWait)
+	defer timerpool.Put(t)
+	select {
+	case <-w.ch:
+		queueWaitDurations[priority].UpdateDuration(startTime)
+		return true, true
+	case <-t.C:
+		cl.mu.Lock()
+		defer cl.mu.Unlock()
+		if w.granted {
+			// The waiter has been started concurrently with the timeout.
+			queueWaitDurations[priority].UpdateDuration(startTime)
+			return true, true
+		}
+		q := cl.queues[priority]
+		for i := range q {
+			if q[i] == w {
+				cl.queues[priority] = append(q[:i], q[i+1:]...)
+				break
+			}
+		}
+		return false, true
+	}
+}
+
+// release must be called when the request with the given priority acquired via acquire is finished.
+func (cl *concurrencyLimiter) release(priority int) {
+	cl.mu.Lock()
+	cl.running--
+	if priority == priorityLow {
+		cl.runningLow--
+	}
+	cl.startQueuedLocked()
+	cl.mu.Unlock()
+}
+
+// hasQueuedLocked returns true if there are queued requests with the given or higher priority.
+func (cl *concurrencyLimiter) hasQueuedLocked(priority int) bool {
+	for p := 0; p <= priority; p++ {
+		if len(cl.queues[p]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (cl *concurrencyLimiter) canRunLocked(priority int) bool {
+	if cl.running >= cl.maxConcurrent {
+		return false
+	}
+	if priority == priorityLow {
+		return cl.runningLow < getPercentLimit(cl.maxConcurrent, *lowPriorityConcurrentRequestsPercent)
+	}
+	return true
+}
+
+func (cl *concurrencyLimiter) startLocked(priority int) {
+	cl.running++
+	if priority == priorityLow {
+		cl.runningLow++
+	}
+}
+
+// startQueuedLocked starts queued requests in the order of their priorities while there are free slots.
+func (cl *concurrencyLimiter) startQueuedLocked() {
+	for priority := range cl.queues {
+		for len(cl.queues[priority]) > 0 && cl.canRunLocked(priority) {
+			q := cl.queues[priority]
+			w := q[0]
+			q[0] = nil
+			cl.queues[priority] = q[1:]
+			cl.startLocked(priority)
+			w.granted = true
+			close(w.ch)
+		}
+	}
+}
+
+// getPercentLimit returns percent of n. It returns at least 1 if n > 0.
+func getPercentLimit(n int, percent float64) int {
+	limit := int(float64(n) * percent / 100)
+	if limit < 1 && n > 0 {
+		limit = 1
+	}
+	return limit
+}
+
+var queueWaitDurations = func() [prioritiesCount]*metrics.Histogram {
+	var hs [prioritiesCount]*metrics.Histogram
+	for priority, name := range priorityNames {
+		hs[priority] = metrics.NewHistogram(fmt.Sprintf(`vm_concurrent_select_queue_wait_duration_seconds{priority=%q}`, name))
+		p := priority
+		_ = metrics.NewGauge(fmt.Sprintf(`vm_concurrent_select_queued{priority=%q}`, name), func() float64 {
+			return float64(concurrencyLimiterV.getQueued(p))
+		})
+	}
+	return hs
+}()
@@ -0,0 +1,197 @@
+package vmselect
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetRequestPriority(t *testing.T) {
+	f := func(header string, priorityExpected int) {
+		t.Helper()
+		r := &http.Request{
+			Header: http.Header{},
+		}
+		if header != "" {
+			r.Header.Set("X-VM-Priority", header)
+		}
+		priority, err := getRequestPriority(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if priority != priorityExpected {
+			t.Fatalf("unexpected priority for %q; got %d; want %d", header, priority, priorityExpected)
+		}
+	}
+	f("", priorityNormal)
+	f("normal", priorityNormal)
+	f("high", priorityHigh)
+	f("HIGH", priorityHigh)
+	f("low", priorityLow)
+
+	r := &http.Request{
+		Header: http.Header{},
+	}
+	r.Header.Set("X-VM-Priority", "urgent")
+	if _, err := getRequestPriority(r); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestGetRequestSource(t *testing.T) {
+	f := func(remoteAddr, xff, sourceExpected string) {
+		t.Helper()
+		r := &http.Request{
+			RemoteAddr: remoteAddr,
+			Header:     http.Header{},
+		}
+		if xff != "" {
+			r.Header.Set("X-Forwarded-For", xff)
+		}
+		source := getRequestSource(r)
+		if source != sourceExpected {
+			t.Fatalf("unexpected source; got %q; want %q", source, sourceExpected)
+		}
+	}
+	// X-Forwarded-For must be ignored without trusted proxies.
+	f("1.2.3.4:5678", "", "1.2.3.4")
+	f("foobar", "", "foobar")
+	f("1.2.3.4:5678", "10.0.0.1", "1.2.3.4")
+	f("1.2.3.4:5678", "10.0.0.1, 10.0.0.2", "1.2.3.4")
+
+	trustedProxiesOrig := *trustedProxies
+	defer func() {
+		*trustedProxies = trustedProxiesOrig
+		initTrustedProxies()
+	}()
+	*trustedProxies = []string{"1.2.3.4", "192.168.0.0/16"}
+	initTrustedProxies()
+
+	// X-Forwarded-For must be ignored for untrusted clients.
+	f("1.2.3.5:5678", "10.0.0.1", "1.2.3.5")
+
+	// X-Forwarded-For must be used for trusted proxies.
+	f("1.2.3.4:5678", "", "1.2.3.4")
+	f("1.2.3.4:5678", "10.0.0.1", "10.0.0.1")
+	f("1.2.3.4:5678", "10.0.0.1, 10.0.0.2", "10.0.0.2")
+	f("192.168.1.1:5678", "10.0.0.1, 192.168.1.2", "10.0.0.1")
+	f("192.168.1.1:5678", "192.168.1.2", "192.168.1.1")
+}
+
+func TestConcurrencyLimiterPriorities(t *testing.T) {
+	cl := newConcurrencyLimiter()
+	cl.setMaxConcurrent(1)
+	if ok, wait := cl.acquire(priorityNormal, time.Second); !ok || wait {
+		t.Fatalf("unexpected result for the first request; ok=%v, wait=%v", ok, wait)
+	}
+
+	// Queue requests with different priorities and verify they are started in the order of their priorities.
+	startedCh := make(chan int, 3)
+	for _, priority := range []int{priorityLow, priorityNormal, priorityHigh} {
+		go func(priority int) {
+			ok, wait := cl.acquire(priority, time.Minute)
+			if !ok || !wait {
+				panic("BUG: the queued request must be started after the wait")
+			}
+			startedCh <- priority
+		}(priority)
+		waitForQueued(t, cl, priority, 1)
+	}
+	release := priorityNormal
+	for _, priorityExpected := range []int{priorityHigh, priorityNormal, priorityLow} {
+		cl.release(release)
+		priority := <-startedCh
+		if priority != priorityExpected {
+			t.Fatalf("unexpected priority for the started request; got %s; want %s", priorityNames[priority], priorityNames[priorityExpected])
+		}
+		release = priority
+	}
+	cl.release(release)
+	if n := cl.getRunning(); n != 0 {
+		t.Fatalf("unexpected number of running requests; got %d; want 0", n)
+	}
+
+	// The request must be removed from the queue on timeout.
+	if ok, _ := cl.acquire(priorityNormal, time.Second); !ok {
+		t.Fatalf("the request must be started")
+	}
+	if ok, wait := cl.acquire(priorityHigh, 10*time.Millisecond); ok || !wait {
+		t.Fatalf("unexpected result for the queued request; ok=%v, wait=%v", ok, wait)
+	}
+	if n := cl.getQueued(priorityHigh); n != 0 {
+		t.Fatalf("unexpected number of queued requests after timeout; got %d; want 0", n)
+	}
+	cl.release(priorityNormal)
+}
+
+func TestConcurrencyLimiterLowPriorityShare(t *testing.T) {
+	cl := newConcurrencyLimiter()
+	cl.setMaxConcurrent(4)
+
+	// Low-priority requests cannot occupy more than -search.lowPriorityConcurrentRequestsPercent=50 of slots.
+	for i := 0; i < 2; i++ {
+		if ok, wait := cl.acquire(priorityLow, time.Second); !ok || wait {
+			t.Fatalf("unexpected result for low-priority request #%d; ok=%v, wait=%v", i, ok, wait)
+		}
+	}
+	if ok, _ := cl.acquire(priorityLow, 10*time.Millisecond); ok {
+		t.Fatalf("low-priority request mustn't be started when the low-priority share is exhausted")
+	}
+	for i := 0; i < 2; i++ {
+		if ok, wait := cl.acquire(priorityNormal, time.Second); !ok || wait {
+			t.Fatalf("unexpected result for normal-priority request #%d; ok=%v, wait=%v", i, ok, wait)
+		}
+	}
+	if n := cl.getRunning(); n != 4 {
+		t.Fatalf("unexpected number of running requests; got %d; want 4", n)
+	}
+
+	// Increasing the limit must start the queued requests.
+	doneCh := make(chan struct{})
+	go func() {
+		if ok, _ := cl.acquire(priorityNormal, time.Minute); !ok {
+			panic("BUG: the queued request must be started")
+		}
+		close(doneCh)
+	}()
+	waitForQueued(t, cl, priorityNormal, 1)
+	cl.setMaxConcurrent(5)
+	<-doneCh
+}
+
+func TestConcurrencyLimiterHighPrioritySources(t *testing.T) {
+	origPercent := *maxHighPriorityConcurrentRequestsPerSourcePercent
+	defer func() {
+		*maxHighPriorityConcurrentRequestsPerSourcePercent = origPercent
+	}()
+	*maxHighPriorityConcurrentRequestsPerSourcePercent = 50
+
+	cl := newConcurrencyLimiter()
+	cl.setMaxConcurrent(4)
+	for i := 0; i < 2; i++ {
+		if !cl.registerHighPriority("foo") {
+			t.Fatalf("high-priority request #%d must be registered", i)
+		}
+	}
+	if cl.registerHighPriority("foo") {
+		t.Fatalf("high-priority request mustn't be registered when the per-source limit is exceeded")
+	}
+	if !cl.registerHighPriority("bar") {
+		t.Fatalf("high-priority request from another source must be registered")
+	}
+	cl.unregisterHighPriority("foo")
+	if !cl.registerHighPriority("foo") {
+		t.Fatalf("high-priority request must be registered after the previous request is finished")
+	}
+}
+
+func waitForQueued(t *testing.T, cl *concurrencyLimiter, priority, nExpected int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for cl.getQueued(priority) != nExpected {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for %d queued requests with %s priority", nExpected, priorityNames[priority])
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,444 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+var cacheWarmConcurrency = flag.Int("search.cacheWarmConcurrency", 1, "The maximum number of concurrently executed queries for warming up the rollup result cache via /internal/cache/warm. "+
+	"These queries aren't limited by -search.maxConcurrentRequests. See https://docs.victoriametrics.com/#cache-warming")
+
+// maxCacheWarmRequestSize is the maximum size of /internal/cache/warm request body.
+const maxCacheWarmRequestSize = 16 * 1024 * 1024
+
+// maxFinishedCacheWarmJobs is the maximum number of finished cache warming jobs to keep for /internal/cache/warm/status.
+const maxFinishedCacheWarmJobs = 100
+
+// cacheWarmQuery is a single query for warming up the rollup result cache.
+//
+// Fields have the same meaning and format as the corresponding /api/v1/query_range args.
+type cacheWarmQuery struct {
+	Query string `json:"query"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	Step  string `json:"step,omitempty"`
+}
+
+// newRequest returns /api/v1/query_range request for q.
+func (q *cacheWarmQuery) newRequest() *http.Request {
+	form := url.Values{}
+	form.Set("query", q.Query)
+	if q.Start != "" {
+		form.Set("start", q.Start)
+	}
+	if q.End != "" {
+		form.Set("end", q.End)
+	}
+	if q.Step != "" {
+		form.Set("step", q.Step)
+	}
+	u := &url.URL{
+		Path:     "/api/v1/query_range",
+		RawQuery: form.Encode(),
+	}
+	return &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		RequestURI: u.RequestURI(),
+		Header:     http.Header{},
+		Form:       form,
+		RemoteAddr: "cache-warm",
+	}
+}
+
+func (q *cacheWarmQuery) validate() error {
+	if q.Query == "" {
+		return fmt.Errorf("missing `query`")
+	}
+	if len(q.Query) > maxQueryLen.IntN() {
+		return fmt.Errorf("too long query; got %d bytes; mustn't exceed `-search.maxQueryLen=%d` bytes", len(q.Query), maxQueryLen.N)
+	}
+	if _, err := metricsql.Parse(q.Query); err != nil {
+		return fmt.Errorf("cannot parse query %q: %w", q.Query, err)
+	}
+	r := q.newRequest()
+	if _, err := searchutils.GetTime(r, "start", 0); err != nil {
+		return err
+	}
+	if _, err := searchutils.GetTime(r, "end", 0); err != nil {
+		return err
+	}
+	if _, err := searchutils.GetDuration(r, "step", defaultStep); err != nil {
+		return err
+	}
+	return nil
+}
+
+// cacheWarmJob is a background job, which executes queries for warming up the rollup result cache.
+type cacheWarmJob struct {
+	id        uint64
+	queries   []cacheWarmQuery
+	startTime time.Time
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+
+	// queriesDone is the number of processed queries including failed queries.
+	queriesDone   uint64
+	queriesFailed uint64
+
+	// mu protects fields below.
+	mu        sync.Mutex
+	status    string
+	endTime   time.Time
+	lastError string
+}
+
+// cacheWarmJobStatus is the status of cacheWarmJob returned from /internal/cache/warm* handlers.
+type cacheWarmJobStatus struct {
+	ID            uint64     `json:"id"`
+	Status        string     `json:"status"`
+	StartTime     time.Time  `json:"startTime"`
+	EndTime       *time.Time `json:"endTime,omitempty"`
+	QueriesTotal  int        `json:"queriesTotal"`
+	QueriesDone   uint64     `json:"queriesDone"`
+	QueriesFailed uint64     `json:"queriesFailed"`
+	LastError     string     `json:"lastError,omitempty"`
+}
+
+func (job *cacheWarmJob) getStatus() *cacheWarmJobStatus {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	js := &cacheWarmJobStatus{
+		ID:            job.id,
+		Status:        job.status,
+		StartTime:     job.startTime,
+		QueriesTotal:  len(job.queries),
+		QueriesDone:   atomic.LoadUint64(&job.queriesDone),
+		QueriesFailed: atomic.LoadUint64(&job.queriesFailed),
+		LastError:     job.lastError,
+	}
+	if !job.endTime.IsZero() {
+		endTime := job.endTime
+		js.EndTime = &endTime
+	}
+	return js
+}
+
+func (job *cacheWarmJob) isRunning() bool {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.status == "running"
+}
+
+func (job *cacheWarmJob) cancel() {
+	job.stopOnce.Do(func() {
+		close(job.stopCh)
+	})
+}
+
+func (job *cacheWarmJob) run() {
+	concurrencyCh := getCacheWarmConcurrencyCh()
+	status := "done"
+	var wg sync.WaitGroup
+	for i := range job.queries {
+		q := &job.queries[i]
+		select {
+		case <-job.stopCh:
+			status = "canceled"
+		case concurrencyCh <- struct{}{}:
+		}
+		if status == "canceled" {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-concurrencyCh
+				wg.Done()
+			}()
+			job.warmQuery(q)
+		}()
+	}
+	wg.Wait()
+
+	job.mu.Lock()
+	job.status = status
+	job.endTime = time.Now()
+	job.mu.Unlock()
+}
+
+func (job *cacheWarmJob) warmQuery(q *cacheWarmQuery) {
+	cacheWarmQueries.Inc()
+	var w discardResponseWriter
+	err := queryRange(nil, time.Now(), &w, q.newRequest())
+	if err != nil {
+		cacheWarmQueryErrors.Inc()
+		atomic.AddUint64(&job.queriesFailed, 1)
+		job.mu.Lock()
+		job.lastError = err.Error()
+		job.mu.Unlock()
+	}
+	atomic.AddUint64(&job.queriesDone, 1)
+}
+
+// discardResponseWriter is http.ResponseWriter, which discards the written response.
+type discardResponseWriter struct {
+	h http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	if w.h == nil {
+		w.h = http.Header{}
+	}
+	return w.h
+}
+
+func (w *discardResponseWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(statusCode int) {}
+
+var (
+	cacheWarmQueries     = metrics.NewCounter(`vm_cache_warm_queries_total`)
+	cacheWarmQueryErrors = metrics.NewCounter(`vm_cache_warm_query_errors_total`)
+)
+
+var (
+	cacheWarmConcurrencyCh     chan struct{}
+	cacheWarmConcurrencyChOnce sync.Once
+)
+
+func getCacheWarmConcurrencyCh() chan struct{} {
+	cacheWarmConcurrencyChOnce.Do(func() {
+		n := *cacheWarmConcurrency
+		if n <= 0 {
+			n = 1
+		}
+		cacheWarmConcurrencyCh = make(chan struct{}, n)
+	})
+	return cacheWarmConcurrencyCh
+}
+
+var cacheWarmJobs struct {
+	mu     sync.Mutex
+	nextID uint64
+	jobs   []*cacheWarmJob
+	wg     sync.WaitGroup
+}
+
+func startCacheWarmJob(queries []cacheWarmQuery) *cacheWarmJob {
+	cacheWarmJobs.mu.Lock()
+	defer cacheWarmJobs.mu.Unlock()
+
+	cacheWarmJobs.nextID++
+	job := &cacheWarmJob{
+		id:        cacheWarmJobs.nextID,
+		queries:   queries,
+		startTime: time.Now(),
+		stopCh:    make(chan struct{}),
+		status:    "running",
+	}
+
+	// Drop the oldest finished jobs.
+	jobs := cacheWarmJobs.jobs
+	finishedJobs := 0
+	for _, j := range jobs {
+		if !j.isRunning() {
+			finishedJobs++
+		}
+	}
+	dst := jobs[:0]
+	for _, j := range jobs {
+		if finishedJobs >= maxFinishedCacheWarmJobs && !j.isRunning() {
+			finishedJobs--
+			continue
+		}
+		dst = append(dst, j)
+	}
+	cacheWarmJobs.jobs = append(dst, job)
+
+	cacheWarmJobs.wg.Add(1)
+	go func() {
+		defer cacheWarmJobs.wg.Done()
+		job.run()
+	}()
+	return job
+}
+
+func getCacheWarmJob(id uint64) *cacheWarmJob {
+	cacheWarmJobs.mu.Lock()
+	defer cacheWarmJobs.mu.Unlock()
+
+	for _, job := range cacheWarmJobs.jobs {
+		if job.id == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// StopCacheWarmJobs cancels all the running cache warming jobs and waits until they are finished.
+func StopCacheWarmJobs() {
+	cacheWarmJobs.mu.Lock()
+	for _, job := range cacheWarmJobs.jobs {
+		job.cancel()
+	}
+	cacheWarmJobs.mu.Unlock()
+
+	cacheWarmJobs.wg.Wait()
+}
+
+// parseCacheWarmQueries parses queries from /internal/cache/warm request body.
+func parseCacheWarmQueries(data []byte) ([]cacheWarmQuery, error) {
+	var req struct {
+		Queries []cacheWarmQuery `json:"queries"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("cannot parse request body: %w", err)
+	}
+	if len(req.Queries) == 0 {
+		return nil, fmt.Errorf("missing `queries` in request body")
+	}
+	for i := range req.Queries {
+		if err := req.Queries[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid queries[%d]: %w", i, err)
+		}
+	}
+	return req.Queries, nil
+}
+
+// CacheWarmHandler processes /internal/cache/warm request.
+//
+// It starts background job, which executes the queries from the request body in order to populate the rollup result cache.
+// See https://docs.victoriametrics.com/#cache-warming
+func CacheWarmHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("unsupported method %s; use POST", r.Method),
+			StatusCode: http.StatusMethodNotAllowed,
+		}
+	}
+	if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		// Request body with other content types such as application/x-www-form-urlencoded may be already consumed during query args parsing.
+		return &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("unsupported Content-Type %q; the request body must be sent with `Content-Type: application/json` header", ct),
+			StatusCode: http.StatusUnsupportedMediaType,
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxCacheWarmRequestSize+1))
+	if err != nil {
+		return fmt.Errorf("cannot read request body: %w", err)
+	}
+	if len(data) > maxCacheWarmRequestSize {
+		return &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("too big request body; mustn't exceed %d bytes", maxCacheWarmRequestSize),
+			StatusCode: http.StatusRequestEntityTooLarge,
+		}
+	}
+	queries, err := parseCacheWarmQueries(data)
+	if err != nil {
+		return &httpserver.ErrorWithStatusCode{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	job := startCacheWarmJob(queries)
+	logger.Infof("started cache warming job #%d with %d queries", job.id, len(queries))
+	return writeCacheWarmResponse(w, job.getStatus())
+}
+
+// CacheWarmStatusHandler processes /internal/cache/warm/status request.
+//
+// It returns statuses for all the cache warming jobs or for the job with the given `id` query arg.
+func CacheWarmStatusHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.FormValue("id") != "" {
+		job, err := getCacheWarmJobFromRequest(r)
+		if err != nil {
+			return err
+		}
+		return writeCacheWarmResponse(w, job.getStatus())
+	}
+	cacheWarmJobs.mu.Lock()
+	jobs := append([]*cacheWarmJob{}, cacheWarmJobs.jobs...)
+	cacheWarmJobs.mu.Unlock()
+
+	statuses := make([]*cacheWarmJobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		statuses = append(statuses, job.getStatus())
+	}
+	return writeCacheWarmResponse(w, statuses)
+}
+
+// CacheWarmCancelHandler processes /internal/cache/warm/cancel request.
+//
+// It cancels the cache warming job with the given `id` query arg. Queries, which are already executed by the job, aren't interrupted.
+func CacheWarmCancelHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("unsupported method %s; use POST", r.Method),
+			StatusCode: http.StatusMethodNotAllowed,
+		}
+	}
+	job, err := getCacheWarmJobFromRequest(r)
+	if err != nil {
+		return err
+	}
+	job.cancel()
+	logger.Infof("canceled cache warming job #%d", job.id)
+	return writeCacheWarmResponse(w, job.getStatus())
+}
+
+func getCacheWarmJobFromRequest(r *http.Request) (*cacheWarmJob, error) {
+	s := r.FormValue("id")
+	if s == "" {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("missing `id` query arg"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("cannot parse `id` query arg %q: %w", s, err),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	job := getCacheWarmJob(id)
+	if job == nil {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("cannot find cache warming job with id=%d", id),
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return job, nil
+}
+
+func writeCacheWarmResponse(w http.ResponseWriter, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal cache warming response: %s", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := fmt.Fprintf(w, `{"status":"success","data":%s}`, data); err != nil {
+		return fmt.Errorf("cannot send cache warming response to remote client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package prometheus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCacheWarmQueriesSuccess(t *testing.T) {
+	f := func(data string, queriesExpected []cacheWarmQuery) {
+		t.Helper()
+		queries, err := parseCacheWarmQueries([]byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(queries, queriesExpected) {
+			t.Fatalf("unexpected queries; got %+v; want %+v", queries, queriesExpected)
+		}
+	}
+	f(`{"queries":[{"query":"up"}]}`, []cacheWarmQuery{{Query: "up"}})
+	f(`{"queries":[{"query":"rate(foo[5m])","start":"-1d","end":"2023-01-02T00:00:00Z","step":"1m"},{"query":"bar","start":"1672531200","step":"30"}]}`, []cacheWarmQuery{
+		{Query: "rate(foo[5m])", Start: "-1d", End: "2023-01-02T00:00:00Z", Step: "1m"},
+		{Query: "bar", Start: "1672531200", Step: "30"},
+	})
+}
+
+func TestParseCacheWarmQueriesFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		if _, err := parseCacheWarmQueries([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error for %s", data)
+		}
+	}
+	f(``)
+	f(`{"queries":[]}`)
+	f(`{"queries":[{"start":"-1h"}]}`)
+	f(`{"queries":[{"query":"sum("}]}`)
+	f(`{"queries":[{"query":"up","start":"foo"}]}`)
+	f(`{"queries":[{"query":"up","end":"2023-13-45"}]}`)
+	f(`{"queries":[{"query":"up","step":"-1m"}]}`)
+}
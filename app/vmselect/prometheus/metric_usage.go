@@ -0,0 +1,119 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/bufferedwriter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// metricUsageEntry is a single entry returned from /api/v1/status/metric_usage.
+type metricUsageEntry struct {
+	storage.MetricUsage
+
+	IngestionRate float64 `json:"ingestionRate"`
+}
+
+// MetricUsageHandler processes /api/v1/status/metric_usage request.
+//
+// It returns per-metric-name ingestion and query stats collected when -storage.trackMetricUsage is set.
+// The following optional query args are supported:
+//
+//   - topN - the number of metric names to return. By default 10 metric names are returned.
+//   - sort - either `ingestion_rate` (the default) or `query_requests`.
+//   - unqueried_days - return only metric names, which weren't queried during the given number of days.
+func MetricUsageHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer metricUsageDuration.UpdateDuration(startTime)
+
+	topN := 10
+	if topNStr := r.FormValue("topN"); len(topNStr) > 0 {
+		n, err := strconv.Atoi(topNStr)
+		if err != nil {
+			return fmt.Errorf("cannot parse `topN` arg %q: %w", topNStr, err)
+		}
+		if n <= 0 {
+			n = 1
+		}
+		if n > 1000 {
+			n = 1000
+		}
+		topN = n
+	}
+	byQueryRequests := false
+	switch sortBy := r.FormValue("sort"); sortBy {
+	case "", "ingestion_rate":
+	case "query_requests":
+		byQueryRequests = true
+	default:
+		return fmt.Errorf("unsupported `sort` arg %q; supported values: ingestion_rate, query_requests", sortBy)
+	}
+	var unqueriedFor time.Duration
+	if daysStr := r.FormValue("unqueried_days"); len(daysStr) > 0 {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil {
+			return fmt.Errorf("cannot parse `unqueried_days` arg %q: %w", daysStr, err)
+		}
+		if days <= 0 {
+			return fmt.Errorf("`unqueried_days` arg must be positive; got %d", days)
+		}
+		unqueriedFor = time.Duration(days) * 24 * time.Hour
+	}
+
+	mus, err := netstorage.GetMetricUsage(qt)
+	if err != nil {
+		return fmt.Errorf("cannot obtain metric usage stats: %w", err)
+	}
+	totalMetricNames := len(mus)
+	entries := getTopMetricUsageEntries(mus, topN, byQueryRequests, unqueriedFor, fasttime.UnixTimestamp())
+	data, err := json.Marshal(entries)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal metric usage entries: %s", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	fmt.Fprintf(bw, `{"status":"success","data":{"totalMetricNames":%d,"metrics":%s}}`, totalMetricNames, data)
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot send metric usage response to remote client: %w", err)
+	}
+	return nil
+}
+
+var metricUsageDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/status/metric_usage"}`)
+
+// getTopMetricUsageEntries returns up to topN entries from mus sorted according to byQueryRequests.
+//
+// If unqueriedFor > 0, then only metric names, which weren't queried during the last unqueriedFor, are returned.
+func getTopMetricUsageEntries(mus []storage.MetricUsage, topN int, byQueryRequests bool, unqueriedFor time.Duration, currentTime uint64) []metricUsageEntry {
+	if unqueriedFor > 0 {
+		dst := mus[:0]
+		for _, mu := range mus {
+			if mu.IsUnqueriedFor(unqueriedFor, currentTime) {
+				dst = append(dst, mu)
+			}
+		}
+		mus = dst
+	}
+	storage.SortMetricUsage(mus, byQueryRequests, currentTime)
+	if len(mus) > topN {
+		mus = mus[:topN]
+	}
+	entries := make([]metricUsageEntry, len(mus))
+	for i, mu := range mus {
+		entries[i] = metricUsageEntry{
+			MetricUsage:   mu,
+			IngestionRate: mu.IngestionRate(currentTime),
+		}
+	}
+	return entries
+}
@@ -0,0 +1,44 @@
+package prometheus
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestGetTopMetricUsageEntries(t *testing.T) {
+	const currentTime = 10 * 24 * 3600
+	mus := []storage.MetricUsage{
+		{MetricName: "rarely_ingested", IngestedRows: 10, FirstSeenTimestamp: 0},
+		{MetricName: "queried", IngestedRows: 1000, QueryRequests: 20, FirstSeenTimestamp: 0, LastQueryTimestamp: currentTime - 3600},
+		{MetricName: "queried_long_ago", IngestedRows: 500, QueryRequests: 30, FirstSeenTimestamp: 0, LastQueryTimestamp: 3600},
+		{MetricName: "new", IngestedRows: 100, FirstSeenTimestamp: currentTime - 100},
+	}
+	f := func(topN int, byQueryRequests bool, unqueriedFor time.Duration, metricNamesExpected []string) {
+		t.Helper()
+		musCopy := append([]storage.MetricUsage{}, mus...)
+		entries := getTopMetricUsageEntries(musCopy, topN, byQueryRequests, unqueriedFor, currentTime)
+		var metricNames []string
+		for _, e := range entries {
+			metricNames = append(metricNames, e.MetricName)
+			if rateExpected := e.MetricUsage.IngestionRate(currentTime); e.IngestionRate != rateExpected {
+				t.Fatalf("unexpected ingestion rate for %q; got %v; want %v", e.MetricName, e.IngestionRate, rateExpected)
+			}
+		}
+		if !reflect.DeepEqual(metricNames, metricNamesExpected) {
+			t.Fatalf("unexpected metric names; got %q; want %q", metricNames, metricNamesExpected)
+		}
+	}
+
+	// Sort by ingestion rate
+	f(10, false, 0, []string{"new", "queried", "queried_long_ago", "rarely_ingested"})
+	f(2, false, 0, []string{"new", "queried"})
+
+	// Sort by query requests
+	f(10, true, 0, []string{"queried_long_ago", "queried", "new", "rarely_ingested"})
+
+	// Metric names, which weren't queried during the last 7 days
+	f(10, false, 7*24*time.Hour, []string{"queried_long_ago", "rarely_ingested"})
+}
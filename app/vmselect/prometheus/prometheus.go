@@ -6,6 +6,7 @@ import (
 	"math"
 	"net/http"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -41,7 +42,10 @@ var (
 		"Prometheus data model closer to Influx-style data model. See https://prometheus.io/docs/prometheus/latest/querying/basics/#staleness for details. "+
 		"See also '-search.setLookbackToStep' flag")
 	setLookbackToStep = flag.Bool("search.setLookbackToStep", false, "Whether to fix lookback interval to 'step' query arg value. "+
-		"If set to true, the query model becomes closer to InfluxDB data model. If set to true, then -search.maxLookback and -search.maxStalenessInterval are ignored")
+		"If set to true, the query model becomes closer to InfluxDB data model. If set to true, then -search.maxLookback and -search.maxStalenessInterval are ignored. "+
+		"The lookback interval can be overridden on per-query basis via lookback_delta arg")
+	maxLookbackDelta = flag.Duration("search.maxLookbackDelta", 0, "The maximum value for lookback_delta and max_lookback query args. "+
+		"Bigger values are limited to this value. There is no limit if zero")
 	maxStepForPointsAdjustment = flag.Duration("search.maxStepForPointsAdjustment", time.Minute, "The maximum step when /api/v1/query_range handler adjusts "+
 		"points with timestamps closer than -search.latencyOffset to the current time. The adjustment is needed because such points may contain incomplete data")
 
@@ -53,6 +57,9 @@ var (
 	maxPointsPerTimeseries = flag.Int("search.maxPointsPerTimeseries", 30e3, "The maximum points per a single timeseries returned from /api/v1/query_range. "+
 		"This option doesn't limit the number of scanned raw samples in the database. The main purpose of this option is to limit the number of per-series points "+
 		"returned to graphing UI such as VMUI or Grafana. There is no sense in setting this limit to values bigger than the horizontal resolution of the graph")
+	autoAdjustStep = flag.Bool("search.autoAdjustStep", false, "Whether to automatically increase the step for /api/v1/query_range queries, which would return more than "+
+		"-search.maxPointsPerTimeseries points per series, instead of returning an error. The adjusted step is returned in the adjustedStep response field "+
+		"together with a warning. The adjustment can be disabled on a per-query basis by passing exact_step=1 query arg")
 )
 
 // Default step used if not set.
@@ -625,19 +632,45 @@ func SeriesHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseW
 	if err != nil {
 		return err
 	}
+	offset, err := searchutils.GetInt(r, "offset")
+	if err != nil {
+		return err
+	}
+	if offset < 0 {
+		return fmt.Errorf("`offset` arg cannot be negative; got %d", offset)
+	}
+	sorted := searchutils.GetBool(r, "sorted")
 
 	minLimit := *maxSeriesLimit
-	if limit > 0 && limit < *maxSeriesLimit {
-		minLimit = limit
+	if !sorted && limit > 0 && limit+offset < *maxSeriesLimit {
+		// The limit cannot be pushed down to the storage for sorted results,
+		// since all the matching series must be fetched before sorting.
+		minLimit = limit + offset
 	}
 	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, minLimit)
 	metricNames, err := netstorage.SearchMetricNames(qt, sq, cp.deadline)
 	if err != nil {
+		if sorted {
+			return fmt.Errorf("cannot fetch time series for %q: %w; the number of series, which can be sorted with `sorted=1` arg, "+
+				"is limited by -search.maxSeries=%d", sq, err, *maxSeriesLimit)
+		}
 		return fmt.Errorf("cannot fetch time series for %q: %w", sq, err)
 	}
+	if sorted {
+		metricNames, err = sortMetricNamesByLabels(metricNames)
+		if err != nil {
+			return err
+		}
+		qt.Printf("sort %d series by labels", len(metricNames))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	bw := bufferedwriter.Get(w)
 	defer bufferedwriter.Put(bw)
+	if offset >= len(metricNames) {
+		metricNames = nil
+	} else {
+		metricNames = metricNames[offset:]
+	}
 	if limit > 0 && limit < len(metricNames) {
 		metricNames = metricNames[:limit]
 	}
@@ -651,6 +684,50 @@ func SeriesHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseW
 	return nil
 }
 
+// sortMetricNamesByLabels sorts marshaled metricNames by metric name and then by labels.
+//
+// This provides stable order for pagination over /api/v1/series results with `limit` and `offset` args.
+func sortMetricNamesByLabels(metricNames []string) ([]string, error) {
+	mns := make([]storage.MetricName, len(metricNames))
+	for i, metricName := range metricNames {
+		if err := mns[i].UnmarshalString(metricName); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal metric name: %w", err)
+		}
+	}
+	idxs := make([]int, len(metricNames))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	sort.Slice(idxs, func(i, j int) bool {
+		return lessMetricNameByLabels(&mns[idxs[i]], &mns[idxs[j]])
+	})
+	result := make([]string, len(metricNames))
+	for i, idx := range idxs {
+		result[i] = metricNames[idx]
+	}
+	return result, nil
+}
+
+func lessMetricNameByLabels(a, b *storage.MetricName) bool {
+	if string(a.MetricGroup) != string(b.MetricGroup) {
+		return string(a.MetricGroup) < string(b.MetricGroup)
+	}
+	n := len(a.Tags)
+	if len(b.Tags) < n {
+		n = len(b.Tags)
+	}
+	for i := 0; i < n; i++ {
+		ta, tb := &a.Tags[i], &b.Tags[i]
+		if string(ta.Key) != string(tb.Key) {
+			return string(ta.Key) < string(tb.Key)
+		}
+		if string(ta.Value) != string(tb.Value) {
+			return string(ta.Value) < string(tb.Value)
+		}
+	}
+	return len(a.Tags) < len(b.Tags)
+}
+
 var seriesDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/series"}`)
 
 // QueryHandler processes /api/v1/query request.
@@ -661,7 +738,7 @@ func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWr
 
 	ct := startTime.UnixNano() / 1e6
 	deadline := searchutils.GetDeadlineForQuery(r, startTime)
-	mayCache := !searchutils.GetBool(r, "nocache")
+	mayCache, skipCacheRead := getCacheOptions(r)
 	query := r.FormValue("query")
 	if len(query) == 0 {
 		return fmt.Errorf("missing `query` arg")
@@ -670,17 +747,21 @@ func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWr
 	if err != nil {
 		return err
 	}
-	lookbackDelta, err := getMaxLookback(r)
+	maxLookback, err := getMaxLookback(r)
 	if err != nil {
 		return err
 	}
-	step, err := searchutils.GetDuration(r, "step", lookbackDelta)
+	step, err := searchutils.GetDuration(r, "step", maxLookback)
 	if err != nil {
 		return err
 	}
 	if step <= 0 {
 		step = defaultStep
 	}
+	lookbackDelta, isExactLookbackDelta, err := getLookbackDelta(r, step)
+	if err != nil {
+		return err
+	}
 
 	if len(query) > maxQueryLen.IntN() {
 		return fmt.Errorf("too long query; got %d bytes; mustn't exceed `-search.maxQueryLen=%d` bytes", len(query), maxQueryLen.N)
@@ -738,8 +819,8 @@ func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWr
 	if err != nil {
 		return err
 	}
-	if !searchutils.GetBool(r, "nocache") && ct-start < queryOffset && start-ct < queryOffset {
-		// Adjust start time only if `nocache` arg isn't set.
+	if mayCache && ct-start < queryOffset && start-ct < queryOffset {
+		// Adjust start time only if the cache isn't disabled via `nocache` arg or `Cache-Control: no-store` header.
 		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/241
 		startPrev := start
 		start = ct - queryOffset
@@ -757,6 +838,7 @@ func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWr
 		QuotedRemoteAddr:    httpserver.GetQuotedRemoteAddr(r),
 		Deadline:            deadline,
 		MayCache:            mayCache,
+		SkipCacheRead:       skipCacheRead,
 		LookbackDelta:       lookbackDelta,
 		RoundDigits:         getRoundDigits(r),
 		EnforcedTagFilterss: etfs,
@@ -764,6 +846,8 @@ func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWr
 			return httpserver.GetRequestURI(r)
 		},
 
+		IsExactLookbackDelta: isExactLookbackDelta,
+
 		QueryStats: qs,
 	}
 	result, err := promql.Exec(qt, ec, query, true)
@@ -805,6 +889,10 @@ var queryDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v
 func QueryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
 	defer queryRangeDuration.UpdateDuration(startTime)
 
+	return queryRange(qt, startTime, w, r)
+}
+
+func queryRange(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
 	ct := startTime.UnixNano() / 1e6
 	query := r.FormValue("query")
 	if len(query) == 0 {
@@ -835,8 +923,8 @@ func QueryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 func queryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, query string,
 	start, end, step int64, r *http.Request, ct int64, etfs [][]storage.TagFilter) error {
 	deadline := searchutils.GetDeadlineForQuery(r, startTime)
-	mayCache := !searchutils.GetBool(r, "nocache")
-	lookbackDelta, err := getMaxLookback(r)
+	mayCache, skipCacheRead := getCacheOptions(r)
+	lookbackDelta, isExactLookbackDelta, err := getLookbackDelta(r, step)
 	if err != nil {
 		return err
 	}
@@ -848,8 +936,18 @@ func queryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 	if start > end {
 		end = start + defaultStep
 	}
+	var adjustedStep int64
+	var warnings []string
 	if err := promql.ValidateMaxPointsPerSeries(start, end, step, *maxPointsPerTimeseries); err != nil {
-		return fmt.Errorf("%w; (see -search.maxPointsPerTimeseries command-line flag)", err)
+		if !*autoAdjustStep || step <= 0 || searchutils.GetBool(r, "exact_step") {
+			return fmt.Errorf("%w; (see -search.maxPointsPerTimeseries command-line flag)", err)
+		}
+		adjustedStep = getAdjustedStep(start, end, step, *maxPointsPerTimeseries)
+		warnings = append(warnings, fmt.Sprintf("step has been increased from %.3fs to %.3fs, since the original step results in more than %d points per series "+
+			"(see -search.maxPointsPerTimeseries command-line flag); pass exact_step=1 query arg in order to disable the adjustment",
+			float64(step)/1e3, float64(adjustedStep)/1e3, *maxPointsPerTimeseries))
+		step = adjustedStep
+		autoAdjustedSteps.Inc()
 	}
 	if mayCache {
 		start, end = promql.AdjustStartEnd(start, end, step)
@@ -865,6 +963,7 @@ func queryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 		QuotedRemoteAddr:    httpserver.GetQuotedRemoteAddr(r),
 		Deadline:            deadline,
 		MayCache:            mayCache,
+		SkipCacheRead:       skipCacheRead,
 		LookbackDelta:       lookbackDelta,
 		RoundDigits:         getRoundDigits(r),
 		EnforcedTagFilterss: etfs,
@@ -872,6 +971,8 @@ func queryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 			return httpserver.GetRequestURI(r)
 		},
 
+		IsExactLookbackDelta: isExactLookbackDelta,
+
 		QueryStats: qs,
 	}
 	result, err := promql.Exec(qt, ec, query, false)
@@ -898,13 +999,35 @@ func queryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 	qtDone := func() {
 		qt.Donef("start=%d, end=%d, step=%d, query=%q: series=%d", start, end, step, query, len(result))
 	}
-	WriteQueryRangeResponse(bw, result, qt, qtDone, qs)
+	WriteQueryRangeResponse(bw, result, qt, qtDone, qs, adjustedStep, warnings)
 	if err := bw.Flush(); err != nil {
 		return fmt.Errorf("cannot send query range response to remote client: %w", err)
 	}
 	return nil
 }
 
+var autoAdjustedSteps = metrics.NewCounter(`vm_query_range_auto_adjusted_steps_total`)
+
+// getAdjustedStep returns the smallest step, which results in no more than maxPoints points per series on the [start..end] time range.
+//
+// The returned step is a multiple of the original step with 1-2-5 multipliers such as 2, 5, 10, 20, 50, etc.,
+// so the returned points are aligned with the points for the original step.
+func getAdjustedStep(start, end, step int64, maxPoints int) int64 {
+	if maxPoints < 1 {
+		maxPoints = 1
+	}
+	minStep := (end-start)/int64(maxPoints) + 1
+	multiplier := int64(1)
+	for {
+		for _, k := range []int64{1, 2, 5} {
+			if step*multiplier*k >= minStep {
+				return step * multiplier * k
+			}
+		}
+		multiplier *= 10
+	}
+}
+
 func removeEmptyValuesAndTimeseries(tss []netstorage.Result) []netstorage.Result {
 	dst := tss[:0]
 	for i := range tss {
@@ -980,26 +1103,59 @@ func adjustLastPoints(tss []netstorage.Result, start, end int64) []netstorage.Re
 	return tss
 }
 
+// getLookbackDelta returns the lookback delta for the query with the given step.
+//
+// The lookback delta is determined in the following order:
+//
+//  1. lookback_delta query arg. It is used as the exact lookbehind window for instant vector selectors as Prometheus does.
+//  2. The query step if -search.setLookbackToStep is set. It is used as the exact lookbehind window for instant vector selectors.
+//  3. max_lookback query arg, -search.maxLookback and -search.maxStalenessInterval. See getMaxLookback.
+//     They limit the lookbehind window, which is automatically detected from the interval between raw samples.
+//
+// The returned bool is set to true if the returned lookback delta must be used as the exact lookbehind window.
+func getLookbackDelta(r *http.Request, step int64) (int64, bool, error) {
+	lookbackDelta, err := searchutils.GetDuration(r, "lookback_delta", 0)
+	if err != nil {
+		return 0, false, err
+	}
+	if lookbackDelta > 0 {
+		return limitLookbackDelta(lookbackDelta), true, nil
+	}
+	if *setLookbackToStep {
+		return step, true, nil
+	}
+	maxLookback, err := getMaxLookback(r)
+	if err != nil {
+		return 0, false, err
+	}
+	return maxLookback, false, nil
+}
+
+// getMaxLookback returns the maximum lookbehind window from max_lookback query arg
+// or from -search.maxLookback and -search.maxStalenessInterval command-line flags.
 func getMaxLookback(r *http.Request) (int64, error) {
 	d := maxLookback.Milliseconds()
 	if d == 0 {
 		d = maxStalenessInterval.Milliseconds()
 	}
-	maxLookback, err := searchutils.GetDuration(r, "max_lookback", d)
+	maxLookback, err := searchutils.GetDuration(r, "max_lookback", 0)
 	if err != nil {
 		return 0, err
 	}
-	d = maxLookback
-	if *setLookbackToStep {
-		step, err := searchutils.GetDuration(r, "step", d)
-		if err != nil {
-			return 0, err
-		}
-		d = step
+	if maxLookback > 0 {
+		d = limitLookbackDelta(maxLookback)
 	}
 	return d, nil
 }
 
+// limitLookbackDelta limits the lookback delta passed via query args to -search.maxLookbackDelta.
+func limitLookbackDelta(d int64) int64 {
+	if n := maxLookbackDelta.Milliseconds(); n > 0 && d > n {
+		return n
+	}
+	return d
+}
+
 func getTagFilterssFromMatches(matches []string) ([][]storage.TagFilter, error) {
 	tagFilterss := make([][]storage.TagFilter, 0, len(matches))
 	for _, match := range matches {
@@ -1012,6 +1168,31 @@ func getTagFilterssFromMatches(matches []string) ([][]storage.TagFilter, error)
 	return tagFilterss, nil
 }
 
+// getCacheOptions returns whether the response for r may be cached and whether reading the response from cache must be skipped.
+//
+// The cache is disabled with `nocache=1` query arg or with `Cache-Control: no-store` request header,
+// while `Cache-Control: no-cache` request header disables only reading from the cache.
+func getCacheOptions(r *http.Request) (bool, bool) {
+	if searchutils.GetBool(r, "nocache") {
+		return false, false
+	}
+	skipCacheRead := false
+	for _, cc := range r.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(cc, ",") {
+			if n := strings.IndexByte(directive, '='); n >= 0 {
+				directive = directive[:n]
+			}
+			switch strings.ToLower(strings.TrimSpace(directive)) {
+			case "no-store":
+				return false, false
+			case "no-cache":
+				skipCacheRead = true
+			}
+		}
+	}
+	return true, skipCacheRead
+}
+
 func getRoundDigits(r *http.Request) int {
 	s := r.FormValue("round_digits")
 	if len(s) == 0 {
@@ -5,8 +5,11 @@ import (
 	"net/http"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
 
 func TestRemoveEmptyValuesAndTimeseries(t *testing.T) {
@@ -229,3 +232,161 @@ func TestGetLatencyOffsetMillisecondsFailure(t *testing.T) {
 	}
 	f("http://localhost?latency_offset=foobar")
 }
+
+func TestGetCacheOptions(t *testing.T) {
+	f := func(url string, cacheControl []string, mayCacheExpected, skipCacheReadExpected bool) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("unexpected error in NewRequest(%q): %s", url, err)
+		}
+		for _, v := range cacheControl {
+			r.Header.Add("Cache-Control", v)
+		}
+		mayCache, skipCacheRead := getCacheOptions(r)
+		if mayCache != mayCacheExpected {
+			t.Fatalf("unexpected mayCache; got %v; want %v", mayCache, mayCacheExpected)
+		}
+		if skipCacheRead != skipCacheReadExpected {
+			t.Fatalf("unexpected skipCacheRead; got %v; want %v", skipCacheRead, skipCacheReadExpected)
+		}
+	}
+	f("http://localhost", nil, true, false)
+	f("http://localhost?nocache=1", nil, false, false)
+	f("http://localhost?nocache=1", []string{"no-cache"}, false, false)
+	f("http://localhost", []string{"max-age=0"}, true, false)
+	f("http://localhost", []string{"no-cache"}, true, true)
+	f("http://localhost", []string{"No-Cache"}, true, true)
+	f("http://localhost", []string{`no-cache="Set-Cookie"`}, true, true)
+	f("http://localhost", []string{"max-age=0, no-cache"}, true, true)
+	f("http://localhost", []string{"no-store"}, false, false)
+	f("http://localhost", []string{"no-cache", "no-store"}, false, false)
+	f("http://localhost", []string{"no-cache, no-store"}, false, false)
+}
+
+func TestGetLookbackDelta(t *testing.T) {
+	f := func(url string, step int64, lookbackDeltaExpected int64, isExactExpected bool) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("unexpected error in NewRequest(%q): %s", url, err)
+		}
+		lookbackDelta, isExact, err := getLookbackDelta(r, step)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if lookbackDelta != lookbackDeltaExpected {
+			t.Fatalf("unexpected lookbackDelta; got %d; want %d", lookbackDelta, lookbackDeltaExpected)
+		}
+		if isExact != isExactExpected {
+			t.Fatalf("unexpected isExact; got %v; want %v", isExact, isExactExpected)
+		}
+	}
+	defer func(maxLookbackOrig time.Duration, setLookbackToStepOrig bool, maxLookbackDeltaOrig time.Duration) {
+		*maxLookback = maxLookbackOrig
+		*setLookbackToStep = setLookbackToStepOrig
+		*maxLookbackDelta = maxLookbackDeltaOrig
+	}(*maxLookback, *setLookbackToStep, *maxLookbackDelta)
+
+	// The lookback delta is automatically detected by default
+	f("http://localhost", 60e3, 0, false)
+
+	// -search.maxLookback limits the lookback delta
+	*maxLookback = 5 * time.Minute
+	f("http://localhost", 60e3, 300e3, false)
+
+	// max_lookback query arg overrides -search.maxLookback
+	f("http://localhost?max_lookback=10m", 60e3, 600e3, false)
+
+	// lookback_delta query arg sets the exact lookback delta and overrides max_lookback
+	f("http://localhost?lookback_delta=15m&max_lookback=10m", 60e3, 900e3, true)
+
+	// -search.setLookbackToStep sets the exact lookback delta to step and overrides max_lookback
+	*setLookbackToStep = true
+	f("http://localhost?max_lookback=10m", 60e3, 60e3, true)
+
+	// lookback_delta query arg overrides -search.setLookbackToStep
+	f("http://localhost?lookback_delta=15m", 60e3, 900e3, true)
+
+	// -search.maxLookbackDelta limits query args
+	*setLookbackToStep = false
+	*maxLookbackDelta = 12 * time.Minute
+	f("http://localhost?lookback_delta=15m", 60e3, 720e3, true)
+	f("http://localhost?max_lookback=15m", 60e3, 720e3, false)
+	f("http://localhost?lookback_delta=10m", 60e3, 600e3, true)
+
+	// -search.maxLookbackDelta doesn't limit -search.maxLookback
+	*maxLookback = 20 * time.Minute
+	f("http://localhost", 60e3, 1200e3, false)
+}
+
+func TestGetAdjustedStep(t *testing.T) {
+	f := func(start, end, step int64, maxPoints int, stepExpected int64) {
+		t.Helper()
+		adjustedStep := getAdjustedStep(start, end, step, maxPoints)
+		if adjustedStep != stepExpected {
+			t.Fatalf("unexpected adjusted step; got %d; want %d", adjustedStep, stepExpected)
+		}
+		if err := promql.ValidateMaxPointsPerSeries(start, end, adjustedStep, maxPoints); err != nil {
+			t.Fatalf("adjusted step must fit maxPoints: %s", err)
+		}
+	}
+	// The original step already fits maxPoints
+	f(0, 100, 10, 11, 10)
+
+	// Nice multipliers
+	f(0, 100, 1, 11, 10)
+	f(0, 100, 1, 30, 5)
+	f(0, 100, 1, 60, 2)
+	f(0, 1000, 3, 11, 150)
+
+	// 90 days with 15s step and 30e3 max points
+	f(0, 90*24*3600*1000, 15000, 30e3, 300000)
+
+	// A single point per series
+	f(0, 100, 1, 1, 200)
+}
+
+func TestSortMetricNamesByLabels(t *testing.T) {
+	f := func(mns [][]string, resultExpected []string) {
+		t.Helper()
+		var metricNames []string
+		for _, labels := range mns {
+			var mn storage.MetricName
+			mn.MetricGroup = []byte(labels[0])
+			for i := 1; i+1 < len(labels); i += 2 {
+				mn.AddTag(labels[i], labels[i+1])
+			}
+			metricNames = append(metricNames, string(mn.Marshal(nil)))
+		}
+		sorted, err := sortMetricNamesByLabels(metricNames)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var result []string
+		for _, metricName := range sorted {
+			var mn storage.MetricName
+			if err := mn.UnmarshalString(metricName); err != nil {
+				t.Fatalf("cannot unmarshal metric name: %s", err)
+			}
+			result = append(result, mn.String())
+		}
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result\ngot\n%q\nwant\n%q", result, resultExpected)
+		}
+	}
+	f(nil, nil)
+	f([][]string{
+		{"up", "job", "node", "pod", "pod-2"},
+		{"up", "job", "node"},
+		{"foo", "job", "node", "pod", "pod-10"},
+		{"up", "job", "api"},
+		{"up", "job", "node", "pod", "pod-10"},
+	}, []string{
+		`foo{job="node",pod="pod-10"}`,
+		`up{job="api"}`,
+		`up{job="node"}`,
+		`up{job="node",pod="pod-10"}`,
+		`up{job="node",pod="pod-2"}`,
+	})
+}
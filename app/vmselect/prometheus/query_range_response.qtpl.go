@@ -11,134 +11,160 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 )
 
-// QueryRangeResponse generates response for /api/v1/query_range.See https://prometheus.io/docs/prometheus/latest/querying/api/#range-queries
+// QueryRangeResponse generates response for /api/v1/query_range.adjustedStep must contain the step in milliseconds if it has been automatically adjusted. Otherwise it must be set to 0.See https://prometheus.io/docs/prometheus/latest/querying/api/#range-queries
 
-//line app/vmselect/prometheus/query_range_response.qtpl:10
+//line app/vmselect/prometheus/query_range_response.qtpl:11
 import (
 	qtio422016 "io"
 
 	qt422016 "github.com/valyala/quicktemplate"
 )
 
-//line app/vmselect/prometheus/query_range_response.qtpl:10
+//line app/vmselect/prometheus/query_range_response.qtpl:11
 var (
 	_ = qtio422016.Copy
 	_ = qt422016.AcquireByteBuffer
 )
 
-//line app/vmselect/prometheus/query_range_response.qtpl:10
-func StreamQueryRangeResponse(qw422016 *qt422016.Writer, rs []netstorage.Result, qt *querytracer.Tracer, qtDone func(), qs *promql.QueryStats) {
-//line app/vmselect/prometheus/query_range_response.qtpl:10
+//line app/vmselect/prometheus/query_range_response.qtpl:11
+func StreamQueryRangeResponse(qw422016 *qt422016.Writer, rs []netstorage.Result, qt *querytracer.Tracer, qtDone func(), qs *promql.QueryStats, adjustedStep int64, warnings []string) {
+//line app/vmselect/prometheus/query_range_response.qtpl:11
 	qw422016.N().S(`{`)
-//line app/vmselect/prometheus/query_range_response.qtpl:13
+//line app/vmselect/prometheus/query_range_response.qtpl:14
 	seriesCount := len(rs)
 	pointsCount := 0
 
-//line app/vmselect/prometheus/query_range_response.qtpl:15
+//line app/vmselect/prometheus/query_range_response.qtpl:16
 	qw422016.N().S(`"status":"success","data":{"resultType":"matrix","result":[`)
-//line app/vmselect/prometheus/query_range_response.qtpl:20
-	if len(rs) > 0 {
 //line app/vmselect/prometheus/query_range_response.qtpl:21
-		streamqueryRangeLine(qw422016, &rs[0])
+	if len(rs) > 0 {
 //line app/vmselect/prometheus/query_range_response.qtpl:22
+		streamqueryRangeLine(qw422016, &rs[0])
+//line app/vmselect/prometheus/query_range_response.qtpl:23
 		pointsCount += len(rs[0].Values)
 
-//line app/vmselect/prometheus/query_range_response.qtpl:23
+//line app/vmselect/prometheus/query_range_response.qtpl:24
 		rs = rs[1:]
 
-//line app/vmselect/prometheus/query_range_response.qtpl:24
+//line app/vmselect/prometheus/query_range_response.qtpl:25
 		for i := range rs {
-//line app/vmselect/prometheus/query_range_response.qtpl:24
-			qw422016.N().S(`,`)
 //line app/vmselect/prometheus/query_range_response.qtpl:25
-			streamqueryRangeLine(qw422016, &rs[i])
+			qw422016.N().S(`,`)
 //line app/vmselect/prometheus/query_range_response.qtpl:26
+			streamqueryRangeLine(qw422016, &rs[i])
+//line app/vmselect/prometheus/query_range_response.qtpl:27
 			pointsCount += len(rs[i].Values)
 
-//line app/vmselect/prometheus/query_range_response.qtpl:27
-		}
 //line app/vmselect/prometheus/query_range_response.qtpl:28
+		}
+//line app/vmselect/prometheus/query_range_response.qtpl:29
 	}
-//line app/vmselect/prometheus/query_range_response.qtpl:28
+//line app/vmselect/prometheus/query_range_response.qtpl:29
 	qw422016.N().S(`]},"stats":{"seriesFetched": "`)
-//line app/vmselect/prometheus/query_range_response.qtpl:32
+//line app/vmselect/prometheus/query_range_response.qtpl:33
 	qw422016.N().D(qs.SeriesFetched)
-//line app/vmselect/prometheus/query_range_response.qtpl:32
+//line app/vmselect/prometheus/query_range_response.qtpl:33
 	qw422016.N().S(`"}`)
 //line app/vmselect/prometheus/query_range_response.qtpl:35
+	if adjustedStep > 0 {
+//line app/vmselect/prometheus/query_range_response.qtpl:35
+		qw422016.N().S(`,"adjustedStep":`)
+//line app/vmselect/prometheus/query_range_response.qtpl:36
+		qw422016.N().F(float64(adjustedStep) / 1e3)
+//line app/vmselect/prometheus/query_range_response.qtpl:37
+	}
+//line app/vmselect/prometheus/query_range_response.qtpl:38
+	if len(warnings) > 0 {
+//line app/vmselect/prometheus/query_range_response.qtpl:38
+		qw422016.N().S(`,"warnings":[`)
+//line app/vmselect/prometheus/query_range_response.qtpl:40
+		qw422016.N().Q(warnings[0])
+//line app/vmselect/prometheus/query_range_response.qtpl:41
+		for _, warning := range warnings[1:] {
+//line app/vmselect/prometheus/query_range_response.qtpl:41
+			qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_range_response.qtpl:42
+			qw422016.N().Q(warning)
+//line app/vmselect/prometheus/query_range_response.qtpl:43
+		}
+//line app/vmselect/prometheus/query_range_response.qtpl:43
+		qw422016.N().S(`]`)
+//line app/vmselect/prometheus/query_range_response.qtpl:45
+	}
+//line app/vmselect/prometheus/query_range_response.qtpl:47
 	qt.Printf("generate /api/v1/query_range response for series=%d, points=%d", seriesCount, pointsCount)
 	qtDone()
 
-//line app/vmselect/prometheus/query_range_response.qtpl:38
+//line app/vmselect/prometheus/query_range_response.qtpl:50
 	streamdumpQueryTrace(qw422016, qt)
-//line app/vmselect/prometheus/query_range_response.qtpl:38
+//line app/vmselect/prometheus/query_range_response.qtpl:50
 	qw422016.N().S(`}`)
-//line app/vmselect/prometheus/query_range_response.qtpl:40
+//line app/vmselect/prometheus/query_range_response.qtpl:52
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:40
-func WriteQueryRangeResponse(qq422016 qtio422016.Writer, rs []netstorage.Result, qt *querytracer.Tracer, qtDone func(), qs *promql.QueryStats) {
-//line app/vmselect/prometheus/query_range_response.qtpl:40
+//line app/vmselect/prometheus/query_range_response.qtpl:52
+func WriteQueryRangeResponse(qq422016 qtio422016.Writer, rs []netstorage.Result, qt *querytracer.Tracer, qtDone func(), qs *promql.QueryStats, adjustedStep int64, warnings []string) {
+//line app/vmselect/prometheus/query_range_response.qtpl:52
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:40
-	StreamQueryRangeResponse(qw422016, rs, qt, qtDone, qs)
-//line app/vmselect/prometheus/query_range_response.qtpl:40
+//line app/vmselect/prometheus/query_range_response.qtpl:52
+	StreamQueryRangeResponse(qw422016, rs, qt, qtDone, qs, adjustedStep, warnings)
+//line app/vmselect/prometheus/query_range_response.qtpl:52
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:40
+//line app/vmselect/prometheus/query_range_response.qtpl:52
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:40
-func QueryRangeResponse(rs []netstorage.Result, qt *querytracer.Tracer, qtDone func(), qs *promql.QueryStats) string {
-//line app/vmselect/prometheus/query_range_response.qtpl:40
+//line app/vmselect/prometheus/query_range_response.qtpl:52
+func QueryRangeResponse(rs []netstorage.Result, qt *querytracer.Tracer, qtDone func(), qs *promql.QueryStats, adjustedStep int64, warnings []string) string {
+//line app/vmselect/prometheus/query_range_response.qtpl:52
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/query_range_response.qtpl:40
-	WriteQueryRangeResponse(qb422016, rs, qt, qtDone, qs)
-//line app/vmselect/prometheus/query_range_response.qtpl:40
+//line app/vmselect/prometheus/query_range_response.qtpl:52
+	WriteQueryRangeResponse(qb422016, rs, qt, qtDone, qs, adjustedStep, warnings)
+//line app/vmselect/prometheus/query_range_response.qtpl:52
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/query_range_response.qtpl:40
+//line app/vmselect/prometheus/query_range_response.qtpl:52
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:40
+//line app/vmselect/prometheus/query_range_response.qtpl:52
 	return qs422016
-//line app/vmselect/prometheus/query_range_response.qtpl:40
+//line app/vmselect/prometheus/query_range_response.qtpl:52
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:42
+//line app/vmselect/prometheus/query_range_response.qtpl:54
 func streamqueryRangeLine(qw422016 *qt422016.Writer, r *netstorage.Result) {
-//line app/vmselect/prometheus/query_range_response.qtpl:42
+//line app/vmselect/prometheus/query_range_response.qtpl:54
 	qw422016.N().S(`{"metric":`)
-//line app/vmselect/prometheus/query_range_response.qtpl:44
+//line app/vmselect/prometheus/query_range_response.qtpl:56
 	streammetricNameObject(qw422016, &r.MetricName)
-//line app/vmselect/prometheus/query_range_response.qtpl:44
+//line app/vmselect/prometheus/query_range_response.qtpl:56
 	qw422016.N().S(`,"values":`)
-//line app/vmselect/prometheus/query_range_response.qtpl:45
+//line app/vmselect/prometheus/query_range_response.qtpl:57
 	streamvaluesWithTimestamps(qw422016, r.Values, r.Timestamps)
-//line app/vmselect/prometheus/query_range_response.qtpl:45
+//line app/vmselect/prometheus/query_range_response.qtpl:57
 	qw422016.N().S(`}`)
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 func writequeryRangeLine(qq422016 qtio422016.Writer, r *netstorage.Result) {
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 	streamqueryRangeLine(qw422016, r)
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 func queryRangeLine(r *netstorage.Result) string {
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 	writequeryRangeLine(qb422016, r)
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 	return qs422016
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:59
 }
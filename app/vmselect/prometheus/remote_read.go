@@ -0,0 +1,355 @@
+package prometheus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/bufferedwriter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+)
+
+var maxRemoteReadRequestSize = flagutil.NewBytes("search.maxRemoteReadRequestSize", 1024*1024, "The maximum size in bytes of a single Prometheus remote read request at /api/v1/read")
+
+const (
+	// maxRemoteReadFrameSize is the maximum size of a single frame in STREAMED_XOR_CHUNKS response.
+	//
+	// The value is the same as the default value for -storage.remote.read-max-bytes-in-frame in Prometheus.
+	maxRemoteReadFrameSize = 1024 * 1024
+
+	// maxSamplesPerRemoteReadChunk is the maximum number of samples per XOR chunk in STREAMED_XOR_CHUNKS response.
+	//
+	// Prometheus uses the same limit for chunks in its tsdb.
+	maxSamplesPerRemoteReadChunk = 120
+
+	streamedRemoteReadContentType = "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse"
+)
+
+// RemoteReadHandler processes Prometheus remote read request at /api/v1/read.
+//
+// Both SAMPLES and STREAMED_XOR_CHUNKS response types are supported.
+// See https://prometheus.io/docs/prometheus/latest/querying/remote_read_api/
+func RemoteReadHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer remoteReadDuration.UpdateDuration(startTime)
+
+	req, err := readRemoteReadRequest(r)
+	if err != nil {
+		return err
+	}
+	responseType, err := negotiateRemoteReadResponseType(req.AcceptedResponseTypes)
+	if err != nil {
+		return &httpserver.ErrorWithStatusCode{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	etfs, err := searchutils.GetExtraTagFilters(r)
+	if err != nil {
+		return err
+	}
+	deadline := searchutils.GetDeadlineForQuery(r, startTime)
+	sqs := make([]*storage.SearchQuery, len(req.Queries))
+	for i, q := range req.Queries {
+		sq, err := getRemoteReadSearchQuery(q, etfs, startTime)
+		if err != nil {
+			return fmt.Errorf("cannot parse query #%d: %w", i+1, err)
+		}
+		sqs[i] = sq
+	}
+	if responseType == prompb.ReadRequest_STREAMED_XOR_CHUNKS {
+		return remoteReadStreamedChunks(w, sqs, deadline)
+	}
+	return remoteReadSamples(w, sqs, deadline)
+}
+
+var remoteReadDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/read"}`)
+
+func readRemoteReadRequest(r *http.Request) (*prompb.ReadRequest, error) {
+	maxSize := maxRemoteReadRequestSize.IntN()
+	compressed, err := io.ReadAll(io.LimitReader(r.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read remote read request: %w", err)
+	}
+	if len(compressed) > maxSize {
+		return nil, fmt.Errorf("too big remote read request; it mustn't exceed -search.maxRemoteReadRequestSize=%d bytes", maxSize)
+	}
+	if n, err := snappy.DecodedLen(compressed); err != nil {
+		return nil, fmt.Errorf("cannot decode snappy-compressed remote read request: %w", err)
+	} else if n > maxSize {
+		return nil, fmt.Errorf("too big remote read request after decompression: %d bytes; it mustn't exceed -search.maxRemoteReadRequestSize=%d bytes", n, maxSize)
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode snappy-compressed remote read request: %w", err)
+	}
+	var req prompb.ReadRequest
+	if err := req.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal remote read request: %w", err)
+	}
+	return &req, nil
+}
+
+// negotiateRemoteReadResponseType returns the first supported response type from accepted.
+//
+// SAMPLES response type is returned if accepted is empty in the same way as Prometheus does.
+func negotiateRemoteReadResponseType(accepted []prompb.ReadRequest_ResponseType) (prompb.ReadRequest_ResponseType, error) {
+	if len(accepted) == 0 {
+		return prompb.ReadRequest_SAMPLES, nil
+	}
+	for _, rt := range accepted {
+		switch rt {
+		case prompb.ReadRequest_SAMPLES, prompb.ReadRequest_STREAMED_XOR_CHUNKS:
+			return rt, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported accepted_response_types: %s; supported types: %s, %s", accepted, prompb.ReadRequest_SAMPLES, prompb.ReadRequest_STREAMED_XOR_CHUNKS)
+}
+
+func getRemoteReadSearchQuery(q *prompb.Query, etfs [][]storage.TagFilter, startTime time.Time) (*storage.SearchQuery, error) {
+	tfs := make([]storage.TagFilter, 0, len(q.Matchers))
+	for _, m := range q.Matchers {
+		tf := storage.TagFilter{
+			Value: []byte(m.Value),
+		}
+		if m.Name != "__name__" {
+			tf.Key = []byte(m.Name)
+		}
+		switch m.Type {
+		case prompb.LabelMatcher_EQ:
+		case prompb.LabelMatcher_NEQ:
+			tf.IsNegative = true
+		case prompb.LabelMatcher_RE:
+			tf.IsRegexp = true
+		case prompb.LabelMatcher_NRE:
+			tf.IsNegative = true
+			tf.IsRegexp = true
+		default:
+			return nil, fmt.Errorf("unsupported label matcher type: %s", m.Type)
+		}
+		tfs = append(tfs, tf)
+	}
+	start := q.StartTimestampMs
+	end := q.EndTimestampMs
+	// Limit the end timestamp in the same way as getCommonParams does.
+	maxTS := startTime.UnixNano()/1e6 + 2*24*3600*1000
+	if end > maxTS {
+		end = maxTS
+	}
+	if end < start {
+		end = start
+	}
+	filterss := searchutils.JoinTagFilterss([][]storage.TagFilter{tfs}, etfs)
+	return storage.NewSearchQuery(start, end, filterss, *maxUniqueTimeseries), nil
+}
+
+func remoteReadSamples(w http.ResponseWriter, sqs []*storage.SearchQuery, deadline searchutils.Deadline) error {
+	resp := &prompb.ReadResponse{
+		Results: make([]*prompb.QueryResult, len(sqs)),
+	}
+	for i, sq := range sqs {
+		rss, err := netstorage.ProcessSearchQuery(nil, sq, deadline)
+		if err != nil {
+			return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
+		}
+		var tssLock sync.Mutex
+		var tss []*prompb.TimeSeries
+		err = rss.RunParallel(nil, func(rs *netstorage.Result, workerID uint) error {
+			samples := make([]prompb.Sample, len(rs.Timestamps))
+			for j, ts := range rs.Timestamps {
+				samples[j] = prompb.Sample{
+					Timestamp: ts,
+					Value:     rs.Values[j],
+				}
+			}
+			ts := &prompb.TimeSeries{
+				Labels:  getRemoteReadLabels(&rs.MetricName),
+				Samples: samples,
+			}
+			tssLock.Lock()
+			tss = append(tss, ts)
+			tssLock.Unlock()
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error during sending data for %q to remote client: %w", sq, err)
+		}
+		sort.Slice(tss, func(i, j int) bool {
+			return lessRemoteReadLabels(tss[i].Labels, tss[j].Labels)
+		})
+		resp.Results[i] = &prompb.QueryResult{
+			Timeseries: tss,
+		}
+	}
+	data, err := resp.Marshal()
+	if err != nil {
+		return fmt.Errorf("cannot marshal remote read response: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+	_, err = w.Write(snappy.Encode(nil, data))
+	return err
+}
+
+func remoteReadStreamedChunks(w http.ResponseWriter, sqs []*storage.SearchQuery, deadline searchutils.Deadline) error {
+	w.Header().Set("Content-Type", streamedRemoteReadContentType)
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+
+	for i, sq := range sqs {
+		rss, err := netstorage.ProcessSearchQuery(nil, sq, deadline)
+		if err != nil {
+			return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
+		}
+		// ChunkedReadResponse requires series sorted by labels, so collect all the series for the query before sending them.
+		var cssLock sync.Mutex
+		var css []*prompb.ChunkedSeries
+		err = rss.RunParallel(nil, func(rs *netstorage.Result, workerID uint) error {
+			cs := &prompb.ChunkedSeries{
+				Labels: getRemoteReadLabels(&rs.MetricName),
+				Chunks: appendRemoteReadChunks(nil, rs.Timestamps, rs.Values),
+			}
+			cssLock.Lock()
+			css = append(css, cs)
+			cssLock.Unlock()
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error during fetching data for %q: %w", sq, err)
+		}
+		sort.Slice(css, func(i, j int) bool {
+			return lessRemoteReadLabels(css[i].Labels, css[j].Labels)
+		})
+		for _, cs := range css {
+			if err := writeRemoteReadChunkedSeries(bw, int64(i), cs.Labels, cs.Chunks); err != nil {
+				return fmt.Errorf("error during sending data for %q to remote client: %w", sq, err)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// lessRemoteReadLabels returns true if the sorted labels a must go before the sorted labels b
+// in the same way as labels.Compare from Prometheus does.
+func lessRemoteReadLabels(a, b []prompb.Label) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].Name != b[i].Name {
+			return a[i].Name < b[i].Name
+		}
+		if a[i].Value != b[i].Value {
+			return a[i].Value < b[i].Value
+		}
+	}
+	return len(a) < len(b)
+}
+
+// getRemoteReadLabels returns labels sorted by name for mn.
+func getRemoteReadLabels(mn *storage.MetricName) []prompb.Label {
+	labels := make([]prompb.Label, 0, len(mn.Tags)+1)
+	if len(mn.MetricGroup) > 0 {
+		labels = append(labels, prompb.Label{
+			Name:  "__name__",
+			Value: string(mn.MetricGroup),
+		})
+	}
+	for _, tag := range mn.Tags {
+		labels = append(labels, prompb.Label{
+			Name:  string(tag.Key),
+			Value: string(tag.Value),
+		})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels
+}
+
+// appendRemoteReadChunks appends XOR chunks with the given samples to dst and returns the result.
+func appendRemoteReadChunks(dst []prompb.Chunk, timestamps []int64, values []float64) []prompb.Chunk {
+	for len(timestamps) > 0 {
+		n := maxSamplesPerRemoteReadChunk
+		if n > len(timestamps) {
+			n = len(timestamps)
+		}
+		c := chunkenc.NewXORChunk()
+		app, err := c.Appender()
+		if err != nil {
+			logger.Panicf("BUG: cannot create appender for XOR chunk: %s", err)
+		}
+		for i := 0; i < n; i++ {
+			app.Append(timestamps[i], values[i])
+		}
+		dst = append(dst, prompb.Chunk{
+			MinTimeMs: timestamps[0],
+			MaxTimeMs: timestamps[n-1],
+			Type:      prompb.Chunk_XOR,
+			Data:      c.Bytes(),
+		})
+		timestamps = timestamps[n:]
+		values = values[n:]
+	}
+	return dst
+}
+
+// writeRemoteReadChunkedSeries writes the series with the given labels and chunks to w.
+//
+// The series is split into multiple frames if it doesn't fit maxRemoteReadFrameSize.
+func writeRemoteReadChunkedSeries(w io.Writer, queryIndex int64, labels []prompb.Label, chunks []prompb.Chunk) error {
+	labelsSize := 0
+	for i := range labels {
+		labelsSize += labels[i].Size()
+	}
+	for len(chunks) > 0 {
+		// Put at least a single chunk into every frame.
+		n := 1
+		frameSize := labelsSize + chunks[0].Size()
+		for n < len(chunks) && frameSize+chunks[n].Size() <= maxRemoteReadFrameSize {
+			frameSize += chunks[n].Size()
+			n++
+		}
+		resp := &prompb.ChunkedReadResponse{
+			ChunkedSeries: []*prompb.ChunkedSeries{{
+				Labels: labels,
+				Chunks: chunks[:n],
+			}},
+			QueryIndex: queryIndex,
+		}
+		data, err := resp.Marshal()
+		if err != nil {
+			return fmt.Errorf("cannot marshal remote read response frame: %w", err)
+		}
+		if err := writeRemoteReadFrame(w, data); err != nil {
+			return err
+		}
+		chunks = chunks[n:]
+	}
+	return nil
+}
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// writeRemoteReadFrame writes data to w in the frame format expected by Prometheus remote read clients:
+// uvarint-encoded data length, big-endian CRC32 Castagnoli checksum of data and data itself.
+func writeRemoteReadFrame(w io.Writer, data []byte) error {
+	var header [binary.MaxVarintLen64 + 4]byte
+	n := binary.PutUvarint(header[:], uint64(len(data)))
+	binary.BigEndian.PutUint32(header[n:], crc32.Checksum(data, castagnoliTable))
+	if _, err := w.Write(header[:n+4]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
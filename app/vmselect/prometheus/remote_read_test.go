@@ -0,0 +1,231 @@
+package prometheus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+)
+
+func TestNegotiateRemoteReadResponseType(t *testing.T) {
+	f := func(accepted []prompb.ReadRequest_ResponseType, resultExpected prompb.ReadRequest_ResponseType) {
+		t.Helper()
+		result, err := negotiateRemoteReadResponseType(accepted)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected response type; got %s; want %s", result, resultExpected)
+		}
+	}
+	f(nil, prompb.ReadRequest_SAMPLES)
+	f([]prompb.ReadRequest_ResponseType{prompb.ReadRequest_SAMPLES}, prompb.ReadRequest_SAMPLES)
+	f([]prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS}, prompb.ReadRequest_STREAMED_XOR_CHUNKS)
+	f([]prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS, prompb.ReadRequest_SAMPLES}, prompb.ReadRequest_STREAMED_XOR_CHUNKS)
+	f([]prompb.ReadRequest_ResponseType{123, prompb.ReadRequest_SAMPLES}, prompb.ReadRequest_SAMPLES)
+
+	if _, err := negotiateRemoteReadResponseType([]prompb.ReadRequest_ResponseType{123}); err == nil {
+		t.Fatalf("expecting non-nil error for unsupported response type")
+	}
+}
+
+func TestGetRemoteReadSearchQuery(t *testing.T) {
+	startTime := time.Unix(1000, 0)
+	q := &prompb.Query{
+		StartTimestampMs: 100,
+		EndTimestampMs:   200,
+		Matchers: []*prompb.LabelMatcher{
+			{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "foo"},
+			{Type: prompb.LabelMatcher_NEQ, Name: "a", Value: "b"},
+			{Type: prompb.LabelMatcher_RE, Name: "c", Value: "d.+"},
+			{Type: prompb.LabelMatcher_NRE, Name: "e", Value: "f|g"},
+		},
+	}
+	sq, err := getRemoteReadSearchQuery(q, nil, startTime)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tfssExpected := [][]storage.TagFilter{{
+		{Value: []byte("foo")},
+		{Key: []byte("a"), Value: []byte("b"), IsNegative: true},
+		{Key: []byte("c"), Value: []byte("d.+"), IsRegexp: true},
+		{Key: []byte("e"), Value: []byte("f|g"), IsNegative: true, IsRegexp: true},
+	}}
+	if !reflect.DeepEqual(sq.TagFilterss, tfssExpected) {
+		t.Fatalf("unexpected tag filters;\ngot\n%v\nwant\n%v", sq.TagFilterss, tfssExpected)
+	}
+	if sq.MinTimestamp != 100 || sq.MaxTimestamp != 200 {
+		t.Fatalf("unexpected time range; got [%d..%d]; want [100..200]", sq.MinTimestamp, sq.MaxTimestamp)
+	}
+	if sq.MaxMetrics != *maxUniqueTimeseries {
+		t.Fatalf("unexpected series limit; got %d; want -search.maxUniqueTimeseries=%d", sq.MaxMetrics, *maxUniqueTimeseries)
+	}
+
+	// The end timestamp must be limited
+	q.EndTimestampMs = 1 << 62
+	sq, err = getRemoteReadSearchQuery(q, nil, startTime)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if maxTimestampExpected := int64(1000e3 + 2*24*3600*1000); sq.MaxTimestamp != maxTimestampExpected {
+		t.Fatalf("unexpected end timestamp; got %d; want %d", sq.MaxTimestamp, maxTimestampExpected)
+	}
+}
+
+func TestGetRemoteReadLabels(t *testing.T) {
+	var mn storage.MetricName
+	mn.MetricGroup = []byte("foo")
+	mn.AddTag("job", "x")
+	mn.AddTag("instance", "y")
+	labels := getRemoteReadLabels(&mn)
+	labelsExpected := []prompb.Label{
+		{Name: "__name__", Value: "foo"},
+		{Name: "instance", Value: "y"},
+		{Name: "job", Value: "x"},
+	}
+	if !reflect.DeepEqual(labels, labelsExpected) {
+		t.Fatalf("unexpected labels; got %v; want %v", labels, labelsExpected)
+	}
+}
+
+func TestLessRemoteReadLabels(t *testing.T) {
+	f := func(a, b []prompb.Label, resultExpected bool) {
+		t.Helper()
+		if result := lessRemoteReadLabels(a, b); result != resultExpected {
+			t.Fatalf("unexpected result for %v < %v; got %v; want %v", a, b, result, resultExpected)
+		}
+	}
+	ab := []prompb.Label{{Name: "a", Value: "b"}}
+	ac := []prompb.Label{{Name: "a", Value: "c"}}
+	bb := []prompb.Label{{Name: "b", Value: "b"}}
+	abcd := []prompb.Label{{Name: "a", Value: "b"}, {Name: "c", Value: "d"}}
+	f(nil, nil, false)
+	f(nil, ab, true)
+	f(ab, nil, false)
+	f(ab, ab, false)
+	f(ab, ac, true)
+	f(ac, ab, false)
+	f(ac, bb, true)
+	f(bb, ac, false)
+	f(ab, abcd, true)
+	f(abcd, ab, false)
+	f(abcd, ac, true)
+}
+
+func TestAppendRemoteReadChunks(t *testing.T) {
+	f := func(samplesCount, chunksCountExpected int) {
+		t.Helper()
+		var timestamps []int64
+		var values []float64
+		for i := 0; i < samplesCount; i++ {
+			timestamps = append(timestamps, int64(i*1000))
+			values = append(values, float64(i)/3)
+		}
+		chunks := appendRemoteReadChunks(nil, timestamps, values)
+		if len(chunks) != chunksCountExpected {
+			t.Fatalf("unexpected number of chunks; got %d; want %d", len(chunks), chunksCountExpected)
+		}
+
+		// Decode chunks and verify they contain the original samples
+		var timestampsResult []int64
+		var valuesResult []float64
+		for _, chk := range chunks {
+			if chk.Type != prompb.Chunk_XOR {
+				t.Fatalf("unexpected chunk type: %s", chk.Type)
+			}
+			c, err := chunkenc.FromData(chunkenc.EncXOR, chk.Data)
+			if err != nil {
+				t.Fatalf("cannot decode chunk: %s", err)
+			}
+			it := c.Iterator(nil)
+			for it.Next() == chunkenc.ValFloat {
+				ts, v := it.At()
+				timestampsResult = append(timestampsResult, ts)
+				valuesResult = append(valuesResult, v)
+			}
+			if err := it.Err(); err != nil {
+				t.Fatalf("cannot iterate chunk: %s", err)
+			}
+			if chk.MinTimeMs != timestampsResult[len(timestampsResult)-c.NumSamples()] {
+				t.Fatalf("unexpected MinTimeMs=%d", chk.MinTimeMs)
+			}
+			if chk.MaxTimeMs != timestampsResult[len(timestampsResult)-1] {
+				t.Fatalf("unexpected MaxTimeMs=%d", chk.MaxTimeMs)
+			}
+		}
+		if !reflect.DeepEqual(timestampsResult, timestamps) {
+			t.Fatalf("unexpected timestamps; got %v; want %v", timestampsResult, timestamps)
+		}
+		if !reflect.DeepEqual(valuesResult, values) {
+			t.Fatalf("unexpected values; got %v; want %v", valuesResult, values)
+		}
+	}
+	f(0, 0)
+	f(1, 1)
+	f(maxSamplesPerRemoteReadChunk, 1)
+	f(maxSamplesPerRemoteReadChunk+1, 2)
+	f(10*maxSamplesPerRemoteReadChunk, 10)
+}
+
+func TestWriteRemoteReadChunkedSeries(t *testing.T) {
+	labels := []prompb.Label{
+		{Name: "__name__", Value: "foo"},
+	}
+	bigChunk := prompb.Chunk{
+		Type: prompb.Chunk_XOR,
+		Data: make([]byte, maxRemoteReadFrameSize/3),
+	}
+	chunks := []prompb.Chunk{bigChunk, bigChunk, bigChunk, bigChunk}
+
+	var bb bytes.Buffer
+	if err := writeRemoteReadChunkedSeries(&bb, 3, labels, chunks); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Read frames back
+	var chunksResult []prompb.Chunk
+	frames := 0
+	data := bb.Bytes()
+	for len(data) > 0 {
+		size, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("cannot read frame size")
+		}
+		data = data[n:]
+		crc := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		frame := data[:size]
+		data = data[size:]
+		if crcExpected := crc32.Checksum(frame, castagnoliTable); crc != crcExpected {
+			t.Fatalf("unexpected crc; got %d; want %d", crc, crcExpected)
+		}
+		var resp prompb.ChunkedReadResponse
+		if err := resp.Unmarshal(frame); err != nil {
+			t.Fatalf("cannot unmarshal frame: %s", err)
+		}
+		if resp.QueryIndex != 3 {
+			t.Fatalf("unexpected query index; got %d; want 3", resp.QueryIndex)
+		}
+		if len(resp.ChunkedSeries) != 1 {
+			t.Fatalf("unexpected number of series in the frame; got %d; want 1", len(resp.ChunkedSeries))
+		}
+		cs := resp.ChunkedSeries[0]
+		if !reflect.DeepEqual(cs.Labels, labels) {
+			t.Fatalf("unexpected labels; got %v; want %v", cs.Labels, labels)
+		}
+		chunksResult = append(chunksResult, cs.Chunks...)
+		frames++
+	}
+	if frames != 2 {
+		t.Fatalf("unexpected number of frames; got %d; want 2", frames)
+	}
+	if len(chunksResult) != len(chunks) {
+		t.Fatalf("unexpected number of chunks; got %d; want %d", len(chunksResult), len(chunks))
+	}
+}
@@ -0,0 +1,97 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/appmetrics"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+)
+
+// StatusFlagsHandler processes /api/v1/status/flags request.
+//
+// It returns the effective values for all the command-line flags with redacted secret flags.
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#flags
+func StatusFlagsHandler(w http.ResponseWriter, r *http.Request) error {
+	data, err := json.Marshal(flagutil.GetFlagValues())
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal flag values: %s", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := fmt.Fprintf(w, `{"status":"success","data":%s}`, data); err != nil {
+		return fmt.Errorf("cannot send flags response to remote client: %w", err)
+	}
+	return nil
+}
+
+// runtimeInfo is the response for /api/v1/status/runtimeinfo.
+//
+// Field names match Prometheus field names where they make sense.
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#runtime-information
+type runtimeInfo struct {
+	StartTime        time.Time `json:"startTime"`
+	CWD              string    `json:"CWD"`
+	GoroutineCount   int       `json:"goroutineCount"`
+	GOMAXPROCS       int       `json:"GOMAXPROCS"`
+	GOMEMLIMIT       int64     `json:"GOMEMLIMIT"`
+	GOGC             string    `json:"GOGC"`
+	GODEBUG          string    `json:"GODEBUG"`
+	StorageRetention string    `json:"storageRetention"`
+
+	// TimeSeriesCount is the number of active series, which is the closest analogue to the number of series in Prometheus head block.
+	TimeSeriesCount uint64 `json:"timeSeriesCount"`
+
+	// The following fields are VictoriaMetrics-specific.
+	RowsCount     uint64 `json:"rowsCount"`
+	DataSizeBytes uint64 `json:"dataSizeBytes"`
+}
+
+// StatusRuntimeInfoHandler processes /api/v1/status/runtimeinfo request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#runtime-information
+func StatusRuntimeInfoHandler(qt *querytracer.Tracer, w http.ResponseWriter, r *http.Request) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "<unknown>"
+	}
+	si := netstorage.GetStorageInfo(qt)
+	ri := &runtimeInfo{
+		StartTime:        appmetrics.GetStartTime(),
+		CWD:              cwd,
+		GoroutineCount:   runtime.NumGoroutine(),
+		GOMAXPROCS:       runtime.GOMAXPROCS(0),
+		GOMEMLIMIT:       debug.SetMemoryLimit(-1),
+		GOGC:             os.Getenv("GOGC"),
+		GODEBUG:          os.Getenv("GODEBUG"),
+		StorageRetention: formatRetention(si.RetentionMsecs),
+		TimeSeriesCount:  si.ActiveSeries,
+		RowsCount:        si.RowsCount,
+		DataSizeBytes:    si.SizeBytes,
+	}
+	data, err := json.Marshal(ri)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal runtime info: %s", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := fmt.Fprintf(w, `{"status":"success","data":%s}`, data); err != nil {
+		return fmt.Errorf("cannot send runtime info response to remote client: %w", err)
+	}
+	return nil
+}
+
+// formatRetention formats retentionMsecs in the same way as Prometheus formats `storageRetention`, e.g. `15d`.
+func formatRetention(retentionMsecs int64) string {
+	const msecsPerDay = 24 * 3600 * 1000
+	if retentionMsecs%msecsPerDay == 0 {
+		return fmt.Sprintf("%dd", retentionMsecs/msecsPerDay)
+	}
+	return (time.Duration(retentionMsecs) * time.Millisecond).String()
+}
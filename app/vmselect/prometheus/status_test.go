@@ -0,0 +1,18 @@
+package prometheus
+
+import (
+	"testing"
+)
+
+func TestFormatRetention(t *testing.T) {
+	f := func(retentionMsecs int64, resultExpected string) {
+		t.Helper()
+		result := formatRetention(retentionMsecs)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for retentionMsecs=%d; got %q; want %q", retentionMsecs, result, resultExpected)
+		}
+	}
+	f(24*3600*1000, "1d")
+	f(31*24*3600*1000, "31d")
+	f(36*3600*1000, "36h0m0s")
+}
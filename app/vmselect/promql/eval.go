@@ -119,6 +119,9 @@ type EvalConfig struct {
 	// Whether the response can be cached.
 	MayCache bool
 
+	// Whether to skip reading the response from cache, while still storing it in the cache if MayCache is set.
+	SkipCacheRead bool
+
 	// LookbackDelta is analog to `-query.lookback-delta` from Prometheus.
 	LookbackDelta int64
 
@@ -132,6 +135,11 @@ type EvalConfig struct {
 	// The request URI isn't stored here because its' construction may take non-trivial amounts of CPU.
 	GetRequestURI func() string
 
+	// IsExactLookbackDelta is set to true if LookbackDelta must be used as the exact lookbehind window
+	// for instant vector selectors such as foo{bar="baz"}. Otherwise LookbackDelta limits the lookbehind window,
+	// which is automatically detected from the interval between raw samples.
+	IsExactLookbackDelta bool
+
 	// QueryStats contains various stats for the currently executed query.
 	//
 	// The caller must initialize the QueryStats if it needs the stats.
@@ -152,10 +160,12 @@ func copyEvalConfig(src *EvalConfig) *EvalConfig {
 	ec.MaxPointsPerSeries = src.MaxPointsPerSeries
 	ec.Deadline = src.Deadline
 	ec.MayCache = src.MayCache
+	ec.SkipCacheRead = src.SkipCacheRead
 	ec.LookbackDelta = src.LookbackDelta
 	ec.RoundDigits = src.RoundDigits
 	ec.EnforcedTagFilterss = src.EnforcedTagFilterss
 	ec.GetRequestURI = src.GetRequestURI
+	ec.IsExactLookbackDelta = src.IsExactLookbackDelta
 	ec.QueryStats = src.QueryStats
 
 	// do not copy src.timestamps - they must be generated again.
@@ -278,6 +288,12 @@ func evalExprInternal(qt *querytracer.Tracer, ec *EvalConfig, e metricsql.Expr)
 		return rv, nil
 	}
 	if fe, ok := e.(*metricsql.FuncExpr); ok {
+		if fe.Name == "series_exists" {
+			qtChild := qt.NewChild("series_exists()")
+			rv, err := evalSeriesExists(qtChild, ec, fe)
+			qtChild.Done()
+			return rv, err
+		}
 		nrf := getRollupFunc(fe.Name)
 		if nrf == nil {
 			qtChild := qt.NewChild("transform %s()", fe.Name)
@@ -360,6 +376,50 @@ func evalTransformFunc(qt *querytracer.Tracer, ec *EvalConfig, fe *metricsql.Fun
 	return rv, nil
 }
 
+// evalSeriesExists evaluates series_exists(selector) or series_exists(selector[d]).
+//
+// It returns 1 if at least a single series matching the selector contains samples on the selected time range, otherwise 0.
+// The storage search is stopped after the first matching sample, so this is much faster than count(selector) > 0.
+func evalSeriesExists(qt *querytracer.Tracer, ec *EvalConfig, fe *metricsql.FuncExpr) ([]*timeseries, error) {
+	if len(fe.Args) != 1 {
+		return nil, &UserReadableError{
+			Err: fmt.Errorf(`unexpected number of args for %q; got %d; want 1`, fe.AppendString(nil), len(fe.Args)),
+		}
+	}
+	var window int64
+	arg := fe.Args[0]
+	if re, ok := arg.(*metricsql.RollupExpr); ok && !re.ForSubquery() && re.Offset == nil && re.At == nil {
+		window = re.Window.Duration(ec.Step)
+		arg = re.Expr
+	}
+	me, ok := arg.(*metricsql.MetricExpr)
+	if !ok {
+		return nil, &UserReadableError{
+			Err: fmt.Errorf(`series_exists() accepts only a series selector with optional lookbehind window in square brackets; got %q`, fe.Args[0].AppendString(nil)),
+		}
+	}
+	if me.IsEmpty() {
+		return evalNumber(ec, 0), nil
+	}
+	tfs := searchutils.ToTagFilters(me.LabelFilters)
+	tfss := searchutils.JoinTagFilterss([][]storage.TagFilter{tfs}, ec.EnforcedTagFilterss)
+	minTimestamp := ec.Start - maxSilenceInterval
+	if window > ec.Step {
+		minTimestamp -= window
+	} else {
+		minTimestamp -= ec.Step
+	}
+	sq := storage.NewSearchQuery(minTimestamp, ec.End, tfss, ec.MaxSeries)
+	ok, err := netstorage.SeriesExists(qt, sq, ec.Deadline)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return evalNumber(ec, 0), nil
+	}
+	return evalNumber(ec, 1), nil
+}
+
 func evalAggrFunc(qt *querytracer.Tracer, ec *EvalConfig, ae *metricsql.AggrFuncExpr) ([]*timeseries, error) {
 	if callbacks := getIncrementalAggrFuncCallbacks(ae.Name); callbacks != nil {
 		fe, nrf := tryGetArgRollupFuncWithMetricExpr(ae)
@@ -920,7 +980,7 @@ func evalRollupFuncWithSubquery(qt *querytracer.Tracer, ec *EvalConfig, funcName
 		return nil, nil
 	}
 	sharedTimestamps := getTimestamps(ec.Start, ec.End, ec.Step, ec.MaxPointsPerSeries)
-	preFunc, rcs, err := getRollupConfigs(funcName, rf, expr, ec.Start, ec.End, ec.Step, ec.MaxPointsPerSeries, window, ec.LookbackDelta, sharedTimestamps)
+	preFunc, rcs, err := getRollupConfigs(funcName, rf, expr, ec.Start, ec.End, ec.Step, ec.MaxPointsPerSeries, window, ec.LookbackDelta, ec.IsExactLookbackDelta, sharedTimestamps)
 	if err != nil {
 		return nil, err
 	}
@@ -1069,7 +1129,7 @@ func evalRollupFuncWithMetricExpr(qt *querytracer.Tracer, ec *EvalConfig, funcNa
 	// Obtain rollup configs before fetching data from db,
 	// so type errors can be caught earlier.
 	sharedTimestamps := getTimestamps(start, ec.End, ec.Step, ec.MaxPointsPerSeries)
-	preFunc, rcs, err := getRollupConfigs(funcName, rf, expr, start, ec.End, ec.Step, ec.MaxPointsPerSeries, window, ec.LookbackDelta, sharedTimestamps)
+	preFunc, rcs, err := getRollupConfigs(funcName, rf, expr, start, ec.End, ec.Step, ec.MaxPointsPerSeries, window, ec.LookbackDelta, ec.IsExactLookbackDelta, sharedTimestamps)
 	if err != nil {
 		return nil, err
 	}
@@ -1080,6 +1140,9 @@ func evalRollupFuncWithMetricExpr(qt *querytracer.Tracer, ec *EvalConfig, funcNa
 	minTimestamp := start - maxSilenceInterval
 	if window > ec.Step {
 		minTimestamp -= window
+	} else if window <= 0 && ec.IsExactLookbackDelta && ec.LookbackDelta > ec.Step {
+		// Fetch samples for the whole lookbehind window.
+		minTimestamp -= ec.LookbackDelta
 	} else {
 		minTimestamp -= ec.Step
 	}
@@ -1154,6 +1154,28 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r}
 		f(q, resultExpected)
 	})
+	t.Run("topk(5, time() @ end())", func(t *testing.T) {
+		t.Parallel()
+		q := `topk(5, time() @ end())`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{2000, 2000, 2000, 2000, 2000, 2000},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run("max_over_time(time()[10m] @ end() offset 10m)", func(t *testing.T) {
+		t.Parallel()
+		q := `max_over_time(time()[10m] @ end() offset 10m)`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1400, 1400, 1400, 1400, 1400, 1400},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
 	t.Run("rand()", func(t *testing.T) {
 		t.Parallel()
 		q := `round(rand()/2)`
@@ -2313,6 +2335,207 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r}
 		f(q, resultExpected)
 	})
+	t.Run(`labels_equal()`, func(t *testing.T) {
+		t.Parallel()
+		q := `sort(labels_equal((
+			label_set(1, "a", "x", "b", "x"),
+			label_set(2, "a", "x", "b", "y"),
+			label_set(3, "c", "z"),
+		), "a", "b"))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1, 1, 1, 1, 1, 1},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("a"),
+				Value: []byte("x"),
+			},
+			{
+				Key:   []byte("b"),
+				Value: []byte("x"),
+			},
+		}
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{3, 3, 3, 3, 3, 3},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("c"),
+			Value: []byte("z"),
+		}}
+		resultExpected := []netstorage.Result{r1, r2}
+		f(q, resultExpected)
+	})
+	t.Run(`drop_empty_series(nan-only)`, func(t *testing.T) {
+		t.Parallel()
+		q := `drop_empty_series(label_set(time() > 3000, "foo", "bar")) default 42`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{42, 42, 42, 42, 42, 42},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`drop_empty_series(partial-nan)`, func(t *testing.T) {
+		t.Parallel()
+		q := `drop_empty_series((
+			label_set(time() > 3000, "foo", "bar"),
+			label_set(time() > 1500, "foo", "baz"),
+		)) default 42`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{42, 42, 42, 1600, 1800, 2000},
+			Timestamps: timestampsExpected,
+		}
+		r.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("foo"),
+			Value: []byte("baz"),
+		}}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`label_set_if()`, func(t *testing.T) {
+		t.Parallel()
+		q := `sort(label_set_if((
+			label_set(1, "job", "foo"),
+			label_set(2, "job", "bar"),
+		), '{job="foo"}', "env", "prod"))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1, 1, 1, 1, 1, 1},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("env"),
+				Value: []byte("prod"),
+			},
+			{
+				Key:   []byte("job"),
+				Value: []byte("foo"),
+			},
+		}
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{2, 2, 2, 2, 2, 2},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("job"),
+			Value: []byte("bar"),
+		}}
+		resultExpected := []netstorage.Result{r1, r2}
+		f(q, resultExpected)
+	})
+	t.Run(`label_del_if()`, func(t *testing.T) {
+		t.Parallel()
+		q := `sort(label_del_if((
+			label_set(1, "job", "foo", "x", "y"),
+			label_set(2, "job", "bar", "x", "y"),
+		), '{job!~"f.+"}', "x"))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1, 1, 1, 1, 1, 1},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("job"),
+				Value: []byte("foo"),
+			},
+			{
+				Key:   []byte("x"),
+				Value: []byte("y"),
+			},
+		}
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{2, 2, 2, 2, 2, 2},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("job"),
+			Value: []byte("bar"),
+		}}
+		resultExpected := []netstorage.Result{r1, r2}
+		f(q, resultExpected)
+	})
+	t.Run(`label_join_if(metric_name)`, func(t *testing.T) {
+		t.Parallel()
+		q := `sort(label_join_if((
+			label_set(alias(1, "foo"), "a", "x", "b", "y"),
+			label_set(alias(2, "bar"), "a", "x", "b", "y"),
+		), 'foo', "ab", "-", "a", "b"))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1, 1, 1, 1, 1, 1},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.MetricGroup = []byte("foo")
+		r1.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("a"),
+				Value: []byte("x"),
+			},
+			{
+				Key:   []byte("ab"),
+				Value: []byte("x-y"),
+			},
+			{
+				Key:   []byte("b"),
+				Value: []byte("y"),
+			},
+		}
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{2, 2, 2, 2, 2, 2},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.MetricGroup = []byte("bar")
+		r2.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("a"),
+				Value: []byte("x"),
+			},
+			{
+				Key:   []byte("b"),
+				Value: []byte("y"),
+			},
+		}
+		resultExpected := []netstorage.Result{r1, r2}
+		f(q, resultExpected)
+	})
+	t.Run(`label_replace_if(partial-nan)`, func(t *testing.T) {
+		t.Parallel()
+		q := `sort(label_replace_if((
+			label_set(time() > 1500, "job", "foo"),
+			label_set(time() < 1500, "job", "bar"),
+		), '{job="foo"}', "job", "new_$1", "job", "(.+)"))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1000, 1200, 1400, nan, nan, nan},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("job"),
+			Value: []byte("bar"),
+		}}
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{nan, nan, nan, 1600, 1800, 2000},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("job"),
+			Value: []byte("new_foo"),
+		}}
+		resultExpected := []netstorage.Result{r1, r2}
+		f(q, resultExpected)
+	})
 	t.Run(`label_graphite_group()`, func(t *testing.T) {
 		t.Parallel()
 		q := `sort(label_graphite_group((
@@ -8487,9 +8710,25 @@ func TestExecError(t *testing.T) {
 	f(`label_replace(1)`)
 	f(`label_transform(1)`)
 	f(`label_set()`)
+	f(`label_set_if()`)
+	f(`label_set_if(1)`)
+	f(`label_set_if(1, 2, "foo", "bar")`)
+	f(`label_set_if(1, "{foo", "foo", "bar")`)
+	f(`label_set_if(1, "sum(foo)", "foo", "bar")`)
+	f(`label_set_if(1, '{foo=~"("}', "foo", "bar")`)
+	f(`label_del_if(1)`)
+	f(`labels_equal()`)
+	f(`labels_equal(1, "foo")`)
+	f(`labels_equal(1, "foo", 2)`)
+	f(`drop_empty_series()`)
+	f(`drop_empty_series(1, 2)`)
 	f(`label_set(1, "foo")`)
 	f(`label_map()`)
 	f(`label_map(1)`)
+	f(`label_lookup()`)
+	f(`label_lookup(1)`)
+	f(`label_lookup(time(), "foo", "bar", "nonexisting")`)
+	f(`label_lookup(time(), "foo", "bar", "nonexisting", "baz")`)
 	f(`label_del()`)
 	f(`label_keep()`)
 	f(`label_match()`)
@@ -8497,6 +8736,12 @@ func TestExecError(t *testing.T) {
 	f(`label_graphite_group()`)
 	f(`round()`)
 	f(`round(1,2,3)`)
+	f(`series_exists()`)
+	f(`series_exists(1)`)
+	f(`series_exists(time())`)
+	f(`series_exists(foo, bar)`)
+	f(`series_exists(foo[5m:1m])`)
+	f(`series_exists(foo offset 5m)`)
 	f(`sgn()`)
 	f(`scalar()`)
 	f(`sort(1,2)`)
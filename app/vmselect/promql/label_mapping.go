@@ -0,0 +1,245 @@
+package promql
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	labelMappingFiles = flagutil.NewArrayString("search.labelMappingFile", "Optional mapping for label_lookup() function in the form name=path. "+
+		"The path can point either to local file or to http url. The file must contain CSV rows with key,value columns "+
+		"or JSON object with string values if the path ends with .json. "+
+		"See https://docs.victoriametrics.com/MetricsQL.html#label_lookup")
+	labelMappingCheckInterval = flag.Duration("search.labelMappingCheckInterval", 30*time.Second, "Interval for checking for changes in -search.labelMappingFile files. "+
+		"Changed files are automatically reloaded. Set to zero in order to disable the reload")
+)
+
+// labelMapping holds key->value mapping loaded from a file for label_lookup() function.
+type labelMapping struct {
+	name string
+	path string
+
+	// m contains map[string]string with the last successfully loaded mapping.
+	m atomic.Value
+
+	// data contains the file contents for the currently loaded mapping.
+	// It is used for detecting file changes and is accessed only by the goroutine, which loads the mapping.
+	data []byte
+
+	reloads               *metrics.Counter
+	reloadErrors          *metrics.Counter
+	lastReloadSuccessful  *metrics.Counter
+	lastReloadSuccessTime *metrics.Counter
+	rows                  *metrics.Counter
+}
+
+func newLabelMapping(name, path string) *labelMapping {
+	return &labelMapping{
+		name:                  name,
+		path:                  path,
+		reloads:               metrics.GetOrCreateCounter(fmt.Sprintf(`vm_label_mapping_reloads_total{name=%q}`, name)),
+		reloadErrors:          metrics.GetOrCreateCounter(fmt.Sprintf(`vm_label_mapping_reload_errors_total{name=%q}`, name)),
+		lastReloadSuccessful:  metrics.GetOrCreateCounter(fmt.Sprintf(`vm_label_mapping_last_reload_successful{name=%q}`, name)),
+		lastReloadSuccessTime: metrics.GetOrCreateCounter(fmt.Sprintf(`vm_label_mapping_last_reload_success_timestamp_seconds{name=%q}`, name)),
+		rows:                  metrics.GetOrCreateCounter(fmt.Sprintf(`vm_label_mapping_rows{name=%q}`, name)),
+	}
+}
+
+// get returns the value for the given key.
+func (lm *labelMapping) get(key string) (string, bool) {
+	m := lm.m.Load().(map[string]string)
+	v, ok := m[key]
+	return v, ok
+}
+
+// reload loads the mapping from lm.path if the file contents have been changed since the previous load.
+func (lm *labelMapping) reload() error {
+	data, err := fs.ReadFileOrHTTP(lm.path)
+	if err != nil {
+		return fmt.Errorf("cannot read mapping %q from %q: %w", lm.name, lm.path, err)
+	}
+	if lm.data != nil && bytes.Equal(data, lm.data) {
+		return nil
+	}
+	lm.reloads.Inc()
+	m, err := parseLabelMapping(data, strings.HasSuffix(lm.path, ".json"))
+	if err != nil {
+		lm.reloadErrors.Inc()
+		lm.lastReloadSuccessful.Set(0)
+		return fmt.Errorf("cannot parse mapping %q from %q: %w", lm.name, lm.path, err)
+	}
+	lm.m.Store(m)
+	lm.data = data
+	lm.lastReloadSuccessful.Set(1)
+	lm.lastReloadSuccessTime.Set(fasttime.UnixTimestamp())
+	lm.rows.Set(uint64(len(m)))
+	return nil
+}
+
+// parseLabelMapping parses mapping from CSV data with key,value rows or from JSON object with string values if isJSON is set.
+func parseLabelMapping(data []byte, isJSON bool) (map[string]string, error) {
+	if isJSON {
+		var m map[string]string
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("cannot parse JSON object with string values: %w", err)
+		}
+		if m == nil {
+			m = make(map[string]string)
+		}
+		return m, nil
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	m := make(map[string]string)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse CSV with key,value rows: %w", err)
+		}
+		key := record[0]
+		if _, ok := m[key]; ok {
+			line, _ := r.FieldPos(0)
+			return nil, fmt.Errorf("duplicate key %q at line %d", key, line)
+		}
+		m[key] = record[1]
+	}
+}
+
+var (
+	// labelMappings contains mappings from -search.labelMappingFile by their names.
+	//
+	// It is initialized at InitLabelMappings and isn't modified after that.
+	labelMappings map[string]*labelMapping
+
+	labelMappingsStopCh chan struct{}
+	labelMappingsWG     sync.WaitGroup
+)
+
+// InitLabelMappings loads mappings from -search.labelMappingFile and starts watching for their changes.
+//
+// StopLabelMappings must be called when the mappings are no longer needed.
+func InitLabelMappings() {
+	lms, err := loadLabelMappings(*labelMappingFiles)
+	if err != nil {
+		logger.Fatalf("cannot load -search.labelMappingFile: %s", err)
+	}
+	labelMappings = lms
+	labelMappingsStopCh = make(chan struct{})
+	if len(lms) == 0 || *labelMappingCheckInterval <= 0 {
+		return
+	}
+	labelMappingsWG.Add(1)
+	go func() {
+		defer labelMappingsWG.Done()
+		t := time.NewTicker(*labelMappingCheckInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-labelMappingsStopCh:
+				return
+			case <-t.C:
+				for _, lm := range lms {
+					if err := lm.reload(); err != nil {
+						logger.Errorf("%s; continue using the previously loaded mapping", err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// StopLabelMappings stops watching for changes in -search.labelMappingFile files.
+func StopLabelMappings() {
+	close(labelMappingsStopCh)
+	labelMappingsWG.Wait()
+}
+
+func loadLabelMappings(nameToPaths []string) (map[string]*labelMapping, error) {
+	lms := make(map[string]*labelMapping, len(nameToPaths))
+	for _, s := range nameToPaths {
+		n := strings.IndexByte(s, '=')
+		if n <= 0 || n == len(s)-1 {
+			return nil, fmt.Errorf("unexpected mapping %q; expecting name=path format", s)
+		}
+		name, path := s[:n], s[n+1:]
+		if _, ok := lms[name]; ok {
+			return nil, fmt.Errorf("duplicate mapping name %q", name)
+		}
+		lm := newLabelMapping(name, path)
+		if err := lm.reload(); err != nil {
+			return nil, err
+		}
+		lms[name] = lm
+	}
+	return lms, nil
+}
+
+func transformLabelLookup(tfa *transformFuncArg) ([]*timeseries, error) {
+	args := tfa.args
+	if len(args) < 4 || len(args) > 5 {
+		return nil, fmt.Errorf(`unexpected number of args; got %d; want 4 or 5`, len(args))
+	}
+	srcLabel, err := getString(args[1], 1)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get source label name: %w", err)
+	}
+	dstLabel, err := getString(args[2], 2)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get destination label name: %w", err)
+	}
+	name, err := getString(args[3], 3)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get mapping name: %w", err)
+	}
+	keepMissing := false
+	if len(args) == 5 {
+		onMissing, err := getString(args[4], 4)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get the action for missing keys: %w", err)
+		}
+		switch onMissing {
+		case "empty":
+		case "keep":
+			keepMissing = true
+		default:
+			return nil, fmt.Errorf(`unexpected action for missing keys: %q; supported values: "empty", "keep"`, onMissing)
+		}
+	}
+	lm := labelMappings[name]
+	if lm == nil {
+		return nil, fmt.Errorf("unknown mapping %q; it must be set via -search.labelMappingFile command-line flag", name)
+	}
+	rvs := args[0]
+	for _, ts := range rvs {
+		mn := &ts.MetricName
+		value, ok := lm.get(string(mn.GetTagValue(srcLabel)))
+		if !ok && keepMissing {
+			continue
+		}
+		dstValue := getDstValue(mn, dstLabel)
+		*dstValue = append((*dstValue)[:0], value...)
+		if len(value) == 0 {
+			mn.RemoveTag(dstLabel)
+		}
+	}
+	return rvs, nil
+}
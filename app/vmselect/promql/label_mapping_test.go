@@ -0,0 +1,207 @@
+package promql
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestParseLabelMappingSuccess(t *testing.T) {
+	f := func(data string, isJSON bool, mExpected map[string]string) {
+		t.Helper()
+		m, err := parseLabelMapping([]byte(data), isJSON)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(m, mExpected) {
+			t.Fatalf("unexpected mapping; got %v; want %v", m, mExpected)
+		}
+	}
+	f("", false, map[string]string{})
+	f("foo,bar\n", false, map[string]string{
+		"foo": "bar",
+	})
+	f("# service,team\napi, backend\n\"web,ui\",frontend\nversion,\n", false, map[string]string{
+		"api":     "backend",
+		"web,ui":  "frontend",
+		"version": "",
+	})
+	f(`{}`, true, map[string]string{})
+	f(`{"api":"backend","web":"frontend"}`, true, map[string]string{
+		"api": "backend",
+		"web": "frontend",
+	})
+}
+
+func TestParseLabelMappingFailure(t *testing.T) {
+	f := func(data string, isJSON bool) {
+		t.Helper()
+		_, err := parseLabelMapping([]byte(data), isJSON)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	// Invalid number of columns
+	f("foo\n", false)
+	f("foo,bar,baz\n", false)
+	f("foo,bar\nbaz\n", false)
+
+	// Duplicate keys
+	f("foo,bar\nfoo,baz\n", false)
+
+	// Invalid JSON
+	f(``, true)
+	f(`[]`, true)
+	f(`{"foo":1}`, true)
+}
+
+func TestLoadLabelMappingsFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "teams.csv")
+	if err := os.WriteFile(path, []byte("api,backend\n"), 0644); err != nil {
+		t.Fatalf("cannot write mapping file: %s", err)
+	}
+	f := func(nameToPaths []string) {
+		t.Helper()
+		if _, err := loadLabelMappings(nameToPaths); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f([]string{"teams"})
+	f([]string{"=" + path})
+	f([]string{"teams="})
+	f([]string{"teams=" + path, "teams=" + path})
+	f([]string{"teams=" + filepath.Join(dir, "missing.csv")})
+}
+
+func TestLabelMappingReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "teams.csv")
+	writeFile := func(data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("cannot write mapping file: %s", err)
+		}
+	}
+	checkValue := func(lm *labelMapping, key, valueExpected string) {
+		t.Helper()
+		value, _ := lm.get(key)
+		if value != valueExpected {
+			t.Fatalf("unexpected value for key %q; got %q; want %q", key, value, valueExpected)
+		}
+	}
+
+	writeFile("api,backend\n")
+	lms, err := loadLabelMappings([]string{"teams=" + path})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	lm := lms["teams"]
+	checkValue(lm, "api", "backend")
+	if n := lm.rows.Get(); n != 1 {
+		t.Fatalf("unexpected number of rows; got %d; want 1", n)
+	}
+
+	// The mapping mustn't be reloaded if the file isn't changed.
+	reloads := lm.reloads.Get()
+	if err := lm.reload(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := lm.reloads.Get() - reloads; n != 0 {
+		t.Fatalf("unexpected number of reloads for unchanged file; got %d; want 0", n)
+	}
+
+	// The changed file must be reloaded.
+	writeFile("api,platform\nweb,frontend\n")
+	if err := lm.reload(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkValue(lm, "api", "platform")
+	checkValue(lm, "web", "frontend")
+	if n := lm.rows.Get(); n != 2 {
+		t.Fatalf("unexpected number of rows; got %d; want 2", n)
+	}
+
+	// The previous mapping must be preserved on invalid file.
+	writeFile("api\n")
+	if err := lm.reload(); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	checkValue(lm, "api", "platform")
+	if n := lm.lastReloadSuccessful.Get(); n != 0 {
+		t.Fatalf("unexpected last reload status; got %d; want 0", n)
+	}
+}
+
+func TestExecLabelLookup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "teams.json")
+	if err := os.WriteFile(path, []byte(`{"api":"backend","web":"frontend","empty":""}`), 0644); err != nil {
+		t.Fatalf("cannot write mapping file: %s", err)
+	}
+	lms, err := loadLabelMappings([]string{"teams=" + path})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	labelMappingsOrig := labelMappings
+	labelMappings = lms
+	defer func() {
+		labelMappings = labelMappingsOrig
+	}()
+
+	timestampsExpected := []int64{1000e3, 1200e3, 1400e3, 1600e3, 1800e3, 2000e3}
+	f := func(q string, teamsExpected []string) {
+		t.Helper()
+		ec := &EvalConfig{
+			Start:              1000e3,
+			End:                2000e3,
+			Step:               200e3,
+			MaxPointsPerSeries: 1e4,
+			MaxSeries:          1000,
+			Deadline:           searchutils.NewDeadline(time.Now(), time.Minute, ""),
+			RoundDigits:        100,
+		}
+		result, err := Exec(nil, ec, q, false)
+		if err != nil {
+			t.Fatalf(`unexpected error when executing %q: %s`, q, err)
+		}
+		var resultExpected []netstorage.Result
+		for i, team := range teamsExpected {
+			r := netstorage.Result{
+				Values:     []float64{1000, 1200, 1400, 1600, 1800, 2000},
+				Timestamps: timestampsExpected,
+			}
+			for j := range r.Values {
+				r.Values[j] += float64(i)
+			}
+			services := []string{"api", "web", "db", "empty"}
+			r.MetricName.Tags = []storage.Tag{{
+				Key:   []byte("service"),
+				Value: []byte(services[i]),
+			}}
+			if team != "" {
+				r.MetricName.Tags = append(r.MetricName.Tags, storage.Tag{
+					Key:   []byte("team"),
+					Value: []byte(team),
+				})
+			}
+			resultExpected = append(resultExpected, r)
+		}
+		testResultsEqual(t, result, resultExpected)
+	}
+	series := `(
+		label_set(time(), "service", "api", "team", "old"),
+		label_set(time()+1, "service", "web"),
+		label_set(time()+2, "service", "db", "team", "old"),
+		label_set(time()+3, "service", "empty", "team", "old"),
+	)`
+	f(`sort(label_lookup(`+series+`, "service", "team", "teams"))`, []string{"backend", "frontend", "", ""})
+	f(`sort(label_lookup(`+series+`, "service", "team", "teams", "empty"))`, []string{"backend", "frontend", "", ""})
+	f(`sort(label_lookup(`+series+`, "service", "team", "teams", "keep"))`, []string{"backend", "frontend", "old", ""})
+}
@@ -319,7 +319,7 @@ func getRollupTag(expr metricsql.Expr) (string, error) {
 }
 
 func getRollupConfigs(funcName string, rf rollupFunc, expr metricsql.Expr, start, end, step int64, maxPointsPerSeries int,
-	window, lookbackDelta int64, sharedTimestamps []int64) (
+	window, lookbackDelta int64, isExactLookbackDelta bool, sharedTimestamps []int64) (
 	func(values []float64, timestamps []int64), []*rollupConfig, error) {
 	preFunc := func(values []float64, timestamps []int64) {}
 	funcName = strings.ToLower(funcName)
@@ -342,6 +342,7 @@ func getRollupConfigs(funcName string, rf rollupFunc, expr metricsql.Expr, start
 
 			MayAdjustWindow:       rollupFuncsCanAdjustWindow[funcName],
 			LookbackDelta:         lookbackDelta,
+			IsExactLookbackDelta:  isExactLookbackDelta,
 			Timestamps:            sharedTimestamps,
 			isDefaultRollup:       funcName == "default_rollup",
 			samplesScannedPerCall: samplesScannedPerCall,
@@ -529,6 +530,9 @@ type rollupConfig struct {
 	// LoookbackDelta is the analog to `-query.lookback-delta` from Prometheus world.
 	LookbackDelta int64
 
+	// Whether LookbackDelta must be used as the exact window for default_rollup.
+	IsExactLookbackDelta bool
+
 	// Whether default_rollup is used.
 	isDefaultRollup bool
 
@@ -675,6 +679,11 @@ func (rc *rollupConfig) doInternal(dstValues []float64, tsm *timeseriesMap, valu
 			// according to https://github.com/VictoriaMetrics/VictoriaMetrics/issues/784
 			window = rc.LookbackDelta
 		}
+		if rc.isDefaultRollup && rc.IsExactLookbackDelta && rc.LookbackDelta > 0 {
+			// Use the exact lookbehind window passed via lookback_delta query arg or set via -search.setLookbackToStep.
+			// This allows returning results for sparse time series with intervals between samples exceeding the step.
+			window = rc.LookbackDelta
+		}
 	}
 	rfa := getRollupFuncArg()
 	rfa.idx = 0
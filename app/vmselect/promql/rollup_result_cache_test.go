@@ -66,6 +66,38 @@ func TestRollupResultCache(t *testing.T) {
 		}
 	})
 
+	// Skip reading from cache, while still storing to cache
+	t.Run("skip-cache-read", func(t *testing.T) {
+		ResetRollupResultCache()
+		ecSkipRead := copyEvalConfig(ec)
+		ecSkipRead.SkipCacheRead = true
+		tss := []*timeseries{
+			{
+				Timestamps: []int64{800, 1000, 1200},
+				Values:     []float64{0, 1, 2},
+			},
+		}
+		rollupResultCacheV.Put(nil, ecSkipRead, fe, window, tss)
+		tss, newStart := rollupResultCacheV.Get(nil, ecSkipRead, fe, window)
+		if newStart != ec.Start {
+			t.Fatalf("unexpected newStart; got %d; want %d", newStart, ec.Start)
+		}
+		if len(tss) != 0 {
+			t.Fatalf("got %d timeseries, while expecting zero", len(tss))
+		}
+		tss, newStart = rollupResultCacheV.Get(nil, ec, fe, window)
+		if newStart != 1400 {
+			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 1400)
+		}
+		tssExpected := []*timeseries{
+			{
+				Timestamps: []int64{1000, 1200},
+				Values:     []float64{1, 2},
+			},
+		}
+		testTimeseriesEqual(t, tss, tssExpected)
+	})
+
 	// Store timeseries overlapping with start
 	t.Run("start-overlap-no-ae", func(t *testing.T) {
 		ResetRollupResultCache()
@@ -820,6 +820,33 @@ func TestRollupFuncsLookbackDelta(t *testing.T) {
 	})
 }
 
+func TestRollupDefaultExactLookbackDelta(t *testing.T) {
+	f := func(lookbackDelta int64, isExactLookbackDelta bool, valuesExpected []float64) {
+		t.Helper()
+		rc := rollupConfig{
+			Func:                 rollupDefault,
+			Start:                0,
+			End:                  100,
+			Step:                 20,
+			LookbackDelta:        lookbackDelta,
+			IsExactLookbackDelta: isExactLookbackDelta,
+			MaxPointsPerSeries:   1e4,
+			isDefaultRollup:      true,
+		}
+		rc.Timestamps = rc.getTimestamps()
+		// Sparse samples with the interval exceeding the step
+		values, _ := rc.Do(nil, []float64{1, 2}, []int64{5, 75})
+		timestampsExpected := []int64{0, 20, 40, 60, 80, 100}
+		testRowsEqual(t, values, rc.Timestamps, valuesExpected, timestampsExpected)
+	}
+	// The window is limited by the step if the interval between samples cannot be detected
+	f(0, false, []float64{nan, 1, nan, nan, 2, nan})
+	f(50, false, []float64{nan, 1, nan, nan, 2, nan})
+	// The exact lookback delta covers the interval between samples
+	f(60, true, []float64{nan, 1, 1, 1, 2, 2})
+	f(10, true, []float64{nan, nan, nan, nan, 2, nan})
+}
+
 func TestRollupFuncsNoWindow(t *testing.T) {
 	t.Run("first", func(t *testing.T) {
 		rc := rollupConfig{
@@ -44,6 +44,7 @@ var transformFuncs = map[string]transformFunc{
 	"days_in_month":              newTransformFuncDateTime(transformDaysInMonth),
 	"deg":                        newTransformFuncOneArg(transformDeg),
 	"drop_common_labels":         transformDropCommonLabels,
+	"drop_empty_series":          transformDropEmptySeries,
 	"end":                        newTransformFuncZeroArgs(transformEnd),
 	"exp":                        newTransformFuncOneArg(transformExp),
 	"floor":                      newTransformFuncOneArg(transformFloor),
@@ -59,19 +60,25 @@ var transformFuncs = map[string]transformFunc{
 	"keep_next_value":            transformKeepNextValue,
 	"label_copy":                 transformLabelCopy,
 	"label_del":                  transformLabelDel,
+	"label_del_if":               newTransformFuncLabelIf(transformLabelDel),
 	"label_graphite_group":       transformLabelGraphiteGroup,
 	"label_join":                 transformLabelJoin,
+	"label_join_if":              newTransformFuncLabelIf(transformLabelJoin),
 	"label_keep":                 transformLabelKeep,
+	"label_lookup":               transformLabelLookup,
 	"label_lowercase":            transformLabelLowercase,
 	"label_map":                  transformLabelMap,
 	"label_match":                transformLabelMatch,
 	"label_mismatch":             transformLabelMismatch,
 	"label_move":                 transformLabelMove,
 	"label_replace":              transformLabelReplace,
+	"label_replace_if":           newTransformFuncLabelIf(transformLabelReplace),
 	"label_set":                  transformLabelSet,
+	"label_set_if":               newTransformFuncLabelIf(transformLabelSet),
 	"label_transform":            transformLabelTransform,
 	"label_uppercase":            transformLabelUppercase,
 	"label_value":                transformLabelValue,
+	"labels_equal":               transformLabelsEqual,
 	"limit_offset":               transformLimitOffset,
 	"ln":                         newTransformFuncOneArg(transformLn),
 	"log2":                       newTransformFuncOneArg(transformLog2),
@@ -1730,6 +1737,142 @@ func transformLabelSet(tfa *transformFuncArg) ([]*timeseries, error) {
 	return rvs, nil
 }
 
+// newTransformFuncLabelIf returns transformFunc, which applies tf only to series matching the series selector passed in the second arg.
+//
+// The remaining series are returned as is. For example, `label_set_if(q, "{job=\"foo\"}", "env", "prod")`
+// sets `env="prod"` label only for series with `job="foo"` label.
+func newTransformFuncLabelIf(tf transformFunc) transformFunc {
+	return func(tfa *transformFuncArg) ([]*timeseries, error) {
+		args := tfa.args
+		if len(args) < 2 {
+			return nil, fmt.Errorf(`not enough args; got %d; want at least %d`, len(args), 2)
+		}
+		filter, err := getString(args[1], 1)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get series selector: %w", err)
+		}
+		sm, err := newSeriesMatcher(filter)
+		if err != nil {
+			return nil, err
+		}
+		var matchingSeries, otherSeries []*timeseries
+		for _, ts := range args[0] {
+			if sm.match(&ts.MetricName) {
+				matchingSeries = append(matchingSeries, ts)
+			} else {
+				otherSeries = append(otherSeries, ts)
+			}
+		}
+		tfaMatching := &transformFuncArg{
+			ec:   tfa.ec,
+			fe:   tfa.fe,
+			args: append([][]*timeseries{matchingSeries}, args[2:]...),
+		}
+		rvs, err := tf(tfaMatching)
+		if err != nil {
+			return nil, err
+		}
+		return append(rvs, otherSeries...), nil
+	}
+}
+
+// seriesMatcher matches series against label filters from series selector.
+type seriesMatcher struct {
+	lfs []metricsql.LabelFilter
+	res []*regexp.Regexp
+}
+
+func newSeriesMatcher(filter string) (*seriesMatcher, error) {
+	e, err := metricsql.Parse(filter)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse series selector %q: %w", filter, err)
+	}
+	me, ok := e.(*metricsql.MetricExpr)
+	if !ok {
+		return nil, fmt.Errorf("expecting series selector such as `{job=\"foo\"}`; got %q", filter)
+	}
+	sm := &seriesMatcher{
+		lfs: me.LabelFilters,
+		res: make([]*regexp.Regexp, len(me.LabelFilters)),
+	}
+	for i := range me.LabelFilters {
+		lf := &me.LabelFilters[i]
+		if !lf.IsRegexp {
+			continue
+		}
+		re, err := metricsql.CompileRegexpAnchored(lf.Value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compile regexp %q: %w", lf.Value, err)
+		}
+		sm.res[i] = re
+	}
+	return sm, nil
+}
+
+func (sm *seriesMatcher) match(mn *storage.MetricName) bool {
+	for i := range sm.lfs {
+		lf := &sm.lfs[i]
+		value := getLabelValue(mn, lf.Label)
+		var ok bool
+		if re := sm.res[i]; re != nil {
+			ok = re.Match(value)
+		} else {
+			ok = string(value) == lf.Value
+		}
+		if ok == lf.IsNegative {
+			return false
+		}
+	}
+	return true
+}
+
+func getLabelValue(mn *storage.MetricName, labelName string) []byte {
+	if labelName == "__name__" {
+		return mn.MetricGroup
+	}
+	return mn.GetTagValue(labelName)
+}
+
+func transformLabelsEqual(tfa *transformFuncArg) ([]*timeseries, error) {
+	args := tfa.args
+	if len(args) < 3 {
+		return nil, fmt.Errorf(`not enough args; got %d; want at least %d`, len(args), 3)
+	}
+	var labelNames []string
+	for i := 1; i < len(args); i++ {
+		labelName, err := getString(args[i], i)
+		if err != nil {
+			return nil, err
+		}
+		labelNames = append(labelNames, labelName)
+	}
+	tss := args[0]
+	rvs := tss[:0]
+	for _, ts := range tss {
+		mn := &ts.MetricName
+		value := getLabelValue(mn, labelNames[0])
+		ok := true
+		for _, labelName := range labelNames[1:] {
+			if string(getLabelValue(mn, labelName)) != string(value) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			rvs = append(rvs, ts)
+		}
+	}
+	return rvs, nil
+}
+
+func transformDropEmptySeries(tfa *transformFuncArg) ([]*timeseries, error) {
+	args := tfa.args
+	if err := expectTransformArgsNum(args, 1); err != nil {
+		return nil, err
+	}
+	return removeEmptySeries(args[0]), nil
+}
+
 func transformLabelUppercase(tfa *transformFuncArg) ([]*timeseries, error) {
 	return transformLabelValueFunc(tfa, strings.ToUpper)
 }
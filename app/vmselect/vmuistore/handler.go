@@ -0,0 +1,261 @@
+package vmuistore
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	storePath = flag.String("vmui.storePath", "", "Path to the file for persisting vmui saved queries and query history. "+
+		"By default the file is stored at <-storageDataPath>/vmui/store.json")
+	userHeader = flag.String("vmui.userHeader", "X-Vmui-User", "HTTP request header for identifying the user for vmui saved queries and query history. "+
+		"The header can be set by vmauth via 'headers' option. Requests without this header share the same anonymous namespace. "+
+		"The header value isn't verified, so requests to /vmui/api/* must be accepted only from a trusted proxy, which sets the header. "+
+		"See https://docs.victoriametrics.com/#vmui-saved-queries")
+	tenantHeader             = flag.String("vmui.tenantHeader", "", "Optional HTTP request header for additional per-tenant namespacing of vmui saved queries and query history")
+	maxSavedQueriesPerUser   = flag.Int("vmui.maxSavedQueriesPerUser", 100, "The maximum number of saved queries per user at /vmui/api/saved-queries")
+	maxHistoryEntriesPerUser = flag.Int("vmui.maxHistoryEntriesPerUser", 100, "The maximum number of query history entries per user at /vmui/api/history. "+
+		"The oldest entries are dropped when the limit is reached")
+	maxQueryLen = flag.Int("vmui.maxQueryLen", 16*1024, "The maximum length in bytes for queries stored via /vmui/api/saved-queries and /vmui/api/history")
+	maxUsers    = flag.Int("vmui.maxUsers", 1000, "The maximum number of users with saved queries and query history at /vmui/api/saved-queries and /vmui/api/history. "+
+		"Requests from new users are rejected when the limit is reached")
+	maxBytesPerUser = flagutil.NewBytes("vmui.maxBytesPerUser", 1024*1024, "The maximum size of saved queries and query history per user. "+
+		"The oldest query history entries are dropped when the limit is reached")
+	flushInterval = flag.Duration("vmui.flushInterval", 5*time.Second, "The interval for writing changes in vmui saved queries and query history to -vmui.storePath. "+
+		"Changes made during the last interval may be lost on unclean shutdown")
+)
+
+var st *store
+
+// Init initializes vmui store.
+//
+// defaultPath is used for storing the data if -vmui.storePath isn't set.
+func Init(defaultPath string) {
+	path := *storePath
+	if path == "" {
+		path = defaultPath
+	}
+	s, err := newStore(path, storeLimits{
+		maxUsers:        *maxUsers,
+		maxBytesPerUser: maxBytesPerUser.IntN(),
+		maxSavedQueries: *maxSavedQueriesPerUser,
+		maxHistory:      *maxHistoryEntriesPerUser,
+	})
+	if err != nil {
+		logger.Fatalf("cannot open vmui store: %s", err)
+	}
+	s.startFlusher(*flushInterval)
+	st = s
+}
+
+// Stop stops vmui store and writes the pending changes to -vmui.storePath.
+func Stop() {
+	st.stop()
+}
+
+var (
+	savedQueriesRequests = metrics.NewCounter(`vm_http_requests_total{path="/vmui/api/saved-queries"}`)
+	historyRequests      = metrics.NewCounter(`vm_http_requests_total{path="/vmui/api/history"}`)
+)
+
+// RequestHandler handles vmui store API requests at the given path.
+//
+// It returns false if path doesn't belong to vmui store API.
+func RequestHandler(w http.ResponseWriter, r *http.Request, path string) bool {
+	switch {
+	case path == "/vmui/api/saved-queries":
+		savedQueriesRequests.Inc()
+		handleError(w, r, handleSavedQueries(w, r))
+		return true
+	case strings.HasPrefix(path, "/vmui/api/saved-queries/"):
+		savedQueriesRequests.Inc()
+		id := path[len("/vmui/api/saved-queries/"):]
+		handleError(w, r, handleSavedQuery(w, r, id))
+		return true
+	case path == "/vmui/api/history":
+		historyRequests.Inc()
+		handleError(w, r, handleHistory(w, r))
+		return true
+	default:
+		return false
+	}
+}
+
+func handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+	}
+}
+
+func getUser(r *http.Request) string {
+	user := r.Header.Get(*userHeader)
+	if *tenantHeader == "" {
+		return user
+	}
+	// Quote the tenant and the user, so they cannot collide when containing `/` chars.
+	tenant := r.Header.Get(*tenantHeader)
+	return strconv.Quote(tenant) + "/" + strconv.Quote(user)
+}
+
+func handleSavedQueries(w http.ResponseWriter, r *http.Request) error {
+	user := getUser(r)
+	switch r.Method {
+	case http.MethodGet:
+		return writeJSON(w, http.StatusOK, st.listSavedQueries(user))
+	case http.MethodPost:
+		var sq SavedQuery
+		if err := readSavedQuery(r, &sq); err != nil {
+			return err
+		}
+		sq, err := st.addSavedQuery(user, sq)
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, http.StatusCreated, sq)
+	default:
+		return newMethodNotAllowedError(r)
+	}
+}
+
+func handleSavedQuery(w http.ResponseWriter, r *http.Request, id string) error {
+	user := getUser(r)
+	switch r.Method {
+	case http.MethodGet:
+		sq, ok := st.getSavedQuery(user, id)
+		if !ok {
+			return newNotFoundError(id)
+		}
+		return writeJSON(w, http.StatusOK, sq)
+	case http.MethodPut:
+		var sq SavedQuery
+		if err := readSavedQuery(r, &sq); err != nil {
+			return err
+		}
+		sq.ID = id
+		sq, ok, err := st.updateSavedQuery(user, sq)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return newNotFoundError(id)
+		}
+		return writeJSON(w, http.StatusOK, sq)
+	case http.MethodDelete:
+		if !st.deleteSavedQuery(user, id) {
+			return newNotFoundError(id)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return newMethodNotAllowedError(r)
+	}
+}
+
+func handleHistory(w http.ResponseWriter, r *http.Request) error {
+	user := getUser(r)
+	switch r.Method {
+	case http.MethodGet:
+		return writeJSON(w, http.StatusOK, st.listHistory(user))
+	case http.MethodPost:
+		var he HistoryEntry
+		if err := readJSON(r, &he); err != nil {
+			return err
+		}
+		if err := checkQuery(he.Query); err != nil {
+			return err
+		}
+		if err := st.addHistory(user, he.Query); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	case http.MethodDelete:
+		st.resetHistory(user)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return newMethodNotAllowedError(r)
+	}
+}
+
+func readSavedQuery(r *http.Request, sq *SavedQuery) error {
+	if err := readJSON(r, sq); err != nil {
+		return err
+	}
+	if sq.Name == "" {
+		return newBadRequestError(fmt.Errorf("missing `name` for the saved query"))
+	}
+	if len(sq.Name)+len(sq.Description) > *maxQueryLen {
+		return newBadRequestError(fmt.Errorf("too long `name` and `description` for the saved query; mustn't exceed -vmui.maxQueryLen=%d bytes", *maxQueryLen))
+	}
+	return checkQuery(sq.Query)
+}
+
+func checkQuery(query string) error {
+	if query == "" {
+		return newBadRequestError(fmt.Errorf("missing `query`"))
+	}
+	if len(query) > *maxQueryLen {
+		return newBadRequestError(fmt.Errorf("too long `query` with %d bytes; mustn't exceed -vmui.maxQueryLen=%d bytes", len(query), *maxQueryLen))
+	}
+	return nil
+}
+
+func readJSON(r *http.Request, dst interface{}) error {
+	// Limit the request body size. The additional space is needed for json encoding overhead.
+	maxBodySize := int64(*maxQueryLen)*2 + 1024
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return fmt.Errorf("cannot read request body: %w", err)
+	}
+	if int64(len(data)) > maxBodySize {
+		return newBadRequestError(fmt.Errorf("too big request body; mustn't exceed %d bytes", maxBodySize))
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return newBadRequestError(fmt.Errorf("cannot parse request body: %w", err))
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("cannot marshal response: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(data)
+	return nil
+}
+
+func newBadRequestError(err error) error {
+	return &httpserver.ErrorWithStatusCode{
+		Err:        err,
+		StatusCode: http.StatusBadRequest,
+	}
+}
+
+func newNotFoundError(id string) error {
+	return &httpserver.ErrorWithStatusCode{
+		Err:        fmt.Errorf("cannot find saved query with id=%q", id),
+		StatusCode: http.StatusNotFound,
+	}
+}
+
+func newMethodNotAllowedError(r *http.Request) error {
+	return &httpserver.ErrorWithStatusCode{
+		Err:        fmt.Errorf("unsupported method %q", r.Method),
+		StatusCode: http.StatusMethodNotAllowed,
+	}
+}
@@ -0,0 +1,46 @@
+package vmuistore
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetUser(t *testing.T) {
+	tenantHeaderOrig := *tenantHeader
+	defer func() { *tenantHeader = tenantHeaderOrig }()
+
+	f := func(tenant, user, userExpected string) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/vmui/api/history", nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		if tenant != "" {
+			r.Header.Set("X-Tenant", tenant)
+		}
+		if user != "" {
+			r.Header.Set(*userHeader, user)
+		}
+		if u := getUser(r); u != userExpected {
+			t.Fatalf("unexpected user for tenant=%q, user=%q; got %q; want %q", tenant, user, u, userExpected)
+		}
+	}
+
+	// -vmui.tenantHeader isn't set
+	*tenantHeader = ""
+	f("", "", "")
+	f("", "foo", "foo")
+	f("bar", "foo", "foo")
+
+	// -vmui.tenantHeader is set
+	*tenantHeader = "X-Tenant"
+	f("", "", `""/""`)
+	f("", "foo", `""/"foo"`)
+	f("bar", "foo", `"bar"/"foo"`)
+
+	// Tenants and users with `/` chars mustn't collide
+	f("a/b", "c", `"a/b"/"c"`)
+	f("a", "b/c", `"a"/"b/c"`)
+	f(`a"/"b`, "c", `"a\"/\"b"/"c"`)
+	f("a", `b"/"c`, `"a"/"b\"/\"c"`)
+}
@@ -0,0 +1,428 @@
+package vmuistore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// SavedQuery is a query saved by vmui user.
+type SavedQuery struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Query       string `json:"query"`
+	Description string `json:"description,omitempty"`
+	CreatedAt   int64  `json:"createdAt"`
+	UpdatedAt   int64  `json:"updatedAt"`
+}
+
+// HistoryEntry is a single query from vmui query history.
+type HistoryEntry struct {
+	Query     string `json:"query"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// userData holds saved queries and query history for a single user.
+type userData struct {
+	SavedQueries []SavedQuery   `json:"savedQueries"`
+	History      []HistoryEntry `json:"history"`
+	NextID       uint64         `json:"nextID"`
+}
+
+// storeData is the on-disk representation of store.
+type storeData struct {
+	Users map[string]*userData `json:"users"`
+}
+
+// storeLimits contains limits for the data stored in store.
+type storeLimits struct {
+	// maxUsers is the maximum number of users with the stored data.
+	maxUsers int
+
+	// maxBytesPerUser is the maximum size of saved queries and query history per user.
+	maxBytesPerUser int
+
+	maxSavedQueries int
+	maxHistory      int
+}
+
+// store persists saved queries and query history per user in a JSON file.
+//
+// Changes are written to the file in background by flush, so frequent changes do not result in frequent file writes.
+//
+// All the methods are safe to call from concurrently running goroutines.
+type store struct {
+	path   string
+	limits storeLimits
+
+	mu   sync.Mutex
+	data storeData
+
+	// dirty is set if data contains changes, which aren't written to the file yet.
+	dirty bool
+
+	// flushLock serializes writes to the file.
+	flushLock sync.Mutex
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// newStore opens store at the given path.
+//
+// The file at path is created on the first flush after the modification if it doesn't exist.
+func newStore(path string, limits storeLimits) (*store, error) {
+	s := &store{
+		path:   path,
+		limits: limits,
+		data: storeData{
+			Users: make(map[string]*userData),
+		},
+		stopCh: make(chan struct{}),
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("cannot read %q: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &s.data); err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+	if s.data.Users == nil {
+		s.data.Users = make(map[string]*userData)
+	}
+	return s, nil
+}
+
+// startFlusher starts writing the changed data to the file every flushInterval.
+//
+// stop must be called when the store is no longer needed.
+func (s *store) startFlusher(flushInterval time.Duration) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(flushInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-t.C:
+				if err := s.flush(); err != nil {
+					logger.Errorf("%s", err)
+				}
+			}
+		}
+	}()
+}
+
+// stop stops the flusher started via startFlusher and writes the pending changes to the file.
+func (s *store) stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+	if err := s.flush(); err != nil {
+		logger.Errorf("%s", err)
+	}
+}
+
+// flush writes the pending changes to the file.
+func (s *store) flush() error {
+	s.flushLock.Lock()
+	defer s.flushLock.Unlock()
+
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(&s.data)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal vmui store data: %s", err)
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err := s.writeFile(data); err != nil {
+		// Retry writing the data on the next flush.
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *store) writeFile(data []byte) error {
+	if err := fs.MkdirAllIfNotExist(filepath.Dir(s.path)); err != nil {
+		return fmt.Errorf("cannot create directory for %q: %w", s.path, err)
+	}
+	if err := fs.WriteFileAtomically(s.path, data, true); err != nil {
+		return fmt.Errorf("cannot persist vmui store data: %w", err)
+	}
+	return nil
+}
+
+func (s *store) getUserLocked(user string) *userData {
+	return s.data.Users[user]
+}
+
+// getOrCreateUserLocked returns data for the given user, creating it if needed.
+//
+// An error is returned if the number of users reaches -vmui.maxUsers.
+func (s *store) getOrCreateUserLocked(user string) (*userData, error) {
+	ud := s.data.Users[user]
+	if ud != nil {
+		return ud, nil
+	}
+	if len(s.data.Users) >= s.limits.maxUsers {
+		return nil, newBadRequestError(fmt.Errorf("cannot store data for more than -vmui.maxUsers=%d users", s.limits.maxUsers))
+	}
+	ud = &userData{}
+	s.data.Users[user] = ud
+	return ud, nil
+}
+
+// deleteUserIfEmptyLocked deletes the data for the given user if it contains neither saved queries nor query history.
+//
+// This frees the slot for new users limited by -vmui.maxUsers.
+func (s *store) deleteUserIfEmptyLocked(user string, ud *userData) {
+	if len(ud.SavedQueries) == 0 && len(ud.History) == 0 {
+		delete(s.data.Users, user)
+	}
+}
+
+// fitUserLocked drops the oldest history entries for ud until it fits -vmui.maxBytesPerUser.
+//
+// An error is returned if ud doesn't fit the limit even without query history.
+func (s *store) fitUserLocked(ud *userData) error {
+	n := ud.sizeBytes()
+	h := ud.History
+	for n > s.limits.maxBytesPerUser && len(h) > 0 {
+		n -= len(h[0].Query)
+		h = h[1:]
+	}
+	if n > s.limits.maxBytesPerUser {
+		return newBadRequestError(fmt.Errorf("cannot store more than -vmui.maxBytesPerUser=%d bytes of saved queries per user", s.limits.maxBytesPerUser))
+	}
+	ud.History = h
+	return nil
+}
+
+func (s *store) markDirtyLocked() {
+	s.dirty = true
+}
+
+// listSavedQueries returns saved queries for the given user sorted by name.
+func (s *store) listSavedQueries(user string) []SavedQuery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ud := s.getUserLocked(user)
+	if ud == nil {
+		return []SavedQuery{}
+	}
+	sqs := append([]SavedQuery{}, ud.SavedQueries...)
+	sort.Slice(sqs, func(i, j int) bool {
+		return sqs[i].Name < sqs[j].Name
+	})
+	return sqs
+}
+
+// getSavedQuery returns saved query with the given id for the given user.
+func (s *store) getSavedQuery(user, id string) (SavedQuery, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ud := s.getUserLocked(user)
+	if ud == nil {
+		return SavedQuery{}, false
+	}
+	n := ud.indexOf(id)
+	if n < 0 {
+		return SavedQuery{}, false
+	}
+	return ud.SavedQueries[n], true
+}
+
+// addSavedQuery adds sq to saved queries for the given user and returns the added query.
+func (s *store) addSavedQuery(user string, sq SavedQuery) (SavedQuery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ud, err := s.getOrCreateUserLocked(user)
+	if err != nil {
+		return SavedQuery{}, err
+	}
+	if len(ud.SavedQueries) >= s.limits.maxSavedQueries {
+		return SavedQuery{}, newBadRequestError(fmt.Errorf("cannot save more than -vmui.maxSavedQueriesPerUser=%d queries", s.limits.maxSavedQueries))
+	}
+	ud.SavedQueries = append(ud.SavedQueries, sq)
+	if err := s.fitUserLocked(ud); err != nil {
+		ud.SavedQueries = ud.SavedQueries[:len(ud.SavedQueries)-1]
+		s.deleteUserIfEmptyLocked(user, ud)
+		return SavedQuery{}, err
+	}
+	ud.NextID++
+	sq.ID = strconv.FormatUint(ud.NextID, 10)
+	sq.CreatedAt = time.Now().Unix()
+	sq.UpdatedAt = sq.CreatedAt
+	ud.SavedQueries[len(ud.SavedQueries)-1] = sq
+	s.markDirtyLocked()
+	return sq, nil
+}
+
+// updateSavedQuery updates the saved query with sq.ID for the given user.
+//
+// false is returned if there is no saved query with sq.ID.
+func (s *store) updateSavedQuery(user string, sq SavedQuery) (SavedQuery, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ud := s.getUserLocked(user)
+	if ud == nil {
+		return SavedQuery{}, false, nil
+	}
+	n := ud.indexOf(sq.ID)
+	if n < 0 {
+		return SavedQuery{}, false, nil
+	}
+	prev := ud.SavedQueries[n]
+	sq.CreatedAt = prev.CreatedAt
+	sq.UpdatedAt = time.Now().Unix()
+	ud.SavedQueries[n] = sq
+	if err := s.fitUserLocked(ud); err != nil {
+		ud.SavedQueries[n] = prev
+		return SavedQuery{}, true, err
+	}
+	s.markDirtyLocked()
+	return sq, true, nil
+}
+
+// deleteSavedQuery deletes the saved query with the given id for the given user.
+//
+// false is returned if there is no saved query with the given id.
+func (s *store) deleteSavedQuery(user, id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ud := s.getUserLocked(user)
+	if ud == nil {
+		return false
+	}
+	n := ud.indexOf(id)
+	if n < 0 {
+		return false
+	}
+	prev := ud.SavedQueries
+	sqs := make([]SavedQuery, 0, len(prev)-1)
+	sqs = append(sqs, prev[:n]...)
+	sqs = append(sqs, prev[n+1:]...)
+	ud.SavedQueries = sqs
+	s.deleteUserIfEmptyLocked(user, ud)
+	s.markDirtyLocked()
+	return true
+}
+
+// listHistory returns query history for the given user starting from the most recent entry.
+func (s *store) listHistory(user string) []HistoryEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ud := s.getUserLocked(user)
+	if ud == nil {
+		return []HistoryEntry{}
+	}
+	h := make([]HistoryEntry, 0, len(ud.History))
+	for i := len(ud.History) - 1; i >= 0; i-- {
+		h = append(h, ud.History[i])
+	}
+	return h
+}
+
+// addHistory appends query to the history for the given user.
+//
+// The oldest entries are dropped if the history exceeds -vmui.maxHistoryEntriesPerUser or -vmui.maxBytesPerUser.
+// Consecutive duplicate queries are stored only once.
+func (s *store) addHistory(user, query string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ud, err := s.getOrCreateUserLocked(user)
+	if err != nil {
+		return err
+	}
+	prev := ud.History
+	now := time.Now().Unix()
+	if len(prev) > 0 && prev[len(prev)-1].Query == query {
+		ud.History = append(prev[:len(prev)-1:len(prev)-1], HistoryEntry{
+			Query:     query,
+			Timestamp: now,
+		})
+	} else {
+		ud.History = append(prev[:len(prev):len(prev)], HistoryEntry{
+			Query:     query,
+			Timestamp: now,
+		})
+	}
+	if n := len(ud.History) - s.limits.maxHistory; n > 0 {
+		ud.History = ud.History[n:]
+	}
+	err = s.fitUserLocked(ud)
+	if err == nil && len(ud.History) == 0 {
+		// The added entry has been dropped, since it doesn't fit -vmui.maxBytesPerUser together with saved queries.
+		err = newBadRequestError(fmt.Errorf("cannot store more than -vmui.maxBytesPerUser=%d bytes of saved queries and query history per user", s.limits.maxBytesPerUser))
+	}
+	if err != nil {
+		ud.History = prev
+		s.deleteUserIfEmptyLocked(user, ud)
+		return err
+	}
+	s.markDirtyLocked()
+	return nil
+}
+
+// resetHistory removes query history for the given user.
+func (s *store) resetHistory(user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ud := s.getUserLocked(user)
+	if ud == nil || len(ud.History) == 0 {
+		return
+	}
+	ud.History = nil
+	s.deleteUserIfEmptyLocked(user, ud)
+	s.markDirtyLocked()
+}
+
+func (ud *userData) indexOf(id string) int {
+	for i := range ud.SavedQueries {
+		if ud.SavedQueries[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// sizeBytes returns the size of saved queries and query history in ud.
+func (ud *userData) sizeBytes() int {
+	n := 0
+	for i := range ud.SavedQueries {
+		sq := &ud.SavedQueries[i]
+		n += len(sq.Name) + len(sq.Query) + len(sq.Description)
+	}
+	for i := range ud.History {
+		n += len(ud.History[i].Query)
+	}
+	return n
+}
@@ -0,0 +1,292 @@
+package vmuistore
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStoreSavedQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vmui", "store.json")
+	s, err := newStore(path, newTestStoreLimits(2, 10))
+	if err != nil {
+		t.Fatalf("cannot create store: %s", err)
+	}
+	if sqs := s.listSavedQueries("foo"); len(sqs) != 0 {
+		t.Fatalf("unexpected saved queries for empty store: %v", sqs)
+	}
+	sq1, err := s.addSavedQuery("foo", SavedQuery{Name: "b", Query: "up"})
+	if err != nil {
+		t.Fatalf("cannot add saved query: %s", err)
+	}
+	sq2, err := s.addSavedQuery("foo", SavedQuery{Name: "a", Query: "rate(x[5m])"})
+	if err != nil {
+		t.Fatalf("cannot add saved query: %s", err)
+	}
+	if sq1.ID == sq2.ID {
+		t.Fatalf("saved queries must have distinct ids; got %q", sq1.ID)
+	}
+	if _, err := s.addSavedQuery("foo", SavedQuery{Name: "c", Query: "x"}); err == nil {
+		t.Fatalf("expecting non-nil error when exceeding the limit on saved queries")
+	}
+
+	// Queries for other users must be isolated.
+	if _, err := s.addSavedQuery("bar", SavedQuery{Name: "c", Query: "x"}); err != nil {
+		t.Fatalf("cannot add saved query for another user: %s", err)
+	}
+	if sq, ok := s.getSavedQuery("bar", sq2.ID); ok {
+		t.Fatalf("user bar mustn't see saved query from user foo: %v", sq)
+	}
+
+	sqs := s.listSavedQueries("foo")
+	if len(sqs) != 2 || sqs[0].Name != "a" || sqs[1].Name != "b" {
+		t.Fatalf("unexpected saved queries: %v", sqs)
+	}
+
+	sq1.Query = "up == 0"
+	if _, ok, err := s.updateSavedQuery("foo", sq1); err != nil || !ok {
+		t.Fatalf("cannot update saved query; ok=%v, err=%v", ok, err)
+	}
+	if _, ok, _ := s.updateSavedQuery("foo", SavedQuery{ID: "missing", Name: "x", Query: "y"}); ok {
+		t.Fatalf("expecting false when updating missing saved query")
+	}
+	if !s.deleteSavedQuery("foo", sq2.ID) {
+		t.Fatalf("cannot delete saved query")
+	}
+	if s.deleteSavedQuery("foo", sq2.ID) {
+		t.Fatalf("expecting false when deleting already deleted saved query")
+	}
+
+	// Re-open the store and verify the data is persisted.
+	if err := s.flush(); err != nil {
+		t.Fatalf("cannot flush store: %s", err)
+	}
+	s, err = newStore(path, newTestStoreLimits(2, 10))
+	if err != nil {
+		t.Fatalf("cannot re-open store: %s", err)
+	}
+	sqs = s.listSavedQueries("foo")
+	if len(sqs) != 1 || sqs[0].ID != sq1.ID || sqs[0].Query != "up == 0" {
+		t.Fatalf("unexpected saved queries after re-opening the store: %v", sqs)
+	}
+
+	// New ids mustn't clash with the previously issued ids.
+	sq3, err := s.addSavedQuery("foo", SavedQuery{Name: "d", Query: "z"})
+	if err != nil {
+		t.Fatalf("cannot add saved query: %s", err)
+	}
+	if sq3.ID == sq1.ID || sq3.ID == sq2.ID {
+		t.Fatalf("unexpected id for the new saved query: %q", sq3.ID)
+	}
+}
+
+func TestStoreHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	s, err := newStore(path, newTestStoreLimits(10, 3))
+	if err != nil {
+		t.Fatalf("cannot create store: %s", err)
+	}
+	for _, q := range []string{"a", "b", "b", "c", "d"} {
+		if err := s.addHistory("foo", q); err != nil {
+			t.Fatalf("cannot add history entry: %s", err)
+		}
+	}
+	f := func(user string, queriesExpected []string) {
+		t.Helper()
+		var queries []string
+		for _, he := range s.listHistory(user) {
+			queries = append(queries, he.Query)
+		}
+		if strings.Join(queries, ",") != strings.Join(queriesExpected, ",") {
+			t.Fatalf("unexpected history for user %q; got %q; want %q", user, queries, queriesExpected)
+		}
+	}
+	f("foo", []string{"d", "c", "b"})
+	f("bar", nil)
+
+	s.resetHistory("foo")
+	f("foo", nil)
+}
+
+func TestStoreConcurrentWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	s, err := newStore(path, newTestStoreLimits(1000, 1000))
+	if err != nil {
+		t.Fatalf("cannot create store: %s", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, err := s.addSavedQuery("foo", SavedQuery{Name: fmt.Sprintf("q_%d_%d", n, j), Query: "up"}); err != nil {
+					panic(fmt.Errorf("cannot add saved query: %w", err))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := s.flush(); err != nil {
+		t.Fatalf("cannot flush store: %s", err)
+	}
+
+	s, err = newStore(path, newTestStoreLimits(1000, 1000))
+	if err != nil {
+		t.Fatalf("cannot re-open store: %s", err)
+	}
+	if n := len(s.listSavedQueries("foo")); n != 100 {
+		t.Fatalf("unexpected number of saved queries; got %d; want 100", n)
+	}
+}
+
+func newTestStoreLimits(maxSavedQueries, maxHistory int) storeLimits {
+	return storeLimits{
+		maxUsers:        100,
+		maxBytesPerUser: 1024 * 1024,
+		maxSavedQueries: maxSavedQueries,
+		maxHistory:      maxHistory,
+	}
+}
+
+func TestStoreLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	s, err := newStore(path, storeLimits{
+		maxUsers:        2,
+		maxBytesPerUser: 10,
+		maxSavedQueries: 10,
+		maxHistory:      10,
+	})
+	if err != nil {
+		t.Fatalf("cannot create store: %s", err)
+	}
+
+	// The oldest history entries must be dropped when exceeding the limit on bytes per user.
+	for _, q := range []string{"aaa", "bbb", "ccc", "ddd"} {
+		if err := s.addHistory("foo", q); err != nil {
+			t.Fatalf("cannot add history entry: %s", err)
+		}
+	}
+	if h := s.listHistory("foo"); len(h) != 3 || h[0].Query != "ddd" || h[2].Query != "bbb" {
+		t.Fatalf("unexpected history: %v", h)
+	}
+
+	// Saved queries must drop history entries and must be rejected if they do not fit the limit.
+	if _, err := s.addSavedQuery("foo", SavedQuery{Name: "x", Query: "up"}); err != nil {
+		t.Fatalf("cannot add saved query: %s", err)
+	}
+	if h := s.listHistory("foo"); len(h) != 2 {
+		t.Fatalf("unexpected history after adding saved query: %v", h)
+	}
+	if _, err := s.addSavedQuery("foo", SavedQuery{Name: "y", Query: "too_long_query"}); err == nil {
+		t.Fatalf("expecting non-nil error when exceeding the limit on bytes per user")
+	}
+	if sqs := s.listSavedQueries("foo"); len(sqs) != 1 {
+		t.Fatalf("unexpected saved queries after the failed addition: %v", sqs)
+	}
+	if err := s.addHistory("foo", "too_long_query"); err == nil {
+		t.Fatalf("expecting non-nil error for history entry exceeding the limit on bytes per user")
+	}
+
+	// New users must be rejected when exceeding the limit on users.
+	if err := s.addHistory("bar", "a"); err != nil {
+		t.Fatalf("cannot add history entry: %s", err)
+	}
+	if err := s.addHistory("baz", "a"); err == nil {
+		t.Fatalf("expecting non-nil error when exceeding the limit on users")
+	}
+	if _, err := s.addSavedQuery("baz", SavedQuery{Name: "x", Query: "up"}); err == nil {
+		t.Fatalf("expecting non-nil error when exceeding the limit on users")
+	}
+
+	// Users without data mustn't count towards the limit.
+	s.resetHistory("bar")
+	if err := s.addHistory("baz", "a"); err != nil {
+		t.Fatalf("cannot add history entry after freeing the slot for users: %s", err)
+	}
+}
+
+func TestStoreFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	s, err := newStore(path, newTestStoreLimits(10, 10))
+	if err != nil {
+		t.Fatalf("cannot create store: %s", err)
+	}
+	s.startFlusher(time.Hour)
+	if err := s.addHistory("foo", "up"); err != nil {
+		t.Fatalf("cannot add history entry: %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the file mustn't be written before flush; got err=%v", err)
+	}
+
+	// stop must write the pending changes.
+	s.stop()
+	s, err = newStore(path, newTestStoreLimits(10, 10))
+	if err != nil {
+		t.Fatalf("cannot re-open store: %s", err)
+	}
+	if h := s.listHistory("foo"); len(h) != 1 || h[0].Query != "up" {
+		t.Fatalf("unexpected history after re-opening the store: %v", h)
+	}
+}
+
+func TestNewStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	if err := os.WriteFile(path, []byte("invalid json"), 0600); err != nil {
+		t.Fatalf("cannot write file: %s", err)
+	}
+	if _, err := newStore(path, newTestStoreLimits(10, 10)); err == nil {
+		t.Fatalf("expecting non-nil error for invalid store file")
+	}
+}
+
+func TestRequestHandler(t *testing.T) {
+	Init(filepath.Join(t.TempDir(), "store.json"))
+	defer Stop()
+
+	f := func(method, path, user, body string, statusCodeExpected int) string {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("X-Vmui-User", user)
+		w := httptest.NewRecorder()
+		if !RequestHandler(w, r, path) {
+			t.Fatalf("unexpected false result from RequestHandler for %s %s", method, path)
+		}
+		if w.Code != statusCodeExpected {
+			t.Fatalf("unexpected status code for %s %s; got %d; want %d; response: %q", method, path, w.Code, statusCodeExpected, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	f(http.MethodGet, "/vmui/api/saved-queries", "foo", "", http.StatusOK)
+	f(http.MethodPost, "/vmui/api/saved-queries", "foo", `{"name":"x","query":"up"}`, http.StatusCreated)
+	f(http.MethodPost, "/vmui/api/saved-queries", "foo", `{"name":"x"}`, http.StatusBadRequest)
+	f(http.MethodPost, "/vmui/api/saved-queries", "foo", `invalid`, http.StatusBadRequest)
+	f(http.MethodGet, "/vmui/api/saved-queries/1", "foo", "", http.StatusOK)
+	f(http.MethodGet, "/vmui/api/saved-queries/1", "bar", "", http.StatusNotFound)
+	f(http.MethodPut, "/vmui/api/saved-queries/1", "foo", `{"name":"y","query":"down"}`, http.StatusOK)
+	f(http.MethodDelete, "/vmui/api/saved-queries/1", "bar", "", http.StatusNotFound)
+	f(http.MethodDelete, "/vmui/api/saved-queries/1", "foo", "", http.StatusNoContent)
+	f(http.MethodPatch, "/vmui/api/saved-queries", "foo", "", http.StatusMethodNotAllowed)
+
+	f(http.MethodPost, "/vmui/api/history", "foo", `{"query":"up"}`, http.StatusNoContent)
+	if resp := f(http.MethodGet, "/vmui/api/history", "foo", "", http.StatusOK); !strings.Contains(resp, `"query":"up"`) {
+		t.Fatalf("missing query in the history response: %q", resp)
+	}
+	if resp := f(http.MethodGet, "/vmui/api/history", "bar", "", http.StatusOK); resp != "[]" {
+		t.Fatalf("unexpected history for another user: %q", resp)
+	}
+	f(http.MethodDelete, "/vmui/api/history", "foo", "", http.StatusNoContent)
+
+	r := httptest.NewRequest(http.MethodGet, "/vmui/index.html", nil)
+	if RequestHandler(httptest.NewRecorder(), r, "/vmui/index.html") {
+		t.Fatalf("RequestHandler mustn't handle static vmui files")
+	}
+}
@@ -0,0 +1,235 @@
+package vmstorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// maxFinishedForceMergeJobs is the maximum number of finished forced merge jobs to keep for /internal/force_merge/status.
+const maxFinishedForceMergeJobs = 100
+
+// forceMergeJob is a forced merge started via /internal/force_merge.
+type forceMergeJob struct {
+	id                  uint64
+	partitionNamePrefix string
+	startTime           time.Time
+
+	fmp storage.ForceMergeProgress
+
+	// mu protects the fields below
+	mu         sync.Mutex
+	finishTime time.Time
+	err        error
+}
+
+// forceMergeJobStatus is the status of forceMergeJob returned from /internal/force_merge/status.
+type forceMergeJobStatus struct {
+	ID              string `json:"id"`
+	PartitionPrefix string `json:"partitionPrefix"`
+	Status          string `json:"status"`
+	Error           string `json:"error,omitempty"`
+	StartTime       string `json:"startTime"`
+	FinishTime      string `json:"finishTime,omitempty"`
+	PartsMerged     uint64 `json:"partsMerged"`
+	PartsRemaining  uint64 `json:"partsRemaining"`
+	BytesWritten    uint64 `json:"bytesWritten"`
+}
+
+func (job *forceMergeJob) status() forceMergeJobStatus {
+	partsTotal := atomic.LoadUint64(&job.fmp.PartsTotal)
+	partsMerged := atomic.LoadUint64(&job.fmp.PartsMerged)
+	partsRemaining := uint64(0)
+	if partsTotal > partsMerged {
+		partsRemaining = partsTotal - partsMerged
+	}
+	js := forceMergeJobStatus{
+		ID:              strconv.FormatUint(job.id, 10),
+		PartitionPrefix: job.partitionNamePrefix,
+		Status:          "running",
+		StartTime:       job.startTime.UTC().Format(time.RFC3339),
+		PartsMerged:     partsMerged,
+		PartsRemaining:  partsRemaining,
+		BytesWritten:    atomic.LoadUint64(&job.fmp.BytesWritten),
+	}
+	job.mu.Lock()
+	if !job.finishTime.IsZero() {
+		js.Status = "finished"
+		js.FinishTime = job.finishTime.UTC().Format(time.RFC3339)
+		js.PartsRemaining = 0
+		if job.err != nil {
+			js.Status = "error"
+			js.Error = job.err.Error()
+		}
+	}
+	job.mu.Unlock()
+	return js
+}
+
+func (job *forceMergeJob) isFinished() bool {
+	job.mu.Lock()
+	ok := !job.finishTime.IsZero()
+	job.mu.Unlock()
+	return ok
+}
+
+var forceMergeJobs struct {
+	mu     sync.Mutex
+	nextID uint64
+	jobs   []*forceMergeJob
+}
+
+// startForceMergeJob starts forced merge for partitions starting with partitionNamePrefix in background via forceMerge.
+//
+// forceMerge is usually set to Storage.ForceMergePartitions.
+// The number of concurrently running jobs is limited by -bigMergeConcurrency.
+// Concurrently running jobs cannot merge the same partitions.
+func startForceMergeJob(partitionNamePrefix string, forceMerge func(partitionNamePrefix string, fmp *storage.ForceMergeProgress) error) (*forceMergeJob, error) {
+	forceMergeJobs.mu.Lock()
+	defer forceMergeJobs.mu.Unlock()
+
+	running := 0
+	for _, job := range forceMergeJobs.jobs {
+		if job.isFinished() {
+			continue
+		}
+		running++
+		if strings.HasPrefix(job.partitionNamePrefix, partitionNamePrefix) || strings.HasPrefix(partitionNamePrefix, job.partitionNamePrefix) {
+			return nil, &httpserver.ErrorWithStatusCode{
+				Err:        fmt.Errorf("forced merge job id=%d for the overlapping partition_prefix=%q is already running", job.id, job.partitionNamePrefix),
+				StatusCode: http.StatusConflict,
+			}
+		}
+	}
+	if maxJobs := storage.GetBigMergeWorkersCount(); running >= maxJobs {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("cannot start more than %d concurrent forced merges; see -bigMergeConcurrency command-line flag", maxJobs),
+			StatusCode: http.StatusTooManyRequests,
+		}
+	}
+
+	forceMergeJobs.nextID++
+	job := &forceMergeJob{
+		id:                  forceMergeJobs.nextID,
+		partitionNamePrefix: partitionNamePrefix,
+		startTime:           time.Now(),
+	}
+	forceMergeJobs.jobs = append(forceMergeJobs.jobs, job)
+	removeFinishedForceMergeJobsLocked()
+
+	go func() {
+		activeForceMerges.Inc()
+		defer activeForceMerges.Dec()
+		logger.Infof("forced merge id=%d for partition_prefix=%q has been started", job.id, partitionNamePrefix)
+		err := forceMerge(partitionNamePrefix, &job.fmp)
+		if err != nil {
+			logger.Errorf("error in forced merge id=%d for partition_prefix=%q: %s", job.id, partitionNamePrefix, err)
+		} else {
+			logger.Infof("forced merge id=%d for partition_prefix=%q has been successfully finished in %.3f seconds",
+				job.id, partitionNamePrefix, time.Since(job.startTime).Seconds())
+		}
+		job.mu.Lock()
+		job.finishTime = time.Now()
+		job.err = err
+		job.mu.Unlock()
+	}()
+	return job, nil
+}
+
+func removeFinishedForceMergeJobsLocked() {
+	finished := 0
+	for _, job := range forceMergeJobs.jobs {
+		if job.isFinished() {
+			finished++
+		}
+	}
+	excess := finished - maxFinishedForceMergeJobs
+	if excess <= 0 {
+		return
+	}
+	// Remove the oldest finished jobs.
+	jobs := forceMergeJobs.jobs[:0]
+	for _, job := range forceMergeJobs.jobs {
+		if excess > 0 && job.isFinished() {
+			excess--
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	forceMergeJobs.jobs = jobs
+}
+
+// getForceMergeJobStatuses returns statuses for forced merge jobs.
+//
+// If id is non-empty, then only the status for the job with the given id is returned.
+// Otherwise statuses for all the running jobs and up to maxFinishedForceMergeJobs finished jobs are returned.
+func getForceMergeJobStatuses(id string) []forceMergeJobStatus {
+	forceMergeJobs.mu.Lock()
+	jobs := append([]*forceMergeJob{}, forceMergeJobs.jobs...)
+	forceMergeJobs.mu.Unlock()
+
+	// Return the most recent jobs first.
+	statuses := []forceMergeJobStatus{}
+	for i := len(jobs) - 1; i >= 0; i-- {
+		job := jobs[i]
+		if id != "" && strconv.FormatUint(job.id, 10) != id {
+			continue
+		}
+		statuses = append(statuses, job.status())
+	}
+	return statuses
+}
+
+func handleForceMerge(w http.ResponseWriter, r *http.Request) {
+	partitionNamePrefix := r.FormValue("partition_prefix")
+	w.Header().Set("Content-Type", "application/json")
+	if r.FormValue("dry_run") == "1" {
+		fmes := Storage.GetForceMergeEstimates(partitionNamePrefix)
+		if fmes == nil {
+			fmes = []storage.ForceMergeEstimate{}
+		}
+		totalBytes := uint64(0)
+		for _, fme := range fmes {
+			totalBytes += fme.SizeBytes
+		}
+		data, err := json.Marshal(fmes)
+		if err != nil {
+			logger.Panicf("BUG: cannot marshal force merge estimates: %s", err)
+		}
+		fmt.Fprintf(w, `{"status":"ok","partitions":%s,"estimatedBytes":%d}`, data, totalBytes)
+		return
+	}
+	job, err := startForceMergeJob(partitionNamePrefix, Storage.ForceMergePartitions)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+	fmt.Fprintf(w, `{"status":"ok","id":"%d"}`, job.id)
+}
+
+func handleForceMergeStatus(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	statuses := getForceMergeJobStatuses(id)
+	if id != "" && len(statuses) == 0 {
+		httpserver.Errorf(w, r, "%s", &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("cannot find forced merge job with id=%q", id),
+			StatusCode: http.StatusNotFound,
+		})
+		return
+	}
+	data, err := json.Marshal(statuses)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal force merge statuses: %s", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"status":"ok","jobs":%s}`, data)
+}
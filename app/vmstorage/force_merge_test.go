@@ -0,0 +1,168 @@
+package vmstorage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestForceMergeJobs(t *testing.T) {
+	bigMergeWorkersCount := storage.GetBigMergeWorkersCount()
+	defer func() {
+		storage.SetBigMergeWorkersCount(bigMergeWorkersCount)
+		resetForceMergeJobs()
+	}()
+	storage.SetBigMergeWorkersCount(2)
+	resetForceMergeJobs()
+
+	// fakeForceMerge reports the progress and waits for the result from the returned channel.
+	fakeForceMerge := func() (func(partitionNamePrefix string, fmp *storage.ForceMergeProgress) error, chan<- error) {
+		resultCh := make(chan error)
+		forceMerge := func(partitionNamePrefix string, fmp *storage.ForceMergeProgress) error {
+			atomic.AddUint64(&fmp.PartsTotal, 10)
+			atomic.AddUint64(&fmp.BytesWritten, 1234)
+			atomic.AddUint64(&fmp.PartsMerged, 4)
+			return <-resultCh
+		}
+		return forceMerge, resultCh
+	}
+	startJob := func(partitionNamePrefix string) (*forceMergeJob, chan<- error) {
+		t.Helper()
+		forceMerge, resultCh := fakeForceMerge()
+		job, err := startForceMergeJob(partitionNamePrefix, forceMerge)
+		if err != nil {
+			t.Fatalf("cannot start forced merge for partition_prefix=%q: %s", partitionNamePrefix, err)
+		}
+		return job, resultCh
+	}
+	startJobError := func(partitionNamePrefix string, statusCodeExpected int) {
+		t.Helper()
+		forceMerge, _ := fakeForceMerge()
+		job, err := startForceMergeJob(partitionNamePrefix, forceMerge)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when starting forced merge for partition_prefix=%q", partitionNamePrefix)
+		}
+		if job != nil {
+			t.Fatalf("expecting nil job on error; got job id=%d", job.id)
+		}
+		var esc *httpserver.ErrorWithStatusCode
+		if !errors.As(err, &esc) {
+			t.Fatalf("unexpected error type %T; want *httpserver.ErrorWithStatusCode", err)
+		}
+		if esc.StatusCode != statusCodeExpected {
+			t.Fatalf("unexpected status code; got %d; want %d", esc.StatusCode, statusCodeExpected)
+		}
+	}
+	waitForStatus := func(job *forceMergeJob, statusExpected string) forceMergeJobStatus {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			js := job.status()
+			if js.Status == statusExpected && (statusExpected != "running" || js.PartsMerged > 0) {
+				return js
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout when waiting for %q status for job id=%d; last status: %+v", statusExpected, job.id, js)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	job1, resultCh1 := startJob("2023_01")
+	js := waitForStatus(job1, "running")
+	if js.ID != "1" || js.PartitionPrefix != "2023_01" || js.PartsMerged != 4 || js.PartsRemaining != 6 || js.BytesWritten != 1234 || js.FinishTime != "" {
+		t.Fatalf("unexpected status for the running job: %+v", js)
+	}
+
+	// Jobs for overlapping partitions cannot run concurrently.
+	startJobError("2023_01", http.StatusConflict)
+	startJobError("2023", http.StatusConflict)
+	startJobError("", http.StatusConflict)
+
+	// The number of concurrent jobs is limited.
+	job2, resultCh2 := startJob("2023_02")
+	startJobError("2023_03", http.StatusTooManyRequests)
+
+	// Finished jobs do not prevent starting new jobs.
+	resultCh1 <- nil
+	js = waitForStatus(job1, "finished")
+	if js.PartsRemaining != 0 || js.Error != "" || js.FinishTime == "" {
+		t.Fatalf("unexpected status for the finished job: %+v", js)
+	}
+	job3, resultCh3 := startJob("2023_01")
+
+	resultCh2 <- fmt.Errorf("some error")
+	js = waitForStatus(job2, "error")
+	if js.Error != "some error" || js.PartsRemaining != 0 {
+		t.Fatalf("unexpected status for the failed job: %+v", js)
+	}
+	resultCh3 <- nil
+	waitForStatus(job3, "finished")
+
+	// Verify statuses are returned in the reverse order of job creation.
+	statuses := getForceMergeJobStatuses("")
+	var ids []string
+	for _, js := range statuses {
+		ids = append(ids, js.ID)
+	}
+	if fmt.Sprintf("%s", ids) != "[3 2 1]" {
+		t.Fatalf("unexpected job ids; got %s; want [3 2 1]", ids)
+	}
+
+	// Verify status lookup by id.
+	statuses = getForceMergeJobStatuses("2")
+	if len(statuses) != 1 || statuses[0].ID != "2" {
+		t.Fatalf("unexpected statuses for id=2: %+v", statuses)
+	}
+	if statuses := getForceMergeJobStatuses("123"); len(statuses) != 0 {
+		t.Fatalf("expecting empty statuses for missing id; got %+v", statuses)
+	}
+}
+
+func TestRemoveFinishedForceMergeJobs(t *testing.T) {
+	defer resetForceMergeJobs()
+	resetForceMergeJobs()
+
+	newJob := func(id uint64, isFinished bool) *forceMergeJob {
+		job := &forceMergeJob{
+			id: id,
+		}
+		if isFinished {
+			job.finishTime = time.Now()
+		}
+		return job
+	}
+
+	// The running job must be preserved, while the oldest finished jobs must be removed.
+	forceMergeJobs.jobs = append(forceMergeJobs.jobs, newJob(1, false))
+	for i := 0; i < maxFinishedForceMergeJobs+5; i++ {
+		forceMergeJobs.jobs = append(forceMergeJobs.jobs, newJob(uint64(i+2), true))
+	}
+	forceMergeJobs.mu.Lock()
+	removeFinishedForceMergeJobsLocked()
+	forceMergeJobs.mu.Unlock()
+
+	jobs := forceMergeJobs.jobs
+	if len(jobs) != maxFinishedForceMergeJobs+1 {
+		t.Fatalf("unexpected number of jobs; got %d; want %d", len(jobs), maxFinishedForceMergeJobs+1)
+	}
+	if jobs[0].id != 1 {
+		t.Fatalf("the running job must be preserved; got id=%d at the first position", jobs[0].id)
+	}
+	if jobs[1].id != 7 {
+		t.Fatalf("the oldest finished jobs must be removed; got id=%d at the second position; want 7", jobs[1].id)
+	}
+}
+
+func resetForceMergeJobs() {
+	forceMergeJobs.mu.Lock()
+	forceMergeJobs.nextID = 0
+	forceMergeJobs.jobs = nil
+	forceMergeJobs.mu.Unlock()
+}
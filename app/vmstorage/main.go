@@ -44,6 +44,20 @@ var (
 		"If set to 0, then the indexdb rotation is performed at 4am UTC time per each -retentionPeriod. "+
 		"If set to 2h, then the indexdb rotation is performed at 4am EET time (the timezone with +2h offset)")
 
+	partitionGranularity = flag.String("storage.partitionGranularity", storage.PartitionGranularityMonthly, "The time range covered by a single data partition. "+
+		"Supported values: monthly, weekly, daily. Smaller partitions allow dropping data outside -retentionPeriod sooner at the cost of bigger number of partitions. "+
+		"The granularity is stored at -storageDataPath on the first start and cannot be changed afterwards. See https://docs.victoriametrics.com/#partition-granularity")
+
+	minAllowedTimestamp = flag.String("storage.minAllowedTimestamp", "1970-01-02T00:00:00Z", "Samples with timestamps smaller than the given value in RFC3339 format are rejected. "+
+		"This protects from samples with bogus timestamps such as 0, which would create partitions for the distant past. "+
+		"The number of rejected samples is exposed at vm_rows_ignored_total{reason=\"min_timestamp\"} metric. See https://docs.victoriametrics.com/#protection-against-invalid-samples")
+	allowNonFiniteValues = flag.Bool("storage.allowNonFiniteValues", false, "Whether to accept samples with +Inf and -Inf values. "+
+		"By default such samples are rejected and their number is exposed at vm_rows_ignored_total{reason=\"non_finite_value\"} metric. "+
+		"See https://docs.victoriametrics.com/#protection-against-invalid-samples")
+	maxAbsValue = flag.Float64("storage.maxAbsValue", 0, "Sample values with the absolute value exceeding the given limit are clamped to the limit. "+
+		"The number of clamped samples is exposed at vm_rows_clamped_total metric. Values aren't clamped if this flag is set to 0. "+
+		"See https://docs.victoriametrics.com/#protection-against-invalid-samples")
+
 	logNewSeries = flag.Bool("logNewSeries", false, "Whether to log new series. This option is for debug purposes only. It can lead to performance issues "+
 		"when big number of new series are ingested into VictoriaMetrics")
 	denyQueriesOutsideRetention = flag.Bool("denyQueriesOutsideRetention", false, "Whether to deny queries outside of the configured -retentionPeriod. "+
@@ -56,7 +70,27 @@ var (
 		"Excess series are logged and dropped. This can be useful for limiting series churn rate. See https://docs.victoriametrics.com/#cardinality-limiter . "+
 		"See also -storage.maxHourlySeries")
 
-	minFreeDiskSpaceBytes = flagutil.NewBytes("storage.minFreeDiskSpaceBytes", 10e6, "The minimum free disk space at -storageDataPath after which the storage stops accepting new data")
+	trackMetricUsage = flag.Bool("storage.trackMetricUsage", false, "Whether to track per-metric-name ingestion and query stats. "+
+		"The stats are exposed at /api/v1/status/metric_usage . Tracking has some CPU and memory overhead. "+
+		"See https://docs.victoriametrics.com/#track-metric-usage")
+	metricUsageMaxEntries = flag.Int("storage.metricUsageMaxEntries", 100e3, "The maximum number of metric names to track if -storage.trackMetricUsage is set. "+
+		"Metric names with the smallest number of ingested samples are evicted when this limit is reached")
+
+	minFreeDiskSpaceBytes = flagutil.NewBytes("storage.minFreeDiskSpaceBytes", 10e6, "The minimum free disk space at -storageDataPath after which the storage stops accepting new data. "+
+		"See also -storage.minFreeDiskSpaceRecoveryBytes and -storage.readOnlyRecoveryDelay")
+	minFreeDiskSpaceRecoveryBytes = flagutil.NewBytes("storage.minFreeDiskSpaceRecoveryBytes", 0, "The minimum free disk space at -storageDataPath after which the storage "+
+		"switches from read-only mode back to accepting new data. It is recommended setting it to a value bigger than -storage.minFreeDiskSpaceBytes "+
+		"in order to prevent from flapping between read-only and read-write modes when background merges temporarily free disk space. "+
+		"The -storage.minFreeDiskSpaceBytes value is used if it is set to a smaller value")
+	readOnlyRecoveryDelay = flag.Duration("storage.readOnlyRecoveryDelay", 0, "The duration the free disk space at -storageDataPath must stay above "+
+		"-storage.minFreeDiskSpaceRecoveryBytes before the storage switches from read-only mode back to accepting new data")
+
+	partBloomFilter = flag.Bool("storage.partBloomFilter", false, "Whether to build per-part bloom filters for series stored in the part. "+
+		"Bloom filters allow skipping parts without the selected series during queries at the cost of additional memory and disk space. "+
+		"Parts created before enabling the flag are searched as usual until they are merged. See https://docs.victoriametrics.com/#part-bloom-filters")
+	partBloomFilterFalsePositiveRate = flag.Float64("storage.partBloomFilterFalsePositiveRate", 0.01, "The false positive rate for per-part bloom filters "+
+		"enabled via -storage.partBloomFilter. Lower rate allows skipping more parts at the cost of bigger bloom filters. "+
+		"The rate must be in the range (0...1)")
 
 	cacheSizeStorageTSID = flagutil.NewBytes("storage.cacheSizeStorageTSID", 0, "Overrides max size for storage/tsid cache. "+
 		"See https://docs.victoriametrics.com/Single-server-VictoriaMetrics.html#cache-tuning")
@@ -89,15 +123,38 @@ func Init(resetCacheIfNeeded func(mrs []storage.MetricRow)) {
 		logger.Fatalf("invalid `-precisionBits`: %s", err)
 	}
 
+	if err := storage.SetPartitionGranularity(*partitionGranularity); err != nil {
+		logger.Fatalf("invalid `-storage.partitionGranularity`: %s", err)
+	}
+
+	minTimestamp, err := time.Parse(time.RFC3339, *minAllowedTimestamp)
+	if err != nil {
+		logger.Fatalf("invalid `-storage.minAllowedTimestamp`: %s", err)
+	}
+	if *partBloomFilterFalsePositiveRate <= 0 || *partBloomFilterFalsePositiveRate >= 1 {
+		logger.Fatalf("`-storage.partBloomFilterFalsePositiveRate` must be in the range (0...1); got %v", *partBloomFilterFalsePositiveRate)
+	}
+	if *maxAbsValue < 0 {
+		logger.Fatalf("`-storage.maxAbsValue` cannot be negative; got %v", *maxAbsValue)
+	}
+
 	resetResponseCacheIfNeeded = resetCacheIfNeeded
 	storage.SetLogNewSeries(*logNewSeries)
 	storage.SetFinalMergeDelay(*finalMergeDelay)
 	storage.SetBigMergeWorkersCount(*bigMergeConcurrency)
 	storage.SetMergeWorkersCount(*smallMergeConcurrency)
 	storage.SetRetentionTimezoneOffset(*retentionTimezoneOffset)
+	storage.SetMinAllowedTimestamp(minTimestamp.UnixMilli())
+	storage.SetAllowNonFiniteValues(*allowNonFiniteValues)
+	storage.SetMaxAbsValue(*maxAbsValue)
+	storage.SetPartBloomFilter(*partBloomFilter, *partBloomFilterFalsePositiveRate)
 	storage.SetFreeDiskSpaceLimit(minFreeDiskSpaceBytes.N)
+	storage.SetFreeDiskSpaceRecoveryLimit(minFreeDiskSpaceRecoveryBytes.N, *readOnlyRecoveryDelay)
 	storage.SetTSIDCacheSize(cacheSizeStorageTSID.IntN())
 	storage.SetTagFiltersCacheSize(cacheSizeIndexDBTagFilters.IntN())
+	if *trackMetricUsage {
+		storage.SetMetricUsageTrackerMaxEntries(*metricUsageMaxEntries)
+	}
 	mergeset.SetIndexBlocksCacheSize(cacheSizeIndexDBIndexBlocks.IntN())
 	mergeset.SetDataBlocksCacheSize(cacheSizeIndexDBDataBlocks.IntN())
 
@@ -113,6 +170,7 @@ func Init(resetCacheIfNeeded func(mrs []storage.MetricRow)) {
 	}
 	Storage = strg
 	initStaleSnapshotsRemover(strg)
+	initSeriesBudgets(strg)
 
 	var m storage.Metrics
 	strg.UpdateMetrics(&m)
@@ -144,17 +202,25 @@ var resetResponseCacheIfNeeded func(mrs []storage.MetricRow)
 //
 // The caller should limit the number of concurrent calls to AddRows() in order to limit memory usage.
 func AddRows(mrs []storage.MetricRow) error {
+	var ars storage.AddRowsStats
+	return AddRowsWithStats(mrs, &ars)
+}
+
+// AddRowsWithStats adds mrs to the storage and updates ars with the stats for the added and the dropped rows.
+//
+// The caller should limit the number of concurrent calls to AddRowsWithStats() in order to limit memory usage.
+func AddRowsWithStats(mrs []storage.MetricRow, ars *storage.AddRowsStats) error {
 	if Storage.IsReadOnly() {
 		return errReadOnly
 	}
 	resetResponseCacheIfNeeded(mrs)
 	WG.Add(1)
-	err := Storage.AddRows(mrs, uint8(*precisionBits))
+	err := Storage.AddRowsWithStats(mrs, uint8(*precisionBits), ars)
 	WG.Done()
 	return err
 }
 
-var errReadOnly = errors.New("the storage is in read-only mode; check -storage.minFreeDiskSpaceBytes command-line flag value")
+var errReadOnly = errors.New("the storage is in read-only mode; check -storage.minFreeDiskSpaceBytes and -storage.minFreeDiskSpaceRecoveryBytes command-line flag values")
 
 // RegisterMetricNames registers all the metrics from mrs in the storage.
 func RegisterMetricNames(qt *querytracer.Tracer, mrs []storage.MetricRow) error {
@@ -225,6 +291,17 @@ func GetTSDBStatus(qt *querytracer.Tracer, tfss []*storage.TagFilters, date uint
 	return status, err
 }
 
+// GetMetricUsage returns per-metric-name usage stats.
+func GetMetricUsage() ([]storage.MetricUsage, error) {
+	WG.Add(1)
+	mus, ok := Storage.GetMetricUsage()
+	WG.Done()
+	if !ok {
+		return nil, fmt.Errorf("metric usage tracking is disabled; pass -storage.trackMetricUsage command-line flag in order to enable it")
+	}
+	return mus, nil
+}
+
 // GetSeriesCount returns the number of time series in the storage.
 func GetSeriesCount(deadline uint64) (uint64, error) {
 	WG.Add(1)
@@ -233,12 +310,43 @@ func GetSeriesCount(deadline uint64) (uint64, error) {
 	return n, err
 }
 
+// StorageInfo contains storage stats exposed at /api/v1/status/runtimeinfo.
+type StorageInfo struct {
+	// RetentionMsecs is the -retentionPeriod in milliseconds.
+	RetentionMsecs int64
+
+	// ActiveSeries is the number of series, which received samples during the current hour.
+	ActiveSeries uint64
+
+	// RowsCount is the number of samples in the storage including pending samples.
+	RowsCount uint64
+
+	// SizeBytes is the size of the data in the storage excluding indexdb.
+	SizeBytes uint64
+}
+
+// GetStorageInfo returns stats for the storage.
+func GetStorageInfo() *StorageInfo {
+	WG.Add(1)
+	var m storage.Metrics
+	Storage.UpdateMetrics(&m)
+	WG.Done()
+	tm := &m.TableMetrics
+	return &StorageInfo{
+		RetentionMsecs: retentionPeriod.Msecs,
+		ActiveSeries:   m.HourMetricIDCacheSize,
+		RowsCount:      tm.TotalRowsCount(),
+		SizeBytes:      tm.InmemorySizeBytes + tm.SmallSizeBytes + tm.BigSizeBytes,
+	}
+}
+
 // Stop stops the vmstorage
 func Stop() {
 	logger.Infof("gracefully closing the storage at %s", *DataPath)
 	startTime := time.Now()
 	WG.WaitAndBlock()
 	stopStaleSnapshotsRemover()
+	stopSeriesBudgets()
 	Storage.MustClose()
 	logger.Infof("successfully closed the storage in %.3f seconds", time.Since(startTime).Seconds())
 
@@ -252,18 +360,14 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		if !httpserver.CheckAuthFlag(w, r, *forceMergeAuthKey, "forceMergeAuthKey") {
 			return true
 		}
-		// Run force merge in background
-		partitionNamePrefix := r.FormValue("partition_prefix")
-		go func() {
-			activeForceMerges.Inc()
-			defer activeForceMerges.Dec()
-			logger.Infof("forced merge for partition_prefix=%q has been started", partitionNamePrefix)
-			startTime := time.Now()
-			if err := Storage.ForceMergePartitions(partitionNamePrefix); err != nil {
-				logger.Errorf("error in forced merge for partition_prefix=%q: %s", partitionNamePrefix, err)
-			}
-			logger.Infof("forced merge for partition_prefix=%q has been successfully finished in %.3f seconds", partitionNamePrefix, time.Since(startTime).Seconds())
-		}()
+		handleForceMerge(w, r)
+		return true
+	}
+	if path == "/internal/force_merge/status" {
+		if !httpserver.CheckAuthFlag(w, r, *forceMergeAuthKey, "forceMergeAuthKey") {
+			return true
+		}
+		handleForceMergeStatus(w, r)
 		return true
 	}
 	if path == "/internal/force_flush" {
@@ -470,6 +574,18 @@ func registerStorageMetrics(strg *storage.Storage) {
 		}
 		return 0
 	})
+	metrics.NewGauge(fmt.Sprintf(`vm_storage_is_read_only_due_to_free_disk_space{path=%q}`, *DataPath), func() float64 {
+		if strg.IsReadOnlyDueToFreeDiskSpace() {
+			return 1
+		}
+		return 0
+	})
+	metrics.NewGauge(fmt.Sprintf(`vm_free_disk_space_recovery_limit_bytes{path=%q}`, *DataPath), func() float64 {
+		if minFreeDiskSpaceRecoveryBytes.N < minFreeDiskSpaceBytes.N {
+			return float64(minFreeDiskSpaceBytes.N)
+		}
+		return float64(minFreeDiskSpaceRecoveryBytes.N)
+	})
 
 	metrics.NewGauge(`vm_active_merges{type="storage/inmemory"}`, func() float64 {
 		return float64(tm().ActiveInmemoryMerges)
@@ -606,6 +722,14 @@ func registerStorageMetrics(strg *storage.Storage) {
 		return float64(idbm().PendingItems)
 	})
 
+	metrics.NewGauge(`vm_storage_max_possible_data_loss_seconds`, func() float64 {
+		n := tm().MaxPossibleDataLossSeconds
+		if m := idbm().MaxPossibleDataLossSeconds; m > n {
+			n = m
+		}
+		return float64(n)
+	})
+
 	metrics.NewGauge(`vm_parts{type="storage/inmemory"}`, func() float64 {
 		return float64(tm().InmemoryPartsCount)
 	})
@@ -667,6 +791,15 @@ func registerStorageMetrics(strg *storage.Storage) {
 	metrics.NewGauge(`vm_rows_ignored_total{reason="small_timestamp"}`, func() float64 {
 		return float64(m().TooSmallTimestampRows)
 	})
+	metrics.NewGauge(`vm_rows_ignored_total{reason="min_timestamp"}`, func() float64 {
+		return float64(m().BelowMinTimestampRows)
+	})
+	metrics.NewGauge(`vm_rows_ignored_total{reason="non_finite_value"}`, func() float64 {
+		return float64(m().NonFiniteValueRows)
+	})
+	metrics.NewGauge(`vm_rows_clamped_total`, func() float64 {
+		return float64(m().ClampedValueRows)
+	})
 
 	metrics.NewGauge(`vm_slow_row_inserts_total`, func() float64 {
 		return float64(m().SlowRowInserts)
@@ -708,6 +841,12 @@ func registerStorageMetrics(strg *storage.Storage) {
 	metrics.NewGauge(`vm_timestamps_bytes_saved_total`, func() float64 {
 		return float64(m().TimestampsBytesSaved)
 	})
+	metrics.NewGauge(`vm_parts_skipped_by_bloom_filter_total`, func() float64 {
+		return float64(m().PartsSkippedByBloomFilter)
+	})
+	metrics.NewGauge(`vm_part_bloom_filters_size_bytes`, func() float64 {
+		return float64(tm().BloomFiltersSizeBytes)
+	})
 
 	metrics.NewGauge(`vm_rows{type="storage/inmemory"}`, func() float64 {
 		return float64(tm().InmemoryRowsCount)
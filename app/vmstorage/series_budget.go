@@ -0,0 +1,142 @@
+package vmstorage
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	seriesBudgetFile = flag.String("storage.seriesBudgetFile", "", "Optional path to a file with per-metric-name series budgets in the form 'metric_name_pattern: max_series'. "+
+		"New series for metric names matching the pattern are rejected when the number of unique series for the pattern during the last hour exceeds max_series. "+
+		"The path can point either to local file or to http url. The file is reloaded on SIGHUP signal and every -storage.seriesBudgetFileCheckInterval. "+
+		"See https://docs.victoriametrics.com/#series-budgets")
+	seriesBudgetFileCheckInterval = flag.Duration("storage.seriesBudgetFileCheckInterval", time.Minute, "Interval for checking for changes in -storage.seriesBudgetFile. "+
+		"The checking is disabled if it is set to 0")
+)
+
+func initSeriesBudgets(strg *storage.Storage) {
+	if len(*seriesBudgetFile) == 0 {
+		return
+	}
+
+	// Register SIGHUP handler for config re-read just before loadSeriesBudgets call.
+	// This guarantees that the config will be re-read if the signal arrives during loadSeriesBudgets call.
+	sighupCh := procutil.NewSighupChan()
+
+	data, sbs, err := loadSeriesBudgets()
+	if err != nil {
+		logger.Fatalf("cannot load -storage.seriesBudgetFile: %s", err)
+	}
+	if err := strg.SetSeriesBudgets(sbs); err != nil {
+		logger.Fatalf("cannot apply -storage.seriesBudgetFile=%q: %s", *seriesBudgetFile, err)
+	}
+	seriesBudgetConfigSuccess.Set(1)
+	seriesBudgetConfigTimestamp.Set(fasttime.UnixTimestamp())
+
+	seriesBudgetsStopCh = make(chan struct{})
+	seriesBudgetsWG.Add(1)
+	go func() {
+		defer seriesBudgetsWG.Done()
+		var tickerCh <-chan time.Time
+		if *seriesBudgetFileCheckInterval > 0 {
+			ticker := time.NewTicker(*seriesBudgetFileCheckInterval)
+			defer ticker.Stop()
+			tickerCh = ticker.C
+		}
+		for {
+			select {
+			case <-seriesBudgetsStopCh:
+				return
+			case <-sighupCh:
+				logger.Infof("received SIGHUP; reloading -storage.seriesBudgetFile=%q...", *seriesBudgetFile)
+			case <-tickerCh:
+			}
+			dataNew, sbs, err := loadSeriesBudgets()
+			if err == nil && bytes.Equal(data, dataNew) {
+				// Nothing changed since the previous load.
+				continue
+			}
+			seriesBudgetConfigReloads.Inc()
+			if err == nil {
+				err = strg.SetSeriesBudgets(sbs)
+			}
+			if err != nil {
+				seriesBudgetConfigReloadErrors.Inc()
+				seriesBudgetConfigSuccess.Set(0)
+				logger.Errorf("cannot load the updated -storage.seriesBudgetFile: %s; preserving the previous config", err)
+				continue
+			}
+			data = dataNew
+			seriesBudgetConfigSuccess.Set(1)
+			seriesBudgetConfigTimestamp.Set(fasttime.UnixTimestamp())
+			logger.Infof("successfully reloaded -storage.seriesBudgetFile=%q", *seriesBudgetFile)
+		}
+	}()
+}
+
+func stopSeriesBudgets() {
+	if seriesBudgetsStopCh == nil {
+		return
+	}
+	close(seriesBudgetsStopCh)
+	seriesBudgetsWG.Wait()
+	seriesBudgetsStopCh = nil
+}
+
+var (
+	seriesBudgetsStopCh chan struct{}
+	seriesBudgetsWG     sync.WaitGroup
+)
+
+var (
+	seriesBudgetConfigReloads      = metrics.NewCounter(`vm_series_budget_config_reloads_total`)
+	seriesBudgetConfigReloadErrors = metrics.NewCounter(`vm_series_budget_config_reloads_errors_total`)
+	seriesBudgetConfigSuccess      = metrics.NewCounter(`vm_series_budget_config_last_reload_successful`)
+	seriesBudgetConfigTimestamp    = metrics.NewCounter(`vm_series_budget_config_last_reload_success_timestamp_seconds`)
+)
+
+func loadSeriesBudgets() ([]byte, []storage.SeriesBudget, error) {
+	data, err := fs.ReadFileOrHTTP(*seriesBudgetFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read -storage.seriesBudgetFile=%q: %w", *seriesBudgetFile, err)
+	}
+	sbs, err := parseSeriesBudgets(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse -storage.seriesBudgetFile=%q: %w", *seriesBudgetFile, err)
+	}
+	return data, sbs, nil
+}
+
+// parseSeriesBudgets parses series budgets from data in the form `metric_name_pattern: max_series`.
+func parseSeriesBudgets(data []byte) ([]storage.SeriesBudget, error) {
+	var m map[string]int
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, err
+	}
+	sbs := make([]storage.SeriesBudget, 0, len(m))
+	for pattern, maxSeries := range m {
+		if maxSeries <= 0 {
+			return nil, fmt.Errorf("max_series for metric name pattern %q must be positive; got %d", pattern, maxSeries)
+		}
+		sbs = append(sbs, storage.SeriesBudget{
+			MetricNamePattern: pattern,
+			MaxSeries:         maxSeries,
+		})
+	}
+	sort.Slice(sbs, func(i, j int) bool {
+		return sbs[i].MetricNamePattern < sbs[j].MetricNamePattern
+	})
+	return sbs, nil
+}
@@ -0,0 +1,48 @@
+package vmstorage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestParseSeriesBudgetsSuccess(t *testing.T) {
+	f := func(data string, sbsExpected []storage.SeriesBudget) {
+		t.Helper()
+		sbs, err := parseSeriesBudgets([]byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(sbs, sbsExpected) {
+			t.Fatalf("unexpected series budgets;\ngot\n%v\nwant\n%v", sbs, sbsExpected)
+		}
+	}
+	f("", []storage.SeriesBudget{})
+	f(`
+node_.*: 1000
+http_requests_total: 20000
+`, []storage.SeriesBudget{
+		{MetricNamePattern: "http_requests_total", MaxSeries: 20000},
+		{MetricNamePattern: "node_.*", MaxSeries: 1000},
+	})
+}
+
+func TestParseSeriesBudgetsFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		sbs, err := parseSeriesBudgets([]byte(data))
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if sbs != nil {
+			t.Fatalf("expecting nil result; got %v", sbs)
+		}
+	}
+	f("foo")
+	f("foo: bar")
+	f("foo: 0")
+	f("foo: -10")
+	f("- foo")
+	f("foo: 1\nfoo: 2")
+}
@@ -78,3 +78,8 @@ func writePrometheusMetrics(w io.Writer) {
 }
 
 var startTime = time.Now()
+
+// GetStartTime returns the time when the app has been started.
+func GetStartTime() time.Time {
+	return startTime
+}
@@ -1,6 +1,8 @@
 package actions
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"sync/atomic"
@@ -60,38 +62,49 @@ func (b *Backup) Run() error {
 		origin = &fsnil.FS{}
 	}
 
+	// Read hashes for the parts from the previous backup at dst, so they aren't re-calculated.
+	prevHashes := readManifestHashes(dst)
+
 	if err := dst.DeleteFile(fscommon.BackupCompleteFilename); err != nil {
 		return fmt.Errorf("cannot delete `backup complete` file at %s: %w", dst, err)
 	}
-	if err := runBackup(src, dst, origin, concurrency); err != nil {
+	m, err := runBackup(src, dst, origin, concurrency, prevHashes)
+	if err != nil {
 		return err
 	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("cannot marshal backup manifest: %w", err)
+	}
+	if err := dst.CreateFile(fscommon.BackupManifestFilename, data); err != nil {
+		return fmt.Errorf("cannot create backup manifest file at %s: %w", dst, err)
+	}
 	if err := dst.CreateFile(fscommon.BackupCompleteFilename, []byte("ok")); err != nil {
 		return fmt.Errorf("cannot create `backup complete` file at %s: %w", dst, err)
 	}
 	return nil
 }
 
-func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, concurrency int) error {
+func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, concurrency int, prevHashes map[partKey]string) (*common.Manifest, error) {
 	startTime := time.Now()
 
 	logger.Infof("starting backup from %s to %s using origin %s", src, dst, origin)
 
 	srcParts, err := src.ListParts()
 	if err != nil {
-		return fmt.Errorf("cannot list src parts: %w", err)
+		return nil, fmt.Errorf("cannot list src parts: %w", err)
 	}
 	logger.Infof("obtained %d parts from src %s", len(srcParts), src)
 
 	dstParts, err := dst.ListParts()
 	if err != nil {
-		return fmt.Errorf("cannot list dst parts: %w", err)
+		return nil, fmt.Errorf("cannot list dst parts: %w", err)
 	}
 	logger.Infof("obtained %d parts from dst %s", len(dstParts), dst)
 
 	originParts, err := origin.ListParts()
 	if err != nil {
-		return fmt.Errorf("cannot list origin parts: %w", err)
+		return nil, fmt.Errorf("cannot list origin parts: %w", err)
 	}
 	logger.Infof("obtained %d parts from origin %s", len(originParts), origin)
 
@@ -114,10 +127,10 @@ func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, con
 			logger.Infof("deleted %d out of %d parts from dst %s in %s", n, len(partsToDelete), dst, elapsed)
 		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if err := dst.RemoveEmptyDirs(); err != nil {
-			return fmt.Errorf("cannot remove empty directories at dst %s: %w", dst, err)
+			return nil, fmt.Errorf("cannot remove empty directories at dst %s: %w", dst, err)
 		}
 	}
 
@@ -139,10 +152,11 @@ func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, con
 			logger.Infof("server-side copied %d out of %d parts from origin %s to dst %s in %s", n, len(originCopyParts), origin, dst, elapsed)
 		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
+	hashes := newPartHashes(prevHashes)
 	srcCopyParts := common.PartsDifference(partsToCopy, originParts)
 	uploadSize := getPartsSize(srcCopyParts)
 	if len(srcCopyParts) > 0 {
@@ -154,8 +168,9 @@ func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, con
 			if err != nil {
 				return fmt.Errorf("cannot create reader for %s from src %s: %w", &p, src, err)
 			}
+			h := sha256.New()
 			sr := &statReader{
-				r:         rc,
+				r:         io.TeeReader(rc, h),
 				bytesRead: &bytesUploaded,
 			}
 			if err := dst.UploadPart(p, sr); err != nil {
@@ -164,6 +179,9 @@ func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, con
 			if err = rc.Close(); err != nil {
 				return fmt.Errorf("cannot close reader for %s from src %s: %w", &p, src, err)
 			}
+			if sr.n == p.Size {
+				hashes.set(p, h.Sum(nil))
+			}
 			return nil
 		}, func(elapsed time.Duration) {
 			n := atomic.LoadUint64(&bytesUploaded)
@@ -172,23 +190,57 @@ func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, con
 		atomic.AddUint64(&bytesUploadedTotal, bytesUploaded)
 		bytesUploadedTotalMetric.Set(bytesUploadedTotal)
 		if err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	// Calculate hashes for the parts, which weren't uploaded from src during this backup.
+	partsToHash := hashes.missingParts(srcParts)
+	if len(partsToHash) > 0 {
+		logger.Infof("calculating hashes for %d parts from src %s", len(partsToHash), src)
+		hashedParts := uint64(0)
+		err = runParallel(concurrency, partsToHash, func(p common.Part) error {
+			rc, err := src.NewReadCloser(p)
+			if err != nil {
+				return fmt.Errorf("cannot create reader for %s from src %s: %w", &p, src, err)
+			}
+			h := sha256.New()
+			_, err = io.Copy(h, rc)
+			if err1 := rc.Close(); err1 != nil && err == nil {
+				err = err1
+			}
+			if err != nil {
+				return fmt.Errorf("cannot read %s from src %s: %w", &p, src, err)
+			}
+			hashes.set(p, h.Sum(nil))
+			atomic.AddUint64(&hashedParts, 1)
+			return nil
+		}, func(elapsed time.Duration) {
+			n := atomic.LoadUint64(&hashedParts)
+			logger.Infof("calculated hashes for %d out of %d parts from src %s in %s", n, len(partsToHash), src, elapsed)
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	logger.Infof("backup from src %s to dst %s with origin %s is complete; backed up %d bytes in %.3f seconds; deleted %d bytes; server-side copied %d bytes; uploaded %d bytes",
 		src, dst, origin, backupSize, time.Since(startTime).Seconds(), deleteSize, copySize, uploadSize)
 
-	return nil
+	return hashes.newManifest(srcParts), nil
 }
 
 type statReader struct {
 	r         io.Reader
 	bytesRead *uint64
+
+	// n is the number of bytes read from sr.
+	n uint64
 }
 
 func (sr *statReader) Read(p []byte) (int, error) {
 	n, err := sr.r.Read(p)
 	atomic.AddUint64(sr.bytesRead, uint64(n))
+	sr.n += uint64(n)
 	return n, err
 }
@@ -0,0 +1,129 @@
+package actions
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fscommon"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// partKey identifies the part in the backup manifest.
+type partKey struct {
+	path   string
+	offset uint64
+	size   uint64
+}
+
+func newPartKey(p common.Part) partKey {
+	return partKey{
+		path:   p.Path,
+		offset: p.Offset,
+		size:   p.Size,
+	}
+}
+
+// partHashes holds hex-encoded SHA-256 hashes for parts.
+//
+// It is safe calling partHashes methods from concurrently running goroutines.
+type partHashes struct {
+	mu sync.Mutex
+	m  map[partKey]string
+}
+
+func newPartHashes(prevHashes map[partKey]string) *partHashes {
+	m := make(map[partKey]string, len(prevHashes))
+	for k, v := range prevHashes {
+		m[k] = v
+	}
+	return &partHashes{
+		m: m,
+	}
+}
+
+func (ph *partHashes) set(p common.Part, hash []byte) {
+	ph.mu.Lock()
+	ph.m[newPartKey(p)] = hex.EncodeToString(hash)
+	ph.mu.Unlock()
+}
+
+// missingParts returns parts without hashes.
+func (ph *partHashes) missingParts(parts []common.Part) []common.Part {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+
+	var missing []common.Part
+	for _, p := range parts {
+		if ph.m[newPartKey(p)] == "" {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// newManifest returns manifest for the given parts.
+func (ph *partHashes) newManifest(parts []common.Part) *common.Manifest {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+
+	m := &common.Manifest{
+		Parts: make([]common.ManifestPart, 0, len(parts)),
+	}
+	for _, p := range parts {
+		m.Parts = append(m.Parts, common.ManifestPart{
+			Path:     p.Path,
+			FileSize: p.FileSize,
+			Offset:   p.Offset,
+			Size:     p.Size,
+			SHA256:   ph.m[newPartKey(p)],
+		})
+	}
+	return m
+}
+
+// readManifest reads backup manifest from fs.
+//
+// nil is returned if fs doesn't contain the manifest. This is the case for backups made by older vmbackup releases.
+func readManifest(fs common.RemoteFS) (*common.Manifest, error) {
+	ok, err := fs.HasFile(fscommon.BackupManifestFilename)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	data, err := fs.ReadFile(fscommon.BackupManifestFilename)
+	if err != nil {
+		return nil, err
+	}
+	var m common.Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("cannot parse %s at %s: %w", fscommon.BackupManifestFilename, fs, err)
+	}
+	return &m, nil
+}
+
+// readManifestHashes returns part hashes from the manifest at fs.
+//
+// nil is returned if the manifest cannot be read.
+func readManifestHashes(fs common.RemoteFS) map[partKey]string {
+	m, err := readManifest(fs)
+	if err != nil {
+		logger.Warnf("cannot read backup manifest at %s: %s; part hashes are re-calculated", fs, err)
+		return nil
+	}
+	if m == nil {
+		return nil
+	}
+	hashes := make(map[partKey]string, len(m.Parts))
+	for i := range m.Parts {
+		mp := &m.Parts[i]
+		if mp.SHA256 != "" {
+			hashes[newPartKey(mp.Part())] = mp.SHA256
+		}
+	}
+	return hashes
+}
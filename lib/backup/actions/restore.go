@@ -1,10 +1,13 @@
 package actions
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -38,24 +41,44 @@ type Restore struct {
 	//
 	// This may be needed for restoring from old backups with missing `backup complete` file.
 	SkipBackupCompleteCheck bool
+
+	// TimeRange may be set in order to restore only partitions and parts containing samples on the given time range.
+	//
+	// All the data is restored if TimeRange is nil.
+	TimeRange *RestoreTimeRange
+
+	// DryRun may be set in order to log the parts, which would be downloaded from Src, without modifying Dst.
+	DryRun bool
 }
 
 // Run runs r with the provided settings.
 func (r *Restore) Run() error {
 	startTime := time.Now()
 
-	// Make sure VictoriaMetrics doesn't run during the restore process.
-	if err := fs.MkdirAllIfNotExist(r.Dst.Dir); err != nil {
-		return fmt.Errorf("cannot create dir %q: %w", r.Dst.Dir, err)
-	}
-	flockF, err := fs.CreateFlockFile(r.Dst.Dir)
-	if err != nil {
-		return fmt.Errorf("cannot create lock file in %q; make sure VictoriaMetrics doesn't use the dir; error: %w", r.Dst.Dir, err)
-	}
-	defer fs.MustClose(flockF)
+	interrupted := false
+	var stateHashes map[partKey]string
+	if !r.DryRun {
+		// Make sure VictoriaMetrics doesn't run during the restore process.
+		if err := fs.MkdirAllIfNotExist(r.Dst.Dir); err != nil {
+			return fmt.Errorf("cannot create dir %q: %w", r.Dst.Dir, err)
+		}
+		flockF, err := fs.CreateFlockFile(r.Dst.Dir)
+		if err != nil {
+			return fmt.Errorf("cannot create lock file in %q; make sure VictoriaMetrics doesn't use the dir; error: %w", r.Dst.Dir, err)
+		}
+		defer fs.MustClose(flockF)
 
-	if err := createRestoreLock(r.Dst.Dir); err != nil {
-		return err
+		interrupted = isRestoreInterrupted(r.Dst.Dir)
+		if interrupted {
+			stateHashes, err = readRestoreState(r.Dst.Dir)
+			if err != nil {
+				return err
+			}
+			logger.Infof("resuming the interrupted restore to %s; found %d completely restored parts in the restore state", r.Dst, len(stateHashes))
+		}
+		if err := createRestoreLock(r.Dst.Dir); err != nil {
+			return err
+		}
 	}
 	concurrency := r.Concurrency
 	src := r.Src
@@ -116,13 +139,22 @@ func (r *Restore) Run() error {
 		offset += p.Size
 	}
 
+	if r.TimeRange != nil {
+		n := len(srcParts)
+		srcParts = r.TimeRange.filterParts(srcParts)
+		backupSize = getPartsSize(srcParts)
+		logger.Infof("selected %d out of %d parts with %d bytes at %s for the time range %s", len(srcParts), n, backupSize, src, r.TimeRange)
+	}
+
 	partsToDelete := common.PartsDifference(dstParts, srcParts)
+	if r.DryRun {
+		return r.logDryRun(srcParts, dstParts, partsToDelete)
+	}
 	deleteSize := uint64(0)
 	if len(partsToDelete) > 0 {
 		// Remove only files with the missing part at offset 0.
-		// Assume other files are partially downloaded during the previous Restore.Run call,
-		// so only the last part in them may be incomplete.
-		// The last part for partially downloaded files will be re-downloaded later.
+		// Assume other files are partially downloaded during the previous Restore.Run call.
+		// Incomplete parts for such files are either verified or re-downloaded later.
 		// This addresses https://github.com/VictoriaMetrics/VictoriaMetrics/issues/487 .
 		pathsToDelete := make(map[string]bool)
 		for _, p := range partsToDelete {
@@ -150,39 +182,93 @@ func (r *Restore) Run() error {
 		return fmt.Errorf("cannot list dst parts after the deletion: %w", err)
 	}
 
+	// Part hashes from the backup manifest are used for verifying the restored parts.
+	manifestHashes := readManifestHashes(src)
+
 	partsToCopy := common.PartsDifference(srcParts, dstParts)
+	partsPresent := common.PartsIntersect(dstParts, srcParts)
+
+	// Decide which of the parts already present at dst can be skipped.
+	var partsSkipped, partsToVerify []common.Part
+	for _, p := range partsPresent {
+		k := newPartKey(p)
+		manifestHash := manifestHashes[k]
+		if !interrupted {
+			// The parts were restored completely by the previous successful restore.
+			partsSkipped = append(partsSkipped, p)
+			continue
+		}
+		if hash, ok := stateHashes[k]; ok && (manifestHash == "" || hash == "" || hash == manifestHash) {
+			// The part has been completely restored by the interrupted restore.
+			partsSkipped = append(partsSkipped, p)
+			continue
+		}
+		if manifestHash == "" {
+			// The part could be partially written by the interrupted restore and its checksum cannot be verified,
+			// since the backup doesn't contain the manifest. Download it again.
+			partsToCopy = append(partsToCopy, p)
+			continue
+		}
+		partsToVerify = append(partsToVerify, p)
+	}
+	knownHashes := make(map[partKey]string, len(partsSkipped))
+	for _, p := range partsSkipped {
+		k := newPartKey(p)
+		hash := stateHashes[k]
+		if hash == "" {
+			hash = manifestHashes[k]
+		}
+		knownHashes[k] = hash
+	}
+	rs, err := newRestoreState(dst.Dir, partsSkipped, knownHashes)
+	if err != nil {
+		return err
+	}
+	defer rs.mustClose()
+
+	partsVerified := 0
+	if len(partsToVerify) > 0 {
+		logger.Infof("verifying checksums for %d parts with %d bytes left at %s by the interrupted restore", len(partsToVerify), getPartsSize(partsToVerify), dst)
+		partsBroken, err := verifyLocalParts(dst, partsToVerify, manifestHashes, concurrency, rs)
+		if err != nil {
+			return err
+		}
+		partsVerified = len(partsToVerify) - len(partsBroken)
+		partsToCopy = append(partsToCopy, partsBroken...)
+		if len(partsBroken) > 0 {
+			logger.Infof("found %d parts with checksum mismatch at %s; they will be downloaded again", len(partsBroken), dst)
+		}
+	}
+
 	downloadSize := getPartsSize(partsToCopy)
 	if len(partsToCopy) > 0 {
-		perPath := make(map[string][]common.Part)
-		for _, p := range partsToCopy {
-			parts := perPath[p.Path]
-			parts = append(parts, p)
-			perPath[p.Path] = parts
-		}
+		// Sort partsToCopy in order to download files sequentially when possible.
+		common.SortParts(partsToCopy)
 		logger.Infof("downloading %d parts from %s to %s", len(partsToCopy), src, dst)
 		bytesDownloaded := uint64(0)
-		err = runParallelPerPath(concurrency, perPath, func(parts []common.Part) error {
-			// Sort partsToCopy in order to properly grow file size during downloading
-			// and to properly resume downloading of incomplete files on the next Restore.Run call.
-			common.SortParts(parts)
-			for _, p := range parts {
-				logger.Infof("downloading %s from %s to %s", &p, src, dst)
-				wc, err := dst.NewWriteCloser(p)
-				if err != nil {
-					return fmt.Errorf("cannot create writer for %q to %s: %w", &p, dst, err)
-				}
-				sw := &statWriter{
-					w:            wc,
-					bytesWritten: &bytesDownloaded,
-				}
-				if err := src.DownloadPart(p, sw); err != nil {
-					return fmt.Errorf("cannot download %s to %s: %w", &p, dst, err)
-				}
-				if err := wc.Close(); err != nil {
-					return fmt.Errorf("cannot close reader from %s from %s: %w", &p, src, err)
-				}
+		err = runParallel(concurrency, partsToCopy, func(p common.Part) error {
+			logger.Infof("downloading %s from %s to %s", &p, src, dst)
+			wc, err := dst.NewWriteCloser(p)
+			if err != nil {
+				return fmt.Errorf("cannot create writer for %q to %s: %w", &p, dst, err)
 			}
-			return nil
+			h := sha256.New()
+			sw := &statWriter{
+				w:            io.MultiWriter(wc, h),
+				bytesWritten: &bytesDownloaded,
+			}
+			if err := src.DownloadPart(p, sw); err != nil {
+				return fmt.Errorf("cannot download %s to %s: %w", &p, dst, err)
+			}
+			if err := wc.Close(); err != nil {
+				return fmt.Errorf("cannot close reader from %s from %s: %w", &p, src, err)
+			}
+			hash := hex.EncodeToString(h.Sum(nil))
+			if manifestHash := manifestHashes[newPartKey(p)]; manifestHash != "" && hash != manifestHash {
+				return fmt.Errorf("checksum mismatch for %s downloaded from %s; got sha256 %s; want sha256 %s; verify the backup with `vmbackup -verify`",
+					&p, src, hash, manifestHash)
+			}
+			return rs.add(p, hash)
 		}, func(elapsed time.Duration) {
 			n := atomic.LoadUint64(&bytesDownloaded)
 			logger.Infof("downloaded %d out of %d bytes from %s to %s in %s", n, downloadSize, src, dst, elapsed)
@@ -192,12 +278,96 @@ func (r *Restore) Run() error {
 		}
 	}
 
-	logger.Infof("restored %d bytes from backup in %.3f seconds; deleted %d bytes; downloaded %d bytes",
-		backupSize, time.Since(startTime).Seconds(), deleteSize, downloadSize)
+	logger.Infof("restored %d bytes from backup in %.3f seconds; deleted %d bytes; skipped %d parts with %d bytes already present at %s "+
+		"(%d of them verified via checksums); downloaded %d parts with %d bytes",
+		backupSize, time.Since(startTime).Seconds(), deleteSize, len(partsSkipped)+partsVerified, backupSize-downloadSize, dst,
+		partsVerified, len(partsToCopy), downloadSize)
 
+	rs.mustClose()
+	if err := removeRestoreState(r.Dst.Dir); err != nil {
+		return err
+	}
 	return removeRestoreLock(r.Dst.Dir)
 }
 
+// verifyLocalParts verifies checksums for the given parts at dst against hashes and records verified parts to rs.
+//
+// It returns parts with checksum mismatch. Such parts must be downloaded again.
+func verifyLocalParts(dst *fslocal.FS, parts []common.Part, hashes map[partKey]string, concurrency int, rs *restoreState) ([]common.Part, error) {
+	var partsBroken []common.Part
+	var mu sync.Mutex
+	bytesVerified := uint64(0)
+	verifySize := getPartsSize(parts)
+	err := runParallel(concurrency, parts, func(p common.Part) error {
+		hash, err := getLocalPartHash(dst, p, &bytesVerified)
+		if err != nil {
+			logger.Warnf("cannot verify %s at %s: %s; the part will be downloaded again", &p, dst, err)
+		}
+		if err != nil || hash != hashes[newPartKey(p)] {
+			mu.Lock()
+			partsBroken = append(partsBroken, p)
+			mu.Unlock()
+			return nil
+		}
+		return rs.add(p, hash)
+	}, func(elapsed time.Duration) {
+		n := atomic.LoadUint64(&bytesVerified)
+		logger.Infof("verified %d out of %d bytes at %s in %s", n, verifySize, dst, elapsed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return partsBroken, nil
+}
+
+func getLocalPartHash(dst *fslocal.FS, p common.Part, bytesRead *uint64) (string, error) {
+	rc, err := dst.NewReadCloser(p)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	sw := &statWriter{
+		w:            h,
+		bytesWritten: bytesRead,
+	}
+	n, err := io.Copy(sw, rc)
+	if err1 := rc.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		return "", err
+	}
+	if uint64(n) != p.Size {
+		return "", fmt.Errorf("unexpected part size; got %d bytes; want %d bytes", n, p.Size)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// logDryRun logs the parts, which would be downloaded from r.Src to r.Dst by r.Run.
+func (r *Restore) logDryRun(srcParts, dstParts, partsToDelete []common.Part) error {
+	// Files with the missing part at offset 0 are deleted and then fully downloaded by Run.
+	pathsToDelete := make(map[string]bool)
+	for _, p := range partsToDelete {
+		if p.Offset == 0 {
+			pathsToDelete[p.Path] = true
+		}
+	}
+	var dstPartsRemaining []common.Part
+	for _, p := range dstParts {
+		if !pathsToDelete[p.Path] {
+			dstPartsRemaining = append(dstPartsRemaining, p)
+		}
+	}
+	partsToCopy := common.PartsDifference(srcParts, dstPartsRemaining)
+	common.SortParts(partsToCopy)
+	for _, p := range partsToCopy {
+		logger.Infof("dry run: would download %s from %s to %s", &p, r.Src, r.Dst)
+	}
+	logger.Infof("dry run: would delete %d files from %s and download %d parts with %d bytes from %s",
+		len(pathsToDelete), r.Dst, len(partsToCopy), getPartsSize(partsToCopy), r.Src)
+	return nil
+}
+
 type statWriter struct {
 	w            io.Writer
 	bytesWritten *uint64
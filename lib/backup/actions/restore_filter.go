@@ -0,0 +1,122 @@
+package actions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/partitionname"
+)
+
+// RestoreTimeRange limits the restored data to partitions and parts containing samples on the given time range.
+type RestoreTimeRange struct {
+	// MinTimestamp is the minimum timestamp in milliseconds for the restored data.
+	MinTimestamp int64
+
+	// MaxTimestamp is the maximum timestamp in milliseconds for the restored data.
+	MaxTimestamp int64
+}
+
+// String returns human-readable representation of tr.
+func (tr *RestoreTimeRange) String() string {
+	start := time.UnixMilli(tr.MinTimestamp).UTC().Format(time.RFC3339)
+	end := time.UnixMilli(tr.MaxTimestamp).UTC().Format(time.RFC3339)
+	return fmt.Sprintf("[%s..%s]", start, end)
+}
+
+// ParseRestoreTimeRange parses time range from s in the form `start,end`.
+//
+// start and end may be specified either as RFC3339 timestamps, as YYYY-MM-DD dates or as unix timestamps in seconds.
+// Empty start or end means unbounded time range from the corresponding side.
+func ParseRestoreTimeRange(s string) (*RestoreTimeRange, error) {
+	n := strings.IndexByte(s, ',')
+	if n < 0 {
+		return nil, fmt.Errorf("missing `,` between start and end in %q", s)
+	}
+	startStr, endStr := s[:n], s[n+1:]
+	tr := &RestoreTimeRange{
+		MinTimestamp: 0,
+		MaxTimestamp: 1<<63 - 1,
+	}
+	if len(startStr) > 0 {
+		start, err := parseRestoreTime(startStr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse start %q: %w", startStr, err)
+		}
+		tr.MinTimestamp = start
+	}
+	if len(endStr) > 0 {
+		end, err := parseRestoreTime(endStr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse end %q: %w", endStr, err)
+		}
+		tr.MaxTimestamp = end
+	}
+	if tr.MinTimestamp > tr.MaxTimestamp {
+		return nil, fmt.Errorf("start cannot exceed end in %q", s)
+	}
+	return tr, nil
+}
+
+func parseRestoreTime(s string) (int64, error) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return secs * 1000, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UnixMilli(), nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported time format; supported formats: RFC3339, YYYY-MM-DD and unix timestamp in seconds")
+	}
+	return t.UnixMilli(), nil
+}
+
+// filterParts returns parts, which belong to partitions with samples on tr.
+//
+// Only files under data/small/<partition> and data/big/<partition> dirs are filtered out.
+// The remaining files such as indexdb are always returned, since they are needed for a valid -storageDataPath.
+//
+// Partitions are restored as a whole, since every partition contains parts.json file with the list of its parts,
+// and VictoriaMetrics refuses to open a partition with missing parts.
+func (tr *RestoreTimeRange) filterParts(parts []common.Part) []common.Part {
+	var dst []common.Part
+	for _, p := range parts {
+		if tr.matchPath(p.Path) {
+			dst = append(dst, p)
+		}
+	}
+	return dst
+}
+
+func (tr *RestoreTimeRange) matchPath(path string) bool {
+	// The path must have the following form: data/{small,big}/partitionName/...
+	a := strings.Split(path, "/")
+	if len(a) < 3 || a[0] != "data" || (a[1] != "small" && a[1] != "big") {
+		return true
+	}
+	partitionMin, partitionMax, ok := parsePartitionNameTimestamps(a[2])
+	if !ok {
+		// Unknown partition name. Restore it in order to be on the safe side.
+		return true
+	}
+	return partitionMin <= tr.MaxTimestamp && partitionMax >= tr.MinTimestamp
+}
+
+// parsePartitionNameTimestamps returns min and max timestamps for the partition with the given name.
+//
+// The name may have any of the forms supported by partitionname.Format,
+// since the partition granularity used when creating the backup is unknown.
+func parsePartitionNameTimestamps(name string) (int64, int64, bool) {
+	for _, granularity := range []string{partitionname.Monthly, partitionname.Weekly, partitionname.Daily} {
+		t, err := partitionname.Parse(name, granularity)
+		if err != nil {
+			continue
+		}
+		minTime, maxTime := partitionname.TimeRange(t, granularity)
+		return minTime.UnixMilli(), maxTime.UnixMilli() - 1, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,122 @@
+package actions
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+)
+
+func TestParseRestoreTimeRangeSuccess(t *testing.T) {
+	f := func(s string, minTimestampExpected, maxTimestampExpected int64) {
+		t.Helper()
+		tr, err := ParseRestoreTimeRange(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if tr.MinTimestamp != minTimestampExpected {
+			t.Fatalf("unexpected MinTimestamp; got %d; want %d", tr.MinTimestamp, minTimestampExpected)
+		}
+		if tr.MaxTimestamp != maxTimestampExpected {
+			t.Fatalf("unexpected MaxTimestamp; got %d; want %d", tr.MaxTimestamp, maxTimestampExpected)
+		}
+	}
+	f(",", 0, 1<<63-1)
+	f("1650000000,", 1650000000000, 1<<63-1)
+	f(",1650000000", 0, 1650000000000)
+	f("2022-04-01,2022-05-01T10:20:30Z", 1648771200000, 1651400430000)
+}
+
+func TestParseRestoreTimeRangeFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		tr, err := ParseRestoreTimeRange(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if tr != nil {
+			t.Fatalf("expecting nil tr; got %v", tr)
+		}
+	}
+	f("")
+	f("2022-04-01")
+	f("foo,")
+	f(",bar")
+	f("2022-05-01,2022-04-01")
+}
+
+func TestRestoreTimeRangeFilterParts(t *testing.T) {
+	f := func(paths []string, pathsExpected []string) {
+		t.Helper()
+		var parts []common.Part
+		for _, path := range paths {
+			parts = append(parts, common.Part{
+				Path: path,
+			})
+		}
+		start := time.Date(2022, 4, 10, 0, 0, 0, 0, time.UTC)
+		end := time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC)
+		tr := &RestoreTimeRange{
+			MinTimestamp: start.UnixMilli(),
+			MaxTimestamp: end.UnixMilli(),
+		}
+		var result []string
+		for _, p := range tr.filterParts(parts) {
+			result = append(result, p.Path)
+		}
+		if !reflect.DeepEqual(result, pathsExpected) {
+			t.Fatalf("unexpected paths;\ngot\n%q\nwant\n%q", result, pathsExpected)
+		}
+	}
+
+	// Non-data files must be always restored
+	f([]string{
+		"indexdb/16F0F2DCE4A5BB9D/123_4_16F0F2DCE4A5BBAA/items.bin",
+		"metadata/minTimestampForCompositeIndex",
+	}, []string{
+		"indexdb/16F0F2DCE4A5BB9D/123_4_16F0F2DCE4A5BBAA/items.bin",
+		"metadata/minTimestampForCompositeIndex",
+	})
+
+	// Filter by partition name
+	f([]string{
+		"data/small/2022_03/123_4_16F0F2DCE4A5BBAA/values.bin",
+		"data/small/2022_04/123_4_16F0F2DCE4A5BBAA/values.bin",
+		"data/big/2022_05/123_4_16F0F2DCE4A5BBAA/values.bin",
+		"data/big/2022_06/123_4_16F0F2DCE4A5BBAA/values.bin",
+		"data/big/foobar/123_4_16F0F2DCE4A5BBAA/values.bin",
+	}, []string{
+		"data/small/2022_04/123_4_16F0F2DCE4A5BBAA/values.bin",
+		"data/big/2022_05/123_4_16F0F2DCE4A5BBAA/values.bin",
+		"data/big/foobar/123_4_16F0F2DCE4A5BBAA/values.bin",
+	})
+
+	// Filter by weekly and daily partition names
+	f([]string{
+		"data/small/2022_w13/17A6D3E45D9F1E61/values.bin",
+		"data/small/2022_w14/17A6D3E45D9F1E62/values.bin",
+		"data/small/2022_w19/17A6D3E45D9F1E63/values.bin",
+		"data/small/2022_w20/17A6D3E45D9F1E64/values.bin",
+		"data/big/2022_04_09/17A6D3E45D9F1E65/values.bin",
+		"data/big/2022_04_10/17A6D3E45D9F1E66/values.bin",
+		"data/big/2022_05_10/17A6D3E45D9F1E67/values.bin",
+		"data/big/2022_05_11/17A6D3E45D9F1E68/values.bin",
+	}, []string{
+		"data/small/2022_w14/17A6D3E45D9F1E62/values.bin",
+		"data/small/2022_w19/17A6D3E45D9F1E63/values.bin",
+		"data/big/2022_04_10/17A6D3E45D9F1E66/values.bin",
+		"data/big/2022_05_10/17A6D3E45D9F1E67/values.bin",
+	})
+
+	// Partitions must be restored as a whole including parts.json and parts outside the time range
+	f([]string{
+		"data/small/2022_04/parts.json",
+		"data/small/2022_04/10_2_20220401000000.000_20220409235959.999_16F0F2DCE4A5BBAA/values.bin",
+		"data/small/2022_04/17A6D3E45D9F1E61/values.bin",
+	}, []string{
+		"data/small/2022_04/parts.json",
+		"data/small/2022_04/10_2_20220401000000.000_20220409235959.999_16F0F2DCE4A5BBAA/values.bin",
+		"data/small/2022_04/17A6D3E45D9F1E61/values.bin",
+	})
+}
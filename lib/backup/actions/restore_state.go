@@ -0,0 +1,147 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/backupnames"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// restoreStatePart is a single line in the restore state file.
+type restoreStatePart struct {
+	Path   string `json:"path"`
+	Offset uint64 `json:"offset"`
+	Size   uint64 `json:"size"`
+
+	// SHA256 is hex-encoded SHA-256 hash of the restored part contents.
+	//
+	// It may be empty if the hash is unknown.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// restoreState tracks parts, which were completely restored to the local dir.
+//
+// The state is stored in backupnames.RestoreStateFilename file at the restore destination dir, one JSON line per part,
+// so the interrupted restore can be resumed without downloading the already restored parts again.
+//
+// It is safe calling restoreState methods from concurrently running goroutines.
+type restoreState struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// readRestoreState reads hashes for the restored parts from the restore state file at dstDir.
+//
+// nil is returned if the state file is missing. The value for the part may be empty if its hash is unknown.
+func readRestoreState(dstDir string) (map[partKey]string, error) {
+	path := filepath.Join(dstDir, backupnames.RestoreStateFilename)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read restore state: %w", err)
+	}
+	hashes := make(map[partKey]string)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var sp restoreStatePart
+		if err := json.Unmarshal(line, &sp); err != nil {
+			// The last line may be incomplete if the previous restore has been interrupted.
+			// Ignore it, since the corresponding part will be verified or downloaded again.
+			continue
+		}
+		k := partKey{
+			path:   sp.Path,
+			offset: sp.Offset,
+			size:   sp.Size,
+		}
+		hashes[k] = sp.SHA256
+	}
+	return hashes, nil
+}
+
+// newRestoreState creates the restore state file at dstDir with the given parts and their hashes.
+//
+// The previous state file is overwritten.
+func newRestoreState(dstDir string, parts []common.Part, hashes map[partKey]string) (*restoreState, error) {
+	var bb bytes.Buffer
+	for _, p := range parts {
+		bb.Write(marshalRestoreStatePart(p, hashes[newPartKey(p)]))
+	}
+	path := filepath.Join(dstDir, backupnames.RestoreStateFilename)
+	if err := fs.WriteFileAtomically(path, bb.Bytes(), true); err != nil {
+		return nil, fmt.Errorf("cannot create restore state: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open restore state: %w", err)
+	}
+	return &restoreState{
+		f: f,
+	}, nil
+}
+
+// add records the restored part p with the given hash to rs.
+func (rs *restoreState) add(p common.Part, hash string) error {
+	line := marshalRestoreStatePart(p, hash)
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if _, err := rs.f.Write(line); err != nil {
+		return fmt.Errorf("cannot write to restore state file %q: %w", rs.f.Name(), err)
+	}
+	return nil
+}
+
+// mustClose closes rs.
+//
+// It is safe calling mustClose multiple times.
+func (rs *restoreState) mustClose() {
+	rs.mu.Lock()
+	if rs.f != nil {
+		fs.MustClose(rs.f)
+		rs.f = nil
+	}
+	rs.mu.Unlock()
+}
+
+func marshalRestoreStatePart(p common.Part, hash string) []byte {
+	sp := restoreStatePart{
+		Path:   p.Path,
+		Offset: p.Offset,
+		Size:   p.Size,
+		SHA256: hash,
+	}
+	data, err := json.Marshal(&sp)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal restore state part: %s", err)
+	}
+	return append(data, '\n')
+}
+
+func removeRestoreState(dstDir string) error {
+	path := filepath.Join(dstDir, backupnames.RestoreStateFilename)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove restore state file %q: %w", path, err)
+	}
+	return nil
+}
+
+// isRestoreInterrupted returns true if the previous restore to dstDir has been interrupted.
+func isRestoreInterrupted(dstDir string) bool {
+	for _, name := range []string{backupnames.RestoreInProgressFilename, backupnames.RestoreStateFilename} {
+		if fs.IsPathExist(filepath.Join(dstDir, name)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/backupnames"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fslocal"
+)
+
+func TestRestoreResume(t *testing.T) {
+	src, parts := newTestBackup(t)
+	dstDir := t.TempDir()
+	restore := func() {
+		t.Helper()
+		r := &Restore{
+			Concurrency: 2,
+			Src:         src,
+			Dst: &fslocal.FS{
+				Dir: dstDir,
+			},
+		}
+		if err := r.Run(); err != nil {
+			t.Fatalf("cannot restore from backup: %s", err)
+		}
+		for _, name := range []string{backupnames.RestoreInProgressFilename, backupnames.RestoreStateFilename} {
+			if _, err := os.Stat(filepath.Join(dstDir, name)); !os.IsNotExist(err) {
+				t.Fatalf("%s file must be removed after the successful restore", name)
+			}
+		}
+	}
+	checkFile := func(path, dataExpected string) {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dstDir, path))
+		if err != nil {
+			t.Fatalf("cannot read restored file: %s", err)
+		}
+		if string(data) != dataExpected {
+			t.Fatalf("unexpected contents for %q; got %q; want %q", path, data, dataExpected)
+		}
+	}
+
+	restore()
+	checkFile("data/small/part1/values.bin", "foobarbaz")
+	checkFile("data/small/part1/timestamps.bin", "1234567890")
+	checkFile("indexdb/table/part2/items.bin", "abc")
+
+	// Simulate the interrupted restore, which has completely restored only part2/items.bin,
+	// while part1/values.bin has been written with garbage of the same size.
+	if err := os.WriteFile(filepath.Join(dstDir, "data/small/part1/values.bin"), make([]byte, len("foobarbaz")), 0644); err != nil {
+		t.Fatalf("cannot write file: %s", err)
+	}
+	if err := createRestoreLock(dstDir); err != nil {
+		t.Fatalf("cannot create restore lock: %s", err)
+	}
+	common.SortParts(parts)
+	var restoredParts []common.Part
+	for _, p := range parts {
+		if p.Path == "indexdb/table/part2/items.bin" {
+			restoredParts = append(restoredParts, p)
+		}
+	}
+	rs, err := newRestoreState(dstDir, restoredParts, nil)
+	if err != nil {
+		t.Fatalf("cannot create restore state: %s", err)
+	}
+	rs.mustClose()
+
+	restore()
+	checkFile("data/small/part1/values.bin", "foobarbaz")
+	checkFile("data/small/part1/timestamps.bin", "1234567890")
+	checkFile("indexdb/table/part2/items.bin", "abc")
+}
+
+func TestRestoreState(t *testing.T) {
+	dir := t.TempDir()
+	hashes, err := readRestoreState(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hashes != nil {
+		t.Fatalf("expecting nil hashes for missing restore state; got %v", hashes)
+	}
+	if isRestoreInterrupted(dir) {
+		t.Fatalf("the restore mustn't be interrupted without the restore state")
+	}
+
+	p1 := common.Part{
+		Path:   "foo",
+		Offset: 0,
+		Size:   10,
+	}
+	p2 := common.Part{
+		Path:   "bar",
+		Offset: 100,
+		Size:   20,
+	}
+	rs, err := newRestoreState(dir, []common.Part{p1}, map[partKey]string{
+		newPartKey(p1): "hash1",
+	})
+	if err != nil {
+		t.Fatalf("cannot create restore state: %s", err)
+	}
+	if err := rs.add(p2, ""); err != nil {
+		t.Fatalf("cannot add part to restore state: %s", err)
+	}
+	rs.mustClose()
+
+	// Simulate incomplete last line after the interrupted restore.
+	path := filepath.Join(dir, backupnames.RestoreStateFilename)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("cannot open restore state: %s", err)
+	}
+	if _, err := f.WriteString(`{"path":"baz","off`); err != nil {
+		t.Fatalf("cannot write to restore state: %s", err)
+	}
+	_ = f.Close()
+
+	if !isRestoreInterrupted(dir) {
+		t.Fatalf("the restore must be interrupted if the restore state exists")
+	}
+	hashes, err = readRestoreState(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(hashes) != 2 {
+		t.Fatalf("unexpected number of parts in restore state; got %d; want 2", len(hashes))
+	}
+	if h, ok := hashes[newPartKey(p1)]; !ok || h != "hash1" {
+		t.Fatalf("unexpected hash for %s; got %q; want %q", &p1, h, "hash1")
+	}
+	if h, ok := hashes[newPartKey(p2)]; !ok || h != "" {
+		t.Fatalf("unexpected hash for %s; got %q; want empty hash", &p2, h)
+	}
+
+	if err := removeRestoreState(dir); err != nil {
+		t.Fatalf("cannot remove restore state: %s", err)
+	}
+	if isRestoreInterrupted(dir) {
+		t.Fatalf("the restore mustn't be interrupted after the restore state removal")
+	}
+}
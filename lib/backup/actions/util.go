@@ -3,6 +3,8 @@ package actions
 import (
 	"flag"
 	"fmt"
+	"net/url"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -12,6 +14,8 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsremote"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/gcsremote"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/s3remote"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/sftpremote"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/webdavremote"
 )
 
 var (
@@ -23,6 +27,14 @@ var (
 		"or if both not set, DefaultSharedConfigProfile is used")
 	customS3Endpoint = flag.String("customS3Endpoint", "", "Custom S3 endpoint for use with S3-compatible storages (e.g. MinIO). S3 is used if not set")
 	s3ForcePathStyle = flag.Bool("s3ForcePathStyle", true, "Prefixing endpoint with bucket name when set false, true by default.")
+
+	sftpPasswordFile          = flag.String("sftp.passwordFile", "", "Optional path to file with password for authentication at SFTP server for `sftp://` paths")
+	sftpPrivateKeyFile        = flag.String("sftp.privateKeyFile", "", "Optional path to file with private key for authentication at SFTP server for `sftp://` paths")
+	sftpKnownHostsFile        = flag.String("sftp.knownHostsFile", "", "Path to known_hosts file for verifying SFTP server host key for `sftp://` paths. ~/.ssh/known_hosts is used if not set")
+	sftpInsecureIgnoreHostKey = flag.Bool("sftp.insecureIgnoreHostKey", false, "Whether to skip verification of SFTP server host key for `sftp://` paths. "+
+		"This is insecure, since it allows man-in-the-middle attacks")
+	webdavPasswordFile = flag.String("webdav.passwordFile", "", "Optional path to file with password for basic auth at WebDAV server for `webdav://` and `webdavs://` paths. "+
+		"It overrides the password set in the path")
 )
 
 func runParallel(concurrency int, parts []common.Part, f func(p common.Part) error, progress func(elapsed time.Duration)) error {
@@ -33,67 +45,6 @@ func runParallel(concurrency int, parts []common.Part, f func(p common.Part) err
 	return err
 }
 
-func runParallelPerPath(concurrency int, perPath map[string][]common.Part, f func(parts []common.Part) error, progress func(elapsed time.Duration)) error {
-	var err error
-	runWithProgress(progress, func() {
-		err = runParallelPerPathInternal(concurrency, perPath, f)
-	})
-	return err
-}
-
-func runParallelPerPathInternal(concurrency int, perPath map[string][]common.Part, f func(parts []common.Part) error) error {
-	if concurrency <= 0 {
-		concurrency = 1
-	}
-	if len(perPath) == 0 {
-		return nil
-	}
-
-	// len(perPath) capacity guarantees non-blocking behavior below.
-	resultCh := make(chan error, len(perPath))
-	workCh := make(chan []common.Part, len(perPath))
-	stopCh := make(chan struct{})
-
-	// Start workers
-	var wg sync.WaitGroup
-	wg.Add(concurrency)
-	for i := 0; i < concurrency; i++ {
-		go func() {
-			defer wg.Done()
-			for parts := range workCh {
-				select {
-				case <-stopCh:
-					return
-				default:
-				}
-				resultCh <- f(parts)
-			}
-		}()
-	}
-
-	// Feed workers with work.
-	for _, parts := range perPath {
-		workCh <- parts
-	}
-	close(workCh)
-
-	// Read results.
-	var err error
-	for i := 0; i < len(perPath); i++ {
-		err = <-resultCh
-		if err != nil {
-			// Stop the work.
-			close(stopCh)
-			break
-		}
-	}
-
-	// Wait for all the workers to stop.
-	wg.Wait()
-
-	return err
-}
-
 func runParallelInternal(concurrency int, parts []common.Part, f func(p common.Part) error) error {
 	if concurrency <= 0 {
 		concurrency = 1
@@ -184,7 +135,7 @@ func NewRemoteFS(path string) (common.RemoteFS, error) {
 	}
 	n := strings.Index(path, "://")
 	if n < 0 {
-		return nil, fmt.Errorf("Missing scheme in path %q. Supported schemes: `gs://`, `s3://`, `azblob://`, `fs://`", path)
+		return nil, fmt.Errorf("Missing scheme in path %q. Supported schemes: `gs://`, `s3://`, `azblob://`, `fs://`, `sftp://`, `webdav://`, `webdavs://`", path)
 	}
 	scheme := path[:n]
 	dir := path[n+len("://"):]
@@ -248,7 +199,72 @@ func NewRemoteFS(path string) (common.RemoteFS, error) {
 			return nil, fmt.Errorf("cannot initialize connection to s3: %w", err)
 		}
 		return fs, nil
+	case "sftp":
+		n := strings.Index(dir, "/")
+		if n < 0 {
+			return nil, fmt.Errorf("missing directory on the sftp server %q", dir)
+		}
+		user, addr, err := sftpremote.ParseAddr(dir[:n])
+		if err != nil {
+			return nil, err
+		}
+		password, err := readPasswordFile(*sftpPasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		fs := &sftpremote.FS{
+			Addr:                  addr,
+			User:                  user,
+			Dir:                   dir[n:],
+			Password:              password,
+			PrivateKeyFile:        *sftpPrivateKeyFile,
+			KnownHostsFile:        *sftpKnownHostsFile,
+			InsecureIgnoreHostKey: *sftpInsecureIgnoreHostKey,
+		}
+		if err := fs.Init(); err != nil {
+			return nil, fmt.Errorf("cannot initialize connection to sftp: %w", err)
+		}
+		return fs, nil
+	case "webdav", "webdavs":
+		u, err := url.Parse(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+		}
+		fs := &webdavremote.FS{
+			Dir: u.Path,
+		}
+		if u.User != nil {
+			fs.Username = u.User.Username()
+			fs.Password, _ = u.User.Password()
+		}
+		if *webdavPasswordFile != "" {
+			password, err := readPasswordFile(*webdavPasswordFile)
+			if err != nil {
+				return nil, err
+			}
+			fs.Password = password
+		}
+		httpScheme := "http"
+		if scheme == "webdavs" {
+			httpScheme = "https"
+		}
+		fs.BaseURL = httpScheme + "://" + u.Host
+		if err := fs.Init(); err != nil {
+			return nil, fmt.Errorf("cannot initialize connection to webdav: %w", err)
+		}
+		return fs, nil
 	default:
 		return nil, fmt.Errorf("unsupported scheme %q", scheme)
 	}
 }
+
+func readPasswordFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read password file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,219 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fscommon"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// Verify verifies backup integrity according to the provided settings.
+type Verify struct {
+	// Concurrency is the number of concurrent workers during the verification.
+	// Concurrency=1 by default.
+	Concurrency int
+
+	// Src is the backup to verify.
+	Src common.RemoteFS
+
+	// SampleRatio is the share of parts in the range [0..1], which must be downloaded for verifying their checksums.
+	//
+	// Checksums aren't verified if SampleRatio is 0.
+	SampleRatio float64
+}
+
+// Run runs v with the provided settings.
+//
+// It returns non-nil error if the backup is incomplete or contains missing or corrupted parts.
+func (v *Verify) Run() error {
+	if v.SampleRatio < 0 || v.SampleRatio > 1 {
+		return fmt.Errorf("SampleRatio must be in the range [0..1]; got %v", v.SampleRatio)
+	}
+	startTime := time.Now()
+	src := v.Src
+	logger.Infof("starting verification of the backup at %s", src)
+
+	ok, err := src.HasFile(fscommon.BackupCompleteFilename)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("cannot find %s file in %s; this means either incomplete backup or old backup", fscommon.BackupCompleteFilename, src)
+	}
+	m, err := readManifest(src)
+	if err != nil {
+		return fmt.Errorf("cannot read backup manifest: %w", err)
+	}
+	parts, err := src.ListParts()
+	if err != nil {
+		return fmt.Errorf("cannot list parts at %s: %w", src, err)
+	}
+	logger.Infof("obtained %d parts from %s", len(parts), src)
+
+	var vs verifyStats
+	vs.checkPartSizes(parts)
+	vs.checkFileCoverage(parts)
+
+	var partsToCheck []common.ManifestPart
+	if m == nil {
+		logger.Warnf("cannot find %s file in %s; this means the backup is made by older vmbackup; checksums cannot be verified for this backup",
+			fscommon.BackupManifestFilename, src)
+	} else {
+		partsToCheck = vs.checkManifest(m, parts)
+	}
+
+	if v.SampleRatio > 0 && len(partsToCheck) > 0 {
+		n := int(math.Ceil(float64(len(partsToCheck)) * v.SampleRatio))
+		rand.Shuffle(len(partsToCheck), func(i, j int) {
+			partsToCheck[i], partsToCheck[j] = partsToCheck[j], partsToCheck[i]
+		})
+		partsToCheck = partsToCheck[:n]
+		if err := vs.checkChecksums(src, partsToCheck, v.Concurrency); err != nil {
+			return err
+		}
+	}
+
+	if vs.missingParts > 0 || vs.brokenParts > 0 || vs.checksumMismatches > 0 {
+		return fmt.Errorf("the backup at %s is broken: found %d missing parts, %d parts with unexpected size and %d parts with checksum mismatch",
+			src, vs.missingParts, vs.brokenParts, vs.checksumMismatches)
+	}
+	logger.Infof("successfully verified the backup at %s in %.3f seconds; checked sizes for %d parts; checked checksums for %d parts",
+		src, time.Since(startTime).Seconds(), len(parts), vs.checkedChecksums)
+	return nil
+}
+
+type verifyStats struct {
+	missingParts       uint64
+	brokenParts        uint64
+	checksumMismatches uint64
+	checkedChecksums   uint64
+}
+
+// checkPartSizes verifies that the actual sizes of parts match their expected sizes.
+func (vs *verifyStats) checkPartSizes(parts []common.Part) {
+	for i := range parts {
+		p := &parts[i]
+		if p.ActualSize != p.Size {
+			logger.Errorf("unexpected size for %s; got %d bytes; want %d bytes", p, p.ActualSize, p.Size)
+			vs.brokenParts++
+		}
+	}
+}
+
+// checkFileCoverage verifies that parts cover every file without gaps.
+func (vs *verifyStats) checkFileCoverage(parts []common.Part) {
+	perPath := make(map[string][]common.Part)
+	for _, p := range parts {
+		perPath[p.Path] = append(perPath[p.Path], p)
+	}
+	paths := make([]string, 0, len(perPath))
+	for path := range perPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		pathParts := perPath[path]
+		sort.Slice(pathParts, func(i, j int) bool {
+			return pathParts[i].Offset < pathParts[j].Offset
+		})
+		offset := uint64(0)
+		for _, p := range pathParts {
+			if p.Offset > offset {
+				logger.Errorf("missing data for file %q at offsets [%d..%d)", path, offset, p.Offset)
+				vs.missingParts++
+			}
+			if end := p.Offset + p.Size; end > offset {
+				offset = end
+			}
+		}
+		if fileSize := pathParts[0].FileSize; offset < fileSize {
+			logger.Errorf("missing data for file %q at offsets [%d..%d)", path, offset, fileSize)
+			vs.missingParts++
+		}
+	}
+}
+
+// checkManifest verifies that all the parts from m exist in parts.
+//
+// It returns manifest parts with known checksums, which exist in parts.
+func (vs *verifyStats) checkManifest(m *common.Manifest, parts []common.Part) []common.ManifestPart {
+	existing := make(map[partKey]bool, len(parts))
+	for _, p := range parts {
+		existing[newPartKey(p)] = true
+	}
+	var partsWithHashes []common.ManifestPart
+	manifestParts := make(map[partKey]bool, len(m.Parts))
+	for _, mp := range m.Parts {
+		p := mp.Part()
+		k := newPartKey(p)
+		manifestParts[k] = true
+		if !existing[k] {
+			logger.Errorf("missing %s, which is registered in the backup manifest", &p)
+			vs.missingParts++
+			continue
+		}
+		if mp.SHA256 != "" {
+			partsWithHashes = append(partsWithHashes, mp)
+		}
+	}
+	for i := range parts {
+		p := &parts[i]
+		if !manifestParts[newPartKey(*p)] {
+			logger.Warnf("%s isn't registered in the backup manifest", p)
+		}
+	}
+	return partsWithHashes
+}
+
+// checkChecksums downloads the given parts from src and verifies their checksums.
+func (vs *verifyStats) checkChecksums(src common.RemoteFS, mps []common.ManifestPart, concurrency int) error {
+	logger.Infof("verifying checksums for %d parts at %s", len(mps), src)
+	parts := make([]common.Part, len(mps))
+	hashes := make(map[partKey]string, len(mps))
+	for i := range mps {
+		parts[i] = mps[i].Part()
+		hashes[newPartKey(parts[i])] = mps[i].SHA256
+	}
+	var bytesDownloaded uint64
+	var checkedParts uint64
+	var mu sync.Mutex
+	err := runParallel(concurrency, parts, func(p common.Part) error {
+		h := sha256.New()
+		w := &statWriter{
+			w:            h,
+			bytesWritten: &bytesDownloaded,
+		}
+		if err := src.DownloadPart(p, w); err != nil {
+			// The part cannot be downloaded in full. Treat it as corrupted.
+			logger.Errorf("cannot verify checksum for %s: %s", &p, err)
+			mu.Lock()
+			vs.checksumMismatches++
+			mu.Unlock()
+			return nil
+		}
+		atomic.AddUint64(&checkedParts, 1)
+		hash := hex.EncodeToString(h.Sum(nil))
+		mu.Lock()
+		vs.checkedChecksums++
+		if hash != hashes[newPartKey(p)] {
+			logger.Errorf("checksum mismatch for %s; got sha256 %s; want sha256 %s", &p, hash, hashes[newPartKey(p)])
+			vs.checksumMismatches++
+		}
+		mu.Unlock()
+		return nil
+	}, func(elapsed time.Duration) {
+		n := atomic.LoadUint64(&checkedParts)
+		bytes := atomic.LoadUint64(&bytesDownloaded)
+		logger.Infof("verified checksums for %d out of %d parts (%d bytes downloaded) at %s in %s", n, len(parts), bytes, src, elapsed)
+	})
+	return err
+}
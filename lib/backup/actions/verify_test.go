@@ -0,0 +1,131 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fslocal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsremote"
+)
+
+func newTestBackup(t *testing.T) (*fsremote.FS, []common.Part) {
+	t.Helper()
+	srcDir := t.TempDir()
+	files := map[string]string{
+		"data/small/part1/values.bin":     "foobarbaz",
+		"data/small/part1/timestamps.bin": "1234567890",
+		"indexdb/table/part2/items.bin":   "abc",
+	}
+	for path, data := range files {
+		path = filepath.Join(srcDir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("cannot create dir: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("cannot create file: %s", err)
+		}
+	}
+	src := &fslocal.FS{
+		Dir: srcDir,
+	}
+	dst := &fsremote.FS{
+		Dir: t.TempDir(),
+	}
+	b := &Backup{
+		Concurrency: 2,
+		Src:         src,
+		Dst:         dst,
+	}
+	if err := b.Run(); err != nil {
+		t.Fatalf("cannot create backup: %s", err)
+	}
+	parts, err := dst.ListParts()
+	if err != nil {
+		t.Fatalf("cannot list parts: %s", err)
+	}
+	if len(parts) != len(files) {
+		t.Fatalf("unexpected number of parts; got %d; want %d", len(parts), len(files))
+	}
+	return dst, parts
+}
+
+func TestVerifySuccess(t *testing.T) {
+	fs, _ := newTestBackup(t)
+	for _, sampleRatio := range []float64{0, 0.5, 1} {
+		v := &Verify{
+			Src:         fs,
+			SampleRatio: sampleRatio,
+		}
+		if err := v.Run(); err != nil {
+			t.Fatalf("unexpected error for sampleRatio=%v: %s", sampleRatio, err)
+		}
+	}
+
+	// The manifest must contain hashes for all the parts.
+	m, err := readManifest(fs)
+	if err != nil {
+		t.Fatalf("cannot read manifest: %s", err)
+	}
+	if len(m.Parts) != 3 {
+		t.Fatalf("unexpected number of parts in the manifest; got %d; want 3", len(m.Parts))
+	}
+	for _, mp := range m.Parts {
+		if len(mp.SHA256) != 64 {
+			t.Fatalf("unexpected sha256 for %s: %q", mp.Path, mp.SHA256)
+		}
+	}
+}
+
+func TestVerifyFailure(t *testing.T) {
+	f := func(corrupt func(fs *fsremote.FS, p common.Part), sampleRatio float64, errExpected string) {
+		t.Helper()
+		fs, parts := newTestBackup(t)
+		corrupt(fs, parts[0])
+		v := &Verify{
+			Src:         fs,
+			SampleRatio: sampleRatio,
+		}
+		err := v.Run()
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errExpected) {
+			t.Fatalf("unexpected error; got %q; want it containing %q", err, errExpected)
+		}
+	}
+	partPath := func(fs *fsremote.FS, p common.Part) string {
+		return p.RemotePath(fs.Dir)
+	}
+
+	// Missing part
+	f(func(fs *fsremote.FS, p common.Part) {
+		if err := fs.DeletePart(p); err != nil {
+			t.Fatalf("cannot delete part: %s", err)
+		}
+	}, 0, "found 1 missing parts, 0 parts with unexpected size and 0 parts with checksum mismatch")
+
+	// Truncated part
+	f(func(fs *fsremote.FS, p common.Part) {
+		if err := os.Truncate(partPath(fs, p), 1); err != nil {
+			t.Fatalf("cannot truncate part: %s", err)
+		}
+	}, 0, "found 0 missing parts, 1 parts with unexpected size and 0 parts with checksum mismatch")
+
+	// Part with corrupted contents
+	f(func(fs *fsremote.FS, p common.Part) {
+		data := strings.Repeat("x", int(p.Size))
+		if err := os.WriteFile(partPath(fs, p), []byte(data), 0644); err != nil {
+			t.Fatalf("cannot write part: %s", err)
+		}
+	}, 1, "found 0 missing parts, 0 parts with unexpected size and 1 parts with checksum mismatch")
+
+	// Incomplete backup
+	f(func(fs *fsremote.FS, p common.Part) {
+		if err := fs.DeleteFile("backup_complete.ignore"); err != nil {
+			t.Fatalf("cannot delete file: %s", err)
+		}
+	}, 0, "incomplete backup")
+}
@@ -309,6 +309,28 @@ func (fs *FS) CreateFile(filePath string, data []byte) error {
 	return nil
 }
 
+// ReadFile returns the contents of filePath at fs.
+func (fs *FS) ReadFile(filePath string) ([]byte, error) {
+	path := fs.Dir + filePath
+	bc := fs.clientForPath(path)
+
+	ctx := context.Background()
+	r, err := bc.DownloadStream(ctx, &blob.DownloadStreamOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open reader for %q at %s (remote path %q): %w", filePath, fs, bc.URL(), err)
+	}
+
+	body := r.NewRetryReader(ctx, &azblob.RetryReaderOptions{})
+	data, err := io.ReadAll(body)
+	if err1 := body.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q at %s (remote path %q): %w", filePath, fs, bc.URL(), err)
+	}
+	return data, nil
+}
+
 // HasFile returns true if filePath exists at fs.
 func (fs *FS) HasFile(filePath string) (bool, error) {
 	path := fs.Dir + filePath
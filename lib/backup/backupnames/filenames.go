@@ -6,4 +6,10 @@ const (
 	// This file is created at the beginning of the restore process and is deleted at the end of the restore process.
 	// If this file exists, then it is unsafe to read the storage data, since it can be incomplete.
 	RestoreInProgressFilename = "restore-in-progress"
+
+	// RestoreStateFilename is the filename for the file with the list of completely restored parts.
+	//
+	// This file is updated during the restore process and is deleted at the end of the restore process.
+	// It is used for resuming the interrupted restore without downloading the already restored parts again.
+	RestoreStateFilename = "restore-state.jsonl"
 )
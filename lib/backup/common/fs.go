@@ -53,4 +53,7 @@ type RemoteFS interface {
 
 	// HasFile returns true if filePath exists at RemoteFS.
 	HasFile(filePath string) (bool, error)
+
+	// ReadFile returns the contents of filePath at RemoteFS.
+	ReadFile(filePath string) ([]byte, error)
 }
@@ -0,0 +1,31 @@
+package common
+
+// Manifest contains the list of backed up parts together with their content hashes.
+//
+// It is stored in the backup and is used for verifying backup integrity.
+type Manifest struct {
+	Parts []ManifestPart `json:"parts"`
+}
+
+// ManifestPart describes a single part in the Manifest.
+type ManifestPart struct {
+	Path     string `json:"path"`
+	FileSize uint64 `json:"fileSize"`
+	Offset   uint64 `json:"offset"`
+	Size     uint64 `json:"size"`
+
+	// SHA256 is hex-encoded SHA-256 hash of the part contents.
+	//
+	// It may be empty if the hash is unknown.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Part returns the part described by mp.
+func (mp *ManifestPart) Part() Part {
+	return Part{
+		Path:     mp.Path,
+		FileSize: mp.FileSize,
+		Offset:   mp.Offset,
+		Size:     mp.Size,
+	}
+}
@@ -136,7 +136,7 @@ func appendFilesInternal(dst []string, d *os.File) ([]string, error) {
 }
 
 func isSpecialFile(name string) bool {
-	return name == "flock.lock" || name == backupnames.RestoreInProgressFilename
+	return name == "flock.lock" || name == backupnames.RestoreInProgressFilename || name == backupnames.RestoreStateFilename
 }
 
 // RemoveEmptyDirs recursively removes empty directories under the given dir.
@@ -250,7 +250,7 @@ func removeEmptyDirsInternal(d *os.File) (bool, error) {
 	if dirEntries > 0 {
 		return false, nil
 	}
-	// Use os.RemoveAll() instead of os.Remove(), since the dir may contain special files such as flock.lock and backupnames.RestoreInProgressFilename,
+	// Use os.RemoveAll() instead of os.Remove(), since the dir may contain special files such as flock.lock, backupnames.RestoreInProgressFilename and backupnames.RestoreStateFilename,
 	// which must be ignored.
 	if err := os.RemoveAll(dir); err != nil {
 		return false, fmt.Errorf("cannot remove %q: %w", dir, err)
@@ -265,3 +265,6 @@ func IgnorePath(path string) bool {
 
 // BackupCompleteFilename is a filename, which is created in the destination fs when backup is complete.
 const BackupCompleteFilename = "backup_complete.ignore"
+
+// BackupManifestFilename is a filename for the backup manifest containing the list of parts with their content hashes.
+const BackupManifestFilename = "backup_manifest.ignore"
@@ -0,0 +1,556 @@
+package sftpremote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fscommon"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// tmpSuffix is the suffix for partially uploaded parts.
+//
+// Such parts are resumed on the next upload attempt.
+const tmpSuffix = ".tmp"
+
+// FS represents filesystem for backups at SFTP server.
+//
+// Init must be called before calling other FS methods.
+type FS struct {
+	// Addr is the SFTP server address in the form host:port.
+	Addr string
+
+	// User is the user name to use for authentication at SFTP server.
+	User string
+
+	// Dir is the absolute path to directory with backup data at SFTP server.
+	Dir string
+
+	// Password is an optional password for authentication at SFTP server.
+	Password string
+
+	// PrivateKeyFile is an optional path to private key file for authentication at SFTP server.
+	PrivateKeyFile string
+
+	// KnownHostsFile is the path to known_hosts file for verifying SFTP server host key.
+	//
+	// ~/.ssh/known_hosts is used if it is empty.
+	KnownHostsFile string
+
+	// InsecureIgnoreHostKey disables verification of SFTP server host key.
+	InsecureIgnoreHostKey bool
+
+	// MaxIdleConns is the maximum number of idle connections to SFTP server to keep in the pool.
+	//
+	// 16 connections are kept if it is zero.
+	MaxIdleConns int
+
+	// dial opens new connection to SFTP server.
+	//
+	// It may be substituted in tests.
+	dial func() (*conn, error)
+
+	idleConnsLock sync.Mutex
+	idleConns     []*conn
+}
+
+type conn struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+func (c *conn) close() {
+	_ = c.sftp.Close()
+	if c.ssh != nil {
+		_ = c.ssh.Close()
+	}
+}
+
+// Init initializes fs.
+//
+// The returned fs must be stopped when no long needed with MustStop call.
+func (fs *FS) Init() error {
+	if fs.dial != nil {
+		logger.Panicf("BUG: Init is already called")
+	}
+	if fs.MaxIdleConns <= 0 {
+		fs.MaxIdleConns = 16
+	}
+	cfg, err := fs.newSSHConfig()
+	if err != nil {
+		return err
+	}
+	fs.dial = func() (*conn, error) {
+		sshClient, err := ssh.Dial("tcp", fs.Addr, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("cannot connect to %q: %w", fs.Addr, err)
+		}
+		sftpClient, err := sftp.NewClient(sshClient, sftp.UseConcurrentWrites(true))
+		if err != nil {
+			_ = sshClient.Close()
+			return nil, fmt.Errorf("cannot start sftp session at %q: %w", fs.Addr, err)
+		}
+		return &conn{
+			ssh:  sshClient,
+			sftp: sftpClient,
+		}, nil
+	}
+
+	// Verify the connection to SFTP server.
+	c, err := fs.getConn()
+	if err != nil {
+		return err
+	}
+	fs.putConn(c, nil)
+	return nil
+}
+
+func (fs *FS) newSSHConfig() (*ssh.ClientConfig, error) {
+	var auths []ssh.AuthMethod
+	if fs.PrivateKeyFile != "" {
+		data, err := os.ReadFile(fs.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read private key file: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse private key from %q: %w", fs.PrivateKeyFile, err)
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if fs.Password != "" {
+		auths = append(auths, ssh.Password(fs.Password))
+	}
+	if len(auths) == 0 {
+		return nil, fmt.Errorf("missing authentication method for sftp server %q; set either password or private key file", fs.Addr)
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	if fs.InsecureIgnoreHostKey {
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	} else {
+		knownHostsFile := fs.KnownHostsFile
+		if knownHostsFile == "" {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("cannot determine home directory for locating known_hosts file: %w", err)
+			}
+			knownHostsFile = filepath.Join(homeDir, ".ssh", "known_hosts")
+		}
+		cb, err := knownhosts.New(knownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load known hosts for verifying sftp server host key: %w", err)
+		}
+		hostKeyCallback = cb
+	}
+
+	cfg := &ssh.ClientConfig{
+		User:            fs.User,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         time.Minute,
+	}
+	return cfg, nil
+}
+
+func (fs *FS) getConn() (*conn, error) {
+	fs.idleConnsLock.Lock()
+	if n := len(fs.idleConns); n > 0 {
+		c := fs.idleConns[n-1]
+		fs.idleConns = fs.idleConns[:n-1]
+		fs.idleConnsLock.Unlock()
+		return c, nil
+	}
+	fs.idleConnsLock.Unlock()
+	return fs.dial()
+}
+
+// putConn returns c to the pool of idle connections.
+//
+// err must contain the error returned from the last operation on c.
+// c is closed if err indicates the connection is broken.
+func (fs *FS) putConn(c *conn, err error) {
+	if err != nil && !isStatusError(err) {
+		c.close()
+		return
+	}
+	fs.idleConnsLock.Lock()
+	if len(fs.idleConns) < fs.MaxIdleConns {
+		fs.idleConns = append(fs.idleConns, c)
+		c = nil
+	}
+	fs.idleConnsLock.Unlock()
+	if c != nil {
+		c.close()
+	}
+}
+
+// isStatusError returns true if err is returned by SFTP server for the successfully executed request.
+//
+// Such errors do not break the connection.
+func isStatusError(err error) bool {
+	var se *sftp.StatusError
+	return errors.As(err, &se) || os.IsNotExist(err) || os.IsPermission(err)
+}
+
+func (fs *FS) withConn(f func(c *sftp.Client) error) error {
+	c, err := fs.getConn()
+	if err != nil {
+		return err
+	}
+	err = f(c.sftp)
+	fs.putConn(c, err)
+	return err
+}
+
+// MustStop stops fs.
+func (fs *FS) MustStop() {
+	fs.idleConnsLock.Lock()
+	idleConns := fs.idleConns
+	fs.idleConns = nil
+	fs.idleConnsLock.Unlock()
+	for _, c := range idleConns {
+		c.close()
+	}
+}
+
+// String returns human-readable description for fs.
+func (fs *FS) String() string {
+	return fmt.Sprintf("SFTP{addr: %q, user: %q, dir: %q}", fs.Addr, fs.User, fs.Dir)
+}
+
+// ListParts returns all the parts for fs.
+func (fs *FS) ListParts() ([]common.Part, error) {
+	dir := fs.Dir
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	var parts []common.Part
+	err := fs.withConn(func(c *sftp.Client) error {
+		w := c.Walk(dir)
+		for w.Step() {
+			if err := w.Err(); err != nil {
+				if os.IsNotExist(err) && w.Path() == dir {
+					// Return empty part list for non-existing directory.
+					// The directory will be created later.
+					return nil
+				}
+				return fmt.Errorf("cannot list %q: %w", w.Path(), err)
+			}
+			file := w.Path()
+			fi := w.Stat()
+			if fi.IsDir() {
+				continue
+			}
+			if !strings.HasPrefix(file, dir) {
+				logger.Panicf("BUG: unexpected prefix for file %q; want %q", file, dir)
+			}
+			if fscommon.IgnorePath(file) || strings.HasSuffix(file, tmpSuffix) {
+				continue
+			}
+			var p common.Part
+			if !p.ParseFromRemotePath(file[len(dir):]) {
+				logger.Infof("skipping unknown file %s", file)
+				continue
+			}
+			p.ActualSize = uint64(fi.Size())
+			parts = append(parts, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// DeletePart deletes part p from fs.
+func (fs *FS) DeletePart(p common.Part) error {
+	remotePath := fs.path(p)
+	err := fs.withConn(func(c *sftp.Client) error {
+		return c.Remove(remotePath)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot remove %q: %w", remotePath, err)
+	}
+	return nil
+}
+
+// RemoveEmptyDirs recursively removes empty dirs in fs.
+func (fs *FS) RemoveEmptyDirs() error {
+	return fs.withConn(func(c *sftp.Client) error {
+		_, err := removeEmptyDirs(c, fs.Dir)
+		return err
+	})
+}
+
+func removeEmptyDirs(c *sftp.Client, dir string) (bool, error) {
+	fis, err := c.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("cannot read directory contents in %q: %w", dir, err)
+	}
+	dirEntries := 0
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			dirEntries++
+			continue
+		}
+		subdir := path.Join(dir, fi.Name())
+		ok, err := removeEmptyDirs(c, subdir)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			dirEntries++
+		}
+	}
+	if dirEntries > 0 {
+		return false, nil
+	}
+	if err := c.RemoveDirectory(dir); err != nil {
+		return false, fmt.Errorf("cannot remove empty directory %q: %w", dir, err)
+	}
+	return true, nil
+}
+
+// CopyPart copies p from srcFS to fs.
+//
+// SFTP doesn't support server-side copying, so the part is downloaded from srcFS and then uploaded to fs.
+func (fs *FS) CopyPart(srcFS common.OriginFS, p common.Part) error {
+	src, ok := srcFS.(*FS)
+	if !ok {
+		return fmt.Errorf("cannot perform server-side copying from %s to %s: both of them must be sftp", srcFS, fs)
+	}
+	pr, pw := io.Pipe()
+	doneCh := make(chan error, 1)
+	go func() {
+		err := src.DownloadPart(p, pw)
+		pw.CloseWithError(err)
+		doneCh <- err
+	}()
+	err := fs.UploadPart(p, pr)
+	_ = pr.CloseWithError(err)
+	if errDownload := <-doneCh; errDownload != nil && err == nil {
+		err = errDownload
+	}
+	if err != nil {
+		return fmt.Errorf("cannot copy %s from %s to %s: %w", &p, src, fs, err)
+	}
+	return nil
+}
+
+// DownloadPart downloads part p from fs to w.
+func (fs *FS) DownloadPart(p common.Part, w io.Writer) error {
+	remotePath := fs.path(p)
+	return fs.withConn(func(c *sftp.Client) error {
+		f, err := c.Open(remotePath)
+		if err != nil {
+			return fmt.Errorf("cannot open %q: %w", remotePath, err)
+		}
+		n, err := f.WriteTo(w)
+		if err1 := f.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+		if err != nil {
+			return fmt.Errorf("cannot download data from %q: %w", remotePath, err)
+		}
+		if uint64(n) != p.Size {
+			return fmt.Errorf("wrong data size downloaded from %q; got %d bytes; want %d bytes", remotePath, n, p.Size)
+		}
+		return nil
+	})
+}
+
+// UploadPart uploads part p from r to fs.
+//
+// The part is uploaded into a temporary file, which is renamed to the final name after the upload is complete.
+// The upload is resumed from the end of the temporary file if it is left after the previous unsuccessful upload.
+func (fs *FS) UploadPart(p common.Part, r io.Reader) error {
+	remotePath := fs.path(p)
+	tmpPath := remotePath + tmpSuffix
+	return fs.withConn(func(c *sftp.Client) error {
+		if err := c.MkdirAll(path.Dir(remotePath)); err != nil {
+			return fmt.Errorf("cannot create directory for %q: %w", remotePath, err)
+		}
+		offset := int64(0)
+		if fi, err := c.Stat(tmpPath); err == nil && uint64(fi.Size()) <= p.Size {
+			offset = fi.Size()
+		}
+		if offset > 0 {
+			// Skip the already uploaded data.
+			if _, err := io.CopyN(io.Discard, r, offset); err != nil {
+				return fmt.Errorf("cannot skip %d already uploaded bytes for %q: %w", offset, remotePath, err)
+			}
+			logger.Infof("resuming upload to %q from offset %d", remotePath, offset)
+		}
+		flags := os.O_WRONLY | os.O_CREATE
+		if offset == 0 {
+			flags |= os.O_TRUNC
+		}
+		f, err := c.OpenFile(tmpPath, flags)
+		if err != nil {
+			return fmt.Errorf("cannot create %q: %w", tmpPath, err)
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("cannot seek to offset %d at %q: %w", offset, tmpPath, err)
+		}
+		n, err := f.ReadFrom(r)
+		if err1 := f.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+		if err != nil {
+			// Leave the temporary file, so the upload could be resumed on the next attempt.
+			return fmt.Errorf("cannot upload data to %q: %w", tmpPath, err)
+		}
+		if uint64(offset+n) != p.Size {
+			_ = c.Remove(tmpPath)
+			return fmt.Errorf("wrong data size uploaded to %q; got %d bytes; want %d bytes", tmpPath, offset+n, p.Size)
+		}
+		if err := rename(c, tmpPath, remotePath); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func rename(c *sftp.Client, oldPath, newPath string) error {
+	err := c.PosixRename(oldPath, newPath)
+	if err == nil {
+		return nil
+	}
+	// The server may not support posix-rename@openssh.com extension.
+	// Fall back to the standard rename, which fails if newPath exists.
+	_ = c.Remove(newPath)
+	if err := c.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("cannot rename %q to %q: %w", oldPath, newPath, err)
+	}
+	return nil
+}
+
+func (fs *FS) path(p common.Part) string {
+	return p.RemotePath(fs.Dir)
+}
+
+// DeleteFile deletes filePath from fs.
+//
+// The function does nothing if the filePath doesn't exist.
+func (fs *FS) DeleteFile(filePath string) error {
+	remotePath := path.Join(fs.Dir, filePath)
+	err := fs.withConn(func(c *sftp.Client) error {
+		err := c.Remove(remotePath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot remove %q: %w", remotePath, err)
+	}
+	return nil
+}
+
+// CreateFile creates filePath at fs and puts data into it.
+//
+// The file is overwritten if it exists.
+func (fs *FS) CreateFile(filePath string, data []byte) error {
+	remotePath := path.Join(fs.Dir, filePath)
+	err := fs.withConn(func(c *sftp.Client) error {
+		if err := c.MkdirAll(path.Dir(remotePath)); err != nil {
+			return err
+		}
+		f, err := c.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		if err1 := f.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cannot write %d bytes to %q: %w", len(data), remotePath, err)
+	}
+	return nil
+}
+
+// ReadFile returns the contents of filePath at fs.
+func (fs *FS) ReadFile(filePath string) ([]byte, error) {
+	remotePath := path.Join(fs.Dir, filePath)
+	var data []byte
+	err := fs.withConn(func(c *sftp.Client) error {
+		f, err := c.Open(remotePath)
+		if err != nil {
+			return err
+		}
+		data, err = io.ReadAll(f)
+		if err1 := f.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %w", remotePath, err)
+	}
+	return data, nil
+}
+
+// HasFile returns true if filePath exists at fs.
+func (fs *FS) HasFile(filePath string) (bool, error) {
+	remotePath := path.Join(fs.Dir, filePath)
+	var fi os.FileInfo
+	err := fs.withConn(func(c *sftp.Client) error {
+		var err error
+		fi, err = c.Stat(remotePath)
+		return err
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("cannot stat %q: %w", remotePath, err)
+	}
+	if fi.IsDir() {
+		return false, fmt.Errorf("%q is directory, while file is needed", remotePath)
+	}
+	return true, nil
+}
+
+// ParseAddr parses SFTP server address from the `user@host:port` string.
+//
+// The default port 22 is used if the port is missing.
+func ParseAddr(s string) (user, addr string, err error) {
+	n := strings.LastIndexByte(s, '@')
+	if n < 0 {
+		return "", "", fmt.Errorf("missing user name in %q; expecting user@host:port", s)
+	}
+	user = s[:n]
+	addr = s[n+1:]
+	if user == "" {
+		return "", "", fmt.Errorf("user name cannot be empty in %q", s)
+	}
+	if addr == "" {
+		return "", "", fmt.Errorf("host cannot be empty in %q", s)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+	return user, addr, nil
+}
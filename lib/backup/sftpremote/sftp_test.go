@@ -0,0 +1,173 @@
+package sftpremote
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+)
+
+func newTestFS(t *testing.T) *FS {
+	t.Helper()
+	fs := &FS{
+		Dir:          t.TempDir(),
+		MaxIdleConns: 2,
+	}
+	fs.dial = func() (*conn, error) {
+		clientConn, serverConn := net.Pipe()
+		srv, err := sftp.NewServer(serverConn)
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			_ = srv.Serve()
+			_ = srv.Close()
+		}()
+		c, err := sftp.NewClientPipe(clientConn, clientConn)
+		if err != nil {
+			return nil, err
+		}
+		return &conn{
+			sftp: c,
+		}, nil
+	}
+	t.Cleanup(fs.MustStop)
+	return fs
+}
+
+func TestFSParts(t *testing.T) {
+	fs := newTestFS(t)
+	data := []byte("foobarbaz")
+	p := common.Part{
+		Path:     "data/small/part1/values.bin",
+		FileSize: 100,
+		Offset:   10,
+		Size:     uint64(len(data)),
+	}
+
+	// Upload must be resumed from the partially uploaded temporary file.
+	tmpPath := fs.path(p) + tmpSuffix
+	if err := os.MkdirAll(filepath.Dir(tmpPath), 0755); err != nil {
+		t.Fatalf("cannot create dir: %s", err)
+	}
+	if err := os.WriteFile(tmpPath, data[:4], 0644); err != nil {
+		t.Fatalf("cannot create temporary file: %s", err)
+	}
+	if err := fs.UploadPart(p, bytes.NewReader(data)); err != nil {
+		t.Fatalf("cannot upload part: %s", err)
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Fatalf("the temporary file must be removed after the upload; stat error: %v", err)
+	}
+
+	parts, err := fs.ListParts()
+	if err != nil {
+		t.Fatalf("cannot list parts: %s", err)
+	}
+	if len(parts) != 1 {
+		t.Fatalf("unexpected number of parts; got %d; want 1", len(parts))
+	}
+	pExpected := p
+	pExpected.ActualSize = p.Size
+	if parts[0] != pExpected {
+		t.Fatalf("unexpected part; got %+v; want %+v", parts[0], pExpected)
+	}
+
+	var bb bytes.Buffer
+	if err := fs.DownloadPart(p, &bb); err != nil {
+		t.Fatalf("cannot download part: %s", err)
+	}
+	if !bytes.Equal(bb.Bytes(), data) {
+		t.Fatalf("unexpected part contents; got %q; want %q", bb.Bytes(), data)
+	}
+
+	// Copy the part to another fs.
+	fsDst := newTestFS(t)
+	if err := fsDst.CopyPart(fs, p); err != nil {
+		t.Fatalf("cannot copy part: %s", err)
+	}
+	bb.Reset()
+	if err := fsDst.DownloadPart(p, &bb); err != nil {
+		t.Fatalf("cannot download copied part: %s", err)
+	}
+	if !bytes.Equal(bb.Bytes(), data) {
+		t.Fatalf("unexpected copied part contents; got %q; want %q", bb.Bytes(), data)
+	}
+
+	if err := fs.DeletePart(p); err != nil {
+		t.Fatalf("cannot delete part: %s", err)
+	}
+	if err := fs.RemoveEmptyDirs(); err != nil {
+		t.Fatalf("cannot remove empty dirs: %s", err)
+	}
+	parts, err = fs.ListParts()
+	if err != nil {
+		t.Fatalf("cannot list parts after deletion: %s", err)
+	}
+	if len(parts) != 0 {
+		t.Fatalf("unexpected parts after deletion: %v", parts)
+	}
+}
+
+func TestFSFiles(t *testing.T) {
+	fs := newTestFS(t)
+	f := func(filePath string, hasFileExpected bool) {
+		t.Helper()
+		ok, err := fs.HasFile(filePath)
+		if err != nil {
+			t.Fatalf("unexpected error in HasFile(%q): %s", filePath, err)
+		}
+		if ok != hasFileExpected {
+			t.Fatalf("unexpected HasFile(%q) result; got %v; want %v", filePath, ok, hasFileExpected)
+		}
+	}
+
+	f("backup_complete.ignore", false)
+	if err := fs.CreateFile("backup_complete.ignore", []byte("ok")); err != nil {
+		t.Fatalf("cannot create file: %s", err)
+	}
+	f("backup_complete.ignore", true)
+	data, err := fs.ReadFile("backup_complete.ignore")
+	if err != nil {
+		t.Fatalf("cannot read file: %s", err)
+	}
+	if string(data) != "ok" {
+		t.Fatalf("unexpected file contents; got %q; want %q", data, "ok")
+	}
+	if err := fs.DeleteFile("backup_complete.ignore"); err != nil {
+		t.Fatalf("cannot delete file: %s", err)
+	}
+	f("backup_complete.ignore", false)
+
+	// Deleting missing file must succeed.
+	if err := fs.DeleteFile("backup_complete.ignore"); err != nil {
+		t.Fatalf("cannot delete missing file: %s", err)
+	}
+}
+
+func TestParseAddr(t *testing.T) {
+	f := func(s, userExpected, addrExpected string) {
+		t.Helper()
+		user, addr, err := ParseAddr(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if user != userExpected || addr != addrExpected {
+			t.Fatalf("unexpected result for %q; got %q, %q; want %q, %q", s, user, addr, userExpected, addrExpected)
+		}
+	}
+	f("backup@foo", "backup", "foo:22")
+	f("backup@foo:2222", "backup", "foo:2222")
+	f("backup@[::1]:2222", "backup", "[::1]:2222")
+
+	for _, s := range []string{"foo:22", "@foo", "backup@"} {
+		if _, _, err := ParseAddr(s); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+}
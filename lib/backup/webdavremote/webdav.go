@@ -0,0 +1,642 @@
+package webdavremote
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fscommon"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// tmpSuffix is the suffix for partially uploaded parts.
+//
+// Such parts are resumed on the next upload attempt if the server supports partial PUT requests.
+const tmpSuffix = ".tmp"
+
+// FS represents filesystem for backups at WebDAV server.
+//
+// Init must be called before calling other FS methods.
+type FS struct {
+	// BaseURL is the url of WebDAV server root such as https://nextcloud.example.com/remote.php/dav/files/user
+	BaseURL string
+
+	// Dir is the path to directory with backup data relative to BaseURL.
+	Dir string
+
+	// Username is an optional user name for basic auth at WebDAV server.
+	Username string
+
+	// Password is an optional password for basic auth at WebDAV server.
+	Password string
+
+	// MaxIdleConns is the maximum number of idle connections to WebDAV server to keep in the pool.
+	//
+	// 16 connections are kept if it is zero.
+	MaxIdleConns int
+
+	baseURL *url.URL
+	c       *http.Client
+
+	// partialPutUnsupported is set to 1 if the server doesn't support PUT requests with Content-Range header.
+	partialPutUnsupported uint32
+}
+
+// Init initializes fs.
+//
+// The returned fs must be stopped when no long needed with MustStop call.
+func (fs *FS) Init() error {
+	if fs.c != nil {
+		logger.Panicf("BUG: Init is already called")
+	}
+	if fs.MaxIdleConns <= 0 {
+		fs.MaxIdleConns = 16
+	}
+	u, err := url.Parse(fs.BaseURL)
+	if err != nil {
+		return fmt.Errorf("cannot parse WebDAV url %q: %w", fs.BaseURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme in WebDAV url %q; want http or https", fs.BaseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	fs.baseURL = u
+	fs.Dir = "/" + strings.Trim(fs.Dir, "/")
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.MaxIdleConnsPerHost = fs.MaxIdleConns
+	fs.c = &http.Client{
+		Transport: tr,
+		Timeout:   time.Hour,
+	}
+	return nil
+}
+
+// MustStop stops fs.
+func (fs *FS) MustStop() {
+	fs.c.CloseIdleConnections()
+}
+
+// String returns human-readable description for fs.
+func (fs *FS) String() string {
+	return fmt.Sprintf("WebDAV{url: %q, dir: %q}", fs.baseURL.Redacted(), fs.Dir)
+}
+
+// ListParts returns all the parts for fs.
+func (fs *FS) ListParts() ([]common.Part, error) {
+	dir := fs.Dir + "/"
+	var parts []common.Part
+	dirs := []string{dir}
+	for len(dirs) > 0 {
+		d := dirs[len(dirs)-1]
+		dirs = dirs[:len(dirs)-1]
+		entries, err := fs.readDir(d)
+		if err != nil {
+			return nil, err
+		}
+		if entries == nil && d == dir {
+			// Return empty part list for non-existing directory.
+			// The directory will be created later.
+			return nil, nil
+		}
+		for _, e := range entries {
+			if e.isDir {
+				dirs = append(dirs, e.path+"/")
+				continue
+			}
+			file := e.path
+			if !strings.HasPrefix(file, dir) {
+				return nil, fmt.Errorf("unexpected prefix for file %q in PROPFIND response from %s; want %q", file, fs, dir)
+			}
+			if fscommon.IgnorePath(file) || strings.HasSuffix(file, tmpSuffix) {
+				continue
+			}
+			var p common.Part
+			if !p.ParseFromRemotePath(file[len(dir):]) {
+				logger.Infof("skipping unknown file %s", file)
+				continue
+			}
+			p.ActualSize = e.size
+			parts = append(parts, p)
+		}
+	}
+	return parts, nil
+}
+
+type dirEntry struct {
+	path  string
+	size  uint64
+	isDir bool
+}
+
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ContentLength string `xml:"getcontentlength"`
+				ResourceType  struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/></d:prop></d:propfind>`
+
+// readDir returns entries for the given dir.
+//
+// nil is returned if dir doesn't exist.
+func (fs *FS) readDir(dir string) ([]dirEntry, error) {
+	req, err := fs.newRequest("PROPFIND", dir, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := fs.c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list %q at %s: %w", dir, fs, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, newStatusError(resp, "cannot list %q at %s", dir, fs)
+	}
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("cannot parse PROPFIND response for %q at %s: %w", dir, fs, err)
+	}
+	entries := []dirEntry{}
+	for _, r := range ms.Responses {
+		p, err := fs.parseHref(r.Href)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSuffix(p, "/") == strings.TrimSuffix(dir, "/") {
+			// Skip the dir itself.
+			continue
+		}
+		e := dirEntry{
+			path: strings.TrimSuffix(p, "/"),
+		}
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			if ps.Prop.ResourceType.Collection != nil {
+				e.isDir = true
+			}
+			if ps.Prop.ContentLength != "" {
+				n, err := strconv.ParseUint(ps.Prop.ContentLength, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("cannot parse content length for %q at %s: %w", p, fs, err)
+				}
+				e.size = n
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// parseHref returns the path relative to fs.BaseURL for the given href from PROPFIND response.
+func (fs *FS) parseHref(href string) (string, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse href %q in PROPFIND response from %s: %w", href, fs, err)
+	}
+	if !strings.HasPrefix(u.Path, fs.baseURL.Path+"/") {
+		return "", fmt.Errorf("unexpected href %q in PROPFIND response from %s; it must start with %q", href, fs, fs.baseURL.Path+"/")
+	}
+	return u.Path[len(fs.baseURL.Path):], nil
+}
+
+// DeletePart deletes part p from fs.
+func (fs *FS) DeletePart(p common.Part) error {
+	path := fs.path(p)
+	if err := fs.delete(path); err != nil {
+		return fmt.Errorf("cannot delete %q at %s: %w", path, fs, err)
+	}
+	return nil
+}
+
+// RemoveEmptyDirs recursively removes empty dirs in fs.
+func (fs *FS) RemoveEmptyDirs() error {
+	_, err := fs.removeEmptyDirs(fs.Dir + "/")
+	return err
+}
+
+func (fs *FS) removeEmptyDirs(dir string) (bool, error) {
+	entries, err := fs.readDir(dir)
+	if err != nil {
+		return false, err
+	}
+	if entries == nil {
+		return true, nil
+	}
+	dirEntries := 0
+	for _, e := range entries {
+		if !e.isDir {
+			dirEntries++
+			continue
+		}
+		ok, err := fs.removeEmptyDirs(e.path + "/")
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			dirEntries++
+		}
+	}
+	if dirEntries > 0 {
+		return false, nil
+	}
+	if err := fs.delete(dir); err != nil {
+		return false, fmt.Errorf("cannot remove empty directory %q at %s: %w", dir, fs, err)
+	}
+	return true, nil
+}
+
+// CopyPart copies p from srcFS to fs.
+//
+// Server-side copying is performed via WebDAV COPY method if srcFS is located at the same WebDAV server.
+// Otherwise the part is downloaded from srcFS and then uploaded to fs.
+func (fs *FS) CopyPart(srcFS common.OriginFS, p common.Part) error {
+	src, ok := srcFS.(*FS)
+	if !ok {
+		return fmt.Errorf("cannot perform server-side copying from %s to %s: both of them must be webdav", srcFS, fs)
+	}
+	if src.baseURL.Host == fs.baseURL.Host && src.baseURL.Scheme == fs.baseURL.Scheme {
+		return fs.copyPartServerSide(src, p)
+	}
+	pr, pw := io.Pipe()
+	doneCh := make(chan error, 1)
+	go func() {
+		err := src.DownloadPart(p, pw)
+		pw.CloseWithError(err)
+		doneCh <- err
+	}()
+	err := fs.UploadPart(p, pr)
+	_ = pr.CloseWithError(err)
+	if errDownload := <-doneCh; errDownload != nil && err == nil {
+		err = errDownload
+	}
+	if err != nil {
+		return fmt.Errorf("cannot copy %s from %s to %s: %w", &p, src, fs, err)
+	}
+	return nil
+}
+
+func (fs *FS) copyPartServerSide(src *FS, p common.Part) error {
+	srcPath := src.path(p)
+	dstPath := fs.path(p)
+	if err := fs.mkdirAll(path.Dir(dstPath)); err != nil {
+		return err
+	}
+	req, err := src.newRequest("COPY", srcPath, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Destination", fs.urlForPath(dstPath).String())
+	req.Header.Set("Overwrite", "T")
+	resp, err := fs.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot copy %q from %s to %q at %s: %w", srcPath, src, dstPath, fs, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return newStatusError(resp, "cannot copy %q from %s to %q at %s", srcPath, src, dstPath, fs)
+	}
+	return nil
+}
+
+// DownloadPart downloads part p from fs to w.
+func (fs *FS) DownloadPart(p common.Part, w io.Writer) error {
+	path := fs.path(p)
+	req, err := fs.newRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := fs.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot open %q at %s: %w", path, fs, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(resp, "cannot open %q at %s", path, fs)
+	}
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return fmt.Errorf("cannot download %q from %s: %w", path, fs, err)
+	}
+	if uint64(n) != p.Size {
+		return fmt.Errorf("wrong data size downloaded from %q at %s; got %d bytes; want %d bytes", path, fs, n, p.Size)
+	}
+	return nil
+}
+
+// UploadPart uploads part p from r to fs.
+//
+// The part is uploaded to a temporary file, which is moved to the part path via MOVE method when the upload is complete.
+// The upload is resumed from the end of the temporary file left after the previous unsuccessful upload.
+// WebDAV has no standard way for appending data to the existing file, so the remaining data is uploaded
+// via PUT request with Content-Range header, which is supported by popular servers such as Apache mod_dav.
+// The part is uploaded from scratch on the next attempt if the server doesn't support such requests.
+func (fs *FS) UploadPart(p common.Part, r io.Reader) error {
+	filePath := fs.path(p)
+	tmpPath := filePath + tmpSuffix
+	if err := fs.mkdirAll(path.Dir(filePath)); err != nil {
+		return err
+	}
+	offset := uint64(0)
+	if atomic.LoadUint32(&fs.partialPutUnsupported) == 0 {
+		size, ok, err := fs.getFileSize(tmpPath)
+		if err != nil {
+			return err
+		}
+		if ok && size <= p.Size {
+			offset = size
+		}
+	}
+	if offset > 0 {
+		// Skip the already uploaded data.
+		if _, err := io.CopyN(io.Discard, r, int64(offset)); err != nil {
+			return fmt.Errorf("cannot skip %d already uploaded bytes for %q: %w", offset, filePath, err)
+		}
+		logger.Infof("resuming upload to %q at %s from offset %d", filePath, fs, offset)
+	}
+	if offset == 0 || offset < p.Size {
+		if err := fs.putFileRange(tmpPath, r, offset, p.Size); err != nil {
+			if offset > 0 && isPartialPutUnsupported(err) {
+				atomic.StoreUint32(&fs.partialPutUnsupported, 1)
+				_ = fs.delete(tmpPath)
+			}
+			// Leave the temporary file, so the upload could be resumed on the next attempt.
+			return fmt.Errorf("cannot upload data to %q at %s: %w", tmpPath, fs, err)
+		}
+	}
+	size, ok, err := fs.getFileSize(tmpPath)
+	if err != nil {
+		return err
+	}
+	if !ok || size != p.Size {
+		if offset > 0 {
+			// The server ignored Content-Range header and overwrote the temporary file with the remaining data.
+			atomic.StoreUint32(&fs.partialPutUnsupported, 1)
+		}
+		_ = fs.delete(tmpPath)
+		return fmt.Errorf("wrong data size uploaded to %q at %s; got %d bytes; want %d bytes", tmpPath, fs, size, p.Size)
+	}
+	return fs.move(tmpPath, filePath)
+}
+
+func (fs *FS) putFile(filePath string, r io.Reader, size int64) error {
+	if err := fs.mkdirAll(path.Dir(filePath)); err != nil {
+		return err
+	}
+	return fs.putFileRange(filePath, r, 0, uint64(size))
+}
+
+// putFileRange writes data for the [offset..size) range of filePath from r.
+//
+// The file must contain exactly offset bytes if offset > 0.
+func (fs *FS) putFileRange(filePath string, r io.Reader, offset, size uint64) error {
+	req, err := fs.newRequest(http.MethodPut, filePath, io.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	// Set the content length explicitly, so the server could reject the upload early if there is no enough space.
+	req.ContentLength = int64(size - offset)
+	if offset > 0 {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, size-1, size))
+	}
+	resp, err := fs.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return &statusError{
+			err:        newStatusError(resp, "unexpected response"),
+			statusCode: resp.StatusCode,
+		}
+	}
+	return nil
+}
+
+type statusError struct {
+	err        error
+	statusCode int
+}
+
+func (se *statusError) Error() string {
+	return se.err.Error()
+}
+
+// isPartialPutUnsupported returns true if err is returned by the server, which doesn't support PUT requests with Content-Range header.
+//
+// Such servers must respond with 400 Bad Request according to RFC 9110, while some of them respond with 501 Not Implemented.
+func isPartialPutUnsupported(err error) bool {
+	se, ok := err.(*statusError)
+	return ok && (se.statusCode == http.StatusBadRequest || se.statusCode == http.StatusNotImplemented)
+}
+
+// getFileSize returns the size of filePath at fs.
+//
+// false is returned if filePath doesn't exist.
+func (fs *FS) getFileSize(filePath string) (uint64, bool, error) {
+	req, err := fs.newRequest(http.MethodHead, filePath, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	resp, err := fs.c.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("cannot obtain the size of %q at %s: %w", filePath, fs, err)
+	}
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if resp.ContentLength < 0 {
+			return 0, false, fmt.Errorf("missing Content-Length in response for %q at %s", filePath, fs)
+		}
+		return uint64(resp.ContentLength), true, nil
+	case http.StatusNotFound:
+		return 0, false, nil
+	default:
+		return 0, false, newStatusError(resp, "cannot obtain the size of %q at %s", filePath, fs)
+	}
+}
+
+// move moves srcPath to dstPath at fs. dstPath is overwritten if it exists.
+func (fs *FS) move(srcPath, dstPath string) error {
+	req, err := fs.newRequest("MOVE", srcPath, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Destination", fs.urlForPath(dstPath).String())
+	req.Header.Set("Overwrite", "T")
+	resp, err := fs.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot move %q to %q at %s: %w", srcPath, dstPath, fs, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return newStatusError(resp, "cannot move %q to %q at %s", srcPath, dstPath, fs)
+	}
+	return nil
+}
+
+// mkdirAll creates dir with all the missing parent dirs at fs.
+func (fs *FS) mkdirAll(dir string) error {
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" {
+		return nil
+	}
+	req, err := fs.newRequest("MKCOL", dir+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := fs.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot create directory %q at %s: %w", dir, fs, err)
+	}
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusMethodNotAllowed:
+		// StatusMethodNotAllowed is returned if the directory already exists.
+		return nil
+	case http.StatusConflict:
+		// The parent directory is missing.
+		if err := fs.mkdirAll(path.Dir(dir)); err != nil {
+			return err
+		}
+		return fs.mkdirAll(dir)
+	default:
+		return newStatusError(resp, "cannot create directory %q at %s", dir, fs)
+	}
+}
+
+func (fs *FS) delete(filePath string) error {
+	req, err := fs.newRequest(http.MethodDelete, filePath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := fs.c.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return newStatusError(resp, "unexpected response")
+	}
+	return nil
+}
+
+func (fs *FS) path(p common.Part) string {
+	return p.RemotePath(fs.Dir)
+}
+
+func (fs *FS) urlForPath(filePath string) *url.URL {
+	u := *fs.baseURL
+	u.Path += filePath
+	u.User = nil
+	return &u
+}
+
+func (fs *FS) newRequest(method, filePath string, body io.Reader) (*http.Request, error) {
+	u := fs.urlForPath(filePath)
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create %s request for %q: %w", method, u.Redacted(), err)
+	}
+	if fs.Username != "" || fs.Password != "" {
+		req.SetBasicAuth(fs.Username, fs.Password)
+	}
+	return req, nil
+}
+
+func newStatusError(resp *http.Response, format string, args ...interface{}) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4*1024))
+	return fmt.Errorf("%s: unexpected status code %d; response body: %q", fmt.Sprintf(format, args...), resp.StatusCode, body)
+}
+
+// DeleteFile deletes filePath from fs.
+//
+// The function does nothing if the filePath doesn't exist.
+func (fs *FS) DeleteFile(filePath string) error {
+	path := path.Join(fs.Dir, filePath)
+	if err := fs.delete(path); err != nil {
+		return fmt.Errorf("cannot delete %q at %s: %w", path, fs, err)
+	}
+	return nil
+}
+
+// CreateFile creates filePath at fs and puts data into it.
+//
+// The file is overwritten if it exists.
+func (fs *FS) CreateFile(filePath string, data []byte) error {
+	path := path.Join(fs.Dir, filePath)
+	if err := fs.putFile(path, bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("cannot write %d bytes to %q at %s: %w", len(data), path, fs, err)
+	}
+	return nil
+}
+
+// ReadFile returns the contents of filePath at fs.
+func (fs *FS) ReadFile(filePath string) ([]byte, error) {
+	path := path.Join(fs.Dir, filePath)
+	req, err := fs.newRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fs.c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %q at %s: %w", path, fs, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp, "cannot open %q at %s", path, fs)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q from %s: %w", path, fs, err)
+	}
+	return data, nil
+}
+
+// HasFile returns true if filePath exists at fs.
+func (fs *FS) HasFile(filePath string) (bool, error) {
+	path := path.Join(fs.Dir, filePath)
+	req, err := fs.newRequest(http.MethodHead, path, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := fs.c.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("cannot check whether %q exists at %s: %w", path, fs, err)
+	}
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, newStatusError(resp, "cannot check whether %q exists at %s", path, fs)
+	}
+}
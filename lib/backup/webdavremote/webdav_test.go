@@ -0,0 +1,256 @@
+package webdavremote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+)
+
+func newTestFS(t *testing.T, dir string) *FS {
+	t.Helper()
+	return newTestFSWithHandler(t, dir, func(h *webdav.Handler) http.Handler {
+		return h
+	})
+}
+
+func newTestFSWithHandler(t *testing.T, dir string, wrapHandler func(h *webdav.Handler) http.Handler) *FS {
+	t.Helper()
+	srv := httptest.NewServer(wrapHandler(&webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}))
+	t.Cleanup(srv.Close)
+	fs := &FS{
+		BaseURL: srv.URL + "/dav",
+		Dir:     dir,
+	}
+	if err := fs.Init(); err != nil {
+		t.Fatalf("cannot initialize fs: %s", err)
+	}
+	t.Cleanup(fs.MustStop)
+	return fs
+}
+
+func TestFSParts(t *testing.T) {
+	fs := newTestFS(t, "backups/foo")
+	parts, err := fs.ListParts()
+	if err != nil {
+		t.Fatalf("cannot list parts at missing dir: %s", err)
+	}
+	if len(parts) != 0 {
+		t.Fatalf("unexpected parts at missing dir: %v", parts)
+	}
+
+	data := []byte("foobarbaz")
+	p := common.Part{
+		Path:     "data/small/part1/values.bin",
+		FileSize: 100,
+		Offset:   10,
+		Size:     uint64(len(data)),
+	}
+	if err := fs.UploadPart(p, bytes.NewReader(data)); err != nil {
+		t.Fatalf("cannot upload part: %s", err)
+	}
+	parts, err = fs.ListParts()
+	if err != nil {
+		t.Fatalf("cannot list parts: %s", err)
+	}
+	if len(parts) != 1 {
+		t.Fatalf("unexpected number of parts; got %d; want 1", len(parts))
+	}
+	pExpected := p
+	pExpected.ActualSize = p.Size
+	if parts[0] != pExpected {
+		t.Fatalf("unexpected part; got %+v; want %+v", parts[0], pExpected)
+	}
+
+	var bb bytes.Buffer
+	if err := fs.DownloadPart(p, &bb); err != nil {
+		t.Fatalf("cannot download part: %s", err)
+	}
+	if !bytes.Equal(bb.Bytes(), data) {
+		t.Fatalf("unexpected part contents; got %q; want %q", bb.Bytes(), data)
+	}
+
+	// Server-side copy to another dir at the same server.
+	fsDst := &FS{
+		BaseURL: fs.BaseURL,
+		Dir:     "backups/bar",
+	}
+	if err := fsDst.Init(); err != nil {
+		t.Fatalf("cannot initialize fs: %s", err)
+	}
+	defer fsDst.MustStop()
+	if err := fsDst.CopyPart(fs, p); err != nil {
+		t.Fatalf("cannot copy part: %s", err)
+	}
+	bb.Reset()
+	if err := fsDst.DownloadPart(p, &bb); err != nil {
+		t.Fatalf("cannot download copied part: %s", err)
+	}
+	if !bytes.Equal(bb.Bytes(), data) {
+		t.Fatalf("unexpected copied part contents; got %q; want %q", bb.Bytes(), data)
+	}
+
+	if err := fs.DeletePart(p); err != nil {
+		t.Fatalf("cannot delete part: %s", err)
+	}
+	if err := fs.RemoveEmptyDirs(); err != nil {
+		t.Fatalf("cannot remove empty dirs: %s", err)
+	}
+	parts, err = fs.ListParts()
+	if err != nil {
+		t.Fatalf("cannot list parts after deletion: %s", err)
+	}
+	if len(parts) != 0 {
+		t.Fatalf("unexpected parts after deletion: %v", parts)
+	}
+}
+
+// partialPutHandler supports PUT requests with Content-Range header in the same way as Apache mod_dav does.
+type partialPutHandler struct {
+	h *webdav.Handler
+}
+
+func (h *partialPutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	contentRange := r.Header.Get("Content-Range")
+	if r.Method != http.MethodPut || contentRange == "" {
+		h.h.ServeHTTP(w, r)
+		return
+	}
+	var start, end, size int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &size); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, h.h.Prefix)
+	f, err := h.h.FileSystem.OpenFile(r.Context(), name, os.O_RDWR, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := io.Copy(f, r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestFSUploadPartResume(t *testing.T) {
+	data := []byte("foobarbaz")
+	p := common.Part{
+		Path:     "data/small/part1/values.bin",
+		FileSize: 100,
+		Size:     uint64(len(data)),
+	}
+	f := func(fs *FS, partialPutSupported bool) {
+		t.Helper()
+
+		// Leave partially uploaded part after the previous unsuccessful upload.
+		tmpPath := fs.path(p) + tmpSuffix
+		if err := fs.putFile(tmpPath, bytes.NewReader(data[:4]), 4); err != nil {
+			t.Fatalf("cannot create temporary file: %s", err)
+		}
+		err := fs.UploadPart(p, bytes.NewReader(data))
+		if !partialPutSupported {
+			// The upload must be restarted from scratch on the next attempt.
+			if err == nil {
+				t.Fatalf("expecting non-nil error when resuming the upload at server without partial PUT support")
+			}
+			if _, ok, err := fs.getFileSize(tmpPath); err != nil || ok {
+				t.Fatalf("the temporary file must be deleted; ok=%v, err=%v", ok, err)
+			}
+			err = fs.UploadPart(p, bytes.NewReader(data))
+		}
+		if err != nil {
+			t.Fatalf("cannot upload part: %s", err)
+		}
+		var bb bytes.Buffer
+		if err := fs.DownloadPart(p, &bb); err != nil {
+			t.Fatalf("cannot download part: %s", err)
+		}
+		if !bytes.Equal(bb.Bytes(), data) {
+			t.Fatalf("unexpected part contents; got %q; want %q", bb.Bytes(), data)
+		}
+		if _, ok, err := fs.getFileSize(tmpPath); err != nil || ok {
+			t.Fatalf("the temporary file must be moved to the part; ok=%v, err=%v", ok, err)
+		}
+		parts, err := fs.ListParts()
+		if err != nil {
+			t.Fatalf("cannot list parts: %s", err)
+		}
+		if len(parts) != 1 {
+			t.Fatalf("unexpected number of parts; got %d; want 1", len(parts))
+		}
+	}
+
+	// The server supports partial PUT requests
+	f(newTestFSWithHandler(t, "backups", func(h *webdav.Handler) http.Handler {
+		return &partialPutHandler{h: h}
+	}), true)
+
+	// The server ignores Content-Range header
+	f(newTestFS(t, "backups"), false)
+
+	// The server rejects PUT requests with Content-Range header
+	f(newTestFSWithHandler(t, "backups", func(h *webdav.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPut && r.Header.Get("Content-Range") != "" {
+				http.Error(w, "Content-Range isn't supported", http.StatusBadRequest)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}), false)
+}
+
+func TestFSFiles(t *testing.T) {
+	fs := newTestFS(t, "backups")
+	f := func(filePath string, hasFileExpected bool) {
+		t.Helper()
+		ok, err := fs.HasFile(filePath)
+		if err != nil {
+			t.Fatalf("unexpected error in HasFile(%q): %s", filePath, err)
+		}
+		if ok != hasFileExpected {
+			t.Fatalf("unexpected HasFile(%q) result; got %v; want %v", filePath, ok, hasFileExpected)
+		}
+	}
+
+	f("backup_complete.ignore", false)
+	if err := fs.CreateFile("backup_complete.ignore", []byte("ok")); err != nil {
+		t.Fatalf("cannot create file: %s", err)
+	}
+	f("backup_complete.ignore", true)
+	data, err := fs.ReadFile("backup_complete.ignore")
+	if err != nil {
+		t.Fatalf("cannot read file: %s", err)
+	}
+	if string(data) != "ok" {
+		t.Fatalf("unexpected file contents; got %q; want %q", data, "ok")
+	}
+	if err := fs.DeleteFile("backup_complete.ignore"); err != nil {
+		t.Fatalf("cannot delete file: %s", err)
+	}
+	f("backup_complete.ignore", false)
+
+	// Deleting missing file must succeed.
+	if err := fs.DeleteFile("backup_complete.ignore"); err != nil {
+		t.Fatalf("cannot delete missing file: %s", err)
+	}
+}
@@ -0,0 +1,224 @@
+package envflag
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var configFile = flag.String("configFile", "", "Optional path to YAML file with flag values in the form flagName: value. "+
+	"Flags set via command line or environment vars have priority over flags from the file. "+
+	"The file is re-read on SIGHUP signal; reloadable flags are updated without the restart, while changes for the remaining flags are logged and ignored. "+
+	"See https://docs.victoriametrics.com/#flags-config-file")
+
+var (
+	configFileReloads      = metrics.NewCounter(`vm_flags_config_file_reloads_total`)
+	configFileReloadErrors = metrics.NewCounter(`vm_flags_config_file_reloads_errors_total`)
+)
+
+// configFileValues contains flag values, which were applied from -configFile.
+//
+// It is accessed only from the goroutine, which calls Parse, and then from the goroutine started by startConfigFileReloader.
+var configFileValues map[string][]string
+
+// applyConfigFile applies flag values from -configFile to fs for flags, which aren't set via command line or env vars.
+func applyConfigFile(fs *flag.FlagSet) {
+	if *configFile == "" {
+		return
+	}
+	values, err := readConfigFile(fs, *configFile)
+	if err != nil {
+		// Do not use lib/logger here, since it is uninitialized yet.
+		log.Fatalf("cannot read -configFile: %s", err)
+	}
+	for _, name := range getSortedNames(values) {
+		if flagutil.GetFlagSource(name) != flagutil.FlagSourceDefault {
+			// The flag is explicitly set via command line or env var.
+			continue
+		}
+		for _, v := range values[name] {
+			if err := fs.Set(name, v); err != nil {
+				// Do not use lib/logger here, since it is uninitialized yet.
+				log.Fatalf("cannot set flag -%s to %q, which is read from -configFile=%q: %s", name, v, *configFile, err)
+			}
+		}
+		flagutil.SetFlagSource(name, flagutil.FlagSourceConfigFile)
+	}
+	configFileValues = values
+}
+
+func startConfigFileReloader(fs *flag.FlagSet) {
+	if *configFile == "" {
+		return
+	}
+	sighupCh := procutil.NewSighupChan()
+	go func() {
+		for range sighupCh {
+			reloadConfigFile(fs)
+		}
+	}()
+}
+
+func reloadConfigFile(fs *flag.FlagSet) {
+	configFileReloads.Inc()
+	logger.Infof("received SIGHUP; reloading -configFile=%q", *configFile)
+	values, err := readConfigFile(fs, *configFile)
+	if err != nil {
+		configFileReloadErrors.Inc()
+		logger.Errorf("cannot read -configFile: %s; preserving the previous flag values", err)
+		return
+	}
+	valuesApplied := make(map[string][]string, len(values))
+	for name, a := range values {
+		valuesApplied[name] = a
+	}
+	changes := make(map[string][]string)
+	var ignored []string
+	names := getSortedNames(values)
+	for name := range configFileValues {
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a, ok := values[name]
+		aPrev, okPrev := configFileValues[name]
+		if ok == okPrev && reflect.DeepEqual(a, aPrev) {
+			continue
+		}
+		source := flagutil.GetFlagSource(name)
+		if source == flagutil.FlagSourceCommandLine || source == flagutil.FlagSourceEnv {
+			logger.Infof("ignoring the updated -%s from -configFile, since the flag is set via %s, which has priority over -configFile", name, getSourceName(source))
+			continue
+		}
+		if !flagutil.IsReloadable(name) {
+			ignored = append(ignored, name)
+			// Preserve the previously applied value, so the change is reported again on the next reload until the restart.
+			if okPrev {
+				valuesApplied[name] = aPrev
+			} else {
+				delete(valuesApplied, name)
+			}
+			continue
+		}
+		// nil value resets the flag to the default value if it is removed from -configFile.
+		changes[name] = a
+	}
+	if len(ignored) > 0 {
+		logger.Warnf("ignoring the updated flags from -configFile, since they require restart to take effect: -%s", strings.Join(ignored, ", -"))
+	}
+	if err := flagutil.ApplyFlagValues(fs, changes, flagutil.FlagSourceConfigFile); err != nil {
+		configFileReloadErrors.Inc()
+		logger.Errorf("cannot apply flags from -configFile=%q: %s; preserving the previous flag values", *configFile, err)
+		return
+	}
+	configFileValues = valuesApplied
+	if len(changes) == 0 {
+		logger.Infof("nothing changed in reloadable flags at -configFile=%q", *configFile)
+		return
+	}
+	updated := getSortedNames(changes)
+	logger.Infof("successfully applied updated flags from -configFile=%q: -%s", *configFile, strings.Join(updated, ", -"))
+}
+
+func getSourceName(source string) string {
+	if source == flagutil.FlagSourceEnv {
+		return "environment var"
+	}
+	return "command line"
+}
+
+// readConfigFile reads flag values from YAML file at the given path.
+//
+// Every returned value contains a single item for scalar YAML values and multiple items for YAML lists.
+func readConfigFile(fs *flag.FlagSet, path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %w", path, err)
+	}
+	data, err = envtemplate.ReplaceBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot expand environment vars at %q: %w", path, err)
+	}
+	values, err := parseConfigFileData(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+	for name := range values {
+		if fs.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown flag -%s at %q", name, path)
+		}
+		if name == "configFile" {
+			return nil, fmt.Errorf("-configFile cannot be set at %q", path)
+		}
+	}
+	return values, nil
+}
+
+func parseConfigFileData(data []byte) (map[string][]string, error) {
+	var m map[string]interface{}
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, err
+	}
+	values := make(map[string][]string, len(m))
+	for name, v := range m {
+		name = strings.TrimPrefix(name, "-")
+		var a []string
+		switch t := v.(type) {
+		case []interface{}:
+			for _, item := range t {
+				s, err := getScalarValue(item)
+				if err != nil {
+					return nil, fmt.Errorf("unsupported list item for flag %q: %w", name, err)
+				}
+				a = append(a, s)
+			}
+		default:
+			s, err := getScalarValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("unsupported value for flag %q: %w", name, err)
+			}
+			a = []string{s}
+		}
+		if len(a) == 0 {
+			return nil, fmt.Errorf("missing values for flag %q", name)
+		}
+		values[name] = a
+	}
+	return values, nil
+}
+
+func getScalarValue(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", fmt.Errorf("missing value")
+	case string:
+		return t, nil
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(t), nil
+	default:
+		return "", fmt.Errorf("got %T; want scalar value", v)
+	}
+}
+
+func getSortedNames(values map[string][]string) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
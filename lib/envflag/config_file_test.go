@@ -0,0 +1,56 @@
+package envflag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConfigFileDataSuccess(t *testing.T) {
+	f := func(data string, valuesExpected map[string][]string) {
+		t.Helper()
+		values, err := parseConfigFileData([]byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(values, valuesExpected) {
+			t.Fatalf("unexpected values; got %v; want %v", values, valuesExpected)
+		}
+	}
+	f(``, map[string][]string{})
+	f(`
+search.maxConcurrentRequests: 16
+memory.allowedPercent: 60.5
+search.maxQueueDuration: 10s
+-envflag.enable: true
+promscrape.config.strictParse: false
+`, map[string][]string{
+		"search.maxConcurrentRequests":  {"16"},
+		"memory.allowedPercent":         {"60.5"},
+		"search.maxQueueDuration":       {"10s"},
+		"envflag.enable":                {"true"},
+		"promscrape.config.strictParse": {"false"},
+	})
+	f(`
+remoteWrite.url:
+- http://foo/api/v1/write
+- http://bar/api/v1/write
+`, map[string][]string{
+		"remoteWrite.url": {"http://foo/api/v1/write", "http://bar/api/v1/write"},
+	})
+}
+
+func TestParseConfigFileDataFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		if _, err := parseConfigFileData([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f(`foo`)
+	f(`[foo]`)
+	f(`foo:`)
+	f(`foo: []`)
+	f(`foo: {bar: baz}`)
+	f(`foo: [bar, [baz]]`)
+	f("foo: 1\nfoo: 2")
+}
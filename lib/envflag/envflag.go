@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 )
 
 var (
@@ -16,13 +17,15 @@ var (
 	prefix = flag.String("envflag.prefix", "", "Prefix for environment variables if -envflag.enable is set")
 )
 
-// Parse parses environment vars and command-line flags.
+// Parse parses environment vars, command-line flags and -configFile.
 //
 // Flags set via command-line override flags set via environment vars.
+// Flags set via environment vars override flags set via -configFile.
 //
 // This function must be called instead of flag.Parse() before using any flags in the program.
 func Parse() {
 	ParseFlagSet(flag.CommandLine, os.Args[1:])
+	startConfigFileReloader(flag.CommandLine)
 }
 
 // ParseFlagSet parses the given args into the given fs.
@@ -32,30 +35,31 @@ func ParseFlagSet(fs *flag.FlagSet, args []string) {
 		// Do not use lib/logger here, since it is uninitialized yet.
 		log.Fatalf("cannot parse flags %q: %s", args, err)
 	}
-	if !*enable {
-		return
-	}
 	// Remember explicitly set command-line flags.
 	flagsSet := make(map[string]bool)
 	fs.Visit(func(f *flag.Flag) {
 		flagsSet[f.Name] = true
+		flagutil.SetFlagSource(f.Name, flagutil.FlagSourceCommandLine)
 	})
-
-	// Obtain the remaining flag values from environment vars.
-	fs.VisitAll(func(f *flag.Flag) {
-		if flagsSet[f.Name] {
-			// The flag is explicitly set via command-line.
-			return
-		}
-		// Get flag value from environment var.
-		fname := getEnvFlagName(f.Name)
-		if v, ok := envtemplate.LookupEnv(fname); ok {
-			if err := fs.Set(f.Name, v); err != nil {
-				// Do not use lib/logger here, since it is uninitialized yet.
-				log.Fatalf("cannot set flag %s to %q, which is read from env var %q: %s", f.Name, v, fname, err)
+	if *enable {
+		// Obtain the remaining flag values from environment vars.
+		fs.VisitAll(func(f *flag.Flag) {
+			if flagsSet[f.Name] {
+				// The flag is explicitly set via command-line.
+				return
 			}
-		}
-	})
+			// Get flag value from environment var.
+			fname := getEnvFlagName(f.Name)
+			if v, ok := envtemplate.LookupEnv(fname); ok {
+				if err := fs.Set(f.Name, v); err != nil {
+					// Do not use lib/logger here, since it is uninitialized yet.
+					log.Fatalf("cannot set flag %s to %q, which is read from env var %q: %s", f.Name, v, fname, err)
+				}
+				flagutil.SetFlagSource(f.Name, flagutil.FlagSourceEnv)
+			}
+		})
+	}
+	applyConfigFile(fs)
 }
 
 // expandArgs substitutes %{ENV_VAR} placeholders inside args
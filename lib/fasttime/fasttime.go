@@ -1,23 +1,47 @@
 package fasttime
 
 import (
+	"flag"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
+var unixMilliResolution = flag.Duration("fasttime.unixMilliResolution", 20*time.Millisecond, "The resolution for millisecond timestamps used by rate limiters "+
+	"such as -remoteWrite.rateLimit. Smaller values improve rate limiting precision for short bursts at the cost of slightly higher CPU usage. "+
+	"The resolution must be in the range [1ms ... 1s]")
+
 func init() {
-	go func() {
-		ticker := time.NewTicker(time.Second)
-		defer ticker.Stop()
-		for tm := range ticker.C {
-			t := uint64(tm.Unix())
-			atomic.StoreUint64(&currentTimestamp, t)
+	go timestampsUpdater()
+}
+
+func timestampsUpdater() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case d := <-tickerIntervalCh:
+			ticker.Reset(d)
+		case tm := <-ticker.C:
+			storeTimestamps(tm)
 		}
-	}()
+	}
+}
+
+func storeTimestamps(tm time.Time) {
+	atomic.StoreUint64(&currentTimestamp, uint64(tm.Unix()))
+	atomic.StoreUint64(&currentUnixMilli, uint64(tm.UnixMilli()))
 }
 
 var currentTimestamp = uint64(time.Now().Unix())
 
+var currentUnixMilli = uint64(time.Now().UnixMilli())
+
+// tickerIntervalCh is used for switching timestampsUpdater to -fasttime.unixMilliResolution on the first UnixMilli call.
+var tickerIntervalCh = make(chan time.Duration, 1)
+
+var unixMilliOnce sync.Once
+
 // UnixTimestamp returns the current unix timestamp in seconds.
 //
 // It is faster than time.Now().Unix()
@@ -25,6 +49,29 @@ func UnixTimestamp() uint64 {
 	return atomic.LoadUint64(&currentTimestamp)
 }
 
+// UnixMilli returns the current unix timestamp in milliseconds.
+//
+// The timestamp is updated every -fasttime.unixMilliResolution after the first call to UnixMilli,
+// so the background updater doesn't wake up frequently in apps, which do not need UnixMilli.
+//
+// It is faster than time.Now().UnixMilli()
+func UnixMilli() uint64 {
+	unixMilliOnce.Do(startUnixMilliUpdates)
+	return atomic.LoadUint64(&currentUnixMilli)
+}
+
+func startUnixMilliUpdates() {
+	d := *unixMilliResolution
+	if d < time.Millisecond {
+		d = time.Millisecond
+	}
+	if d > time.Second {
+		d = time.Second
+	}
+	storeTimestamps(time.Now())
+	tickerIntervalCh <- d
+}
+
 // UnixDate returns date from the current unix timestamp.
 //
 // The date is calculated by dividing unix timestamp by (24*3600)
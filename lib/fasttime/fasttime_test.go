@@ -13,6 +13,26 @@ func TestUnixTimestamp(t *testing.T) {
 	}
 }
 
+func TestUnixMilli(t *testing.T) {
+	msecsExpected := uint64(time.Now().UnixMilli())
+	msecs := UnixMilli()
+	if msecs+1000 < msecsExpected || msecs > msecsExpected+1000 {
+		t.Fatalf("unexpected UnixMilli; got %d; want %d", msecs, msecsExpected)
+	}
+
+	// Verify that the timestamp is updated with the sub-second resolution.
+	deadline := time.Now().Add(5 * time.Second)
+	for UnixMilli() == msecs {
+		if time.Now().After(deadline) {
+			t.Fatalf("UnixMilli isn't updated during 5 seconds")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if d := UnixMilli() - msecs; d >= 1000 {
+		t.Fatalf("too big UnixMilli update interval: %dms; want less than 1000ms", d)
+	}
+}
+
 func TestUnixDate(t *testing.T) {
 	dateExpected := uint64(time.Now().Unix() / (24 * 3600))
 	date := UnixDate()
@@ -28,5 +28,27 @@ func BenchmarkTimeNowUnix(b *testing.B) {
 	})
 }
 
+func BenchmarkUnixMilli(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		var ts uint64
+		for pb.Next() {
+			ts += UnixMilli()
+		}
+		atomic.StoreUint64(&Sink, ts)
+	})
+}
+
+func BenchmarkTimeNowUnixMilli(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		var ts uint64
+		for pb.Next() {
+			ts += uint64(time.Now().UnixMilli())
+		}
+		atomic.StoreUint64(&Sink, ts)
+	})
+}
+
 // Sink should prevent from code elimination by optimizing compiler
 var Sink uint64
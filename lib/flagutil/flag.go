@@ -7,14 +7,34 @@ import (
 	"strings"
 )
 
-// WriteFlags writes all the explicitly set flags to w.
+// WriteFlags writes all the explicitly set flags to w together with their sources.
+//
+// See FlagSource* constants for possible sources.
 func WriteFlags(w io.Writer) {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
 	flag.Visit(func(f *flag.Flag) {
-		lname := strings.ToLower(f.Name)
-		value := f.Value.String()
-		if IsSecretFlag(lname) {
-			value = "secret"
-		}
-		fmt.Fprintf(w, "-%s=%q\n", f.Name, value)
+		fmt.Fprintf(w, "-%s=%q # source=%s\n", f.Name, getFlagValue(f), getFlagSourceLocked(f.Name))
 	})
 }
+
+// GetFlagValues returns the effective values for all the registered flags, including flags with default values.
+//
+// Values for secret flags are replaced with "secret" in the same way as WriteFlags does.
+func GetFlagValues() map[string]string {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+	m := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		m[f.Name] = getFlagValue(f)
+	})
+	return m
+}
+
+func getFlagValue(f *flag.Flag) string {
+	lname := strings.ToLower(f.Name)
+	if IsSecretFlag(lname) {
+		return "secret"
+	}
+	return f.Value.String()
+}
@@ -0,0 +1,28 @@
+package flagutil
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestGetFlagValues(t *testing.T) {
+	_ = flag.String("flagutil.testFlag", "foo", "test flag")
+	_ = flag.String("flagutil.testPassword", "bar", "test secret flag")
+	_ = flag.String("flagutil.testRegisteredSecret", "baz", "test registered secret flag")
+	RegisterSecretFlag("flagutil.testRegisteredSecret")
+
+	m := GetFlagValues()
+	f := func(name, valueExpected string) {
+		t.Helper()
+		value, ok := m[name]
+		if !ok {
+			t.Fatalf("missing flag %q", name)
+		}
+		if value != valueExpected {
+			t.Fatalf("unexpected value for flag %q; got %q; want %q", name, value, valueExpected)
+		}
+	}
+	f("flagutil.testFlag", "foo")
+	f("flagutil.testPassword", "secret")
+	f("flagutil.testRegisteredSecret", "secret")
+}
@@ -0,0 +1,159 @@
+package flagutil
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Flag value sources returned by GetFlagSource.
+const (
+	// FlagSourceDefault means the flag has the default value.
+	FlagSourceDefault = "default"
+
+	// FlagSourceCommandLine means the flag value is set via command line.
+	FlagSourceCommandLine = "flag"
+
+	// FlagSourceEnv means the flag value is read from environment var.
+	FlagSourceEnv = "env"
+
+	// FlagSourceConfigFile means the flag value is read from -configFile.
+	FlagSourceConfigFile = "configFile"
+)
+
+// flagsMu protects flag values from concurrent updates by ApplyFlagValues and reads by WriteFlags and GetFlagValues.
+var flagsMu sync.Mutex
+
+var (
+	flagSources     = make(map[string]string)
+	reloadableFlags = make(map[string]func())
+)
+
+// RegisterReloadable marks the flag with the given name as reloadable without the restart.
+//
+// The optional apply callback is called after the flag value is updated by ApplyFlagValues.
+// The callback must propagate the new value to the code, which uses the flag,
+// since the code mustn't read reloadable flag values directly in order to avoid data races.
+//
+// All the flags, which aren't registered via RegisterReloadable, require restart in order to apply their new values.
+func RegisterReloadable(name string, apply func()) {
+	if apply == nil {
+		apply = func() {}
+	}
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+	if _, ok := reloadableFlags[name]; ok {
+		panic(fmt.Errorf("BUG: flag -%s is already registered as reloadable", name))
+	}
+	reloadableFlags[name] = apply
+}
+
+// IsReloadable returns true if the flag with the given name is registered via RegisterReloadable.
+func IsReloadable(name string) bool {
+	flagsMu.Lock()
+	_, ok := reloadableFlags[name]
+	flagsMu.Unlock()
+	return ok
+}
+
+// SetFlagSource sets the source for the flag with the given name.
+//
+// See FlagSource* constants for possible sources.
+func SetFlagSource(name, source string) {
+	flagsMu.Lock()
+	flagSources[name] = source
+	flagsMu.Unlock()
+}
+
+// GetFlagSource returns the source for the flag with the given name.
+//
+// FlagSourceDefault is returned if the source isn't set via SetFlagSource.
+func GetFlagSource(name string) string {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+	return getFlagSourceLocked(name)
+}
+
+func getFlagSourceLocked(name string) string {
+	source, ok := flagSources[name]
+	if !ok {
+		return FlagSourceDefault
+	}
+	return source
+}
+
+// ApplyFlagValues atomically applies values to the flags at fs and then calls apply callbacks registered via RegisterReloadable for the updated flags.
+//
+// values must contain flag names as keys. nil value resets the flag to its default value.
+// All the flags from values must be registered via RegisterReloadable.
+// The values are applied with the given source. See FlagSource* constants.
+//
+// No flags are updated if at least a single value cannot be applied.
+func ApplyFlagValues(fs *flag.FlagSet, values map[string][]string, source string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Validate all the values before applying them, so either all the values are applied or none of them.
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("unknown flag -%s", name)
+		}
+		if !IsReloadable(name) {
+			return fmt.Errorf("flag -%s isn't reloadable", name)
+		}
+		a := values[name]
+		if a == nil {
+			a = []string{f.DefValue}
+		}
+		v := newFlagValue(f)
+		for _, s := range a {
+			if err := v.Set(s); err != nil {
+				return fmt.Errorf("cannot set -%s=%q: %w", name, s, err)
+			}
+		}
+	}
+
+	flagsMu.Lock()
+	applyFuncs := make([]func(), 0, len(names))
+	for _, name := range names {
+		f := fs.Lookup(name)
+		a := values[name]
+		if a == nil {
+			mustSetFlagValue(fs, f.Name, f.DefValue)
+			delete(flagSources, name)
+		} else {
+			for _, s := range a {
+				mustSetFlagValue(fs, f.Name, s)
+			}
+			flagSources[name] = source
+		}
+		applyFuncs = append(applyFuncs, reloadableFlags[name])
+	}
+	flagsMu.Unlock()
+
+	for _, apply := range applyFuncs {
+		apply()
+	}
+	return nil
+}
+
+// newFlagValue returns new zero value with the same type as f.Value.
+func newFlagValue(f *flag.Flag) flag.Value {
+	t := reflect.TypeOf(f.Value)
+	if t.Kind() != reflect.Ptr {
+		panic(fmt.Errorf("BUG: unexpected type for -%s value: %s; want pointer", f.Name, t))
+	}
+	return reflect.New(t.Elem()).Interface().(flag.Value)
+}
+
+func mustSetFlagValue(fs *flag.FlagSet, name, s string) {
+	if err := fs.Set(name, s); err != nil {
+		panic(fmt.Errorf("BUG: cannot set the already validated value -%s=%q: %w", name, s, err))
+	}
+}
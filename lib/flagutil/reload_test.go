@@ -0,0 +1,85 @@
+package flagutil
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestApplyFlagValues(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	maxRequests := fs.Int("flagutil.testReloadableInt", 10, "test reloadable flag")
+	timeout := fs.Duration("flagutil.testReloadableDuration", time.Second, "test reloadable flag")
+	_ = fs.String("flagutil.testNonReloadable", "foo", "test non-reloadable flag")
+
+	var applyCalls int
+	var maxRequestsApplied int
+	RegisterReloadable("flagutil.testReloadableInt", func() {
+		applyCalls++
+		maxRequestsApplied = *maxRequests
+	})
+	RegisterReloadable("flagutil.testReloadableDuration", nil)
+	if !IsReloadable("flagutil.testReloadableInt") {
+		t.Fatalf("flagutil.testReloadableInt must be reloadable")
+	}
+	if IsReloadable("flagutil.testNonReloadable") {
+		t.Fatalf("flagutil.testNonReloadable mustn't be reloadable")
+	}
+
+	f := func(values map[string][]string, maxRequestsExpected int, timeoutExpected time.Duration, source string) {
+		t.Helper()
+		if err := ApplyFlagValues(fs, values, FlagSourceConfigFile); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if *maxRequests != maxRequestsExpected {
+			t.Fatalf("unexpected flag value; got %d; want %d", *maxRequests, maxRequestsExpected)
+		}
+		if maxRequestsApplied != maxRequestsExpected {
+			t.Fatalf("unexpected applied flag value; got %d; want %d", maxRequestsApplied, maxRequestsExpected)
+		}
+		if *timeout != timeoutExpected {
+			t.Fatalf("unexpected flag value; got %s; want %s", *timeout, timeoutExpected)
+		}
+		if s := GetFlagSource("flagutil.testReloadableInt"); s != source {
+			t.Fatalf("unexpected flag source; got %q; want %q", s, source)
+		}
+	}
+	f(map[string][]string{
+		"flagutil.testReloadableInt":      {"20"},
+		"flagutil.testReloadableDuration": {"5s"},
+	}, 20, 5*time.Second, FlagSourceConfigFile)
+	if applyCalls != 1 {
+		t.Fatalf("unexpected number of apply calls; got %d; want 1", applyCalls)
+	}
+
+	// Reset to default value
+	f(map[string][]string{
+		"flagutil.testReloadableInt": nil,
+	}, 10, 5*time.Second, FlagSourceDefault)
+
+	// Invalid values mustn't be applied
+	fe := func(values map[string][]string) {
+		t.Helper()
+		if err := ApplyFlagValues(fs, values, FlagSourceConfigFile); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if *maxRequests != 10 {
+			t.Fatalf("unexpected flag value after failed apply; got %d; want 10", *maxRequests)
+		}
+		if *timeout != 5*time.Second {
+			t.Fatalf("unexpected flag value after failed apply; got %s; want 5s", *timeout)
+		}
+	}
+	fe(map[string][]string{
+		"flagutil.testReloadableInt":      {"30"},
+		"flagutil.testReloadableDuration": {"foobar"},
+	})
+	fe(map[string][]string{
+		"flagutil.testReloadableInt": {"30"},
+		"flagutil.testNonReloadable": {"bar"},
+	})
+	fe(map[string][]string{
+		"flagutil.testReloadableInt": {"30"},
+		"flagutil.testMissingFlag":   {"bar"},
+	})
+}
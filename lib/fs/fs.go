@@ -418,7 +418,7 @@ type freeSpaceEntry struct {
 
 // ReadFileOrHTTP reads path either from local filesystem or from http if path starts with http or https.
 func ReadFileOrHTTP(path string) ([]byte, error) {
-	if isHTTPURL(path) {
+	if IsHTTPURL(path) {
 		// reads remote file via http or https, if url is given
 		resp, err := http.Get(path)
 		if err != nil {
@@ -446,14 +446,14 @@ func ReadFileOrHTTP(path string) ([]byte, error) {
 
 // GetFilepath returns full path to file for the given baseDir and path.
 func GetFilepath(baseDir, path string) string {
-	if filepath.IsAbs(path) || isHTTPURL(path) {
+	if filepath.IsAbs(path) || IsHTTPURL(path) {
 		return path
 	}
 	return filepath.Join(baseDir, path)
 }
 
-// isHTTPURL checks if a given targetURL is valid and contains a valid http scheme
-func isHTTPURL(targetURL string) bool {
+// IsHTTPURL checks if a given targetURL is valid and contains a valid http scheme
+func IsHTTPURL(targetURL string) bool {
 	parsed, err := url.Parse(targetURL)
 	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
 
@@ -0,0 +1,161 @@
+package httpserver
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	detailedMetrics = flag.Bool("http.detailedMetrics", false, "Whether to expose vm_http_request_duration_seconds, vm_http_request_size_bytes "+
+		"and vm_http_response_size_bytes histograms per each path prefix served by the component. "+
+		"Requests to unknown paths are exposed with path=\"other\" label")
+	logSlowRequestDuration = flag.Duration("http.logSlowRequestDuration", 0, "Requests taking longer than the given duration are logged together with method, path, "+
+		"remote address and duration. Slow requests aren't logged if this flag is set to 0. See also -search.logSlowQueryDuration")
+)
+
+// WithDetailedMetrics returns RequestHandler, which collects detailed metrics for requests served by rh if -http.detailedMetrics is set.
+//
+// The metrics are labeled by the longest matching prefix from pathPrefixes, so the number of exposed series remains bounded.
+// Requests, which don't match any of pathPrefixes, are labeled with path="other".
+//
+// rh is returned as is if -http.detailedMetrics isn't set.
+func WithDetailedMetrics(rh RequestHandler, pathPrefixes []string) RequestHandler {
+	if !*detailedMetrics {
+		return rh
+	}
+	prefixes := append([]string{}, pathPrefixes...)
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		startTime := time.Now()
+		rc := &countingReadCloser{
+			rc: r.Body,
+		}
+		if r.Body != nil {
+			r.Body = rc
+		}
+		cw := &countingResponseWriter{
+			w: w,
+		}
+		if !rh(cw, r) {
+			return false
+		}
+		pm := getPathMetrics(normalizePath(prefixes, r.URL.Path))
+		pm.requestDuration.UpdateDuration(startTime)
+		pm.requestSize.Update(float64(rc.n))
+		pm.responseSize.Update(float64(cw.n))
+		return true
+	}
+}
+
+// normalizePath returns the longest prefix from prefixes matching the given path.
+//
+// prefixes must be sorted by length in descending order.
+func normalizePath(prefixes []string, path string) string {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return prefix
+		}
+	}
+	return "other"
+}
+
+type pathMetrics struct {
+	requestDuration *metrics.Histogram
+	requestSize     *metrics.Histogram
+	responseSize    *metrics.Histogram
+}
+
+var pathMetricsMap sync.Map
+
+func getPathMetrics(path string) *pathMetrics {
+	if v, ok := pathMetricsMap.Load(path); ok {
+		return v.(*pathMetrics)
+	}
+	pm := &pathMetrics{
+		requestDuration: metrics.GetOrCreateHistogram(fmt.Sprintf(`vm_http_request_duration_seconds{path=%q}`, path)),
+		requestSize:     metrics.GetOrCreateHistogram(fmt.Sprintf(`vm_http_request_size_bytes{path=%q}`, path)),
+		responseSize:    metrics.GetOrCreateHistogram(fmt.Sprintf(`vm_http_response_size_bytes{path=%q}`, path)),
+	}
+	v, _ := pathMetricsMap.LoadOrStore(path, pm)
+	return v.(*pathMetrics)
+}
+
+type countingReadCloser struct {
+	rc io.ReadCloser
+	n  int64
+}
+
+func (crc *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := crc.rc.Read(p)
+	crc.n += int64(n)
+	return n, err
+}
+
+func (crc *countingReadCloser) Close() error {
+	return crc.rc.Close()
+}
+
+type countingResponseWriter struct {
+	w http.ResponseWriter
+	n int64
+}
+
+// Header implements http.ResponseWriter interface.
+func (cw *countingResponseWriter) Header() http.Header {
+	return cw.w.Header()
+}
+
+// WriteHeader implements http.ResponseWriter interface.
+func (cw *countingResponseWriter) WriteHeader(statusCode int) {
+	cw.w.WriteHeader(statusCode)
+}
+
+// Write implements http.ResponseWriter interface.
+func (cw *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher interface.
+func (cw *countingResponseWriter) Flush() {
+	if f, ok := cw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker interface.
+//
+// It is used for proxying connections upgraded to another protocol such as WebSocket.
+func (cw *countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T doesn't support hijacking", cw.w)
+	}
+	return hj.Hijack()
+}
+
+// logSlowRequest logs r if it takes longer than -http.logSlowRequestDuration since startTime.
+func logSlowRequest(r *http.Request, startTime time.Time) {
+	d := time.Since(startTime)
+	if d <= *logSlowRequestDuration {
+		return
+	}
+	slowRequests.Inc()
+	logger.Warnf("slow request: method=%s, path=%q, remoteAddr=%s, duration=%.3f seconds", r.Method, r.URL.Path, GetQuotedRemoteAddr(r), d.Seconds())
+}
+
+var slowRequests = metrics.NewCounter(`vm_http_slow_requests_total`)
@@ -0,0 +1,99 @@
+package httpserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func TestNormalizePath(t *testing.T) {
+	prefixes := []string{"/api/v1/import/csv", "/api/v1/import", "/api/v1/query_range", "/api/v1/query", "/datadog/"}
+	f := func(path, resultExpected string) {
+		t.Helper()
+		result := normalizePath(prefixes, path)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q; got %q; want %q", path, result, resultExpected)
+		}
+	}
+	f("/api/v1/import", "/api/v1/import")
+	f("/api/v1/import/csv", "/api/v1/import/csv")
+	f("/api/v1/import/native", "/api/v1/import")
+	f("/api/v1/query", "/api/v1/query")
+	f("/api/v1/query_range", "/api/v1/query_range")
+	f("/datadog/api/v1/series", "/datadog/")
+	f("/datadog", "other")
+	f("/foo/bar", "other")
+	f("", "other")
+}
+
+func TestWithDetailedMetrics(t *testing.T) {
+	origValue := *detailedMetrics
+	*detailedMetrics = true
+	defer func() {
+		*detailedMetrics = origValue
+	}()
+
+	rh := WithDetailedMetrics(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != "/api/v1/import" {
+			return false
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("cannot read request body: %s", err)
+		}
+		_, _ = w.Write(data[:3])
+		return true
+	}, []string{"/api/v1/import"})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/import", strings.NewReader("foobar"))
+	w := httptest.NewRecorder()
+	if !rh(w, r) {
+		t.Fatalf("the request must be served")
+	}
+	if w.Body.String() != "foo" {
+		t.Fatalf("unexpected response; got %q; want %q", w.Body.String(), "foo")
+	}
+	pm := getPathMetrics("/api/v1/import")
+	if n := histogramCount(pm.requestSize); n != 1 {
+		t.Fatalf("unexpected number of requests; got %d; want 1", n)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/foo", nil)
+	if rh(httptest.NewRecorder(), r) {
+		t.Fatalf("the request mustn't be served")
+	}
+	if n := histogramCount(pm.requestSize); n != 1 {
+		t.Fatalf("unexpected number of requests after unserved request; got %d; want 1", n)
+	}
+}
+
+func TestCountingResponseWriterInterfaces(t *testing.T) {
+	var w http.ResponseWriter = &countingResponseWriter{
+		w: httptest.NewRecorder(),
+	}
+	f, ok := w.(http.Flusher)
+	if !ok {
+		t.Fatalf("countingResponseWriter must implement http.Flusher")
+	}
+	f.Flush()
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		t.Fatalf("countingResponseWriter must implement http.Hijacker")
+	}
+	// httptest.ResponseRecorder doesn't support hijacking, so the error must be returned.
+	if _, _, err := hj.Hijack(); err == nil {
+		t.Fatalf("expecting non-nil error when hijacking unsupported response writer")
+	}
+}
+
+func histogramCount(h *metrics.Histogram) uint64 {
+	n := uint64(0)
+	h.VisitNonZeroBuckets(func(vmrange string, count uint64) {
+		n += count
+	})
+	return n
+}
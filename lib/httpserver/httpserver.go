@@ -37,6 +37,13 @@ var (
 	tlsMinVersion   = flag.String("tlsMinVersion", "", "Optional minimum TLS version to use for incoming requests over HTTPS if -tls is set. "+
 		"Supported values: TLS10, TLS11, TLS12, TLS13")
 
+	tlsAutocert = flag.Bool("tls.autocert", false, "Whether to obtain TLS certificates automatically from Let's Encrypt via ACME protocol if -tls is set. "+
+		"Certificates are obtained for -tls.autocertHosts and are stored at -tls.autocertCacheDir. -tlsCertFile takes precedence over this flag. "+
+		"See https://docs.victoriametrics.com/#automatic-tls-certificates")
+	tlsAutocertHosts    = flagutil.NewArrayString("tls.autocertHosts", "Hostnames to obtain TLS certificates for if -tls.autocert is set")
+	tlsAutocertCacheDir = flag.String("tls.autocertCacheDir", "", "Path to directory for storing TLS certificates obtained if -tls.autocert is set. "+
+		"The directory is preserved across restarts, so certificates aren't re-issued on every start")
+
 	pathPrefix = flag.String("http.pathPrefix", "", "An optional prefix to add to all the paths handled by http server. For example, if '-http.pathPrefix=/foo/bar' is set, "+
 		"then all the http requests will be handled on '/foo/bar/*' paths. This may be useful for proxied requests. "+
 		"See https://www.robustperception.io/using-external-urls-and-proxies-with-prometheus")
@@ -97,11 +104,22 @@ func Serve(addr string, useProxyProtocol bool, rh RequestHandler) {
 	logger.Infof("pprof handlers are exposed at %s://%s/debug/pprof/", scheme, hostAddr)
 	var tlsConfig *tls.Config
 	if *tlsEnable {
-		tc, err := netutil.GetServerTLSConfig(*tlsCertFile, *tlsKeyFile, *tlsMinVersion, *tlsCipherSuites)
-		if err != nil {
-			logger.Fatalf("cannot load TLS cert from -tlsCertFile=%q, -tlsKeyFile=%q, -tlsMinVersion=%q: %s", *tlsCertFile, *tlsKeyFile, *tlsMinVersion, err)
+		if *tlsAutocert && *tlsCertFile == "" {
+			tc, err := netutil.GetServerAutocertTLSConfig(*tlsAutocertHosts, *tlsAutocertCacheDir, *tlsMinVersion, *tlsCipherSuites)
+			if err != nil {
+				logger.Fatalf("cannot initialize automatic TLS certificates for -tls.autocertHosts=%q, -tls.autocertCacheDir=%q: %s", *tlsAutocertHosts, *tlsAutocertCacheDir, err)
+			}
+			tlsConfig = tc
+		} else {
+			if *tlsAutocert {
+				logger.Warnf("ignoring -tls.autocert, since -tlsCertFile=%q is set", *tlsCertFile)
+			}
+			tc, err := netutil.GetServerTLSConfig(*tlsCertFile, *tlsKeyFile, *tlsMinVersion, *tlsCipherSuites)
+			if err != nil {
+				logger.Fatalf("cannot load TLS cert from -tlsCertFile=%q, -tlsKeyFile=%q, -tlsMinVersion=%q: %s", *tlsCertFile, *tlsKeyFile, *tlsMinVersion, err)
+			}
+			tlsConfig = tc
 		}
-		tlsConfig = tc
 	}
 	ln, err := netutil.NewTCPListener(scheme, addr, useProxyProtocol, tlsConfig)
 	if err != nil {
@@ -238,6 +256,10 @@ func handlerWrapper(s *server, w http.ResponseWriter, r *http.Request, rh Reques
 		}
 	}()
 
+	if *logSlowRequestDuration > 0 {
+		defer logSlowRequest(r, time.Now())
+	}
+
 	w.Header().Add("X-Server-Hostname", hostname)
 	requestsTotal.Inc()
 	if whetherToCloseConn(r) {
@@ -307,7 +329,7 @@ func handlerWrapper(s *server, w http.ResponseWriter, r *http.Request, rh Reques
 		metricsHandlerDuration.UpdateDuration(startTime)
 		return
 	case "/flags":
-		if !CheckAuthFlag(w, r, *flagsAuthKey, "flagsAuthKey") {
+		if !CheckFlagsAuthKey(w, r) {
 			return
 		}
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -318,10 +340,13 @@ func handlerWrapper(s *server, w http.ResponseWriter, r *http.Request, rh Reques
 		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1833
 		fmt.Fprintf(w, "VictoriaMetrics is Healthy.\n")
 		return
+	case "/ready":
+		handleReady(s, w, "OK")
+		return
 	case "/-/ready":
 		// This is needed for Prometheus compatibility
 		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1833
-		fmt.Fprintf(w, "VictoriaMetrics is Ready.\n")
+		handleReady(s, w, "VictoriaMetrics is Ready.\n")
 		return
 	default:
 		if strings.HasPrefix(r.URL.Path, "/debug/pprof/") {
@@ -360,6 +385,13 @@ func CheckAuthFlag(w http.ResponseWriter, r *http.Request, flagValue string, fla
 	return true
 }
 
+// CheckFlagsAuthKey checks -flagsAuthKey for the request to the page exposing command-line flags.
+//
+// Falls back to checkBasicAuth if -flagsAuthKey is not set
+func CheckFlagsAuthKey(w http.ResponseWriter, r *http.Request) bool {
+	return CheckAuthFlag(w, r, *flagsAuthKey, "flagsAuthKey")
+}
+
 // CheckBasicAuth validates credentials provided in request if httpAuth.* flags are set
 // returns true if credentials are valid or httpAuth.* flags are not set
 func CheckBasicAuth(w http.ResponseWriter, r *http.Request) bool {
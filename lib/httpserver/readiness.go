@@ -0,0 +1,79 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RegisterReadinessCheck registers readiness check with the given name for /ready page.
+//
+// check must return nil if the component is ready to serve requests.
+// Otherwise it must return an error describing the condition, which isn't satisfied yet.
+// check may be called concurrently from multiple goroutines.
+// The previously registered check with the same name is replaced by the new one.
+//
+// /ready page returns non-OK response until all the registered checks return nil.
+// /health page isn't affected by the registered checks, so it can be used as liveness probe.
+func RegisterReadinessCheck(name string, check func() error) {
+	readinessChecksLock.Lock()
+	defer readinessChecksLock.Unlock()
+
+	for i := range readinessChecks {
+		if readinessChecks[i].name == name {
+			readinessChecks[i].check = check
+			return
+		}
+	}
+	readinessChecks = append(readinessChecks, readinessCheck{
+		name:  name,
+		check: check,
+	})
+}
+
+type readinessCheck struct {
+	name  string
+	check func() error
+}
+
+var (
+	readinessChecksLock sync.Mutex
+	readinessChecks     []readinessCheck
+)
+
+// getUnsatisfiedReadinessChecks returns human-readable descriptions for the registered readiness checks, which aren't satisfied.
+func getUnsatisfiedReadinessChecks() []string {
+	readinessChecksLock.Lock()
+	rcs := append([]readinessCheck{}, readinessChecks...)
+	readinessChecksLock.Unlock()
+
+	var errs []string
+	for _, rc := range rcs {
+		if err := rc.check(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", rc.name, err))
+		}
+	}
+	return errs
+}
+
+func handleReady(s *server, w http.ResponseWriter, okMsg string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	var errs []string
+	if deadline := atomic.LoadInt64(&s.shutdownDelayDeadline); deadline > 0 {
+		d := time.Until(time.Unix(0, deadline))
+		if d < 0 {
+			d = 0
+		}
+		errs = append(errs, fmt.Sprintf("the server is in delayed shutdown mode, which will end in %.3fs", d.Seconds()))
+	}
+	errs = append(errs, getUnsatisfiedReadinessChecks()...)
+	if len(errs) == 0 {
+		w.Write([]byte(okMsg))
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintf(w, "not ready:\n%s\n", strings.Join(errs, "\n"))
+}
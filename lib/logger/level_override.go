@@ -0,0 +1,143 @@
+package logger
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+)
+
+var loggerLevelOverride = flagutil.NewArrayString("loggerLevelOverride", "Optional overrides for -loggerLevel per module in the form module=level, "+
+	"where module is the package path prefix relative to the VictoriaMetrics repository root. "+
+	"For example, -loggerLevel=WARN -loggerLevelOverride=lib/promscrape=INFO,lib/storage=ERROR logs info messages only from lib/promscrape "+
+	"and its subpackages, while suppressing warnings from lib/storage. Possible levels: INFO, WARN, ERROR, FATAL, PANIC")
+
+// levelOverride contains the minimum log level for all the packages starting with the given module prefix.
+type levelOverride struct {
+	module string
+	level  string
+}
+
+// levelOverrides contains overrides from -loggerLevelOverride sorted by module length in descending order,
+// so the most specific module is matched first.
+//
+// levelOverrides must be initialized only once at Init.
+var levelOverrides []levelOverride
+
+// callSiteLevels caches the minimum log level per each call site program counter,
+// so the caller package is determined only after the first log call at the given call site.
+var callSiteLevels sync.Map
+
+func initLevelOverrides() {
+	los, err := parseLevelOverrides(*loggerLevelOverride)
+	if err != nil {
+		// We cannot use logger.Panicf here, since the logger isn't initialized yet.
+		panic(fmt.Errorf("FATAL: cannot parse -loggerLevelOverride: %w", err))
+	}
+	levelOverrides = los
+}
+
+func parseLevelOverrides(a []string) ([]levelOverride, error) {
+	var los []levelOverride
+	seen := make(map[string]bool, len(a))
+	for _, s := range a {
+		n := strings.IndexByte(s, '=')
+		if n < 0 {
+			return nil, fmt.Errorf("missing '=' in %q; it must have the form module=level", s)
+		}
+		module := strings.Trim(strings.TrimSpace(s[:n]), "/")
+		level := strings.TrimSpace(s[n+1:])
+		if module == "" {
+			return nil, fmt.Errorf("missing module in %q; it must have the form module=level", s)
+		}
+		if !isValidLevel(level) {
+			return nil, fmt.Errorf("unsupported level %q for module %q; supported values are: INFO, WARN, ERROR, FATAL, PANIC", level, module)
+		}
+		if seen[module] {
+			return nil, fmt.Errorf("duplicate module %q", module)
+		}
+		seen[module] = true
+		los = append(los, levelOverride{
+			module: module,
+			level:  level,
+		})
+	}
+	sort.SliceStable(los, func(i, j int) bool {
+		return len(los[i].module) > len(los[j].module)
+	})
+	return los, nil
+}
+
+func isValidLevel(level string) bool {
+	switch level {
+	case "INFO", "WARN", "ERROR", "FATAL", "PANIC":
+		return true
+	default:
+		return false
+	}
+}
+
+func logLevelOverrides() {
+	if len(levelOverrides) == 0 {
+		return
+	}
+	a := make([]string, len(levelOverrides))
+	for i, lo := range levelOverrides {
+		a[i] = lo.module + "=" + lo.level
+	}
+	sort.Strings(a)
+	Infof("effective log level overrides: %s; the remaining modules use -loggerLevel=%s", strings.Join(a, ","), *loggerLevel)
+}
+
+// getCallerMinLevel returns the minimum log level for the caller located at the given skipframes.
+func getCallerMinLevel(skipframes int) string {
+	var pcs [1]uintptr
+	if runtime.Callers(skipframes+1, pcs[:]) == 0 {
+		return *loggerLevel
+	}
+	pc := pcs[0]
+	if v, ok := callSiteLevels.Load(pc); ok {
+		return v.(string)
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	level := getModuleMinLevel(levelOverrides, getCallerPackage(frame.Function, frame.File), *loggerLevel)
+	callSiteLevels.Store(pc, level)
+	return level
+}
+
+// getCallerPackage returns package path relative to the VictoriaMetrics repository root for the given fully qualified funcName located at file.
+//
+// For example, it returns lib/storage for github.com/VictoriaMetrics/VictoriaMetrics/lib/storage.(*partition).mergeParts
+func getCallerPackage(funcName, file string) string {
+	pkg := funcName
+	n := strings.LastIndexByte(pkg, '/')
+	if m := strings.IndexByte(pkg[n+1:], '.'); m >= 0 {
+		pkg = pkg[:n+1+m]
+	}
+	if pkg == "main" {
+		// Function names for main packages do not contain package path.
+		// All the main packages are located under app/ dir in VictoriaMetrics repository, so obtain the package path from file.
+		dir := path.Dir(file)
+		if n := strings.LastIndex(dir, "/app/"); n >= 0 {
+			return dir[n+1:]
+		}
+		return pkg
+	}
+	return strings.TrimPrefix(pkg, "github.com/VictoriaMetrics/VictoriaMetrics/")
+}
+
+func getModuleMinLevel(los []levelOverride, pkg, defaultLevel string) string {
+	for _, lo := range los {
+		if !strings.HasPrefix(pkg, lo.module) {
+			continue
+		}
+		if len(pkg) == len(lo.module) || pkg[len(lo.module)] == '/' {
+			return lo.level
+		}
+	}
+	return defaultLevel
+}
@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLevelOverridesSuccess(t *testing.T) {
+	f := func(a []string, losExpected []levelOverride) {
+		t.Helper()
+		los, err := parseLevelOverrides(a)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(los, losExpected) {
+			t.Fatalf("unexpected overrides; got %v; want %v", los, losExpected)
+		}
+	}
+	f(nil, nil)
+	f([]string{"lib/storage=WARN"}, []levelOverride{
+		{module: "lib/storage", level: "WARN"},
+	})
+	f([]string{"lib/promscrape=INFO", "/lib/promscrape/discovery/kubernetes/ = ERROR", "app=PANIC"}, []levelOverride{
+		{module: "lib/promscrape/discovery/kubernetes", level: "ERROR"},
+		{module: "lib/promscrape", level: "INFO"},
+		{module: "app", level: "PANIC"},
+	})
+}
+
+func TestParseLevelOverridesFailure(t *testing.T) {
+	f := func(a []string) {
+		t.Helper()
+		if _, err := parseLevelOverrides(a); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f([]string{"lib/storage"})
+	f([]string{"=INFO"})
+	f([]string{"lib/storage="})
+	f([]string{"lib/storage=DEBUG"})
+	f([]string{"lib/storage=info"})
+	f([]string{"lib/storage=INFO", "lib/storage/=WARN"})
+}
+
+func TestGetCallerPackage(t *testing.T) {
+	f := func(funcName, file, pkgExpected string) {
+		t.Helper()
+		pkg := getCallerPackage(funcName, file)
+		if pkg != pkgExpected {
+			t.Fatalf("unexpected package for %q; got %q; want %q", funcName, pkg, pkgExpected)
+		}
+	}
+	f("", "", "")
+	f("main.main", "???", "main")
+	f("main.main", "/home/user/VictoriaMetrics/app/vmagent/main.go", "app/vmagent")
+	f("main.main", "/app/victoria-metrics/main.go", "app/victoria-metrics")
+	f("github.com/VictoriaMetrics/VictoriaMetrics/lib/storage.(*partition).mergeParts", "/VictoriaMetrics/lib/storage/partition.go", "lib/storage")
+	f("github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kubernetes.newAPIWatcher.func1", "", "lib/promscrape/discovery/kubernetes")
+	f("github.com/VictoriaMetrics/metrics.(*Set).WritePrometheus", "", "github.com/VictoriaMetrics/metrics")
+}
+
+func TestGetModuleMinLevel(t *testing.T) {
+	los, err := parseLevelOverrides([]string{"lib/promscrape=INFO", "lib/promscrape/discovery/kubernetes=ERROR", "lib/storage=WARN"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := func(pkg, levelExpected string) {
+		t.Helper()
+		level := getModuleMinLevel(los, pkg, "FATAL")
+		if level != levelExpected {
+			t.Fatalf("unexpected level for %q; got %q; want %q", pkg, level, levelExpected)
+		}
+	}
+	f("lib/promscrape", "INFO")
+	f("lib/promscrape/discovery/consul", "INFO")
+	f("lib/promscrape/discovery/kubernetes", "ERROR")
+	f("lib/promscrapefoo", "FATAL")
+	f("lib/storage", "WARN")
+	f("lib/mergeset", "FATAL")
+	f("app/vmagent", "FATAL")
+}
+
+func TestLevelOverrides(t *testing.T) {
+	loggerLevelOrig := *loggerLevel
+	levelOverridesOrig := levelOverrides
+	defer func() {
+		*loggerLevel = loggerLevelOrig
+		levelOverrides = levelOverridesOrig
+		ResetOutputForTest()
+	}()
+	var bb bytes.Buffer
+	SetOutputForTests(&bb)
+
+	*loggerLevel = "ERROR"
+	los, err := parseLevelOverrides([]string{"lib/logger=INFO"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	levelOverrides = los
+	for i := 0; i < 3; i++ {
+		Infof("info message from lib/logger")
+	}
+	if n := strings.Count(bb.String(), "info message from lib/logger"); n != 3 {
+		t.Fatalf("unexpected number of logged info messages; got %d; want 3; output:\n%s", n, bb.String())
+	}
+
+	bb.Reset()
+	los, err = parseLevelOverrides([]string{"lib/storage=INFO"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	levelOverrides = los
+	Infof("info message from lib/logger")
+	if s := bb.String(); s != "" {
+		t.Fatalf("unexpected output for info message, which must be suppressed by -loggerLevel=ERROR: %q", s)
+	}
+}
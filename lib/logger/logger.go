@@ -37,10 +37,12 @@ func Init() {
 	setLoggerJSONFields()
 	setLoggerOutput()
 	validateLoggerLevel()
+	initLevelOverrides()
 	validateLoggerFormat()
 	initTimezone()
 	go logLimiterCleaner()
 	logAllFlags()
+	logLevelOverrides()
 }
 
 func initTimezone() {
@@ -131,7 +133,11 @@ func logLevel(level, format string, args ...interface{}) {
 }
 
 func logLevelSkipframes(skipframes int, level, format string, args ...interface{}) {
-	if shouldSkipLog(level) {
+	minLevel := *loggerLevel
+	if len(levelOverrides) > 0 {
+		minLevel = getCallerMinLevel(3 + skipframes)
+	}
+	if shouldSkipLog(minLevel, level) {
 		return
 	}
 	msg := fmt.Sprintf(format, args...)
@@ -286,8 +292,8 @@ func logMessage(level, msg string, skipframes int) {
 
 var mu sync.Mutex
 
-func shouldSkipLog(level string) bool {
-	switch *loggerLevel {
+func shouldSkipLog(minLevel, level string) bool {
+	switch minLevel {
 	case "WARN":
 		switch level {
 		case "WARN", "ERROR", "FATAL", "PANIC":
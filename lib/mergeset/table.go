@@ -186,6 +186,18 @@ func (riss *rawItemsShards) addItems(tb *Table, items [][]byte) {
 	}
 }
 
+// getMaxPossibleDataLossSeconds returns the age in seconds of the oldest items in riss at currentTime.
+func (riss *rawItemsShards) getMaxPossibleDataLossSeconds(currentTime uint64) uint64 {
+	n := uint64(0)
+	for i := range riss.shards {
+		oldestItemAddTime := atomic.LoadUint64(&riss.shards[i].oldestItemAddTime)
+		if oldestItemAddTime > 0 && oldestItemAddTime < currentTime && currentTime-oldestItemAddTime > n {
+			n = currentTime - oldestItemAddTime
+		}
+	}
+	return n
+}
+
 func (riss *rawItemsShards) Len() int {
 	n := 0
 	for i := range riss.shards {
@@ -195,9 +207,12 @@ func (riss *rawItemsShards) Len() int {
 }
 
 type rawItemsShardNopad struct {
-	// Put lastFlushTime to the top in order to avoid unaligned memory access on 32-bit architectures
+	// Put lastFlushTime and oldestItemAddTime to the top in order to avoid unaligned memory access on 32-bit architectures
 	lastFlushTime uint64
 
+	// oldestItemAddTime is the unix timestamp when the oldest item in ibs was added. It is 0 if ibs is empty.
+	oldestItemAddTime uint64
+
 	mu  sync.Mutex
 	ibs []*inmemoryBlock
 }
@@ -231,6 +246,9 @@ func (ris *rawItemsShard) addItems(tb *Table, items [][]byte) [][]byte {
 		ibs = append(ibs, ib)
 		ris.ibs = ibs
 	}
+	if len(items) > 0 && atomic.LoadUint64(&ris.oldestItemAddTime) == 0 {
+		atomic.StoreUint64(&ris.oldestItemAddTime, fasttime.UnixTimestamp())
+	}
 	ib := ibs[len(ibs)-1]
 	for i, item := range items {
 		if ib.Add(item) {
@@ -241,6 +259,7 @@ func (ris *rawItemsShard) addItems(tb *Table, items [][]byte) [][]byte {
 			ibs = make([]*inmemoryBlock, 0, maxBlocksPerShard)
 			tailItems = items[i:]
 			atomic.StoreUint64(&ris.lastFlushTime, fasttime.UnixTimestamp())
+			atomic.StoreUint64(&ris.oldestItemAddTime, 0)
 			break
 		}
 		ib = getInmemoryBlock()
@@ -486,6 +505,10 @@ type TableMetrics struct {
 	IndexBlocksCacheMisses       uint64
 
 	PartsRefCount uint64
+
+	// MaxPossibleDataLossSeconds is the age in seconds of the oldest added items, which aren't flushed to disk yet.
+	// These items may be lost on unclean shutdown.
+	MaxPossibleDataLossSeconds uint64
 }
 
 // TotalItemsCount returns the total number of items in the table.
@@ -512,6 +535,9 @@ func (tb *Table) UpdateMetrics(m *TableMetrics) {
 
 	m.PendingItems += uint64(tb.rawItems.Len())
 
+	currentTime := fasttime.UnixTimestamp()
+	maxDataLossSeconds := tb.rawItems.getMaxPossibleDataLossSeconds(currentTime)
+
 	tb.partsLock.Lock()
 
 	m.InmemoryPartsCount += uint64(len(tb.inmemoryParts))
@@ -521,6 +547,9 @@ func (tb *Table) UpdateMetrics(m *TableMetrics) {
 		m.InmemoryItemsCount += p.ph.itemsCount
 		m.InmemorySizeBytes += p.size
 		m.PartsRefCount += uint64(atomic.LoadUint32(&pw.refCount))
+		if n := pw.getMaxPossibleDataLossSeconds(currentTime); n > maxDataLossSeconds {
+			maxDataLossSeconds = n
+		}
 	}
 
 	m.FilePartsCount += uint64(len(tb.fileParts))
@@ -533,6 +562,10 @@ func (tb *Table) UpdateMetrics(m *TableMetrics) {
 	}
 	tb.partsLock.Unlock()
 
+	if maxDataLossSeconds > m.MaxPossibleDataLossSeconds {
+		m.MaxPossibleDataLossSeconds = maxDataLossSeconds
+	}
+
 	m.DataBlocksCacheSize = uint64(ibCache.Len())
 	m.DataBlocksCacheSizeBytes = uint64(ibCache.SizeBytes())
 	m.DataBlocksCacheSizeMaxBytes = uint64(ibCache.SizeMaxBytes())
@@ -718,6 +751,7 @@ func (ris *rawItemsShard) appendBlocksToFlush(dst []*inmemoryBlock, tb *Table, i
 	}
 	ris.ibs = ibs[:0]
 	atomic.StoreUint64(&ris.lastFlushTime, currentTime)
+	atomic.StoreUint64(&ris.oldestItemAddTime, 0)
 	ris.mu.Unlock()
 	return dst
 }
@@ -920,6 +954,17 @@ func (tb *Table) createInmemoryPart(ibs []*inmemoryBlock) *partWrapper {
 	return newPartWrapperFromInmemoryPart(mpDst, flushToDiskDeadline)
 }
 
+// getMaxPossibleDataLossSeconds returns the age in seconds of the oldest data in the in-memory part pw at currentTime.
+func (pw *partWrapper) getMaxPossibleDataLossSeconds(currentTime uint64) uint64 {
+	// The in-memory part is created dataFlushInterval before its flushToDiskDeadline.
+	// Merged in-memory parts inherit the earliest flushToDiskDeadline from the source parts.
+	createdAt := pw.flushToDiskDeadline.Add(-dataFlushInterval).Unix()
+	if createdAt < 0 || uint64(createdAt) >= currentTime {
+		return 0
+	}
+	return currentTime - uint64(createdAt)
+}
+
 func newPartWrapperFromInmemoryPart(mp *inmemoryPart, flushToDiskDeadline time.Time) *partWrapper {
 	p := mp.NewPart()
 	return &partWrapper{
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestTableOpenClose(t *testing.T) {
@@ -301,3 +302,59 @@ func testReopenTable(t *testing.T, path string, itemsCount int) {
 		tb.MustClose()
 	}
 }
+
+func TestRawItemsShardsGetMaxPossibleDataLossSeconds(t *testing.T) {
+	var riss rawItemsShards
+	riss.init()
+	f := func(currentTime, resultExpected uint64) {
+		t.Helper()
+		result := riss.getMaxPossibleDataLossSeconds(currentTime)
+		if result != resultExpected {
+			t.Fatalf("unexpected result at currentTime=%d; got %d; want %d", currentTime, result, resultExpected)
+		}
+	}
+
+	// There are no pending items
+	f(110, 0)
+
+	// Adding items must register the time of the oldest pending item
+	ris := &riss.shards[0]
+	ris.addItems(nil, [][]byte{[]byte("foo")})
+	if ris.oldestItemAddTime == 0 {
+		t.Fatalf("oldestItemAddTime must be set after adding items")
+	}
+	ris.oldestItemAddTime = 1
+	ris.addItems(nil, [][]byte{[]byte("bar")})
+	if ris.oldestItemAddTime != 1 {
+		t.Fatalf("oldestItemAddTime mustn't change when adding items to non-empty shard; got %d; want 1", ris.oldestItemAddTime)
+	}
+
+	// The age must be counted from the time the oldest pending item was added
+	ris.lastFlushTime = 10
+	ris.oldestItemAddTime = 100
+	f(100, 0)
+	f(110, 10)
+
+	// Flushed items mustn't be counted
+	var dst []*inmemoryBlock
+	for i := range riss.shards {
+		dst = riss.shards[i].appendBlocksToFlush(dst, nil, true)
+	}
+	f(110, 0)
+}
+
+func TestPartWrapperGetMaxPossibleDataLossSeconds(t *testing.T) {
+	f := func(createdAt, currentTime, resultExpected uint64) {
+		t.Helper()
+		pw := &partWrapper{
+			flushToDiskDeadline: time.Unix(int64(createdAt), 0).Add(dataFlushInterval),
+		}
+		result := pw.getMaxPossibleDataLossSeconds(currentTime)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for createdAt=%d, currentTime=%d; got %d; want %d", createdAt, currentTime, result, resultExpected)
+		}
+	}
+	f(100, 100, 0)
+	f(100, 90, 0)
+	f(100, 103, 3)
+}
@@ -1,10 +1,13 @@
 package netutil
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
@@ -45,35 +48,102 @@ func (ppc *proxyProtocolConn) RemoteAddr() net.Addr {
 	return ppc.remoteAddr
 }
 
+// readProxyProto reads proxy protocol v1 or v2 header from r and returns the real client address from the header.
+//
+// nil address is returned if the header doesn't contain the client address, e.g. for LOCAL command in v2 or UNKNOWN protocol in v1.
+// The caller must use the address of the connection in this case.
+//
+// See https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt
 func readProxyProto(r io.Reader) (net.Addr, error) {
 	bb := bbPool.Get()
 	defer bbPool.Put(bb)
 
+	// Read the first bytes of the header, which are enough for detecting the protocol version.
+	// Do not read more bytes, since v1 header may be shorter than v2 header.
+	bb.B = bytesutil.ResizeNoCopyMayOverallocate(bb.B, len(v1Prefix))
+	if _, err := io.ReadFull(r, bb.B); err != nil {
+		return nil, fmt.Errorf("cannot read proxy protocol header: %w", err)
+	}
+	if string(bb.B) == v1Prefix {
+		return readProxyProtoV1(r, bb)
+	}
+	return readProxyProtoV2(r, bb)
+}
+
+// readProxyProtoV1 reads the remaining part of proxy protocol v1 header after v1Prefix.
+func readProxyProtoV1(r io.Reader, bb *bytesutil.ByteBuffer) (net.Addr, error) {
+	// Read the header byte by byte in order to avoid reading the data after the header.
+	// The header is short, so this shouldn't be slow.
+	bb.B = bb.B[:0]
+	var buf [1]byte
+	for {
+		if len(bb.B) >= v1MaxHeaderLen-len(v1Prefix) {
+			return nil, fmt.Errorf("too long proxy protocol v1 header; it mustn't exceed %d bytes", v1MaxHeaderLen)
+		}
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, fmt.Errorf("cannot read proxy protocol v1 header: %w", err)
+		}
+		bb.B = append(bb.B, buf[0])
+		if bytes.HasSuffix(bb.B, []byte("\r\n")) {
+			break
+		}
+	}
+	line := string(bb.B[:len(bb.B)-2])
+	fields := strings.Split(line, " ")
+	switch fields[0] {
+	case "UNKNOWN":
+		// The real sender address should be used. The rest of the line must be ignored.
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q in proxy protocol v1 header %q; supported values: TCP4, TCP6, UNKNOWN", fields[0], line)
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("unexpected number of fields in proxy protocol v1 header %q; got %d; want 5", line, len(fields))
+	}
+	ip := net.ParseIP(fields[1])
+	if ip == nil || (fields[0] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("invalid source address %q for protocol %s in proxy protocol v1 header %q", fields[1], fields[0], line)
+	}
+	port, err := strconv.ParseUint(fields[3], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source port %q in proxy protocol v1 header %q: %w", fields[3], line, err)
+	}
+	if net.ParseIP(fields[2]) == nil {
+		return nil, fmt.Errorf("invalid destination address %q in proxy protocol v1 header %q", fields[2], line)
+	}
+	if _, err := strconv.ParseUint(fields[4], 10, 16); err != nil {
+		return nil, fmt.Errorf("invalid destination port %q in proxy protocol v1 header %q: %w", fields[4], line, err)
+	}
+	remoteAddr := &net.TCPAddr{
+		IP:   ip,
+		Port: int(port),
+	}
+	return remoteAddr, nil
+}
+
+// readProxyProtoV2 reads proxy protocol v2 header, which starts with the given bb.
+func readProxyProtoV2(r io.Reader, bb *bytesutil.ByteBuffer) (net.Addr, error) {
 	// Read the first 16 bytes of proxy protocol header:
 	// - bytes 0-11: v2Identifier
 	// - byte 12: version and command
 	// - byte 13: family and protocol
 	// - bytes 14-15: payload length
-	//
-	// See https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt
-	bb.B = bytesutil.ResizeNoCopyMayOverallocate(bb.B, 16)
-	if _, err := io.ReadFull(r, bb.B); err != nil {
+	n := len(bb.B)
+	bb.B = bytesutil.ResizeWithCopyMayOverallocate(bb.B, 16)
+	if _, err := io.ReadFull(r, bb.B[n:]); err != nil {
 		return nil, fmt.Errorf("cannot read proxy protocol header: %w", err)
 	}
 	ident := bb.B[:12]
 	if string(ident) != v2Identifier {
-		return nil, fmt.Errorf("unexpected proxy protocol header: %q; want %q", ident, v2Identifier)
+		return nil, fmt.Errorf("unexpected proxy protocol header: %q; want either %q for v1 or %q for v2", ident, v1Prefix, v2Identifier)
 	}
 	version := bb.B[12] >> 4
 	command := bb.B[12] & 0x0f
 	family := bb.B[13] >> 4
 	proto := bb.B[13] & 0x0f
 	if version != 2 {
-		return nil, fmt.Errorf("unsupported proxy protocol version, only v2 protocol version is supported, got: %d", version)
-	}
-	if proto != 1 {
-		// Only TCP is supported (aka STREAM).
-		return nil, fmt.Errorf("the proxy protocol implementation doesn't support proto %d; expecting 1", proto)
+		return nil, fmt.Errorf("unsupported proxy protocol version %d in v2 header; want 2", version)
 	}
 	// The length of the remainder of the header including any TLVs in network byte order
 	// 0, 1, 2
@@ -94,6 +164,14 @@ func readProxyProto(r io.Reader) (net.Addr, error) {
 		// Proxy LOCAL command. Ignore the protocol block. The real sender address should be used.
 		return nil, nil
 	case 1:
+		if family == 0 {
+			// AF_UNSPEC. The real sender address should be used.
+			return nil, nil
+		}
+		if proto != 1 {
+			// Only TCP is supported (aka STREAM).
+			return nil, fmt.Errorf("the proxy protocol implementation doesn't support proto %d; expecting 1", proto)
+		}
 		// Parse the protocol block according to the family.
 		switch family {
 		case 1:
@@ -112,7 +190,8 @@ func readProxyProto(r io.Reader) (net.Addr, error) {
 				return nil, fmt.Errorf("cannot read ipv6 address from proxy protocol block with the length %d bytes; expected at least 36 bytes", len(bb.B))
 			}
 			remoteAddr := &net.TCPAddr{
-				IP:   bb.B[0:16],
+				// Copy the IP, since bb is returned to the pool.
+				IP:   append(net.IP{}, bb.B[0:16]...),
 				Port: int(binary.BigEndian.Uint16(bb.B[32:34])),
 			}
 			return remoteAddr, nil
@@ -124,6 +203,14 @@ func readProxyProto(r io.Reader) (net.Addr, error) {
 	}
 }
 
-const v2Identifier = "\r\n\r\n\x00\r\nQUIT\n"
+const (
+	// v1Prefix is the prefix for proxy protocol v1 header.
+	v1Prefix = "PROXY "
+
+	// v1MaxHeaderLen is the maximum length of proxy protocol v1 header including the trailing CRLF.
+	v1MaxHeaderLen = 107
+
+	v2Identifier = "\r\n\r\n\x00\r\nQUIT\n"
+)
 
 var bbPool bytesutil.ByteBufferPool
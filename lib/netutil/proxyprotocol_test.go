@@ -5,6 +5,7 @@ import (
 	"io"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -52,6 +53,27 @@ func TestParseProxyProtocolSuccess(t *testing.T) {
 		// ports
 		0, 80, 0, 0}, nil,
 		&net.TCPAddr{IP: net.ParseIP("::1"), Port: 80})
+	// LOCAL command with AF_UNSPEC family
+	f([]byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A, 0x20, 0x00, 0x00, 0x00}, nil,
+		nil)
+	// PROXY command with AF_UNSPEC family
+	f([]byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A, 0x21, 0x00, 0x00, 0x00}, nil,
+		nil)
+
+	// v1 ipv4
+	f([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"), nil,
+		&net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 56324})
+	// v1 ipv4 with payload
+	f([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\nGET / HTTP/1.1\r\n"), []byte("GET / HTTP/1.1\r\n"),
+		&net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 56324})
+	// v1 ipv6
+	f([]byte("PROXY TCP6 ::1 ::2 56324 443\r\n"), nil,
+		&net.TCPAddr{IP: net.ParseIP("::1"), Port: 56324})
+	// v1 unknown protocol
+	f([]byte("PROXY UNKNOWN\r\nfoo"), []byte("foo"),
+		nil)
+	f([]byte("PROXY UNKNOWN ffff::1 ffff::2 65535 65535\r\n"), nil,
+		nil)
 }
 
 func TestParseProxyProtocolFail(t *testing.T) {
@@ -104,4 +126,24 @@ func TestParseProxyProtocolFail(t *testing.T) {
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
 		// ports
 		0, 80, 0, 0})
+
+	// v1 missing CRLF
+	f([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443"))
+	// v1 too long header
+	f([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443" + strings.Repeat(" ", 100) + "\r\n"))
+	// v1 unsupported protocol
+	f([]byte("PROXY UDP4 192.168.0.1 192.168.0.11 56324 443\r\n"))
+	// v1 missing fields
+	f([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324\r\n"))
+	// v1 invalid address
+	f([]byte("PROXY TCP4 foo 192.168.0.11 56324 443\r\n"))
+	f([]byte("PROXY TCP4 192.168.0.1 bar 56324 443\r\n"))
+	// v1 mismatch ipv6 and ipv4
+	f([]byte("PROXY TCP4 ::1 ::2 56324 443\r\n"))
+	f([]byte("PROXY TCP6 192.168.0.1 192.168.0.11 56324 443\r\n"))
+	// v1 invalid port
+	f([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 foo 443\r\n"))
+	f([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 65536\r\n"))
+	// plain http request
+	f([]byte("GET / HTTP/1.1\r\nHost: foo\r\n\r\n"))
 }
@@ -27,16 +27,15 @@ func NewTCPListener(name, addr string, useProxyProtocol bool, tlsConfig *tls.Con
 	if err != nil {
 		return nil, err
 	}
-	if tlsConfig != nil {
-		ln = tls.NewListener(ln, tlsConfig)
-	}
 	ms := metrics.GetDefaultSet()
 	tln := &TCPListener{
 		Listener:         ln,
 		useProxyProtocol: useProxyProtocol,
+		tlsConfig:        tlsConfig,
 
-		accepts:      ms.NewCounter(fmt.Sprintf(`vm_tcplistener_accepts_total{name=%q, addr=%q}`, name, addr)),
-		acceptErrors: ms.NewCounter(fmt.Sprintf(`vm_tcplistener_errors_total{name=%q, addr=%q, type="accept"}`, name, addr)),
+		accepts:             ms.NewCounter(fmt.Sprintf(`vm_tcplistener_accepts_total{name=%q, addr=%q}`, name, addr)),
+		acceptErrors:        ms.NewCounter(fmt.Sprintf(`vm_tcplistener_errors_total{name=%q, addr=%q, type="accept"}`, name, addr)),
+		proxyProtocolErrors: ms.NewCounter(fmt.Sprintf(`vm_tcplistener_errors_total{name=%q, addr=%q, type="proxy_protocol"}`, name, addr)),
 	}
 	tln.connMetrics.init(ms, "vm_tcplistener", name, addr)
 	return tln, err
@@ -71,11 +70,16 @@ func GetTCPNetwork() string {
 type TCPListener struct {
 	net.Listener
 
-	accepts      *metrics.Counter
-	acceptErrors *metrics.Counter
+	accepts             *metrics.Counter
+	acceptErrors        *metrics.Counter
+	proxyProtocolErrors *metrics.Counter
 
 	useProxyProtocol bool
 
+	// tlsConfig is applied to the accepted connections after reading proxy protocol header if useProxyProtocol is set,
+	// since proxy protocol header is sent before TLS handshake.
+	tlsConfig *tls.Config
+
 	connMetrics
 }
 
@@ -100,13 +104,17 @@ func (ln *TCPListener) Accept() (net.Conn, error) {
 			pConn, err := newProxyProtocolConn(conn)
 			if err != nil {
 				if !errors.Is(err, io.EOF) {
-					proxyProtocolReadErrorLogger.Errorf("cannot read proxy proto conn for TCP addr %q: %s", ln.Addr(), err)
+					ln.proxyProtocolErrors.Inc()
+					proxyProtocolReadErrorLogger.Errorf("cannot read proxy proto conn for TCP addr %q from %q: %s", ln.Addr(), conn.RemoteAddr(), err)
 				}
 				_ = conn.Close()
 				continue
 			}
 			conn = pConn
 		}
+		if ln.tlsConfig != nil {
+			conn = tls.Server(conn, ln.tlsConfig)
+		}
 		ln.conns.Inc()
 		sc := &statConn{
 			Conn: conn,
@@ -0,0 +1,147 @@
+package netutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPListenerProxyProtocol(t *testing.T) {
+	ln, err := NewTCPListener("test_proxy_protocol", "127.0.0.1:0", true, nil)
+	if err != nil {
+		t.Fatalf("cannot create listener: %s", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		// Malformed header must be rejected without affecting the next connections.
+		c := mustDial(t, ln.Addr().String())
+		if c == nil {
+			return
+		}
+		_, _ = c.Write([]byte("GET / HTTP/1.1\r\nHost: foo\r\n\r\n"))
+		_, _ = io.ReadAll(c)
+		_ = c.Close()
+
+		c = mustDial(t, ln.Addr().String())
+		if c == nil {
+			return
+		}
+		_, _ = c.Write([]byte("PROXY TCP4 10.0.0.1 10.0.0.2 1234 80\r\nhello"))
+		_ = c.Close()
+	}()
+
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("cannot accept connection: %s", err)
+	}
+	defer c.Close()
+	remoteAddr := c.RemoteAddr().String()
+	if remoteAddr != "10.0.0.1:1234" {
+		t.Fatalf("unexpected remote addr; got %q; want %q", remoteAddr, "10.0.0.1:1234")
+	}
+	data, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("cannot read data: %s", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("unexpected data; got %q; want %q", data, "hello")
+	}
+	if n := ln.proxyProtocolErrors.Get(); n != 1 {
+		t.Fatalf("unexpected number of proxy protocol errors; got %d; want 1", n)
+	}
+}
+
+func TestTCPListenerProxyProtocolTLS(t *testing.T) {
+	serverTLSConfig := &tls.Config{
+		Certificates: []tls.Certificate{mustGenerateCert(t)},
+	}
+	ln, err := NewTCPListener("test_proxy_protocol_tls", "127.0.0.1:0", true, serverTLSConfig)
+	if err != nil {
+		t.Fatalf("cannot create listener: %s", err)
+	}
+	defer ln.Close()
+
+	clientErrCh := make(chan error, 1)
+	go func() {
+		// The proxy protocol header is sent before TLS handshake.
+		c := mustDial(t, ln.Addr().String())
+		if c == nil {
+			clientErrCh <- nil
+			return
+		}
+		defer c.Close()
+		if _, err := c.Write([]byte("PROXY TCP6 ::1 ::2 1234 443\r\n")); err != nil {
+			clientErrCh <- err
+			return
+		}
+		tc := tls.Client(c, &tls.Config{
+			InsecureSkipVerify: true,
+		})
+		_, err := tc.Write([]byte("hello"))
+		if err == nil {
+			err = tc.Close()
+		}
+		clientErrCh <- err
+	}()
+
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("cannot accept connection: %s", err)
+	}
+	defer c.Close()
+	remoteAddr := c.RemoteAddr().String()
+	if remoteAddr != "[::1]:1234" {
+		t.Fatalf("unexpected remote addr; got %q; want %q", remoteAddr, "[::1]:1234")
+	}
+	buf := make([]byte, len("hello"))
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatalf("cannot read data: %s", err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("unexpected data; got %q; want %q", buf, "hello")
+	}
+	if err := <-clientErrCh; err != nil {
+		t.Fatalf("unexpected client error: %s", err)
+	}
+}
+
+func mustDial(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Errorf("cannot dial %q: %s", addr, err)
+		return nil
+	}
+	return c
+}
+
+func mustGenerateCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %s", err)
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
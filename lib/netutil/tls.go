@@ -7,6 +7,8 @@ import (
 	"sync"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 // GetServerTLSConfig returns TLS config for the server.
@@ -49,6 +51,44 @@ func GetServerTLSConfig(tlsCertFile, tlsKeyFile, tlsMinVersion string, tlsCipher
 	return cfg, nil
 }
 
+// GetServerAutocertTLSConfig returns TLS config for the server, which automatically obtains and renews TLS certificates
+// for the given hosts via ACME protocol at https://letsencrypt.org/ .
+//
+// Certificates are obtained via TLS-ALPN-01 challenge, so the server must be reachable at port 443 for the given hosts.
+// Certificates are stored at cacheDir, so they are re-used after restarts.
+// Certificates are renewed in background before their expiration, so the renewal doesn't interrupt serving.
+func GetServerAutocertTLSConfig(hosts []string, cacheDir, tlsMinVersion string, tlsCipherSuites []string) (*tls.Config, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("hosts for automatic TLS certificates cannot be empty")
+	}
+	if cacheDir == "" {
+		// Do not allow in-memory cache, since this results in a new certificate issuing on every restart,
+		// which may quickly hit Let's Encrypt rate limits.
+		return nil, fmt.Errorf("cache dir for automatic TLS certificates cannot be empty")
+	}
+	cipherSuites, err := cipherSuitesFromNames(tlsCipherSuites)
+	if err != nil {
+		return nil, fmt.Errorf("cannot use TLS cipher suites from tlsCipherSuites=%q: %w", tlsCipherSuites, err)
+	}
+	minVersion, err := ParseTLSVersion(tlsMinVersion)
+	if err != nil {
+		return nil, fmt.Errorf("cannnot use TLS min version from tlsMinVersion=%q. Supported TLS versions (TLS10, TLS11, TLS12, TLS13): %w", tlsMinVersion, err)
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(hosts...),
+		Cache:      autocert.DirCache(cacheDir),
+	}
+	cfg := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: m.GetCertificate,
+		// Do not advertise h2, since http/2 is disabled at VictoriaMetrics services.
+		NextProtos:   []string{"http/1.1", acme.ALPNProto},
+		CipherSuites: cipherSuites,
+	}
+	return cfg, nil
+}
+
 func cipherSuitesFromNames(cipherSuiteNames []string) ([]uint16, error) {
 	if len(cipherSuiteNames) == 0 {
 		return nil, nil
@@ -118,3 +118,30 @@ func TestParseTLSVersionFailure(t *testing.T) {
 	// incorrect tls version in tlsName
 	f("TLS14")
 }
+
+func TestGetServerAutocertTLSConfig(t *testing.T) {
+	f := func(hosts []string, cacheDir string) {
+		t.Helper()
+		if _, err := GetServerAutocertTLSConfig(hosts, cacheDir, "", nil); err == nil {
+			t.Fatalf("expecting non-nil error for hosts=%q, cacheDir=%q", hosts, cacheDir)
+		}
+	}
+	f(nil, "/tmp/autocert")
+	f([]string{"foo.bar"}, "")
+
+	cfg, err := GetServerAutocertTLSConfig([]string{"foo.bar"}, t.TempDir(), "TLS12", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("unexpected MinVersion; got %d; want %d", cfg.MinVersion, tls.VersionTLS12)
+	}
+	nextProtosExpected := []string{"http/1.1", "acme-tls/1"}
+	if !reflect.DeepEqual(cfg.NextProtos, nextProtosExpected) {
+		t.Fatalf("unexpected NextProtos; got %q; want %q", cfg.NextProtos, nextProtosExpected)
+	}
+	// Certificates mustn't be issued for hosts outside the list.
+	if _, err := cfg.GetCertificate(&tls.ClientHelloInfo{ServerName: "baz.bar"}); err == nil {
+		t.Fatalf("expecting non-nil error for unknown host")
+	}
+}
@@ -0,0 +1,97 @@
+package partitionname
+
+import (
+	"fmt"
+	"time"
+)
+
+// Supported partition granularities.
+const (
+	Monthly = "monthly"
+	Weekly  = "weekly"
+	Daily   = "daily"
+)
+
+// Format returns the name for the partition with the given granularity, which contains t.
+//
+// The name has the following form depending on granularity:
+// YYYY_MM for monthly partitions, YYYY_wWW for weekly partitions and YYYY_MM_DD for daily partitions.
+// Weekly partitions start on Monday according to ISO 8601.
+func Format(t time.Time, granularity string) string {
+	t = t.UTC()
+	switch granularity {
+	case Daily:
+		return t.Format("2006_01_02")
+	case Weekly:
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%04d_w%02d", y, w)
+	default:
+		return t.Format("2006_01")
+	}
+}
+
+// Parse returns the start time for the partition with the given name and granularity.
+//
+// See Format for the supported partition names.
+func Parse(name, granularity string) (time.Time, error) {
+	var t time.Time
+	var err error
+	switch granularity {
+	case Daily:
+		t, err = time.Parse("2006_01_02", name)
+	case Weekly:
+		t, err = parseWeekly(name)
+	default:
+		t, err = time.Parse("2006_01", name)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse partition name %q for %s partition granularity: %w", name, granularity, err)
+	}
+	return t, nil
+}
+
+// parseWeekly returns the start of the ISO week for the given partition name in the form YYYY_wWW.
+func parseWeekly(name string) (time.Time, error) {
+	var y, w int
+	if len(name) != len("2006_w01") || name[4:6] != "_w" {
+		return time.Time{}, fmt.Errorf("expecting YYYY_wWW")
+	}
+	if _, err := fmt.Sscanf(name, "%04d_w%02d", &y, &w); err != nil {
+		return time.Time{}, fmt.Errorf("expecting YYYY_wWW: %w", err)
+	}
+	// January 4 is always in the first ISO week of the year.
+	jan4 := time.Date(y, time.January, 4, 0, 0, 0, 0, time.UTC)
+	t := weekStart(jan4).AddDate(0, 0, 7*(w-1))
+	if yy, ww := t.ISOWeek(); yy != y || ww != w {
+		return time.Time{}, fmt.Errorf("week %d doesn't exist in the year %d", w, y)
+	}
+	return t, nil
+}
+
+// TimeRange returns the start and the end of the partition with the given granularity, which contains t.
+//
+// The end is exclusive, e.g. it equals to the start of the next partition.
+func TimeRange(t time.Time, granularity string) (time.Time, time.Time) {
+	var minTime, maxTime time.Time
+	switch granularity {
+	case Daily:
+		y, m, d := t.UTC().Date()
+		minTime = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		maxTime = minTime.AddDate(0, 0, 1)
+	case Weekly:
+		minTime = weekStart(t)
+		maxTime = minTime.AddDate(0, 0, 7)
+	default:
+		y, m, _ := t.UTC().Date()
+		minTime = time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+		maxTime = time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return minTime, maxTime
+}
+
+// weekStart returns the start of the ISO week for t.
+func weekStart(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	daysSinceMonday := (int(t.UTC().Weekday()) + 6) % 7
+	return time.Date(y, m, d-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
@@ -62,33 +63,41 @@ func MustOpenFastQueue(path, name string, maxInmemoryBlocks int, maxPendingBytes
 }
 
 // UnblockAllReaders unblocks all the readers.
-func (fq *FastQueue) UnblockAllReaders() {
+//
+// The readers may continue reading the queued data during the given gracePeriod.
+// This allows sending Prometheus stale markers and the pending data on shutdown.
+// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1526
+func (fq *FastQueue) UnblockAllReaders(gracePeriod time.Duration) {
 	fq.mu.Lock()
 	defer fq.mu.Unlock()
 
-	// Unblock blocked readers
-	// Allow for up to 5 seconds for sending Prometheus stale markers.
-	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1526
-	fq.stopDeadline = fasttime.UnixTimestamp() + 5
+	fq.stopDeadline = fasttime.UnixTimestamp() + uint64(gracePeriod.Seconds())
 	fq.cond.Broadcast()
 }
 
-// MustClose unblocks all the readers.
+// MustClose unblocks all the readers and persists in-memory blocks to the file-based queue.
 //
 // It is expected no new writers during and after the call.
 func (fq *FastQueue) MustClose() {
-	fq.UnblockAllReaders()
+	fq.UnblockAllReaders(0)
 
 	fq.mu.Lock()
 	defer fq.mu.Unlock()
 
 	// flush blocks from fq.ch to fq.pq, so they can be persisted
+	blocksFlushed := len(fq.ch)
+	bytesFlushed := fq.pendingInmemoryBytes
+	blocksDropped := fq.pq.blocksDropped.Get()
+	bytesDropped := fq.pq.bytesDropped.Get()
 	fq.flushInmemoryBlocksToFileLocked()
+	blocksDropped = fq.pq.blocksDropped.Get() - blocksDropped
+	bytesDropped = fq.pq.bytesDropped.Get() - bytesDropped
 
 	// Close fq.pq
 	fq.pq.MustClose()
 
-	logger.Infof("closed fast persistent queue at %q", fq.pq.dir)
+	logger.Infof("closed fast persistent queue at %q; flushed %d in-memory blocks with %d bytes to the file-based queue; "+
+		"dropped %d blocks with %d bytes because of the queue size limit", fq.pq.dir, blocksFlushed, bytesFlushed, blocksDropped, bytesDropped)
 }
 
 func (fq *FastQueue) flushInmemoryBlocksToFileIfNeededLocked() {
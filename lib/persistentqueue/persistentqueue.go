@@ -294,18 +294,37 @@ func tryOpeningQueue(path, name string, chunkFileSize, maxBlockSize, maxPendingB
 			q.writerPath = filepath
 			q.writerOffset = mi.WriterOffset
 			q.writerLocalOffset = mi.WriterOffset % q.chunkFileSize
-			q.writerFlushedOffset = mi.WriterOffset
 			if fileSize := fs.MustFileSize(q.writerPath); fileSize != q.writerLocalOffset {
-				if fileSize < q.writerLocalOffset {
-					logger.Errorf("%q size (%d bytes) is smaller than the writer offset (%d bytes); removing the file",
-						q.writerPath, fileSize, q.writerLocalOffset)
+				// This may be the case on unclean shutdown (OOM, `kill -9`, hardware reset) in the middle of writing a block
+				// or before writing the metainfo. Try recovering complete blocks instead of discarding the whole file.
+				startOffset := q.writerLocalOffset
+				if fileSize < startOffset {
+					startOffset = 0
+					if mi.ReaderOffset >= offset {
+						// The reader and the writer share the same chunk file, so the blocks start at the reader offset.
+						startOffset = mi.ReaderOffset % q.chunkFileSize
+					}
+				}
+				endOffset, err := getCompleteBlocksEndOffset(q.writerPath, startOffset, fileSize, q.maxBlockSize)
+				if err != nil {
+					logger.Errorf("cannot recover %q with size %d bytes and writer offset %d bytes: %s; removing the file",
+						q.writerPath, fileSize, q.writerLocalOffset, err)
+					fs.MustRemoveAll(q.writerPath)
+					continue
+				}
+				logger.Warnf("%q size (%d bytes) doesn't match the writer offset (%d bytes); "+
+					"this may be the case on unclean shutdown (OOM, `kill -9`, hardware reset); "+
+					"recovered complete blocks up to %d bytes and dropped %d bytes of partially written tail block",
+					q.writerPath, fileSize, q.writerLocalOffset, endOffset, fileSize-endOffset)
+				if err := os.Truncate(q.writerPath, int64(endOffset)); err != nil {
+					logger.Errorf("cannot truncate %q to %d bytes: %s; removing the file", q.writerPath, endOffset, err)
 					fs.MustRemoveAll(q.writerPath)
 					continue
 				}
-				logger.Warnf("%q size (%d bytes) is bigger than writer offset (%d bytes); "+
-					"this may be the case on unclean shutdown (OOM, `kill -9`, hardware reset); trying to fix it by adjusting fileSize to %d",
-					q.writerPath, fileSize, q.writerLocalOffset, q.writerLocalOffset)
+				q.writerOffset = offset + endOffset
+				q.writerLocalOffset = endOffset
 			}
+			q.writerFlushedOffset = q.writerOffset
 			w, err := filestream.OpenWriterAt(q.writerPath, int64(q.writerLocalOffset), false)
 			if err != nil {
 				logger.Errorf("cannot open %q for writing at offset %d: %s; removing this file", q.writerPath, q.writerLocalOffset, err)
@@ -331,6 +350,33 @@ func tryOpeningQueue(path, name string, chunkFileSize, maxBlockSize, maxPendingB
 	return &q, nil
 }
 
+// getCompleteBlocksEndOffset returns the offset for the end of the last complete block in the chunk file at path with the given fileSize.
+//
+// Blocks are scanned starting from startOffset, which must point to the beginning of a block.
+func getCompleteBlocksEndOffset(path string, startOffset, fileSize, maxBlockSize uint64) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	offset := startOffset
+	header := make([]byte, 8)
+	for offset+8 <= fileSize {
+		if _, err := f.ReadAt(header, int64(offset)); err != nil {
+			return 0, fmt.Errorf("cannot read block header at offset %d: %w", offset, err)
+		}
+		blockLen := encoding.UnmarshalUint64(header)
+		if blockLen > maxBlockSize || offset+8+blockLen > fileSize {
+			// Partially written or corrupted block.
+			break
+		}
+		offset += 8 + blockLen
+	}
+	return offset, nil
+}
+
 // MustClose closes q.
 //
 // MustWriteBlock mustn't be called during and after the call to MustClose.
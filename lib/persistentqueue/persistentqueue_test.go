@@ -5,6 +5,8 @@ import (
 	"os"
 	"strconv"
 	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 )
 
 func TestQueueOpenClose(t *testing.T) {
@@ -244,6 +246,86 @@ func TestQueueWriteCloseRead(t *testing.T) {
 	}
 }
 
+func TestQueueRecoverAfterUncleanShutdown(t *testing.T) {
+	f := func(path string, corruptChunk func(chunkPath string, blocks [][]byte) [][]byte) {
+		t.Helper()
+		mustDeleteDir(path)
+		defer mustDeleteDir(path)
+
+		q := mustOpen(path, "foobar", 0)
+		var blocks [][]byte
+		for i := 0; i < 10; i++ {
+			block := []byte(fmt.Sprintf("block %d", i))
+			q.MustWriteBlock(block)
+			blocks = append(blocks, block)
+		}
+		q.MustClose()
+
+		// Simulate unclean shutdown in the middle of writing a block.
+		blocksExpected := corruptChunk(fmt.Sprintf("%s/%016X", path, 0), blocks)
+
+		q = mustOpen(path, "foobar", 0)
+		defer q.MustClose()
+		var buf []byte
+		var ok bool
+		for _, block := range blocksExpected {
+			buf, ok = q.MustReadBlockNonblocking(buf[:0])
+			if !ok {
+				t.Fatalf("unexpected ok=%v returned from MustReadBlockNonblocking; want true", ok)
+			}
+			if string(buf) != string(block) {
+				t.Fatalf("unexpected block read; got %q; want %q", buf, block)
+			}
+		}
+		if buf, ok = q.MustReadBlockNonblocking(buf[:0]); ok {
+			t.Fatalf("unexpected block read after the recovered blocks: %q", buf)
+		}
+
+		// Make sure the queue is writable after the recovery.
+		q.MustWriteBlock([]byte("new block"))
+		buf, ok = q.MustReadBlockNonblocking(buf[:0])
+		if !ok || string(buf) != "new block" {
+			t.Fatalf("unexpected block read; got %q, ok=%v; want %q, ok=true", buf, ok, "new block")
+		}
+	}
+
+	// Complete blocks are written after the metainfo was flushed, followed by partially written block.
+	f("queue-recover-partial-tail-block", func(chunkPath string, blocks [][]byte) [][]byte {
+		fd, err := os.OpenFile(chunkPath, os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			t.Fatalf("cannot open %q: %s", chunkPath, err)
+		}
+		var data []byte
+		for i := 0; i < 3; i++ {
+			block := []byte(fmt.Sprintf("unflushed block %d", i))
+			data = encoding.MarshalUint64(data, uint64(len(block)))
+			data = append(data, block...)
+			blocks = append(blocks, block)
+		}
+		data = encoding.MarshalUint64(data, 100)
+		data = append(data, "partial"...)
+		if _, err := fd.Write(data); err != nil {
+			t.Fatalf("cannot write to %q: %s", chunkPath, err)
+		}
+		if err := fd.Close(); err != nil {
+			t.Fatalf("cannot close %q: %s", chunkPath, err)
+		}
+		return blocks
+	})
+
+	// The metainfo points beyond the end of the chunk file, which is cut in the middle of the last block.
+	f("queue-recover-truncated-chunk", func(chunkPath string, blocks [][]byte) [][]byte {
+		fi, err := os.Stat(chunkPath)
+		if err != nil {
+			t.Fatalf("cannot stat %q: %s", chunkPath, err)
+		}
+		if err := os.Truncate(chunkPath, fi.Size()-3); err != nil {
+			t.Fatalf("cannot truncate %q: %s", chunkPath, err)
+		}
+		return blocks[:len(blocks)-1]
+	})
+}
+
 func TestQueueChunkManagementSimple(t *testing.T) {
 	path := "queue-chunk-management-simple"
 	mustDeleteDir(path)
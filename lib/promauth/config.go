@@ -103,6 +103,9 @@ type Authorization struct {
 	Type            string  `yaml:"type,omitempty"`
 	Credentials     *Secret `yaml:"credentials,omitempty"`
 	CredentialsFile string  `yaml:"credentials_file,omitempty"`
+
+	// CredentialsExec contains optional config for obtaining credentials from an external command.
+	CredentialsExec *CredentialsExecConfig `yaml:"credentials_exec,omitempty"`
 }
 
 // BasicAuthConfig represents basic auth config.
@@ -268,6 +271,7 @@ type Config struct {
 	tlsCertDigest string
 
 	getAuthHeader      func() string
+	getAuthHeaderError func() error
 	authHeaderLock     sync.Mutex
 	authHeader         string
 	authHeaderDeadline uint64
@@ -353,6 +357,17 @@ func (ac *Config) GetAuthHeader() string {
 	return ac.authHeader
 }
 
+// GetAuthHeaderError returns the error occurred when obtaining `Authorization` header for ac.
+//
+// Nil is returned if the header has been obtained successfully or if the configured auth method doesn't report errors.
+func (ac *Config) GetAuthHeaderError() error {
+	f := ac.getAuthHeaderError
+	if f == nil {
+		return nil
+	}
+	return f()
+}
+
 // String returns human-readable representation for ac.
 //
 // It is also used for comparing Config objects for equality. If two Config
@@ -542,9 +557,10 @@ func (opts *Options) NewConfig() (*Config, error) {
 		getTLSCert:    tctx.getTLSCert,
 		tlsCertDigest: tctx.tlsCertDigest,
 
-		getAuthHeader: actx.getAuthHeader,
-		headers:       headers,
-		authDigest:    actx.authDigest,
+		getAuthHeader:      actx.getAuthHeader,
+		getAuthHeaderError: actx.getAuthHeaderError,
+		headers:            headers,
+		authDigest:         actx.authDigest,
 	}
 	return ac, nil
 }
@@ -553,6 +569,9 @@ type authContext struct {
 	// getAuthHeader must return <value> for 'Authorization: <value>' http request header
 	getAuthHeader func() string
 
+	// getAuthHeaderError is an optional func, which must return the error occurred when obtaining the header
+	getAuthHeaderError func() error
+
 	// authDigest must contain the digest for the used authorization
 	// The digest must be changed whenever the original config changes.
 	authDigest string
@@ -563,6 +582,25 @@ func (actx *authContext) initFromAuthorization(baseDir string, az *Authorization
 	if az.Type != "" {
 		azType = az.Type
 	}
+	if az.CredentialsExec != nil {
+		if az.Credentials != nil || az.CredentialsFile != "" {
+			return fmt.Errorf("`credentials_exec` cannot be used together with `credentials` or `credentials_file`")
+		}
+		ce, err := newCredentialsExec(baseDir, az.CredentialsExec)
+		if err != nil {
+			return err
+		}
+		actx.getAuthHeader = func() string {
+			token, err := ce.getToken()
+			if err != nil {
+				return ""
+			}
+			return azType + " " + token
+		}
+		actx.getAuthHeaderError = ce.getError
+		actx.authDigest = fmt.Sprintf("custom(type=%q, credsExec={%s})", az.Type, az.CredentialsExec.String())
+		return nil
+	}
 	if az.CredentialsFile == "" {
 		actx.getAuthHeader = func() string {
 			return azType + " " + az.Credentials.String()
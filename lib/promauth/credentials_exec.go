@@ -0,0 +1,283 @@
+package promauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+	"gopkg.in/yaml.v2"
+)
+
+var allowCredentialsExec = flag.Bool("allowCredentialsExec", false, "Whether to allow `authorization.credentials_exec` option, which runs the configured command "+
+	"for obtaining credentials. The option is always refused in configs loaded from http(s) urls. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#http-api-client-options")
+
+// CredentialsExecConfig represents config for obtaining credentials from an external command.
+//
+// The command must print JSON with the token and its optional expiration time to stdout:
+//
+//	{"token":"...","expiration":"2006-01-02T15:04:05Z"}
+//
+// The output of kubectl exec credential plugins is supported as well:
+//
+//	{"status":{"token":"...","expirationTimestamp":"2006-01-02T15:04:05Z"}}
+type CredentialsExecConfig struct {
+	// Command is the command to run. Relative paths containing a path separator are resolved against the config dir.
+	// Commands without a path separator are searched in $PATH.
+	Command string `yaml:"command"`
+
+	// Args contains optional command-line args to pass to the command.
+	Args []string `yaml:"args,omitempty"`
+
+	// Timeout is the maximum duration for the command execution. By default 10s.
+	Timeout *promutils.Duration `yaml:"timeout,omitempty"`
+
+	// RefreshMargin is the duration before the token expiration when the token must be refreshed. By default 1m.
+	RefreshMargin *promutils.Duration `yaml:"refresh_margin,omitempty"`
+}
+
+// String returns string representation of ce.
+func (ce *CredentialsExecConfig) String() string {
+	return fmt.Sprintf("command=%q, args=%q, timeout=%s, refresh_margin=%s", ce.Command, ce.Args, ce.Timeout.Duration(), ce.RefreshMargin.Duration())
+}
+
+const (
+	defaultCredentialsExecTimeout       = 10 * time.Second
+	defaultCredentialsExecRefreshMargin = time.Minute
+
+	// credentialsExecDefaultTTL is the lifetime for tokens returned without expiration time.
+	credentialsExecDefaultTTL = 5 * time.Minute
+
+	credentialsExecMinBackoff = time.Second
+	credentialsExecMaxBackoff = time.Minute
+)
+
+// credentialsExec obtains tokens from an external command and caches them until the expiration minus refresh margin.
+//
+// The command is run only when the cached token must be refreshed. Failed runs are retried with exponential backoff.
+// Concurrent callers share a single command run.
+type credentialsExec struct {
+	command       string
+	args          []string
+	timeout       time.Duration
+	refreshMargin time.Duration
+
+	mu          sync.Mutex
+	token       string
+	expiration  time.Time
+	refreshTime time.Time
+
+	lastErr        error
+	backoff        time.Duration
+	nextRetryTime  time.Time
+	commandRunsNum int
+
+	// runCh is closed when the currently running command finishes. It is nil if the command isn't running.
+	runCh chan struct{}
+}
+
+func newCredentialsExec(baseDir string, ce *CredentialsExecConfig) (*credentialsExec, error) {
+	if !*allowCredentialsExec {
+		return nil, fmt.Errorf("`credentials_exec` is disabled; pass -allowCredentialsExec command-line flag in order to enable it")
+	}
+	if ce.Command == "" {
+		return nil, fmt.Errorf("missing `command` option in `credentials_exec`")
+	}
+	command := ce.Command
+	if strings.ContainsRune(command, '/') {
+		command = fs.GetFilepath(baseDir, command)
+	}
+	timeout := ce.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = defaultCredentialsExecTimeout
+	}
+	refreshMargin := ce.RefreshMargin.Duration()
+	if refreshMargin <= 0 {
+		refreshMargin = defaultCredentialsExecRefreshMargin
+	}
+	return &credentialsExec{
+		command:       command,
+		args:          ce.Args,
+		timeout:       timeout,
+		refreshMargin: refreshMargin,
+	}, nil
+}
+
+// getToken returns the cached token or obtains a new token from the command if the cached token must be refreshed.
+//
+// The previously obtained token is returned until its expiration if the command fails.
+// The command runs without holding ce.mu, so callers with a valid cached token aren't blocked by it.
+func (ce *credentialsExec) getToken() (string, error) {
+	ce.mu.Lock()
+	now := time.Now()
+	if ce.token != "" && now.Before(ce.refreshTime) {
+		token := ce.token
+		ce.mu.Unlock()
+		return token, nil
+	}
+	if now.Before(ce.nextRetryTime) {
+		token, err := ce.getTokenOnErrorLocked(now)
+		ce.mu.Unlock()
+		return token, err
+	}
+	if runCh := ce.runCh; runCh != nil {
+		// The command is already running.
+		if ce.token != "" && now.Before(ce.expiration) {
+			token := ce.token
+			ce.mu.Unlock()
+			return token, nil
+		}
+		ce.mu.Unlock()
+		<-runCh
+		ce.mu.Lock()
+		token, err := ce.getTokenOnErrorLocked(time.Now())
+		ce.mu.Unlock()
+		return token, err
+	}
+	runCh := make(chan struct{})
+	ce.runCh = runCh
+	ce.commandRunsNum++
+	ce.mu.Unlock()
+
+	token, expiration, err := ce.runCommand()
+
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	ce.runCh = nil
+	close(runCh)
+	now = time.Now()
+	if err != nil {
+		ce.backoff *= 2
+		if ce.backoff < credentialsExecMinBackoff {
+			ce.backoff = credentialsExecMinBackoff
+		}
+		if ce.backoff > credentialsExecMaxBackoff {
+			ce.backoff = credentialsExecMaxBackoff
+		}
+		ce.nextRetryTime = now.Add(ce.backoff)
+		ce.lastErr = fmt.Errorf("cannot obtain credentials from `credentials_exec` command %q: %w; next attempt in %s", ce.command, err, ce.backoff)
+		logger.Errorf("%s", ce.lastErr)
+		return ce.getTokenOnErrorLocked(now)
+	}
+	if expiration.IsZero() {
+		expiration = now.Add(credentialsExecDefaultTTL)
+	}
+	refreshTime := expiration.Add(-ce.refreshMargin)
+	if !refreshTime.After(now) {
+		// The token lifetime is shorter than the refresh margin. Refresh it at the half of its lifetime
+		// in order to avoid running the command on every request.
+		refreshTime = now.Add(expiration.Sub(now) / 2)
+	}
+	ce.token = token
+	ce.expiration = expiration
+	ce.refreshTime = refreshTime
+	ce.lastErr = nil
+	ce.backoff = 0
+	ce.nextRetryTime = time.Time{}
+	return token, nil
+}
+
+func (ce *credentialsExec) getTokenOnErrorLocked(now time.Time) (string, error) {
+	if ce.token != "" && now.Before(ce.expiration) {
+		return ce.token, nil
+	}
+	return "", ce.lastErr
+}
+
+// getError returns the error for the last failed attempt to obtain the token if there is no valid cached token.
+func (ce *credentialsExec) getError() error {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	if ce.token != "" && time.Now().Before(ce.expiration) {
+		return nil
+	}
+	return ce.lastErr
+}
+
+func (ce *credentialsExec) runCommand() (string, time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ce.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, ce.command, ce.args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("the command didn't finish in %s", ce.timeout)
+		}
+		return "", time.Time{}, fmt.Errorf("%w; stderr: %q", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseCredentialsExecOutput(stdout.Bytes(), time.Now())
+}
+
+type credentialsExecOutput struct {
+	Token      string    `json:"token"`
+	Expiration time.Time `json:"expiration"`
+
+	// Status contains the token in kubectl ExecCredential format.
+	Status *struct {
+		Token               string    `json:"token"`
+		ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+func parseCredentialsExecOutput(data []byte, now time.Time) (string, time.Time, error) {
+	var out credentialsExecOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return "", time.Time{}, fmt.Errorf("cannot parse command output as JSON: %w", err)
+	}
+	token := out.Token
+	expiration := out.Expiration
+	if token == "" && out.Status != nil {
+		token = out.Status.Token
+		expiration = out.Status.ExpirationTimestamp
+	}
+	if token == "" {
+		return "", time.Time{}, fmt.Errorf("missing `token` in command output")
+	}
+	if !expiration.IsZero() && !expiration.After(now) {
+		return "", time.Time{}, fmt.Errorf("the command returned already expired token; expiration: %s", expiration.Format(time.RFC3339))
+	}
+	return token, expiration, nil
+}
+
+// CheckNoCredentialsExec returns an error if the given YAML config data contains `credentials_exec` option.
+//
+// It must be called for configs loaded from http(s) urls, since such configs mustn't be able to run commands.
+func CheckNoCredentialsExec(data []byte) error {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("cannot parse config: %w", err)
+	}
+	if hasCredentialsExec(v) {
+		return fmt.Errorf("`credentials_exec` isn't allowed in configs loaded from http(s) urls")
+	}
+	return nil
+}
+
+func hasCredentialsExec(v interface{}) bool {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		for k, vv := range t {
+			if k == "credentials_exec" || hasCredentialsExec(vv) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, vv := range t {
+			if hasCredentialsExec(vv) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,317 @@
+package promauth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func TestParseCredentialsExecOutputSuccess(t *testing.T) {
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := func(data, tokenExpected string, expirationExpected time.Time) {
+		t.Helper()
+		token, expiration, err := parseCredentialsExecOutput([]byte(data), now)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if token != tokenExpected {
+			t.Fatalf("unexpected token; got %q; want %q", token, tokenExpected)
+		}
+		if !expiration.Equal(expirationExpected) {
+			t.Fatalf("unexpected expiration; got %s; want %s", expiration, expirationExpected)
+		}
+	}
+	f(`{"token":"foo"}`, "foo", time.Time{})
+	f(`{"token":"foo","expiration":"2023-01-02T04:00:00Z"}`, "foo", time.Date(2023, 1, 2, 4, 0, 0, 0, time.UTC))
+	f(`{"kind":"ExecCredential","status":{"token":"bar","expirationTimestamp":"2023-01-02T04:00:00Z"}}`, "bar", time.Date(2023, 1, 2, 4, 0, 0, 0, time.UTC))
+}
+
+func TestParseCredentialsExecOutputFailure(t *testing.T) {
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := func(data string) {
+		t.Helper()
+		_, _, err := parseCredentialsExecOutput([]byte(data), now)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f(``)
+	f(`foo`)
+	f(`{}`)
+	f(`{"token":""}`)
+	f(`{"token":"foo","expiration":"foobar"}`)
+	// Expired token
+	f(`{"token":"foo","expiration":"2023-01-02T03:00:00Z"}`)
+}
+
+func TestCredentialsExecCaching(t *testing.T) {
+	enableCredentialsExec(t)
+	dir := t.TempDir()
+	writeScript(t, dir, "token.sh", `echo run >> runs.txt; echo '{"token":"foo","expiration":"`+time.Now().Add(time.Hour).UTC().Format(time.RFC3339)+`"}'`)
+	ce, err := newCredentialsExec(dir, &CredentialsExecConfig{
+		Command: "./token.sh",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		token, err := ce.getToken()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if token != "foo" {
+			t.Fatalf("unexpected token; got %q; want %q", token, "foo")
+		}
+	}
+	if ce.commandRunsNum != 1 {
+		t.Fatalf("unexpected number of command runs; got %d; want 1", ce.commandRunsNum)
+	}
+	if err := ce.getError(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The token must be refreshed when the refresh margin exceeds its remaining lifetime.
+	ce.refreshMargin = 2 * time.Hour
+	ce.refreshTime = time.Now()
+	if _, err := ce.getToken(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ce.commandRunsNum != 2 {
+		t.Fatalf("unexpected number of command runs; got %d; want 2", ce.commandRunsNum)
+	}
+	if !ce.refreshTime.After(time.Now()) {
+		t.Fatalf("refresh time must be in the future; got %s", ce.refreshTime)
+	}
+}
+
+func TestCredentialsExecFailure(t *testing.T) {
+	enableCredentialsExec(t)
+	dir := t.TempDir()
+	writeScript(t, dir, "token.sh", `echo "access denied" >&2; exit 1`)
+	ac, err := (&Options{
+		BaseDir: dir,
+		Authorization: &Authorization{
+			CredentialsExec: &CredentialsExecConfig{
+				Command: "./token.sh",
+			},
+		},
+	}).NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ah := ac.GetAuthHeader(); ah != "" {
+		t.Fatalf("unexpected auth header; got %q; want empty header", ah)
+	}
+	err = ac.GetAuthHeaderError()
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if !strings.Contains(err.Error(), "access denied") {
+		t.Fatalf("the error must contain the command stderr; got %q", err)
+	}
+
+	// The command mustn't be run again until the backoff passes.
+	ce, err := newCredentialsExec(dir, &CredentialsExecConfig{
+		Command: "./token.sh",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := ce.getToken(); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	if ce.commandRunsNum != 1 {
+		t.Fatalf("unexpected number of command runs; got %d; want 1", ce.commandRunsNum)
+	}
+	if ce.backoff != credentialsExecMinBackoff {
+		t.Fatalf("unexpected backoff; got %s; want %s", ce.backoff, credentialsExecMinBackoff)
+	}
+
+	// The previously obtained token must be used until its expiration.
+	ce.token = "foo"
+	ce.expiration = time.Now().Add(time.Hour)
+	ce.nextRetryTime = time.Time{}
+	token, err := ce.getToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if token != "foo" {
+		t.Fatalf("unexpected token; got %q; want %q", token, "foo")
+	}
+	if ce.backoff != 2*credentialsExecMinBackoff {
+		t.Fatalf("unexpected backoff; got %s; want %s", ce.backoff, 2*credentialsExecMinBackoff)
+	}
+}
+
+func TestCredentialsExecTimeout(t *testing.T) {
+	enableCredentialsExec(t)
+	ce, err := newCredentialsExec(".", &CredentialsExecConfig{
+		Command: "sleep",
+		Args:    []string{"10"},
+		Timeout: promutils.NewDuration(100 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = ce.getToken()
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if !strings.Contains(err.Error(), "didn't finish in 100ms") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestCredentialsExecConcurrent(t *testing.T) {
+	enableCredentialsExec(t)
+	dir := t.TempDir()
+	writeScript(t, dir, "token.sh", `sleep 0.2; echo '{"token":"foo"}'`)
+	ce, err := newCredentialsExec(dir, &CredentialsExecConfig{
+		Command: "./token.sh",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := ce.getToken()
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+				return
+			}
+			if token != "foo" {
+				t.Errorf("unexpected token; got %q; want %q", token, "foo")
+			}
+		}()
+	}
+	wg.Wait()
+	if ce.commandRunsNum != 1 {
+		t.Fatalf("unexpected number of command runs; got %d; want 1", ce.commandRunsNum)
+	}
+
+	// The cached token must be returned without waiting for the command refreshing it.
+	ce.refreshTime = time.Now()
+	go func() {
+		_, _ = ce.getToken()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	startTime := time.Now()
+	token, err := ce.getToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if token != "foo" {
+		t.Fatalf("unexpected token; got %q; want %q", token, "foo")
+	}
+	if d := time.Since(startTime); d > 100*time.Millisecond {
+		t.Fatalf("getToken must return the cached token without waiting for the command; it took %s", d)
+	}
+}
+
+func TestCredentialsExecDisabled(t *testing.T) {
+	_, err := newCredentialsExec(".", &CredentialsExecConfig{
+		Command: "foo",
+	})
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if !strings.Contains(err.Error(), "-allowCredentialsExec") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestCheckNoCredentialsExec(t *testing.T) {
+	f := func(data string, resultExpected bool) {
+		t.Helper()
+		err := CheckNoCredentialsExec([]byte(data))
+		if result := err == nil; result != resultExpected {
+			t.Fatalf("unexpected result for %q; got %v; want %v; err: %v", data, result, resultExpected, err)
+		}
+	}
+	f(``, true)
+	f(`
+scrape_configs:
+- job_name: foo
+  authorization:
+    credentials: bar
+`, true)
+	f(`
+scrape_configs:
+- job_name: foo
+  authorization:
+    credentials_exec:
+      command: bar
+`, false)
+	f(`
+scrape_configs:
+- job_name: foo
+  kubernetes_sd_configs:
+  - role: pod
+    authorization:
+      credentials_exec:
+        command: bar
+`, false)
+	f(`
+- job_name: foo
+  proxy_authorization:
+    credentials_exec: {command: bar}
+`, false)
+	// Invalid yaml
+	f(`foo: [`, false)
+}
+
+func TestCredentialsExecConfigFailure(t *testing.T) {
+	enableCredentialsExec(t)
+	f := func(az *Authorization) {
+		t.Helper()
+		opts := &Options{
+			Authorization: az,
+		}
+		if _, err := opts.NewConfig(); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f(&Authorization{
+		CredentialsExec: &CredentialsExecConfig{},
+	})
+	f(&Authorization{
+		Credentials: NewSecret("foo"),
+		CredentialsExec: &CredentialsExecConfig{
+			Command: "foo",
+		},
+	})
+	f(&Authorization{
+		CredentialsFile: "foo",
+		CredentialsExec: &CredentialsExecConfig{
+			Command: "foo",
+		},
+	})
+}
+
+func enableCredentialsExec(t *testing.T) {
+	t.Helper()
+	prev := *allowCredentialsExec
+	*allowCredentialsExec = true
+	t.Cleanup(func() {
+		*allowCredentialsExec = prev
+	})
+}
+
+func writeScript(t *testing.T, dir, name, script string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	data := "#!/bin/sh\ncd " + dir + "\n" + script + "\n"
+	if err := os.WriteFile(path, []byte(data), 0755); err != nil {
+		t.Fatalf("cannot write script: %s", err)
+	}
+}
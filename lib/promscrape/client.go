@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -53,9 +54,14 @@ type client struct {
 	setProxyHeaders         func(req *http.Request)
 	setFasthttpHeaders      func(req *fasthttp.Request)
 	setFasthttpProxyHeaders func(req *fasthttp.Request)
+	getAuthHeaderError      func() error
 	denyRedirects           bool
 	disableCompression      bool
 	disableKeepAlive        bool
+
+	// proxyURL is the redacted proxy url for non-TLS targets scraped via http or https proxy.
+	// It is used for distinguishing proxy errors from target errors.
+	proxyURL string
 }
 
 func addMissingPort(addr string, isTLS bool) string {
@@ -93,6 +99,7 @@ func newClient(ctx context.Context, sw *ScrapeWork) *client {
 	setProxyHeaders := func(req *http.Request) {}
 	setFasthttpProxyHeaders := func(req *fasthttp.Request) {}
 	proxyURL := sw.ProxyURL
+	forwardProxyURL := ""
 	if !isTLS && proxyURL.IsHTTPOrHTTPS() {
 		// Send full sw.ScrapeURL in requests to a proxy host for non-TLS scrape targets
 		// like net/http package from Go does.
@@ -111,6 +118,7 @@ func newClient(ctx context.Context, sw *ScrapeWork) *client {
 		setFasthttpProxyHeaders = func(req *fasthttp.Request) {
 			proxyURLOrig.SetFasthttpHeaders(sw.ProxyAuthConfig, req)
 		}
+		forwardProxyURL = pu.Redacted()
 		proxyURL = &proxy.URL{}
 	}
 	hostPort = addMissingPort(hostPort, isTLS)
@@ -119,6 +127,16 @@ func newClient(ctx context.Context, sw *ScrapeWork) *client {
 	if err != nil {
 		logger.Fatalf("cannot create dial func: %s", err)
 	}
+	if forwardProxyURL != "" {
+		dialFuncOrig := dialFunc
+		dialFunc = func(addr string) (net.Conn, error) {
+			conn, err := dialFuncOrig(addr)
+			if err != nil {
+				return nil, fmt.Errorf("cannot connect to proxy %q: %w", forwardProxyURL, err)
+			}
+			return conn, nil
+		}
+	}
 	hc := &fasthttp.HostClient{
 		Addr:                         dialAddr,
 		Name:                         "vm_promscrape",
@@ -134,8 +152,20 @@ func newClient(ctx context.Context, sw *ScrapeWork) *client {
 	}
 	var sc *http.Client
 	var proxyURLFunc func(*http.Request) (*url.URL, error)
+	dialContext := statStdDial
 	if pu := sw.ProxyURL.GetURL(); pu != nil {
-		proxyURLFunc = http.ProxyURL(pu)
+		if sw.ProxyURL.IsHTTPOrHTTPS() {
+			proxyURLFunc = http.ProxyURL(pu)
+		} else {
+			// net/http doesn't support all the socks5 proxy schemes, so dial targets via the proxy manually.
+			socksDialFunc, err := newStatDialFunc(sw.ProxyURL, sw.ProxyAuthConfig)
+			if err != nil {
+				logger.Fatalf("cannot create dial func: %s", err)
+			}
+			dialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return socksDialFunc(addr)
+			}
+		}
 	}
 	sc = &http.Client{
 		Transport: &http.Transport{
@@ -145,7 +175,7 @@ func newClient(ctx context.Context, sw *ScrapeWork) *client {
 			IdleConnTimeout:        2 * sw.ScrapeInterval,
 			DisableCompression:     *disableCompression || sw.DisableCompression,
 			DisableKeepAlives:      *disableKeepAlive || sw.DisableKeepAlive,
-			DialContext:            statStdDial,
+			DialContext:            dialContext,
 			MaxIdleConnsPerHost:    100,
 			MaxResponseHeaderBytes: int64(maxResponseHeadersSize.N),
 
@@ -177,9 +207,11 @@ func newClient(ctx context.Context, sw *ScrapeWork) *client {
 		setProxyHeaders:         setProxyHeaders,
 		setFasthttpHeaders:      func(req *fasthttp.Request) { sw.AuthConfig.SetFasthttpHeaders(req, true) },
 		setFasthttpProxyHeaders: setFasthttpProxyHeaders,
+		getAuthHeaderError:      sw.AuthConfig.GetAuthHeaderError,
 		denyRedirects:           sw.DenyRedirects,
 		disableCompression:      sw.DisableCompression,
 		disableKeepAlive:        sw.DisableKeepAlive,
+		proxyURL:                forwardProxyURL,
 	}
 }
 
@@ -201,6 +233,11 @@ func (c *client) GetStreamReader() (*streamReader, error) {
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1179#issuecomment-813117162
 	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", c.scrapeTimeoutSecondsStr)
 	c.setHeaders(req)
+	if err := c.getAuthHeaderError(); err != nil {
+		cancel()
+		scrapesAuthFailed.Inc()
+		return nil, fmt.Errorf("cannot scrape %q: %w", c.scrapeURL, err)
+	}
 	c.setProxyHeaders(req)
 	scrapeRequests.Inc()
 	resp, err := c.sc.Do(req)
@@ -213,6 +250,10 @@ func (c *client) GetStreamReader() (*streamReader, error) {
 		respBody, _ := io.ReadAll(resp.Body)
 		_ = resp.Body.Close()
 		cancel()
+		if resp.StatusCode == http.StatusProxyAuthRequired && c.proxyURL != "" {
+			return nil, fmt.Errorf("proxy %q rejected the request for scraping %q because of missing or invalid proxy auth; "+
+				"check `proxy_url` and `proxy_*` auth options; response body: %q", c.proxyURL, c.scrapeURL, respBody)
+		}
 		return nil, fmt.Errorf("unexpected status code returned when scraping %q: %d; expecting %d; response body: %q",
 			c.scrapeURL, resp.StatusCode, http.StatusOK, respBody)
 	}
@@ -249,6 +290,11 @@ func (c *client) ReadData(dst []byte) ([]byte, error) {
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1179#issuecomment-813117162
 	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", c.scrapeTimeoutSecondsStr)
 	c.setFasthttpHeaders(req)
+	if err := c.getAuthHeaderError(); err != nil {
+		fasthttp.ReleaseRequest(req)
+		scrapesAuthFailed.Inc()
+		return dst, fmt.Errorf("cannot scrape %q: %w", c.scrapeURL, err)
+	}
 	c.setFasthttpProxyHeaders(req)
 	if !*disableCompression && !c.disableCompression {
 		req.Header.Set("Accept-Encoding", "gzip")
@@ -335,6 +381,10 @@ func (c *client) ReadData(dst []byte) ([]byte, error) {
 	}
 	if statusCode != fasthttp.StatusOK {
 		metrics.GetOrCreateCounter(fmt.Sprintf(`vm_promscrape_scrapes_total{status_code="%d"}`, statusCode)).Inc()
+		if statusCode == fasthttp.StatusProxyAuthRequired && c.proxyURL != "" {
+			return dst, fmt.Errorf("proxy %q rejected the request for scraping %q because of missing or invalid proxy auth; "+
+				"check `proxy_url` and `proxy_*` auth options; response body: %q", c.proxyURL, c.scrapeURL, dst)
+		}
 		return dst, fmt.Errorf("unexpected status code returned when scraping %q: %d; expecting %d; response body: %q",
 			c.scrapeURL, statusCode, fasthttp.StatusOK, dst)
 	}
@@ -350,6 +400,7 @@ var (
 	scrapesOK             = metrics.NewCounter(`vm_promscrape_scrapes_total{status_code="200"}`)
 	scrapesGunzipped      = metrics.NewCounter(`vm_promscrape_scrapes_gunziped_total`)
 	scrapesGunzipFailed   = metrics.NewCounter(`vm_promscrape_scrapes_gunzip_failed_total`)
+	scrapesAuthFailed     = metrics.NewCounter(`vm_promscrape_scrapes_auth_failed_total`)
 	scrapeRequests        = metrics.NewCounter(`vm_promscrape_scrape_requests_total`)
 	scrapeRetries         = metrics.NewCounter(`vm_promscrape_scrape_retries_total`)
 )
@@ -249,6 +249,7 @@ type ScrapeConfig struct {
 	Params               map[string][]string         `yaml:"params,omitempty"`
 	HTTPClientConfig     promauth.HTTPClientConfig   `yaml:",inline"`
 	ProxyURL             *proxy.URL                  `yaml:"proxy_url,omitempty"`
+	NoProxy              []string                    `yaml:"no_proxy,omitempty"`
 	RelabelConfigs       []promrelabel.RelabelConfig `yaml:"relabel_configs,omitempty"`
 	MetricRelabelConfigs []promrelabel.RelabelConfig `yaml:"metric_relabel_configs,omitempty"`
 	SampleLimit          int                         `yaml:"sample_limit,omitempty"`
@@ -272,14 +273,17 @@ type ScrapeConfig struct {
 	YandexCloudSDConfigs  []yandexcloud.SDConfig  `yaml:"yandexcloud_sd_configs,omitempty"`
 
 	// These options are supported only by lib/promscrape.
-	DisableCompression  bool                       `yaml:"disable_compression,omitempty"`
-	DisableKeepAlive    bool                       `yaml:"disable_keepalive,omitempty"`
-	StreamParse         bool                       `yaml:"stream_parse,omitempty"`
-	ScrapeAlignInterval *promutils.Duration        `yaml:"scrape_align_interval,omitempty"`
-	ScrapeOffset        *promutils.Duration        `yaml:"scrape_offset,omitempty"`
-	SeriesLimit         int                        `yaml:"series_limit,omitempty"`
-	NoStaleMarkers      *bool                      `yaml:"no_stale_markers,omitempty"`
-	ProxyClientConfig   promauth.ProxyClientConfig `yaml:",inline"`
+	DisableCompression    bool                       `yaml:"disable_compression,omitempty"`
+	DisableKeepAlive      bool                       `yaml:"disable_keepalive,omitempty"`
+	StreamParse           bool                       `yaml:"stream_parse,omitempty"`
+	ScrapeAlignInterval   *promutils.Duration        `yaml:"scrape_align_interval,omitempty"`
+	ScrapeOffset          *promutils.Duration        `yaml:"scrape_offset,omitempty"`
+	SeriesLimit           int                        `yaml:"series_limit,omitempty"`
+	NoStaleMarkers        *bool                      `yaml:"no_stale_markers,omitempty"`
+	DuplicateSamplePolicy string                     `yaml:"duplicate_sample_policy,omitempty"`
+	DetectCounterResets   bool                       `yaml:"detect_counter_resets,omitempty"`
+	MaxScrapeSampleAge    *promutils.Duration        `yaml:"max_scrape_sample_age,omitempty"`
+	ProxyClientConfig     promauth.ProxyClientConfig `yaml:",inline"`
 
 	// This is set in loadConfig
 	swc *scrapeWorkConfig
@@ -383,6 +387,11 @@ func loadConfig(path string) (*Config, []byte, error) {
 	if err != nil {
 		return nil, nil, fmt.Errorf("cannot read Prometheus config from %q: %w", path, err)
 	}
+	if fs.IsHTTPURL(path) {
+		if err := promauth.CheckNoCredentialsExec(data); err != nil {
+			return nil, nil, fmt.Errorf("cannot load Prometheus config from %q: %w", path, err)
+		}
+	}
 	var c Config
 	dataNew, err := c.parseData(data, path)
 	if err != nil {
@@ -410,6 +419,11 @@ func loadScrapeConfigFiles(baseDir string, scrapeConfigFiles []string) ([]*Scrap
 			if err != nil {
 				return nil, nil, fmt.Errorf("cannot load %q: %w", path, err)
 			}
+			if fs.IsHTTPURL(path) {
+				if err := promauth.CheckNoCredentialsExec(data); err != nil {
+					return nil, nil, fmt.Errorf("cannot load %q: %w", path, err)
+				}
+			}
 			data, err = envtemplate.ReplaceBytes(data)
 			if err != nil {
 				return nil, nil, fmt.Errorf("cannot expand environment vars in %q: %w", path, err)
@@ -981,6 +995,9 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse proxy auth config for `job_name` %q: %w", jobName, err)
 	}
+	if err := proxy.CheckNoProxy(sc.NoProxy); err != nil {
+		return nil, fmt.Errorf("cannot parse `no_proxy` for `job_name` %q: %w", jobName, err)
+	}
 	relabelConfigs, err := promrelabel.ParseRelabelConfigs(sc.RelabelConfigs)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse `relabel_configs` for `job_name` %q: %w", jobName, err)
@@ -998,6 +1015,11 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 	if sc.SeriesLimit > 0 {
 		seriesLimit = sc.SeriesLimit
 	}
+	switch sc.DuplicateSamplePolicy {
+	case "", "first", "last", "max", "error":
+	default:
+		return nil, fmt.Errorf("unsupported `duplicate_sample_policy: %q` for `job_name` %q; supported values: first, last, max, error", sc.DuplicateSamplePolicy, jobName)
+	}
 	swc := &scrapeWorkConfig{
 		scrapeInterval:       scrapeInterval,
 		scrapeIntervalString: scrapeInterval.String(),
@@ -1008,6 +1030,7 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 		scheme:               scheme,
 		params:               params,
 		proxyURL:             sc.ProxyURL,
+		noProxy:              sc.NoProxy,
 		proxyAuthConfig:      proxyAC,
 		authConfig:           ac,
 		honorLabels:          honorLabels,
@@ -1024,6 +1047,9 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 		scrapeOffset:         sc.ScrapeOffset.Duration(),
 		seriesLimit:          seriesLimit,
 		noStaleMarkers:       noStaleTracking,
+		duplicatePolicy:      sc.DuplicateSamplePolicy,
+		detectCounterResets:  sc.DetectCounterResets,
+		maxScrapeSampleAge:   sc.MaxScrapeSampleAge.Duration(),
 	}
 	return swc, nil
 }
@@ -1038,6 +1064,7 @@ type scrapeWorkConfig struct {
 	scheme               string
 	params               map[string][]string
 	proxyURL             *proxy.URL
+	noProxy              []string
 	proxyAuthConfig      *promauth.Config
 	authConfig           *promauth.Config
 	honorLabels          bool
@@ -1054,6 +1081,9 @@ type scrapeWorkConfig struct {
 	scrapeOffset         time.Duration
 	seriesLimit          int
 	noStaleMarkers       bool
+	duplicatePolicy      string
+	detectCounterResets  bool
+	maxScrapeSampleAge   time.Duration
 }
 
 type targetLabelsGetter interface {
@@ -1247,9 +1277,23 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 		droppedTargetsMap.Register(originalLabels, swc.relabelConfigs)
 		return nil, nil
 	}
-	if _, err := url.Parse(scrapeURL); err != nil {
+	su, err := url.Parse(scrapeURL)
+	if err != nil {
 		return nil, fmt.Errorf("invalid target url=%q for job=%q: %w", scrapeURL, swc.jobName, err)
 	}
+	// Read proxy url from __proxy_url__ label, so it could be set individually per each target via relabeling.
+	// `no_proxy` isn't applied to __proxy_url__, since it is set explicitly for the target.
+	// See https://docs.victoriametrics.com/vmagent.html#scraping-targets-via-a-proxy
+	proxyURL := swc.proxyURL
+	if s := labels.Get("__proxy_url__"); len(s) > 0 {
+		pu, err := proxy.ParseURL(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse __proxy_url__ for job=%q: %w", swc.jobName, err)
+		}
+		proxyURL = pu
+	} else if proxy.IsNoProxyHost(su.Hostname(), swc.noProxy) {
+		proxyURL = nil
+	}
 
 	var at *auth.Token
 	tenantID := labels.Get("__tenant_id__")
@@ -1298,6 +1342,16 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 		}
 		streamParse = b
 	}
+	// Read honor_timestamps option from __honor_timestamps__ label.
+	// See https://docs.victoriametrics.com/vmagent.html#sample-timestamps
+	honorTimestamps := swc.honorTimestamps
+	if s := labels.Get("__honor_timestamps__"); len(s) > 0 {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse __honor_timestamps__=%q: %w", s, err)
+		}
+		honorTimestamps = b
+	}
 	// Remove labels with "__" prefix according to https://www.robustperception.io/life-of-a-label/
 	labels.RemoveLabelsWithDoubleUnderscorePrefix()
 	// Add missing "instance" label according to https://www.robustperception.io/life-of-a-label
@@ -1314,29 +1368,32 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 	labelsCopy.InternStrings()
 
 	sw := &ScrapeWork{
-		ScrapeURL:            scrapeURL,
-		ScrapeInterval:       scrapeInterval,
-		ScrapeTimeout:        scrapeTimeout,
-		HonorLabels:          swc.honorLabels,
-		HonorTimestamps:      swc.honorTimestamps,
-		DenyRedirects:        swc.denyRedirects,
-		OriginalLabels:       originalLabels,
-		Labels:               labelsCopy,
-		ExternalLabels:       swc.externalLabels,
-		ProxyURL:             swc.proxyURL,
-		ProxyAuthConfig:      swc.proxyAuthConfig,
-		AuthConfig:           swc.authConfig,
-		RelabelConfigs:       swc.relabelConfigs,
-		MetricRelabelConfigs: swc.metricRelabelConfigs,
-		SampleLimit:          swc.sampleLimit,
-		DisableCompression:   swc.disableCompression,
-		DisableKeepAlive:     swc.disableKeepAlive,
-		StreamParse:          streamParse,
-		ScrapeAlignInterval:  swc.scrapeAlignInterval,
-		ScrapeOffset:         swc.scrapeOffset,
-		SeriesLimit:          seriesLimit,
-		NoStaleMarkers:       swc.noStaleMarkers,
-		AuthToken:            at,
+		ScrapeURL:             scrapeURL,
+		ScrapeInterval:        scrapeInterval,
+		ScrapeTimeout:         scrapeTimeout,
+		HonorLabels:           swc.honorLabels,
+		HonorTimestamps:       honorTimestamps,
+		DenyRedirects:         swc.denyRedirects,
+		OriginalLabels:        originalLabels,
+		Labels:                labelsCopy,
+		ExternalLabels:        swc.externalLabels,
+		ProxyURL:              proxyURL,
+		ProxyAuthConfig:       swc.proxyAuthConfig,
+		AuthConfig:            swc.authConfig,
+		RelabelConfigs:        swc.relabelConfigs,
+		MetricRelabelConfigs:  swc.metricRelabelConfigs,
+		SampleLimit:           swc.sampleLimit,
+		DisableCompression:    swc.disableCompression,
+		DisableKeepAlive:      swc.disableKeepAlive,
+		StreamParse:           streamParse,
+		ScrapeAlignInterval:   swc.scrapeAlignInterval,
+		ScrapeOffset:          swc.scrapeOffset,
+		SeriesLimit:           seriesLimit,
+		NoStaleMarkers:        swc.noStaleMarkers,
+		DuplicateSamplePolicy: swc.duplicatePolicy,
+		DetectCounterResets:   swc.detectCounterResets,
+		MaxScrapeSampleAge:    swc.maxScrapeSampleAge,
+		AuthToken:             at,
 
 		jobNameOriginal: swc.jobName,
 	}
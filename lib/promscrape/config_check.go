@@ -0,0 +1,506 @@
+package promscrape
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var configCheck = flag.Bool("promscrape.config.check", false, "Validates -promscrape.config file offline and then exits. "+
+	"Service discovery isn't started and targets aren't scraped. The found problems are written to stdout in JSON with file, line and field per each problem. "+
+	"The exit code is 0 if there are no problems, 1 if there are errors and 2 if there are only warnings. "+
+	"Unsupported fields are reported as errors if -promscrape.config.strictParse is set, otherwise they are reported as warnings. "+
+	"See https://docs.victoriametrics.com/vmagent.html#checking-scrape-configs")
+
+// IsConfigCheck returns true if -promscrape.config.check command-line flag is set.
+func IsConfigCheck() bool {
+	return *configCheck
+}
+
+const (
+	// ConfigProblemError is the severity for problems, which prevent from loading the config.
+	ConfigProblemError = "error"
+	// ConfigProblemWarning is the severity for problems, which do not prevent from loading the config.
+	ConfigProblemWarning = "warning"
+)
+
+// ConfigProblem is a problem found in -promscrape.config by RunConfigCheck.
+type ConfigProblem struct {
+	// File is the path to the file with the problem.
+	File string `json:"file"`
+
+	// Line is the line number with the problem. It is set to 0 if the line is unknown.
+	Line int `json:"line,omitempty"`
+
+	// Field is the path to the field with the problem, for example, scrape_configs[0].relabel_configs[1].
+	Field string `json:"field,omitempty"`
+
+	// Severity is either ConfigProblemError or ConfigProblemWarning.
+	Severity string `json:"severity"`
+
+	Message string `json:"message"`
+}
+
+type configCheckResult struct {
+	Problems []ConfigProblem `json:"problems"`
+	Errors   int             `json:"errors"`
+	Warnings int             `json:"warnings"`
+}
+
+// RunConfigCheck validates -promscrape.config without starting service discovery and writes the found problems to w in JSON.
+//
+// It returns the exit code for the process: 0 if there are no problems, 1 if there are errors and 2 if there are only warnings.
+func RunConfigCheck(w io.Writer) int {
+	problems := checkConfigFile(*promscrapeConfigFile, *strictParse)
+	res := configCheckResult{
+		Problems: problems,
+	}
+	if res.Problems == nil {
+		res.Problems = []ConfigProblem{}
+	}
+	for _, p := range problems {
+		if p.Severity == ConfigProblemError {
+			res.Errors++
+		} else {
+			res.Warnings++
+		}
+	}
+	data, err := json.Marshal(&res)
+	if err != nil {
+		panic(fmt.Errorf("BUG: cannot marshal config check result: %w", err))
+	}
+	data = append(data, '\n')
+	if _, err := w.Write(data); err != nil {
+		return 1
+	}
+	switch {
+	case res.Errors > 0:
+		return 1
+	case res.Warnings > 0:
+		return 2
+	default:
+		return 0
+	}
+}
+
+type configChecker struct {
+	isStrict bool
+	problems []ConfigProblem
+
+	// jobLocations contains locations for the already checked job names.
+	jobLocations map[string]string
+}
+
+// checkConfigFile returns problems found in Prometheus config at the given path.
+func checkConfigFile(path string, isStrict bool) []ConfigProblem {
+	cc := &configChecker{
+		isStrict:     isStrict,
+		jobLocations: make(map[string]string),
+	}
+	cc.checkConfig(path)
+	return cc.problems
+}
+
+func (cc *configChecker) addProblem(file string, line int, field, severity, format string, args ...interface{}) {
+	cc.problems = append(cc.problems, ConfigProblem{
+		File:     file,
+		Line:     line,
+		Field:    field,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (cc *configChecker) checkConfig(path string) {
+	if path == "" {
+		cc.addProblem("", 0, "", ConfigProblemError, "missing -promscrape.config command-line flag")
+		return
+	}
+	data, err := fs.ReadFileOrHTTP(path)
+	if err != nil {
+		cc.addProblem(path, 0, "", ConfigProblemError, "cannot read Prometheus config: %s", err)
+		return
+	}
+	var cfg Config
+	fields, ok := cc.unmarshal(path, data, &cfg, "", cc.isStrict)
+	if !ok {
+		return
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		cc.addProblem(path, 0, "", ConfigProblemError, "cannot obtain abs path: %s", err)
+		return
+	}
+	baseDir := filepath.Dir(absPath)
+	cc.checkScrapeConfigs(path, cfg.ScrapeConfigs, fields, baseDir, &cfg.Global)
+
+	for i, file := range cfg.ScrapeConfigFiles {
+		field := fmt.Sprintf("scrape_config_files[%d]", i)
+		line := fields.getLine(field)
+		pattern := fs.GetFilepath(baseDir, file)
+		paths := []string{pattern}
+		if strings.Contains(pattern, "*") {
+			ps, err := filepath.Glob(pattern)
+			if err != nil {
+				cc.addProblem(path, line, field, ConfigProblemError, "invalid pattern %q: %s", pattern, err)
+				continue
+			}
+			if len(ps) == 0 {
+				cc.addProblem(path, line, field, ConfigProblemWarning, "no files match the pattern %q", pattern)
+				continue
+			}
+			sort.Strings(ps)
+			paths = ps
+		}
+		for _, scPath := range paths {
+			data, err := fs.ReadFileOrHTTP(scPath)
+			if err != nil {
+				cc.addProblem(path, line, field, ConfigProblemError, "cannot load %q: %s", scPath, err)
+				continue
+			}
+			var scs []*ScrapeConfig
+			// Files from `scrape_config_files` are always parsed in strict mode - see loadScrapeConfigFiles.
+			scFields, ok := cc.unmarshal(scPath, data, &scs, "scrape_configs", true)
+			if !ok {
+				continue
+			}
+			cc.checkScrapeConfigs(scPath, scs, scFields, baseDir, &cfg.Global)
+		}
+	}
+}
+
+// unmarshal unmarshals data from the given file into dst and returns fields found in data.
+//
+// false is returned if data cannot be unmarshaled, so further checks make no sense.
+func (cc *configChecker) unmarshal(file string, data []byte, dst interface{}, rootPath string, isStrict bool) (*yamlFields, bool) {
+	data, err := envtemplate.ReplaceBytes(data)
+	if err != nil {
+		cc.addProblem(file, 0, "", ConfigProblemError, "cannot expand environment variables: %s", err)
+		return nil, false
+	}
+	err = yaml.UnmarshalStrict(data, dst)
+	te, ok := err.(*yaml.TypeError)
+	if err != nil && !ok {
+		cc.addProblem(file, getYAMLErrorLine(err.Error()), "", ConfigProblemError, "cannot parse yaml: %s", err)
+		return nil, false
+	}
+	fields := newYAMLFields(data, rootPath)
+	if err == nil {
+		return fields, true
+	}
+	// yaml.TypeError is returned after decoding as much data as possible,
+	// so continue checking the partially unmarshaled dst in order to find more problems.
+	for _, s := range te.Errors {
+		n := yamlErrorLineRe.FindStringSubmatch(s)
+		if n == nil {
+			cc.addProblem(file, 0, "", ConfigProblemError, "%s", s)
+			continue
+		}
+		line, _ := strconv.Atoi(n[1])
+		msg := n[2]
+		m := yamlUnknownFieldRe.FindStringSubmatch(msg)
+		if m == nil {
+			cc.addProblem(file, line, fields.getPathForKey(line, ""), ConfigProblemError, "%s", msg)
+			continue
+		}
+		field := fields.getPathForKey(line, m[1])
+		if field == "" {
+			field = m[1]
+		}
+		if isStrict {
+			cc.addProblem(file, line, field, ConfigProblemError, "unsupported field %q; pass -promscrape.config.strictParse=false command-line flag for ignoring unknown fields in yaml config", m[1])
+		} else {
+			cc.addProblem(file, line, field, ConfigProblemWarning, "unsupported field %q is ignored", m[1])
+		}
+	}
+	return fields, true
+}
+
+var (
+	yamlErrorLineRe    = regexp.MustCompile(`^line (\d+): (.+)$`)
+	yamlUnknownFieldRe = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+	yamlAnyLineRe      = regexp.MustCompile(`line (\d+):`)
+)
+
+func getYAMLErrorLine(s string) int {
+	m := yamlAnyLineRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+func (cc *configChecker) checkScrapeConfigs(file string, scs []*ScrapeConfig, fields *yamlFields, baseDir string, globalCfg *GlobalConfig) {
+	for i, sc := range scs {
+		if sc == nil {
+			continue
+		}
+		path := fmt.Sprintf("scrape_configs[%d]", i)
+		if jobName := sc.JobName; jobName != "" {
+			jobField := path + ".job_name"
+			jobLine := fields.getLine(jobField)
+			if location, ok := cc.jobLocations[jobName]; ok {
+				cc.addProblem(file, jobLine, jobField, ConfigProblemError, "duplicate `job_name` %q; it is already defined at %s", jobName, location)
+			} else {
+				cc.jobLocations[jobName] = fmt.Sprintf("%s:%d", file, jobLine)
+			}
+		}
+		cc.checkRelabelConfigs(file, fields, path+".relabel_configs", sc.RelabelConfigs)
+		cc.checkRelabelConfigs(file, fields, path+".metric_relabel_configs", sc.MetricRelabelConfigs)
+		if !cc.checkReferencedFiles(file, fields, path, baseDir) {
+			// getScrapeWorkConfig would fail on the missing files without pointing to the exact field.
+			continue
+		}
+
+		// Relabel configs are already checked above with more precise locations.
+		scCopy := *sc
+		scCopy.RelabelConfigs = nil
+		scCopy.MetricRelabelConfigs = nil
+		if _, err := getScrapeWorkConfig(&scCopy, baseDir, globalCfg); err != nil {
+			cc.addProblem(file, fields.getLine(path), path, ConfigProblemError, "%s", err)
+		}
+	}
+}
+
+// checkReferencedFiles checks files referenced by the scrape config at the given path.
+//
+// false is returned if some of the referenced files are missing.
+func (cc *configChecker) checkReferencedFiles(file string, fields *yamlFields, path, baseDir string) bool {
+	ok := true
+	prefix := path + "."
+	for _, f := range fields.items {
+		if f.key == "" || !strings.HasPrefix(f.path, prefix) {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(f.key, "_file"):
+			if !cc.checkReferencedFile(file, f, baseDir) {
+				ok = false
+			}
+		case f.key == "files" && strings.Contains(f.path, ".file_sd_configs["):
+			cc.checkFileSDFiles(file, fields, f, baseDir)
+		}
+	}
+	return ok
+}
+
+func (cc *configChecker) checkRelabelConfigs(file string, fields *yamlFields, path string, rcs []promrelabel.RelabelConfig) {
+	for i := range rcs {
+		rcPath := fmt.Sprintf("%s[%d]", path, i)
+		if _, err := promrelabel.ParseRelabelConfigs(rcs[i : i+1]); err != nil {
+			// Strip the misleading "relabel_config #1" prefix, since the location is already known.
+			if e := errors.Unwrap(err); e != nil {
+				err = e
+			}
+			cc.addProblem(file, fields.getLine(rcPath), rcPath, ConfigProblemError, "%s", err)
+		}
+	}
+}
+
+// checkReferencedFile verifies that the file referenced by f exists.
+func (cc *configChecker) checkReferencedFile(file string, f *yamlField, baseDir string) bool {
+	if f.value == "" {
+		return true
+	}
+	path := fs.GetFilepath(baseDir, f.value)
+	if isHTTPPath(path) {
+		return true
+	}
+	if _, err := os.Stat(path); err != nil {
+		cc.addProblem(file, f.line, f.path, ConfigProblemError, "cannot access the file referenced by `%s`: %s", f.key, err)
+		return false
+	}
+	return true
+}
+
+// checkFileSDFiles verifies files referenced by `files` list at `file_sd_configs`.
+//
+// Missing files are reported as warnings, since vmagent skips them until they are created.
+func (cc *configChecker) checkFileSDFiles(file string, fields *yamlFields, f *yamlField, baseDir string) {
+	for i := 0; ; i++ {
+		field := fmt.Sprintf("%s[%d]", f.path, i)
+		item := fields.byPath[field]
+		if item == nil {
+			return
+		}
+		if item.value == "" {
+			continue
+		}
+		pattern := fs.GetFilepath(baseDir, item.value)
+		paths := []string{pattern}
+		if strings.Contains(pattern, "*") {
+			ps, err := filepath.Glob(pattern)
+			if err != nil {
+				cc.addProblem(file, item.line, field, ConfigProblemError, "invalid pattern %q: %s", pattern, err)
+				continue
+			}
+			if len(ps) == 0 {
+				cc.addProblem(file, item.line, field, ConfigProblemWarning, "no files match the pattern %q", pattern)
+				continue
+			}
+			sort.Strings(ps)
+			paths = ps
+		} else if _, err := os.Stat(pattern); err != nil {
+			cc.addProblem(file, item.line, field, ConfigProblemWarning, "cannot access the file: %s", err)
+			continue
+		}
+		for _, path := range paths {
+			if _, err := loadStaticConfigs(path); err != nil {
+				cc.addProblem(path, getYAMLErrorLine(err.Error()), "", ConfigProblemError, "cannot load the file referenced by %s at %s:%d: %s", field, file, item.line, err)
+			}
+		}
+	}
+}
+
+func isHTTPPath(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// yamlFields holds locations for fields in yaml document.
+//
+// yaml.v2 doesn't expose locations for the parsed fields, so they are obtained from yaml.v3 nodes.
+// Fields from aliases and merged anchors get the locations of the referenced anchors.
+type yamlFields struct {
+	// items contains fields in the order they appear in the document.
+	items  []*yamlField
+	byPath map[string]*yamlField
+}
+
+// yamlField is either a mapping value or a sequence item in yaml document.
+type yamlField struct {
+	path string
+
+	// key is empty for sequence items.
+	key string
+
+	// value is the scalar value for the field. It is empty for collections.
+	value string
+
+	line int
+}
+
+// newYAMLFields returns fields found in yaml data. Paths for the found fields start with rootPath.
+//
+// Empty fields are returned if data cannot be parsed.
+func newYAMLFields(data []byte, rootPath string) *yamlFields {
+	yf := &yamlFields{
+		byPath: make(map[string]*yamlField),
+	}
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &root); err != nil {
+		return yf
+	}
+	yf.addNode(rootPath, &root)
+	return yf
+}
+
+func (yf *yamlFields) addNode(path string, n *yamlv3.Node) {
+	n = resolveYAMLAlias(n)
+	switch n.Kind {
+	case yamlv3.DocumentNode:
+		for _, c := range n.Content {
+			yf.addNode(path, c)
+		}
+	case yamlv3.SequenceNode:
+		for i, c := range n.Content {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			yf.add(itemPath, "", getYAMLScalarValue(c), c.Line)
+			yf.addNode(itemPath, c)
+		}
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Tag == "!!merge" {
+				yf.addMergedNodes(path, v)
+				continue
+			}
+			fieldPath := k.Value
+			if path != "" {
+				fieldPath = path + "." + k.Value
+			}
+			yf.add(fieldPath, k.Value, getYAMLScalarValue(v), k.Line)
+			yf.addNode(fieldPath, v)
+		}
+	}
+}
+
+// addMergedNodes adds fields from mappings merged via `<<` key into the mapping at the given path.
+func (yf *yamlFields) addMergedNodes(path string, n *yamlv3.Node) {
+	n = resolveYAMLAlias(n)
+	if n.Kind == yamlv3.SequenceNode {
+		for _, c := range n.Content {
+			yf.addNode(path, c)
+		}
+		return
+	}
+	yf.addNode(path, n)
+}
+
+func resolveYAMLAlias(n *yamlv3.Node) *yamlv3.Node {
+	for n.Kind == yamlv3.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+func getYAMLScalarValue(n *yamlv3.Node) string {
+	n = resolveYAMLAlias(n)
+	if n.Kind != yamlv3.ScalarNode {
+		return ""
+	}
+	return n.Value
+}
+
+func (yf *yamlFields) add(path, key, value string, line int) {
+	f := &yamlField{
+		path:  path,
+		key:   key,
+		value: value,
+		line:  line,
+	}
+	yf.items = append(yf.items, f)
+	if _, ok := yf.byPath[path]; !ok {
+		yf.byPath[path] = f
+	}
+}
+
+// getLine returns the line for the field at the given path.
+//
+// 0 is returned if the field is missing.
+func (yf *yamlFields) getLine(path string) int {
+	f := yf.byPath[path]
+	if f == nil {
+		return 0
+	}
+	return f.line
+}
+
+// getPathForKey returns the path for the given key at the given line.
+//
+// The path for the first field at the given line is returned if key is empty.
+func (yf *yamlFields) getPathForKey(line int, key string) string {
+	for _, f := range yf.items {
+		if f.key == "" || f.line != line {
+			continue
+		}
+		if key == "" || f.key == key {
+			return f.path
+		}
+	}
+	return ""
+}
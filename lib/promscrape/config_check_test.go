@@ -0,0 +1,288 @@
+package promscrape
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, data string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("cannot write %q: %s", path, err)
+		}
+		return path
+	}
+	writeFile("token", "secret")
+	writeFile("extra.yml", `
+- job_name: foo
+  static_configs:
+  - targets: [bar]
+`)
+	type problem struct {
+		line     int
+		field    string
+		severity string
+	}
+	f := func(data string, isStrict bool, problemsExpected []problem) {
+		t.Helper()
+		path := writeFile("config.yml", data)
+		problems := checkConfigFile(path, isStrict)
+		if len(problems) != len(problemsExpected) {
+			t.Fatalf("unexpected number of problems; got %d; want %d; problems: %+v", len(problems), len(problemsExpected), problems)
+		}
+		for i, p := range problems {
+			pe := problemsExpected[i]
+			if p.Line != pe.line || p.Field != pe.field || p.Severity != pe.severity {
+				t.Fatalf("unexpected problem #%d; got line=%d, field=%q, severity=%q; want line=%d, field=%q, severity=%q; message: %s",
+					i, p.Line, p.Field, p.Severity, pe.line, pe.field, pe.severity, p.Message)
+			}
+		}
+	}
+
+	// Valid config
+	f(`
+scrape_configs:
+- job_name: foo
+  bearer_token_file: token
+  static_configs:
+  - targets: [bar]
+`, true, nil)
+
+	// Invalid yaml
+	f(`
+scrape_configs:
+- job_name: foo
+  static_configs: [
+`, true, []problem{
+		{4, "", ConfigProblemError},
+	})
+
+	// Unsupported fields
+	f(`
+scrape_configs:
+- job_name: foo
+  foo_bar: baz
+`, true, []problem{
+		{4, "scrape_configs[0].foo_bar", ConfigProblemError},
+	})
+	f(`
+scrape_configs:
+- job_name: foo
+  foo_bar: baz
+`, false, []problem{
+		{4, "scrape_configs[0].foo_bar", ConfigProblemWarning},
+	})
+
+	// Invalid relabeling regex
+	f(`
+scrape_configs:
+- job_name: foo
+  relabel_configs:
+  - action: keep
+    source_labels: [foo]
+  - action: keep
+    source_labels: [foo]
+    regex: "a(b"
+`, true, []problem{
+		{7, "scrape_configs[0].relabel_configs[1]", ConfigProblemError},
+	})
+
+	// Invalid scrape config
+	f(`
+scrape_configs:
+- job_name: foo
+  scheme: ftp
+`, true, []problem{
+		{3, "scrape_configs[0]", ConfigProblemError},
+	})
+
+	// Duplicate job names, including job names from scrape_config_files
+	f(`
+scrape_configs:
+- job_name: foo
+- job_name: foo
+scrape_config_files:
+- extra.yml
+`, true, []problem{
+		{4, "scrape_configs[1].job_name", ConfigProblemError},
+		{2, "scrape_configs[0].job_name", ConfigProblemError},
+	})
+
+	// Missing referenced files
+	f(`
+scrape_configs:
+- job_name: foo
+  basic_auth:
+    username: foo
+    password_file: missing-password
+  file_sd_configs:
+  - files:
+    - missing.json
+    - sd/*.yml
+scrape_config_files:
+- missing/*.yml
+`, true, []problem{
+		{6, "scrape_configs[0].basic_auth.password_file", ConfigProblemError},
+		{9, "scrape_configs[0].file_sd_configs[0].files[0]", ConfigProblemWarning},
+		{10, "scrape_configs[0].file_sd_configs[0].files[1]", ConfigProblemWarning},
+		{12, "scrape_config_files[0]", ConfigProblemWarning},
+	})
+}
+
+func TestRunConfigCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	origPath := *promscrapeConfigFile
+	*promscrapeConfigFile = path
+	defer func() {
+		*promscrapeConfigFile = origPath
+	}()
+
+	f := func(data string, exitCodeExpected, errorsExpected, warningsExpected int) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("cannot write %q: %s", path, err)
+		}
+		var bb bytes.Buffer
+		exitCode := RunConfigCheck(&bb)
+		if exitCode != exitCodeExpected {
+			t.Fatalf("unexpected exit code; got %d; want %d; output: %s", exitCode, exitCodeExpected, bb.String())
+		}
+		var res configCheckResult
+		if err := json.Unmarshal(bb.Bytes(), &res); err != nil {
+			t.Fatalf("cannot unmarshal output %q: %s", bb.String(), err)
+		}
+		if res.Errors != errorsExpected || res.Warnings != warningsExpected {
+			t.Fatalf("unexpected number of errors and warnings; got %d, %d; want %d, %d", res.Errors, res.Warnings, errorsExpected, warningsExpected)
+		}
+	}
+	f(`
+scrape_configs:
+- job_name: foo
+`, 0, 0, 0)
+	f(`
+scrape_configs:
+- job_name: foo
+  file_sd_configs:
+  - files: [missing.json]
+`, 2, 0, 1)
+	f(`
+scrape_configs:
+- job_name: foo
+  scheme: ftp
+  file_sd_configs:
+  - files: [missing.json]
+`, 1, 1, 1)
+}
+
+func TestNewYAMLFields(t *testing.T) {
+	f := func(data, rootPath string, linesExpected map[string]int, valuesExpected map[string]string) {
+		t.Helper()
+		fields := newYAMLFields([]byte(data), rootPath)
+		for path, lineExpected := range linesExpected {
+			if line := fields.getLine(path); line != lineExpected {
+				t.Fatalf("unexpected line for %q; got %d; want %d", path, line, lineExpected)
+			}
+		}
+		for path, valueExpected := range valuesExpected {
+			f := fields.byPath[path]
+			if f == nil {
+				t.Fatalf("missing field %q", path)
+			}
+			if f.value != valueExpected {
+				t.Fatalf("unexpected value for %q; got %q; want %q", path, f.value, valueExpected)
+			}
+		}
+	}
+
+	// Block-style collections with sequences at the same indentation as the parent key
+	f(`
+# comment
+scrape_configs:
+- job_name: foo # comment
+  static_configs:
+    - targets:
+      - "host:80"
+  file_sd_configs:
+  - files: ['a.json', "b # c.json"]
+-
+  job_name: "bar"
+  'basic_auth':
+    password_file: /path/to/file
+`, "", map[string]int{
+		"scrape_configs":                                 3,
+		"scrape_configs[0]":                              4,
+		"scrape_configs[0].job_name":                     4,
+		"scrape_configs[0].static_configs[0]":            6,
+		"scrape_configs[0].static_configs[0].targets[0]": 7,
+		"scrape_configs[0].file_sd_configs[0].files":     9,
+		"scrape_configs[0].file_sd_configs[0].files[1]":  9,
+		"scrape_configs[1]":                              11,
+		"scrape_configs[1].job_name":                     11,
+		"scrape_configs[1].basic_auth.password_file":     13,
+	}, map[string]string{
+		"scrape_configs[0].job_name":                     "foo",
+		"scrape_configs[0].static_configs[0].targets[0]": "host:80",
+		"scrape_configs[0].file_sd_configs[0].files[0]":  "a.json",
+		"scrape_configs[0].file_sd_configs[0].files[1]":  "b # c.json",
+		"scrape_configs[1].job_name":                     "bar",
+		"scrape_configs[1].basic_auth.password_file":     "/path/to/file",
+	})
+
+	// Block scalars, multi-line flow collections, aliases and root sequence
+	f(`
+- job_name: foo
+  relabel_configs: &rcs
+  - replacement: |
+      - job_name: bar
+    target_label: x
+  static_configs: [
+    {targets: [a]},
+  ]
+  metric_relabel_configs: *rcs
+- job_name: baz
+`, "scrape_configs", map[string]int{
+		"scrape_configs[0].relabel_configs[0]":              4,
+		"scrape_configs[0].relabel_configs[0].target_label": 6,
+		"scrape_configs[0].static_configs":                  7,
+		"scrape_configs[0].static_configs[0].targets[0]":    8,
+		"scrape_configs[0].metric_relabel_configs":          10,
+		"scrape_configs[0].metric_relabel_configs[0]":       4,
+		"scrape_configs[1].job_name":                        11,
+		"scrape_configs[2].job_name":                        0,
+	}, map[string]string{
+		"scrape_configs[0].relabel_configs[0].target_label":        "x",
+		"scrape_configs[0].relabel_configs[0].replacement":         "- job_name: bar\n",
+		"scrape_configs[0].static_configs[0].targets[0]":           "a",
+		"scrape_configs[0].metric_relabel_configs[0].target_label": "x",
+		"scrape_configs[1].job_name":                               "baz",
+	})
+
+	// Merged anchors and flow mappings
+	f(`
+defaults: &defaults
+  basic_auth: {username: foo, password_file: /path/to/file}
+scrape_configs:
+- <<: *defaults
+  job_name: foo
+- {job_name: bar, tls_config: {ca_file: ca.pem}}
+`, "", map[string]int{
+		"scrape_configs[0].basic_auth.password_file": 3,
+		"scrape_configs[0].job_name":                 6,
+		"scrape_configs[1].job_name":                 7,
+		"scrape_configs[1].tls_config.ca_file":       7,
+	}, map[string]string{
+		"scrape_configs[0].basic_auth.password_file": "/path/to/file",
+		"scrape_configs[1].tls_config.ca_file":       "ca.pem",
+	})
+
+	// Invalid yaml
+	f("scrape_configs:\n\t- job_name: foo\n", "", map[string]int{
+		"scrape_configs": 0,
+	}, nil)
+}
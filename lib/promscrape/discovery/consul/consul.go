@@ -22,6 +22,9 @@ type SDConfig struct {
 	// Partition only supported at enteprise consul.
 	// https://developer.hashicorp.com/consul/docs/enterprise/admin-partitions
 	Partition string `yaml:"partition,omitempty"`
+	// Filter is applied by Consul to catalog and health API responses.
+	// https://developer.hashicorp.com/consul/api-docs/features/filtering
+	Filter string `yaml:"filter,omitempty"`
 
 	Scheme            string                     `yaml:"scheme,omitempty"`
 	Username          string                     `yaml:"username"`
@@ -59,6 +59,9 @@ func newConsulWatcher(client *discoveryutils.Client, sdc *SDConfig, datacenter,
 	if sdc.Partition != "" {
 		baseQueryArgs += "&partition=" + url.QueryEscape(sdc.Partition)
 	}
+	if sdc.Filter != "" {
+		baseQueryArgs += "&filter=" + url.QueryEscape(sdc.Filter)
+	}
 	for k, v := range sdc.NodeMeta {
 		baseQueryArgs += "&node-meta=" + url.QueryEscape(k+":"+v)
 	}
@@ -0,0 +1,111 @@
+package consul
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func TestConsulWatcherNamespaceFilter(t *testing.T) {
+	const filter = `ServiceMeta.env == "prod"`
+	var queryErrsLock sync.Mutex
+	var queryErrs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		for k, v := range map[string]string{
+			"dc":        "dc1",
+			"ns":        "ns-dev",
+			"partition": "part-foobar",
+			"filter":    filter,
+		} {
+			if got := q.Get(k); got != v {
+				queryErrsLock.Lock()
+				queryErrs = append(queryErrs, fmt.Sprintf("unexpected %q query arg at %q; got %q; want %q", k, r.URL.Path, got, v))
+				queryErrsLock.Unlock()
+			}
+		}
+		w.Header().Set("X-Consul-Index", "10")
+		switch r.URL.Path {
+		case "/v1/catalog/services":
+			fmt.Fprintf(w, `{"redis":["primary"]}`)
+		case "/v1/health/service/redis":
+			fmt.Fprintf(w, `[
+  {
+    "Node": {
+      "Node": "foobar",
+      "Address": "10.1.10.12",
+      "Datacenter": "dc1"
+    },
+    "Service": {
+      "ID": "redis",
+      "Service": "redis",
+      "Tags": ["primary"],
+      "Address": "10.1.10.12",
+      "Meta": {
+        "env": "prod"
+      },
+      "Port": 8000,
+      "Namespace": "ns-dev",
+      "Partition": "part-foobar"
+    },
+    "Checks": [
+      {
+        "Node": "foobar",
+        "CheckID": "service:redis",
+        "Status": "passing",
+        "ServiceID": "redis",
+        "ServiceName": "redis",
+        "Namespace": "ns-dev"
+      }
+    ]
+  }
+]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	sdc := &SDConfig{
+		Server:     srv.URL,
+		Datacenter: "dc1",
+		Namespace:  "ns-dev",
+		Partition:  "part-foobar",
+		Filter:     filter,
+	}
+	labelss, err := sdc.GetLabels("")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sdc.MustStop()
+	queryErrsLock.Lock()
+	defer queryErrsLock.Unlock()
+	for _, e := range queryErrs {
+		t.Errorf("%s", e)
+	}
+	expectedLabelss := []*promutils.Labels{
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                        "10.1.10.12:8000",
+			"__meta_consul_address":              "10.1.10.12",
+			"__meta_consul_dc":                   "dc1",
+			"__meta_consul_health":               "passing",
+			"__meta_consul_namespace":            "ns-dev",
+			"__meta_consul_node":                 "foobar",
+			"__meta_consul_partition":            "part-foobar",
+			"__meta_consul_service":              "redis",
+			"__meta_consul_service_address":      "10.1.10.12",
+			"__meta_consul_service_id":           "redis",
+			"__meta_consul_service_metadata_env": "prod",
+			"__meta_consul_service_port":         "8000",
+			"__meta_consul_tag_primary":          "",
+			"__meta_consul_tagpresent_primary":   "true",
+			"__meta_consul_tags":                 ",primary,",
+		}),
+	}
+	discoveryutils.TestEqualLabelss(t, labelss, expectedLabelss)
+}
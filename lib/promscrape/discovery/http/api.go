@@ -1,12 +1,17 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutils"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
 	"github.com/VictoriaMetrics/metrics"
@@ -18,8 +23,27 @@ type apiConfig struct {
 	client *discoveryutils.Client
 	path   string
 
-	fetchErrors *metrics.Counter
-	parseErrors *metrics.Counter
+	fetchErrors    *metrics.Counter
+	parseErrors    *metrics.Counter
+	skippedEntries *metrics.Counter
+
+	// lastSuccessTimestamp holds unix timestamp in seconds for the last successful fetch of targets.
+	lastSuccessTimestamp *metrics.Counter
+
+	// mu protects the fields below
+	mu sync.Mutex
+
+	// tgs contains the last successfully fetched targets.
+	// They are returned on fetch errors, so the targets do not disappear on temporary http_sd errors.
+	tgs []httpGroupTarget
+
+	// hasTargets is set to true after the first successful fetch of targets.
+	hasTargets bool
+
+	// etag and lastModified are obtained from the last successful response.
+	// They are used for sending conditional requests to http_sd url.
+	etag         string
+	lastModified string
 }
 
 // httpGroupTarget represent prometheus GroupTarget
@@ -49,10 +73,12 @@ func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
 		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
 	}
 	cfg := &apiConfig{
-		client:      client,
-		path:        parsedURL.RequestURI(),
-		fetchErrors: metrics.GetOrCreateCounter(fmt.Sprintf(`promscrape_discovery_http_errors_total{type="fetch",url=%q}`, sdc.URL)),
-		parseErrors: metrics.GetOrCreateCounter(fmt.Sprintf(`promscrape_discovery_http_errors_total{type="parse",url=%q}`, sdc.URL)),
+		client:               client,
+		path:                 parsedURL.RequestURI(),
+		fetchErrors:          metrics.GetOrCreateCounter(fmt.Sprintf(`promscrape_discovery_http_errors_total{type="fetch",url=%q}`, sdc.URL)),
+		parseErrors:          metrics.GetOrCreateCounter(fmt.Sprintf(`promscrape_discovery_http_errors_total{type="parse",url=%q}`, sdc.URL)),
+		skippedEntries:       metrics.GetOrCreateCounter(fmt.Sprintf(`promscrape_discovery_http_skipped_target_groups_total{url=%q}`, sdc.URL)),
+		lastSuccessTimestamp: metrics.GetOrCreateCounter(fmt.Sprintf(`promscrape_discovery_http_last_successful_fetch_timestamp_seconds{url=%q}`, sdc.URL)),
 	}
 	return cfg, nil
 }
@@ -66,26 +92,102 @@ func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
 }
 
 func getHTTPTargets(cfg *apiConfig) ([]httpGroupTarget, error) {
-	data, err := cfg.client.GetAPIResponseWithReqParams(cfg.path, func(request *http.Request) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	statusCode := 0
+	var etag, lastModified string
+	data, err := cfg.client.GetAPIResponseWithParamsCtx(cfg.client.Context(), cfg.path, func(request *http.Request) {
 		request.Header.Set("X-Prometheus-Refresh-Interval-Seconds", strconv.FormatFloat(SDCheckInterval.Seconds(), 'f', 0, 64))
 		request.Header.Set("Accept", "application/json")
+		if cfg.hasTargets {
+			if cfg.etag != "" {
+				request.Header.Set("If-None-Match", cfg.etag)
+			}
+			if cfg.lastModified != "" {
+				request.Header.Set("If-Modified-Since", cfg.lastModified)
+			}
+		}
+	}, func(resp *http.Response) {
+		statusCode = resp.StatusCode
+		etag = resp.Header.Get("ETag")
+		lastModified = resp.Header.Get("Last-Modified")
 	})
 	if err != nil {
+		if statusCode == http.StatusNotModified && cfg.hasTargets {
+			// The targets didn't change since the last successful fetch.
+			cfg.lastSuccessTimestamp.Set(fasttime.UnixTimestamp())
+			return cfg.tgs, nil
+		}
 		cfg.fetchErrors.Inc()
-		return nil, fmt.Errorf("cannot read http_sd api response: %w", err)
+		err = fmt.Errorf("cannot read http_sd api response: %w", err)
+		return cfg.getPrevTargetsLocked(err)
 	}
-	tg, err := parseAPIResponse(data, cfg.path)
+	tgs, skipped, err := parseAPIResponse(data, cfg.path)
+	cfg.skippedEntries.Add(skipped)
 	if err != nil {
 		cfg.parseErrors.Inc()
+		return cfg.getPrevTargetsLocked(err)
+	}
+	cfg.tgs = tgs
+	cfg.hasTargets = true
+	cfg.etag = etag
+	cfg.lastModified = lastModified
+	cfg.lastSuccessTimestamp.Set(fasttime.UnixTimestamp())
+	return tgs, nil
+}
+
+// getPrevTargetsLocked returns the last successfully fetched targets if the fetch fails with err.
+//
+// err is returned if there are no previously fetched targets.
+func (cfg *apiConfig) getPrevTargetsLocked(err error) ([]httpGroupTarget, error) {
+	if !cfg.hasTargets {
 		return nil, err
 	}
-	return tg, nil
+	staleness := time.Duration(fasttime.UnixTimestamp()-cfg.lastSuccessTimestamp.Get()) * time.Second
+	logger.Errorf("%s; using the previously fetched targets obtained %s ago", err, staleness)
+	return cfg.tgs, nil
+}
+
+// parseAPIResponse parses http_sd response from data.
+//
+// Target groups, which cannot be parsed, are skipped. The number of skipped target groups is returned in skipped.
+// An error is returned only if data isn't a valid JSON array.
+func parseAPIResponse(data []byte, path string) (tgs []httpGroupTarget, skipped int, err error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, 0, fmt.Errorf("cannot parse http_sd api response path: %s, err:  %w", path, err)
+	}
+	tgs = make([]httpGroupTarget, 0, len(entries))
+	for i, entry := range entries {
+		var tg httpGroupTarget
+		d := json.NewDecoder(bytes.NewReader(entry))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&tg); err != nil {
+			// Check whether the entry contains only unknown fields, which can be ignored.
+			tg = httpGroupTarget{}
+			if errLax := json.Unmarshal(entry, &tg); errLax != nil {
+				logger.Warnf("skipping target group #%d from http_sd api response path: %s, err: %s", i+1, path, errLax)
+				skipped++
+				continue
+			}
+			logger.Warnf("ignoring unknown fields in target group #%d from http_sd api response path: %s, err: %s", i+1, path, err)
+		}
+		if err := tg.validate(); err != nil {
+			logger.Warnf("skipping target group #%d from http_sd api response path: %s, err: %s", i+1, path, err)
+			skipped++
+			continue
+		}
+		tgs = append(tgs, tg)
+	}
+	return tgs, skipped, nil
 }
 
-func parseAPIResponse(data []byte, path string) ([]httpGroupTarget, error) {
-	var r []httpGroupTarget
-	if err := json.Unmarshal(data, &r); err != nil {
-		return nil, fmt.Errorf("cannot parse http_sd api response path: %s, err:  %w", path, err)
+func (tg *httpGroupTarget) validate() error {
+	for _, target := range tg.Targets {
+		if target == "" {
+			return fmt.Errorf("`targets` cannot contain empty values")
+		}
 	}
-	return r, nil
+	return nil
 }
@@ -1,7 +1,10 @@
 package http
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"sync/atomic"
 	"testing"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
@@ -13,10 +16,11 @@ func Test_parseAPIResponse(t *testing.T) {
 		path string
 	}
 	tests := []struct {
-		name    string
-		args    args
-		want    []httpGroupTarget
-		wantErr bool
+		name        string
+		args        args
+		want        []httpGroupTarget
+		wantSkipped int
+		wantErr     bool
 	}{
 
 		{
@@ -35,17 +39,118 @@ func Test_parseAPIResponse(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "unknown fields",
+			args: args{
+				path: "/unknown-fields",
+				data: []byte(`[{"targets": ["target-1"], "labels": {"a": "b"}, "foo": "bar"}]`),
+			},
+			want: []httpGroupTarget{
+				{
+					Labels:  promutils.NewLabelsFromMap(map[string]string{"a": "b"}),
+					Targets: []string{"target-1"},
+				},
+			},
+		},
+		{
+			name: "bad entries",
+			args: args{
+				path: "/bad-entries",
+				data: []byte(`[{"targets": [1]}, {"targets": ["target-1"]}, {"targets": [""]}, "foo"]`),
+			},
+			want: []httpGroupTarget{
+				{
+					Targets: []string{"target-1"},
+				},
+			},
+			wantSkipped: 3,
+		},
+		{
+			name: "invalid json",
+			args: args{
+				path: "/invalid",
+				data: []byte(`{"targets": ["target-1"]}`),
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseAPIResponse(tt.args.data, tt.args.path)
+			got, skipped, err := parseAPIResponse(tt.args.data, tt.args.path)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseAPIResponse() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if skipped != tt.wantSkipped {
+				t.Errorf("parseAPIResponse() skipped = %d, want %d", skipped, tt.wantSkipped)
+			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("parseAPIResponse() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestGetHTTPTargetsCaching(t *testing.T) {
+	var mode atomic.Value
+	mode.Store("ok")
+	var conditionalRequests uint64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddUint64(&conditionalRequests, 1)
+		}
+		switch mode.Load().(string) {
+		case "ok":
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte(`[{"targets": ["target-1"]}]`))
+		case "not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		case "error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "invalid":
+			_, _ = w.Write([]byte(`invalid`))
+		}
+	}))
+	defer s.Close()
+
+	sdc := &SDConfig{
+		URL: s.URL + "/sd",
+	}
+	cfg, err := newAPIConfig(sdc, ".")
+	if err != nil {
+		t.Fatalf("cannot create api config: %s", err)
+	}
+	defer cfg.client.Stop()
+
+	tgsExpected := []httpGroupTarget{
+		{
+			Targets: []string{"target-1"},
+		},
+	}
+	f := func(m string) {
+		t.Helper()
+		mode.Store(m)
+		tgs, err := getHTTPTargets(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error in mode %q: %s", m, err)
+		}
+		if !reflect.DeepEqual(tgs, tgsExpected) {
+			t.Fatalf("unexpected targets in mode %q; got %v; want %v", m, tgs, tgsExpected)
+		}
+	}
+
+	// The first fetch must fail if there are no previously fetched targets.
+	mode.Store("error")
+	if _, err := getHTTPTargets(cfg); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+
+	f("ok")
+	f("not-modified")
+	f("error")
+	f("invalid")
+	f("ok")
+	if n := atomic.LoadUint64(&conditionalRequests); n != 4 {
+		t.Fatalf("unexpected number of conditional requests; got %d; want 4", n)
+	}
+}
@@ -29,9 +29,19 @@ type ListMeta struct {
 }
 
 func (om *ObjectMeta) registerLabelsAndAnnotations(prefix string, m *promutils.Labels) {
+	om.registerFilteredLabelsAndAnnotations(prefix, m, nil, nil)
+}
+
+// registerFilteredLabelsAndAnnotations registers only labels with names from labelNames and annotations with names from annotationNames.
+//
+// All the labels are registered if labelNames is empty. All the annotations are registered if annotationNames is empty.
+func (om *ObjectMeta) registerFilteredLabelsAndAnnotations(prefix string, m *promutils.Labels, labelNames, annotationNames []string) {
 	bb := bbPool.Get()
 	b := bb.B
 	for _, lb := range om.Labels.GetLabels() {
+		if !isAllowedName(lb.Name, labelNames) {
+			continue
+		}
 		b = appendThreeStrings(b[:0], prefix, "_label_", lb.Name)
 		labelName := bytesutil.ToUnsafeString(b)
 		m.Add(discoveryutils.SanitizeLabelName(labelName), lb.Value)
@@ -41,6 +51,9 @@ func (om *ObjectMeta) registerLabelsAndAnnotations(prefix string, m *promutils.L
 		m.Add(discoveryutils.SanitizeLabelName(labelName), "true")
 	}
 	for _, a := range om.Annotations.GetLabels() {
+		if !isAllowedName(a.Name, annotationNames) {
+			continue
+		}
 		b = appendThreeStrings(b[:0], prefix, "_annotation_", a.Name)
 		labelName := bytesutil.ToUnsafeString(b)
 		m.Add(discoveryutils.SanitizeLabelName(labelName), a.Value)
@@ -53,6 +66,18 @@ func (om *ObjectMeta) registerLabelsAndAnnotations(prefix string, m *promutils.L
 	bbPool.Put(bb)
 }
 
+func isAllowedName(name string, allowedNames []string) bool {
+	if len(allowedNames) == 0 {
+		return true
+	}
+	for _, allowedName := range allowedNames {
+		if name == allowedName {
+			return true
+		}
+	}
+	return false
+}
+
 var bbPool bytesutil.ByteBufferPool
 
 func appendThreeStrings(dst []byte, a, b, c string) []byte {
@@ -0,0 +1,52 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func TestObjectMetaRegisterFilteredLabelsAndAnnotations(t *testing.T) {
+	f := func(labelNames, annotationNames []string, resultExpected *promutils.Labels) {
+		t.Helper()
+		om := &ObjectMeta{
+			Labels: promutils.NewLabelsFromMap(map[string]string{
+				"zone": "a",
+				"role": "worker",
+			}),
+			Annotations: promutils.NewLabelsFromMap(map[string]string{
+				"owner": "team-x",
+			}),
+		}
+		m := promutils.NewLabels(0)
+		om.registerFilteredLabelsAndAnnotations("__meta_kubernetes_node", m, labelNames, annotationNames)
+		m.Sort()
+		if !areEqualLabelss([]*promutils.Labels{m}, []*promutils.Labels{resultExpected}) {
+			t.Fatalf("unexpected labels;\ngot\n%s\nwant\n%s", m, resultExpected)
+		}
+	}
+
+	// Empty filters must result in all the labels and annotations
+	f(nil, nil, promutils.NewLabelsFromMap(map[string]string{
+		"__meta_kubernetes_node_annotation_owner":        "team-x",
+		"__meta_kubernetes_node_annotationpresent_owner": "true",
+		"__meta_kubernetes_node_label_role":              "worker",
+		"__meta_kubernetes_node_label_zone":              "a",
+		"__meta_kubernetes_node_labelpresent_role":       "true",
+		"__meta_kubernetes_node_labelpresent_zone":       "true",
+	}))
+
+	// Filter labels only
+	f([]string{"zone", "missing"}, nil, promutils.NewLabelsFromMap(map[string]string{
+		"__meta_kubernetes_node_annotation_owner":        "team-x",
+		"__meta_kubernetes_node_annotationpresent_owner": "true",
+		"__meta_kubernetes_node_label_zone":              "a",
+		"__meta_kubernetes_node_labelpresent_zone":       "true",
+	}))
+
+	// Filter both labels and annotations
+	f([]string{"role"}, []string{"missing"}, promutils.NewLabelsFromMap(map[string]string{
+		"__meta_kubernetes_node_label_role":        "worker",
+		"__meta_kubernetes_node_labelpresent_role": "true",
+	}))
+}
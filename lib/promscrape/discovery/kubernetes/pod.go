@@ -7,10 +7,20 @@ import (
 	"strings"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutils"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
 )
 
+var (
+	attachNodeLabels = flagutil.NewArrayString("promscrape.kubernetes.attachNodeLabels", "Optional list of node label names to attach to pod, endpoints and endpointslice targets "+
+		"as __meta_kubernetes_node_label_* labels when attach_metadata.node is set in kubernetes_sd_configs. All the node labels are attached if this list is empty. "+
+		"See https://docs.victoriametrics.com/sd_configs.html#kubernetes_sd_configs")
+	attachNodeAnnotations = flagutil.NewArrayString("promscrape.kubernetes.attachNodeAnnotations", "Optional list of node annotation names to attach to pod, endpoints and endpointslice targets "+
+		"as __meta_kubernetes_node_annotation_* labels when attach_metadata.node is set in kubernetes_sd_configs. All the node annotations are attached if this list is empty. "+
+		"See https://docs.victoriametrics.com/sd_configs.html#kubernetes_sd_configs")
+)
+
 func (p *Pod) key() string {
 	return p.Metadata.key()
 }
@@ -184,7 +194,7 @@ func (p *Pod) appendCommonLabels(m *promutils.Labels, gw *groupWatcher) {
 		o := gw.getObjectByRoleLocked("node", p.Metadata.Namespace, p.Spec.NodeName)
 		if o != nil {
 			n := o.(*Node)
-			n.Metadata.registerLabelsAndAnnotations("__meta_kubernetes_node", m)
+			n.Metadata.registerFilteredLabelsAndAnnotations("__meta_kubernetes_node", m, *attachNodeLabels, *attachNodeAnnotations)
 		}
 	}
 	m.Add("__meta_kubernetes_pod_name", p.Metadata.Name)
@@ -134,6 +134,17 @@ type ScrapeWork struct {
 	// See https://docs.victoriametrics.com/vmagent.html#prometheus-staleness-markers
 	NoStaleMarkers bool
 
+	// How to handle samples with duplicate labels after metric relabeling.
+	// Supported values: "" (send all the samples), "first", "last", "max" and "error".
+	DuplicateSamplePolicy string
+
+	// Whether to detect counter resets and expose them via scrape_counter_resets_total metric.
+	DetectCounterResets bool
+
+	// The maximum age of the scraped sample timestamp relative to the scrape time.
+	// Older samples are dropped if HonorTimestamps is set.
+	MaxScrapeSampleAge time.Duration
+
 	// The Tenant Info
 	AuthToken *auth.Token
 
@@ -144,7 +155,9 @@ type ScrapeWork struct {
 func (sw *ScrapeWork) canSwitchToStreamParseMode() bool {
 	// Deny switching to stream parse mode if `sample_limit` or `series_limit` options are set,
 	// since these limits cannot be applied in stream parsing mode.
-	return sw.SampleLimit <= 0 && sw.SeriesLimit <= 0
+	// Deny switching to stream parse mode for `duplicate_sample_policy: last` and `duplicate_sample_policy: max`,
+	// since samples pushed in the previous chunks cannot be updated.
+	return sw.SampleLimit <= 0 && sw.SeriesLimit <= 0 && sw.DuplicateSamplePolicy != "last" && sw.DuplicateSamplePolicy != "max"
 }
 
 // key returns unique identifier for the given sw.
@@ -158,12 +171,14 @@ func (sw *ScrapeWork) key() string {
 		"ExternalLabels=%s, "+
 		"ProxyURL=%s, ProxyAuthConfig=%s, AuthConfig=%s, MetricRelabelConfigs=%q, "+
 		"SampleLimit=%d, DisableCompression=%v, DisableKeepAlive=%v, StreamParse=%v, "+
-		"ScrapeAlignInterval=%s, ScrapeOffset=%s, SeriesLimit=%d, NoStaleMarkers=%v",
+		"ScrapeAlignInterval=%s, ScrapeOffset=%s, SeriesLimit=%d, NoStaleMarkers=%v, "+
+		"DuplicateSamplePolicy=%s, DetectCounterResets=%v, MaxScrapeSampleAge=%s",
 		sw.jobNameOriginal, sw.ScrapeURL, sw.ScrapeInterval, sw.ScrapeTimeout, sw.HonorLabels, sw.HonorTimestamps, sw.DenyRedirects, sw.Labels.String(),
 		sw.ExternalLabels.String(),
 		sw.ProxyURL.String(), sw.ProxyAuthConfig.String(), sw.AuthConfig.String(), sw.MetricRelabelConfigs.String(),
 		sw.SampleLimit, sw.DisableCompression, sw.DisableKeepAlive, sw.StreamParse,
-		sw.ScrapeAlignInterval, sw.ScrapeOffset, sw.SeriesLimit, sw.NoStaleMarkers)
+		sw.ScrapeAlignInterval, sw.ScrapeOffset, sw.SeriesLimit, sw.NoStaleMarkers,
+		sw.DuplicateSamplePolicy, sw.DetectCounterResets, sw.MaxScrapeSampleAge)
 	return key
 }
 
@@ -200,6 +215,18 @@ type scrapeWork struct {
 	// Optional limiter on the number of unique series per scrape target.
 	seriesLimiter *bloomfilter.Limiter
 
+	// The number of samples with duplicate labels handled according to Config.DuplicateSamplePolicy.
+	duplicateSamplesTotal uint64
+
+	// The number of scrapes with at least a single counter reset if Config.DetectCounterResets is set.
+	counterResetsTotal uint64
+
+	// The number of samples dropped because of too old timestamps if Config.MaxScrapeSampleAge is set.
+	tooOldSamplesTotal uint64
+
+	// counterValues holds the last values for counters if Config.DetectCounterResets is set.
+	counterValues map[uint64]*counterValue
+
 	// prevBodyLen contains the previous response body length for the given scrape work.
 	// It is used as a hint in order to reduce memory usage for body buffers.
 	prevBodyLen int
@@ -463,7 +490,11 @@ func (sw *scrapeWork) processScrapedData(scrapeTimestamp, realTimestamp int64, b
 	samplesScraped := len(srcRows)
 	scrapedSamples.Update(float64(samplesScraped))
 	for i := range srcRows {
-		sw.addRowToTimeseries(wc, &srcRows[i], scrapeTimestamp, true)
+		r := &srcRows[i]
+		if sw.dropTooOldSample(r, scrapeTimestamp) {
+			continue
+		}
+		sw.addRowToTimeseries(wc, r, scrapeTimestamp, true)
 	}
 	samplesPostRelabeling := len(wc.writeRequest.Timeseries)
 	if sw.Config.SampleLimit > 0 && samplesPostRelabeling > sw.Config.SampleLimit {
@@ -473,6 +504,19 @@ func (sw *scrapeWork) processScrapedData(scrapeTimestamp, realTimestamp int64, b
 		err = fmt.Errorf("the response from %q exceeds sample_limit=%d; "+
 			"either reduce the sample count for the target or increase sample_limit", sw.Config.ScrapeURL, sw.Config.SampleLimit)
 	}
+	if up == 1 {
+		if errLocal := sw.applyDuplicateSamplePolicy(wc, nil); errLocal != nil {
+			wc.resetNoRows()
+			up = 0
+			err = errLocal
+		}
+	}
+	if up == 1 && sw.Config.DetectCounterResets {
+		if sw.detectCounterResets(wc, scrapeTimestamp) {
+			sw.counterResetsTotal++
+		}
+		sw.purgeCounterValues(scrapeTimestamp)
+	}
 	if up == 0 {
 		bodyString = ""
 	}
@@ -567,11 +611,18 @@ func (sw *scrapeWork) scrapeStream(scrapeTimestamp, realTimestamp int64) error {
 	bodyString := ""
 	areIdenticalSeries := true
 	samplesDropped := 0
+	counterResetDetected := false
 	sr, err := sw.GetStreamReader()
 	if err != nil {
 		err = fmt.Errorf("cannot read data: %s", err)
 	} else {
 		var mu sync.Mutex
+		// seen holds hashes for series pushed in the previous chunks
+		// in order to detect duplicate samples across chunks.
+		var seen map[uint64]struct{}
+		if sw.Config.DuplicateSamplePolicy != "" {
+			seen = make(map[uint64]struct{})
+		}
 		err = sbr.Init(sr)
 		if err == nil {
 			bodyString = bytesutil.ToUnsafeString(sbr.body)
@@ -581,7 +632,11 @@ func (sw *scrapeWork) scrapeStream(scrapeTimestamp, realTimestamp int64) error {
 				defer mu.Unlock()
 				samplesScraped += len(rows)
 				for i := range rows {
-					sw.addRowToTimeseries(wc, &rows[i], scrapeTimestamp, true)
+					r := &rows[i]
+					if sw.dropTooOldSample(r, scrapeTimestamp) {
+						continue
+					}
+					sw.addRowToTimeseries(wc, r, scrapeTimestamp, true)
 				}
 				samplesPostRelabeling += len(wc.writeRequest.Timeseries)
 				if sw.Config.SampleLimit > 0 && samplesPostRelabeling > sw.Config.SampleLimit {
@@ -590,6 +645,13 @@ func (sw *scrapeWork) scrapeStream(scrapeTimestamp, realTimestamp int64) error {
 					return fmt.Errorf("the response from %q exceeds sample_limit=%d; "+
 						"either reduce the sample count for the target or increase sample_limit", sw.Config.ScrapeURL, sw.Config.SampleLimit)
 				}
+				if err := sw.applyDuplicateSamplePolicy(wc, seen); err != nil {
+					wc.resetNoRows()
+					return err
+				}
+				if sw.Config.DetectCounterResets && sw.detectCounterResets(wc, scrapeTimestamp) {
+					counterResetDetected = true
+				}
 				if sw.seriesLimitExceeded || !areIdenticalSeries {
 					samplesDropped += sw.applySeriesLimit(wc)
 				}
@@ -616,6 +678,12 @@ func (sw *scrapeWork) scrapeStream(scrapeTimestamp, realTimestamp int64) error {
 		up = 0
 		scrapesFailed.Inc()
 	}
+	if counterResetDetected {
+		sw.counterResetsTotal++
+	}
+	if up == 1 && sw.Config.DetectCounterResets {
+		sw.purgeCounterValues(scrapeTimestamp)
+	}
 	seriesAdded := 0
 	if !areIdenticalSeries {
 		// The returned value for seriesAdded may be bigger than the real number of added series
@@ -767,6 +835,127 @@ func (sw *scrapeWork) applySeriesLimit(wc *writeRequestCtx) int {
 	return samplesDropped
 }
 
+// applyDuplicateSamplePolicy handles samples with duplicate labels at wc according to sw.Config.DuplicateSamplePolicy.
+//
+// If seen isn't nil, then it must contain hashes for series already pushed during the current scrape.
+// Samples for these series cannot be updated, so they are dropped for `first` policy.
+// seen is updated with hashes for series left in wc.
+func (sw *scrapeWork) applyDuplicateSamplePolicy(wc *writeRequestCtx, seen map[uint64]struct{}) error {
+	policy := sw.Config.DuplicateSamplePolicy
+	if policy == "" {
+		return nil
+	}
+	tss := wc.writeRequest.Timeseries
+	idxs := make(map[uint64]int, len(tss))
+	dstSeries := tss[:0]
+	duplicates := 0
+	var firstDuplicate []prompbmarshal.Label
+	for _, ts := range tss {
+		h := sw.getLabelsHash(ts.Labels)
+		if _, ok := seen[h]; ok {
+			duplicates++
+			if firstDuplicate == nil {
+				firstDuplicate = ts.Labels
+			}
+			continue
+		}
+		idx, ok := idxs[h]
+		if !ok {
+			idxs[h] = len(dstSeries)
+			dstSeries = append(dstSeries, ts)
+			continue
+		}
+		duplicates++
+		if firstDuplicate == nil {
+			firstDuplicate = ts.Labels
+		}
+		switch policy {
+		case "last":
+			dstSeries[idx].Samples = ts.Samples
+		case "max":
+			v := dstSeries[idx].Samples[0].Value
+			if vNew := ts.Samples[0].Value; vNew > v || math.IsNaN(v) {
+				dstSeries[idx].Samples = ts.Samples
+			}
+		}
+	}
+	sw.duplicateSamplesTotal += uint64(duplicates)
+	if policy == "error" && duplicates > 0 {
+		return fmt.Errorf("the response from %q contains %d samples with duplicate labels after metric relabeling; the first duplicate: %s; "+
+			"either fix the target or change duplicate_sample_policy", sw.Config.ScrapeURL, duplicates, promrelabel.LabelsToString(firstDuplicate))
+	}
+	prompbmarshal.ResetTimeSeries(tss[len(dstSeries):])
+	wc.writeRequest.Timeseries = dstSeries
+	if seen != nil {
+		for h := range idxs {
+			seen[h] = struct{}{}
+		}
+	}
+	return nil
+}
+
+type counterValue struct {
+	value           float64
+	scrapeTimestamp int64
+}
+
+// detectCounterResets returns true if at least a single counter at wc has lower value than during the previous scrape.
+//
+// Series with names ending with `_total`, `_count` and `_bucket` are treated as counters.
+func (sw *scrapeWork) detectCounterResets(wc *writeRequestCtx, scrapeTimestamp int64) bool {
+	if sw.counterValues == nil {
+		sw.counterValues = make(map[uint64]*counterValue)
+	}
+	m := sw.counterValues
+	resetDetected := false
+	for _, ts := range wc.writeRequest.Timeseries {
+		if !isCounterName(getMetricName(ts.Labels)) {
+			continue
+		}
+		v := ts.Samples[0].Value
+		if math.IsNaN(v) {
+			continue
+		}
+		h := sw.getLabelsHash(ts.Labels)
+		cv := m[h]
+		if cv == nil {
+			m[h] = &counterValue{
+				value:           v,
+				scrapeTimestamp: scrapeTimestamp,
+			}
+			continue
+		}
+		if v < cv.value {
+			resetDetected = true
+		}
+		cv.value = v
+		cv.scrapeTimestamp = scrapeTimestamp
+	}
+	return resetDetected
+}
+
+// purgeCounterValues removes counters, which weren't updated at the scrape with the given scrapeTimestamp.
+func (sw *scrapeWork) purgeCounterValues(scrapeTimestamp int64) {
+	for h, cv := range sw.counterValues {
+		if cv.scrapeTimestamp != scrapeTimestamp {
+			delete(sw.counterValues, h)
+		}
+	}
+}
+
+func isCounterName(metricName string) bool {
+	return strings.HasSuffix(metricName, "_total") || strings.HasSuffix(metricName, "_count") || strings.HasSuffix(metricName, "_bucket")
+}
+
+func getMetricName(labels []prompbmarshal.Label) string {
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			return label.Value
+		}
+	}
+	return ""
+}
+
 var sendStaleSeriesConcurrencyLimitCh = make(chan struct{}, cgroup.AvailableCPUs())
 
 func (sw *scrapeWork) sendStaleSeries(lastScrape, currScrape string, timestamp int64, addAutoSeries bool) {
@@ -867,7 +1056,8 @@ func isAutoMetric(s string) bool {
 		"scrape_samples_post_metric_relabeling", "scrape_series_added",
 		"scrape_timeout_seconds", "scrape_samples_limit",
 		"scrape_series_limit_samples_dropped", "scrape_series_limit",
-		"scrape_series_current":
+		"scrape_series_current", "scrape_duplicate_samples_total",
+		"scrape_counter_resets_total", "scrape_samples_too_old_dropped_total":
 		return true
 	}
 	return false
@@ -890,6 +1080,15 @@ func (sw *scrapeWork) addAutoMetrics(am *autoMetrics, wc *writeRequestCtx, times
 		sw.addAutoTimeseries(wc, "scrape_series_limit", float64(sl.MaxItems()), timestamp)
 		sw.addAutoTimeseries(wc, "scrape_series_current", float64(sl.CurrentItems()), timestamp)
 	}
+	if sw.Config.DuplicateSamplePolicy != "" {
+		sw.addAutoTimeseries(wc, "scrape_duplicate_samples_total", float64(sw.duplicateSamplesTotal), timestamp)
+	}
+	if sw.Config.DetectCounterResets {
+		sw.addAutoTimeseries(wc, "scrape_counter_resets_total", float64(sw.counterResetsTotal), timestamp)
+	}
+	if sw.Config.MaxScrapeSampleAge > 0 {
+		sw.addAutoTimeseries(wc, "scrape_samples_too_old_dropped_total", float64(sw.tooOldSamplesTotal), timestamp)
+	}
 }
 
 // addAutoTimeseries adds automatically generated time series with the given name, value and timestamp.
@@ -954,6 +1153,25 @@ func (sw *scrapeWork) addRowToTimeseries(wc *writeRequestCtx, r *parser.Row, tim
 	})
 }
 
+// dropTooOldSample returns true if the timestamp for r exposed by the target is older than the Config.MaxScrapeSampleAge
+// relative to the scrapeTimestamp. Such samples must be dropped.
+//
+// See https://docs.victoriametrics.com/vmagent.html#sample-timestamps
+func (sw *scrapeWork) dropTooOldSample(r *parser.Row, scrapeTimestamp int64) bool {
+	maxAge := sw.Config.MaxScrapeSampleAge
+	if maxAge <= 0 || !sw.Config.HonorTimestamps || r.Timestamp == 0 {
+		return false
+	}
+	if scrapeTimestamp-r.Timestamp <= maxAge.Milliseconds() {
+		return false
+	}
+	sw.tooOldSamplesTotal++
+	scrapedSamplesTooOld.Inc()
+	return true
+}
+
+var scrapedSamplesTooOld = metrics.NewCounter(`vm_promscrape_scraped_samples_too_old_dropped_total`)
+
 var bbPool bytesutil.ByteBufferPool
 
 func appendLabels(dst []prompbmarshal.Label, metric string, src []parser.Tag, extraLabels []prompbmarshal.Label, honorLabels bool) []prompbmarshal.Label {
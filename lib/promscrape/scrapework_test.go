@@ -32,6 +32,9 @@ func TestIsAutoMetric(t *testing.T) {
 	f("scrape_series_limit_samples_dropped", true)
 	f("scrape_series_limit", true)
 	f("scrape_series_current", true)
+	f("scrape_duplicate_samples_total", true)
+	f("scrape_counter_resets_total", true)
+	f("scrape_samples_too_old_dropped_total", true)
 
 	f("foobar", false)
 	f("exported_up", false)
@@ -153,7 +156,7 @@ func TestScrapeWorkScrapeInternalSuccess(t *testing.T) {
 
 		timestamp := int64(123000)
 		if err := sw.scrapeInternal(timestamp, timestamp); err != nil {
-			if !strings.Contains(err.Error(), "sample_limit") {
+			if !strings.Contains(err.Error(), "sample_limit") && !strings.Contains(err.Error(), "duplicate_sample_policy") {
 				t.Fatalf("unexpected error: %s", err)
 			}
 		}
@@ -467,6 +470,172 @@ func TestScrapeWorkScrapeInternalSuccess(t *testing.T) {
 		scrape_series_limit_samples_dropped 1 123
 		scrape_timeout_seconds 42 123
 	`)
+	// Duplicate samples after metric relabeling with various duplicate_sample_policy values.
+	dupData := `
+		foo{bar="a"} 1
+		foo{bar="b"} 3
+		foo{bar="c"} 2
+		abc 5
+	`
+	dupRelabelConfigs := `
+- action: labeldrop
+  regex: bar
+`
+	f(dupData, &ScrapeWork{
+		ScrapeTimeout:         time.Second * 42,
+		MetricRelabelConfigs:  mustParseRelabelConfigs(dupRelabelConfigs),
+		DuplicateSamplePolicy: "first",
+	}, `
+		foo 1 123
+		abc 5 123
+		up 1 123
+		scrape_samples_scraped 4 123
+		scrape_duration_seconds 0 123
+		scrape_samples_post_metric_relabeling 4 123
+		scrape_series_added 4 123
+		scrape_timeout_seconds 42 123
+		scrape_duplicate_samples_total 2 123
+	`)
+	f(dupData, &ScrapeWork{
+		ScrapeTimeout:         time.Second * 42,
+		MetricRelabelConfigs:  mustParseRelabelConfigs(dupRelabelConfigs),
+		DuplicateSamplePolicy: "last",
+	}, `
+		foo 2 123
+		abc 5 123
+		up 1 123
+		scrape_samples_scraped 4 123
+		scrape_duration_seconds 0 123
+		scrape_samples_post_metric_relabeling 4 123
+		scrape_series_added 4 123
+		scrape_timeout_seconds 42 123
+		scrape_duplicate_samples_total 2 123
+	`)
+	f(dupData, &ScrapeWork{
+		ScrapeTimeout:         time.Second * 42,
+		MetricRelabelConfigs:  mustParseRelabelConfigs(dupRelabelConfigs),
+		DuplicateSamplePolicy: "max",
+	}, `
+		foo 3 123
+		abc 5 123
+		up 1 123
+		scrape_samples_scraped 4 123
+		scrape_duration_seconds 0 123
+		scrape_samples_post_metric_relabeling 4 123
+		scrape_series_added 4 123
+		scrape_timeout_seconds 42 123
+		scrape_duplicate_samples_total 2 123
+	`)
+	// Scrape failure because of duplicate samples.
+	f(dupData, &ScrapeWork{
+		ScrapeTimeout:         time.Second * 42,
+		MetricRelabelConfigs:  mustParseRelabelConfigs(dupRelabelConfigs),
+		DuplicateSamplePolicy: "error",
+	}, `
+		up 0 123
+		scrape_samples_scraped 4 123
+		scrape_duration_seconds 0 123
+		scrape_samples_post_metric_relabeling 4 123
+		scrape_series_added 0 123
+		scrape_timeout_seconds 42 123
+		scrape_duplicate_samples_total 2 123
+	`)
+	// No duplicates.
+	f(`
+		foo{bar="a"} 1
+		foo{bar="b"} 3
+	`, &ScrapeWork{
+		ScrapeTimeout:         time.Second * 42,
+		DuplicateSamplePolicy: "error",
+	}, `
+		foo{bar="a"} 1 123
+		foo{bar="b"} 3 123
+		up 1 123
+		scrape_samples_scraped 2 123
+		scrape_duration_seconds 0 123
+		scrape_samples_post_metric_relabeling 2 123
+		scrape_series_added 2 123
+		scrape_timeout_seconds 42 123
+		scrape_duplicate_samples_total 0 123
+	`)
+	// Drop samples with too old timestamps.
+	f(`
+		foo{bar="a"} 1 3
+		foo{bar="b"} 2 100000
+		foo{bar="c"} 3
+	`, &ScrapeWork{
+		ScrapeTimeout:      time.Second * 42,
+		HonorTimestamps:    true,
+		MaxScrapeSampleAge: time.Minute,
+	}, `
+		foo{bar="b"} 2 100000
+		foo{bar="c"} 3 123
+		up 1 123
+		scrape_samples_scraped 3 123
+		scrape_duration_seconds 0 123
+		scrape_samples_post_metric_relabeling 2 123
+		scrape_series_added 3 123
+		scrape_timeout_seconds 42 123
+		scrape_samples_too_old_dropped_total 1 123
+	`)
+	// max_scrape_sample_age has no effect if timestamps aren't honored.
+	f(`
+		foo{bar="a"} 1 3
+	`, &ScrapeWork{
+		ScrapeTimeout:      time.Second * 42,
+		MaxScrapeSampleAge: time.Minute,
+	}, `
+		foo{bar="a"} 1 123
+		up 1 123
+		scrape_samples_scraped 1 123
+		scrape_duration_seconds 0 123
+		scrape_samples_post_metric_relabeling 1 123
+		scrape_series_added 1 123
+		scrape_timeout_seconds 42 123
+		scrape_samples_too_old_dropped_total 0 123
+	`)
+}
+
+func TestScrapeWorkDetectCounterResets(t *testing.T) {
+	var sw scrapeWork
+	sw.Config = &ScrapeWork{
+		ScrapeTimeout:       time.Second * 42,
+		NoStaleMarkers:      true,
+		DetectCounterResets: true,
+	}
+	var data string
+	sw.ReadData = func(dst []byte) ([]byte, error) {
+		return append(dst, data...), nil
+	}
+	var counterResets float64
+	sw.PushData = func(at *auth.Token, wr *prompbmarshal.WriteRequest) {
+		for _, ts := range wr.Timeseries {
+			if getMetricName(ts.Labels) == "scrape_counter_resets_total" {
+				counterResets = ts.Samples[0].Value
+			}
+		}
+	}
+	timestamp := int64(123000)
+	f := func(s string, counterResetsExpected float64) {
+		t.Helper()
+		data = s
+		timestamp += 1000
+		if err := sw.scrapeInternal(timestamp, timestamp); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if counterResets != counterResetsExpected {
+			t.Fatalf("unexpected scrape_counter_resets_total; got %v; want %v", counterResets, counterResetsExpected)
+		}
+	}
+	f(`foo_total 10`+"\n"+`bar_count 5`+"\n"+`gauge 3`, 0)
+	f(`foo_total 12`+"\n"+`bar_count 6`+"\n"+`gauge 1`, 0)
+	// Both counters are reset at the same scrape.
+	f(`foo_total 1`+"\n"+`bar_count 2`+"\n"+`gauge 2`, 1)
+	f(`foo_total 2`+"\n"+`bar_count 3`, 1)
+	f(`foo_total 2`+"\n"+`bar_count 0`, 2)
+	// The disappeared counter is forgotten.
+	f(`bar_count 0`, 2)
+	f(`foo_total 1`+"\n"+`bar_count 0`, 2)
 }
 
 func TestAddRowToTimeseriesNoRelabeling(t *testing.T) {
@@ -140,6 +140,8 @@ func (tsm *targetStatusMap) Update(sw *scrapeWork, up bool, scrapeTime, scrapeDu
 		ts.scrapesFailed++
 	}
 	ts.err = err
+	// It is safe reading sw.tooOldSamplesTotal here, since Update is called from the goroutine, which scrapes sw.
+	ts.tooOldSamplesTotal = sw.tooOldSamplesTotal
 	tsm.mu.Unlock()
 }
 
@@ -241,6 +243,9 @@ type targetStatus struct {
 	scrapesTotal   int
 	scrapesFailed  int
 	err            error
+
+	// tooOldSamplesTotal is the number of samples dropped because of sw.Config.MaxScrapeSampleAge.
+	tooOldSamplesTotal uint64
 }
 
 func (ts *targetStatus) getDurationFromLastScrape() time.Duration {
@@ -147,779 +147,821 @@ func StreamTargetsResponsePlain(qw422016 *qt422016.Writer, tsr *targetsStatusRes
 			qw422016.N().S(`,`)
 //line lib/promscrape/targetstatus.qtpl:31
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:31
-			qw422016.N().S(`error=`)
 //line lib/promscrape/targetstatus.qtpl:32
-			if ts.err != nil {
+			if !ts.sw.Config.HonorTimestamps {
 //line lib/promscrape/targetstatus.qtpl:32
-				qw422016.N().S(ts.err.Error())
+				qw422016.N().S(`honor_timestamps=false,`)
+//line lib/promscrape/targetstatus.qtpl:32
+				qw422016.N().S(` `)
 //line lib/promscrape/targetstatus.qtpl:32
 			}
 //line lib/promscrape/targetstatus.qtpl:33
+			if ts.sw.Config.MaxScrapeSampleAge > 0 {
+//line lib/promscrape/targetstatus.qtpl:33
+				qw422016.N().S(`samples_too_old_dropped=`)
+//line lib/promscrape/targetstatus.qtpl:33
+				qw422016.N().DUL(ts.tooOldSamplesTotal)
+//line lib/promscrape/targetstatus.qtpl:33
+				qw422016.N().S(`,`)
+//line lib/promscrape/targetstatus.qtpl:33
+				qw422016.N().S(` `)
+//line lib/promscrape/targetstatus.qtpl:33
+			}
+//line lib/promscrape/targetstatus.qtpl:33
+			qw422016.N().S(`error=`)
+//line lib/promscrape/targetstatus.qtpl:34
+			if ts.err != nil {
+//line lib/promscrape/targetstatus.qtpl:34
+				qw422016.N().S(ts.err.Error())
+//line lib/promscrape/targetstatus.qtpl:34
+			}
+//line lib/promscrape/targetstatus.qtpl:35
 			qw422016.N().S(`
 `)
-//line lib/promscrape/targetstatus.qtpl:34
+//line lib/promscrape/targetstatus.qtpl:36
 		}
-//line lib/promscrape/targetstatus.qtpl:35
-	}
 //line lib/promscrape/targetstatus.qtpl:37
+	}
+//line lib/promscrape/targetstatus.qtpl:39
 	for _, jobName := range tsr.emptyJobs {
-//line lib/promscrape/targetstatus.qtpl:37
+//line lib/promscrape/targetstatus.qtpl:39
 		qw422016.N().S(`job=`)
-//line lib/promscrape/targetstatus.qtpl:38
+//line lib/promscrape/targetstatus.qtpl:40
 		qw422016.N().S(jobName)
-//line lib/promscrape/targetstatus.qtpl:38
+//line lib/promscrape/targetstatus.qtpl:40
 		qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:38
+//line lib/promscrape/targetstatus.qtpl:40
 		qw422016.N().S(`(0/0 up)`)
-//line lib/promscrape/targetstatus.qtpl:39
+//line lib/promscrape/targetstatus.qtpl:41
 		qw422016.N().S(`
 `)
-//line lib/promscrape/targetstatus.qtpl:40
-	}
 //line lib/promscrape/targetstatus.qtpl:42
+	}
+//line lib/promscrape/targetstatus.qtpl:44
 }
 
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 func WriteTargetsResponsePlain(qq422016 qtio422016.Writer, tsr *targetsStatusResult, filter *requestFilter) {
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 	StreamTargetsResponsePlain(qw422016, tsr, filter)
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 }
 
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 func TargetsResponsePlain(tsr *targetsStatusResult, filter *requestFilter) string {
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 	WriteTargetsResponsePlain(qb422016, tsr, filter)
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:42
+//line lib/promscrape/targetstatus.qtpl:44
 }
 
-//line lib/promscrape/targetstatus.qtpl:44
+//line lib/promscrape/targetstatus.qtpl:46
 func StreamTargetsResponseHTML(qw422016 *qt422016.Writer, tsr *targetsStatusResult, filter *requestFilter) {
-//line lib/promscrape/targetstatus.qtpl:44
+//line lib/promscrape/targetstatus.qtpl:46
 	qw422016.N().S(`<!DOCTYPE html><html lang="en"><head>`)
-//line lib/promscrape/targetstatus.qtpl:48
+//line lib/promscrape/targetstatus.qtpl:50
 	htmlcomponents.StreamCommonHeader(qw422016)
-//line lib/promscrape/targetstatus.qtpl:48
+//line lib/promscrape/targetstatus.qtpl:50
 	qw422016.N().S(`<title>Active Targets</title></head><body>`)
-//line lib/promscrape/targetstatus.qtpl:52
+//line lib/promscrape/targetstatus.qtpl:54
 	htmlcomponents.StreamNavbar(qw422016)
-//line lib/promscrape/targetstatus.qtpl:52
-	qw422016.N().S(`<div class="container-fluid">`)
 //line lib/promscrape/targetstatus.qtpl:54
+	qw422016.N().S(`<div class="container-fluid">`)
+//line lib/promscrape/targetstatus.qtpl:56
 	if tsr.err != nil {
-//line lib/promscrape/targetstatus.qtpl:55
+//line lib/promscrape/targetstatus.qtpl:57
 		htmlcomponents.StreamErrorNotification(qw422016, tsr.err)
-//line lib/promscrape/targetstatus.qtpl:56
+//line lib/promscrape/targetstatus.qtpl:58
 	}
-//line lib/promscrape/targetstatus.qtpl:56
+//line lib/promscrape/targetstatus.qtpl:58
 	qw422016.N().S(`<div class="row"><main class="col-12"><h1>Active Targets</h1><hr />`)
-//line lib/promscrape/targetstatus.qtpl:61
+//line lib/promscrape/targetstatus.qtpl:63
 	streamfiltersForm(qw422016, filter)
-//line lib/promscrape/targetstatus.qtpl:61
-	qw422016.N().S(`<hr />`)
 //line lib/promscrape/targetstatus.qtpl:63
+	qw422016.N().S(`<hr />`)
+//line lib/promscrape/targetstatus.qtpl:65
 	streamtargetsTabs(qw422016, tsr, filter, "scrapeTargets")
-//line lib/promscrape/targetstatus.qtpl:63
+//line lib/promscrape/targetstatus.qtpl:65
 	qw422016.N().S(`</main></div></div></body></html>`)
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 }
 
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 func WriteTargetsResponseHTML(qq422016 qtio422016.Writer, tsr *targetsStatusResult, filter *requestFilter) {
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 	StreamTargetsResponseHTML(qw422016, tsr, filter)
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 }
 
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 func TargetsResponseHTML(tsr *targetsStatusResult, filter *requestFilter) string {
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 	WriteTargetsResponseHTML(qb422016, tsr, filter)
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:69
+//line lib/promscrape/targetstatus.qtpl:71
 }
 
-//line lib/promscrape/targetstatus.qtpl:71
+//line lib/promscrape/targetstatus.qtpl:73
 func StreamServiceDiscoveryResponse(qw422016 *qt422016.Writer, tsr *targetsStatusResult, filter *requestFilter) {
-//line lib/promscrape/targetstatus.qtpl:71
+//line lib/promscrape/targetstatus.qtpl:73
 	qw422016.N().S(`<!DOCTYPE html><html lang="en"><head>`)
-//line lib/promscrape/targetstatus.qtpl:75
+//line lib/promscrape/targetstatus.qtpl:77
 	htmlcomponents.StreamCommonHeader(qw422016)
-//line lib/promscrape/targetstatus.qtpl:75
+//line lib/promscrape/targetstatus.qtpl:77
 	qw422016.N().S(`<title>Discovered Targets</title></head><body>`)
-//line lib/promscrape/targetstatus.qtpl:79
+//line lib/promscrape/targetstatus.qtpl:81
 	htmlcomponents.StreamNavbar(qw422016)
-//line lib/promscrape/targetstatus.qtpl:79
-	qw422016.N().S(`<div class="container-fluid">`)
 //line lib/promscrape/targetstatus.qtpl:81
+	qw422016.N().S(`<div class="container-fluid">`)
+//line lib/promscrape/targetstatus.qtpl:83
 	if tsr.err != nil {
-//line lib/promscrape/targetstatus.qtpl:82
+//line lib/promscrape/targetstatus.qtpl:84
 		htmlcomponents.StreamErrorNotification(qw422016, tsr.err)
-//line lib/promscrape/targetstatus.qtpl:83
+//line lib/promscrape/targetstatus.qtpl:85
 	}
-//line lib/promscrape/targetstatus.qtpl:83
+//line lib/promscrape/targetstatus.qtpl:85
 	qw422016.N().S(`<div class="row"><main class="col-12"><h1>Discovered Targets</h1><hr />`)
-//line lib/promscrape/targetstatus.qtpl:88
+//line lib/promscrape/targetstatus.qtpl:90
 	streamfiltersForm(qw422016, filter)
-//line lib/promscrape/targetstatus.qtpl:88
-	qw422016.N().S(`<hr />`)
 //line lib/promscrape/targetstatus.qtpl:90
+	qw422016.N().S(`<hr />`)
+//line lib/promscrape/targetstatus.qtpl:92
 	streamtargetsTabs(qw422016, tsr, filter, "discoveredTargets")
-//line lib/promscrape/targetstatus.qtpl:90
+//line lib/promscrape/targetstatus.qtpl:92
 	qw422016.N().S(`</main></div></div></body></html>`)
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 }
 
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 func WriteServiceDiscoveryResponse(qq422016 qtio422016.Writer, tsr *targetsStatusResult, filter *requestFilter) {
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 	StreamServiceDiscoveryResponse(qw422016, tsr, filter)
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 }
 
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 func ServiceDiscoveryResponse(tsr *targetsStatusResult, filter *requestFilter) string {
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 	WriteServiceDiscoveryResponse(qb422016, tsr, filter)
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:96
+//line lib/promscrape/targetstatus.qtpl:98
 }
 
-//line lib/promscrape/targetstatus.qtpl:98
+//line lib/promscrape/targetstatus.qtpl:100
 func streamfiltersForm(qw422016 *qt422016.Writer, filter *requestFilter) {
-//line lib/promscrape/targetstatus.qtpl:98
+//line lib/promscrape/targetstatus.qtpl:100
 	qw422016.N().S(`<div class="row g-3 align-items-center mb-3"><div class="col-auto"><button id="all-btn" type="button" class="btn`)
-//line lib/promscrape/targetstatus.qtpl:101
+//line lib/promscrape/targetstatus.qtpl:103
 	qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:101
+//line lib/promscrape/targetstatus.qtpl:103
 	if !filter.showOnlyUnhealthy {
-//line lib/promscrape/targetstatus.qtpl:101
+//line lib/promscrape/targetstatus.qtpl:103
 		qw422016.N().S(`btn-secondary`)
-//line lib/promscrape/targetstatus.qtpl:101
+//line lib/promscrape/targetstatus.qtpl:103
 	} else {
-//line lib/promscrape/targetstatus.qtpl:101
+//line lib/promscrape/targetstatus.qtpl:103
 		qw422016.N().S(`btn-success`)
-//line lib/promscrape/targetstatus.qtpl:101
+//line lib/promscrape/targetstatus.qtpl:103
 	}
-//line lib/promscrape/targetstatus.qtpl:101
+//line lib/promscrape/targetstatus.qtpl:103
 	qw422016.N().S(`"onclick="location.href='?`)
-//line lib/promscrape/targetstatus.qtpl:102
+//line lib/promscrape/targetstatus.qtpl:104
 	streamqueryArgs(qw422016, filter, map[string]string{"show_only_unhealthy": "false"})
-//line lib/promscrape/targetstatus.qtpl:102
+//line lib/promscrape/targetstatus.qtpl:104
 	qw422016.N().S(`'">All</button></div><div class="col-auto"><button id="unhealthy-btn" type="button" class="btn`)
-//line lib/promscrape/targetstatus.qtpl:107
+//line lib/promscrape/targetstatus.qtpl:109
 	qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:107
+//line lib/promscrape/targetstatus.qtpl:109
 	if filter.showOnlyUnhealthy {
-//line lib/promscrape/targetstatus.qtpl:107
+//line lib/promscrape/targetstatus.qtpl:109
 		qw422016.N().S(`btn-secondary`)
-//line lib/promscrape/targetstatus.qtpl:107
+//line lib/promscrape/targetstatus.qtpl:109
 	} else {
-//line lib/promscrape/targetstatus.qtpl:107
+//line lib/promscrape/targetstatus.qtpl:109
 		qw422016.N().S(`btn-danger`)
-//line lib/promscrape/targetstatus.qtpl:107
+//line lib/promscrape/targetstatus.qtpl:109
 	}
-//line lib/promscrape/targetstatus.qtpl:107
+//line lib/promscrape/targetstatus.qtpl:109
 	qw422016.N().S(`"onclick="location.href='?`)
-//line lib/promscrape/targetstatus.qtpl:108
+//line lib/promscrape/targetstatus.qtpl:110
 	streamqueryArgs(qw422016, filter, map[string]string{"show_only_unhealthy": "true"})
-//line lib/promscrape/targetstatus.qtpl:108
+//line lib/promscrape/targetstatus.qtpl:110
 	qw422016.N().S(`'">Unhealthy</button></div><div class="col-auto"><button type="button" class="btn btn-primary" onclick="document.querySelectorAll('.scrape-job').forEach((el) => { el.style.display = 'none'; })">Collapse all</button></div><div class="col-auto"><button type="button" class="btn btn-secondary" onclick="document.querySelectorAll('.scrape-job').forEach((el) => { el.style.display = 'block'; })">Expand all</button></div><div class="col-auto"><button type="button" class="btn btn-success" onclick="document.getElementById('filters').style.display='block'">Filter targets</button></div></div><div id="filters"`)
-//line lib/promscrape/targetstatus.qtpl:128
+//line lib/promscrape/targetstatus.qtpl:130
 	if filter.endpointSearch == "" && filter.labelSearch == "" {
-//line lib/promscrape/targetstatus.qtpl:128
+//line lib/promscrape/targetstatus.qtpl:130
 		qw422016.N().S(`style="display:none"`)
-//line lib/promscrape/targetstatus.qtpl:128
+//line lib/promscrape/targetstatus.qtpl:130
 	}
-//line lib/promscrape/targetstatus.qtpl:128
+//line lib/promscrape/targetstatus.qtpl:130
 	qw422016.N().S(`><form class="form-horizontal"><div class="form-group mb-3"><label for="endpoint_search" class="col-sm-10 control-label">Endpoint filter (<a target="_blank" href="https://github.com/google/re2/wiki/Syntax">Regexp</a> is accepted)</label><div class="col-sm-10"><input type="text" id="endpoint_search" name="endpoint_search"placeholder="For example, 127.0.0.1" class="form-control" value="`)
-//line lib/promscrape/targetstatus.qtpl:134
+//line lib/promscrape/targetstatus.qtpl:136
 	qw422016.E().S(filter.endpointSearch)
-//line lib/promscrape/targetstatus.qtpl:134
+//line lib/promscrape/targetstatus.qtpl:136
 	qw422016.N().S(`"/></div></div><div class="form-group mb-3"><label for="label_search" class="col-sm-10 control-label">Labels filter (<a target="_blank" href="https://prometheus.io/docs/prometheus/latest/querying/basics/#time-series-selectors">Arbitrary time series selectors</a> are accepted)</label><div class="col-sm-10"><input type="text" id="label_search" name="label_search"placeholder="For example, {instance=~'.+:9100'}" class="form-control" value="`)
-//line lib/promscrape/targetstatus.qtpl:141
+//line lib/promscrape/targetstatus.qtpl:143
 	qw422016.E().S(filter.labelSearch)
-//line lib/promscrape/targetstatus.qtpl:141
+//line lib/promscrape/targetstatus.qtpl:143
 	qw422016.N().S(`"/></div></div><input type="hidden" name="show_only_unhealthy" value="`)
-//line lib/promscrape/targetstatus.qtpl:144
+//line lib/promscrape/targetstatus.qtpl:146
 	qw422016.E().V(filter.showOnlyUnhealthy)
-//line lib/promscrape/targetstatus.qtpl:144
+//line lib/promscrape/targetstatus.qtpl:146
 	qw422016.N().S(`"/><input type="hidden" name="show_original_labels" value="`)
-//line lib/promscrape/targetstatus.qtpl:145
+//line lib/promscrape/targetstatus.qtpl:147
 	qw422016.E().V(filter.showOriginalLabels)
-//line lib/promscrape/targetstatus.qtpl:145
+//line lib/promscrape/targetstatus.qtpl:147
 	qw422016.N().S(`"/><button type="submit" class="btn btn-success mb-3">Submit</button><button type="button" class="btn btn-danger mb-3" onclick="location.href='?'">Clear target filters</button></form></div>`)
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 }
 
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 func writefiltersForm(qq422016 qtio422016.Writer, filter *requestFilter) {
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 	streamfiltersForm(qw422016, filter)
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 }
 
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 func filtersForm(filter *requestFilter) string {
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 	writefiltersForm(qb422016, filter)
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:150
+//line lib/promscrape/targetstatus.qtpl:152
 }
 
-//line lib/promscrape/targetstatus.qtpl:152
+//line lib/promscrape/targetstatus.qtpl:154
 func streamtargetsTabs(qw422016 *qt422016.Writer, tsr *targetsStatusResult, filter *requestFilter, activeTab string) {
-//line lib/promscrape/targetstatus.qtpl:152
+//line lib/promscrape/targetstatus.qtpl:154
 	qw422016.N().S(`<ul class="nav nav-tabs" id="myTab" role="tablist"><li class="nav-item" role="presentation"><button class="nav-link`)
-//line lib/promscrape/targetstatus.qtpl:155
+//line lib/promscrape/targetstatus.qtpl:157
 	if activeTab == "scrapeTargets" {
-//line lib/promscrape/targetstatus.qtpl:155
+//line lib/promscrape/targetstatus.qtpl:157
 		qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:155
+//line lib/promscrape/targetstatus.qtpl:157
 		qw422016.N().S(`active`)
-//line lib/promscrape/targetstatus.qtpl:155
+//line lib/promscrape/targetstatus.qtpl:157
 	}
-//line lib/promscrape/targetstatus.qtpl:155
+//line lib/promscrape/targetstatus.qtpl:157
 	qw422016.N().S(`" type="button" role="tab"onclick="location.href='targets?`)
-//line lib/promscrape/targetstatus.qtpl:156
+//line lib/promscrape/targetstatus.qtpl:158
 	streamqueryArgs(qw422016, filter, nil)
-//line lib/promscrape/targetstatus.qtpl:156
+//line lib/promscrape/targetstatus.qtpl:158
 	qw422016.N().S(`'">Active targets</button></li><li class="nav-item" role="presentation"><button class="nav-link`)
-//line lib/promscrape/targetstatus.qtpl:161
+//line lib/promscrape/targetstatus.qtpl:163
 	if activeTab == "discoveredTargets" {
-//line lib/promscrape/targetstatus.qtpl:161
+//line lib/promscrape/targetstatus.qtpl:163
 		qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:161
+//line lib/promscrape/targetstatus.qtpl:163
 		qw422016.N().S(`active`)
-//line lib/promscrape/targetstatus.qtpl:161
+//line lib/promscrape/targetstatus.qtpl:163
 	}
-//line lib/promscrape/targetstatus.qtpl:161
+//line lib/promscrape/targetstatus.qtpl:163
 	qw422016.N().S(`" type="button" role="tab"onclick="location.href='service-discovery?`)
-//line lib/promscrape/targetstatus.qtpl:162
+//line lib/promscrape/targetstatus.qtpl:164
 	streamqueryArgs(qw422016, filter, nil)
-//line lib/promscrape/targetstatus.qtpl:162
+//line lib/promscrape/targetstatus.qtpl:164
 	qw422016.N().S(`'">Discovered targets</button></li></ul><div class="tab-content"><div class="tab-pane active" role="tabpanel">`)
-//line lib/promscrape/targetstatus.qtpl:169
+//line lib/promscrape/targetstatus.qtpl:171
 	switch activeTab {
-//line lib/promscrape/targetstatus.qtpl:170
+//line lib/promscrape/targetstatus.qtpl:172
 	case "scrapeTargets":
-//line lib/promscrape/targetstatus.qtpl:171
+//line lib/promscrape/targetstatus.qtpl:173
 		streamscrapeTargets(qw422016, tsr)
-//line lib/promscrape/targetstatus.qtpl:172
+//line lib/promscrape/targetstatus.qtpl:174
 	case "discoveredTargets":
-//line lib/promscrape/targetstatus.qtpl:173
+//line lib/promscrape/targetstatus.qtpl:175
 		streamdiscoveredTargets(qw422016, tsr)
-//line lib/promscrape/targetstatus.qtpl:174
+//line lib/promscrape/targetstatus.qtpl:176
 	}
-//line lib/promscrape/targetstatus.qtpl:174
+//line lib/promscrape/targetstatus.qtpl:176
 	qw422016.N().S(`</div></div>`)
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 }
 
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 func writetargetsTabs(qq422016 qtio422016.Writer, tsr *targetsStatusResult, filter *requestFilter, activeTab string) {
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 	streamtargetsTabs(qw422016, tsr, filter, activeTab)
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 }
 
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 func targetsTabs(tsr *targetsStatusResult, filter *requestFilter, activeTab string) string {
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 	writetargetsTabs(qb422016, tsr, filter, activeTab)
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:177
+//line lib/promscrape/targetstatus.qtpl:179
 }
 
-//line lib/promscrape/targetstatus.qtpl:179
+//line lib/promscrape/targetstatus.qtpl:181
 func streamscrapeTargets(qw422016 *qt422016.Writer, tsr *targetsStatusResult) {
-//line lib/promscrape/targetstatus.qtpl:179
+//line lib/promscrape/targetstatus.qtpl:181
 	qw422016.N().S(`<div class="row mt-4"><div class="col-12">`)
-//line lib/promscrape/targetstatus.qtpl:182
+//line lib/promscrape/targetstatus.qtpl:184
 	for i, jts := range tsr.jobTargetsStatuses {
-//line lib/promscrape/targetstatus.qtpl:183
+//line lib/promscrape/targetstatus.qtpl:185
 		streamscrapeJobTargets(qw422016, i, jts)
-//line lib/promscrape/targetstatus.qtpl:184
+//line lib/promscrape/targetstatus.qtpl:186
 	}
-//line lib/promscrape/targetstatus.qtpl:185
-	for i, jobName := range tsr.emptyJobs {
 //line lib/promscrape/targetstatus.qtpl:187
+	for i, jobName := range tsr.emptyJobs {
+//line lib/promscrape/targetstatus.qtpl:189
 		num := i + len(tsr.jobTargetsStatuses)
 		jts := &jobTargetsStatuses{
 			jobName: jobName,
 		}
 
-//line lib/promscrape/targetstatus.qtpl:192
+//line lib/promscrape/targetstatus.qtpl:194
 		streamscrapeJobTargets(qw422016, num, jts)
-//line lib/promscrape/targetstatus.qtpl:193
+//line lib/promscrape/targetstatus.qtpl:195
 	}
-//line lib/promscrape/targetstatus.qtpl:193
+//line lib/promscrape/targetstatus.qtpl:195
 	qw422016.N().S(`</div></div>`)
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 }
 
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 func writescrapeTargets(qq422016 qtio422016.Writer, tsr *targetsStatusResult) {
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 	streamscrapeTargets(qw422016, tsr)
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 }
 
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 func scrapeTargets(tsr *targetsStatusResult) string {
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 	writescrapeTargets(qb422016, tsr)
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:196
+//line lib/promscrape/targetstatus.qtpl:198
 }
 
-//line lib/promscrape/targetstatus.qtpl:198
+//line lib/promscrape/targetstatus.qtpl:200
 func streamscrapeJobTargets(qw422016 *qt422016.Writer, num int, jts *jobTargetsStatuses) {
-//line lib/promscrape/targetstatus.qtpl:198
+//line lib/promscrape/targetstatus.qtpl:200
 	qw422016.N().S(`<div class="row mb-4"><div class="col-12"><h4><span class="me-2">`)
-//line lib/promscrape/targetstatus.qtpl:202
+//line lib/promscrape/targetstatus.qtpl:204
 	qw422016.E().S(jts.jobName)
-//line lib/promscrape/targetstatus.qtpl:202
+//line lib/promscrape/targetstatus.qtpl:204
 	qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:202
+//line lib/promscrape/targetstatus.qtpl:204
 	qw422016.N().S(`(`)
-//line lib/promscrape/targetstatus.qtpl:202
+//line lib/promscrape/targetstatus.qtpl:204
 	qw422016.N().D(jts.upCount)
-//line lib/promscrape/targetstatus.qtpl:202
+//line lib/promscrape/targetstatus.qtpl:204
 	qw422016.N().S(`/`)
-//line lib/promscrape/targetstatus.qtpl:202
+//line lib/promscrape/targetstatus.qtpl:204
 	qw422016.N().D(jts.targetsTotal)
-//line lib/promscrape/targetstatus.qtpl:202
+//line lib/promscrape/targetstatus.qtpl:204
 	qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:202
+//line lib/promscrape/targetstatus.qtpl:204
 	qw422016.N().S(`up)</span>`)
-//line lib/promscrape/targetstatus.qtpl:203
+//line lib/promscrape/targetstatus.qtpl:205
 	streamshowHideScrapeJobButtons(qw422016, num)
-//line lib/promscrape/targetstatus.qtpl:203
-	qw422016.N().S(`</h4><div id="scrape-job-`)
 //line lib/promscrape/targetstatus.qtpl:205
+	qw422016.N().S(`</h4><div id="scrape-job-`)
+//line lib/promscrape/targetstatus.qtpl:207
 	qw422016.N().D(num)
-//line lib/promscrape/targetstatus.qtpl:205
+//line lib/promscrape/targetstatus.qtpl:207
 	qw422016.N().S(`" class="scrape-job table-responsive"><table class="table table-striped table-hover table-bordered table-sm"><thead><tr><th scope="col">Endpoint</th><th scope="col">State</th><th scope="col" title="target labels">Labels</th><th scope="col" title="debug relabeling">Debug relabeling</th><th scope="col" title="total scrapes">Scrapes</th><th scope="col" title="total scrape errors">Errors</th><th scope="col" title="the time of the last scrape">Last Scrape</th><th scope="col" title="the duration of the last scrape">Duration</th><th scope="col" title="the number of metrics scraped during the last scrape">Samples</th><th scope="col" title="error from the last scrape (if any)">Last error</th></tr></thead><tbody>`)
-//line lib/promscrape/targetstatus.qtpl:222
-	for _, ts := range jts.targetsStatus {
 //line lib/promscrape/targetstatus.qtpl:224
+	for _, ts := range jts.targetsStatus {
+//line lib/promscrape/targetstatus.qtpl:226
 		endpoint := ts.sw.Config.ScrapeURL
 		// The target is uniquely identified by a pointer to its original labels.
 		targetID := getLabelsID(ts.sw.Config.OriginalLabels)
 		lastScrapeDuration := ts.getDurationFromLastScrape()
 
-//line lib/promscrape/targetstatus.qtpl:228
+//line lib/promscrape/targetstatus.qtpl:230
 		qw422016.N().S(`<tr`)
-//line lib/promscrape/targetstatus.qtpl:229
+//line lib/promscrape/targetstatus.qtpl:231
 		if !ts.up {
-//line lib/promscrape/targetstatus.qtpl:229
+//line lib/promscrape/targetstatus.qtpl:231
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:229
+//line lib/promscrape/targetstatus.qtpl:231
 			qw422016.N().S(`class="alert alert-danger" role="alert"`)
-//line lib/promscrape/targetstatus.qtpl:229
+//line lib/promscrape/targetstatus.qtpl:231
 		}
-//line lib/promscrape/targetstatus.qtpl:229
-		qw422016.N().S(`><td class="endpoint"><a href="`)
 //line lib/promscrape/targetstatus.qtpl:231
+		qw422016.N().S(`><td class="endpoint"><a href="`)
+//line lib/promscrape/targetstatus.qtpl:233
 		qw422016.E().S(endpoint)
-//line lib/promscrape/targetstatus.qtpl:231
+//line lib/promscrape/targetstatus.qtpl:233
 		qw422016.N().S(`" target="_blank">`)
-//line lib/promscrape/targetstatus.qtpl:231
+//line lib/promscrape/targetstatus.qtpl:233
 		qw422016.E().S(endpoint)
-//line lib/promscrape/targetstatus.qtpl:231
+//line lib/promscrape/targetstatus.qtpl:233
 		qw422016.N().S(`</a> (<a href="target_response?id=`)
-//line lib/promscrape/targetstatus.qtpl:232
+//line lib/promscrape/targetstatus.qtpl:234
 		qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:232
+//line lib/promscrape/targetstatus.qtpl:234
 		qw422016.N().S(`" target="_blank"title="click to fetch target response on behalf of the scraper">response</a>)</td><td>`)
-//line lib/promscrape/targetstatus.qtpl:237
+//line lib/promscrape/targetstatus.qtpl:239
 		if ts.up {
-//line lib/promscrape/targetstatus.qtpl:237
-			qw422016.N().S(`<span class="badge bg-success">UP</span>`)
 //line lib/promscrape/targetstatus.qtpl:239
+			qw422016.N().S(`<span class="badge bg-success">UP</span>`)
+//line lib/promscrape/targetstatus.qtpl:241
 		} else {
-//line lib/promscrape/targetstatus.qtpl:239
-			qw422016.N().S(`<span class="badge bg-danger">DOWN</span>`)
 //line lib/promscrape/targetstatus.qtpl:241
+			qw422016.N().S(`<span class="badge bg-danger">DOWN</span>`)
+//line lib/promscrape/targetstatus.qtpl:243
 		}
-//line lib/promscrape/targetstatus.qtpl:241
+//line lib/promscrape/targetstatus.qtpl:243
 		qw422016.N().S(`</td><td class="labels"><div title="click to show original labels"onclick="document.getElementById('original-labels-`)
-//line lib/promscrape/targetstatus.qtpl:245
+//line lib/promscrape/targetstatus.qtpl:247
 		qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:245
+//line lib/promscrape/targetstatus.qtpl:247
 		qw422016.N().S(`').style.display='block'">`)
-//line lib/promscrape/targetstatus.qtpl:246
+//line lib/promscrape/targetstatus.qtpl:248
 		streamformatLabels(qw422016, ts.sw.Config.Labels)
-//line lib/promscrape/targetstatus.qtpl:246
-		qw422016.N().S(`</div><div style="display:none" id="original-labels-`)
 //line lib/promscrape/targetstatus.qtpl:248
+		qw422016.N().S(`</div><div style="display:none" id="original-labels-`)
+//line lib/promscrape/targetstatus.qtpl:250
 		qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:248
+//line lib/promscrape/targetstatus.qtpl:250
 		qw422016.N().S(`">`)
-//line lib/promscrape/targetstatus.qtpl:249
+//line lib/promscrape/targetstatus.qtpl:251
 		streamformatLabels(qw422016, ts.sw.Config.OriginalLabels)
-//line lib/promscrape/targetstatus.qtpl:249
+//line lib/promscrape/targetstatus.qtpl:251
 		qw422016.N().S(`</div></td><td><a href="target-relabel-debug?id=`)
-//line lib/promscrape/targetstatus.qtpl:253
+//line lib/promscrape/targetstatus.qtpl:255
 		qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:253
+//line lib/promscrape/targetstatus.qtpl:255
 		qw422016.N().S(`" target="_blank">target</a>`)
-//line lib/promscrape/targetstatus.qtpl:253
+//line lib/promscrape/targetstatus.qtpl:255
 		qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:253
+//line lib/promscrape/targetstatus.qtpl:255
 		qw422016.N().S(`<a href="metric-relabel-debug?id=`)
-//line lib/promscrape/targetstatus.qtpl:254
+//line lib/promscrape/targetstatus.qtpl:256
 		qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:254
-		qw422016.N().S(`" target="_blank">metrics</a></td><td>`)
 //line lib/promscrape/targetstatus.qtpl:256
+		qw422016.N().S(`" target="_blank">metrics</a></td><td>`)
+//line lib/promscrape/targetstatus.qtpl:258
 		qw422016.N().D(ts.scrapesTotal)
-//line lib/promscrape/targetstatus.qtpl:256
+//line lib/promscrape/targetstatus.qtpl:258
 		qw422016.N().S(`</td><td>`)
-//line lib/promscrape/targetstatus.qtpl:257
+//line lib/promscrape/targetstatus.qtpl:259
 		qw422016.N().D(ts.scrapesFailed)
-//line lib/promscrape/targetstatus.qtpl:257
-		qw422016.N().S(`</td><td>`)
 //line lib/promscrape/targetstatus.qtpl:259
+		qw422016.N().S(`</td><td>`)
+//line lib/promscrape/targetstatus.qtpl:261
 		if lastScrapeDuration < 365*24*time.Hour {
-//line lib/promscrape/targetstatus.qtpl:260
+//line lib/promscrape/targetstatus.qtpl:262
 			qw422016.N().D(int(lastScrapeDuration.Milliseconds()))
-//line lib/promscrape/targetstatus.qtpl:260
+//line lib/promscrape/targetstatus.qtpl:262
 			qw422016.N().S(`ms ago`)
-//line lib/promscrape/targetstatus.qtpl:261
+//line lib/promscrape/targetstatus.qtpl:263
 		} else {
-//line lib/promscrape/targetstatus.qtpl:261
-			qw422016.N().S(`none`)
 //line lib/promscrape/targetstatus.qtpl:263
+			qw422016.N().S(`none`)
+//line lib/promscrape/targetstatus.qtpl:265
 		}
-//line lib/promscrape/targetstatus.qtpl:263
+//line lib/promscrape/targetstatus.qtpl:265
 		qw422016.N().S(`<td>`)
-//line lib/promscrape/targetstatus.qtpl:264
+//line lib/promscrape/targetstatus.qtpl:266
 		qw422016.N().D(int(ts.scrapeDuration))
-//line lib/promscrape/targetstatus.qtpl:264
+//line lib/promscrape/targetstatus.qtpl:266
 		qw422016.N().S(`ms</td><td>`)
-//line lib/promscrape/targetstatus.qtpl:265
+//line lib/promscrape/targetstatus.qtpl:268
 		qw422016.N().D(ts.samplesScraped)
-//line lib/promscrape/targetstatus.qtpl:265
+//line lib/promscrape/targetstatus.qtpl:269
+		if !ts.sw.Config.HonorTimestamps {
+//line lib/promscrape/targetstatus.qtpl:269
+			qw422016.N().S(`<br/><span class="badge bg-secondary" title="timestamps exposed by the target are ignored">honor_timestamps: false</span>`)
+//line lib/promscrape/targetstatus.qtpl:271
+		}
+//line lib/promscrape/targetstatus.qtpl:272
+		if ts.sw.Config.MaxScrapeSampleAge > 0 {
+//line lib/promscrape/targetstatus.qtpl:272
+			qw422016.N().S(`<br/><span title="the number of samples dropped because their timestamps are older than max_scrape_sample_age=`)
+//line lib/promscrape/targetstatus.qtpl:273
+			qw422016.E().S(ts.sw.Config.MaxScrapeSampleAge.String())
+//line lib/promscrape/targetstatus.qtpl:273
+			qw422016.N().S(`">too old:`)
+//line lib/promscrape/targetstatus.qtpl:274
+			qw422016.N().S(` `)
+//line lib/promscrape/targetstatus.qtpl:274
+			qw422016.N().DUL(ts.tooOldSamplesTotal)
+//line lib/promscrape/targetstatus.qtpl:274
+			qw422016.N().S(`</span>`)
+//line lib/promscrape/targetstatus.qtpl:276
+		}
+//line lib/promscrape/targetstatus.qtpl:276
 		qw422016.N().S(`</td><td>`)
-//line lib/promscrape/targetstatus.qtpl:266
+//line lib/promscrape/targetstatus.qtpl:278
 		if ts.err != nil {
-//line lib/promscrape/targetstatus.qtpl:266
+//line lib/promscrape/targetstatus.qtpl:278
 			qw422016.E().S(ts.err.Error())
-//line lib/promscrape/targetstatus.qtpl:266
+//line lib/promscrape/targetstatus.qtpl:278
 		}
-//line lib/promscrape/targetstatus.qtpl:266
+//line lib/promscrape/targetstatus.qtpl:278
 		qw422016.N().S(`</td></tr>`)
-//line lib/promscrape/targetstatus.qtpl:268
+//line lib/promscrape/targetstatus.qtpl:280
 	}
-//line lib/promscrape/targetstatus.qtpl:268
+//line lib/promscrape/targetstatus.qtpl:280
 	qw422016.N().S(`</tbody></table></div></div></div>`)
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 }
 
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 func writescrapeJobTargets(qq422016 qtio422016.Writer, num int, jts *jobTargetsStatuses) {
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 	streamscrapeJobTargets(qw422016, num, jts)
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 }
 
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 func scrapeJobTargets(num int, jts *jobTargetsStatuses) string {
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 	writescrapeJobTargets(qb422016, num, jts)
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:274
+//line lib/promscrape/targetstatus.qtpl:286
 }
 
-//line lib/promscrape/targetstatus.qtpl:276
+//line lib/promscrape/targetstatus.qtpl:288
 func streamdiscoveredTargets(qw422016 *qt422016.Writer, tsr *targetsStatusResult) {
-//line lib/promscrape/targetstatus.qtpl:277
+//line lib/promscrape/targetstatus.qtpl:289
 	tljs := tsr.getTargetLabelsByJob()
 
-//line lib/promscrape/targetstatus.qtpl:277
+//line lib/promscrape/targetstatus.qtpl:289
 	qw422016.N().S(`<div class="row mt-4"><div class="col-12">`)
-//line lib/promscrape/targetstatus.qtpl:280
+//line lib/promscrape/targetstatus.qtpl:292
 	for i, tlj := range tljs {
-//line lib/promscrape/targetstatus.qtpl:281
+//line lib/promscrape/targetstatus.qtpl:293
 		streamdiscoveredJobTargets(qw422016, i, tlj)
-//line lib/promscrape/targetstatus.qtpl:282
+//line lib/promscrape/targetstatus.qtpl:294
 	}
-//line lib/promscrape/targetstatus.qtpl:282
+//line lib/promscrape/targetstatus.qtpl:294
 	qw422016.N().S(`</div></div>`)
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 }
 
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 func writediscoveredTargets(qq422016 qtio422016.Writer, tsr *targetsStatusResult) {
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 	streamdiscoveredTargets(qw422016, tsr)
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 }
 
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 func discoveredTargets(tsr *targetsStatusResult) string {
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 	writediscoveredTargets(qb422016, tsr)
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:285
+//line lib/promscrape/targetstatus.qtpl:297
 }
 
-//line lib/promscrape/targetstatus.qtpl:287
+//line lib/promscrape/targetstatus.qtpl:299
 func streamdiscoveredJobTargets(qw422016 *qt422016.Writer, num int, tlj *targetLabelsByJob) {
-//line lib/promscrape/targetstatus.qtpl:287
+//line lib/promscrape/targetstatus.qtpl:299
 	qw422016.N().S(`<h4><span class="me-2">`)
-//line lib/promscrape/targetstatus.qtpl:289
+//line lib/promscrape/targetstatus.qtpl:301
 	qw422016.E().S(tlj.jobName)
-//line lib/promscrape/targetstatus.qtpl:289
+//line lib/promscrape/targetstatus.qtpl:301
 	qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:289
+//line lib/promscrape/targetstatus.qtpl:301
 	qw422016.N().S(`(`)
-//line lib/promscrape/targetstatus.qtpl:289
+//line lib/promscrape/targetstatus.qtpl:301
 	qw422016.N().D(tlj.activeTargets)
-//line lib/promscrape/targetstatus.qtpl:289
+//line lib/promscrape/targetstatus.qtpl:301
 	qw422016.N().S(`/`)
-//line lib/promscrape/targetstatus.qtpl:289
+//line lib/promscrape/targetstatus.qtpl:301
 	qw422016.N().D(tlj.activeTargets + tlj.droppedTargets)
-//line lib/promscrape/targetstatus.qtpl:289
+//line lib/promscrape/targetstatus.qtpl:301
 	qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:289
+//line lib/promscrape/targetstatus.qtpl:301
 	qw422016.N().S(`active)</span>`)
-//line lib/promscrape/targetstatus.qtpl:290
+//line lib/promscrape/targetstatus.qtpl:302
 	streamshowHideScrapeJobButtons(qw422016, num)
-//line lib/promscrape/targetstatus.qtpl:290
+//line lib/promscrape/targetstatus.qtpl:302
 	qw422016.N().S(`</h4><div id="scrape-job-`)
-//line lib/promscrape/targetstatus.qtpl:292
+//line lib/promscrape/targetstatus.qtpl:304
 	qw422016.N().D(num)
-//line lib/promscrape/targetstatus.qtpl:292
+//line lib/promscrape/targetstatus.qtpl:304
 	qw422016.N().S(`" class="scrape-job table-responsive"><table class="table table-striped table-hover table-bordered table-sm"><thead><tr><th scope="col" style="width: 5%">Status</th><th scope="col" style="width: 60%">Discovered Labels</th><th scope="col" style="width: 30%">Target Labels</th><th scope="col" stile="width: 5%">Debug relabeling</a></tr></thead><tbody>`)
-//line lib/promscrape/targetstatus.qtpl:303
+//line lib/promscrape/targetstatus.qtpl:315
 	for _, t := range tlj.targets {
-//line lib/promscrape/targetstatus.qtpl:303
+//line lib/promscrape/targetstatus.qtpl:315
 		qw422016.N().S(`<tr`)
-//line lib/promscrape/targetstatus.qtpl:305
+//line lib/promscrape/targetstatus.qtpl:317
 		if !t.up {
-//line lib/promscrape/targetstatus.qtpl:306
+//line lib/promscrape/targetstatus.qtpl:318
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:306
+//line lib/promscrape/targetstatus.qtpl:318
 			qw422016.N().S(`role="alert"`)
-//line lib/promscrape/targetstatus.qtpl:306
+//line lib/promscrape/targetstatus.qtpl:318
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:307
+//line lib/promscrape/targetstatus.qtpl:319
 			if t.labels.Len() > 0 {
-//line lib/promscrape/targetstatus.qtpl:307
+//line lib/promscrape/targetstatus.qtpl:319
 				qw422016.N().S(`class="alert alert-danger"`)
-//line lib/promscrape/targetstatus.qtpl:309
+//line lib/promscrape/targetstatus.qtpl:321
 			} else {
-//line lib/promscrape/targetstatus.qtpl:309
+//line lib/promscrape/targetstatus.qtpl:321
 				qw422016.N().S(`class="alert alert-warning"`)
-//line lib/promscrape/targetstatus.qtpl:311
+//line lib/promscrape/targetstatus.qtpl:323
 			}
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:324
 		}
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:324
 		qw422016.N().S(`><td>`)
-//line lib/promscrape/targetstatus.qtpl:315
+//line lib/promscrape/targetstatus.qtpl:327
 		if t.up {
-//line lib/promscrape/targetstatus.qtpl:315
+//line lib/promscrape/targetstatus.qtpl:327
 			qw422016.N().S(`<span class="badge bg-success">UP</span>`)
-//line lib/promscrape/targetstatus.qtpl:317
+//line lib/promscrape/targetstatus.qtpl:329
 		} else if t.labels.Len() > 0 {
-//line lib/promscrape/targetstatus.qtpl:317
+//line lib/promscrape/targetstatus.qtpl:329
 			qw422016.N().S(`<span class="badge bg-danger">DOWN</span>`)
-//line lib/promscrape/targetstatus.qtpl:319
+//line lib/promscrape/targetstatus.qtpl:331
 		} else {
-//line lib/promscrape/targetstatus.qtpl:319
+//line lib/promscrape/targetstatus.qtpl:331
 			qw422016.N().S(`<span class="badge bg-warning">DROPPED</span>`)
-//line lib/promscrape/targetstatus.qtpl:321
+//line lib/promscrape/targetstatus.qtpl:333
 		}
-//line lib/promscrape/targetstatus.qtpl:321
+//line lib/promscrape/targetstatus.qtpl:333
 		qw422016.N().S(`</td><td class="labels">`)
-//line lib/promscrape/targetstatus.qtpl:324
+//line lib/promscrape/targetstatus.qtpl:336
 		streamformatLabels(qw422016, t.originalLabels)
-//line lib/promscrape/targetstatus.qtpl:324
+//line lib/promscrape/targetstatus.qtpl:336
 		qw422016.N().S(`</td><td class="labels">`)
-//line lib/promscrape/targetstatus.qtpl:327
+//line lib/promscrape/targetstatus.qtpl:339
 		streamformatLabels(qw422016, t.labels)
-//line lib/promscrape/targetstatus.qtpl:327
+//line lib/promscrape/targetstatus.qtpl:339
 		qw422016.N().S(`</td><td>`)
-//line lib/promscrape/targetstatus.qtpl:330
+//line lib/promscrape/targetstatus.qtpl:342
 		targetID := getLabelsID(t.originalLabels)
 
-//line lib/promscrape/targetstatus.qtpl:330
+//line lib/promscrape/targetstatus.qtpl:342
 		qw422016.N().S(`<a href="target-relabel-debug?id=`)
-//line lib/promscrape/targetstatus.qtpl:331
+//line lib/promscrape/targetstatus.qtpl:343
 		qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:331
+//line lib/promscrape/targetstatus.qtpl:343
 		qw422016.N().S(`" target="_blank">debug</a></td></tr>`)
-//line lib/promscrape/targetstatus.qtpl:334
+//line lib/promscrape/targetstatus.qtpl:346
 	}
-//line lib/promscrape/targetstatus.qtpl:334
+//line lib/promscrape/targetstatus.qtpl:346
 	qw422016.N().S(`</tbody></table></div>`)
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 }
 
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 func writediscoveredJobTargets(qq422016 qtio422016.Writer, num int, tlj *targetLabelsByJob) {
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 	streamdiscoveredJobTargets(qw422016, num, tlj)
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 }
 
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 func discoveredJobTargets(num int, tlj *targetLabelsByJob) string {
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 	writediscoveredJobTargets(qb422016, num, tlj)
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:350
 }
 
-//line lib/promscrape/targetstatus.qtpl:340
+//line lib/promscrape/targetstatus.qtpl:352
 func streamshowHideScrapeJobButtons(qw422016 *qt422016.Writer, num int) {
-//line lib/promscrape/targetstatus.qtpl:340
+//line lib/promscrape/targetstatus.qtpl:352
 	qw422016.N().S(`<button type="button" class="btn btn-primary btn-sm me-1"onclick="document.getElementById('scrape-job-`)
-//line lib/promscrape/targetstatus.qtpl:342
+//line lib/promscrape/targetstatus.qtpl:354
 	qw422016.N().D(num)
-//line lib/promscrape/targetstatus.qtpl:342
+//line lib/promscrape/targetstatus.qtpl:354
 	qw422016.N().S(`').style.display='none'">collapse</button><button type="button" class="btn btn-secondary btn-sm me-1"onclick="document.getElementById('scrape-job-`)
-//line lib/promscrape/targetstatus.qtpl:346
+//line lib/promscrape/targetstatus.qtpl:358
 	qw422016.N().D(num)
-//line lib/promscrape/targetstatus.qtpl:346
+//line lib/promscrape/targetstatus.qtpl:358
 	qw422016.N().S(`').style.display='block'">expand</button>`)
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 }
 
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 func writeshowHideScrapeJobButtons(qq422016 qtio422016.Writer, num int) {
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 	streamshowHideScrapeJobButtons(qw422016, num)
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 }
 
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 func showHideScrapeJobButtons(num int) string {
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 	writeshowHideScrapeJobButtons(qb422016, num)
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:349
+//line lib/promscrape/targetstatus.qtpl:361
 }
 
-//line lib/promscrape/targetstatus.qtpl:351
+//line lib/promscrape/targetstatus.qtpl:363
 func streamqueryArgs(qw422016 *qt422016.Writer, filter *requestFilter, override map[string]string) {
-//line lib/promscrape/targetstatus.qtpl:353
+//line lib/promscrape/targetstatus.qtpl:365
 	showOnlyUnhealthy := "false"
 	if filter.showOnlyUnhealthy {
 		showOnlyUnhealthy = "true"
@@ -937,89 +979,89 @@ func streamqueryArgs(qw422016 *qt422016.Writer, filter *requestFilter, override
 		qa[k] = []string{v}
 	}
 
-//line lib/promscrape/targetstatus.qtpl:370
+//line lib/promscrape/targetstatus.qtpl:382
 	qw422016.E().S(qa.Encode())
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 }
 
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 func writequeryArgs(qq422016 qtio422016.Writer, filter *requestFilter, override map[string]string) {
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 	streamqueryArgs(qw422016, filter, override)
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 }
 
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 func queryArgs(filter *requestFilter, override map[string]string) string {
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 	writequeryArgs(qb422016, filter, override)
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:383
 }
 
-//line lib/promscrape/targetstatus.qtpl:373
+//line lib/promscrape/targetstatus.qtpl:385
 func streamformatLabels(qw422016 *qt422016.Writer, labels *promutils.Labels) {
-//line lib/promscrape/targetstatus.qtpl:374
+//line lib/promscrape/targetstatus.qtpl:386
 	labelsList := labels.GetLabels()
 
-//line lib/promscrape/targetstatus.qtpl:374
+//line lib/promscrape/targetstatus.qtpl:386
 	qw422016.N().S(`{`)
-//line lib/promscrape/targetstatus.qtpl:376
+//line lib/promscrape/targetstatus.qtpl:388
 	for i, label := range labelsList {
-//line lib/promscrape/targetstatus.qtpl:377
+//line lib/promscrape/targetstatus.qtpl:389
 		qw422016.E().S(label.Name)
-//line lib/promscrape/targetstatus.qtpl:377
+//line lib/promscrape/targetstatus.qtpl:389
 		qw422016.N().S(`=`)
-//line lib/promscrape/targetstatus.qtpl:377
+//line lib/promscrape/targetstatus.qtpl:389
 		qw422016.E().Q(label.Value)
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:390
 		if i+1 < len(labelsList) {
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:390
 			qw422016.N().S(`,`)
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:390
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:390
 		}
-//line lib/promscrape/targetstatus.qtpl:379
+//line lib/promscrape/targetstatus.qtpl:391
 	}
-//line lib/promscrape/targetstatus.qtpl:379
+//line lib/promscrape/targetstatus.qtpl:391
 	qw422016.N().S(`}`)
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 }
 
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 func writeformatLabels(qq422016 qtio422016.Writer, labels *promutils.Labels) {
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 	streamformatLabels(qw422016, labels)
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 }
 
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 func formatLabels(labels *promutils.Labels) string {
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 	writeformatLabels(qb422016, labels)
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:381
+//line lib/promscrape/targetstatus.qtpl:393
 }
@@ -0,0 +1,402 @@
+package prometheus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"mime"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+)
+
+// IsProtobufContentType returns true if contentType refers to delimited Prometheus protobuf exposition format.
+//
+// See https://github.com/prometheus/docs/blob/main/content/docs/instrumenting/exposition_formats.md#protobuf-format
+func IsProtobufContentType(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if mediaType != "application/vnd.google.protobuf" {
+		return false
+	}
+	if proto := params["proto"]; proto != "" && proto != "io.prometheus.client.MetricFamily" {
+		return false
+	}
+	return params["encoding"] == "delimited"
+}
+
+// Metric types from io.prometheus.client.MetricType
+const (
+	metricTypeCounter        = 0
+	metricTypeGauge          = 1
+	metricTypeSummary        = 2
+	metricTypeUntyped        = 3
+	metricTypeHistogram      = 4
+	metricTypeGaugeHistogram = 5
+)
+
+// UnmarshalProtobuf unmarshals varint-delimited io.prometheus.client.MetricFamily messages from data.
+//
+// Summaries and histograms are expanded into `_sum`, `_count` and `_bucket` series
+// in the same way as the Prometheus text exposition format does.
+// Metric names and label names are sanitized, so they contain only chars supported by Prometheus.
+//
+// data shouldn't be modified while rs is in use.
+func (rs *Rows) UnmarshalProtobuf(data []byte) error {
+	rows := rs.Rows[:0]
+	tagsPool := rs.tagsPool[:0]
+	var err error
+	for len(data) > 0 {
+		n, size := binary.Uvarint(data)
+		if size <= 0 {
+			err = fmt.Errorf("cannot read MetricFamily length: invalid varint")
+			break
+		}
+		data = data[size:]
+		if n > uint64(len(data)) {
+			err = fmt.Errorf("too big MetricFamily length: %d bytes; only %d bytes left in the input", n, len(data))
+			break
+		}
+		rows, tagsPool, err = unmarshalMetricFamily(rows, tagsPool, data[:n])
+		if err != nil {
+			err = fmt.Errorf("cannot unmarshal MetricFamily: %w", err)
+			break
+		}
+		data = data[n:]
+	}
+	rs.Rows = rows
+	rs.tagsPool = tagsPool
+	return err
+}
+
+type metricFamily struct {
+	name       string
+	metricType uint64
+	metrics    [][]byte
+}
+
+func unmarshalMetricFamily(dst []Row, tagsPool []Tag, src []byte) ([]Row, []Tag, error) {
+	var mf metricFamily
+	err := iterateFields(src, func(fieldNum, wireType uint64, v uint64, data []byte) error {
+		switch fieldNum {
+		case 1:
+			if wireType != wireTypeLen {
+				return fmt.Errorf("unexpected wire type for name: %d", wireType)
+			}
+			mf.name = bytesutil.ToUnsafeString(data)
+		case 3:
+			if wireType != wireTypeVarint {
+				return fmt.Errorf("unexpected wire type for type: %d", wireType)
+			}
+			mf.metricType = v
+		case 4:
+			if wireType != wireTypeLen {
+				return fmt.Errorf("unexpected wire type for metric: %d", wireType)
+			}
+			mf.metrics = append(mf.metrics, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return dst, tagsPool, err
+	}
+	if mf.name == "" {
+		return dst, tagsPool, fmt.Errorf("missing metric name")
+	}
+	name := sanitizeName(mf.name)
+	for _, m := range mf.metrics {
+		dst, tagsPool, err = unmarshalProtobufMetric(dst, tagsPool, name, mf.metricType, m)
+		if err != nil {
+			return dst, tagsPool, fmt.Errorf("cannot unmarshal metric %q: %w", name, err)
+		}
+	}
+	return dst, tagsPool, nil
+}
+
+type bucket struct {
+	upperBound float64
+	count      float64
+}
+
+type quantile struct {
+	quantile float64
+	value    float64
+}
+
+func unmarshalProtobufMetric(dst []Row, tagsPool []Tag, name string, metricType uint64, src []byte) ([]Row, []Tag, error) {
+	tagsStart := len(tagsPool)
+	var timestamp int64
+	var value float64
+	var sampleCount float64
+	var sampleSum float64
+	var buckets []bucket
+	var quantiles []quantile
+	err := iterateFields(src, func(fieldNum, wireType uint64, v uint64, data []byte) error {
+		if fieldNum == 6 {
+			if wireType != wireTypeVarint {
+				return fmt.Errorf("unexpected wire type for timestamp_ms: %d", wireType)
+			}
+			timestamp = int64(v)
+			return nil
+		}
+		if wireType != wireTypeLen {
+			return nil
+		}
+		switch fieldNum {
+		case 1:
+			var tag Tag
+			if err := unmarshalLabelPair(&tag, data); err != nil {
+				return fmt.Errorf("cannot unmarshal label: %w", err)
+			}
+			tagsPool = append(tagsPool, tag)
+		case 2, 3, 5:
+			// Gauge, Counter and Untyped messages contain the value at field 1
+			return iterateFields(data, func(fieldNum, wireType uint64, v uint64, _ []byte) error {
+				if fieldNum == 1 && wireType == wireTypeI64 {
+					value = math.Float64frombits(v)
+				}
+				return nil
+			})
+		case 4:
+			return iterateFields(data, func(fieldNum, wireType uint64, v uint64, data []byte) error {
+				switch {
+				case fieldNum == 1 && wireType == wireTypeVarint:
+					sampleCount = float64(v)
+				case fieldNum == 2 && wireType == wireTypeI64:
+					sampleSum = math.Float64frombits(v)
+				case fieldNum == 3 && wireType == wireTypeLen:
+					var q quantile
+					err := iterateFields(data, func(fieldNum, wireType uint64, v uint64, _ []byte) error {
+						if wireType != wireTypeI64 {
+							return nil
+						}
+						switch fieldNum {
+						case 1:
+							q.quantile = math.Float64frombits(v)
+						case 2:
+							q.value = math.Float64frombits(v)
+						}
+						return nil
+					})
+					if err != nil {
+						return fmt.Errorf("cannot unmarshal quantile: %w", err)
+					}
+					quantiles = append(quantiles, q)
+				}
+				return nil
+			})
+		case 7:
+			return iterateFields(data, func(fieldNum, wireType uint64, v uint64, data []byte) error {
+				switch {
+				case fieldNum == 1 && wireType == wireTypeVarint:
+					sampleCount = float64(v)
+				case fieldNum == 4 && wireType == wireTypeI64:
+					sampleCount = math.Float64frombits(v)
+				case fieldNum == 2 && wireType == wireTypeI64:
+					sampleSum = math.Float64frombits(v)
+				case fieldNum == 3 && wireType == wireTypeLen:
+					var b bucket
+					err := iterateFields(data, func(fieldNum, wireType uint64, v uint64, _ []byte) error {
+						switch {
+						case fieldNum == 1 && wireType == wireTypeVarint:
+							b.count = float64(v)
+						case fieldNum == 4 && wireType == wireTypeI64:
+							b.count = math.Float64frombits(v)
+						case fieldNum == 2 && wireType == wireTypeI64:
+							b.upperBound = math.Float64frombits(v)
+						}
+						return nil
+					})
+					if err != nil {
+						return fmt.Errorf("cannot unmarshal bucket: %w", err)
+					}
+					buckets = append(buckets, b)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return dst, tagsPool, err
+	}
+	tags := tagsPool[tagsStart:]
+	if len(tags) == 0 {
+		tags = nil
+	}
+
+	switch metricType {
+	case metricTypeCounter, metricTypeGauge, metricTypeUntyped:
+		dst = appendProtobufRow(dst, name, tags, value, timestamp)
+	case metricTypeSummary:
+		for _, q := range quantiles {
+			tagsPool = appendTagsWithExtra(tagsPool, tags, "quantile", formatFloat(q.quantile))
+			dst = appendProtobufRow(dst, name, tagsPool[len(tagsPool)-len(tags)-1:], q.value, timestamp)
+		}
+		dst = appendProtobufRow(dst, name+"_sum", tags, sampleSum, timestamp)
+		dst = appendProtobufRow(dst, name+"_count", tags, sampleCount, timestamp)
+	case metricTypeHistogram, metricTypeGaugeHistogram:
+		bucketName := name + "_bucket"
+		hasInf := false
+		for _, b := range buckets {
+			if math.IsInf(b.upperBound, 1) {
+				hasInf = true
+			}
+			tagsPool = appendTagsWithExtra(tagsPool, tags, "le", formatFloat(b.upperBound))
+			dst = appendProtobufRow(dst, bucketName, tagsPool[len(tagsPool)-len(tags)-1:], b.count, timestamp)
+		}
+		if !hasInf {
+			// The text exposition format always contains the `+Inf` bucket.
+			tagsPool = appendTagsWithExtra(tagsPool, tags, "le", "+Inf")
+			dst = appendProtobufRow(dst, bucketName, tagsPool[len(tagsPool)-len(tags)-1:], sampleCount, timestamp)
+		}
+		dst = appendProtobufRow(dst, name+"_sum", tags, sampleSum, timestamp)
+		dst = appendProtobufRow(dst, name+"_count", tags, sampleCount, timestamp)
+	default:
+		return dst, tagsPool, fmt.Errorf("unsupported metric type: %d", metricType)
+	}
+	return dst, tagsPool, nil
+}
+
+func appendProtobufRow(dst []Row, name string, tags []Tag, value float64, timestamp int64) []Row {
+	if cap(dst) > len(dst) {
+		dst = dst[:len(dst)+1]
+	} else {
+		dst = append(dst, Row{})
+	}
+	r := &dst[len(dst)-1]
+	r.Metric = name
+	r.Tags = tags
+	r.Value = value
+	r.Timestamp = timestamp
+	return dst
+}
+
+func appendTagsWithExtra(dst, tags []Tag, key, value string) []Tag {
+	dst = append(dst, tags...)
+	return append(dst, Tag{
+		Key:   key,
+		Value: value,
+	})
+}
+
+func unmarshalLabelPair(tag *Tag, src []byte) error {
+	return iterateFields(src, func(fieldNum, wireType uint64, _ uint64, data []byte) error {
+		if wireType != wireTypeLen {
+			return nil
+		}
+		switch fieldNum {
+		case 1:
+			tag.Key = sanitizeName(bytesutil.ToUnsafeString(data))
+		case 2:
+			tag.Value = bytesutil.ToUnsafeString(data)
+		}
+		return nil
+	})
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// sanitizeName replaces chars unsupported by Prometheus in metric names and label names with _.
+//
+// See https://prometheus.io/docs/concepts/data_model/#metric-names-and-labels
+func sanitizeName(s string) string {
+	n := 0
+	for n < len(s) && isPromNameChar(s[n]) {
+		n++
+	}
+	if n == len(s) {
+		return s
+	}
+	b := []byte(s)
+	for i := n; i < len(b); i++ {
+		if !isPromNameChar(b[i]) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func isPromNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == ':'
+}
+
+// Protobuf wire types.
+//
+// See https://protobuf.dev/programming-guides/encoding/#structure
+const (
+	wireTypeVarint = 0
+	wireTypeI64    = 1
+	wireTypeLen    = 2
+	wireTypeI32    = 5
+)
+
+// iterateFields calls f for every field in the protobuf message src.
+//
+// v contains the value for varint, i64 and i32 fields, while data contains the value for length-delimited fields.
+func iterateFields(src []byte, f func(fieldNum, wireType uint64, v uint64, data []byte) error) error {
+	for len(src) > 0 {
+		tag, size := binary.Uvarint(src)
+		if size <= 0 {
+			return fmt.Errorf("cannot read field tag: invalid varint")
+		}
+		src = src[size:]
+		fieldNum := tag >> 3
+		wireType := tag & 0x7
+		if fieldNum == 0 {
+			return fmt.Errorf("illegal field number 0")
+		}
+		var v uint64
+		var data []byte
+		switch wireType {
+		case wireTypeVarint:
+			v, size = binary.Uvarint(src)
+			if size <= 0 {
+				return fmt.Errorf("cannot read varint value for field #%d", fieldNum)
+			}
+			src = src[size:]
+		case wireTypeI64:
+			if len(src) < 8 {
+				return fmt.Errorf("cannot read i64 value for field #%d: only %d bytes left", fieldNum, len(src))
+			}
+			v = binary.LittleEndian.Uint64(src)
+			src = src[8:]
+		case wireTypeLen:
+			n, size := binary.Uvarint(src)
+			if size <= 0 {
+				return fmt.Errorf("cannot read length for field #%d", fieldNum)
+			}
+			src = src[size:]
+			if n > uint64(len(src)) {
+				return fmt.Errorf("too big length for field #%d: %d bytes; only %d bytes left", fieldNum, n, len(src))
+			}
+			data = src[:n]
+			src = src[n:]
+		case wireTypeI32:
+			if len(src) < 4 {
+				return fmt.Errorf("cannot read i32 value for field #%d: only %d bytes left", fieldNum, len(src))
+			}
+			v = uint64(binary.LittleEndian.Uint32(src))
+			src = src[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d for field #%d", wireType, fieldNum)
+		}
+		if err := f(fieldNum, wireType, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package prometheus
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestIsProtobufContentType(t *testing.T) {
+	f := func(contentType string, resultExpected bool) {
+		t.Helper()
+		result := IsProtobufContentType(contentType)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for IsProtobufContentType(%q); got %v; want %v", contentType, result, resultExpected)
+		}
+	}
+	f("", false)
+	f("text/plain", false)
+	f("text/plain; version=0.0.4", false)
+	f("application/vnd.google.protobuf", false)
+	f("application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=text", false)
+	f("application/vnd.google.protobuf; proto=foo.Bar; encoding=delimited", false)
+	f("application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited", true)
+	f("application/vnd.google.protobuf;encoding=delimited", true)
+}
+
+func TestRowsUnmarshalProtobufSuccess(t *testing.T) {
+	f := func(data []byte, rowsExpected []Row) {
+		t.Helper()
+		var rows Rows
+		if err := rows.UnmarshalProtobuf(data); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows;\ngot\n%+v\nwant\n%+v", rows.Rows, rowsExpected)
+		}
+
+		// Try unmarshaling again
+		if err := rows.UnmarshalProtobuf(data); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows on the second unmarshal;\ngot\n%+v\nwant\n%+v", rows.Rows, rowsExpected)
+		}
+
+		rows.Reset()
+		if len(rows.Rows) != 0 {
+			t.Fatalf("non-empty rows after reset: %+v", rows.Rows)
+		}
+	}
+
+	// Empty input
+	f(nil, nil)
+
+	// Counter with labels and timestamp
+	f(appendDelimited(nil, marshalMetricFamily("foo_total", metricTypeCounter,
+		marshalMetric([][2]string{{"job", "x"}, {"bad-name", "y"}}, 3, marshalValue(123), 1234),
+	)), []Row{{
+		Metric: "foo_total",
+		Tags: []Tag{
+			{Key: "job", Value: "x"},
+			{Key: "bad_name", Value: "y"},
+		},
+		Value:     123,
+		Timestamp: 1234,
+	}})
+
+	// Multiple gauges and untyped metric in distinct families
+	data := appendDelimited(nil, marshalMetricFamily("foo.bar", metricTypeGauge,
+		marshalMetric(nil, 2, marshalValue(-1.5), 0),
+		marshalMetric([][2]string{{"a", "b"}}, 2, marshalValue(2), 0),
+	))
+	data = appendDelimited(data, marshalMetricFamily("baz", metricTypeUntyped,
+		marshalMetric(nil, 5, marshalValue(math.Inf(1)), 0),
+	))
+	f(data, []Row{
+		{
+			Metric: "foo_bar",
+			Value:  -1.5,
+		},
+		{
+			Metric: "foo_bar",
+			Tags:   []Tag{{Key: "a", Value: "b"}},
+			Value:  2,
+		},
+		{
+			Metric: "baz",
+			Value:  math.Inf(1),
+		},
+	})
+
+	// Summary
+	var summary []byte
+	summary = appendVarintField(summary, 1, 10)
+	summary = appendDoubleField(summary, 2, 42.5)
+	summary = appendBytesField(summary, 3, appendDoubleField(appendDoubleField(nil, 1, 0.5), 2, 3))
+	summary = appendBytesField(summary, 3, appendDoubleField(appendDoubleField(nil, 1, 0.99), 2, 7))
+	f(appendDelimited(nil, marshalMetricFamily("rpc_duration_seconds", metricTypeSummary,
+		marshalMetric([][2]string{{"service", "a"}}, 4, summary, 0),
+	)), []Row{
+		{
+			Metric: "rpc_duration_seconds",
+			Tags: []Tag{
+				{Key: "service", Value: "a"},
+				{Key: "quantile", Value: "0.5"},
+			},
+			Value: 3,
+		},
+		{
+			Metric: "rpc_duration_seconds",
+			Tags: []Tag{
+				{Key: "service", Value: "a"},
+				{Key: "quantile", Value: "0.99"},
+			},
+			Value: 7,
+		},
+		{
+			Metric: "rpc_duration_seconds_sum",
+			Tags:   []Tag{{Key: "service", Value: "a"}},
+			Value:  42.5,
+		},
+		{
+			Metric: "rpc_duration_seconds_count",
+			Tags:   []Tag{{Key: "service", Value: "a"}},
+			Value:  10,
+		},
+	})
+
+	// Histogram without +Inf bucket
+	var histogram []byte
+	histogram = appendVarintField(histogram, 1, 5)
+	histogram = appendDoubleField(histogram, 2, 11)
+	histogram = appendBytesField(histogram, 3, appendDoubleField(appendVarintField(nil, 1, 2), 2, 0.1))
+	histogram = appendBytesField(histogram, 3, appendDoubleField(appendVarintField(nil, 1, 4), 2, 1))
+	f(appendDelimited(nil, marshalMetricFamily("req_duration", metricTypeHistogram,
+		marshalMetric(nil, 7, histogram, 100),
+	)), []Row{
+		{
+			Metric:    "req_duration_bucket",
+			Tags:      []Tag{{Key: "le", Value: "0.1"}},
+			Value:     2,
+			Timestamp: 100,
+		},
+		{
+			Metric:    "req_duration_bucket",
+			Tags:      []Tag{{Key: "le", Value: "1"}},
+			Value:     4,
+			Timestamp: 100,
+		},
+		{
+			Metric:    "req_duration_bucket",
+			Tags:      []Tag{{Key: "le", Value: "+Inf"}},
+			Value:     5,
+			Timestamp: 100,
+		},
+		{
+			Metric:    "req_duration_sum",
+			Value:     11,
+			Timestamp: 100,
+		},
+		{
+			Metric:    "req_duration_count",
+			Value:     5,
+			Timestamp: 100,
+		},
+	})
+
+	// Histogram with +Inf bucket
+	histogram = histogram[:0]
+	histogram = appendVarintField(histogram, 1, 3)
+	histogram = appendDoubleField(histogram, 2, 1)
+	histogram = appendBytesField(histogram, 3, appendDoubleField(appendVarintField(nil, 1, 3), 2, math.Inf(1)))
+	f(appendDelimited(nil, marshalMetricFamily("h", metricTypeHistogram,
+		marshalMetric(nil, 7, histogram, 0),
+	)), []Row{
+		{
+			Metric: "h_bucket",
+			Tags:   []Tag{{Key: "le", Value: "+Inf"}},
+			Value:  3,
+		},
+		{
+			Metric: "h_sum",
+			Value:  1,
+		},
+		{
+			Metric: "h_count",
+			Value:  3,
+		},
+	})
+}
+
+func TestRowsUnmarshalProtobufFailure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+		var rows Rows
+		if err := rows.UnmarshalProtobuf(data); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	valid := appendDelimited(nil, marshalMetricFamily("foo", metricTypeGauge, marshalMetric(nil, 2, marshalValue(1), 0)))
+
+	// Invalid varint delimiter
+	f([]byte{0xff})
+	f([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	// Too big length in the delimiter
+	f([]byte{0x10, 0x01})
+
+	// Truncated message
+	f(valid[:len(valid)-1])
+
+	// Missing metric name
+	f(appendDelimited(nil, appendVarintField(nil, 3, metricTypeGauge)))
+
+	// Unsupported metric type
+	f(appendDelimited(nil, marshalMetricFamily("foo", 123, marshalMetric(nil, 2, marshalValue(1), 0))))
+
+	// Invalid wire type for name
+	f(appendDelimited(nil, appendVarintField(nil, 1, 123)))
+
+	// Unsupported wire type
+	f(appendDelimited(nil, []byte{0x0b}))
+}
+
+func FuzzRowsUnmarshalProtobuf(f *testing.F) {
+	var summary []byte
+	summary = appendVarintField(summary, 1, 10)
+	summary = appendBytesField(summary, 3, appendDoubleField(appendDoubleField(nil, 1, 0.5), 2, 3))
+	data := appendDelimited(nil, marshalMetricFamily("foo", metricTypeCounter,
+		marshalMetric([][2]string{{"job", "x"}}, 3, marshalValue(1), 1234),
+	))
+	data = appendDelimited(data, marshalMetricFamily("bar", metricTypeSummary, marshalMetric(nil, 4, summary, 0)))
+	f.Add(data)
+	f.Add([]byte{0xff, 0x01})
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var rows Rows
+		_ = rows.UnmarshalProtobuf(data)
+	})
+}
+
+func appendDelimited(dst, msg []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(msg)))
+	return append(dst, msg...)
+}
+
+func marshalMetricFamily(name string, metricType uint64, metrics ...[]byte) []byte {
+	dst := appendBytesField(nil, 1, []byte(name))
+	dst = appendVarintField(dst, 3, metricType)
+	for _, m := range metrics {
+		dst = appendBytesField(dst, 4, m)
+	}
+	return dst
+}
+
+func marshalMetric(labels [][2]string, valueField uint64, value []byte, timestamp int64) []byte {
+	var dst []byte
+	for _, label := range labels {
+		lp := appendBytesField(nil, 1, []byte(label[0]))
+		lp = appendBytesField(lp, 2, []byte(label[1]))
+		dst = appendBytesField(dst, 1, lp)
+	}
+	dst = appendBytesField(dst, valueField, value)
+	if timestamp != 0 {
+		dst = appendVarintField(dst, 6, uint64(timestamp))
+	}
+	return dst
+}
+
+func marshalValue(v float64) []byte {
+	return appendDoubleField(nil, 1, v)
+}
+
+func appendVarintField(dst []byte, fieldNum, v uint64) []byte {
+	dst = binary.AppendUvarint(dst, fieldNum<<3|wireTypeVarint)
+	return binary.AppendUvarint(dst, v)
+}
+
+func appendDoubleField(dst []byte, fieldNum uint64, v float64) []byte {
+	dst = binary.AppendUvarint(dst, fieldNum<<3|wireTypeI64)
+	return binary.LittleEndian.AppendUint64(dst, math.Float64bits(v))
+}
+
+func appendBytesField(dst []byte, fieldNum uint64, data []byte) []byte {
+	dst = binary.AppendUvarint(dst, fieldNum<<3|wireTypeLen)
+	dst = binary.AppendUvarint(dst, uint64(len(data)))
+	return append(dst, data...)
+}
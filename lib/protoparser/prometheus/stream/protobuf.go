@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var maxProtobufRequestSize = flagutil.NewBytes("import.prometheus.maxProtobufRequestSize", 32*1024*1024, "The maximum size in bytes of a single request "+
+	"with Prometheus protobuf exposition format sent to /api/v1/import/prometheus")
+
+// ParseProtobuf parses delimited MetricFamily messages in Prometheus protobuf exposition format from r
+// and calls callback for the parsed rows.
+//
+// callback shouldn't hold rows after returning.
+func ParseProtobuf(r io.Reader, defaultTimestamp int64, isGzipped bool, callback func(rows []prometheus.Row) error) error {
+	wcr := writeconcurrencylimiter.GetReader(r)
+	defer writeconcurrencylimiter.PutReader(wcr)
+	r = wcr
+
+	if isGzipped {
+		zr, err := common.GetGzipReader(r)
+		if err != nil {
+			return fmt.Errorf("cannot read gzipped Prometheus protobuf exposition data: %w", err)
+		}
+		defer common.PutGzipReader(zr)
+		r = zr
+	}
+
+	bb := protobufBodyPool.Get()
+	defer protobufBodyPool.Put(bb)
+	protobufReadCalls.Inc()
+	lr := io.LimitReader(r, maxProtobufRequestSize.N+1)
+	reqLen, err := bb.ReadFrom(lr)
+	if err != nil {
+		protobufReadErrors.Inc()
+		return fmt.Errorf("cannot read Prometheus protobuf exposition data: %w", err)
+	}
+	if reqLen > maxProtobufRequestSize.N {
+		protobufReadErrors.Inc()
+		return fmt.Errorf("too big request; mustn't exceed `-import.prometheus.maxProtobufRequestSize=%d` bytes", maxProtobufRequestSize.N)
+	}
+
+	rs := getProtobufRows()
+	defer putProtobufRows(rs)
+	if err := rs.UnmarshalProtobuf(bb.B); err != nil {
+		protobufUnmarshalErrors.Inc()
+		return fmt.Errorf("cannot unmarshal Prometheus protobuf exposition data with size %d bytes: %w", len(bb.B), err)
+	}
+	rows := rs.Rows
+	protobufRowsRead.Add(len(rows))
+
+	// Fill missing timestamps with the current timestamp.
+	if defaultTimestamp <= 0 {
+		defaultTimestamp = time.Now().UnixNano() / 1e6
+	}
+	for i := range rows {
+		r := &rows[i]
+		if r.Timestamp == 0 {
+			r.Timestamp = defaultTimestamp
+		}
+	}
+
+	if err := callback(rows); err != nil {
+		return fmt.Errorf("error when processing imported data: %w", err)
+	}
+	return nil
+}
+
+var protobufBodyPool bytesutil.ByteBufferPool
+
+var (
+	protobufReadCalls       = metrics.NewCounter(`vm_protoparser_read_calls_total{type="prometheus_protobuf"}`)
+	protobufReadErrors      = metrics.NewCounter(`vm_protoparser_read_errors_total{type="prometheus_protobuf"}`)
+	protobufRowsRead        = metrics.NewCounter(`vm_protoparser_rows_read_total{type="prometheus_protobuf"}`)
+	protobufUnmarshalErrors = metrics.NewCounter(`vm_protoparser_unmarshal_errors_total{type="prometheus_protobuf"}`)
+)
+
+func getProtobufRows() *prometheus.Rows {
+	v := protobufRowsPool.Get()
+	if v == nil {
+		return &prometheus.Rows{}
+	}
+	return v.(*prometheus.Rows)
+}
+
+func putProtobufRows(rs *prometheus.Rows) {
+	rs.Reset()
+	protobufRowsPool.Put(rs)
+}
+
+var protobufRowsPool sync.Pool
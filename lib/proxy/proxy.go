@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"fmt"
@@ -34,6 +35,21 @@ func MustNewURL(u string) *URL {
 	}
 }
 
+// ParseURL parses and validates proxy url s.
+func ParseURL(s string) (*URL, error) {
+	pu, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy url: %w", err)
+	}
+	u := &URL{
+		URL: pu,
+	}
+	if err := u.checkScheme(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
 // GetURL return the underlying url.
 func (u *URL) GetURL() *url.URL {
 	if u == nil || u.URL == nil {
@@ -123,13 +139,11 @@ func (u *URL) NewDialFunc(ac *promauth.Config) (fasthttp.DialFunc, error) {
 	if u == nil || u.URL == nil {
 		return defaultDialFunc, nil
 	}
-	pu := u.URL
-	switch pu.Scheme {
-	case "http", "https", "socks5", "tls+socks5":
-	default:
-		return nil, fmt.Errorf("unknown scheme=%q for proxy_url=%q, must be http, https, socks5 or tls+socks5", pu.Scheme, pu.Redacted())
+	if err := u.checkScheme(); err != nil {
+		return nil, err
 	}
-	isTLS := (pu.Scheme == "https" || pu.Scheme == "tls+socks5")
+	pu := u.URL
+	isTLS := (pu.Scheme == "https" || pu.Scheme == "tls+socks5" || pu.Scheme == "tls+socks5h")
 	proxyAddr := addMissingPort(pu.Host, isTLS)
 	var tlsCfg *tls.Config
 	if isTLS {
@@ -138,8 +152,11 @@ func (u *URL) NewDialFunc(ac *promauth.Config) (fasthttp.DialFunc, error) {
 			tlsCfg.ServerName = tlsServerName(proxyAddr)
 		}
 	}
-	if pu.Scheme == "socks5" || pu.Scheme == "tls+socks5" {
-		return socks5DialFunc(proxyAddr, pu, tlsCfg)
+	switch pu.Scheme {
+	case "socks5", "tls+socks5":
+		return socks5DialFunc(proxyAddr, pu, tlsCfg, false)
+	case "socks5h", "tls+socks5h":
+		return socks5DialFunc(proxyAddr, pu, tlsCfg, true)
 	}
 	dialFunc := func(addr string) (net.Conn, error) {
 		proxyConn, err := defaultDialFunc(proxyAddr)
@@ -164,7 +181,20 @@ func (u *URL) NewDialFunc(ac *promauth.Config) (fasthttp.DialFunc, error) {
 	return dialFunc, nil
 }
 
-func socks5DialFunc(proxyAddr string, pu *url.URL, tlsCfg *tls.Config) (fasthttp.DialFunc, error) {
+func (u *URL) checkScheme() error {
+	pu := u.URL
+	switch pu.Scheme {
+	case "http", "https", "socks5", "socks5h", "tls+socks5", "tls+socks5h":
+		return nil
+	default:
+		return fmt.Errorf("unknown scheme=%q for proxy_url=%q, must be http, https, socks5, socks5h, tls+socks5 or tls+socks5h", pu.Scheme, pu.Redacted())
+	}
+}
+
+// socks5DialFunc returns dial func for socks5 proxy at proxyAddr.
+//
+// Target host names are resolved by the proxy if remoteDNS is set. Otherwise they are resolved locally like curl does for socks5:// urls.
+func socks5DialFunc(proxyAddr string, pu *url.URL, tlsCfg *tls.Config, remoteDNS bool) (fasthttp.DialFunc, error) {
 	var sac *proxy.Auth
 	if pu.User != nil {
 		username := pu.User.Username()
@@ -186,11 +216,104 @@ func socks5DialFunc(proxyAddr string, pu *url.URL, tlsCfg *tls.Config) (fasthttp
 		return nil, fmt.Errorf("cannot create socks5 proxy for url: %s, err: %w", pu.Redacted(), err)
 	}
 	dialFunc := func(addr string) (net.Conn, error) {
-		return d.Dial(network, addr)
+		dstAddr := addr
+		if !remoteDNS {
+			resolvedAddr, err := resolveAddr(addr)
+			if err != nil {
+				return nil, err
+			}
+			dstAddr = resolvedAddr
+		}
+		conn, err := d.Dial(network, dstAddr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot connect to %q via socks5 proxy %q: %w", addr, pu.Redacted(), err)
+		}
+		return conn, nil
 	}
 	return dialFunc, nil
 }
 
+// resolveAddr replaces host name in addr with its ip address.
+func resolveAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse address %q: %w", addr, err)
+	}
+	if net.ParseIP(host) != nil {
+		return addr, nil
+	}
+	ipNetwork := "ip4"
+	if netutil.TCP6Enabled() {
+		ipNetwork = "ip"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIP(ctx, ipNetwork, host)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %q before connecting to socks5 proxy; use socks5h:// proxy url for resolving it by the proxy: %w", host, err)
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("cannot resolve %q before connecting to socks5 proxy: no ip addresses found", host)
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// IsNoProxyHost returns true if host matches one of noProxy entries, so it must be accessed without proxy.
+//
+// noProxy entries may contain ip addresses, CIDRs such as 10.0.0.0/8, host names and domain names.
+// Host name entries match the host itself and all its subdomains. Entries starting with `.` match only subdomains.
+// `*` matches all the hosts.
+func IsNoProxyHost(host string, noProxy []string) bool {
+	if len(noProxy) == 0 {
+		return false
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+	for _, s := range noProxy {
+		s = strings.ToLower(strings.TrimSpace(s))
+		switch {
+		case s == "*":
+			return true
+		case strings.Contains(s, "/"):
+			_, ipNet, err := net.ParseCIDR(s)
+			if err == nil && ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+		case strings.HasPrefix(s, "."):
+			if strings.HasSuffix(host, s) {
+				return true
+			}
+		default:
+			if sIP := net.ParseIP(s); sIP != nil {
+				if ip != nil && sIP.Equal(ip) {
+					return true
+				}
+				continue
+			}
+			if host == s || strings.HasSuffix(host, "."+s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckNoProxy verifies whether noProxy entries are valid for passing to IsNoProxyHost.
+func CheckNoProxy(noProxy []string) error {
+	for _, s := range noProxy {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return fmt.Errorf("no_proxy entries cannot be empty")
+		}
+		if strings.Contains(s, "/") {
+			if _, _, err := net.ParseCIDR(s); err != nil {
+				return fmt.Errorf("cannot parse no_proxy entry %q as CIDR: %w", s, err)
+			}
+		}
+	}
+	return nil
+}
+
 func addMissingPort(addr string, isTLS bool) string {
 	if strings.IndexByte(addr, ':') >= 0 {
 		return addr
@@ -46,6 +46,20 @@ type blockStreamWriter struct {
 	// since such metrics have identical timestamps.
 	prevTimestampsData        []byte
 	prevTimestampsBlockOffset uint64
+
+	// mp is the destination in-memory part if bsw is initialized via InitFromInmemoryPart.
+	mp *inmemoryPart
+
+	// bf is the bloom filter for metricIDs of the written blocks. It is set via initBloomFilter if bloom filters are enabled.
+	//
+	// The bloom filter is stored together with the created part at MustClose. See SetPartBloomFilter.
+	bf *metricIDsBloomFilter
+
+	// bfPrevMetricID is the metricID of the previous block added to bf.
+	bfPrevMetricID uint64
+
+	// bfItemsCount is the number of unique metricIDs added to bf.
+	bfItemsCount uint64
 }
 
 func (bsw *blockStreamWriter) assertWriteClosers() {
@@ -77,6 +91,22 @@ func (bsw *blockStreamWriter) reset() {
 
 	bsw.prevTimestampsData = bsw.prevTimestampsData[:0]
 	bsw.prevTimestampsBlockOffset = 0
+
+	bsw.mp = nil
+	bsw.bf = nil
+	bsw.bfPrevMetricID = 0
+	bsw.bfItemsCount = 0
+}
+
+// initBloomFilter initializes bloom filter for metricIDs of the blocks written to bsw if bloom filters are enabled.
+//
+// metricIDsCount must contain an upper bound for the number of unique metricIDs in the written blocks.
+// The function must be called after bsw initialization and before writing blocks to bsw.
+func (bsw *blockStreamWriter) initBloomFilter(metricIDsCount uint64) {
+	if !partBloomFilterEnabled {
+		return
+	}
+	bsw.bf = newMetricIDsBloomFilter(metricIDsCount, partBloomFilterFalsePositiveRate)
 }
 
 // InitFromInmemoryPart initializes bsw from inmemory part.
@@ -89,6 +119,8 @@ func (bsw *blockStreamWriter) InitFromInmemoryPart(mp *inmemoryPart, compressLev
 	bsw.indexWriter = &mp.indexData
 	bsw.metaindexWriter = &mp.metaindexData
 
+	bsw.mp = mp
+
 	bsw.assertWriteClosers()
 }
 
@@ -171,6 +203,17 @@ func (bsw *blockStreamWriter) MustClose() {
 	bsw.indexWriter.MustClose()
 	bsw.metaindexWriter.MustClose()
 
+	// Build bloom filter for the created part.
+	if bf := bsw.bf; bf != nil && bsw.bfItemsCount > 0 {
+		// The filter may be sized from an upper bound for the number of metricIDs, so shrink it to the actual number of metricIDs.
+		bf.shrink(bsw.bfItemsCount, partBloomFilterFalsePositiveRate)
+		if bsw.mp != nil {
+			bsw.mp.metricIDsBloomFilter = bf
+		} else if err := writeMetricIDsBloomFilter(bsw.path, bf); err != nil {
+			logger.Panicf("FATAL: cannot create bloom filter for part %q: %s", bsw.path, err)
+		}
+	}
+
 	// Sync bsw.path contents to make sure it doesn't disappear
 	// after system crash or power loss.
 	if bsw.path != "" {
@@ -195,6 +238,15 @@ func (bsw *blockStreamWriter) WriteExternalBlock(b *Block, ph *partHeader, rowsM
 	}
 	bsw.indexData = append(bsw.indexData, headerData...)
 	bsw.mr.RegisterBlockHeader(&b.bh)
+	if bsw.bf != nil {
+		// Blocks are written in the order of their TSIDs, so it is enough to compare metricID with the previous one.
+		metricID := b.bh.TSID.MetricID
+		if bsw.bfItemsCount == 0 || bsw.bfPrevMetricID != metricID {
+			bsw.bf.add(metricID)
+			bsw.bfPrevMetricID = metricID
+			bsw.bfItemsCount++
+		}
+	}
 	if len(bsw.indexData) >= maxBlockSize {
 		bsw.flushIndexData()
 	}
@@ -8,6 +8,8 @@ const (
 	partsFilename      = "parts.json"
 	metadataFilename   = "metadata.json"
 
+	metricIDsBloomFilterFilename = "metricids_bloom.bin"
+
 	appliedRetentionFilename    = "appliedRetention.txt"
 	resetCacheOnStartupFilename = "reset_cache_on_startup"
 )
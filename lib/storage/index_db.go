@@ -525,7 +525,7 @@ func (is *indexSearch) GetOrCreateTSIDByName(dst *TSID, metricName, metricNameRa
 		if err == nil {
 			// Fast path - the TSID for the given metricName has been found in the index.
 			is.tsidByNameMisses = 0
-			if err = is.db.s.registerSeriesCardinality(dst.MetricID, metricNameRaw); err != nil {
+			if err = is.db.s.registerSeriesCardinality(dst.MetricID, metricNameRaw, false); err != nil {
 				return err
 			}
 			// There is no need in checking whether the TSID is present in the per-day index for the given date,
@@ -592,7 +592,7 @@ func (is *indexSearch) createTSIDByName(dst *TSID, metricName, metricNameRaw []b
 	if err != nil {
 		return fmt.Errorf("cannot generate TSID: %w", err)
 	}
-	if err := is.db.s.registerSeriesCardinality(dst.MetricID, metricNameRaw); err != nil {
+	if err := is.db.s.registerSeriesCardinality(dst.MetricID, metricNameRaw, created); err != nil {
 		return err
 	}
 	is.createGlobalIndexes(dst, mn)
@@ -2384,7 +2384,7 @@ func (is *indexSearch) getMetricIDsForTagFilter(qt *querytracer.Tracer, tf *tagF
 	if len(tf.orSuffixes) > 0 {
 		// Fast path for orSuffixes - seek for rows for each value from orSuffixes.
 		loopsCount, err := is.updateMetricIDsForOrSuffixes(tf, metricIDs, maxMetrics, maxLoopsCount)
-		qt.Printf("found %d metric ids for filter={%s} using exact search; spent %d loops", metricIDs.Len(), tf, loopsCount)
+		qt.Printf("found %d metric ids for filter={%s} using %s; spent %d loops", metricIDs.Len(), tf, tf.searchStrategy(), loopsCount)
 		if err != nil {
 			return nil, loopsCount, fmt.Errorf("error when searching for metricIDs for tagFilter in fast path: %w; tagFilter=%s", err, tf)
 		}
@@ -2393,7 +2393,7 @@ func (is *indexSearch) getMetricIDsForTagFilter(qt *querytracer.Tracer, tf *tagF
 
 	// Slow path - scan for all the rows with the given prefix.
 	loopsCount, err := is.getMetricIDsForTagFilterSlow(tf, metricIDs.Add, maxLoopsCount)
-	qt.Printf("found %d metric ids for filter={%s} using prefix search; spent %d loops", metricIDs.Len(), tf, loopsCount)
+	qt.Printf("found %d metric ids for filter={%s} using %s; spent %d loops", metricIDs.Len(), tf, tf.searchStrategy(), loopsCount)
 	if err != nil {
 		return nil, loopsCount, fmt.Errorf("error when searching for metricIDs for tagFilter in slow path: %w; tagFilter=%s", err, tf)
 	}
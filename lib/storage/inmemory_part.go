@@ -20,6 +20,9 @@ type inmemoryPart struct {
 	indexData      bytesutil.ByteBuffer
 	metaindexData  bytesutil.ByteBuffer
 
+	// metricIDsBloomFilter is set by blockStreamWriter if bloom filters are enabled. See SetPartBloomFilter.
+	metricIDsBloomFilter *metricIDsBloomFilter
+
 	creationTime uint64
 }
 
@@ -31,6 +34,7 @@ func (mp *inmemoryPart) Reset() {
 	mp.valuesData.Reset()
 	mp.indexData.Reset()
 	mp.metaindexData.Reset()
+	mp.metricIDsBloomFilter = nil
 
 	mp.creationTime = 0
 }
@@ -56,6 +60,11 @@ func (mp *inmemoryPart) StoreToDisk(path string) error {
 	if err := fs.WriteFileAndSync(metaindexPath, mp.metaindexData.B); err != nil {
 		return fmt.Errorf("cannot store metaindex: %w", err)
 	}
+	if mp.metricIDsBloomFilter != nil {
+		if err := writeMetricIDsBloomFilter(path, mp.metricIDsBloomFilter); err != nil {
+			return err
+		}
+	}
 	if err := mp.ph.WriteMetadata(path); err != nil {
 		return fmt.Errorf("cannot store metadata: %w", err)
 	}
@@ -84,7 +93,7 @@ func (mp *inmemoryPart) InitFromRows(rows []rawRow) {
 // It is unsafe re-using mp while the returned part is in use.
 func (mp *inmemoryPart) NewPart() (*part, error) {
 	size := mp.size()
-	return newPart(&mp.ph, "", size, mp.metaindexData.NewReader(), &mp.timestampsData, &mp.valuesData, &mp.indexData)
+	return newPart(&mp.ph, "", size, mp.metaindexData.NewReader(), &mp.timestampsData, &mp.valuesData, &mp.indexData, mp.metricIDsBloomFilter)
 }
 
 func (mp *inmemoryPart) size() uint64 {
@@ -0,0 +1,398 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/cespare/xxhash/v2"
+)
+
+// metricUsageFilename is the name of the file inside metadata dir, where metric usage stats are persisted.
+const metricUsageFilename = "metric_usage.json"
+
+var metricUsageTrackerMaxEntries int
+
+// SetMetricUsageTrackerMaxEntries enables tracking of per-metric-name usage stats for up to maxEntries metric names.
+//
+// The tracking is disabled if maxEntries <= 0.
+// This function must be called before OpenStorage.
+func SetMetricUsageTrackerMaxEntries(maxEntries int) {
+	metricUsageTrackerMaxEntries = maxEntries
+}
+
+var metricUsageSaveInterval = time.Minute
+
+// MetricUsage contains usage stats for a single metric name.
+type MetricUsage struct {
+	// MetricName is the name of the metric.
+	MetricName string `json:"metricName"`
+
+	// IngestedRows is the number of rows ingested for MetricName since FirstSeenTimestamp.
+	IngestedRows uint64 `json:"ingestedRows"`
+
+	// QueryRequests is the number of searches, which touched MetricName since FirstSeenTimestamp.
+	QueryRequests uint64 `json:"queryRequests"`
+
+	// FirstSeenTimestamp is unix timestamp in seconds when MetricName has been started to be tracked.
+	FirstSeenTimestamp uint64 `json:"firstSeenTimestamp"`
+
+	// LastQueryTimestamp is unix timestamp in seconds for the last search, which touched MetricName.
+	//
+	// It is set to 0 if MetricName wasn't queried since FirstSeenTimestamp.
+	LastQueryTimestamp uint64 `json:"lastQueryTimestamp"`
+}
+
+// IngestionRate returns the average number of rows per second ingested for mu.MetricName at currentTime.
+func (mu *MetricUsage) IngestionRate(currentTime uint64) float64 {
+	d := uint64(1)
+	if currentTime > mu.FirstSeenTimestamp {
+		d = currentTime - mu.FirstSeenTimestamp
+	}
+	return float64(mu.IngestedRows) / float64(d)
+}
+
+// IsUnqueriedFor returns true if mu.MetricName is tracked for at least d, but wasn't queried during the last d at currentTime.
+func (mu *MetricUsage) IsUnqueriedFor(d time.Duration, currentTime uint64) bool {
+	secs := uint64(d.Seconds())
+	if currentTime < mu.FirstSeenTimestamp+secs {
+		return false
+	}
+	return mu.LastQueryTimestamp+secs <= currentTime
+}
+
+// SortMetricUsage sorts mus by ingestion rate at currentTime in descending order.
+//
+// If byQueryRequests is set, then mus is sorted by QueryRequests in descending order.
+func SortMetricUsage(mus []MetricUsage, byQueryRequests bool, currentTime uint64) {
+	sort.Slice(mus, func(i, j int) bool {
+		a, b := &mus[i], &mus[j]
+		if byQueryRequests && a.QueryRequests != b.QueryRequests {
+			return a.QueryRequests > b.QueryRequests
+		}
+		aRate, bRate := a.IngestionRate(currentTime), b.IngestionRate(currentTime)
+		if aRate != bRate {
+			return aRate > bRate
+		}
+		return a.MetricName < b.MetricName
+	})
+}
+
+// metricUsageTracker tracks usage stats for up to maxEntries metric names with the biggest number of ingested rows.
+//
+// When the number of tracked metric names reaches maxEntries, then 10% of entries with the smallest number
+// of ingested rows are evicted. This bounds memory usage at the cost of losing stats for rarely ingested metrics.
+//
+// The entries are sharded by metric name and their stats are updated via atomic operations,
+// so concurrent ingestion and search goroutines do not contend on a global lock.
+type metricUsageTracker struct {
+	// Put entriesCount to the top in order to avoid unaligned memory access on 32-bit architectures
+	entriesCount uint64
+
+	// evicting is set to 1 while evictEntries is in progress.
+	evicting uint32
+
+	maxEntries int
+
+	shards []metricUsageShard
+}
+
+type metricUsageShardNopad struct {
+	mu sync.RWMutex
+	m  map[string]*metricUsageEntry
+}
+
+type metricUsageShard struct {
+	metricUsageShardNopad
+
+	// The padding prevents false sharing on widespread platforms with
+	// 128 mod (cache line size) = 0 .
+	_ [128 - unsafe.Sizeof(metricUsageShardNopad{})%128]byte
+}
+
+// metricUsageEntry contains usage stats for a single metric name, which are updated via atomic operations.
+type metricUsageEntry struct {
+	ingestedRows       uint64
+	queryRequests      uint64
+	lastQueryTimestamp uint64
+
+	firstSeenTimestamp uint64
+	metricName         string
+}
+
+func (e *metricUsageEntry) toMetricUsage() MetricUsage {
+	return MetricUsage{
+		MetricName:         e.metricName,
+		IngestedRows:       atomic.LoadUint64(&e.ingestedRows),
+		QueryRequests:      atomic.LoadUint64(&e.queryRequests),
+		FirstSeenTimestamp: e.firstSeenTimestamp,
+		LastQueryTimestamp: atomic.LoadUint64(&e.lastQueryTimestamp),
+	}
+}
+
+func newMetricUsageTracker(maxEntries int) *metricUsageTracker {
+	shards := make([]metricUsageShard, cgroup.AvailableCPUs())
+	for i := range shards {
+		shards[i].m = make(map[string]*metricUsageEntry)
+	}
+	return &metricUsageTracker{
+		maxEntries: maxEntries,
+		shards:     shards,
+	}
+}
+
+func (mut *metricUsageTracker) getShard(metricGroup []byte) *metricUsageShard {
+	h := xxhash.Sum64(metricGroup)
+	return &mut.shards[h%uint64(len(mut.shards))]
+}
+
+// registerIngestedRows registers ingestion of mrs.
+//
+// It is safe calling this function on nil mut.
+func (mut *metricUsageTracker) registerIngestedRows(mrs []*MetricRow) {
+	if mut == nil || len(mrs) == 0 {
+		return
+	}
+	currentTime := fasttime.UnixTimestamp()
+	var prevMetricGroup []byte
+	rows := uint64(0)
+	flush := func() {
+		if rows > 0 {
+			e := mut.getOrCreateEntry(prevMetricGroup, currentTime)
+			atomic.AddUint64(&e.ingestedRows, rows)
+			rows = 0
+		}
+	}
+	for _, mr := range mrs {
+		metricGroup := getMetricGroupFromRaw(mr.MetricNameRaw)
+		if len(metricGroup) == 0 {
+			continue
+		}
+		if rows > 0 && string(metricGroup) != string(prevMetricGroup) {
+			flush()
+		}
+		prevMetricGroup = metricGroup
+		rows++
+	}
+	flush()
+}
+
+// registerQuery registers a search, which touched the given metricGroup.
+//
+// It is safe calling this function on nil mut.
+func (mut *metricUsageTracker) registerQuery(metricGroup []byte) {
+	if mut == nil {
+		return
+	}
+	currentTime := fasttime.UnixTimestamp()
+	e := mut.getOrCreateEntry(metricGroup, currentTime)
+	atomic.AddUint64(&e.queryRequests, 1)
+	atomic.StoreUint64(&e.lastQueryTimestamp, currentTime)
+}
+
+func (mut *metricUsageTracker) getOrCreateEntry(metricGroup []byte, currentTime uint64) *metricUsageEntry {
+	shard := mut.getShard(metricGroup)
+	shard.mu.RLock()
+	e := shard.m[string(metricGroup)]
+	shard.mu.RUnlock()
+	if e != nil {
+		return e
+	}
+	return mut.addEntry(&metricUsageEntry{
+		metricName:         string(metricGroup),
+		firstSeenTimestamp: currentTime,
+	})
+}
+
+// addEntry adds e to mut and returns it.
+//
+// The already existing entry with the same metric name is returned instead of e if it exists.
+func (mut *metricUsageTracker) addEntry(e *metricUsageEntry) *metricUsageEntry {
+	if atomic.LoadUint64(&mut.entriesCount) >= uint64(mut.maxEntries) {
+		mut.evictEntries()
+	}
+	shard := mut.getShard([]byte(e.metricName))
+	shard.mu.Lock()
+	if eExisting := shard.m[e.metricName]; eExisting != nil {
+		shard.mu.Unlock()
+		return eExisting
+	}
+	shard.m[e.metricName] = e
+	shard.mu.Unlock()
+	atomic.AddUint64(&mut.entriesCount, 1)
+	return e
+}
+
+// evictEntries evicts 10% of entries with the smallest number of ingested rows.
+//
+// The entries are sorted without holding shard locks, so concurrent goroutines may continue updating the stats.
+// Only a single goroutine performs the eviction at a time, while the remaining goroutines continue adding entries.
+func (mut *metricUsageTracker) evictEntries() {
+	if !atomic.CompareAndSwapUint32(&mut.evicting, 0, 1) {
+		return
+	}
+	defer atomic.StoreUint32(&mut.evicting, 0)
+
+	type evictionCandidate struct {
+		e            *metricUsageEntry
+		ingestedRows uint64
+	}
+	var ecs []evictionCandidate
+	for i := range mut.shards {
+		shard := &mut.shards[i]
+		shard.mu.RLock()
+		for _, e := range shard.m {
+			ecs = append(ecs, evictionCandidate{
+				e:            e,
+				ingestedRows: atomic.LoadUint64(&e.ingestedRows),
+			})
+		}
+		shard.mu.RUnlock()
+	}
+	sort.Slice(ecs, func(i, j int) bool {
+		return ecs[i].ingestedRows < ecs[j].ingestedRows
+	})
+	n := len(ecs)/10 + 1
+	if n > len(ecs) {
+		n = len(ecs)
+	}
+	for _, ec := range ecs[:n] {
+		e := ec.e
+		shard := mut.getShard([]byte(e.metricName))
+		shard.mu.Lock()
+		if shard.m[e.metricName] == e {
+			delete(shard.m, e.metricName)
+			atomic.AddUint64(&mut.entriesCount, ^uint64(0))
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// getAll returns a copy of all the tracked entries.
+func (mut *metricUsageTracker) getAll() []MetricUsage {
+	mus := make([]MetricUsage, 0, atomic.LoadUint64(&mut.entriesCount))
+	for i := range mut.shards {
+		shard := &mut.shards[i]
+		shard.mu.RLock()
+		for _, e := range shard.m {
+			mus = append(mus, e.toMetricUsage())
+		}
+		shard.mu.RUnlock()
+	}
+	return mus
+}
+
+func (mut *metricUsageTracker) mustSave(path string) {
+	mus := mut.getAll()
+	sort.Slice(mus, func(i, j int) bool {
+		return mus[i].MetricName < mus[j].MetricName
+	})
+	data, err := json.Marshal(mus)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal metric usage stats: %s", err)
+	}
+	if err := fs.WriteFileAtomically(path, data, true); err != nil {
+		logger.Panicf("FATAL: cannot store metric usage stats to %q: %s", path, err)
+	}
+}
+
+func mustLoadMetricUsageTracker(path string, maxEntries int) *metricUsageTracker {
+	mut := newMetricUsageTracker(maxEntries)
+	if err := mut.load(path); err != nil {
+		logger.Errorf("cannot load metric usage stats, so starting with empty stats; error: %s", err)
+		mut = newMetricUsageTracker(maxEntries)
+	}
+	return mut
+}
+
+func (mut *metricUsageTracker) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var mus []MetricUsage
+	if err := json.Unmarshal(data, &mus); err != nil {
+		return fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+	for i := range mus {
+		mu := &mus[i]
+		mut.addEntry(&metricUsageEntry{
+			ingestedRows:       mu.IngestedRows,
+			queryRequests:      mu.QueryRequests,
+			lastQueryTimestamp: mu.LastQueryTimestamp,
+			firstSeenTimestamp: mu.FirstSeenTimestamp,
+			metricName:         mu.MetricName,
+		})
+	}
+	return nil
+}
+
+// getMetricGroupFromRaw returns metric group from metricNameRaw encoded with MarshalMetricNameRaw.
+//
+// Nil is returned if metricNameRaw doesn't contain metric group.
+func getMetricGroupFromRaw(metricNameRaw []byte) []byte {
+	src := metricNameRaw
+	for len(src) > 0 {
+		tail, key, err := unmarshalBytesFast(src)
+		if err != nil {
+			return nil
+		}
+		tail, value, err := unmarshalBytesFast(tail)
+		if err != nil {
+			return nil
+		}
+		if len(key) == 0 {
+			return value
+		}
+		src = tail
+	}
+	return nil
+}
+
+func (s *Storage) startMetricUsageSaver() {
+	s.metricUsageSaverWG.Add(1)
+	go func() {
+		s.metricUsageSaver()
+		s.metricUsageSaverWG.Done()
+	}()
+}
+
+func (s *Storage) metricUsageSaver() {
+	ticker := time.NewTicker(metricUsageSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.metricUsageTracker.mustSave(s.metricUsagePath())
+		}
+	}
+}
+
+func (s *Storage) metricUsagePath() string {
+	return filepath.Join(s.path, metadataDirname, metricUsageFilename)
+}
+
+// GetMetricUsage returns usage stats for the tracked metric names.
+//
+// False is returned if metric usage tracking is disabled via SetMetricUsageTrackerMaxEntries.
+func (s *Storage) GetMetricUsage() ([]MetricUsage, bool) {
+	if s.metricUsageTracker == nil {
+		return nil, false
+	}
+	return s.metricUsageTracker.getAll(), true
+}
@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+)
+
+func TestGetMetricGroupFromRaw(t *testing.T) {
+	f := func(mn *MetricName, resultExpected string) {
+		t.Helper()
+		metricNameRaw := mn.marshalRaw(nil)
+		result := getMetricGroupFromRaw(metricNameRaw)
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected metric group; got %q; want %q", result, resultExpected)
+		}
+	}
+	f(&MetricName{}, "")
+	f(&MetricName{
+		MetricGroup: []byte("foo"),
+	}, "foo")
+	f(&MetricName{
+		MetricGroup: []byte("foo"),
+		Tags: []Tag{
+			{Key: []byte("job"), Value: []byte("bar")},
+		},
+	}, "foo")
+
+	// Invalid metricNameRaw
+	if result := getMetricGroupFromRaw([]byte("x")); result != nil {
+		t.Fatalf("expecting nil result for invalid metricNameRaw; got %q", result)
+	}
+}
+
+func TestMetricUsageTrackerRegister(t *testing.T) {
+	mut := newMetricUsageTracker(100)
+	var mrs []*MetricRow
+	for i := 0; i < 10; i++ {
+		mn := &MetricName{
+			MetricGroup: []byte(fmt.Sprintf("metric_%d", i%3)),
+			Tags: []Tag{
+				{Key: []byte("instance"), Value: []byte(fmt.Sprintf("host_%d", i))},
+			},
+		}
+		mrs = append(mrs, &MetricRow{
+			MetricNameRaw: mn.marshalRaw(nil),
+		})
+	}
+	mut.registerIngestedRows(mrs)
+	mut.registerQuery([]byte("metric_1"))
+	mut.registerQuery([]byte("metric_1"))
+	mut.registerQuery([]byte("metric_3"))
+
+	ingestedRows := make(map[string]uint64)
+	queryRequests := make(map[string]uint64)
+	for _, mu := range mut.getAll() {
+		ingestedRows[mu.MetricName] = mu.IngestedRows
+		queryRequests[mu.MetricName] = mu.QueryRequests
+		if mu.QueryRequests > 0 && mu.LastQueryTimestamp == 0 {
+			t.Fatalf("expecting non-zero LastQueryTimestamp for %q", mu.MetricName)
+		}
+	}
+	ingestedRowsExpected := map[string]uint64{
+		"metric_0": 4,
+		"metric_1": 3,
+		"metric_2": 3,
+		"metric_3": 0,
+	}
+	if !reflect.DeepEqual(ingestedRows, ingestedRowsExpected) {
+		t.Fatalf("unexpected ingested rows; got %v; want %v", ingestedRows, ingestedRowsExpected)
+	}
+	queryRequestsExpected := map[string]uint64{
+		"metric_0": 0,
+		"metric_1": 2,
+		"metric_2": 0,
+		"metric_3": 1,
+	}
+	if !reflect.DeepEqual(queryRequests, queryRequestsExpected) {
+		t.Fatalf("unexpected query requests; got %v; want %v", queryRequests, queryRequestsExpected)
+	}
+
+	// Calls on nil tracker must be ignored
+	var mutNil *metricUsageTracker
+	mutNil.registerIngestedRows(mrs)
+	mutNil.registerQuery([]byte("foo"))
+}
+
+func TestMetricUsageTrackerEviction(t *testing.T) {
+	mut := newMetricUsageTracker(10)
+	for i := 0; i < 10; i++ {
+		e := mut.getOrCreateEntry([]byte(fmt.Sprintf("metric_%d", i)), 0)
+		e.ingestedRows = uint64(i + 1)
+	}
+	// Adding new entry must evict entries with the smallest number of ingested rows.
+	mut.getOrCreateEntry([]byte("foo"), 0)
+	m := make(map[string]bool)
+	for _, mu := range mut.getAll() {
+		m[mu.MetricName] = true
+	}
+	if len(m) != 9 {
+		t.Fatalf("unexpected number of entries; got %d; want 9", len(m))
+	}
+	if n := mut.entriesCount; n != 9 {
+		t.Fatalf("unexpected entriesCount; got %d; want 9", n)
+	}
+	if m["metric_0"] || m["metric_1"] {
+		t.Fatalf("entries with the smallest number of ingested rows must be evicted")
+	}
+	if !m["foo"] || !m["metric_2"] {
+		t.Fatalf("missing expected entries")
+	}
+}
+
+func TestMetricUsageTrackerConcurrent(t *testing.T) {
+	mut := newMetricUsageTracker(1000)
+	var mrs []*MetricRow
+	for i := 0; i < 100; i++ {
+		mn := &MetricName{
+			MetricGroup: []byte(fmt.Sprintf("metric_%d", i/10)),
+		}
+		mrs = append(mrs, &MetricRow{
+			MetricNameRaw: mn.marshalRaw(nil),
+		})
+	}
+	const workers = 5
+	ch := make(chan struct{}, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := 0; j < 10; j++ {
+				mut.registerIngestedRows(mrs)
+				mut.registerQuery([]byte("metric_0"))
+			}
+			ch <- struct{}{}
+		}()
+	}
+	for i := 0; i < workers; i++ {
+		<-ch
+	}
+	mus := mut.getAll()
+	if len(mus) != 10 {
+		t.Fatalf("unexpected number of entries; got %d; want 10", len(mus))
+	}
+	for _, mu := range mus {
+		if mu.IngestedRows != workers*10*10 {
+			t.Fatalf("unexpected ingested rows for %q; got %d; want %d", mu.MetricName, mu.IngestedRows, workers*10*10)
+		}
+		queryRequestsExpected := uint64(0)
+		if mu.MetricName == "metric_0" {
+			queryRequestsExpected = workers * 10
+		}
+		if mu.QueryRequests != queryRequestsExpected {
+			t.Fatalf("unexpected query requests for %q; got %d; want %d", mu.MetricName, mu.QueryRequests, queryRequestsExpected)
+		}
+	}
+}
+
+func TestMetricUsageTrackerSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), metricUsageFilename)
+
+	// Missing file must result in empty tracker
+	mut := mustLoadMetricUsageTracker(path, 100)
+	if n := len(mut.getAll()); n != 0 {
+		t.Fatalf("unexpected number of entries; got %d; want 0", n)
+	}
+
+	mut.getOrCreateEntry([]byte("foo"), 123).ingestedRows = 10
+	mut.registerQuery([]byte("bar"))
+	mut.mustSave(path)
+	musExpected := mut.getAll()
+	SortMetricUsage(musExpected, false, 1000)
+
+	mut = mustLoadMetricUsageTracker(path, 100)
+	mus := mut.getAll()
+	SortMetricUsage(mus, false, 1000)
+	if !reflect.DeepEqual(mus, musExpected) {
+		t.Fatalf("unexpected entries after load;\ngot\n%v\nwant\n%v", mus, musExpected)
+	}
+
+	// Invalid file contents must result in empty tracker
+	if err := os.WriteFile(path, []byte("invalid"), 0644); err != nil {
+		t.Fatalf("cannot write %q: %s", path, err)
+	}
+	mut = mustLoadMetricUsageTracker(path, 100)
+	if n := len(mut.getAll()); n != 0 {
+		t.Fatalf("unexpected number of entries; got %d; want 0", n)
+	}
+}
+
+func TestMetricUsageIsUnqueriedFor(t *testing.T) {
+	f := func(firstSeenTimestamp, lastQueryTimestamp uint64, resultExpected bool) {
+		t.Helper()
+		mu := &MetricUsage{
+			FirstSeenTimestamp: firstSeenTimestamp,
+			LastQueryTimestamp: lastQueryTimestamp,
+		}
+		result := mu.IsUnqueriedFor(time.Hour, 10000)
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %v; want %v", result, resultExpected)
+		}
+	}
+	// Never queried
+	f(1000, 0, true)
+
+	// Tracked for less than an hour
+	f(9000, 0, false)
+
+	// Queried during the last hour
+	f(1000, 9000, false)
+
+	// Queried more than an hour ago
+	f(1000, 5000, true)
+}
+
+func TestStorageMetricUsage(t *testing.T) {
+	path := "TestStorageMetricUsage"
+	SetMetricUsageTrackerMaxEntries(100)
+	defer SetMetricUsageTrackerMaxEntries(0)
+	st, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage %q: %s", path, err)
+	}
+	defer func() {
+		if err := os.RemoveAll(path); err != nil {
+			t.Fatalf("cannot remove storage %q: %s", path, err)
+		}
+	}()
+
+	timestamp := timestampFromTime(time.Now())
+	var mrs []MetricRow
+	for i := 0; i < 10; i++ {
+		mn := &MetricName{
+			MetricGroup: []byte(fmt.Sprintf("metric_%d", i%2)),
+			Tags: []Tag{
+				{Key: []byte("instance"), Value: []byte(fmt.Sprintf("host_%d", i))},
+			},
+		}
+		mrs = append(mrs, MetricRow{
+			MetricNameRaw: mn.marshalRaw(nil),
+			Timestamp:     timestamp,
+			Value:         float64(i),
+		})
+	}
+	if err := st.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	st.DebugFlush()
+
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("metric_1"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	tr := TimeRange{
+		MinTimestamp: timestamp - 1000,
+		MaxTimestamp: timestamp + 1000,
+	}
+	var s Search
+	s.Init(nil, st, []*TagFilters{tfs}, tr, 1e5, noDeadline)
+	blocks := 0
+	for s.NextMetricBlock() {
+		blocks++
+	}
+	if err := s.Error(); err != nil {
+		t.Fatalf("search error: %s", err)
+	}
+	s.MustClose()
+	if blocks != 5 {
+		t.Fatalf("unexpected number of found blocks; got %d; want 5", blocks)
+	}
+
+	// Re-open the storage in order to verify the stats are persisted.
+	st.MustClose()
+	st, err = OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot re-open storage %q: %s", path, err)
+	}
+	defer st.MustClose()
+
+	mus, ok := st.GetMetricUsage()
+	if !ok {
+		t.Fatalf("metric usage tracking must be enabled")
+	}
+	SortMetricUsage(mus, true, fasttime.UnixTimestamp())
+	if len(mus) != 2 {
+		t.Fatalf("unexpected number of entries; got %d; want 2", len(mus))
+	}
+	f := func(mu *MetricUsage, metricName string, ingestedRows, queryRequests uint64) {
+		t.Helper()
+		if mu.MetricName != metricName {
+			t.Fatalf("unexpected metric name; got %q; want %q", mu.MetricName, metricName)
+		}
+		if mu.IngestedRows != ingestedRows {
+			t.Fatalf("unexpected ingested rows for %q; got %d; want %d", metricName, mu.IngestedRows, ingestedRows)
+		}
+		if mu.QueryRequests != queryRequests {
+			t.Fatalf("unexpected query requests for %q; got %d; want %d", metricName, mu.QueryRequests, queryRequests)
+		}
+	}
+	f(&mus[0], "metric_1", 5, 1)
+	f(&mus[1], "metric_0", 5, 0)
+}
@@ -43,6 +43,11 @@ type part struct {
 	indexFile      fs.MustReadAtCloser
 
 	metaindex []metaindexRow
+
+	// metricIDsBloomFilter contains bloom filter for metricIDs in the part.
+	//
+	// It is nil for parts without bloom filter. See SetPartBloomFilter.
+	metricIDsBloomFilter *metricIDsBloomFilter
 }
 
 // openFilePart opens file-based part from the given path.
@@ -76,15 +81,25 @@ func openFilePart(path string) (*part, error) {
 	}
 	metaindexSize := fs.MustFileSize(metaindexPath)
 
+	bf, err := readMetricIDsBloomFilter(path)
+	if err != nil {
+		metaindexFile.MustClose()
+		timestampsFile.MustClose()
+		valuesFile.MustClose()
+		indexFile.MustClose()
+		return nil, err
+	}
+
 	size := timestampsSize + valuesSize + indexSize + metaindexSize
-	return newPart(&ph, path, size, metaindexFile, timestampsFile, valuesFile, indexFile)
+	return newPart(&ph, path, size, metaindexFile, timestampsFile, valuesFile, indexFile, bf)
 }
 
 // newPart returns new part initialized with the given arguments.
 //
 // The returned part calls MustClose on all the files passed to newPart
 // when calling part.MustClose.
-func newPart(ph *partHeader, path string, size uint64, metaindexReader filestream.ReadCloser, timestampsFile, valuesFile, indexFile fs.MustReadAtCloser) (*part, error) {
+func newPart(ph *partHeader, path string, size uint64, metaindexReader filestream.ReadCloser, timestampsFile, valuesFile, indexFile fs.MustReadAtCloser,
+	bf *metricIDsBloomFilter) (*part, error) {
 	var errors []error
 	metaindex, err := unmarshalMetaindexRows(nil, metaindexReader)
 	if err != nil {
@@ -100,6 +115,7 @@ func newPart(ph *partHeader, path string, size uint64, metaindexReader filestrea
 	p.valuesFile = valuesFile
 	p.indexFile = indexFile
 	p.metaindex = metaindex
+	p.metricIDsBloomFilter = bf
 
 	if len(errors) > 0 {
 		// Return only the first error, since it has no sense in returning all errors.
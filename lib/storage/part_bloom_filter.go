@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+// SetPartBloomFilter enables building and using per-part bloom filters for metricIDs
+// with the given falsePositiveRate.
+//
+// The filters allow skipping parts without the searched series during queries.
+// Parts created without the filters are searched as usual.
+//
+// The function must be called before opening or creating any storage.
+func SetPartBloomFilter(enabled bool, falsePositiveRate float64) {
+	partBloomFilterEnabled = enabled
+	partBloomFilterFalsePositiveRate = falsePositiveRate
+}
+
+var (
+	partBloomFilterEnabled           bool
+	partBloomFilterFalsePositiveRate = 0.01
+)
+
+// partsSkippedByBloomFilter is the total number of parts skipped during searches by their bloom filters.
+var partsSkippedByBloomFilter uint64
+
+const (
+	metricIDsBloomFilterVersion = 1
+
+	// maxMetricIDsBloomFilterHashes is the upper limit for the number of hashes per metricID.
+	//
+	// It limits CPU time spent on checking the filter for too low false positive rates.
+	maxMetricIDsBloomFilterHashes = 16
+)
+
+// metricIDsBloomFilter is a bloom filter for metricIDs stored in a part.
+//
+// The filter is immutable after the creation, so it can be used from concurrent goroutines.
+type metricIDsBloomFilter struct {
+	hashesCount int
+	bits        []uint64
+}
+
+// newMetricIDsBloomFilter returns an empty filter for up to itemsCount metricIDs with the given falsePositiveRate.
+//
+// metricIDs must be added to the returned filter via add.
+func newMetricIDsBloomFilter(itemsCount uint64, falsePositiveRate float64) *metricIDsBloomFilter {
+	n := float64(itemsCount)
+	if n < 1 {
+		n = 1
+	}
+	bitsCount := getMetricIDsBloomFilterBitsCount(itemsCount, falsePositiveRate)
+	hashesCount := int(math.Round(float64(bitsCount) / n * math.Ln2))
+	if hashesCount < 1 {
+		hashesCount = 1
+	}
+	if hashesCount > maxMetricIDsBloomFilterHashes {
+		hashesCount = maxMetricIDsBloomFilterHashes
+	}
+
+	// Round up the number of words to a multiple of a power of two,
+	// so the filter can be shrunk by halving its size in shrink.
+	// This increases the filter size by less than 1/16.
+	wordsCount := (bitsCount + 63) / 64
+	if shift := bits.Len64(wordsCount) - 5; shift > 0 {
+		wordsCount = ((wordsCount + (1 << shift) - 1) >> shift) << shift
+	}
+	return &metricIDsBloomFilter{
+		hashesCount: hashesCount,
+		bits:        make([]uint64, wordsCount),
+	}
+}
+
+// getMetricIDsBloomFilterBitsCount returns the number of bits needed for storing itemsCount metricIDs with the given falsePositiveRate.
+func getMetricIDsBloomFilterBitsCount(itemsCount uint64, falsePositiveRate float64) uint64 {
+	n := float64(itemsCount)
+	if n < 1 {
+		n = 1
+	}
+	// See https://en.wikipedia.org/wiki/Bloom_filter#Optimal_number_of_hash_functions
+	bitsCount := -n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)
+	if bitsCount < 64 {
+		bitsCount = 64
+	}
+	return uint64(math.Ceil(bitsCount))
+}
+
+// shrink reduces the size of bf if it is bigger than needed for itemsCount metricIDs added to bf
+// with the given falsePositiveRate.
+//
+// This is needed for filters sized from an upper bound for the number of metricIDs such as during merges,
+// since the merged parts may contain the same metricIDs.
+// The size is halved by merging the upper half of bits into the lower half. This preserves the bit indexes
+// for all the added metricIDs, since they are calculated modulo the number of bits and the new number of bits
+// divides the previous one.
+// The number of hashes is left unchanged, since its optimal value depends only on falsePositiveRate.
+func (bf *metricIDsBloomFilter) shrink(itemsCount uint64, falsePositiveRate float64) {
+	wordsCount := (getMetricIDsBloomFilterBitsCount(itemsCount, falsePositiveRate) + 63) / 64
+	a := bf.bits
+	for len(a)%2 == 0 && uint64(len(a)/2) >= wordsCount {
+		half := len(a) / 2
+		for i, w := range a[half:] {
+			a[i] |= w
+		}
+		a = a[:half]
+	}
+	if len(a) < len(bf.bits) {
+		// Do not hold the original bits in memory.
+		bf.bits = append([]uint64{}, a...)
+	}
+}
+
+// getItemsCountUpperBound returns an estimated upper bound for the number of metricIDs bf has been created for.
+//
+// The estimation is based on the number of bits and hashes in bf, since the number of hashes
+// is rounded to the nearest integer at newMetricIDsBloomFilter.
+// 0 is returned if the estimation is impossible.
+func (bf *metricIDsBloomFilter) getItemsCountUpperBound() uint64 {
+	if bf.hashesCount <= 1 || bf.hashesCount >= maxMetricIDsBloomFilterHashes {
+		return 0
+	}
+	bitsCount := float64(len(bf.bits)) * 64
+	return uint64(math.Ceil(bitsCount * math.Ln2 / (float64(bf.hashesCount) - 0.5)))
+}
+
+// getMetricIDsCountUpperBound returns an upper bound for the number of unique metricIDs in p.
+//
+// It is used for sizing bloom filter for the part created from p during merge without holding all the metricIDs in memory.
+func getMetricIDsCountUpperBound(p *part) uint64 {
+	// Every block contains samples for a single metricID.
+	n := p.ph.BlocksCount
+	if bf := p.metricIDsBloomFilter; bf != nil {
+		if m := bf.getItemsCountUpperBound(); m > 0 && m < n {
+			n = m
+		}
+	}
+	return n
+}
+
+func (bf *metricIDsBloomFilter) add(metricID uint64) {
+	bits := bf.bits
+	maxBits := uint64(len(bits)) * 64
+	h1, h2 := getMetricIDHashes(metricID)
+	for i := 0; i < bf.hashesCount; i++ {
+		idx := (h1 + uint64(i)*h2) % maxBits
+		bits[idx/64] |= 1 << (idx % 64)
+	}
+}
+
+// has returns false if metricID is definitely missing in bf.
+func (bf *metricIDsBloomFilter) has(metricID uint64) bool {
+	bits := bf.bits
+	maxBits := uint64(len(bits)) * 64
+	h1, h2 := getMetricIDHashes(metricID)
+	for i := 0; i < bf.hashesCount; i++ {
+		idx := (h1 + uint64(i)*h2) % maxBits
+		if bits[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hasAnyTSID returns false if all the tsids are definitely missing in bf.
+func (bf *metricIDsBloomFilter) hasAnyTSID(tsids []TSID) bool {
+	for i := range tsids {
+		if bf.has(tsids[i].MetricID) {
+			return true
+		}
+	}
+	return false
+}
+
+// getMetricIDHashes returns hashes for metricID, which are used for obtaining bit indexes
+// according to https://www.eecs.harvard.edu/~michaelm/postscripts/rsa2008.pdf
+func getMetricIDHashes(metricID uint64) (uint64, uint64) {
+	h1 := fastHashUint64(metricID)
+	h2 := fastHashUint64(h1) | 1
+	return h1, h2
+}
+
+// sizeBytes returns the size of bf in bytes.
+func (bf *metricIDsBloomFilter) sizeBytes() uint64 {
+	return uint64(len(bf.bits)) * 8
+}
+
+// bloomFilterSizeBytes returns the size of the bloom filter for p in bytes.
+//
+// 0 is returned if p has no bloom filter.
+func (p *part) bloomFilterSizeBytes() uint64 {
+	if p.metricIDsBloomFilter == nil {
+		return 0
+	}
+	return p.metricIDsBloomFilter.sizeBytes()
+}
+
+func (bf *metricIDsBloomFilter) marshal(dst []byte) []byte {
+	dst = append(dst, metricIDsBloomFilterVersion, byte(bf.hashesCount))
+	for _, w := range bf.bits {
+		dst = encoding.MarshalUint64(dst, w)
+	}
+	return dst
+}
+
+func (bf *metricIDsBloomFilter) unmarshal(src []byte) error {
+	if len(src) < 2 {
+		return fmt.Errorf("too short data for bloom filter; got %d bytes; want at least 2 bytes", len(src))
+	}
+	if src[0] != metricIDsBloomFilterVersion {
+		return fmt.Errorf("unsupported bloom filter version; got %d; want %d", src[0], metricIDsBloomFilterVersion)
+	}
+	hashesCount := int(src[1])
+	if hashesCount < 1 || hashesCount > maxMetricIDsBloomFilterHashes {
+		return fmt.Errorf("unexpected number of bloom filter hashes; got %d; want [1...%d]", hashesCount, maxMetricIDsBloomFilterHashes)
+	}
+	src = src[2:]
+	if len(src) == 0 || len(src)%8 != 0 {
+		return fmt.Errorf("unexpected bloom filter bits size; got %d bytes; want non-zero multiple of 8 bytes", len(src))
+	}
+	bits := make([]uint64, len(src)/8)
+	for i := range bits {
+		bits[i] = encoding.UnmarshalUint64(src)
+		src = src[8:]
+	}
+	bf.hashesCount = hashesCount
+	bf.bits = bits
+	return nil
+}
+
+// writeMetricIDsBloomFilter writes bf to the part at partPath.
+func writeMetricIDsBloomFilter(partPath string, bf *metricIDsBloomFilter) error {
+	path := filepath.Join(partPath, metricIDsBloomFilterFilename)
+	if err := fs.WriteFileAndSync(path, bf.marshal(nil)); err != nil {
+		return fmt.Errorf("cannot store bloom filter: %w", err)
+	}
+	return nil
+}
+
+// readMetricIDsBloomFilter reads bloom filter for the part at partPath.
+//
+// nil filter is returned if the part has no bloom filter or if bloom filters are disabled.
+func readMetricIDsBloomFilter(partPath string) (*metricIDsBloomFilter, error) {
+	if !partBloomFilterEnabled {
+		return nil, nil
+	}
+	path := filepath.Join(partPath, metricIDsBloomFilterFilename)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// The part has been created before enabling bloom filters.
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read %q: %w", path, err)
+	}
+	var bf metricIDsBloomFilter
+	if err := bf.unmarshal(data); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal bloom filter from %q: %w", path, err)
+	}
+	return &bf, nil
+}
+
+func addPartsSkippedByBloomFilter(n uint64) {
+	if n > 0 {
+		atomic.AddUint64(&partsSkippedByBloomFilter, n)
+	}
+}
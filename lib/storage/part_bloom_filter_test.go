@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMetricIDsBloomFilter(t *testing.T) {
+	f := func(itemsCount int, falsePositiveRate float64) {
+		t.Helper()
+		rng := rand.New(rand.NewSource(1))
+		metricIDs := make([]uint64, itemsCount)
+		m := make(map[uint64]bool, itemsCount)
+		for i := range metricIDs {
+			metricIDs[i] = rng.Uint64()
+			m[metricIDs[i]] = true
+		}
+		bf := newMetricIDsBloomFilter(uint64(itemsCount), falsePositiveRate)
+		for _, metricID := range metricIDs {
+			bf.add(metricID)
+		}
+		if n := bf.getItemsCountUpperBound(); n > 0 && n < uint64(itemsCount) {
+			t.Fatalf("too small upper bound for the number of items; got %d; want at least %d", n, itemsCount)
+		}
+		for _, metricID := range metricIDs {
+			if !bf.has(metricID) {
+				t.Fatalf("missing metricID=%d in the bloom filter", metricID)
+			}
+		}
+
+		// Verify the false positive rate.
+		const checksCount = 100000
+		falsePositives := 0
+		for i := 0; i < checksCount; i++ {
+			metricID := rng.Uint64()
+			if !m[metricID] && bf.has(metricID) {
+				falsePositives++
+			}
+		}
+		if maxFalsePositives := int(3*falsePositiveRate*checksCount) + 10; falsePositives > maxFalsePositives {
+			t.Fatalf("too many false positives for %d items and falsePositiveRate=%v; got %d; want no more than %d",
+				itemsCount, falsePositiveRate, falsePositives, maxFalsePositives)
+		}
+
+		// Verify marshaling.
+		data := bf.marshal(nil)
+		var bf2 metricIDsBloomFilter
+		if err := bf2.unmarshal(data); err != nil {
+			t.Fatalf("cannot unmarshal bloom filter: %s", err)
+		}
+		if bf2.hashesCount != bf.hashesCount {
+			t.Fatalf("unexpected hashesCount after unmarshal; got %d; want %d", bf2.hashesCount, bf.hashesCount)
+		}
+		for _, metricID := range metricIDs {
+			if !bf2.has(metricID) {
+				t.Fatalf("missing metricID=%d in the unmarshaled bloom filter", metricID)
+			}
+		}
+	}
+	f(0, 0.01)
+	f(1, 0.01)
+	f(10, 0.1)
+	f(1000, 0.01)
+	f(100000, 0.01)
+	f(100000, 0.001)
+	f(10000, 1e-9)
+}
+
+func TestMetricIDsBloomFilterShrink(t *testing.T) {
+	f := func(itemsCountEstimated, itemsCount int, falsePositiveRate float64) {
+		t.Helper()
+		rng := rand.New(rand.NewSource(1))
+		metricIDs := make([]uint64, itemsCount)
+		m := make(map[uint64]bool, itemsCount)
+		for i := range metricIDs {
+			metricIDs[i] = rng.Uint64()
+			m[metricIDs[i]] = true
+		}
+		bf := newMetricIDsBloomFilter(uint64(itemsCountEstimated), falsePositiveRate)
+		for _, metricID := range metricIDs {
+			bf.add(metricID)
+		}
+		bf.shrink(uint64(itemsCount), falsePositiveRate)
+
+		// The shrunk filter mustn't exceed the filter created for the actual number of items by more than 2x.
+		// Small filters cannot be shrunk below 32 words.
+		bfExpected := newMetricIDsBloomFilter(uint64(itemsCount), falsePositiveRate)
+		maxSize := 2 * bfExpected.sizeBytes()
+		if maxSize < 32*8 {
+			maxSize = 32 * 8
+		}
+		if size := bf.sizeBytes(); size > maxSize {
+			t.Fatalf("too big bloom filter after shrinking from %d to %d items; got %d bytes; want no more than %d bytes",
+				itemsCountEstimated, itemsCount, size, maxSize)
+		}
+		for _, metricID := range metricIDs {
+			if !bf.has(metricID) {
+				t.Fatalf("missing metricID=%d in the shrunk bloom filter", metricID)
+			}
+		}
+
+		// Verify the false positive rate.
+		const checksCount = 100000
+		falsePositives := 0
+		for i := 0; i < checksCount; i++ {
+			metricID := rng.Uint64()
+			if !m[metricID] && bf.has(metricID) {
+				falsePositives++
+			}
+		}
+		if maxFalsePositives := int(3*falsePositiveRate*checksCount) + 10; falsePositives > maxFalsePositives {
+			t.Fatalf("too many false positives for the shrunk bloom filter with %d items and falsePositiveRate=%v; got %d; want no more than %d",
+				itemsCount, falsePositiveRate, falsePositives, maxFalsePositives)
+		}
+	}
+	f(1, 1, 0.01)
+	f(1000, 1, 0.01)
+	f(1000, 1000, 0.01)
+	f(100000, 1000, 0.01)
+	f(1000000, 10000, 0.01)
+	f(1000000, 300000, 0.001)
+}
+
+func TestMetricIDsBloomFilterUnmarshalFailure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+		var bf metricIDsBloomFilter
+		if err := bf.unmarshal(data); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f(nil)
+	f([]byte{metricIDsBloomFilterVersion})
+	f([]byte{metricIDsBloomFilterVersion, 4})
+	f([]byte{metricIDsBloomFilterVersion, 4, 1, 2, 3})
+	f([]byte{metricIDsBloomFilterVersion, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	f([]byte{metricIDsBloomFilterVersion, maxMetricIDsBloomFilterHashes + 1, 0, 0, 0, 0, 0, 0, 0, 0})
+	f([]byte{metricIDsBloomFilterVersion + 1, 4, 0, 0, 0, 0, 0, 0, 0, 0})
+}
+
+func TestPartSearchBloomFilter(t *testing.T) {
+	origEnabled := partBloomFilterEnabled
+	defer func() {
+		partBloomFilterEnabled = origEnabled
+	}()
+
+	var rows []rawRow
+	var r rawRow
+	r.PrecisionBits = 24
+	for i := 0; i < 1000; i++ {
+		r.TSID.MetricID = uint64(i % 100)
+		r.Timestamp = int64(i)
+		r.Value = float64(i)
+		rows = append(rows, r)
+	}
+	tr := TimeRange{
+		MinTimestamp: 0,
+		MaxTimestamp: 1000,
+	}
+	tsidsMissing := []TSID{{MetricID: 1000}, {MetricID: 1001}}
+	tsidsExisting := []TSID{{MetricID: 10}, {MetricID: 1000}}
+
+	f := func(p *part, tsids []TSID, skippedExpected bool) {
+		t.Helper()
+		var ps partSearch
+		ps.Init(p, tsids, tr)
+		if ps.skippedByBloomFilter != skippedExpected {
+			t.Fatalf("unexpected skippedByBloomFilter; got %v; want %v", ps.skippedByBloomFilter, skippedExpected)
+		}
+		testPartSearch(t, p, tsids, tr, getTestExpectedRawBlocks(rows, tsids, tr))
+	}
+
+	// Parts without bloom filters must be searched as usual.
+	partBloomFilterEnabled = false
+	p := newTestPart(rows)
+	if p.metricIDsBloomFilter != nil {
+		t.Fatalf("the bloom filter mustn't be built if it is disabled")
+	}
+	f(p, tsidsMissing, false)
+	f(p, tsidsExisting, false)
+
+	partBloomFilterEnabled = true
+	mp := newTestInmemoryPart(rows)
+	p, err := mp.NewPart()
+	if err != nil {
+		t.Fatalf("cannot create part: %s", err)
+	}
+	if p.metricIDsBloomFilter == nil {
+		t.Fatalf("the bloom filter must be built if it is enabled")
+	}
+	f(p, tsidsMissing, true)
+	f(p, tsidsExisting, false)
+
+	// The bloom filter must be persisted together with the part.
+	path := filepath.Join(t.TempDir(), "part")
+	if err := mp.StoreToDisk(path); err != nil {
+		t.Fatalf("cannot store part to disk: %s", err)
+	}
+	pFile, err := openFilePart(path)
+	if err != nil {
+		t.Fatalf("cannot open part: %s", err)
+	}
+	if pFile.metricIDsBloomFilter == nil {
+		t.Fatalf("the bloom filter must be read from disk")
+	}
+	f(pFile, tsidsMissing, true)
+	f(pFile, tsidsExisting, false)
+	pFile.MustClose()
+
+	// Parts without bloom filter file on disk must be opened and searched as usual.
+	if err := os.Remove(filepath.Join(path, metricIDsBloomFilterFilename)); err != nil {
+		t.Fatalf("cannot remove bloom filter file: %s", err)
+	}
+	pFile, err = openFilePart(path)
+	if err != nil {
+		t.Fatalf("cannot open part without bloom filter: %s", err)
+	}
+	if pFile.metricIDsBloomFilter != nil {
+		t.Fatalf("unexpected bloom filter for the part without bloom filter file")
+	}
+	f(pFile, tsidsMissing, false)
+	f(pFile, tsidsExisting, false)
+	pFile.MustClose()
+}
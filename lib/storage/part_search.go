@@ -38,6 +38,9 @@ type partSearch struct {
 	compressedIndexBuf []byte
 	indexBuf           []byte
 
+	// skippedByBloomFilter is set to true if the part is skipped at Init, since its bloom filter doesn't contain the searched metricIDs.
+	skippedByBloomFilter bool
+
 	err error
 }
 
@@ -50,6 +53,7 @@ func (ps *partSearch) reset() {
 	ps.bhs = nil
 	ps.compressedIndexBuf = ps.compressedIndexBuf[:0]
 	ps.indexBuf = ps.indexBuf[:0]
+	ps.skippedByBloomFilter = false
 	ps.err = nil
 }
 
@@ -69,8 +73,13 @@ func (ps *partSearch) Init(p *part, tsids []TSID, tr TimeRange) {
 		if isInTest && !sort.SliceIsSorted(tsids, func(i, j int) bool { return tsids[i].Less(&tsids[j]) }) {
 			logger.Panicf("BUG: tsids must be sorted; got %+v", tsids)
 		}
-		// take ownership of tsids.
-		ps.tsids = tsids
+		if bf := p.metricIDsBloomFilter; bf != nil && !bf.hasAnyTSID(tsids) {
+			// Fast path - the part definitely doesn't contain the given tsids.
+			ps.skippedByBloomFilter = true
+		} else {
+			// take ownership of tsids.
+			ps.tsids = tsids
+		}
 	}
 	ps.tr = tr
 	ps.metaindex = p.metaindex
@@ -353,10 +353,17 @@ type partitionMetrics struct {
 	SmallPartsRefCount    uint64
 	BigPartsRefCount      uint64
 
+	// BloomFiltersSizeBytes is the size of per-part bloom filters held in memory. See SetPartBloomFilter.
+	BloomFiltersSizeBytes uint64
+
 	InmemoryAssistedMerges uint64
 	SmallAssistedMerges    uint64
 
 	MergeNeedFreeDiskSpace uint64
+
+	// MaxPossibleDataLossSeconds is the age in seconds of the oldest ingested data, which isn't flushed to disk yet.
+	// This data may be lost on unclean shutdown. See -inmemoryDataFlushInterval command-line flag.
+	MaxPossibleDataLossSeconds uint64
 }
 
 // TotalRowsCount returns total number of rows in tm.
@@ -368,6 +375,9 @@ func (pm *partitionMetrics) TotalRowsCount() uint64 {
 func (pt *partition) UpdateMetrics(m *partitionMetrics) {
 	m.PendingRows += uint64(pt.rawRows.Len())
 
+	currentTime := fasttime.UnixTimestamp()
+	maxDataLossSeconds := pt.rawRows.getMaxPossibleDataLossSeconds(currentTime)
+
 	pt.partsLock.Lock()
 
 	for _, pw := range pt.inmemoryParts {
@@ -376,6 +386,10 @@ func (pt *partition) UpdateMetrics(m *partitionMetrics) {
 		m.InmemoryBlocksCount += p.ph.BlocksCount
 		m.InmemorySizeBytes += p.size
 		m.InmemoryPartsRefCount += uint64(atomic.LoadUint32(&pw.refCount))
+		m.BloomFiltersSizeBytes += p.bloomFilterSizeBytes()
+		if n := pw.getMaxPossibleDataLossSeconds(currentTime); n > maxDataLossSeconds {
+			maxDataLossSeconds = n
+		}
 	}
 	for _, pw := range pt.smallParts {
 		p := pw.p
@@ -383,6 +397,7 @@ func (pt *partition) UpdateMetrics(m *partitionMetrics) {
 		m.SmallBlocksCount += p.ph.BlocksCount
 		m.SmallSizeBytes += p.size
 		m.SmallPartsRefCount += uint64(atomic.LoadUint32(&pw.refCount))
+		m.BloomFiltersSizeBytes += p.bloomFilterSizeBytes()
 	}
 	for _, pw := range pt.bigParts {
 		p := pw.p
@@ -390,6 +405,11 @@ func (pt *partition) UpdateMetrics(m *partitionMetrics) {
 		m.BigBlocksCount += p.ph.BlocksCount
 		m.BigSizeBytes += p.size
 		m.BigPartsRefCount += uint64(atomic.LoadUint32(&pw.refCount))
+		m.BloomFiltersSizeBytes += p.bloomFilterSizeBytes()
+	}
+
+	if maxDataLossSeconds > m.MaxPossibleDataLossSeconds {
+		m.MaxPossibleDataLossSeconds = maxDataLossSeconds
 	}
 
 	m.InmemoryPartsCount += uint64(len(pt.inmemoryParts))
@@ -470,6 +490,18 @@ func (rrss *rawRowsShards) addRows(pt *partition, rows []rawRow) {
 	}
 }
 
+// getMaxPossibleDataLossSeconds returns the age in seconds of the oldest rows in rrss at currentTime.
+func (rrss *rawRowsShards) getMaxPossibleDataLossSeconds(currentTime uint64) uint64 {
+	n := uint64(0)
+	for i := range rrss.shards[:] {
+		oldestRowAddTime := atomic.LoadUint64(&rrss.shards[i].oldestRowAddTime)
+		if oldestRowAddTime > 0 && oldestRowAddTime < currentTime && currentTime-oldestRowAddTime > n {
+			n = currentTime - oldestRowAddTime
+		}
+	}
+	return n
+}
+
 func (rrss *rawRowsShards) Len() int {
 	n := 0
 	for i := range rrss.shards[:] {
@@ -479,9 +511,12 @@ func (rrss *rawRowsShards) Len() int {
 }
 
 type rawRowsShardNopad struct {
-	// Put lastFlushTime to the top in order to avoid unaligned memory access on 32-bit architectures
+	// Put lastFlushTime and oldestRowAddTime to the top in order to avoid unaligned memory access on 32-bit architectures
 	lastFlushTime uint64
 
+	// oldestRowAddTime is the unix timestamp when the oldest row in rows was added. It is 0 if rows is empty.
+	oldestRowAddTime uint64
+
 	mu   sync.Mutex
 	rows []rawRow
 }
@@ -508,6 +543,9 @@ func (rrs *rawRowsShard) addRows(pt *partition, rows []rawRow) []rawRow {
 	if cap(rrs.rows) == 0 {
 		rrs.rows = newRawRows()
 	}
+	if len(rrs.rows) == 0 && len(rows) > 0 {
+		atomic.StoreUint64(&rrs.oldestRowAddTime, fasttime.UnixTimestamp())
+	}
 	n := copy(rrs.rows[len(rrs.rows):cap(rrs.rows)], rows)
 	rrs.rows = rrs.rows[:len(rrs.rows)+n]
 	rows = rows[n:]
@@ -517,7 +555,9 @@ func (rrs *rawRowsShard) addRows(pt *partition, rows []rawRow) []rawRow {
 		n = copy(rrs.rows[:cap(rrs.rows)], rows)
 		rrs.rows = rrs.rows[:n]
 		rows = rows[n:]
-		atomic.StoreUint64(&rrs.lastFlushTime, fasttime.UnixTimestamp())
+		currentTime := fasttime.UnixTimestamp()
+		atomic.StoreUint64(&rrs.lastFlushTime, currentTime)
+		atomic.StoreUint64(&rrs.oldestRowAddTime, currentTime)
 	}
 	rrs.mu.Unlock()
 
@@ -740,6 +780,17 @@ func newPartWrapperFromInmemoryPart(mp *inmemoryPart, flushToDiskDeadline time.T
 	return pw
 }
 
+// getMaxPossibleDataLossSeconds returns the age in seconds of the oldest data in the in-memory part pw at currentTime.
+func (pw *partWrapper) getMaxPossibleDataLossSeconds(currentTime uint64) uint64 {
+	// The in-memory part is created dataFlushInterval before its flushToDiskDeadline.
+	// Merged in-memory parts inherit the earliest flushToDiskDeadline from the source parts.
+	createdAt := pw.flushToDiskDeadline.Add(-dataFlushInterval).Unix()
+	if createdAt < 0 || uint64(createdAt) >= currentTime {
+		return 0
+	}
+	return currentTime - uint64(createdAt)
+}
+
 // HasTimestamp returns true if the pt contains the given timestamp.
 func (pt *partition) HasTimestamp(timestamp int64) bool {
 	return timestamp >= pt.tr.MinTimestamp && timestamp <= pt.tr.MaxTimestamp
@@ -879,7 +930,7 @@ func (pt *partition) flushInmemoryParts(isFinal bool) {
 	}
 	pt.partsLock.Unlock()
 
-	if err := pt.mergePartsOptimal(pws, nil); err != nil {
+	if err := pt.mergePartsOptimal(pws, nil, nil); err != nil {
 		logger.Panicf("FATAL: cannot merge in-memory parts: %s", err)
 	}
 }
@@ -908,11 +959,12 @@ func (rrs *rawRowsShard) appendRawRowsToFlush(dst []rawRow, pt *partition, isFin
 	dst = append(dst, rrs.rows...)
 	rrs.rows = rrs.rows[:0]
 	atomic.StoreUint64(&rrs.lastFlushTime, currentTime)
+	atomic.StoreUint64(&rrs.oldestRowAddTime, 0)
 	rrs.mu.Unlock()
 	return dst
 }
 
-func (pt *partition) mergePartsOptimal(pws []*partWrapper, stopCh <-chan struct{}) error {
+func (pt *partition) mergePartsOptimal(pws []*partWrapper, stopCh <-chan struct{}, fmp *ForceMergeProgress) error {
 	sortPartsForOptimalMerge(pws)
 	for len(pws) > 0 {
 		n := defaultPartsToMerge
@@ -921,7 +973,7 @@ func (pt *partition) mergePartsOptimal(pws []*partWrapper, stopCh <-chan struct{
 		}
 		pwsChunk := pws[:n]
 		pws = pws[n:]
-		err := pt.mergeParts(pwsChunk, stopCh, true)
+		err := pt.mergeParts(pwsChunk, stopCh, true, fmp)
 		if err == nil {
 			continue
 		}
@@ -935,13 +987,17 @@ func (pt *partition) mergePartsOptimal(pws []*partWrapper, stopCh <-chan struct{
 }
 
 // ForceMergeAllParts runs merge for all the parts in pt.
-func (pt *partition) ForceMergeAllParts() error {
+//
+// The merge progress is tracked in fmp if it isn't nil.
+func (pt *partition) ForceMergeAllParts(fmp *ForceMergeProgress) error {
 	pws := pt.getAllPartsForMerge()
 	if len(pws) == 0 {
 		// Nothing to merge.
 		return nil
 	}
+	defer fmp.resetCreatedParts()
 	for {
+		fmp.addPartsToMerge(pws)
 		// Check whether there is enough disk space for merging pws.
 		newPartSize := getPartsSize(pws)
 		maxOutBytes := fs.MustGetFreeSpace(pt.bigPartsPath)
@@ -955,7 +1011,7 @@ func (pt *partition) ForceMergeAllParts() error {
 		// If len(pws) == 1, then the merge must run anyway.
 		// This allows applying the configured retention, removing the deleted series
 		// and performing de-duplication if needed.
-		if err := pt.mergePartsOptimal(pws, pt.stopCh); err != nil {
+		if err := pt.mergePartsOptimal(pws, pt.stopCh, fmp); err != nil {
 			return fmt.Errorf("cannot force merge %d parts from partition %q: %w", len(pws), pt.name, err)
 		}
 		pws = pt.getAllPartsForMerge()
@@ -968,6 +1024,93 @@ func (pt *partition) ForceMergeAllParts() error {
 
 var forceMergeLogger = logger.WithThrottler("forceMerge", time.Minute)
 
+// ForceMergeProgress tracks the progress of forced merge.
+//
+// All the exported fields must be accessed via atomic functions.
+type ForceMergeProgress struct {
+	// PartsTotal is the number of parts selected for the merge so far.
+	//
+	// Parts created by the forced merge itself aren't counted, even if they are merged again.
+	PartsTotal uint64
+
+	// PartsMerged is the number of source parts merged so far.
+	//
+	// Parts created by the forced merge itself aren't counted, even if they are merged again.
+	PartsMerged uint64
+
+	// BytesWritten is the size of the created parts in bytes.
+	BytesWritten uint64
+
+	// createdParts contains paths to parts created by the forced merge of the current partition.
+	//
+	// It is accessed only by the goroutine running the forced merge.
+	createdParts map[string]struct{}
+}
+
+// addPartsToMerge adds pws to fmp.PartsTotal except of parts created by the forced merge.
+func (fmp *ForceMergeProgress) addPartsToMerge(pws []*partWrapper) {
+	if fmp == nil {
+		return
+	}
+	n := 0
+	for _, pw := range pws {
+		if _, ok := fmp.createdParts[pw.p.path]; !ok {
+			n++
+		}
+	}
+	atomic.AddUint64(&fmp.PartsTotal, uint64(n))
+}
+
+// addMergedParts registers the merge of pws into pwNew.
+//
+// pwNew may be nil if all the source rows have been deleted.
+func (fmp *ForceMergeProgress) addMergedParts(pws []*partWrapper, pwNew *partWrapper) {
+	if fmp == nil {
+		return
+	}
+	n := 0
+	for _, pw := range pws {
+		if _, ok := fmp.createdParts[pw.p.path]; ok {
+			delete(fmp.createdParts, pw.p.path)
+			continue
+		}
+		n++
+	}
+	atomic.AddUint64(&fmp.PartsMerged, uint64(n))
+	if pwNew == nil {
+		return
+	}
+	atomic.AddUint64(&fmp.BytesWritten, pwNew.p.size)
+	if pwNew.p.path != "" {
+		if fmp.createdParts == nil {
+			fmp.createdParts = make(map[string]struct{})
+		}
+		fmp.createdParts[pwNew.p.path] = struct{}{}
+	}
+}
+
+// resetCreatedParts resets the parts created by the forced merge of the current partition.
+func (fmp *ForceMergeProgress) resetCreatedParts() {
+	if fmp == nil {
+		return
+	}
+	fmp.createdParts = nil
+}
+
+// getForceMergeEstimate returns the number of parts and their size in bytes, which would be merged by ForceMergeAllParts.
+func (pt *partition) getForceMergeEstimate() (int, uint64) {
+	pt.partsLock.Lock()
+	defer pt.partsLock.Unlock()
+
+	n := 0
+	size := uint64(0)
+	for _, pws := range [][]*partWrapper{pt.inmemoryParts, pt.smallParts, pt.bigParts} {
+		n += len(pws)
+		size += getPartsSize(pws)
+	}
+	return n, size
+}
+
 func (pt *partition) getAllPartsForMerge() []*partWrapper {
 	var pws []*partWrapper
 	pt.partsLock.Lock()
@@ -1023,6 +1166,11 @@ func SetBigMergeWorkersCount(n int) {
 	bigMergeWorkersLimitCh = make(chan struct{}, n)
 }
 
+// GetBigMergeWorkersCount returns the maximum number of concurrent mergers for big blocks.
+func GetBigMergeWorkersCount() int {
+	return cap(bigMergeWorkersLimitCh)
+}
+
 // SetMergeWorkersCount sets the maximum number of concurrent mergers for parts.
 //
 // The function must be called before opening or creating any storage.
@@ -1173,7 +1321,7 @@ func (pt *partition) mergeInmemoryParts() error {
 	pt.partsLock.Unlock()
 
 	atomicSetBool(&pt.mergeNeedFreeDiskSpace, needFreeSpace)
-	return pt.mergeParts(pws, pt.stopCh, false)
+	return pt.mergeParts(pws, pt.stopCh, false, nil)
 }
 
 func (pt *partition) mergeExistingParts(isFinal bool) error {
@@ -1193,7 +1341,7 @@ func (pt *partition) mergeExistingParts(isFinal bool) error {
 	pt.partsLock.Unlock()
 
 	atomicSetBool(&pt.mergeNeedFreeDiskSpace, needFreeSpace)
-	return pt.mergeParts(pws, pt.stopCh, isFinal)
+	return pt.mergeParts(pws, pt.stopCh, isFinal, nil)
 }
 
 func (pt *partition) releasePartsToMerge(pws []*partWrapper) {
@@ -1222,7 +1370,7 @@ func (pt *partition) runFinalDedup() error {
 	t := time.Now()
 	logger.Infof("starting final dedup for partition %s using requiredDedupInterval=%d ms, since the partition has smaller actualDedupInterval=%d ms",
 		pt.bigPartsPath, requiredDedupInterval, actualDedupInterval)
-	if err := pt.ForceMergeAllParts(); err != nil {
+	if err := pt.ForceMergeAllParts(nil); err != nil {
 		return fmt.Errorf("cannot perform final dedup for partition %s: %w", pt.bigPartsPath, err)
 	}
 	logger.Infof("final dedup for partition %s has been finished in %.3f seconds", pt.bigPartsPath, time.Since(t).Seconds())
@@ -1263,7 +1411,9 @@ func getMinDedupInterval(pws []*partWrapper) int64 {
 // if isFinal is set, then the resulting part will be saved to disk.
 //
 // All the parts inside pws must have isInMerge field set to true.
-func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}, isFinal bool) error {
+//
+// The merge progress is tracked in fmp if it isn't nil.
+func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}, isFinal bool, fmp *ForceMergeProgress) error {
 	if len(pws) == 0 {
 		// Nothing to merge.
 		return errNothingToMerge
@@ -1291,6 +1441,7 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}, isFi
 			logger.Panicf("FATAL: cannot store in-memory part to %s: %s", dstPartPath, err)
 		}
 		pwNew := pt.openCreatedPart(&mp.ph, pws, nil, dstPartPath)
+		fmp.addMergedParts(pws, pwNew)
 		pt.swapSrcWithDstParts(pws, pwNew, dstPartType)
 		return nil
 	}
@@ -1333,6 +1484,16 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}, isFi
 		}
 	}
 
+	if partBloomFilterEnabled {
+		// Size the bloom filter for the destination part from the source parts,
+		// so metricIDs are added to it while writing blocks instead of collecting them in memory.
+		metricIDsCount := uint64(0)
+		for _, pw := range pws {
+			metricIDsCount += getMetricIDsCountUpperBound(pw.p)
+		}
+		bsw.initBloomFilter(metricIDsCount)
+	}
+
 	// Merge source parts to destination part.
 	ph, err := pt.mergePartsInternal(dstPartPath, bsw, bsrs, dstPartType, stopCh)
 	putBlockStreamWriter(bsw)
@@ -1357,6 +1518,7 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}, isFi
 		dstBlocksCount = pDst.ph.BlocksCount
 		dstSize = pDst.size
 	}
+	fmp.addMergedParts(pws, pwNew)
 
 	pt.swapSrcWithDstParts(pws, pwNew, dstPartType)
 
@@ -23,6 +23,9 @@ type partitionSearch struct {
 	psPool []partSearch
 	psHeap partSearchHeap
 
+	// partsSkippedByBloomFilter is the number of parts skipped at Init by their bloom filters.
+	partsSkippedByBloomFilter int
+
 	err error
 
 	nextBlockNoop bool
@@ -48,6 +51,7 @@ func (pts *partitionSearch) reset() {
 	}
 	pts.psHeap = pts.psHeap[:0]
 
+	pts.partsSkippedByBloomFilter = 0
 	pts.err = nil
 	pts.nextBlockNoop = false
 	pts.needClosing = false
@@ -88,7 +92,11 @@ func (pts *partitionSearch) Init(pt *partition, tsids []TSID, tr TimeRange) {
 	}
 	pts.psPool = pts.psPool[:len(pts.pws)]
 	for i, pw := range pts.pws {
-		pts.psPool[i].Init(pw.p, tsids, tr)
+		ps := &pts.psPool[i]
+		ps.Init(pw.p, tsids, tr)
+		if ps.skippedByBloomFilter {
+			pts.partsSkippedByBloomFilter++
+		}
 	}
 
 	// Initialize the psHeap.
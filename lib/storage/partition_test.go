@@ -1,9 +1,11 @@
 package storage
 
 import (
+	"fmt"
 	"math/rand"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestPartitionGetMaxOutBytes(t *testing.T) {
@@ -168,3 +170,136 @@ func newTestPartWrappersForSizes(sizes []uint64) []*partWrapper {
 	}
 	return pws
 }
+
+func TestRawRowsShardsGetMaxPossibleDataLossSeconds(t *testing.T) {
+	var rrss rawRowsShards
+	rrss.init()
+	f := func(currentTime, resultExpected uint64) {
+		t.Helper()
+		result := rrss.getMaxPossibleDataLossSeconds(currentTime)
+		if result != resultExpected {
+			t.Fatalf("unexpected result at currentTime=%d; got %d; want %d", currentTime, result, resultExpected)
+		}
+	}
+
+	// There are no pending rows
+	f(110, 0)
+
+	// Adding rows must register the time of the oldest pending row
+	rrs := &rrss.shards[0]
+	rrs.addRows(nil, []rawRow{{}})
+	oldestRowAddTime := rrs.oldestRowAddTime
+	if oldestRowAddTime == 0 {
+		t.Fatalf("oldestRowAddTime must be set after adding rows")
+	}
+	rrs.oldestRowAddTime = 1
+	rrs.addRows(nil, []rawRow{{}})
+	if rrs.oldestRowAddTime != 1 {
+		t.Fatalf("oldestRowAddTime mustn't change when adding rows to non-empty shard; got %d; want 1", rrs.oldestRowAddTime)
+	}
+
+	// The age must be counted from the time the oldest pending row was added, not from the last flush
+	rrs.lastFlushTime = 10
+	rrs.oldestRowAddTime = 100
+	f(100, 0)
+	f(110, 10)
+
+	// The oldest pending rows must be used
+	if len(rrss.shards) > 1 {
+		rrs = &rrss.shards[1]
+		rrs.rows = append(rrs.rows, rawRow{})
+		rrs.oldestRowAddTime = 90
+		f(110, 20)
+	}
+
+	// Flushed rows mustn't be counted
+	var dst []rawRow
+	for i := range rrss.shards {
+		dst = rrss.shards[i].appendRawRowsToFlush(dst, nil, true)
+	}
+	f(110, 0)
+}
+
+func TestPartWrapperGetMaxPossibleDataLossSeconds(t *testing.T) {
+	f := func(createdAt, currentTime, resultExpected uint64) {
+		t.Helper()
+		pw := &partWrapper{
+			flushToDiskDeadline: time.Unix(int64(createdAt), 0).Add(dataFlushInterval),
+		}
+		result := pw.getMaxPossibleDataLossSeconds(currentTime)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for createdAt=%d, currentTime=%d; got %d; want %d", createdAt, currentTime, result, resultExpected)
+		}
+	}
+	f(100, 100, 0)
+	f(100, 90, 0)
+	f(100, 103, 3)
+}
+
+func TestForceMergeProgress(t *testing.T) {
+	newPartWrapper := func(path string) *partWrapper {
+		return &partWrapper{
+			p: &part{
+				path: path,
+				size: 100,
+			},
+		}
+	}
+	newPartWrappers := func(prefix string, n int) []*partWrapper {
+		pws := make([]*partWrapper, n)
+		for i := range pws {
+			pws[i] = newPartWrapper(fmt.Sprintf("%s%d", prefix, i))
+		}
+		return pws
+	}
+	f := func(fmp *ForceMergeProgress, partsTotalExpected, partsMergedExpected, bytesWrittenExpected uint64) {
+		t.Helper()
+		if fmp.PartsTotal != partsTotalExpected {
+			t.Fatalf("unexpected PartsTotal; got %d; want %d", fmp.PartsTotal, partsTotalExpected)
+		}
+		if fmp.PartsMerged != partsMergedExpected {
+			t.Fatalf("unexpected PartsMerged; got %d; want %d", fmp.PartsMerged, partsMergedExpected)
+		}
+		if fmp.BytesWritten != bytesWrittenExpected {
+			t.Fatalf("unexpected BytesWritten; got %d; want %d", fmp.BytesWritten, bytesWrittenExpected)
+		}
+	}
+
+	var fmp ForceMergeProgress
+
+	// The first round merges 20 parts into 2 parts.
+	pws := newPartWrappers("src", 20)
+	fmp.addPartsToMerge(pws)
+	f(&fmp, 20, 0, 0)
+	pwNew1 := newPartWrapper("new1")
+	fmp.addMergedParts(pws[:15], pwNew1)
+	f(&fmp, 20, 15, 100)
+	pwNew2 := newPartWrapper("new2")
+	fmp.addMergedParts(pws[15:], pwNew2)
+	f(&fmp, 20, 20, 200)
+
+	// The second round merges the created parts with a part created concurrently.
+	// The created parts mustn't be counted in PartsTotal and PartsMerged.
+	pws = []*partWrapper{pwNew1, pwNew2, newPartWrapper("concurrent")}
+	fmp.addPartsToMerge(pws)
+	f(&fmp, 21, 20, 200)
+	fmp.addMergedParts(pws, newPartWrapper("new3"))
+	f(&fmp, 21, 21, 300)
+
+	// Merge resulting in an empty part.
+	pws = []*partWrapper{newPartWrapper("other")}
+	fmp.addPartsToMerge(pws)
+	fmp.addMergedParts(pws, nil)
+	f(&fmp, 22, 22, 300)
+
+	fmp.resetCreatedParts()
+	if len(fmp.createdParts) != 0 {
+		t.Fatalf("unexpected created parts after reset: %v", fmp.createdParts)
+	}
+
+	// nil progress must be ignored.
+	var fmpNil *ForceMergeProgress
+	fmpNil.addPartsToMerge(pws)
+	fmpNil.addMergedParts(pws, nil)
+	fmpNil.resetCreatedParts()
+}
@@ -99,6 +99,9 @@ func (rrm *rawRowsMarshaler) marshalToInmemoryPart(mp *inmemoryPart, rows []rawR
 	if !sort.IsSorted(&rrs) {
 		sort.Sort(&rrs)
 	}
+	if partBloomFilterEnabled {
+		rrm.bsw.initBloomFilter(getUniqueMetricIDsCount(rows))
+	}
 
 	// Group rows into blocks.
 	var scale int16
@@ -135,6 +138,17 @@ func (rrm *rawRowsMarshaler) marshalToInmemoryPart(mp *inmemoryPart, rows []rawR
 	rrm.bsw.MustClose()
 }
 
+// getUniqueMetricIDsCount returns the number of unique metricIDs in rows sorted by TSID.
+func getUniqueMetricIDsCount(rows []rawRow) uint64 {
+	n := uint64(0)
+	for i := range rows {
+		if i == 0 || rows[i].TSID.MetricID != rows[i-1].TSID.MetricID {
+			n++
+		}
+	}
+	return n
+}
+
 func getRawRowsMarshaler() *rawRowsMarshaler {
 	v := rrmPool.Get()
 	if v == nil {
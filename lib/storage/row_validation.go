@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"math"
+)
+
+// SetMinAllowedTimestamp sets the minimum allowed timestamp in milliseconds for the added rows.
+//
+// Rows with smaller timestamps are rejected. This protects from rows with bogus timestamps such as 0,
+// which would create partitions for the distant past.
+//
+// The function must be called before opening or creating any storage.
+func SetMinAllowedTimestamp(timestamp int64) {
+	minAllowedTimestamp = timestamp
+}
+
+// SetAllowNonFiniteValues sets whether the added rows may contain +Inf and -Inf values.
+//
+// Rows with +Inf and -Inf values are rejected if ok is false.
+// NaN values other than Prometheus staleness markers are always skipped, since the underlying encoding doesn't support them.
+//
+// The function must be called before opening or creating any storage.
+func SetAllowNonFiniteValues(ok bool) {
+	allowNonFiniteValues = ok
+}
+
+// SetMaxAbsValue sets the maximum absolute value for the added rows.
+//
+// Values exceeding maxValue are clamped to maxValue, while values smaller than -maxValue are clamped to -maxValue.
+// Values aren't clamped if maxValue is 0.
+//
+// The function must be called before opening or creating any storage.
+func SetMaxAbsValue(maxValue float64) {
+	maxAbsValue = maxValue
+}
+
+var (
+	minAllowedTimestamp  = int64(math.MinInt64)
+	allowNonFiniteValues = true
+	maxAbsValue          float64
+)
+
+// maxRejectedRowsDetails is the maximum number of rejected rows mentioned in the warning per each Storage.add call.
+const maxRejectedRowsDetails = 3
@@ -116,6 +116,10 @@ type Search struct {
 	loops int
 
 	prevMetricID uint64
+
+	// mut is used for registering queried metric names. It is nil if metric usage tracking is disabled.
+	mut             *metricUsageTracker
+	prevMetricGroup []byte
 }
 
 func (s *Search) reset() {
@@ -132,6 +136,8 @@ func (s *Search) reset() {
 	s.needClosing = false
 	s.loops = 0
 	s.prevMetricID = 0
+	s.mut = nil
+	s.prevMetricGroup = s.prevMetricGroup[:0]
 }
 
 // Init initializes s from the given storage, tfss and tr.
@@ -154,6 +160,7 @@ func (s *Search) Init(qt *querytracer.Tracer, storage *Storage, tfss []*TagFilte
 	s.tfss = tfss
 	s.deadline = deadline
 	s.needClosing = true
+	s.mut = storage.metricUsageTracker
 
 	var tsids []TSID
 	metricIDs, err := s.idb.searchMetricIDs(qt, tfss, tr, maxMetrics, deadline)
@@ -168,6 +175,9 @@ func (s *Search) Init(qt *querytracer.Tracer, storage *Storage, tfss []*TagFilte
 	// on Search.MustClose otherwise.
 	s.ts.Init(storage.tb, tsids, tr)
 	qt.Printf("search for parts with data for %d series", len(tsids))
+	if partBloomFilterEnabled {
+		qt.Printf("skip %d parts, which don't contain the found series according to their bloom filters", s.ts.partsSkippedByBloomFilter)
+	}
 	if err != nil {
 		s.err = err
 		return 0
@@ -223,6 +233,9 @@ func (s *Search) NextMetricBlock() bool {
 				return false
 			}
 			s.prevMetricID = tsid.MetricID
+			if s.mut != nil {
+				s.registerMetricGroupQuery()
+			}
 		}
 		s.MetricBlockRef.BlockRef = s.ts.BlockRef
 		return true
@@ -236,6 +249,21 @@ func (s *Search) NextMetricBlock() bool {
 	return false
 }
 
+// registerMetricGroupQuery registers the query for the metric group from s.MetricBlockRef.MetricName.
+//
+// Blocks are sorted by TSID, which starts with MetricGroupID, so every metric group is registered once per search.
+func (s *Search) registerMetricGroupQuery() {
+	_, metricGroup, err := unmarshalTagValue(nil, s.MetricBlockRef.MetricName)
+	if err != nil {
+		return
+	}
+	if len(s.prevMetricGroup) > 0 && string(metricGroup) == string(s.prevMetricGroup) {
+		return
+	}
+	s.mut.registerQuery(metricGroup)
+	s.prevMetricGroup = append(s.prevMetricGroup[:0], metricGroup...)
+}
+
 // SearchQuery is used for sending search queries from vmselect to vmstorage.
 type SearchQuery struct {
 	// The time range for searching time series
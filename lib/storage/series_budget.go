@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bloomfilter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// SeriesBudget limits the number of unique series during the last hour for metric names matching MetricNamePattern.
+type SeriesBudget struct {
+	// MetricNamePattern is an anchored regexp for metric names the budget applies to.
+	MetricNamePattern string
+
+	// MaxSeries is the maximum number of unique series during the last hour for all the metric names matching MetricNamePattern.
+	MaxSeries int
+}
+
+// SetSeriesBudgets replaces series budgets for s with sbs.
+//
+// Series budgets aren't applied if sbs is empty.
+// Budgets with unchanged MetricNamePattern and MaxSeries preserve the already registered series.
+func (s *Storage) SetSeriesBudgets(sbs []SeriesBudget) error {
+	sbsOld := s.getSeriesBudgets()
+	var rulesOld []*seriesBudgetRule
+	if sbsOld != nil {
+		rulesOld = sbsOld.rules
+	}
+	rules := make([]*seriesBudgetRule, 0, len(sbs))
+	reused := make(map[*seriesBudgetRule]bool)
+	for _, sb := range sbs {
+		if sb.MaxSeries <= 0 {
+			return fmt.Errorf("max series for metric name pattern %q must be positive; got %d", sb.MetricNamePattern, sb.MaxSeries)
+		}
+		re, err := regexp.Compile("^(?:" + sb.MetricNamePattern + ")$")
+		if err != nil {
+			return fmt.Errorf("cannot parse metric name pattern %q: %w", sb.MetricNamePattern, err)
+		}
+		var r *seriesBudgetRule
+		for _, rOld := range rulesOld {
+			if rOld.pattern == sb.MetricNamePattern && rOld.limiter.MaxItems() == sb.MaxSeries && !reused[rOld] {
+				r = rOld
+				reused[rOld] = true
+				break
+			}
+		}
+		if r == nil {
+			r = &seriesBudgetRule{
+				pattern: sb.MetricNamePattern,
+				re:      re,
+				limiter: bloomfilter.NewLimiter(sb.MaxSeries, time.Hour),
+			}
+		}
+		rules = append(rules, r)
+	}
+	var sbsNew *seriesBudgets
+	if len(rules) > 0 {
+		sbsNew = newSeriesBudgets(rules)
+	}
+	s.seriesBudgets.Store(sbsNew)
+	if sbsNew != nil {
+		metrics.RegisterSet(sbsNew.metricsSet)
+	}
+	if sbsOld != nil {
+		metrics.UnregisterSet(sbsOld.metricsSet)
+	}
+	for _, rOld := range rulesOld {
+		if !reused[rOld] {
+			rOld.limiter.MustStop()
+		}
+	}
+	return nil
+}
+
+func (s *Storage) getSeriesBudgets() *seriesBudgets {
+	sbs, _ := s.seriesBudgets.Load().(*seriesBudgets)
+	return sbs
+}
+
+func (s *Storage) mustStopSeriesBudgets() {
+	sbs := s.getSeriesBudgets()
+	if sbs == nil {
+		return
+	}
+	metrics.UnregisterSet(sbs.metricsSet)
+	for _, r := range sbs.rules {
+		r.limiter.MustStop()
+	}
+}
+
+// registerSeriesBudget registers the series with the given metricID and metricGroup in the matching series budgets.
+//
+// Series exceeding the budget are rejected only if isNewSeries is set, so already existing series are never affected by budgets.
+func (s *Storage) registerSeriesBudget(metricID uint64, metricGroup []byte, isNewSeries bool) error {
+	sbs := s.getSeriesBudgets()
+	if sbs == nil {
+		return nil
+	}
+	for _, r := range sbs.getMatchingRules(metricGroup) {
+		if !r.limiter.Add(metricID) && isNewSeries {
+			registerSeriesBudgetRejectedSeries(metricGroup)
+			logSkippedSeriesBudget(metricGroup, r)
+			return errSeriesCardinalityExceeded
+		}
+	}
+	return nil
+}
+
+type seriesBudgetRule struct {
+	pattern string
+	re      *regexp.Regexp
+	limiter *bloomfilter.Limiter
+}
+
+type seriesBudgets struct {
+	rules []*seriesBudgetRule
+
+	// matchingRulesCache contains matching rules per each metric name.
+	matchingRulesCacheLock sync.Mutex
+	matchingRulesCache     map[string][]*seriesBudgetRule
+
+	// metricsSet contains per-rule metrics.
+	metricsSet *metrics.Set
+}
+
+func newSeriesBudgets(rules []*seriesBudgetRule) *seriesBudgets {
+	ms := metrics.NewSet()
+	for _, r := range rules {
+		r := r
+		ms.NewGauge(fmt.Sprintf(`vm_series_budget_max_series{metric_name_pattern=%q}`, r.pattern), func() float64 {
+			return float64(r.limiter.MaxItems())
+		})
+		ms.NewGauge(fmt.Sprintf(`vm_series_budget_current_series{metric_name_pattern=%q}`, r.pattern), func() float64 {
+			return float64(r.limiter.CurrentItems())
+		})
+	}
+	return &seriesBudgets{
+		rules:              rules,
+		matchingRulesCache: make(map[string][]*seriesBudgetRule),
+		metricsSet:         ms,
+	}
+}
+
+// maxSeriesBudgetCacheEntries limits the number of cached metric names in seriesBudgets.matchingRulesCache.
+const maxSeriesBudgetCacheEntries = 100e3
+
+func (sbs *seriesBudgets) getMatchingRules(metricGroup []byte) []*seriesBudgetRule {
+	sbs.matchingRulesCacheLock.Lock()
+	rules, ok := sbs.matchingRulesCache[string(metricGroup)]
+	sbs.matchingRulesCacheLock.Unlock()
+	if ok {
+		return rules
+	}
+	for _, r := range sbs.rules {
+		if r.re.Match(metricGroup) {
+			rules = append(rules, r)
+		}
+	}
+	sbs.matchingRulesCacheLock.Lock()
+	if len(sbs.matchingRulesCache) >= maxSeriesBudgetCacheEntries {
+		sbs.matchingRulesCache = make(map[string][]*seriesBudgetRule)
+	}
+	sbs.matchingRulesCache[string(metricGroup)] = rules
+	sbs.matchingRulesCacheLock.Unlock()
+	return rules
+}
+
+// maxSeriesBudgetRejectedMetricNames limits the number of unique metric_name label values
+// for vm_new_series_rejected_total metric.
+//
+// Rejected series for the remaining metric names are counted under metric_name="other".
+const maxSeriesBudgetRejectedMetricNames = 100
+
+func registerSeriesBudgetRejectedSeries(metricGroup []byte) {
+	seriesBudgetRejectedSeriesLock.Lock()
+	c := seriesBudgetRejectedSeries[string(metricGroup)]
+	if c == nil {
+		metricName := string(metricGroup)
+		if len(seriesBudgetRejectedSeries) >= maxSeriesBudgetRejectedMetricNames {
+			metricName = "other"
+		}
+		c = metrics.GetOrCreateCounter(fmt.Sprintf(`vm_new_series_rejected_total{metric_name=%q}`, metricName))
+		if len(seriesBudgetRejectedSeries) < maxSeriesBudgetRejectedMetricNames {
+			seriesBudgetRejectedSeries[string(metricGroup)] = c
+		}
+	}
+	seriesBudgetRejectedSeriesLock.Unlock()
+	c.Inc()
+}
+
+// seriesBudgetRejectedSeries contains per-metric-name counters for series rejected by series budgets.
+var (
+	seriesBudgetRejectedSeriesLock sync.Mutex
+	seriesBudgetRejectedSeries     = make(map[string]*metrics.Counter)
+)
+
+func logSkippedSeriesBudget(metricGroup []byte, r *seriesBudgetRule) {
+	select {
+	case <-logSkippedSeriesTicker.C:
+		logger.Warnf("skip new series for metric %q because the series budget %d for metric name pattern %q is exceeded during the last hour",
+			metricGroup, r.limiter.MaxItems(), r.pattern)
+	default:
+	}
+}
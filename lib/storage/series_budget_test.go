@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bloomfilter"
+)
+
+func TestStorageSetSeriesBudgetsFailure(t *testing.T) {
+	path := "TestStorageSetSeriesBudgetsFailure"
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	defer func() {
+		s.MustClose()
+		if err := os.RemoveAll(path); err != nil {
+			t.Fatalf("cannot remove %q: %s", path, err)
+		}
+	}()
+	f := func(sbs []SeriesBudget) {
+		t.Helper()
+		if err := s.SetSeriesBudgets(sbs); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f([]SeriesBudget{{MetricNamePattern: "foo", MaxSeries: 0}})
+	f([]SeriesBudget{{MetricNamePattern: "foo(", MaxSeries: 10}})
+}
+
+func TestStorageSeriesBudgets(t *testing.T) {
+	path := "TestStorageSeriesBudgets"
+	s, err := OpenStorage(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	defer func() {
+		s.MustClose()
+		if err := os.RemoveAll(path); err != nil {
+			t.Fatalf("cannot remove %q: %s", path, err)
+		}
+	}()
+
+	timestamp := timestampFromTime(time.Now())
+	addSeries := func(metricName string, startInstance, endInstance int) {
+		t.Helper()
+		var mrs []MetricRow
+		for instance := startInstance; instance <= endInstance; instance++ {
+			mn := &MetricName{
+				MetricGroup: []byte(metricName),
+				Tags: []Tag{
+					{Key: []byte("instance"), Value: []byte(fmt.Sprintf("host_%d", instance))},
+				},
+			}
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: mn.marshalRaw(nil),
+				Timestamp:     timestamp,
+				Value:         1,
+			})
+		}
+		if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+			t.Fatalf("cannot add rows: %s", err)
+		}
+	}
+	getSeriesCount := func(metricName string) int {
+		t.Helper()
+		s.DebugFlush()
+		tfs := NewTagFilters()
+		if err := tfs.Add(nil, []byte(metricName), false, false); err != nil {
+			t.Fatalf("cannot add tag filter: %s", err)
+		}
+		tr := TimeRange{
+			MinTimestamp: timestamp - 1000,
+			MaxTimestamp: timestamp + 1000,
+		}
+		names, err := s.SearchMetricNames(nil, []*TagFilters{tfs}, tr, 1e5, noDeadline)
+		if err != nil {
+			t.Fatalf("cannot search metric names: %s", err)
+		}
+		return len(names)
+	}
+	checkSeriesCount := func(metricName string, nExpected int) {
+		t.Helper()
+		if n := getSeriesCount(metricName); n != nExpected {
+			t.Fatalf("unexpected number of series for %q; got %d; want %d", metricName, n, nExpected)
+		}
+	}
+
+	// Series registered before the budget is set must be preserved.
+	addSeries("foo_existing", 1, 30)
+	checkSeriesCount("foo_existing", 30)
+
+	if err := s.SetSeriesBudgets([]SeriesBudget{{MetricNamePattern: "foo_.*", MaxSeries: 20}}); err != nil {
+		t.Fatalf("cannot set series budgets: %s", err)
+	}
+	addSeries("foo_existing", 1, 30)
+	checkSeriesCount("foo_existing", 30)
+
+	// New series exceeding the budget must be rejected.
+	// The budget is approximate because of bloom filter false positives, so allow some excess series.
+	addSeries("foo_new", 1, 100)
+	if n := getSeriesCount("foo_new"); n > 20 {
+		t.Fatalf("too many series registered for foo_new; got %d; want no more than 20", n)
+	}
+
+	// Series for metric names without budgets must be accepted.
+	addSeries("bar", 1, 100)
+	checkSeriesCount("bar", 100)
+
+	// Removed budgets must allow new series.
+	if err := s.SetSeriesBudgets(nil); err != nil {
+		t.Fatalf("cannot reset series budgets: %s", err)
+	}
+	addSeries("foo_new", 1, 100)
+	checkSeriesCount("foo_new", 100)
+}
+
+func TestStorageRegisterSeriesBudget(t *testing.T) {
+	var s Storage
+	if err := s.SetSeriesBudgets([]SeriesBudget{
+		{MetricNamePattern: "foo|bar", MaxSeries: 3},
+		{MetricNamePattern: "bar", MaxSeries: 1},
+	}); err != nil {
+		t.Fatalf("cannot set series budgets: %s", err)
+	}
+	defer s.mustStopSeriesBudgets()
+
+	f := func(metricID uint64, metricGroup string, isNewSeries, okExpected bool) {
+		t.Helper()
+		err := s.registerSeriesBudget(metricID, []byte(metricGroup), isNewSeries)
+		if okExpected && err != nil {
+			t.Fatalf("unexpected error for metricID=%d, metricGroup=%q: %s", metricID, metricGroup, err)
+		}
+		if !okExpected && err == nil {
+			t.Fatalf("expecting non-nil error for metricID=%d, metricGroup=%q", metricID, metricGroup)
+		}
+	}
+
+	// Metric names without budgets
+	f(1e9, "baz", true, true)
+	f(2e9, "foobar", true, true)
+
+	// The bar budget is exceeded by the second series
+	f(3e9, "bar", true, true)
+	f(4e9, "bar", true, false)
+
+	// Existing series are never rejected
+	f(4e9, "bar", false, true)
+	f(5e9, "foo", false, true)
+
+	// The foo|bar budget is exhausted by the already registered series
+	f(6e9, "foo", true, false)
+	f(3e9, "bar", true, true)
+}
+
+func TestStorageRegisterSeriesCardinalityBudgetFirst(t *testing.T) {
+	var s Storage
+	s.hourlySeriesLimiter = bloomfilter.NewLimiter(100, time.Hour)
+	defer s.hourlySeriesLimiter.MustStop()
+	if err := s.SetSeriesBudgets([]SeriesBudget{{MetricNamePattern: "foo", MaxSeries: 1}}); err != nil {
+		t.Fatalf("cannot set series budgets: %s", err)
+	}
+	defer s.mustStopSeriesBudgets()
+
+	mn := &MetricName{
+		MetricGroup: []byte("foo"),
+	}
+	metricNameRaw := mn.marshalRaw(nil)
+	if err := s.registerSeriesCardinality(1, metricNameRaw, true); err != nil {
+		t.Fatalf("unexpected error for the first series: %s", err)
+	}
+
+	// New series rejected by the budget obtain new metricIDs on every retry.
+	// They mustn't occupy slots in the global series limiters.
+	for metricID := uint64(2); metricID < 200; metricID++ {
+		if err := s.registerSeriesCardinality(metricID, metricNameRaw, true); err == nil {
+			t.Fatalf("expecting non-nil error for metricID=%d", metricID)
+		}
+	}
+	if n := s.hourlySeriesLimiter.CurrentItems(); n != 1 {
+		t.Fatalf("unexpected number of series in the hourly limiter; got %d; want 1", n)
+	}
+}
@@ -45,6 +45,10 @@ type Storage struct {
 	tooSmallTimestampRows uint64
 	tooBigTimestampRows   uint64
 
+	belowMinTimestampRows uint64
+	nonFiniteValueRows    uint64
+	clampedValueRows      uint64
+
 	slowRowInserts         uint64
 	slowPerDayIndexInserts uint64
 	slowMetricNameLoads    uint64
@@ -67,6 +71,9 @@ type Storage struct {
 	hourlySeriesLimiter *bloomfilter.Limiter
 	dailySeriesLimiter  *bloomfilter.Limiter
 
+	// seriesBudgets contains *seriesBudgets set via SetSeriesBudgets.
+	seriesBudgets atomic.Value
+
 	// tsidCache is MetricName -> TSID cache.
 	tsidCache *workingsetcache.Cache
 
@@ -132,6 +139,15 @@ type Storage struct {
 	deletedMetricIDsUpdateLock sync.Mutex
 
 	isReadOnly uint32
+
+	// isReadOnlyDueToFreeDiskSpace is set to 1 if the storage is switched to read-only mode
+	// because of the lack of free disk space. See startFreeDiskSpaceWatcher.
+	isReadOnlyDueToFreeDiskSpace uint32
+
+	// metricUsageTracker tracks per-metric-name usage stats. It is nil if the tracking is disabled.
+	// See SetMetricUsageTrackerMaxEntries.
+	metricUsageTracker *metricUsageTracker
+	metricUsageSaverWG sync.WaitGroup
 }
 
 // OpenStorage opens storage on the given path with the given retentionMsecs.
@@ -182,6 +198,18 @@ func OpenStorage(path string, retentionMsecs int64, maxHourlySeries, maxDailySer
 		return nil, fmt.Errorf("restore lock file exists, incomplete vmrestore run. Run vmrestore again or remove lock file %q", restoreLockF)
 	}
 
+	// Verify partition granularity before opening the data.
+	metadataDir := filepath.Join(path, metadataDirname)
+	isEmptyDB := !fs.IsPathExist(filepath.Join(path, indexdbDirname))
+	if err := fs.MkdirAllIfNotExist(metadataDir); err != nil {
+		return nil, fmt.Errorf("cannot create %q: %w", metadataDir, err)
+	}
+	if err := checkPartitionGranularity(metadataDir, isEmptyDB); err != nil {
+		// Release the lock file, so the storage could be opened with the correct partition granularity.
+		_ = s.flockF.Close()
+		return nil, err
+	}
+
 	// Pre-create snapshots directory if it is missing.
 	snapshotsPath := filepath.Join(path, snapshotsDirname)
 	if err := fs.MkdirAllIfNotExist(snapshotsPath); err != nil {
@@ -219,12 +247,10 @@ func OpenStorage(path string, retentionMsecs int64, maxHourlySeries, maxDailySer
 	s.prefetchedMetricIDs.Store(&uint64set.Set{})
 
 	// Load metadata
-	metadataDir := filepath.Join(path, metadataDirname)
-	isEmptyDB := !fs.IsPathExist(filepath.Join(path, indexdbDirname))
-	if err := fs.MkdirAllIfNotExist(metadataDir); err != nil {
-		return nil, fmt.Errorf("cannot create %q: %w", metadataDir, err)
-	}
 	s.minTimestampForCompositeIndex = mustGetMinTimestampForCompositeIndex(metadataDir, isEmptyDB)
+	if metricUsageTrackerMaxEntries > 0 {
+		s.metricUsageTracker = mustLoadMetricUsageTracker(s.metricUsagePath(), metricUsageTrackerMaxEntries)
+	}
 
 	// Load indexdb
 	idbPath := filepath.Join(path, indexdbDirname)
@@ -268,6 +294,9 @@ func OpenStorage(path string, retentionMsecs int64, maxHourlySeries, maxDailySer
 	s.startCurrHourMetricIDsUpdater()
 	s.startNextDayMetricIDsUpdater()
 	s.startRetentionWatcher()
+	if s.metricUsageTracker != nil {
+		s.startMetricUsageSaver()
+	}
 
 	return s, nil
 }
@@ -462,6 +491,10 @@ type Metrics struct {
 	TooSmallTimestampRows uint64
 	TooBigTimestampRows   uint64
 
+	BelowMinTimestampRows uint64
+	NonFiniteValueRows    uint64
+	ClampedValueRows      uint64
+
 	SlowRowInserts         uint64
 	SlowPerDayIndexInserts uint64
 	SlowMetricNameLoads    uint64
@@ -477,6 +510,8 @@ type Metrics struct {
 	TimestampsBlocksMerged uint64
 	TimestampsBytesSaved   uint64
 
+	PartsSkippedByBloomFilter uint64
+
 	TSIDCacheSize         uint64
 	TSIDCacheSizeBytes    uint64
 	TSIDCacheSizeMaxBytes uint64
@@ -531,6 +566,10 @@ func (s *Storage) UpdateMetrics(m *Metrics) {
 	m.TooSmallTimestampRows += atomic.LoadUint64(&s.tooSmallTimestampRows)
 	m.TooBigTimestampRows += atomic.LoadUint64(&s.tooBigTimestampRows)
 
+	m.BelowMinTimestampRows += atomic.LoadUint64(&s.belowMinTimestampRows)
+	m.NonFiniteValueRows += atomic.LoadUint64(&s.nonFiniteValueRows)
+	m.ClampedValueRows += atomic.LoadUint64(&s.clampedValueRows)
+
 	m.SlowRowInserts += atomic.LoadUint64(&s.slowRowInserts)
 	m.SlowPerDayIndexInserts += atomic.LoadUint64(&s.slowPerDayIndexInserts)
 	m.SlowMetricNameLoads += atomic.LoadUint64(&s.slowMetricNameLoads)
@@ -550,6 +589,8 @@ func (s *Storage) UpdateMetrics(m *Metrics) {
 	m.TimestampsBlocksMerged = atomic.LoadUint64(&timestampsBlocksMerged)
 	m.TimestampsBytesSaved = atomic.LoadUint64(&timestampsBytesSaved)
 
+	m.PartsSkippedByBloomFilter = atomic.LoadUint64(&partsSkippedByBloomFilter)
+
 	var cs fastcache.Stats
 	s.tsidCache.UpdateStats(&cs)
 	m.TSIDCacheSize += cs.EntriesCount
@@ -613,26 +654,92 @@ func SetFreeDiskSpaceLimit(bytes int64) {
 	freeDiskSpaceLimitBytes = uint64(bytes)
 }
 
-var freeDiskSpaceLimitBytes uint64
+// SetFreeDiskSpaceRecoveryLimit sets the minimum free disk space size of current storage path
+// for switching the storage from read-only mode back to read-write mode.
+//
+// The storage is switched back to read-write mode only after the free disk space stays above the limit for the given delay.
+// This prevents from flapping between read-only and read-write modes when background merges temporarily free disk space.
+// The limit set via SetFreeDiskSpaceLimit is used if bytes is smaller than it.
+//
+// The function must be called before opening or creating any storage.
+func SetFreeDiskSpaceRecoveryLimit(bytes int64, delay time.Duration) {
+	freeDiskSpaceRecoveryBytes = uint64(bytes)
+	freeDiskSpaceRecoveryDelay = delay
+}
+
+var (
+	freeDiskSpaceLimitBytes    uint64
+	freeDiskSpaceRecoveryBytes uint64
+	freeDiskSpaceRecoveryDelay time.Duration
+)
+
+func getFreeDiskSpaceRecoveryBytes() uint64 {
+	if freeDiskSpaceRecoveryBytes < freeDiskSpaceLimitBytes {
+		return freeDiskSpaceLimitBytes
+	}
+	return freeDiskSpaceRecoveryBytes
+}
 
 // IsReadOnly returns information is storage in read only mode
 func (s *Storage) IsReadOnly() bool {
 	return atomic.LoadUint32(&s.isReadOnly) == 1
 }
 
+// IsReadOnlyDueToFreeDiskSpace returns true if the storage is in read-only mode because of the lack of free disk space.
+func (s *Storage) IsReadOnlyDueToFreeDiskSpace() bool {
+	return atomic.LoadUint32(&s.isReadOnlyDueToFreeDiskSpace) == 1
+}
+
+// freeDiskSpaceHysteresis decides whether the storage must be in read-only mode depending on the free disk space.
+type freeDiskSpaceHysteresis struct {
+	// recoveryStart is the time when the free disk space became bigger than getFreeDiskSpaceRecoveryBytes() in read-only mode.
+	recoveryStart time.Time
+}
+
+// isReadOnly returns whether the storage in isReadOnly mode must be in read-only mode at ct when it has freeSpaceBytes of free disk space.
+func (h *freeDiskSpaceHysteresis) isReadOnly(isReadOnly bool, freeSpaceBytes uint64, ct time.Time) bool {
+	if freeSpaceBytes < freeDiskSpaceLimitBytes {
+		h.recoveryStart = time.Time{}
+		return true
+	}
+	if !isReadOnly {
+		return false
+	}
+	if freeSpaceBytes < getFreeDiskSpaceRecoveryBytes() {
+		h.recoveryStart = time.Time{}
+		return true
+	}
+	if h.recoveryStart.IsZero() {
+		h.recoveryStart = ct
+	}
+	if ct.Sub(h.recoveryStart) < freeDiskSpaceRecoveryDelay {
+		return true
+	}
+	h.recoveryStart = time.Time{}
+	return false
+}
+
 func (s *Storage) startFreeDiskSpaceWatcher() {
+	var h freeDiskSpaceHysteresis
 	f := func() {
 		freeSpaceBytes := fs.MustGetFreeSpace(s.path)
-		if freeSpaceBytes < freeDiskSpaceLimitBytes {
-			// Switch the storage to readonly mode if there is no enough free space left at s.path
-			logger.Warnf("switching the storage at %s to read-only mode, since it has less than -storage.minFreeDiskSpaceBytes=%d of free space: %d bytes left",
-				s.path, freeDiskSpaceLimitBytes, freeSpaceBytes)
-			atomic.StoreUint32(&s.isReadOnly, 1)
+		isReadOnly := s.IsReadOnlyDueToFreeDiskSpace()
+		if h.isReadOnly(isReadOnly, freeSpaceBytes, time.Now()) {
+			if !isReadOnly {
+				// Switch the storage to readonly mode if there is no enough free space left at s.path
+				logger.Warnf("switching the storage at %s to read-only mode, since it has less than -storage.minFreeDiskSpaceBytes=%d of free space: %d bytes left",
+					s.path, freeDiskSpaceLimitBytes, freeSpaceBytes)
+				atomic.StoreUint32(&s.isReadOnlyDueToFreeDiskSpace, 1)
+				atomic.StoreUint32(&s.isReadOnly, 1)
+			}
 			return
 		}
-		if atomic.CompareAndSwapUint32(&s.isReadOnly, 1, 0) {
-			logger.Warnf("enabling writing to the storage at %s, since it has more than -storage.minFreeDiskSpaceBytes=%d of free space: %d bytes left",
-				s.path, freeDiskSpaceLimitBytes, freeSpaceBytes)
+		if isReadOnly {
+			logger.Warnf("enabling writing to the storage at %s, since it has more than -storage.minFreeDiskSpaceRecoveryBytes=%d of free space "+
+				"during -storage.readOnlyRecoveryDelay=%s: %d bytes left",
+				s.path, getFreeDiskSpaceRecoveryBytes(), freeDiskSpaceRecoveryDelay, freeSpaceBytes)
+			atomic.StoreUint32(&s.isReadOnlyDueToFreeDiskSpace, 0)
+			atomic.StoreUint32(&s.isReadOnly, 0)
 		}
 	}
 	f()
@@ -800,6 +907,7 @@ func (s *Storage) MustClose() {
 	s.retentionWatcherWG.Wait()
 	s.currHourMetricIDsUpdaterWG.Wait()
 	s.nextDayMetricIDsUpdaterWG.Wait()
+	s.metricUsageSaverWG.Wait()
 
 	s.tb.MustClose()
 	s.idb().MustClose()
@@ -820,6 +928,10 @@ func (s *Storage) MustClose() {
 	nextDayMetricIDs := s.nextDayMetricIDs.Load().(*byDateMetricIDEntry)
 	s.mustSaveNextDayMetricIDs(nextDayMetricIDs)
 
+	if s.metricUsageTracker != nil {
+		s.metricUsageTracker.mustSave(s.metricUsagePath())
+	}
+
 	// Release lock file.
 	if err := s.flockF.Close(); err != nil {
 		logger.Panicf("FATAL: cannot close lock file %q: %s", s.flockF.Name(), err)
@@ -832,6 +944,7 @@ func (s *Storage) MustClose() {
 	if sl := s.dailySeriesLimiter; sl != nil {
 		sl.MustStop()
 	}
+	s.mustStopSeriesBudgets()
 }
 
 func (s *Storage) mustLoadNextDayMetricIDs(date uint64) *byDateMetricIDEntry {
@@ -1011,6 +1124,40 @@ func mustGetMinTimestampForCompositeIndex(metadataDir string, isEmptyDB bool) in
 	return minTimestamp
 }
 
+// checkPartitionGranularity verifies that the partition granularity set via SetPartitionGranularity matches the granularity of the existing data.
+//
+// The granularity is stored in metadataDir on the first start. The storage without the stored granularity
+// is created by older VictoriaMetrics releases, which support only monthly partitions.
+func checkPartitionGranularity(metadataDir string, isEmptyDB bool) error {
+	path := filepath.Join(metadataDir, "partitionGranularity")
+	data, err := os.ReadFile(path)
+	if err == nil {
+		granularity := string(data)
+		if granularity != partitionGranularity {
+			return fmt.Errorf("the storage was created with %s partition granularity, while %s partition granularity is requested; "+
+				"the partition granularity cannot be changed for the existing storage; either use -storage.partitionGranularity=%s "+
+				"or migrate the data to a new storage with the needed partition granularity; see https://docs.victoriametrics.com/#partition-granularity",
+				granularity, partitionGranularity, granularity)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("cannot read partition granularity: %w", err)
+	}
+	granularity := partitionGranularity
+	if !isEmptyDB {
+		// The storage was created by older release with monthly partitions.
+		granularity = PartitionGranularityMonthly
+	}
+	if err := fs.WriteFileAtomically(path, []byte(granularity), true); err != nil {
+		return fmt.Errorf("cannot store partition granularity: %w", err)
+	}
+	if granularity != partitionGranularity {
+		return checkPartitionGranularity(metadataDir, isEmptyDB)
+	}
+	return nil
+}
+
 func loadMinTimestampForCompositeIndex(path string) (int64, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -1509,17 +1656,50 @@ func (mr *MetricRow) UnmarshalX(src []byte) ([]byte, error) {
 // ForceMergePartitions force-merges partitions in s with names starting from the given partitionNamePrefix.
 //
 // Partitions are merged sequentially in order to reduce load on the system.
-func (s *Storage) ForceMergePartitions(partitionNamePrefix string) error {
-	return s.tb.ForceMergePartitions(partitionNamePrefix)
+// The merge progress is tracked in fmp if it isn't nil.
+func (s *Storage) ForceMergePartitions(partitionNamePrefix string, fmp *ForceMergeProgress) error {
+	return s.tb.ForceMergePartitions(partitionNamePrefix, fmp)
+}
+
+// GetForceMergeEstimates returns estimates for ForceMergePartitions call with the given partitionNamePrefix.
+func (s *Storage) GetForceMergeEstimates(partitionNamePrefix string) []ForceMergeEstimate {
+	return s.tb.GetForceMergeEstimates(partitionNamePrefix)
 }
 
 var rowsAddedTotal uint64
 
+// AddRowsStats contains stats for rows passed to Storage.AddRowsWithStats.
+type AddRowsStats struct {
+	// RowsAdded is the number of rows added to the storage.
+	RowsAdded int
+
+	// InvalidTimestampRows is the number of rows dropped because of timestamps outside the allowed time range.
+	InvalidTimestampRows int
+
+	// InvalidValueRows is the number of rows dropped because of NaN or non-finite values.
+	InvalidValueRows int
+
+	// SeriesLimitRows is the number of rows dropped because of the limits on the number of series.
+	SeriesLimitRows int
+
+	// OtherDroppedRows is the number of rows dropped because of other reasons such as invalid metric names.
+	OtherDroppedRows int
+}
+
 // AddRows adds the given mrs to s.
 //
 // The caller should limit the number of concurrent AddRows calls to the number
 // of available CPU cores in order to limit memory usage.
 func (s *Storage) AddRows(mrs []MetricRow, precisionBits uint8) error {
+	var ars AddRowsStats
+	return s.AddRowsWithStats(mrs, precisionBits, &ars)
+}
+
+// AddRowsWithStats adds the given mrs to s and updates ars with the stats for the added and the dropped rows.
+//
+// The caller should limit the number of concurrent AddRowsWithStats calls to the number
+// of available CPU cores in order to limit memory usage.
+func (s *Storage) AddRowsWithStats(mrs []MetricRow, precisionBits uint8, ars *AddRowsStats) error {
 	if len(mrs) == 0 {
 		return nil
 	}
@@ -1536,7 +1716,7 @@ func (s *Storage) AddRows(mrs []MetricRow, precisionBits uint8) error {
 		} else {
 			mrs = nil
 		}
-		if err := s.add(ic.rrs, ic.tmpMrs, mrsBlock, precisionBits); err != nil {
+		if err := s.add(ic.rrs, ic.tmpMrs, mrsBlock, precisionBits, ars); err != nil {
 			if firstErr == nil {
 				firstErr = err
 			}
@@ -1595,7 +1775,7 @@ func (s *Storage) RegisterMetricNames(qt *querytracer.Tracer, mrs []MetricRow) e
 	for i := range mrs {
 		mr := &mrs[i]
 		if s.getTSIDFromCache(&genTSID, mr.MetricNameRaw) {
-			if err := s.registerSeriesCardinality(genTSID.TSID.MetricID, mr.MetricNameRaw); err != nil {
+			if err := s.registerSeriesCardinality(genTSID.TSID.MetricID, mr.MetricNameRaw, false); err != nil {
 				continue
 			}
 			if genTSID.generation == idb.generation {
@@ -1622,7 +1802,7 @@ func (s *Storage) RegisterMetricNames(qt *querytracer.Tracer, mrs []MetricRow) e
 	return nil
 }
 
-func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, precisionBits uint8) error {
+func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, precisionBits uint8, ars *AddRowsStats) error {
 	idb := s.idb()
 	is := idb.getIndexSearch(noDeadline)
 	defer idb.putIndexSearch(is)
@@ -1638,6 +1818,12 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 
 	// Return only the first error, since it has no sense in returning all errors.
 	var firstWarn error
+	// Details for the first few rows rejected because of invalid timestamps or values.
+	var rejectedRowsDetails []string
+	rejectedRows := 0
+	invalidTimestampRows := 0
+	invalidValueRows := 0
+	seriesLimitRows := 0
 	j := 0
 	for i := range mrs {
 		mr := &mrs[i]
@@ -1645,9 +1831,42 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 			if !decimal.IsStaleNaN(mr.Value) {
 				// Skip NaNs other than Prometheus staleness marker, since the underlying encoding
 				// doesn't know how to work with them.
+				invalidValueRows++
 				continue
 			}
 		}
+		if mr.Timestamp < minAllowedTimestamp {
+			if len(rejectedRowsDetails) < maxRejectedRowsDetails {
+				metricName := getUserReadableMetricName(mr.MetricNameRaw)
+				rejectedRowsDetails = append(rejectedRowsDetails, fmt.Sprintf("timestamp %d is smaller than -storage.minAllowedTimestamp=%d; metricName: %s",
+					mr.Timestamp, minAllowedTimestamp, metricName))
+			}
+			atomic.AddUint64(&s.belowMinTimestampRows, 1)
+			rejectedRows++
+			invalidTimestampRows++
+			continue
+		}
+		value := mr.Value
+		if math.IsInf(value, 0) && !allowNonFiniteValues {
+			if len(rejectedRowsDetails) < maxRejectedRowsDetails {
+				metricName := getUserReadableMetricName(mr.MetricNameRaw)
+				rejectedRowsDetails = append(rejectedRowsDetails, fmt.Sprintf("non-finite value %v isn't allowed without -storage.allowNonFiniteValues; metricName: %s",
+					value, metricName))
+			}
+			atomic.AddUint64(&s.nonFiniteValueRows, 1)
+			rejectedRows++
+			invalidValueRows++
+			continue
+		}
+		if maxAbsValue > 0 {
+			if value > maxAbsValue {
+				value = maxAbsValue
+				atomic.AddUint64(&s.clampedValueRows, 1)
+			} else if value < -maxAbsValue {
+				value = -maxAbsValue
+				atomic.AddUint64(&s.clampedValueRows, 1)
+			}
+		}
 		if mr.Timestamp < minTimestamp {
 			// Skip rows with too small timestamps outside the retention.
 			if firstWarn == nil {
@@ -1657,6 +1876,7 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 					mr.Timestamp, minTimestamp, metricName)
 			}
 			atomic.AddUint64(&s.tooSmallTimestampRows, 1)
+			invalidTimestampRows++
 			continue
 		}
 		if mr.Timestamp > maxTimestamp {
@@ -1667,13 +1887,14 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 					mr.Timestamp, maxTimestamp, metricName)
 			}
 			atomic.AddUint64(&s.tooBigTimestampRows, 1)
+			invalidTimestampRows++
 			continue
 		}
 		dstMrs[j] = mr
 		r := &rows[j]
 		j++
 		r.Timestamp = mr.Timestamp
-		r.Value = mr.Value
+		r.Value = value
 		r.PrecisionBits = precisionBits
 		if string(mr.MetricNameRaw) == string(prevMetricNameRaw) {
 			// Fast path - the current mr contains the same metric name as the previous mr, so it contains the same TSID.
@@ -1682,8 +1903,9 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 			continue
 		}
 		if s.getTSIDFromCache(&genTSID, mr.MetricNameRaw) {
-			if err := s.registerSeriesCardinality(r.TSID.MetricID, mr.MetricNameRaw); err != nil {
+			if err := s.registerSeriesCardinality(genTSID.TSID.MetricID, mr.MetricNameRaw, false); err != nil {
 				j--
+				seriesLimitRows++
 				continue
 			}
 			r.TSID = genTSID.TSID
@@ -1755,6 +1977,7 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 			if err := is.GetOrCreateTSIDByName(&r.TSID, pmr.MetricName, mr.MetricNameRaw, date); err != nil {
 				j--
 				if errors.Is(err, errSeriesCardinalityExceeded) {
+					seriesLimitRows++
 					continue
 				}
 				// Do not stop adding rows on error - just skip invalid row.
@@ -1778,6 +2001,9 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 	if firstWarn != nil {
 		storageAddRowsLogger.Warnf("warn occurred during rows addition: %s", firstWarn)
 	}
+	if rejectedRows > 0 {
+		storageAddRowsLogger.Warnf("rejected %d rows with invalid timestamps or values; the first rejected rows: %s", rejectedRows, strings.Join(rejectedRowsDetails, " | "))
+	}
 	dstMrs = dstMrs[:j]
 	rows = rows[:j]
 
@@ -1790,15 +2016,34 @@ func (s *Storage) add(rows []rawRow, dstMrs []*MetricRow, mrs []MetricRow, preci
 			err = fmt.Errorf("cannot add rows to table: %w", err)
 		}
 	}
+	if err == nil {
+		s.metricUsageTracker.registerIngestedRows(dstMrs)
+	}
 	if err != nil {
 		return fmt.Errorf("error occurred during rows addition: %w", err)
 	}
+	ars.RowsAdded += j
+	ars.InvalidTimestampRows += invalidTimestampRows
+	ars.InvalidValueRows += invalidValueRows
+	ars.SeriesLimitRows += seriesLimitRows
+	ars.OtherDroppedRows += len(mrs) - j - invalidTimestampRows - invalidValueRows - seriesLimitRows
 	return nil
 }
 
 var storageAddRowsLogger = logger.WithThrottler("storageAddRows", 5*time.Second)
 
-func (s *Storage) registerSeriesCardinality(metricID uint64, metricNameRaw []byte) error {
+// registerSeriesCardinality registers the series with the given metricID and metricNameRaw in series limiters.
+//
+// isNewSeries must be set if the series has been just created. See registerSeriesBudget for details.
+func (s *Storage) registerSeriesCardinality(metricID uint64, metricNameRaw []byte, isNewSeries bool) error {
+	// Check series budgets before the global limiters, since new series rejected by budgets obtain new metricIDs
+	// on every retry. Otherwise such series would occupy slots in -storage.maxHourlySeries and -storage.maxDailySeries limiters.
+	if s.getSeriesBudgets() != nil {
+		metricGroup := getMetricGroupFromRaw(metricNameRaw)
+		if err := s.registerSeriesBudget(metricID, metricGroup, isNewSeries); err != nil {
+			return err
+		}
+	}
 	if sl := s.hourlySeriesLimiter; sl != nil && !sl.Add(metricID) {
 		atomic.AddUint64(&s.hourlySeriesLimitRowsDropped, 1)
 		logSkippedSeries(metricNameRaw, "-storage.maxHourlySeries", sl.MaxItems())
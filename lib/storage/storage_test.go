@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -61,6 +62,51 @@ func TestGetRegexpForGraphiteNodeQuery(t *testing.T) {
 	f(`foo{bar,,b{{a,b*},z},[x-y]*z}a`, `^foo(?:bar||b(?:(?:a|b[^.]*)|z)|[x-y][^.]*z)a$`)
 }
 
+func TestFreeDiskSpaceHysteresis(t *testing.T) {
+	limitOrig, recoveryOrig, delayOrig := freeDiskSpaceLimitBytes, freeDiskSpaceRecoveryBytes, freeDiskSpaceRecoveryDelay
+	defer func() {
+		freeDiskSpaceLimitBytes, freeDiskSpaceRecoveryBytes, freeDiskSpaceRecoveryDelay = limitOrig, recoveryOrig, delayOrig
+	}()
+	SetFreeDiskSpaceLimit(100)
+	SetFreeDiskSpaceRecoveryLimit(200, 10*time.Second)
+
+	var h freeDiskSpaceHysteresis
+	isReadOnly := false
+	ct := time.Unix(1000, 0)
+	f := func(freeSpaceBytes uint64, d time.Duration, resultExpected bool) {
+		t.Helper()
+		ct = ct.Add(d)
+		isReadOnly = h.isReadOnly(isReadOnly, freeSpaceBytes, ct)
+		if isReadOnly != resultExpected {
+			t.Fatalf("unexpected read-only state for freeSpaceBytes=%d at %s; got %v; want %v", freeSpaceBytes, ct, isReadOnly, resultExpected)
+		}
+	}
+
+	// Read-write mode is preserved while there is enough free space
+	f(150, 0, false)
+
+	// Switch to read-only mode
+	f(50, time.Second, true)
+
+	// The free space is bigger than -storage.minFreeDiskSpaceBytes, but smaller than -storage.minFreeDiskSpaceRecoveryBytes
+	f(150, time.Second, true)
+
+	// The free space must stay above -storage.minFreeDiskSpaceRecoveryBytes during -storage.readOnlyRecoveryDelay
+	f(250, time.Second, true)
+	f(250, 5*time.Second, true)
+
+	// The free space drops below -storage.minFreeDiskSpaceRecoveryBytes, so the delay is reset
+	f(150, time.Second, true)
+	f(250, time.Second, true)
+	f(250, 9*time.Second, true)
+	f(250, time.Second, false)
+
+	// Recovery limit smaller than the free disk space limit
+	SetFreeDiskSpaceRecoveryLimit(0, 0)
+	f(50, time.Second, true)
+	f(100, time.Second, false)
+}
+
 func TestDateMetricIDCacheSerial(t *testing.T) {
 	c := newDateMetricIDCache()
 	if err := testDateMetricIDCache(c, false); err != nil {
@@ -433,6 +479,126 @@ func TestStorageOpenClose(t *testing.T) {
 	}
 }
 
+func TestStoragePartitionGranularity(t *testing.T) {
+	path := "TestStoragePartitionGranularity"
+	defer func() {
+		if err := SetPartitionGranularity(PartitionGranularityMonthly); err != nil {
+			t.Fatalf("cannot restore partition granularity: %s", err)
+		}
+		if err := os.RemoveAll(path); err != nil {
+			t.Fatalf("cannot remove %q: %s", path, err)
+		}
+	}()
+	setGranularity := func(granularity string) {
+		t.Helper()
+		if err := SetPartitionGranularity(granularity); err != nil {
+			t.Fatalf("cannot set partition granularity: %s", err)
+		}
+	}
+
+	setGranularity(PartitionGranularityDaily)
+	s, err := OpenStorage(path, -1, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	maxTimestamp := timestampFromTime(time.Now())
+	mrs := testGenerateMetricRows(rng, 100, maxTimestamp-3*msecPerDay, maxTimestamp)
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	s.DebugFlush()
+	ptws := s.tb.GetPartitions(nil)
+	for _, ptw := range ptws {
+		if len(ptw.pt.name) != len("2006_01_02") {
+			t.Fatalf("unexpected name for daily partition: %q", ptw.pt.name)
+		}
+	}
+	if len(ptws) < 3 {
+		t.Fatalf("unexpected number of daily partitions; got %d; want at least 3", len(ptws))
+	}
+	s.tb.PutPartitions(ptws)
+	s.MustClose()
+
+	// The storage cannot be opened with another partition granularity.
+	setGranularity(PartitionGranularityMonthly)
+	if _, err := OpenStorage(path, -1, 0, 0); err == nil || !strings.Contains(err.Error(), "daily partition granularity") {
+		t.Fatalf("expecting error about daily partition granularity; got %v", err)
+	}
+
+	// The storage can be opened with the same partition granularity.
+	setGranularity(PartitionGranularityDaily)
+	s, err = OpenStorage(path, -1, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	s.MustClose()
+}
+
+func TestStorageAddRowsValidation(t *testing.T) {
+	path := "TestStorageAddRowsValidation"
+	maxTimestamp := timestampFromTime(time.Now())
+	SetMinAllowedTimestamp(maxTimestamp - 10*msecPerDay)
+	SetAllowNonFiniteValues(false)
+	SetMaxAbsValue(1e9)
+	defer func() {
+		SetMinAllowedTimestamp(math.MinInt64)
+		SetAllowNonFiniteValues(true)
+		SetMaxAbsValue(0)
+		if err := os.RemoveAll(path); err != nil {
+			t.Fatalf("cannot remove %q: %s", path, err)
+		}
+	}()
+	s, err := OpenStorage(path, -1, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	defer s.MustClose()
+
+	rng := rand.New(rand.NewSource(1))
+	mrs := testGenerateMetricRows(rng, 10, maxTimestamp-msecPerDay, maxTimestamp)
+	for i := range mrs {
+		mr := &mrs[i]
+		switch i % 5 {
+		case 0:
+			mr.Timestamp = 0
+		case 1:
+			mr.Value = math.Inf(1)
+		case 2:
+			mr.Value = -1e308
+		case 3:
+			mr.Value = 1e300
+		}
+	}
+	var ars AddRowsStats
+	if err := s.AddRowsWithStats(mrs, defaultPrecisionBits, &ars); err != nil {
+		t.Fatalf("rejected rows mustn't fail the whole request; got error: %s", err)
+	}
+	arsExpected := AddRowsStats{
+		RowsAdded:            6,
+		InvalidTimestampRows: 2,
+		InvalidValueRows:     2,
+	}
+	if ars != arsExpected {
+		t.Fatalf("unexpected AddRowsStats; got %+v; want %+v", ars, arsExpected)
+	}
+	s.DebugFlush()
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.BelowMinTimestampRows != 2 {
+		t.Fatalf("unexpected number of rows with too small timestamps; got %d; want 2", m.BelowMinTimestampRows)
+	}
+	if m.NonFiniteValueRows != 2 {
+		t.Fatalf("unexpected number of rows with non-finite values; got %d; want 2", m.NonFiniteValueRows)
+	}
+	if m.ClampedValueRows != 4 {
+		t.Fatalf("unexpected number of clamped rows; got %d; want 4", m.ClampedValueRows)
+	}
+	if n := m.TableMetrics.TotalRowsCount(); n != 6 {
+		t.Fatalf("unexpected number of stored rows; got %d; want 6", n)
+	}
+}
+
 func TestStorageOpenMultipleTimes(t *testing.T) {
 	path := "TestStorageOpenMultipleTimes"
 	s1, err := OpenStorage(path, -1, 0, 0)
@@ -1050,10 +1216,30 @@ func testStorageAddRows(rng *rand.Rand, s *Storage) error {
 		return fmt.Errorf("snapshot %q must contain at least %d rows; got %d", snapshotPath, minRowsExpected, rowsCount)
 	}
 
+	// Verify that force merge estimates cover all the parts.
+	fmes := s1.GetForceMergeEstimates("")
+	partsExpected := 0
+	for _, fme := range fmes {
+		if fme.Parts > 0 && fme.SizeBytes == 0 {
+			return fmt.Errorf("unexpected zero size for %d parts to merge in partition %q", fme.Parts, fme.Partition)
+		}
+		partsExpected += fme.Parts
+	}
+
 	// Verify that force merge for the snapshot leaves only a single part per partition.
-	if err := s1.ForceMergePartitions(""); err != nil {
+	var fmp ForceMergeProgress
+	if err := s1.ForceMergePartitions("", &fmp); err != nil {
 		return fmt.Errorf("error when force merging partitions: %w", err)
 	}
+	if fmp.PartsMerged != fmp.PartsTotal {
+		return fmt.Errorf("unexpected number of merged parts; got %d; want %d", fmp.PartsMerged, fmp.PartsTotal)
+	}
+	if fmp.PartsTotal < uint64(partsExpected) {
+		return fmt.Errorf("unexpected number of parts selected for the merge; got %d; want at least %d", fmp.PartsTotal, partsExpected)
+	}
+	if fmp.PartsMerged > 0 && fmp.BytesWritten == 0 {
+		return fmt.Errorf("expecting non-zero bytes written after merging %d parts", fmp.PartsMerged)
+	}
 	ptws := s1.tb.GetPartitions(nil)
 	for _, ptw := range ptws {
 		pws := ptw.pt.GetParts(nil, true)
@@ -260,7 +260,8 @@ func (tb *table) UpdateMetrics(m *TableMetrics) {
 // ForceMergePartitions force-merges partitions in tb with names starting from the given partitionNamePrefix.
 //
 // Partitions are merged sequentially in order to reduce load on the system.
-func (tb *table) ForceMergePartitions(partitionNamePrefix string) error {
+// The merge progress is tracked in fmp if it isn't nil.
+func (tb *table) ForceMergePartitions(partitionNamePrefix string, fmp *ForceMergeProgress) error {
 	ptws := tb.GetPartitions(nil)
 	defer tb.PutPartitions(ptws)
 	for _, ptw := range ptws {
@@ -269,7 +270,7 @@ func (tb *table) ForceMergePartitions(partitionNamePrefix string) error {
 		}
 		logger.Infof("starting forced merge for partition %q", ptw.pt.name)
 		startTime := time.Now()
-		if err := ptw.pt.ForceMergeAllParts(); err != nil {
+		if err := ptw.pt.ForceMergeAllParts(fmp); err != nil {
 			return fmt.Errorf("cannot complete forced merge for partition %q: %w", ptw.pt.name, err)
 		}
 		logger.Infof("forced merge for partition %q has been finished in %.3f seconds", ptw.pt.name, time.Since(startTime).Seconds())
@@ -277,6 +278,37 @@ func (tb *table) ForceMergePartitions(partitionNamePrefix string) error {
 	return nil
 }
 
+// ForceMergeEstimate contains the estimated amounts of work for forced merge of a single partition.
+type ForceMergeEstimate struct {
+	// Partition is the partition name.
+	Partition string `json:"partition"`
+
+	// Parts is the number of parts to merge.
+	Parts int `json:"parts"`
+
+	// SizeBytes is the size of the parts to merge.
+	SizeBytes uint64 `json:"sizeBytes"`
+}
+
+// GetForceMergeEstimates returns estimates for ForceMergePartitions call with the given partitionNamePrefix.
+func (tb *table) GetForceMergeEstimates(partitionNamePrefix string) []ForceMergeEstimate {
+	ptws := tb.GetPartitions(nil)
+	defer tb.PutPartitions(ptws)
+	var fmes []ForceMergeEstimate
+	for _, ptw := range ptws {
+		if !strings.HasPrefix(ptw.pt.name, partitionNamePrefix) {
+			continue
+		}
+		parts, size := ptw.pt.getForceMergeEstimate()
+		fmes = append(fmes, ForceMergeEstimate{
+			Partition: ptw.pt.name,
+			Parts:     parts,
+			SizeBytes: size,
+		})
+	}
+	return fmes
+}
+
 // AddRows adds the given rows to the table tb.
 func (tb *table) AddRows(rows []rawRow) error {
 	if len(rows) == 0 {
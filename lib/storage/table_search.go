@@ -22,6 +22,9 @@ type tableSearch struct {
 	ptsPool []partitionSearch
 	ptsHeap partitionSearchHeap
 
+	// partsSkippedByBloomFilter is the number of parts skipped at Init by their bloom filters.
+	partsSkippedByBloomFilter int
+
 	err error
 
 	nextBlockNoop bool
@@ -47,6 +50,7 @@ func (ts *tableSearch) reset() {
 	}
 	ts.ptsHeap = ts.ptsHeap[:0]
 
+	ts.partsSkippedByBloomFilter = 0
 	ts.err = nil
 	ts.nextBlockNoop = false
 	ts.needClosing = false
@@ -89,8 +93,11 @@ func (ts *tableSearch) Init(tb *table, tsids []TSID, tr TimeRange) {
 	}
 	ts.ptsPool = ts.ptsPool[:len(ts.ptws)]
 	for i, ptw := range ts.ptws {
-		ts.ptsPool[i].Init(ptw.pt, tsids, tr)
+		pts := &ts.ptsPool[i]
+		pts.Init(ptw.pt, tsids, tr)
+		ts.partsSkippedByBloomFilter += pts.partsSkippedByBloomFilter
 	}
+	addPartsSkippedByBloomFilter(uint64(ts.partsSkippedByBloomFilter))
 
 	// Initialize the ptsHeap.
 	ts.ptsHeap = ts.ptsHeap[:0]
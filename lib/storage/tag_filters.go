@@ -323,6 +323,25 @@ func (tf *tagFilter) getOp() string {
 	return "="
 }
 
+// searchStrategy returns human-readable description of the strategy used for searching tf matches in the index.
+//
+// The returned value is used in query traces.
+func (tf *tagFilter) searchStrategy() string {
+	if len(tf.orSuffixes) > 0 {
+		if !tf.isRegexp {
+			return "exact value lookup"
+		}
+		if len(tf.regexpPrefix) > 0 {
+			return fmt.Sprintf("exact lookups for %d values with prefix %q", len(tf.orSuffixes), tf.regexpPrefix)
+		}
+		return fmt.Sprintf("exact lookups for %d values", len(tf.orSuffixes))
+	}
+	if len(tf.regexpPrefix) > 0 {
+		return fmt.Sprintf("range scan for values with prefix %q", tf.regexpPrefix)
+	}
+	return "full scan of label values"
+}
+
 // Marshal appends marshaled tf to dst
 // and returns the result.
 func (tf *tagFilter) Marshal(dst []byte) []byte {
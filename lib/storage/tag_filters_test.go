@@ -1201,6 +1201,48 @@ func TestSimplifyRegexp(t *testing.T) {
 	f("(foo|bar$)x*", "", "(?:foo|bar$)x*")
 }
 
+func TestTagFilterSearchStrategy(t *testing.T) {
+	f := func(value string, isRegexp bool, strategyExpected string) {
+		t.Helper()
+		var tf tagFilter
+		if err := tf.Init(nil, []byte("pod"), []byte(value), false, isRegexp); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		strategy := tf.searchStrategy()
+		if strategy != strategyExpected {
+			t.Fatalf("unexpected search strategy for %q; got %q; want %q", value, strategy, strategyExpected)
+		}
+	}
+
+	// Plain values
+	f("checkout", false, "exact value lookup")
+	f("checkout", true, "exact value lookup")
+
+	// Regexps with literal prefix
+	f("checkout-.*", true, `range scan for values with prefix "checkout-"`)
+	f("checkout-.+", true, `range scan for values with prefix "checkout-"`)
+	f("checkout-[0-9]+", true, `range scan for values with prefix "checkout-"`)
+
+	// Regexps are implicitly anchored, so explicit anchors don't disable the fast path
+	f("^checkout-.*", true, `range scan for values with prefix "checkout-"`)
+	f("checkout-.*$", true, `range scan for values with prefix "checkout-"`)
+	f("^checkout-.*$", true, `range scan for values with prefix "checkout-"`)
+	f("^checkout$", true, "exact value lookup")
+
+	// Alternations of literals
+	f("foo|bar", true, "exact lookups for 2 values")
+	f("^foo|bar$", true, "exact lookups for 2 values")
+	f("checkout-(main|canary)", true, `exact lookups for 2 values with prefix "checkout-"`)
+
+	// Regexps without literal prefix
+	f(".*-checkout", true, "full scan of label values")
+	f("[a-z]+-checkout", true, "full scan of label values")
+
+	// Case-insensitive regexps cannot use the literal prefix
+	f("(?i)checkout-.*", true, "full scan of label values")
+	f("(?i)foo|bar", true, "full scan of label values")
+}
+
 func TestTagFiltersString(t *testing.T) {
 	tfs := NewTagFilters()
 	mustAdd := func(key, value string, isNegative, isRegexp bool) {
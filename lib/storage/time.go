@@ -3,6 +3,8 @@ package storage
 import (
 	"fmt"
 	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/partitionname"
 )
 
 func dateToString(date uint64) string {
@@ -45,17 +47,43 @@ func TimestampToHumanReadableFormat(timestamp int64) string {
 	return t.Format("2006-01-02T15:04:05.999Z")
 }
 
+// Supported partition granularities. See SetPartitionGranularity.
+const (
+	PartitionGranularityMonthly = partitionname.Monthly
+	PartitionGranularityWeekly  = partitionname.Weekly
+	PartitionGranularityDaily   = partitionname.Daily
+)
+
+var partitionGranularity = PartitionGranularityMonthly
+
+// SetPartitionGranularity sets the time range covered by a single partition.
+//
+// Supported values: monthly, weekly and daily. Monthly granularity is used by default.
+// Weekly partitions start on Monday according to ISO 8601.
+//
+// This function must be called before OpenStorage.
+func SetPartitionGranularity(granularity string) error {
+	switch granularity {
+	case PartitionGranularityMonthly, PartitionGranularityWeekly, PartitionGranularityDaily:
+		partitionGranularity = granularity
+		return nil
+	default:
+		return fmt.Errorf("unsupported partition granularity %q; supported values: %s, %s, %s",
+			granularity, PartitionGranularityMonthly, PartitionGranularityWeekly, PartitionGranularityDaily)
+	}
+}
+
 // timestampToPartitionName returns partition name for the given timestamp.
 func timestampToPartitionName(timestamp int64) string {
 	t := timestampToTime(timestamp)
-	return t.Format("2006_01")
+	return partitionname.Format(t, partitionGranularity)
 }
 
 // fromPartitionName initializes tr from the given partition name.
 func (tr *TimeRange) fromPartitionName(name string) error {
-	t, err := time.Parse("2006_01", name)
+	t, err := partitionname.Parse(name, partitionGranularity)
 	if err != nil {
-		return fmt.Errorf("cannot parse partition name %q: %w", name, err)
+		return err
 	}
 	tr.fromPartitionTime(t)
 	return nil
@@ -69,9 +97,7 @@ func (tr *TimeRange) fromPartitionTimestamp(timestamp int64) {
 
 // fromPartitionTime initializes tr from the given partition time t.
 func (tr *TimeRange) fromPartitionTime(t time.Time) {
-	y, m, _ := t.UTC().Date()
-	minTime := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
-	maxTime := time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+	minTime, maxTime := partitionname.TimeRange(t, partitionGranularity)
 	tr.MinTimestamp = minTime.Unix() * 1e3
 	tr.MaxTimestamp = maxTime.Unix()*1e3 - 1
 }
@@ -52,3 +52,68 @@ func testTimeRangeFromPartition(t *testing.T, initialTime time.Time) {
 		t.Fatalf("unexpected nextY, nextM; got %d, %d; want %d, %d+1;\nnextTime=%s\nmaxTime=%s", nextY, nextM, maxY, maxM, nextTime, maxTime)
 	}
 }
+
+func TestPartitionGranularity(t *testing.T) {
+	defer func() {
+		if err := SetPartitionGranularity(PartitionGranularityMonthly); err != nil {
+			t.Fatalf("cannot restore partition granularity: %s", err)
+		}
+	}()
+
+	f := func(granularity string, timestamp string, nameExpected string, trExpected string) {
+		t.Helper()
+		if err := SetPartitionGranularity(granularity); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		ts, err := time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			t.Fatalf("cannot parse timestamp: %s", err)
+		}
+		name := timestampToPartitionName(timestampFromTime(ts))
+		if name != nameExpected {
+			t.Fatalf("unexpected partition name for %s; got %q; want %q", timestamp, name, nameExpected)
+		}
+		var tr TimeRange
+		tr.fromPartitionTimestamp(timestampFromTime(ts))
+		if s := tr.String(); s != trExpected {
+			t.Fatalf("unexpected time range for %s; got %s; want %s", timestamp, s, trExpected)
+		}
+		var trFromName TimeRange
+		if err := trFromName.fromPartitionName(name); err != nil {
+			t.Fatalf("cannot parse partition name %q: %s", name, err)
+		}
+		if trFromName != tr {
+			t.Fatalf("unexpected time range for partition name %q; got %s; want %s", name, &trFromName, &tr)
+		}
+	}
+	f(PartitionGranularityMonthly, "2023-02-15T10:20:30Z", "2023_02", "[2023-02-01T00:00:00Z..2023-02-28T23:59:59.999Z]")
+	f(PartitionGranularityDaily, "2023-02-15T10:20:30Z", "2023_02_15", "[2023-02-15T00:00:00Z..2023-02-15T23:59:59.999Z]")
+	f(PartitionGranularityWeekly, "2023-02-15T10:20:30Z", "2023_w07", "[2023-02-13T00:00:00Z..2023-02-19T23:59:59.999Z]")
+
+	// ISO weeks, which cross year boundaries
+	f(PartitionGranularityWeekly, "2021-01-01T00:00:00Z", "2020_w53", "[2020-12-28T00:00:00Z..2021-01-03T23:59:59.999Z]")
+	f(PartitionGranularityWeekly, "2024-12-31T23:59:59Z", "2025_w01", "[2024-12-30T00:00:00Z..2025-01-05T23:59:59.999Z]")
+
+	// Partition names for other granularities must be rejected
+	fError := func(granularity, name string) {
+		t.Helper()
+		if err := SetPartitionGranularity(granularity); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var tr TimeRange
+		if err := tr.fromPartitionName(name); err == nil {
+			t.Fatalf("expecting non-nil error when parsing partition name %q for %s granularity", name, granularity)
+		}
+	}
+	fError(PartitionGranularityMonthly, "2023_02_15")
+	fError(PartitionGranularityMonthly, "2023_w07")
+	fError(PartitionGranularityDaily, "2023_02")
+	fError(PartitionGranularityDaily, "2023_w07")
+	fError(PartitionGranularityWeekly, "2023_02")
+	fError(PartitionGranularityWeekly, "2023_02_15")
+	fError(PartitionGranularityWeekly, "2023_w53")
+
+	if err := SetPartitionGranularity("hourly"); err == nil {
+		t.Fatalf("expecting non-nil error for unsupported granularity")
+	}
+}
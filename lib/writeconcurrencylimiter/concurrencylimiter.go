@@ -6,9 +6,11 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timerpool"
 	"github.com/VictoriaMetrics/metrics"
@@ -22,6 +24,11 @@ var (
 		"concurrent insert requests are executed")
 )
 
+func init() {
+	flagutil.RegisterReloadable("maxConcurrentInserts", updateConcurrencyLimitCh)
+	flagutil.RegisterReloadable("insert.maxQueueDuration", updateMaxQueueDuration)
+}
+
 // Reader is a reader, which increases the concurrency after the first Read() call
 //
 // The concurrency can be reduced by calling DecConcurrency().
@@ -29,6 +36,12 @@ var (
 type Reader struct {
 	r                    io.Reader
 	increasedConcurrency bool
+
+	// concurrencyLimitCh is the channel, which was used for increasing the concurrency.
+	//
+	// The concurrency must be decreased at the same channel, since the channel can be changed
+	// when -maxConcurrentInserts is reloaded.
+	concurrencyLimitCh chan struct{}
 }
 
 // GetReader returns the Reader for r.
@@ -63,17 +76,19 @@ var readerPool sync.Pool
 func (r *Reader) Read(p []byte) (int, error) {
 	n, err := r.r.Read(p)
 	if !r.increasedConcurrency {
-		if !incConcurrency() {
+		ch := getConcurrencyLimitCh()
+		if !incConcurrency(ch) {
 			err = &httpserver.ErrorWithStatusCode{
 				Err: fmt.Errorf("cannot process insert request for %.3f seconds because %d concurrent insert requests are executed. "+
 					"Possible solutions: to reduce workload; to increase compute resources at the server; "+
 					"to increase -insert.maxQueueDuration; to increase -maxConcurrentInserts",
-					maxQueueDuration.Seconds(), *maxConcurrentInserts),
+					getMaxQueueDuration().Seconds(), cap(ch)),
 				StatusCode: http.StatusServiceUnavailable,
 			}
 			return 0, err
 		}
 		r.increasedConcurrency = true
+		r.concurrencyLimitCh = ch
 	}
 	return n, err
 }
@@ -81,33 +96,56 @@ func (r *Reader) Read(p []byte) (int, error) {
 // DecConcurrency decreases the concurrency, so it could be increased again after the next Read() call.
 func (r *Reader) DecConcurrency() {
 	if r.increasedConcurrency {
-		decConcurrency()
+		decConcurrency(r.concurrencyLimitCh)
 		r.increasedConcurrency = false
+		r.concurrencyLimitCh = nil
 	}
 }
 
-func initConcurrencyLimitCh() {
-	concurrencyLimitCh = make(chan struct{}, *maxConcurrentInserts)
+func initConcurrencyLimiter() {
+	updateConcurrencyLimitCh()
+	updateMaxQueueDuration()
+}
+
+// updateConcurrencyLimitCh sets the concurrency limit channel according to -maxConcurrentInserts.
+//
+// Requests, which already increased the concurrency at the previous channel, continue using it until completion,
+// so the concurrency may temporarily exceed the updated -maxConcurrentInserts.
+func updateConcurrencyLimitCh() {
+	concurrencyLimitCh.Store(make(chan struct{}, *maxConcurrentInserts))
+}
+
+func updateMaxQueueDuration() {
+	atomic.StoreInt64(&maxQueueDurationNsecs, int64(*maxQueueDuration))
+}
+
+func getConcurrencyLimitCh() chan struct{} {
+	concurrencyLimiterOnce.Do(initConcurrencyLimiter)
+	return concurrencyLimitCh.Load().(chan struct{})
+}
+
+func getMaxQueueDuration() time.Duration {
+	concurrencyLimiterOnce.Do(initConcurrencyLimiter)
+	return time.Duration(atomic.LoadInt64(&maxQueueDurationNsecs))
 }
 
 var (
-	concurrencyLimitCh     chan struct{}
-	concurrencyLimitChOnce sync.Once
+	concurrencyLimitCh     atomic.Value
+	maxQueueDurationNsecs  int64
+	concurrencyLimiterOnce sync.Once
 )
 
-func incConcurrency() bool {
-	concurrencyLimitChOnce.Do(initConcurrencyLimitCh)
-
+func incConcurrency(ch chan struct{}) bool {
 	select {
-	case concurrencyLimitCh <- struct{}{}:
+	case ch <- struct{}{}:
 		return true
 	default:
 	}
 
 	concurrencyLimitReached.Inc()
-	t := timerpool.Get(*maxQueueDuration)
+	t := timerpool.Get(getMaxQueueDuration())
 	select {
-	case concurrencyLimitCh <- struct{}{}:
+	case ch <- struct{}{}:
 		timerpool.Put(t)
 		return true
 	case <-t.C:
@@ -117,8 +155,8 @@ func incConcurrency() bool {
 	}
 }
 
-func decConcurrency() {
-	<-concurrencyLimitCh
+func decConcurrency(ch chan struct{}) {
+	<-ch
 }
 
 var (
@@ -126,11 +164,9 @@ var (
 	concurrencyLimitTimeout = metrics.NewCounter(`vm_concurrent_insert_limit_timeout_total`)
 
 	_ = metrics.NewGauge(`vm_concurrent_insert_capacity`, func() float64 {
-		concurrencyLimitChOnce.Do(initConcurrencyLimitCh)
-		return float64(cap(concurrencyLimitCh))
+		return float64(cap(getConcurrencyLimitCh()))
 	})
 	_ = metrics.NewGauge(`vm_concurrent_insert_current`, func() float64 {
-		concurrencyLimitChOnce.Do(initConcurrencyLimitCh)
-		return float64(len(concurrencyLimitCh))
+		return float64(len(getConcurrencyLimitCh()))
 	})
 )